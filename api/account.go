@@ -33,6 +33,11 @@ type (
 		// an account and the balance reported by a host.
 		Drift *big.Int `json:"drift"`
 
+		// Drifted indicates whether the balance reported by the host diverged
+		// from the tracked balance by more than the allowed threshold. Drifted
+		// accounts can't be used for spending until their drift is reset.
+		Drifted bool `json:"drifted"`
+
 		// RequiresSync indicates whether an account needs to be synced with the
 		// host before it can be used again.
 		RequiresSync bool `json:"requiresSync"`
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	rhpv3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/siad/build"
 )
@@ -51,15 +53,40 @@ type (
 		Upload      uint64         `json:"upload"`
 		Storage     uint64         `json:"storage"`
 		Prune       bool           `json:"prune"`
+
+		// SpendBudget caps the cumulative contract spending within a period,
+		// once exceeded no contracts are formed, renewed or refreshed until
+		// the period rolls over. A zero budget disables the cap.
+		SpendBudget types.Currency `json:"spendBudget"`
+
+		// MinWalletReserve is the spendable wallet balance the autopilot
+		// keeps in reserve for fees, no contracts are formed once spending
+		// would dip below it. Renewals and refreshes only dip into the
+		// reserve if ReserveAllowRenewals is set. A zero reserve disables
+		// the check.
+		MinWalletReserve     types.Currency `json:"minWalletReserve"`
+		ReserveAllowRenewals bool           `json:"reserveAllowRenewals"`
+
+		// MaxHostFunding caps the funds allocated to a single contract when
+		// it's formed, renewed or refreshed and MaxHostStorage caps the
+		// amount of data a contract is sized for, this limits how much we
+		// depend on a single host. The caps apply per contract, not to the
+		// sum of all contracts with a host, and hosts whose contract price
+		// exceeds the funding cap are skipped. Funds that aren't allocated to
+		// a host remain available for others. A zero value disables the cap.
+		MaxHostFunding types.Currency `json:"maxHostFunding"`
+		MaxHostStorage uint64         `json:"maxHostStorage"`
 	}
 
 	// HostsConfig contains all hosts settings used in the autopilot.
 	HostsConfig struct {
-		AllowRedundantIPs     bool                        `json:"allowRedundantIPs"`
-		MaxDowntimeHours      uint64                      `json:"maxDowntimeHours"`
-		MinProtocolVersion    string                      `json:"minProtocolVersion"`
-		MinRecentScanFailures uint64                      `json:"minRecentScanFailures"`
-		ScoreOverrides        map[types.PublicKey]float64 `json:"scoreOverrides"`
+		AllowRedundantIPs       bool                        `json:"allowRedundantIPs"`
+		DiversityWeight         float64                     `json:"diversityWeight"`
+		MaxDowntimeHours        uint64                      `json:"maxDowntimeHours"`
+		MinProtocolVersion      string                      `json:"minProtocolVersion"`
+		MinRecentScanFailures   uint64                      `json:"minRecentScanFailures"`
+		RemovalGracePeriodHours uint64                      `json:"removalGracePeriodHours"`
+		ScoreOverrides          map[types.PublicKey]float64 `json:"scoreOverrides"`
 	}
 )
 
@@ -98,6 +125,125 @@ type (
 		BuildState
 	}
 
+	// ScannerStatusResponse is the response type for the
+	// /autopilot/scanner/status endpoint.
+	ScannerStatusResponse struct {
+		Scanning            bool        `json:"scanning"`
+		Queued              uint64      `json:"queued"`
+		Scanned             uint64      `json:"scanned"`
+		Offset              int         `json:"offset"`
+		StartTime           TimeRFC3339 `json:"startTime"`
+		EstimatedCompletion TimeRFC3339 `json:"estimatedCompletion"`
+	}
+
+	// AutopilotScanResponse is the response type for the /autopilot/scan
+	// endpoint, indicating whether a host scan was started.
+	AutopilotScanResponse struct {
+		Started bool `json:"started"`
+	}
+
+	// MigratorStatusResponse is the response type for the
+	// /autopilot/migrator/status endpoint.
+	MigratorStatusResponse struct {
+		Migrating bool        `json:"migrating"`
+		Paused    bool        `json:"paused"`
+		Queued    uint64      `json:"queued"`
+		Migrated  uint64      `json:"migrated"`
+		Failed    uint64      `json:"failed"`
+		StartTime TimeRFC3339 `json:"startTime"`
+	}
+
+	// ScanResult is the outcome of a single host scan, it's returned by the
+	// /autopilot/scanner/scans endpoint.
+	ScanResult struct {
+		HostKey   types.PublicKey `json:"hostKey"`
+		Timestamp TimeRFC3339     `json:"timestamp"`
+		Latency   DurationMS      `json:"latency"`
+		Error     string          `json:"error,omitempty"`
+	}
+
+	// ContractsPruneResponse is the response type for the
+	// /autopilot/contracts/prune endpoint.
+	ContractsPruneResponse struct {
+		Contracts   []ContractPruneResult `json:"contracts"`
+		TotalPruned uint64                `json:"totalPruned"`
+	}
+
+	// ContractsPreviewResponse is the response type for the
+	// /autopilot/contracts/preview endpoint.
+	ContractsPreviewResponse struct {
+		Add   []ContractSetChange `json:"add"`
+		Renew []ContractSetChange `json:"renew"`
+		Drop  []ContractSetChange `json:"drop"`
+	}
+
+	// ContractFormationEstimateRequest is the request type for the
+	// /autopilot/formation/estimate endpoint. Fields that are left empty
+	// default to the autopilot's config and the redundancy settings.
+	ContractFormationEstimateRequest struct {
+		Hosts      uint64  `json:"hosts"`
+		Redundancy float64 `json:"redundancy"`
+		Storage    uint64  `json:"storage"`
+		Period     uint64  `json:"period"`
+	}
+
+	// ContractFormationEstimateResponse is the response type for the
+	// /autopilot/formation/estimate endpoint. It contains the estimated
+	// upfront cost of forming contracts with the selected hosts, the funding
+	// covers uploading and storing the data while the fees cover the
+	// contract price, the siafund tax and the transaction fee.
+	ContractFormationEstimateResponse struct {
+		Hosts      []HostFormationEstimate `json:"hosts"`
+		Collateral types.Currency          `json:"collateral"`
+		Fees       types.Currency          `json:"fees"`
+		Funding    types.Currency          `json:"funding"`
+	}
+
+	// HostFormationEstimate contains the estimated cost of forming a contract
+	// with a single host.
+	HostFormationEstimate struct {
+		HostKey    types.PublicKey `json:"hostKey"`
+		Storage    uint64          `json:"storage"`
+		Collateral types.Currency  `json:"collateral"`
+		Fees       types.Currency  `json:"fees"`
+		Funding    types.Currency  `json:"funding"`
+	}
+
+	// ContractSetChange describes a single change contract maintenance would
+	// make to the contract set, the contract id is not set for additions.
+	ContractSetChange struct {
+		ContractID types.FileContractID `json:"contractID"`
+		HostKey    types.PublicKey      `json:"hostKey"`
+		Reason     string               `json:"reason"`
+	}
+
+	// ContractPruneResult contains the amount of data that was reclaimed by
+	// pruning a single contract.
+	ContractPruneResult struct {
+		ContractID types.FileContractID `json:"contractID"`
+		HostKey    types.PublicKey      `json:"hostKey"`
+		Pruned     uint64               `json:"pruned"`
+		Remaining  uint64               `json:"remaining"`
+		Error      string               `json:"error,omitempty"`
+	}
+
+	// AccountsRebalanceResponse is the response type for the
+	// /autopilot/accounts/rebalance endpoint.
+	AccountsRebalanceResponse struct {
+		Accounts   []AccountRebalanceResult `json:"accounts"`
+		MinBalance types.Currency           `json:"minBalance"`
+		MaxBalance types.Currency           `json:"maxBalance"`
+	}
+
+	// AccountRebalanceResult contains the outcome of rebalancing a single
+	// account.
+	AccountRebalanceResult struct {
+		AccountID rhpv3.Account   `json:"accountID"`
+		HostKey   types.PublicKey `json:"hostKey"`
+		Funded    bool            `json:"funded"`
+		Error     string          `json:"error,omitempty"`
+	}
+
 	ConfigEvaluationRequest struct {
 		AutopilotConfig    AutopilotConfig    `json:"autopilotConfig"`
 		GougingSettings    GougingSettings    `json:"gougingSettings"`
@@ -133,6 +279,12 @@ func (c AutopilotConfig) Validate() error {
 		return ErrMaxDowntimeHoursTooHigh
 	} else if c.Hosts.MinProtocolVersion != "" && !build.IsVersion(c.Hosts.MinProtocolVersion) {
 		return fmt.Errorf("invalid min protocol version '%s'", c.Hosts.MinProtocolVersion)
+	} else if c.Hosts.DiversityWeight < 0 {
+		return fmt.Errorf("invalid diversity weight %v, must not be negative", c.Hosts.DiversityWeight)
+	} else if !c.Contracts.MaxHostFunding.IsZero() && c.Contracts.MaxHostFunding.Cmp(c.Contracts.Allowance) > 0 {
+		return fmt.Errorf("invalid max host funding %v, must not exceed the allowance %v", c.Contracts.MaxHostFunding, c.Contracts.Allowance)
+	} else if c.Contracts.MaxHostStorage > 0 && c.Contracts.MaxHostStorage < rhpv2.SectorSize {
+		return fmt.Errorf("invalid max host storage %v, must be at least one sector", c.Contracts.MaxHostStorage)
 	}
 	return nil
 }
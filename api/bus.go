@@ -1,9 +1,21 @@
 package api
 
 import (
+	"errors"
+
 	"go.sia.tech/core/types"
 )
 
+var (
+	// ErrConsensusResyncInProgress is returned when starting an upload or a
+	// consensus resync while a consensus resync is already in progress.
+	ErrConsensusResyncInProgress = errors.New("consensus resync in progress")
+
+	// ErrUploadsInProgress is returned when trying to resync consensus while
+	// there are uploads in progress.
+	ErrUploadsInProgress = errors.New("uploads in progress")
+)
+
 type (
 	// ConsensusState holds the current blockheight and whether we are synced or not.
 	ConsensusState struct {
@@ -44,3 +56,59 @@ type (
 		BuildState
 	}
 )
+
+type (
+	// BusHealthResponse is the response type for the /bus/health endpoint.
+	BusHealthResponse struct {
+		// Healthy is false if any of the critical subsystems, the database
+		// and consensus, is unhealthy.
+		Healthy bool `json:"healthy"`
+
+		Database  DatabaseHealth  `json:"database"`
+		Consensus ConsensusHealth `json:"consensus"`
+		Wallet    WalletHealth    `json:"wallet"`
+		Contracts ContractsHealth `json:"contracts"`
+	}
+
+	// DatabaseHealth describes whether the database is reachable.
+	DatabaseHealth struct {
+		Reachable bool   `json:"reachable"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	// ConsensusHealth describes whether the chain is synced and whether the
+	// stores caught up with the chain.
+	ConsensusHealth struct {
+		Synced      bool   `json:"synced"`
+		ChainHeight uint64 `json:"chainHeight"`
+		StoreHeight uint64 `json:"storeHeight"`
+	}
+
+	// WalletHealth describes whether the wallet is funded.
+	WalletHealth struct {
+		Funded    bool           `json:"funded"`
+		Spendable types.Currency `json:"spendable"`
+		Error     string         `json:"error,omitempty"`
+	}
+
+	// ContractsHealth describes the number of usable contracts, these are the
+	// contracts in the default contract set, and the contracts that are about
+	// to expire while still storing referenced sectors.
+	ContractsHealth struct {
+		Usable   int                `json:"usable"`
+		Expiring []ExpiringContract `json:"expiring,omitempty"`
+		Error    string             `json:"error,omitempty"`
+	}
+
+	// ExpiringContract describes a contract that is about to expire while it
+	// still stores sectors that are referenced by slabs. WindowStart is the
+	// height at which the contract's proof window starts, after which the
+	// contract can no longer be renewed.
+	ExpiringContract struct {
+		ID              types.FileContractID `json:"id"`
+		HostKey         types.PublicKey      `json:"hostKey"`
+		WindowStart     uint64               `json:"windowStart"`
+		BlocksRemaining uint64               `json:"blocksRemaining"`
+		Sectors         uint64               `json:"sectors"`
+	}
+)
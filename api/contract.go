@@ -20,6 +20,7 @@ const (
 	ContractArchivalReasonHostPruned = "hostpruned"
 	ContractArchivalReasonRemoved    = "removed"
 	ContractArchivalReasonRenewed    = "renewed"
+	ContractArchivalReasonUnknown    = "unknown"
 )
 
 var (
@@ -83,6 +84,14 @@ type (
 		FundAccount types.Currency `json:"fundAccount"`
 		Deletions   types.Currency `json:"deletions"`
 		SectorRoots types.Currency `json:"sectorRoots"`
+		Storage     types.Currency `json:"storage"`
+	}
+
+	// ContractSpendingResponse is the response type for the
+	// /contract/:id/spending endpoint.
+	ContractSpendingResponse struct {
+		ContractSpending
+		Total types.Currency `json:"total"`
 	}
 
 	ContractSpendingRecord struct {
@@ -98,10 +107,11 @@ type (
 	// An ArchivedContract contains all information about a contract with a host
 	// that has been moved to the archive either due to expiring or being renewed.
 	ArchivedContract struct {
-		ID        types.FileContractID `json:"id"`
-		HostKey   types.PublicKey      `json:"hostKey"`
-		RenewedTo types.FileContractID `json:"renewedTo"`
-		Spending  ContractSpending     `json:"spending"`
+		ID             types.FileContractID `json:"id"`
+		HostKey        types.PublicKey      `json:"hostKey"`
+		RenewedTo      types.FileContractID `json:"renewedTo"`
+		Spending       ContractSpending     `json:"spending"`
+		ArchivalReason string               `json:"archivalReason"`
 
 		ProofHeight    uint64 `json:"proofHeight"`
 		RevisionHeight uint64 `json:"revisionHeight"`
@@ -161,6 +171,13 @@ type (
 		TotalCost     types.Currency         `json:"totalCost"`
 	}
 
+	// ContractPendingResponse is the response type for the
+	// /contract/:id/pending endpoint.
+	ContractPendingResponse struct {
+		Size  uint64          `json:"size"`
+		Roots []types.Hash256 `json:"roots"`
+	}
+
 	// ContractRootsResponse is the response type for the /contract/:id/roots
 	// endpoint.
 	ContractRootsResponse struct {
@@ -179,6 +196,32 @@ type (
 		TotalSize     uint64                 `json:"totalSize"`
 	}
 
+	// ContractSetStats contains aggregate metrics of the contracts in a
+	// contract set.
+	ContractSetStats struct {
+		Name          string         `json:"name"`
+		Contracts     int            `json:"contracts"`
+		Hosts         int            `json:"hosts"`
+		TotalStorage  uint64         `json:"totalStorage"`
+		AvgHostScore  float64        `json:"avgHostScore"`
+		MaxRedundancy float64        `json:"maxRedundancy"`
+		MonthlyCost   types.Currency `json:"monthlyCost"`
+	}
+
+	// ContractSetsCompareResponse is the response type for the
+	// /contracts/sets/compare endpoint.
+	ContractSetsCompareResponse struct {
+		A ContractSetStats `json:"a"`
+		B ContractSetStats `json:"b"`
+	}
+
+	// ContractSetUpdateResponse is the response type for the PUT
+	// /contracts/set/:set endpoint.
+	ContractSetUpdateResponse struct {
+		Added   []types.FileContractID `json:"added"`
+		Removed []types.FileContractID `json:"removed"`
+	}
+
 	ContractsOpts struct {
 		ContractSet string `json:"contractset"`
 	}
@@ -191,9 +234,20 @@ func (x ContractSpending) Add(y ContractSpending) (z ContractSpending) {
 	z.FundAccount = x.FundAccount.Add(y.FundAccount)
 	z.Deletions = x.Deletions.Add(y.Deletions)
 	z.SectorRoots = x.SectorRoots.Add(y.SectorRoots)
+	z.Storage = x.Storage.Add(y.Storage)
 	return
 }
 
+// Total returns the sum of all spending categories.
+func (x ContractSpending) Total() types.Currency {
+	return x.Uploads.
+		Add(x.Downloads).
+		Add(x.FundAccount).
+		Add(x.Deletions).
+		Add(x.SectorRoots).
+		Add(x.Storage)
+}
+
 // EndHeight returns the height at which the host is no longer obligated to
 // store contract data.
 func (c Contract) EndHeight() uint64 { return c.WindowStart }
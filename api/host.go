@@ -1,8 +1,10 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/url"
 	"strings"
 	"time"
@@ -20,12 +22,31 @@ const (
 	UsabilityFilterModeAll      = "all"
 	UsabilityFilterModeUsable   = "usable"
 	UsabilityFilterModeUnusable = "unusable"
+
+	HostSortByLastScan = "lastScan"
+
+	HostSortDirAsc  = "asc"
+	HostSortDirDesc = "desc"
+
+	GougingPriceContract = "contract"
+	GougingPriceDownload = "download"
+	GougingPriceRPC      = "rpc"
+	GougingPriceStorage  = "storage"
+	GougingPriceUpload   = "upload"
 )
 
 var (
 	// ErrHostNotFound is returned when a host can't be retrieved from the
 	// database.
 	ErrHostNotFound = errors.New("host doesn't exist in hostdb")
+
+	// ErrInvalidHostSortParameters is returned when invalid sort parameters
+	// are provided when fetching hosts.
+	ErrInvalidHostSortParameters = errors.New("invalid sort parameters")
+
+	// ErrInvalidHostOverride is returned when a host override is both force
+	// including and force excluding a host or has a negative score multiplier.
+	ErrInvalidHostOverride = errors.New("invalid host override")
 )
 
 var (
@@ -46,6 +67,18 @@ type (
 		Scans []HostScan `json:"scans"`
 	}
 
+	// HostsOperationsRequest is the request type for the /hosts/operations
+	// endpoint.
+	HostsOperationsRequest struct {
+		Operations []HostOperationRecord `json:"operations"`
+	}
+
+	// HostsBandwidthRequest is the request type for the /hosts/bandwidth
+	// endpoint.
+	HostsBandwidthRequest struct {
+		Bandwidth []HostBandwidth `json:"bandwidth"`
+	}
+
 	// HostsPriceTablesRequest is the request type for the /hosts/pricetables endpoint.
 	HostsPriceTablesRequest struct {
 		PriceTableUpdates []HostPriceTableUpdate `json:"priceTableUpdates"`
@@ -55,6 +88,7 @@ type (
 	HostsRemoveRequest struct {
 		MaxDowntimeHours      DurationH `json:"maxDowntimeHours"`
 		MinRecentScanFailures uint64    `json:"minRecentScanFailures"`
+		GracePeriodHours      DurationH `json:"gracePeriodHours"`
 	}
 
 	// SearchHostsRequest is the request type for the /api/bus/search/hosts
@@ -81,9 +115,20 @@ type (
 		GougingBreakdown HostGougingBreakdown `json:"gougingBreakdown"`
 		Score            float64              `json:"score"`
 		ScoreBreakdown   HostScoreBreakdown   `json:"scoreBreakdown"`
+		AdjustedScore    float64              `json:"adjustedScore"`
 		Usable           bool                 `json:"usable"`
 		UnusableReasons  []string             `json:"unusableReasons,omitempty"`
 	}
+
+	// HostScoreResponse is the response type for the GET
+	// /autopilot/host/:hostKey/score endpoint.
+	HostScoreResponse struct {
+		HostKey       types.PublicKey    `json:"hostKey"`
+		Score         float64            `json:"score"`
+		Breakdown     HostScoreBreakdown `json:"breakdown"`
+		AdjustedScore float64            `json:"adjustedScore"`
+		Penalizing    string             `json:"penalizing"`
+	}
 )
 
 type (
@@ -94,6 +139,14 @@ type (
 		Clear  bool              `json:"clear"`
 	}
 
+	// UpdateHostOverridesRequest is the request type for the /hosts/overrides
+	// endpoint.
+	UpdateHostOverridesRequest struct {
+		Add    []HostOverride    `json:"add"`
+		Remove []types.PublicKey `json:"remove"`
+		Clear  bool              `json:"clear"`
+	}
+
 	// UpdateBlocklistRequest is the request type for /hosts/blocklist endpoint.
 	UpdateBlocklistRequest struct {
 		Add    []string `json:"add"`
@@ -110,6 +163,8 @@ type (
 	}
 	HostsForScanningOptions struct {
 		MaxLastScan TimeRFC3339
+		SortBy      string
+		SortDir     string
 		Limit       int
 		Offset      int
 	}
@@ -144,6 +199,12 @@ func (opts HostsForScanningOptions) Apply(values url.Values) {
 	if !opts.MaxLastScan.IsZero() {
 		values.Set("lastScan", TimeRFC3339(opts.MaxLastScan).String())
 	}
+	if opts.SortBy != "" {
+		values.Set("sortBy", opts.SortBy)
+	}
+	if opts.SortDir != "" {
+		values.Set("sortDir", opts.SortDir)
+	}
 }
 
 type (
@@ -159,6 +220,7 @@ type (
 		Blocked          bool                 `json:"blocked"`
 		Checks           map[string]HostCheck `json:"checks"`
 		StoredData       uint64               `json:"storedData"`
+		Override         *HostOverride        `json:"override,omitempty"`
 	}
 
 	HostAddress struct {
@@ -177,6 +239,48 @@ type (
 
 		SuccessfulInteractions float64 `json:"successfulInteractions"`
 		FailedInteractions     float64 `json:"failedInteractions"`
+
+		// SuccessfulOperations and FailedOperations are rolling counts of the
+		// sector uploads and downloads performed on the host, older
+		// operations decay as new ones are recorded.
+		SuccessfulOperations float64 `json:"successfulOperations"`
+		FailedOperations     float64 `json:"failedOperations"`
+	}
+
+	// HostOperationRecord contains the number of successful and failed
+	// operations, i.e. sector uploads and downloads, performed on a host.
+	HostOperationRecord struct {
+		HostKey    types.PublicKey `json:"hostKey"`
+		Successful uint64          `json:"successful"`
+		Failed     uint64          `json:"failed"`
+	}
+
+	// HostBandwidth contains the number of bytes uploaded to and downloaded
+	// from a host.
+	HostBandwidth struct {
+		HostKey    types.PublicKey `json:"hostKey"`
+		Uploaded   uint64          `json:"uploaded"`
+		Downloaded uint64          `json:"downloaded"`
+	}
+
+	// HostOverride contains the manual settings for a host that take
+	// precedence over the autopilot's own judgement. Force excluded hosts are
+	// never used, force included hosts are used despite a low score or a
+	// redundant IP but not if they are gouging. The score multiplier is applied
+	// to the host's score, a multiplier of 0 leaves the score untouched.
+	HostOverride struct {
+		HostKey         types.PublicKey `json:"hostKey"`
+		ForceInclude    bool            `json:"forceInclude"`
+		ForceExclude    bool            `json:"forceExclude"`
+		ScoreMultiplier float64         `json:"scoreMultiplier"`
+	}
+
+	// HostPendingRemoval describes a host that crossed the thresholds for
+	// being removed but is given a grace period to recover before it is.
+	HostPendingRemoval struct {
+		HostKey    types.PublicKey `json:"hostKey"`
+		NetAddress string          `json:"netAddress"`
+		Since      time.Time       `json:"since"`
 	}
 
 	HostScan struct {
@@ -211,6 +315,17 @@ type (
 		GougingErr  string `json:"gougingErr"`
 		PruneErr    string `json:"pruneErr"`
 		UploadErr   string `json:"uploadErr"`
+
+		Reasons []GougingReason `json:"reasons,omitempty"`
+	}
+
+	// GougingReason describes a price of a host that exceeds the limit
+	// configured in the gouging settings.
+	GougingReason struct {
+		Price  string         `json:"price"`
+		Value  types.Currency `json:"value"`
+		Limit  types.Currency `json:"limit"`
+		Excess types.Currency `json:"excess"`
 	}
 
 	HostScoreBreakdown struct {
@@ -221,6 +336,7 @@ type (
 		Uptime           float64 `json:"uptime"`
 		Version          float64 `json:"version"`
 		Prices           float64 `json:"prices"`
+		SuccessRate      float64 `json:"successRate"`
 	}
 
 	HostUsabilityBreakdown struct {
@@ -250,8 +366,70 @@ func (h Host) IsOnline() bool {
 	return h.Interactions.LastScanSuccess || h.Interactions.SecondToLastScanSuccess
 }
 
+// ForceIncluded returns whether the host's override force includes it.
+func (h Host) ForceIncluded() bool {
+	return h.Override != nil && h.Override.ForceInclude
+}
+
+// ForceExcluded returns whether the host's override force excludes it.
+func (h Host) ForceExcluded() bool {
+	return h.Override != nil && h.Override.ForceExclude
+}
+
+// ScoreMultiplier returns the multiplier that is applied to the host's score,
+// it's 1 unless the host's override specifies one.
+func (h Host) ScoreMultiplier() float64 {
+	if h.Override == nil || h.Override.ScoreMultiplier == 0 {
+		return 1
+	}
+	return h.Override.ScoreMultiplier
+}
+
+// Validate returns an error if the override is invalid.
+func (o HostOverride) Validate() error {
+	if o.ForceInclude && o.ForceExclude {
+		return fmt.Errorf("%w: host %v can't be force included and excluded at the same time", ErrInvalidHostOverride, o.HostKey)
+	} else if o.ScoreMultiplier < 0 || math.IsNaN(o.ScoreMultiplier) || math.IsInf(o.ScoreMultiplier, 0) {
+		return fmt.Errorf("%w: host %v has an invalid score multiplier %v", ErrInvalidHostOverride, o.HostKey, o.ScoreMultiplier)
+	}
+	return nil
+}
+
+// OperationSuccessRate returns the rolling success rate of the operations
+// performed on the host, a host without operations has a success rate of 1.
+func (hi HostInteractions) OperationSuccessRate() float64 {
+	total := hi.SuccessfulOperations + hi.FailedOperations
+	if total == 0 {
+		return 1
+	}
+	return hi.SuccessfulOperations / total
+}
+
+// UptimeRatio returns the ratio of the host's uptime to the total time it was
+// monitored by scans, a host that wasn't monitored yet has a ratio of 0.
+func (hi HostInteractions) UptimeRatio() float64 {
+	total := hi.Uptime + hi.Downtime
+	if total == 0 {
+		return 0
+	}
+	return float64(hi.Uptime) / float64(total)
+}
+
+// MarshalJSON implements json.Marshaler, it includes the host's uptime ratio
+// so API consumers don't have to derive it from the uptime and downtime.
+func (hi HostInteractions) MarshalJSON() ([]byte, error) {
+	type interactions HostInteractions
+	return json.Marshal(struct {
+		interactions
+		UptimeRatio float64 `json:"uptimeRatio"`
+	}{
+		interactions: interactions(hi),
+		UptimeRatio:  hi.UptimeRatio(),
+	})
+}
+
 func (sb HostScoreBreakdown) String() string {
-	return fmt.Sprintf("Age: %v, Col: %v, Int: %v, SR: %v, UT: %v, V: %v, Pr: %v", sb.Age, sb.Collateral, sb.Interactions, sb.StorageRemaining, sb.Uptime, sb.Version, sb.Prices)
+	return fmt.Sprintf("Age: %v, Col: %v, Int: %v, SR: %v, UT: %v, V: %v, Pr: %v, Suc: %v", sb.Age, sb.Collateral, sb.Interactions, sb.StorageRemaining, sb.Uptime, sb.Version, sb.Prices, sb.SuccessRate)
 }
 
 func (hgb HostGougingBreakdown) Gouging() bool {
@@ -285,8 +463,36 @@ func (hgb HostGougingBreakdown) String() string {
 	return strings.Join(reasons, ";")
 }
 
+func (r GougingReason) String() string {
+	return fmt.Sprintf("%s price exceeds max by %v: %v > %v", r.Price, r.Excess, r.Value, r.Limit)
+}
+
 func (sb HostScoreBreakdown) Score() float64 {
-	return sb.Age * sb.Collateral * sb.Interactions * sb.StorageRemaining * sb.Uptime * sb.Version * sb.Prices
+	return sb.Age * sb.Collateral * sb.Interactions * sb.StorageRemaining * sb.Uptime * sb.Version * sb.Prices * sb.SuccessRate
+}
+
+// Lowest returns the name of the score component with the lowest value, which
+// is the component that penalizes the host the most.
+func (sb HostScoreBreakdown) Lowest() string {
+	lowest, name := math.Inf(1), ""
+	for _, c := range []struct {
+		name  string
+		value float64
+	}{
+		{"age", sb.Age},
+		{"collateral", sb.Collateral},
+		{"interactions", sb.Interactions},
+		{"storageRemaining", sb.StorageRemaining},
+		{"uptime", sb.Uptime},
+		{"version", sb.Version},
+		{"prices", sb.Prices},
+		{"successRate", sb.SuccessRate},
+	} {
+		if c.value < lowest {
+			lowest, name = c.value, c.name
+		}
+	}
+	return name
 }
 
 func (ub HostUsabilityBreakdown) IsUsable() bool {
@@ -79,6 +79,7 @@ type (
 		FundAccountSpending types.Currency `json:"fundAccountSpending"`
 		DeleteSpending      types.Currency `json:"deleteSpending"`
 		ListSpending        types.Currency `json:"listSpending"`
+		StorageSpending     types.Currency `json:"storageSpending"`
 	}
 
 	ContractMetricsQueryOpts struct {
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"go.sia.tech/renterd/object"
 )
@@ -16,6 +17,11 @@ import (
 const (
 	ObjectMetadataPrefix = "X-Sia-Meta-"
 
+	// ObjectUserMetadataMaxSize is the maximum total size of the keys and
+	// values of an object's user-defined metadata, it matches the limit S3
+	// imposes on user-defined metadata.
+	ObjectUserMetadataMaxSize = 2 << 10 // 2 KiB
+
 	ObjectsRenameModeSingle = "single"
 	ObjectsRenameModeMulti  = "multi"
 
@@ -40,6 +46,10 @@ var (
 	// from the database.
 	ErrObjectCorrupted = errors.New("object corrupted")
 
+	// ErrObjectModified is returned when an object is only updated if its
+	// ETag matches the expected ETag but it doesn't.
+	ErrObjectModified = errors.New("object was modified")
+
 	// ErrInvalidObjectSortParameters is returned when invalid sort parameters
 	// were provided
 	ErrInvalidObjectSortParameters = errors.New("invalid sort parameters")
@@ -47,6 +57,10 @@ var (
 	// ErrSlabNotFound is returned when a slab can't be retrieved from the
 	// database.
 	ErrSlabNotFound = errors.New("slab not found")
+
+	// ErrObjectUserMetadataTooLarge is returned when the user-defined metadata
+	// of an object exceeds ObjectUserMetadataMaxSize.
+	ErrObjectUserMetadataTooLarge = errors.New("object user metadata too large")
 )
 
 type (
@@ -101,19 +115,29 @@ type (
 
 	// ObjectsDeleteRequest is the request type for the /bus/objects/list endpoint.
 	ObjectsListRequest struct {
-		Bucket  string `json:"bucket"`
-		Limit   int    `json:"limit"`
-		SortBy  string `json:"sortBy"`
-		SortDir string `json:"sortDir"`
-		Prefix  string `json:"prefix"`
-		Marker  string `json:"marker"`
+		Bucket    string `json:"bucket"`
+		Limit     int    `json:"limit"`
+		SortBy    string `json:"sortBy"`
+		SortDir   string `json:"sortDir"`
+		Prefix    string `json:"prefix"`
+		Delimiter string `json:"delimiter,omitempty"`
+		Marker    string `json:"marker"`
 	}
 
 	// ObjectsListResponse is the response type for the /bus/objects/list endpoint.
 	ObjectsListResponse struct {
-		HasMore    bool             `json:"hasMore"`
-		NextMarker string           `json:"nextMarker"`
-		Objects    []ObjectMetadata `json:"objects"`
+		HasMore        bool             `json:"hasMore"`
+		NextMarker     string           `json:"nextMarker"`
+		Objects        []ObjectMetadata `json:"objects"`
+		CommonPrefixes []string         `json:"commonPrefixes,omitempty"`
+	}
+
+	// ObjectsMetadataRequest is the request type for the /bus/objects/metadata
+	// endpoint.
+	ObjectsMetadataRequest struct {
+		Bucket   string             `json:"bucket"`
+		Path     string             `json:"path"`
+		Metadata ObjectUserMetadata `json:"metadata"`
 	}
 
 	// ObjectsRenameRequest is the request type for the /bus/objects/rename endpoint.
@@ -141,6 +165,19 @@ type (
 	}
 )
 
+// Validate returns an error if the total size of the metadata's keys and values
+// exceeds ObjectUserMetadataMaxSize.
+func (m ObjectUserMetadata) Validate() error {
+	var size int
+	for k, v := range m {
+		size += len(k) + len(v)
+	}
+	if size > ObjectUserMetadataMaxSize {
+		return fmt.Errorf("%w: %d > %d bytes", ErrObjectUserMetadataTooLarge, size, ObjectUserMetadataMaxSize)
+	}
+	return nil
+}
+
 func ExtractObjectUserMetadataFrom(metadata map[string]string) ObjectUserMetadata {
 	oum := make(map[string]string)
 	for k, v := range metadata {
@@ -172,6 +209,10 @@ type (
 		ETag     string
 		MimeType string
 		Metadata ObjectUserMetadata
+
+		// ExpectedETag, if set, only updates the object if its current ETag
+		// matches the expected ETag.
+		ExpectedETag string
 	}
 
 	// AddObjectRequest is the request type for the /bus/object/*key endpoint.
@@ -182,6 +223,8 @@ type (
 		ETag        string             `json:"eTag"`
 		MimeType    string             `json:"mimeType"`
 		Metadata    ObjectUserMetadata `json:"metadata"`
+
+		ExpectedETag string `json:"expectedETag,omitempty"`
 	}
 
 	// CopyObjectOptions is the options type for the bus client.
@@ -228,9 +271,10 @@ type (
 	}
 
 	ListObjectOptions struct {
-		Prefix string
-		Marker string
-		Limit  int
+		Prefix    string
+		Delimiter string
+		Marker    string
+		Limit     int
 	}
 
 	SearchObjectOptions struct {
@@ -247,6 +291,17 @@ type (
 		ContentLength int64
 		MimeType      string
 		Metadata      ObjectUserMetadata
+
+		// Timeout aborts the upload if it isn't finished in time, a zero
+		// timeout disables it.
+		Timeout time.Duration
+	}
+
+	// PatchObjectOptions is the options type for the worker client.
+	PatchObjectOptions struct {
+		ContractSet string
+		Offset      int64
+		Length      int64
 	}
 
 	UploadMultipartUploadPartOptions struct {
@@ -271,6 +326,9 @@ func (opts UploadObjectOptions) ApplyValues(values url.Values) {
 	if opts.MimeType != "" {
 		values.Set("mimetype", opts.MimeType)
 	}
+	if opts.Timeout > 0 {
+		values.Set("timeout", DurationMS(opts.Timeout).String())
+	}
 }
 
 func (opts UploadObjectOptions) ApplyHeaders(h http.Header) {
@@ -279,6 +337,16 @@ func (opts UploadObjectOptions) ApplyHeaders(h http.Header) {
 	}
 }
 
+func (opts PatchObjectOptions) ApplyValues(values url.Values) {
+	if opts.ContractSet != "" {
+		values.Set("contractset", opts.ContractSet)
+	}
+}
+
+func (opts PatchObjectOptions) ApplyHeaders(h http.Header) {
+	h.Set("Range", fmt.Sprintf("bytes=%d-%d", opts.Offset, opts.Offset+opts.Length-1))
+}
+
 func (opts UploadMultipartUploadPartOptions) Apply(values url.Values) {
 	if opts.EncryptionOffset != nil {
 		values.Set("offset", fmt.Sprint(*opts.EncryptionOffset))
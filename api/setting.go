@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -12,6 +13,7 @@ import (
 const (
 	SettingContractSet      = "contractset"
 	SettingGouging          = "gouging"
+	SettingMigrator         = "migrator"
 	SettingRedundancy       = "redundancy"
 	SettingS3Authentication = "s3authentication"
 	SettingUploadPacking    = "uploadpacking"
@@ -28,11 +30,30 @@ var (
 	// not valid
 	ErrInvalidRedundancySettings = errors.New("invalid redundancy settings")
 
+	// ErrInvalidSetting is returned if a setting is written with a value that
+	// doesn't pass the validation of its key.
+	ErrInvalidSetting = errors.New("invalid setting")
+
+	// ErrSettingConflict is returned if a conditional setting update fails
+	// because the setting was modified in the meantime.
+	ErrSettingConflict = errors.New("setting was modified concurrently")
+
 	// ErrSettingNotFound is returned if a requested setting is not present in the
 	// database.
 	ErrSettingNotFound = errors.New("setting not found")
 )
 
+// settingValidators contains a validator for every known setting key, settings
+// with unknown keys are not validated.
+var settingValidators = map[string]func(value []byte) error{
+	SettingContractSet:      validateSetting[ContractSetSetting],
+	SettingGouging:          validateSetting[GougingSettings],
+	SettingMigrator:         validateSetting[MigratorSettings],
+	SettingRedundancy:       validateSetting[RedundancySettings],
+	SettingS3Authentication: validateSetting[S3AuthenticationSettings],
+	SettingUploadPacking:    validateSetting[UploadPackingSettings],
+}
+
 type (
 	// ContractSetSetting contains the default contract set used by the worker for
 	// uploads and migrations.
@@ -78,6 +99,38 @@ type (
 		// this multiplier is only applied for when trying to migrate critically
 		// low-health slabs.
 		MigrationSurchargeMultiplier uint64 `json:"migrationSurchargeMultiplier"`
+
+		// PricePercentile, when set, makes the bus derive the price limits
+		// from the prices of the online hosts with a valid price table
+		// instead of using the static limits, every limit is set to the given
+		// percentile of the corresponding host prices. The limits are applied
+		// to the gouging params so the autopilot and the workers agree on
+		// them. A value of 0 uses the static limits.
+		PricePercentile float64 `json:"pricePercentile,omitempty"`
+	}
+
+	// SettingHistoryEntry describes a single change to a setting. A nil old
+	// value means the setting was created, a nil new value means it was
+	// deleted.
+	SettingHistoryEntry struct {
+		Key       string          `json:"key"`
+		OldValue  json.RawMessage `json:"oldValue"`
+		NewValue  json.RawMessage `json:"newValue"`
+		Timestamp time.Time       `json:"timestamp"`
+	}
+
+	// VersionedSetting contains a setting's value together with its version,
+	// the version is incremented on every update and can be used to update the
+	// setting conditionally.
+	VersionedSetting struct {
+		Value   json.RawMessage `json:"value"`
+		Version uint64          `json:"version"`
+	}
+
+	// MigratorSettings contains the state of the autopilot's slab migrator
+	// that has to survive a restart.
+	MigratorSettings struct {
+		Paused bool `json:"paused"`
 	}
 
 	// RedundancySettings contain settings that dictate an object's redundancy.
@@ -98,6 +151,37 @@ type (
 	}
 )
 
+// ValidateSetting returns an error wrapping ErrInvalidSetting if the given
+// value is not a valid setting for the given key.
+func ValidateSetting(key string, value []byte) error {
+	validate, ok := settingValidators[key]
+	if !ok {
+		return nil
+	} else if err := validate(value); err != nil {
+		return fmt.Errorf("%w '%s': %v", ErrInvalidSetting, key, err)
+	}
+	return nil
+}
+
+func validateSetting[T interface{ Validate() error }](value []byte) error {
+	var setting T
+	if err := json.Unmarshal(value, &setting); err != nil {
+		return fmt.Errorf("failed to decode setting: %w", err)
+	}
+	return setting.Validate()
+}
+
+// Validate returns an error if the contract set setting is not considered
+// valid.
+func (css ContractSetSetting) Validate() error {
+	return nil
+}
+
+// Validate returns an error if the migrator settings are not considered valid.
+func (ms MigratorSettings) Validate() error {
+	return nil
+}
+
 // Validate returns an error if the gouging settings are not considered valid.
 func (gs GougingSettings) Validate() error {
 	if gs.HostBlockHeightLeeway < 3 {
@@ -112,6 +196,9 @@ func (gs GougingSettings) Validate() error {
 	if gs.MinPriceTableValidity < 10*time.Second {
 		return errors.New("MinPriceTableValidity must be at least 10 seconds")
 	}
+	if gs.PricePercentile < 0 || gs.PricePercentile > 100 {
+		return errors.New("PricePercentile must be between 0 and 100")
+	}
 	_, overflow := gs.MaxDownloadPrice.Mul64WithOverflow(gs.MigrationSurchargeMultiplier)
 	if overflow {
 		maxMultiplier := types.MaxCurrency.Div(gs.MaxDownloadPrice).Big().Uint64()
@@ -151,6 +238,15 @@ func (rs RedundancySettings) Validate() error {
 	return nil
 }
 
+// Validate returns an error if the upload packing settings are not considered
+// valid.
+func (ups UploadPackingSettings) Validate() error {
+	if ups.SlabBufferMaxSizeSoft < 0 {
+		return errors.New("SlabBufferMaxSizeSoft must not be negative")
+	}
+	return nil
+}
+
 // Validate returns an error if the authentication settings are not considered
 // valid.
 func (s3as S3AuthenticationSettings) Validate() error {
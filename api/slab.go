@@ -21,6 +21,28 @@ type (
 		Locked      bool   `json:"locked"`      // whether the slab buffer is locked for uploading
 	}
 
+	// SlabHealth describes the redundancy of a slab on usable contracts.
+	SlabHealth struct {
+		Key          object.EncryptionKey `json:"key"`
+		Health       float64              `json:"health"`
+		MinShards    uint8                `json:"minShards"`
+		TotalShards  uint8                `json:"totalShards"`
+		UsableShards int                  `json:"usableShards"`
+	}
+
+	// SlabLocation describes where a shard of a slab is stored. A shard that is
+	// stored in multiple contracts has a location for each contract, a shard
+	// that isn't stored in any contract has a single location without a
+	// contract.
+	SlabLocation struct {
+		ShardIndex int                  `json:"shardIndex"`
+		Root       types.Hash256        `json:"root"`
+		ContractID types.FileContractID `json:"contractID"`
+		HostKey    types.PublicKey      `json:"hostKey"`
+		NetAddress string               `json:"netAddress"`
+		Usable     bool                 `json:"usable"`
+	}
+
 	UnhealthySlab struct {
 		Key    object.EncryptionKey `json:"key"`
 		Health float64              `json:"health"`
@@ -38,6 +60,13 @@ type (
 		Slabs                        []object.SlabSlice `json:"slabs"`
 	}
 
+	// EvacuationSlabsRequest is the request type for the /slabs/evacuation
+	// endpoint.
+	EvacuationSlabsRequest struct {
+		HostKeys []types.PublicKey `json:"hostKeys"`
+		Limit    int               `json:"limit"`
+	}
+
 	// MigrationSlabsRequest is the request type for the /slabs/migration endpoint.
 	MigrationSlabsRequest struct {
 		ContractSet  string  `json:"contractSet"`
@@ -57,6 +86,44 @@ type (
 		Slabs []UploadedPackedSlab `json:"slabs"`
 	}
 
+	// SlabsMigrateRequest is the request type for the /autopilot/slabs/migrate
+	// endpoint.
+	SlabsMigrateRequest struct {
+		HostKeys []types.PublicKey `json:"hostKeys"`
+	}
+
+	// SlabsMigrateResponse is the response type for the
+	// /autopilot/slabs/migrate endpoint.
+	SlabsMigrateResponse struct {
+		Slabs               []SlabMigrationResult `json:"slabs"`
+		TotalShardsMigrated int                   `json:"totalShardsMigrated"`
+	}
+
+	// SlabMigrationResult contains the outcome of migrating a single slab off
+	// the evacuated hosts.
+	SlabMigrationResult struct {
+		Key               object.EncryptionKey `json:"key"`
+		NumShardsMigrated int                  `json:"numShardsMigrated"`
+		Error             string               `json:"error,omitempty"`
+	}
+
+	// ActiveUpload contains information about an upload that is currently
+	// being tracked by the bus.
+	ActiveUpload struct {
+		ID              UploadID                     `json:"id"`
+		Started         TimeRFC3339                  `json:"started"`
+		Deadline        TimeRFC3339                  `json:"deadline"`
+		Priority        int                          `json:"priority"`
+		Sectors         int                          `json:"sectors"`
+		ContractSectors map[types.FileContractID]int `json:"contractSectors"`
+	}
+
+	// UploadTrackRequest is the request type for the /upload/:id endpoint.
+	UploadTrackRequest struct {
+		Deadline TimeRFC3339 `json:"deadline"`
+		Priority int         `json:"priority"`
+	}
+
 	// UploadSectorRequest is the request type for the /upload/:id/sector endpoint.
 	UploadSectorRequest struct {
 		ContractID types.FileContractID `json:"contractID"`
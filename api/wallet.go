@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"time"
@@ -10,12 +11,86 @@ import (
 	"go.sia.tech/core/types"
 )
 
+const (
+	// CoinSelectionLargestFirst funds transactions using the largest outputs
+	// first, minimizing the number of inputs and thus the fee.
+	CoinSelectionLargestFirst CoinSelectionStrategy = "largestFirst"
+
+	// CoinSelectionSmallestFirst funds transactions using the smallest outputs
+	// first, consolidating small outputs at the cost of a higher fee.
+	CoinSelectionSmallestFirst CoinSelectionStrategy = "smallestFirst"
+
+	// CoinSelectionMinimizeChange funds transactions using the outputs that
+	// result in the smallest change output, avoiding a change output if
+	// possible.
+	CoinSelectionMinimizeChange CoinSelectionStrategy = "minimizeChange"
+)
+
+var (
+	// ErrInvalidCoinSelectionStrategy is returned when an unknown coin
+	// selection strategy is configured.
+	ErrInvalidCoinSelectionStrategy = errors.New("invalid coin selection strategy")
+)
+
 type (
+	// CoinSelectionStrategy determines which outputs the wallet uses to fund
+	// transactions.
+	CoinSelectionStrategy string
+
+	// WalletAddressBalance is the balance of a single wallet address, it's
+	// returned by the /wallet/addresses endpoint.
+	WalletAddressBalance struct {
+		Address     types.Address  `json:"address"`
+		Spendable   types.Currency `json:"spendable"`
+		Confirmed   types.Currency `json:"confirmed"`
+		Unconfirmed types.Currency `json:"unconfirmed"`
+	}
+
+	// WalletCoinSelectionRequest is the request type for the
+	// /wallet/coinselection endpoint.
+	WalletCoinSelectionRequest struct {
+		Strategy CoinSelectionStrategy `json:"strategy"`
+	}
+
+	// WalletCoinSelectionResponse is the response type for the
+	// /wallet/coinselection endpoint.
+	WalletCoinSelectionResponse struct {
+		Strategy CoinSelectionStrategy `json:"strategy"`
+	}
+
+	// WalletDefragRequest is the request type for the /wallet/defrag endpoint.
+	WalletDefragRequest struct {
+		MaxFee    types.Currency `json:"maxFee"`
+		MaxInputs int            `json:"maxInputs"`
+		Threshold int            `json:"threshold"`
+	}
+
+	// WalletDefragResponse is the response type for the /wallet/defrag
+	// endpoint. If no defragmentation was necessary, the response is empty.
+	WalletDefragResponse struct {
+		ID     types.TransactionID `json:"id"`
+		Fee    types.Currency      `json:"fee"`
+		Inputs int                 `json:"inputs"`
+	}
+
+	// FeeEstimate contains the recommended fee per byte for transactions
+	// depending on how fast they should be confirmed.
+	FeeEstimate struct {
+		Fast     types.Currency `json:"fast"`
+		Standard types.Currency `json:"standard"`
+		Slow     types.Currency `json:"slow"`
+	}
+
 	// WalletFundRequest is the request type for the /wallet/fund endpoint.
 	WalletFundRequest struct {
 		Transaction        types.Transaction `json:"transaction"`
 		Amount             types.Currency    `json:"amount"`
 		UseUnconfirmedTxns bool              `json:"useUnconfirmedTxns"`
+
+		// Address restricts the outputs used to fund the transaction to the
+		// ones controlled by the given wallet address, if not set all of the
+		// wallet's outputs are used.
+		Address types.Address `json:"address"`
 	}
 
 	// WalletFundResponse is the response type for the /wallet/fund endpoint.
@@ -109,3 +184,26 @@ func WalletTransactionsWithOffset(offset int) WalletTransactionsOption {
 		q.Set("offset", fmt.Sprint(offset))
 	}
 }
+
+func WalletTransactionsWithType(txnType string) WalletTransactionsOption {
+	return func(q url.Values) {
+		q.Set("type", txnType)
+	}
+}
+
+func WalletTransactionsWithMarker(marker types.TransactionID) WalletTransactionsOption {
+	return func(q url.Values) {
+		q.Set("marker", marker.String())
+	}
+}
+
+// Validate returns an error if the strategy is not a known coin selection
+// strategy.
+func (s CoinSelectionStrategy) Validate() error {
+	switch s {
+	case CoinSelectionLargestFirst, CoinSelectionSmallestFirst, CoinSelectionMinimizeChange:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidCoinSelectionStrategy, s)
+	}
+}
@@ -1,8 +1,49 @@
 package api
 
-import "go.sia.tech/renterd/webhooks"
+import (
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/webhooks"
+)
 
-type WebHookResponse struct {
-	Webhooks []webhooks.Webhook          `json:"webhooks"`
-	Queues   []webhooks.WebhookQueueInfo `json:"queues"`
-}
+const (
+	WebhookModuleContract = "contract"
+	WebhookModuleHost     = "host"
+
+	WebhookEventContractArchived = "archived"
+	WebhookEventContractExpiring = "expiring"
+	WebhookEventContractFormed   = "formed"
+	WebhookEventContractRenewed  = "renewed"
+	WebhookEventHostRemoved      = "removed"
+)
+
+type (
+	WebHookResponse struct {
+		Webhooks []webhooks.Webhook          `json:"webhooks"`
+		Queues   []webhooks.WebhookQueueInfo `json:"queues"`
+	}
+
+	// EventContractArchived is the payload of the contract.archived event, it
+	// maps the ids of the archived contracts to the reason they were
+	// archived.
+	EventContractArchived struct {
+		Contracts map[types.FileContractID]string `json:"contracts"`
+	}
+
+	// EventContractExpiring is the payload of the contract.expiring event, it
+	// is emitted once for every contract that is about to expire while still
+	// storing referenced sectors.
+	EventContractExpiring struct {
+		Contract ExpiringContract `json:"contract"`
+	}
+
+	// EventContractRenewed is the payload of the contract.renewed event.
+	EventContractRenewed struct {
+		Renewal     ContractMetadata     `json:"renewal"`
+		RenewedFrom types.FileContractID `json:"renewedFrom"`
+	}
+
+	// EventHostsRemoved is the payload of the host.removed event.
+	EventHostsRemoved struct {
+		Removed uint64 `json:"removed"`
+	}
+)
@@ -12,6 +12,7 @@ import (
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	rhpv3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/object"
 )
 
 var (
@@ -27,6 +28,11 @@ var (
 	// be scanned since it is on a private network.
 	ErrHostOnPrivateNetwork = errors.New("host is on a private network")
 
+	// ErrInsufficientContracts is returned by the worker API when an upload
+	// is rejected because the contract set doesn't contain enough contracts to
+	// reach the requested redundancy.
+	ErrInsufficientContracts = errors.New("not enough contracts to support requested redundancy")
+
 	// ErrMultiRangeNotSupported is returned by the worker API when a request
 	// tries to download multiple ranges at once.
 	ErrMultiRangeNotSupported = errors.New("multipart ranges are not supported")
@@ -80,6 +86,17 @@ type (
 		Error             string `json:"error,omitempty"`
 	}
 
+	// WorkerRateLimits contains the bandwidth limits of a worker in bytes per
+	// second, a limit of zero means unlimited. The global limits are shared
+	// by all connections to hosts, the per connection limits apply to every
+	// connection individually.
+	WorkerRateLimits struct {
+		Download        uint64 `json:"download"`
+		DownloadPerConn uint64 `json:"downloadPerConn"`
+		Upload          uint64 `json:"upload"`
+		UploadPerConn   uint64 `json:"uploadPerConn"`
+	}
+
 	// RHPFormRequest is the request type for the /rhp/form endpoint.
 	RHPFormRequest struct {
 		EndHeight      uint64          `json:"endHeight"`
@@ -194,6 +211,14 @@ type (
 		NumDownloads               uint64          `json:"numDownloads"`
 	}
 
+	// PriceTableStatsResponse is the response type for the /stats/pricetables
+	// endpoint.
+	PriceTableStatsResponse struct {
+		CacheHits    uint64  `json:"cacheHits"`
+		CacheMisses  uint64  `json:"cacheMisses"`
+		CacheHitRate float64 `json:"cacheHitRate"`
+	}
+
 	// UploadStatsResponse is the response type for the /stats/uploads endpoint.
 	UploadStatsResponse struct {
 		AvgSlabUploadSpeedMBPS float64         `json:"avgSlabUploadSpeedMbps"`
@@ -218,9 +243,32 @@ type (
 		ETag string `json:"etag"`
 	}
 
+	PatchObjectResponse struct {
+		ETag string `json:"etag"`
+	}
+
 	UploadMultipartUploadPartResponse struct {
 		ETag string `json:"etag"`
 	}
+
+	// VerifyObjectResponse is the response type for the /verify/objects
+	// endpoint.
+	VerifyObjectResponse struct {
+		Recoverable bool               `json:"recoverable"`
+		Slabs       []SlabVerification `json:"slabs"`
+	}
+
+	// SlabVerification describes how many of a slab's shards could be
+	// retrieved from the hosts. A slab is recoverable if at least 'MinShards'
+	// shards are reachable and at risk if any of its shards are unreachable.
+	SlabVerification struct {
+		Key             object.EncryptionKey `json:"key"`
+		MinShards       uint8                `json:"minShards"`
+		TotalShards     uint8                `json:"totalShards"`
+		ReachableShards uint8                `json:"reachableShards"`
+		Recoverable     bool                 `json:"recoverable"`
+		AtRisk          bool                 `json:"atRisk"`
+	}
 )
 
 // ContentRange represents a content range returned via the "Content-Range"
@@ -15,14 +15,23 @@ import (
 	"go.uber.org/zap"
 )
 
-var errMaxDriftExceeded = errors.New("drift on account is too large")
-
 var (
-	minBalance  = types.Siacoins(1).Div64(2).Big()
-	maxBalance  = types.Siacoins(1)
-	maxNegDrift = new(big.Int).Neg(types.Siacoins(10).Big())
+	errAccountDrifted   = errors.New("account drifted")
+	errMaxDriftExceeded = errors.New("drift on account is too large")
 )
 
+var maxNegDrift = new(big.Int).Neg(types.Siacoins(10).Big())
+
+// defaultMaxDrift is the default maximum delta between an account's tracked
+// balance and the balance reported by its host during a sync before the account
+// is flagged as drifted, it can be configured through the autopilot config.
+var defaultMaxDrift = types.Siacoins(1).Div64(10)
+
+// accountSyncInterval is the interval at which an account's balance is
+// reconciled with the balance reported by the host, regardless of whether the
+// account requires a sync.
+const accountSyncInterval = time.Hour
+
 type accounts struct {
 	ap *Autopilot
 	a  AccountStore
@@ -32,20 +41,41 @@ type accounts struct {
 
 	refillInterval time.Duration
 
+	// minBalance and maxBalance define the band within which account
+	// balances are kept, accounts that drop below the minimum are refilled
+	// up to the maximum
+	minBalance types.Currency
+	maxBalance types.Currency
+
+	// maxDrift is the maximum delta between an account's tracked balance and
+	// the balance reported by its host during a sync before the account is
+	// flagged as drifted
+	maxDrift types.Currency
+
 	mu                sync.Mutex
 	inProgressRefills map[types.Hash256]struct{}
+	lastSyncs         map[types.Hash256]time.Time
 }
 
 type AccountStore interface {
 	Account(ctx context.Context, id rhpv3.Account, hk types.PublicKey) (account api.Account, err error)
 	Accounts(ctx context.Context) (accounts []api.Account, err error)
+	MarkDrifted(ctx context.Context, id rhpv3.Account) error
 }
 
 type ContractStore interface {
 	Contracts(ctx context.Context, opts api.ContractsOpts) ([]api.ContractMetadata, error)
 }
 
-func newAccounts(ap *Autopilot, a AccountStore, c ContractStore, w *workerPool, l *zap.SugaredLogger, refillInterval time.Duration) *accounts {
+func newAccounts(ap *Autopilot, a AccountStore, c ContractStore, w *workerPool, l *zap.SugaredLogger, refillInterval time.Duration, minBalance, maxBalance, maxDrift types.Currency) (*accounts, error) {
+	if maxBalance.IsZero() {
+		return nil, errors.New("accounts max balance has to be greater than zero")
+	} else if minBalance.Cmp(maxBalance) > 0 {
+		return nil, fmt.Errorf("accounts min balance %v can't exceed the max balance %v", minBalance, maxBalance)
+	}
+	if maxDrift.IsZero() {
+		maxDrift = defaultMaxDrift
+	}
 	return &accounts{
 		ap: ap,
 		a:  a,
@@ -54,8 +84,12 @@ func newAccounts(ap *Autopilot, a AccountStore, c ContractStore, w *workerPool,
 		w:  w,
 
 		refillInterval:    refillInterval,
+		minBalance:        minBalance,
+		maxBalance:        maxBalance,
+		maxDrift:          maxDrift,
 		inProgressRefills: make(map[types.Hash256]struct{}),
-	}
+		lastSyncs:         make(map[types.Hash256]time.Time),
+	}, nil
 }
 
 func (a *accounts) markRefillInProgress(workerID string, hk types.PublicKey) bool {
@@ -81,6 +115,24 @@ func (a *accounts) markRefillDone(workerID string, hk types.PublicKey) {
 	delete(a.inProgressRefills, k)
 }
 
+// markSyncDue returns true if the worker's account with the given host wasn't
+// synced within the sync interval, in which case the current time is recorded
+// as the time of the last sync. Accounts we haven't seen before aren't due
+// until the interval passed, which avoids syncing every account on startup.
+func (a *accounts) markSyncDue(workerID string, hk types.PublicKey) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	k := types.HashBytes(append([]byte(workerID), hk[:]...))
+	if lastSync, ok := a.lastSyncs[k]; !ok {
+		a.lastSyncs[k] = time.Now()
+		return false
+	} else if time.Since(lastSync) < accountSyncInterval {
+		return false
+	}
+	a.lastSyncs[k] = time.Now()
+	return true
+}
+
 func (a *accounts) refillWorkersAccountsLoop(ctx context.Context) {
 	ticker := time.NewTicker(a.refillInterval)
 
@@ -103,34 +155,95 @@ func (a *accounts) refillWorkersAccountsLoop(ctx context.Context) {
 // goroutine from a previous call, refillWorkerAccounts will skip that account
 // until the previously launched goroutine returns.
 func (a *accounts) refillWorkerAccounts(ctx context.Context, w Worker) {
+	workerID, contracts, inContractSet, err := a.refillCandidates(ctx, w)
+	if err != nil {
+		a.l.Errorw(err.Error())
+		return
+	}
+
+	// refill accounts in separate goroutines
+	for _, c := range contracts {
+		// launch refill if not already in progress
+		if a.markRefillInProgress(workerID, c.HostKey) {
+			go func(contract api.ContractMetadata) {
+				rCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+				defer cancel()
+				a.refillWorkerAccount(rCtx, w, workerID, contract, inContractSet)
+				a.markRefillDone(workerID, contract.HostKey)
+			}(c)
+		}
+	}
+}
+
+// rebalance refills all accounts on a worker that require a refill and blocks
+// until all refills are done. Accounts that are already being refilled are
+// skipped.
+func (a *accounts) rebalance(ctx context.Context, w Worker) ([]api.AccountRebalanceResult, error) {
+	workerID, contracts, inContractSet, err := a.refillCandidates(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make([]api.AccountRebalanceResult, 0, len(contracts))
+	for _, c := range contracts {
+		if !a.markRefillInProgress(workerID, c.HostKey) {
+			continue
+		}
+		wg.Add(1)
+		go func(contract api.ContractMetadata) {
+			defer wg.Done()
+			rCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			defer cancel()
+			accountID, refilled, rerr := a.refillWorkerAccount(rCtx, w, workerID, contract, inContractSet)
+			a.markRefillDone(workerID, contract.HostKey)
+
+			res := api.AccountRebalanceResult{
+				AccountID: accountID,
+				HostKey:   contract.HostKey,
+				Funded:    refilled,
+			}
+			if rerr != nil {
+				res.Error = rerr.Error()
+			}
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// refillCandidates returns the id of the given worker and the contracts for
+// which the worker's accounts should be refilled, alongside a map of the
+// contracts that are part of the autopilot's contract set.
+func (a *accounts) refillCandidates(ctx context.Context, w Worker) (string, []api.ContractMetadata, map[types.FileContractID]struct{}, error) {
 	// fetch config
 	cfg, err := a.ap.Config(ctx)
 	if err != nil {
-		a.l.Errorw(fmt.Sprintf("failed to fetch config for refill: %v", err))
-		return
+		return "", nil, nil, fmt.Errorf("failed to fetch config for refill: %w", err)
 	}
 
 	// fetch worker id
 	workerID, err := w.ID(ctx)
 	if err != nil {
-		a.l.Errorw(fmt.Sprintf("failed to fetch worker id for refill: %v", err))
-		return
+		return "", nil, nil, fmt.Errorf("failed to fetch worker id for refill: %w", err)
 	}
 
 	// fetch all contracts
 	contracts, err := a.c.Contracts(ctx, api.ContractsOpts{})
 	if err != nil {
-		a.l.Errorw(fmt.Sprintf("failed to fetch contracts for refill: %v", err))
-		return
+		return "", nil, nil, fmt.Errorf("failed to fetch contracts for refill: %w", err)
 	} else if len(contracts) == 0 {
-		return
+		return workerID, nil, nil, nil
 	}
 
 	// fetch all contract set contracts
 	contractSetContracts, err := a.c.Contracts(ctx, api.ContractsOpts{ContractSet: cfg.Config.Contracts.Set})
 	if err != nil {
-		a.l.Errorw(fmt.Sprintf("failed to fetch contract set contracts: %v", err))
-		return
+		return "", nil, nil, fmt.Errorf("failed to fetch contract set contracts: %w", err)
 	}
 
 	// build a map of contract set contracts
@@ -138,47 +251,51 @@ func (a *accounts) refillWorkerAccounts(ctx context.Context, w Worker) {
 	for _, contract := range contractSetContracts {
 		inContractSet[contract.ID] = struct{}{}
 	}
+	return workerID, contracts, inContractSet, nil
+}
 
-	// refill accounts in separate goroutines
-	for _, c := range contracts {
-		// launch refill if not already in progress
-		if a.markRefillInProgress(workerID, c.HostKey) {
-			go func(contract api.ContractMetadata) {
-				rCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-				defer cancel()
-				accountID, refilled, rerr := refillWorkerAccount(rCtx, a.a, w, contract)
-				if rerr != nil {
-					if rerr.Is(errMaxDriftExceeded) {
-						// register the alert if error is errMaxDriftExceeded
-						a.ap.RegisterAlert(ctx, newAccountRefillAlert(accountID, contract, *rerr))
-					}
-					if _, inSet := inContractSet[contract.ID]; inSet {
-						a.l.Errorw(rerr.err.Error(), rerr.keysAndValues...)
-					} else {
-						a.l.Debugw(rerr.err.Error(), rerr.keysAndValues...)
-					}
-				} else {
-					// dismiss alerts on success
-					a.ap.DismissAlert(ctx, alerts.IDForAccount(alertAccountRefillID, accountID))
-
-					// log success
-					if refilled {
-						a.l.Infow("Successfully funded account",
-							"account", accountID,
-							"host", contract.HostKey,
-							"balance", maxBalance,
-						)
-					}
-				}
+// refillWorkerAccount refills the worker's account with the contract's host if
+// necessary, it registers an alert if the host is potentially cheating or if
+// the account drifted and logs the outcome. The account is synced with the host
+// first if it wasn't synced within the sync interval.
+func (a *accounts) refillWorkerAccount(ctx context.Context, w Worker, workerID string, contract api.ContractMetadata, inContractSet map[types.FileContractID]struct{}) (rhpv3.Account, bool, *refillError) {
+	forceSync := a.markSyncDue(workerID, contract.HostKey)
+	accountID, refilled, rerr := refillWorkerAccount(ctx, a.a, w, contract, a.minBalance, a.maxBalance, a.maxDrift, forceSync)
+	if rerr != nil {
+		if rerr.Is(errMaxDriftExceeded) {
+			// register the alert if error is errMaxDriftExceeded
+			a.ap.RegisterAlert(ctx, newAccountRefillAlert(accountID, contract, *rerr))
+		} else if rerr.Is(errAccountDrifted) {
+			// register the alert if the account drifted
+			a.ap.RegisterAlert(ctx, newAccountDriftedAlert(accountID, contract, rerr.drift))
+		}
+		if _, inSet := inContractSet[contract.ID]; inSet {
+			a.l.Errorw(rerr.err.Error(), rerr.keysAndValues...)
+		} else {
+			a.l.Debugw(rerr.err.Error(), rerr.keysAndValues...)
+		}
+	} else {
+		// dismiss alerts on success
+		a.ap.DismissAlert(ctx,
+			alerts.IDForAccount(alertAccountRefillID, accountID),
+			alerts.IDForAccount(alertAccountDriftedID, accountID),
+		)
 
-				a.markRefillDone(workerID, contract.HostKey)
-			}(c)
+		// log success
+		if refilled {
+			a.l.Infow("Successfully funded account",
+				"account", accountID,
+				"host", contract.HostKey,
+				"balance", a.maxBalance,
+			)
 		}
 	}
+	return accountID, refilled, rerr
 }
 
 type refillError struct {
 	err           error
+	drift         *big.Int
 	keysAndValues []interface{}
 }
 
@@ -193,7 +310,7 @@ func (err *refillError) Is(target error) bool {
 	return errors.Is(err.err, target)
 }
 
-func refillWorkerAccount(ctx context.Context, a AccountStore, w Worker, contract api.ContractMetadata) (accountID rhpv3.Account, refilled bool, rerr *refillError) {
+func refillWorkerAccount(ctx context.Context, a AccountStore, w Worker, contract api.ContractMetadata, minBalance, maxBalance, maxDrift types.Currency, forceSync bool) (accountID rhpv3.Account, refilled bool, rerr *refillError) {
 	wrapErr := func(err error, keysAndValues ...interface{}) *refillError {
 		if err == nil {
 			return nil
@@ -231,8 +348,20 @@ func refillWorkerAccount(ctx context.Context, a AccountStore, w Worker, contract
 		return
 	}
 
-	// check if a resync is needed
-	if account.RequiresSync {
+	// drifted accounts aren't refilled until their drift is reset
+	if account.Drifted {
+		rerr = wrapErr(fmt.Errorf("not refilling account since its drift wasn't reset: %w", errAccountDrifted),
+			"accountID", account.ID,
+			"hostKey", contract.HostKey,
+			"drift", account.Drift,
+		)
+		rerr.drift = account.Drift
+		return
+	}
+
+	// check if a resync is needed, accounts are synced periodically to detect
+	// drift between the tracked balance and the one reported by the host
+	if account.RequiresSync || forceSync {
 		// sync the account
 		err = w.RHPSync(ctx, contract.ID, contract.HostKey, contract.HostIP, contract.SiamuxAddr)
 		if err != nil {
@@ -244,15 +373,34 @@ func refillWorkerAccount(ctx context.Context, a AccountStore, w Worker, contract
 		}
 
 		// refetch the account after syncing
+		driftBefore := account.Drift
 		account, err = a.Account(ctx, accountID, contract.HostKey)
 		if err != nil {
 			rerr = wrapErr(err)
 			return
 		}
+
+		// flag the account as drifted if the balance reported by the host
+		// diverged too much from the tracked balance
+		delta := new(big.Int).Sub(account.Drift, driftBefore)
+		if delta.CmpAbs(maxDrift.Big()) > 0 {
+			if err := a.MarkDrifted(ctx, accountID); err != nil {
+				rerr = wrapErr(fmt.Errorf("failed to mark account as drifted: %w", err))
+				return
+			}
+			rerr = wrapErr(fmt.Errorf("not refilling account since its balance drifted: %w", errAccountDrifted),
+				"accountID", account.ID,
+				"hostKey", contract.HostKey,
+				"delta", delta,
+				"maxDrift", maxDrift,
+			)
+			rerr.drift = account.Drift
+			return
+		}
 	}
 
 	// check if refill is needed
-	if account.Balance.Cmp(minBalance) >= 0 {
+	if !needsRefill(account, minBalance) {
 		rerr = wrapErr(err)
 		return
 	}
@@ -271,3 +419,9 @@ func refillWorkerAccount(ctx context.Context, a AccountStore, w Worker, contract
 	}
 	return
 }
+
+// needsRefill returns true if the account's balance dropped below the given
+// minimum balance.
+func needsRefill(account api.Account, minBalance types.Currency) bool {
+	return account.Balance.Cmp(minBalance.Big()) < 0
+}
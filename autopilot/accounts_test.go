@@ -0,0 +1,186 @@
+package autopilot
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	rhpv3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+type mockAccountStore struct {
+	accounts map[types.PublicKey]api.Account
+	drifted  map[rhpv3.Account]bool
+}
+
+func (s *mockAccountStore) Account(_ context.Context, _ rhpv3.Account, hk types.PublicKey) (api.Account, error) {
+	return s.accounts[hk], nil
+}
+
+func (s *mockAccountStore) MarkDrifted(_ context.Context, id rhpv3.Account) error {
+	s.drifted[id] = true
+	return nil
+}
+
+func (s *mockAccountStore) Accounts(_ context.Context) (accounts []api.Account, _ error) {
+	for _, a := range s.accounts {
+		accounts = append(accounts, a)
+	}
+	return
+}
+
+type mockAccountWorker struct {
+	Worker
+
+	funded map[types.PublicKey]types.Currency
+
+	// synced is the balance the host reports when the worker syncs an account
+	s      *mockAccountStore
+	synced map[types.PublicKey]types.Currency
+}
+
+func (w *mockAccountWorker) Account(_ context.Context, hk types.PublicKey) (rhpv3.Account, error) {
+	return rhpv3.Account(hk), nil
+}
+
+func (w *mockAccountWorker) RHPFund(_ context.Context, _ types.FileContractID, hk types.PublicKey, _, _ string, balance types.Currency) error {
+	w.funded[hk] = balance
+	return nil
+}
+
+func (w *mockAccountWorker) RHPSync(_ context.Context, _ types.FileContractID, hk types.PublicKey, _, _ string) error {
+	acc := w.s.accounts[hk]
+	balance := w.synced[hk].Big()
+	acc.Drift = new(big.Int).Add(acc.Drift, new(big.Int).Sub(balance, acc.Balance))
+	acc.Balance = balance
+	w.s.accounts[hk] = acc
+	return nil
+}
+
+func TestNewAccountsBalanceBand(t *testing.T) {
+	validate := func(minBalance, maxBalance types.Currency) error {
+		_, err := newAccounts(nil, nil, nil, nil, zap.NewNop().Sugar(), time.Minute, minBalance, maxBalance, types.ZeroCurrency)
+		return err
+	}
+
+	// assert a band with equal bounds is accepted
+	if err := validate(types.Siacoins(1), types.Siacoins(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert a min balance that exceeds the max balance is rejected
+	if err := validate(types.Siacoins(2), types.Siacoins(1)); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// assert a zero max balance is rejected
+	if err := validate(types.ZeroCurrency, types.ZeroCurrency); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRefillWorkerAccount(t *testing.T) {
+	minBalance := types.Siacoins(1).Div64(2)
+	maxBalance := types.Siacoins(1)
+
+	// prepare accounts below, at and above the floor
+	balances := map[types.PublicKey]types.Currency{
+		{1}: types.ZeroCurrency,
+		{2}: minBalance.Sub(types.NewCurrency64(1)),
+		{3}: minBalance,
+		{4}: maxBalance.Mul64(2),
+	}
+	s := &mockAccountStore{accounts: make(map[types.PublicKey]api.Account)}
+	for hk, balance := range balances {
+		s.accounts[hk] = api.Account{
+			ID:      rhpv3.Account(hk),
+			HostKey: hk,
+			Balance: balance.Big(),
+			Drift:   types.ZeroCurrency.Big(),
+		}
+	}
+
+	// refill all accounts
+	w := &mockAccountWorker{funded: make(map[types.PublicKey]types.Currency)}
+	for hk := range balances {
+		_, refilled, rerr := refillWorkerAccount(context.Background(), s, w, api.ContractMetadata{HostKey: hk}, minBalance, maxBalance, defaultMaxDrift, false)
+		if rerr != nil {
+			t.Fatal(rerr)
+		} else if refilled != needsRefill(s.accounts[hk], minBalance) {
+			t.Fatalf("unexpected refill for account with balance %v", balances[hk])
+		}
+	}
+
+	// assert only the accounts below the floor were topped up to the max
+	if len(w.funded) != 2 {
+		t.Fatalf("expected 2 accounts to be funded, got %v", len(w.funded))
+	}
+	for _, hk := range []types.PublicKey{{1}, {2}} {
+		if balance, ok := w.funded[hk]; !ok {
+			t.Fatalf("account %v wasn't funded", hk)
+		} else if !balance.Equals(maxBalance) {
+			t.Fatalf("account %v was funded with %v, expected %v", hk, balance, maxBalance)
+		}
+	}
+}
+
+func TestRefillWorkerAccountDrift(t *testing.T) {
+	minBalance := types.Siacoins(1).Div64(2)
+	maxBalance := types.Siacoins(1)
+	maxDrift := defaultMaxDrift
+
+	// prepare an account that drifts within the threshold and one that drifts
+	// beyond it
+	s := &mockAccountStore{
+		accounts: make(map[types.PublicKey]api.Account),
+		drifted:  make(map[rhpv3.Account]bool),
+	}
+	w := &mockAccountWorker{
+		funded: make(map[types.PublicKey]types.Currency),
+		s:      s,
+		synced: map[types.PublicKey]types.Currency{
+			{1}: maxBalance.Sub(maxDrift),
+			{2}: maxBalance.Sub(maxDrift).Sub(types.NewCurrency64(1)),
+		},
+	}
+	for hk := range w.synced {
+		s.accounts[hk] = api.Account{
+			ID:      rhpv3.Account(hk),
+			HostKey: hk,
+			Balance: maxBalance.Big(),
+			Drift:   types.ZeroCurrency.Big(),
+		}
+	}
+
+	// assert the account within the threshold isn't flagged
+	_, _, rerr := refillWorkerAccount(context.Background(), s, w, api.ContractMetadata{HostKey: types.PublicKey{1}}, minBalance, maxBalance, maxDrift, true)
+	if rerr != nil {
+		t.Fatal(rerr)
+	} else if s.drifted[rhpv3.Account(types.PublicKey{1})] {
+		t.Fatal("account shouldn't be flagged as drifted")
+	}
+
+	// assert the account beyond the threshold is flagged
+	_, _, rerr = refillWorkerAccount(context.Background(), s, w, api.ContractMetadata{HostKey: types.PublicKey{2}}, minBalance, maxBalance, maxDrift, true)
+	if rerr == nil || !rerr.Is(errAccountDrifted) {
+		t.Fatalf("expected errAccountDrifted, got %v", rerr)
+	} else if !s.drifted[rhpv3.Account(types.PublicKey{2})] {
+		t.Fatal("account should be flagged as drifted")
+	}
+
+	// assert drifted accounts aren't refilled
+	acc := s.accounts[types.PublicKey{2}]
+	acc.Balance = types.ZeroCurrency.Big()
+	acc.Drifted = true
+	s.accounts[types.PublicKey{2}] = acc
+	_, refilled, rerr := refillWorkerAccount(context.Background(), s, w, api.ContractMetadata{HostKey: types.PublicKey{2}}, minBalance, maxBalance, maxDrift, false)
+	if rerr == nil || !rerr.Is(errAccountDrifted) {
+		t.Fatalf("expected errAccountDrifted, got %v", rerr)
+	} else if refilled {
+		t.Fatal("drifted account shouldn't be refilled")
+	}
+}
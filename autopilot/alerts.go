@@ -3,6 +3,7 @@ package autopilot
 import (
 	"context"
 	"fmt"
+	"math/big"
 	"time"
 
 	rhpv3 "go.sia.tech/core/rhp/v3"
@@ -13,10 +14,11 @@ import (
 )
 
 var (
-	alertAccountRefillID = alerts.RandomAlertID() // constant until restarted
-	alertLowBalanceID    = alerts.RandomAlertID() // constant until restarted
-	alertMigrationID     = alerts.RandomAlertID() // constant until restarted
-	alertPruningID       = alerts.RandomAlertID() // constant until restarted
+	alertAccountDriftedID = alerts.RandomAlertID() // constant until restarted
+	alertAccountRefillID  = alerts.RandomAlertID() // constant until restarted
+	alertLowBalanceID     = alerts.RandomAlertID() // constant until restarted
+	alertMigrationID      = alerts.RandomAlertID() // constant until restarted
+	alertPruningID        = alerts.RandomAlertID() // constant until restarted
 )
 
 func (ap *Autopilot) RegisterAlert(ctx context.Context, a alerts.Alert) {
@@ -53,6 +55,22 @@ func newAccountLowBalanceAlert(address types.Address, balance, allowance types.C
 	}
 }
 
+func newAccountDriftedAlert(id rhpv3.Account, contract api.ContractMetadata, drift *big.Int) alerts.Alert {
+	return alerts.Alert{
+		ID:       alerts.IDForAccount(alertAccountDriftedID, id),
+		Severity: alerts.SeverityWarning,
+		Message:  "Ephemeral account drifted",
+		Data: map[string]interface{}{
+			"accountID":  id.String(),
+			"contractID": contract.ID.String(),
+			"hostKey":    contract.HostKey.String(),
+			"drift":      drift.String(),
+			"hint":       "The balance reported by the host diverged from the tracked balance, the account won't be used until its drift is reset through POST /account/:id/resetdrift.",
+		},
+		Timestamp: time.Now(),
+	}
+}
+
 func newAccountRefillAlert(id rhpv3.Account, contract api.ContractMetadata, err refillError) alerts.Alert {
 	data := map[string]interface{}{
 		"error":      err.Error(),
@@ -18,6 +18,7 @@ import (
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/autopilot/contractor"
 	"go.sia.tech/renterd/build"
+	"go.sia.tech/renterd/config"
 	"go.sia.tech/renterd/internal/utils"
 	"go.sia.tech/renterd/object"
 	"go.sia.tech/renterd/wallet"
@@ -32,6 +33,7 @@ type Bus interface {
 	// Accounts
 	Account(ctx context.Context, id rhpv3.Account, hostKey types.PublicKey) (account api.Account, err error)
 	Accounts(ctx context.Context) (accounts []api.Account, err error)
+	MarkDrifted(ctx context.Context, id rhpv3.Account) error
 
 	// Autopilots
 	Autopilot(ctx context.Context, id string) (autopilot api.Autopilot, err error)
@@ -48,13 +50,13 @@ type Bus interface {
 	Contract(ctx context.Context, id types.FileContractID) (api.ContractMetadata, error)
 	Contracts(ctx context.Context, opts api.ContractsOpts) (contracts []api.ContractMetadata, err error)
 	FileContractTax(ctx context.Context, payout types.Currency) (types.Currency, error)
-	SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) error
+	SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) (api.ContractSetUpdateResponse, error)
 	PrunableData(ctx context.Context) (prunableData api.ContractsPrunableDataResponse, err error)
 
 	// hostdb
 	Host(ctx context.Context, hostKey types.PublicKey) (api.Host, error)
 	HostsForScanning(ctx context.Context, opts api.HostsForScanningOptions) ([]api.HostAddress, error)
-	RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime time.Duration) (uint64, error)
+	RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime, gracePeriod time.Duration) (uint64, error)
 	SearchHosts(ctx context.Context, opts api.SearchHostOptions) ([]api.Host, error)
 	UpdateHostCheck(ctx context.Context, autopilotID string, hostKey types.PublicKey, hostCheck api.HostCheck) error
 
@@ -69,9 +71,14 @@ type Bus interface {
 	ObjectsBySlabKey(ctx context.Context, bucket string, key object.EncryptionKey) (objects []api.ObjectMetadata, err error)
 	RefreshHealth(ctx context.Context) error
 	Slab(ctx context.Context, key object.EncryptionKey) (object.Slab, error)
+	SlabsForEvacuation(ctx context.Context, hostKeys []types.PublicKey, limit int) ([]api.UnhealthySlab, error)
 	SlabsForMigration(ctx context.Context, healthCutoff float64, set string, limit int) ([]api.UnhealthySlab, error)
 
+	// params
+	GougingParams(ctx context.Context) (api.GougingParams, error)
+
 	// settings
+	Setting(ctx context.Context, key string, value interface{}) error
 	UpdateSetting(ctx context.Context, key string, value interface{}) error
 	GougingSettings(ctx context.Context) (gs api.GougingSettings, err error)
 	RedundancySettings(ctx context.Context) (rs api.RedundancySettings, err error)
@@ -80,12 +87,12 @@ type Bus interface {
 	SyncerPeers(ctx context.Context) (resp []string, err error)
 
 	// txpool
-	RecommendedFee(ctx context.Context) (types.Currency, error)
 	TransactionPool(ctx context.Context) (txns []types.Transaction, err error)
 
 	// wallet
 	Wallet(ctx context.Context) (api.WalletResponse, error)
 	WalletDiscard(ctx context.Context, txn types.Transaction) error
+	WalletFeeEstimate(ctx context.Context) (api.FeeEstimate, error)
 	WalletOutputs(ctx context.Context) (resp []wallet.SiacoinElement, err error)
 	WalletPending(ctx context.Context) (resp []types.Transaction, err error)
 	WalletRedistribute(ctx context.Context, outputs int, amount types.Currency) (ids []types.TransactionID, err error)
@@ -122,7 +129,7 @@ type Autopilot struct {
 }
 
 // New initializes an Autopilot.
-func New(id string, bus Bus, workers []Worker, logger *zap.Logger, heartbeat time.Duration, scannerScanInterval time.Duration, scannerBatchSize, scannerNumThreads uint64, migrationHealthCutoff float64, accountsRefillInterval time.Duration, revisionSubmissionBuffer, migratorParallelSlabsPerWorker uint64, revisionBroadcastInterval time.Duration) (*Autopilot, error) {
+func New(cfg config.Autopilot, id string, bus Bus, workers []Worker, logger *zap.Logger) (*Autopilot, error) {
 	shutdownCtx, shutdownCtxCancel := context.WithCancel(context.Background())
 
 	ap := &Autopilot{
@@ -135,13 +142,27 @@ func New(id string, bus Bus, workers []Worker, logger *zap.Logger, heartbeat tim
 		shutdownCtx:       shutdownCtx,
 		shutdownCtxCancel: shutdownCtxCancel,
 
-		tickerDuration: heartbeat,
+		tickerDuration: cfg.Heartbeat,
+	}
+
+	// the batch size is only adapted if bounds are configured
+	scannerBatchSizeMin, scannerBatchSizeMax := cfg.ScannerBatchSizeMin, cfg.ScannerBatchSizeMax
+	if scannerBatchSizeMin == 0 {
+		scannerBatchSizeMin = cfg.ScannerBatchSize
+	}
+	if scannerBatchSizeMax == 0 {
+		scannerBatchSizeMax = cfg.ScannerBatchSize
 	}
+
 	scanner, err := newScanner(
 		ap,
-		scannerBatchSize,
-		scannerNumThreads,
-		scannerScanInterval,
+		cfg.ScannerBatchSize,
+		scannerBatchSizeMin,
+		scannerBatchSizeMax,
+		cfg.ScannerNumThreads,
+		cfg.ScannerHistorySize,
+		cfg.ScannerMinRecentScanFailures,
+		cfg.ScannerInterval,
 		scannerTimeoutInterval,
 		scannerTimeoutMinTimeout,
 	)
@@ -150,9 +171,12 @@ func New(id string, bus Bus, workers []Worker, logger *zap.Logger, heartbeat tim
 	}
 
 	ap.s = scanner
-	ap.c = contractor.New(bus, bus, ap.logger, revisionSubmissionBuffer, revisionBroadcastInterval)
-	ap.m = newMigrator(ap, migrationHealthCutoff, migratorParallelSlabsPerWorker)
-	ap.a = newAccounts(ap, ap.bus, ap.bus, ap.workers, ap.logger, accountsRefillInterval)
+	ap.c = contractor.New(bus, bus, ap.logger, cfg.RevisionSubmissionBuffer, cfg.RevisionBroadcastInterval, cfg.ContractSelectionSeed)
+	ap.m = newMigrator(ap, cfg.MigrationHealthCutoff, cfg.MigratorParallelSlabsPerWorker)
+	ap.a, err = newAccounts(ap, ap.bus, ap.bus, ap.workers, ap.logger, cfg.AccountsRefillInterval, cfg.AccountsMinBalance, cfg.AccountsMaxBalance, cfg.AccountsMaxDrift)
+	if err != nil {
+		return nil, err
+	}
 
 	return ap, nil
 }
@@ -164,16 +188,54 @@ func (ap *Autopilot) Config(ctx context.Context) (api.Autopilot, error) {
 // Handler returns an HTTP handler that serves the autopilot api.
 func (ap *Autopilot) Handler() http.Handler {
 	return jape.Mux(map[string]jape.Handler{
-		"GET    /config":        ap.configHandlerGET,
-		"PUT    /config":        ap.configHandlerPUT,
-		"POST   /config":        ap.configHandlerPOST,
-		"POST   /hosts":         ap.hostsHandlerPOST,
-		"GET    /host/:hostKey": ap.hostHandlerGET,
-		"GET    /state":         ap.stateHandlerGET,
-		"POST   /trigger":       ap.triggerHandlerPOST,
+		"POST   /accounts/rebalance":   ap.accountsRebalanceHandlerPOST,
+		"GET    /config":               ap.configHandlerGET,
+		"PUT    /config":               ap.configHandlerPUT,
+		"POST   /config":               ap.configHandlerPOST,
+		"GET    /contracts/preview":    ap.contractsPreviewHandlerGET,
+		"POST   /contracts/prune":      ap.contractsPruneHandlerPOST,
+		"POST   /formation/estimate":   ap.formationEstimateHandlerPOST,
+		"POST   /hosts":                ap.hostsHandlerPOST,
+		"GET    /host/:hostKey":        ap.hostHandlerGET,
+		"POST   /host/:hostKey/rescan": ap.hostRescanHandlerPOST,
+		"GET    /host/:hostKey/score":  ap.hostScoreHandlerGET,
+		"POST   /migrator/pause":       ap.migratorPauseHandlerPOST,
+		"POST   /migrator/resume":      ap.migratorResumeHandlerPOST,
+		"GET    /migrator/status":      ap.migratorStatusHandlerGET,
+		"POST   /scan":                 ap.scanHandlerPOST,
+		"GET    /scanner/scans":        ap.scannerScansHandlerGET,
+		"GET    /scanner/status":       ap.scannerStatusHandlerGET,
+		"POST   /slabs/migrate":        ap.slabsMigrateHandlerPOST,
+		"GET    /state":                ap.stateHandlerGET,
+		"POST   /trigger":              ap.triggerHandlerPOST,
 	})
 }
 
+func (ap *Autopilot) accountsRebalanceHandlerPOST(jc jape.Context) {
+	resp := api.AccountsRebalanceResponse{
+		Accounts:   []api.AccountRebalanceResult{},
+		MinBalance: ap.a.minBalance,
+		MaxBalance: ap.a.maxBalance,
+	}
+
+	// rebalance the accounts of all workers
+	var err error
+	ap.workers.withWorkers(func(workers []Worker) {
+		for _, w := range workers {
+			var results []api.AccountRebalanceResult
+			results, err = ap.a.rebalance(jc.Request.Context(), w)
+			if err != nil {
+				return
+			}
+			resp.Accounts = append(resp.Accounts, results...)
+		}
+	})
+	if jc.Check("failed to rebalance accounts", err) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
 func (ap *Autopilot) configHandlerPOST(jc jape.Context) {
 	ctx := jc.Request.Context()
 
@@ -191,10 +253,11 @@ func (ap *Autopilot) configHandlerPOST(jc jape.Context) {
 	if jc.Check("failed to get consensus state", err) != nil {
 		return
 	}
-	fee, err := ap.bus.RecommendedFee(ctx)
-	if jc.Check("failed to get recommended fee", err) != nil {
+	fees, err := ap.bus.WalletFeeEstimate(ctx)
+	if jc.Check("failed to get fee estimate", err) != nil {
 		return
 	}
+	fee := fees.Fast
 
 	// fetch hosts
 	hosts, err := ap.bus.SearchHosts(ctx, api.SearchHostOptions{Limit: -1, FilterMode: api.HostFilterModeAllowed})
@@ -206,6 +269,70 @@ func (ap *Autopilot) configHandlerPOST(jc jape.Context) {
 	jc.Encode(contractor.EvaluateConfig(reqCfg, cs, fee, rs, gs, hosts))
 }
 
+func (ap *Autopilot) contractsPruneHandlerPOST(jc jape.Context) {
+	resp, err := ap.pruneContracts(jc.Request.Context(), ap.workers)
+	if errors.Is(err, errPruningInProgress) {
+		jc.Error(err, http.StatusConflict)
+		return
+	} else if jc.Check("failed to prune contracts", err) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
+func (ap *Autopilot) contractsPreviewHandlerGET(jc jape.Context) {
+	state, err := ap.buildState(jc.Request.Context())
+	if jc.Check("failed to build state", err) != nil {
+		return
+	}
+
+	var resp api.ContractsPreviewResponse
+	ap.workers.withWorker(func(w Worker) {
+		resp, err = ap.c.PreviewContractMaintenance(jc.Request.Context(), w, state)
+	})
+	if jc.Check("failed to preview contract maintenance", err) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
+func (ap *Autopilot) formationEstimateHandlerPOST(jc jape.Context) {
+	var req api.ContractFormationEstimateRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	state, err := ap.buildState(jc.Request.Context())
+	if jc.Check("failed to build state", err) != nil {
+		return
+	}
+	resp, err := ap.c.EstimateContractFormation(jc.Request.Context(), state, req)
+	if errors.Is(err, contractor.ErrInvalidFormationEstimate) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("failed to estimate contract formation", err) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
+func (ap *Autopilot) slabsMigrateHandlerPOST(jc jape.Context) {
+	var req api.SlabsMigrateRequest
+	if jc.Decode(&req) != nil {
+		return
+	} else if len(req.HostKeys) == 0 {
+		jc.Error(errors.New("no hosts to evacuate"), http.StatusBadRequest)
+		return
+	}
+	resp, err := ap.m.evacuateHosts(jc.Request.Context(), ap.workers, req.HostKeys)
+	if errors.Is(err, api.ErrContractSetNotSpecified) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("failed to migrate slabs", err) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
 func (ap *Autopilot) Run() error {
 	ap.startStopMu.Lock()
 	if ap.isRunning() {
@@ -733,6 +860,7 @@ func (ap *Autopilot) hostHandlerGET(jc jape.Context) {
 				GougingBreakdown: check.Gouging,
 				Score:            check.Score.Score(),
 				ScoreBreakdown:   check.Score,
+				AdjustedScore:    check.Score.Score() * hi.ScoreMultiplier(),
 				Usable:           check.Usability.IsUsable(),
 				UnusableReasons:  check.Usability.UnusableReasons(),
 			},
@@ -743,6 +871,53 @@ func (ap *Autopilot) hostHandlerGET(jc jape.Context) {
 	jc.Encode(api.HostResponse{Host: hi})
 }
 
+func (ap *Autopilot) hostRescanHandlerPOST(jc jape.Context) {
+	var hk types.PublicKey
+	if jc.DecodeParam("hostKey", &hk) != nil {
+		return
+	}
+
+	h, err := ap.bus.Host(jc.Request.Context(), hk)
+	if jc.Check("failed to get host", err) != nil {
+		return
+	}
+
+	var scan api.RHPScanResponse
+	ap.workers.withWorker(func(w Worker) {
+		scan, err = ap.s.rescanHost(jc.Request.Context(), w, hk, h.NetAddress)
+	})
+	if jc.Check("failed to rescan host", err) != nil {
+		return
+	}
+	jc.Encode(scan)
+}
+
+func (ap *Autopilot) hostScoreHandlerGET(jc jape.Context) {
+	var hk types.PublicKey
+	if jc.DecodeParam("hostKey", &hk) != nil {
+		return
+	}
+
+	state, err := ap.buildState(jc.Request.Context())
+	if jc.Check("failed to build state", err) != nil {
+		return
+	}
+
+	hi, err := ap.bus.Host(jc.Request.Context(), hk)
+	if jc.Check("failed to get host info", err) != nil {
+		return
+	}
+
+	sb := contractor.HostScore(state, hi)
+	jc.Encode(api.HostScoreResponse{
+		HostKey:       hk,
+		Score:         sb.Score(),
+		Breakdown:     sb,
+		AdjustedScore: sb.Score() * hi.ScoreMultiplier(),
+		Penalizing:    sb.Lowest(),
+	})
+}
+
 func (ap *Autopilot) hostsHandlerPOST(jc jape.Context) {
 	var req api.SearchHostsRequest
 	if jc.Decode(&req) != nil {
@@ -779,6 +954,7 @@ func (ap *Autopilot) hostsHandlerPOST(jc jape.Context) {
 					GougingBreakdown: check.Gouging,
 					Score:            check.Score.Score(),
 					ScoreBreakdown:   check.Score,
+					AdjustedScore:    check.Score.Score() * host.ScoreMultiplier(),
 					Usable:           check.Usability.IsUsable(),
 					UnusableReasons:  check.Usability.UnusableReasons(),
 				},
@@ -790,6 +966,34 @@ func (ap *Autopilot) hostsHandlerPOST(jc jape.Context) {
 	jc.Encode(resps)
 }
 
+func (ap *Autopilot) migratorPauseHandlerPOST(jc jape.Context) {
+	jc.Check("failed to pause migrator", ap.m.Pause(jc.Request.Context()))
+}
+
+func (ap *Autopilot) migratorResumeHandlerPOST(jc jape.Context) {
+	jc.Check("failed to resume migrator", ap.m.Resume(jc.Request.Context()))
+}
+
+func (ap *Autopilot) migratorStatusHandlerGET(jc jape.Context) {
+	jc.Encode(ap.m.status())
+}
+
+func (ap *Autopilot) scannerScansHandlerGET(jc jape.Context) {
+	jc.Encode(ap.s.recentScans())
+}
+
+func (ap *Autopilot) scanHandlerPOST(jc jape.Context) {
+	var started bool
+	ap.workers.withWorker(func(w Worker) {
+		started = ap.s.tryStartScan(ap.shutdownCtx, w)
+	})
+	jc.Encode(api.AutopilotScanResponse{Started: started})
+}
+
+func (ap *Autopilot) scannerStatusHandlerGET(jc jape.Context) {
+	jc.Encode(ap.s.status())
+}
+
 func (ap *Autopilot) stateHandlerGET(jc jape.Context) {
 	ap.mu.Lock()
 	pruning, pLastStart := ap.pruning, ap.pruningLastStart // TODO: move to a 'pruner' type
@@ -842,14 +1046,17 @@ func (ap *Autopilot) buildState(ctx context.Context) (*contractor.MaintenanceSta
 		return nil, fmt.Errorf("could not fetch redundancy settings, err: %v", err)
 	}
 
-	// fetch gouging settings
-	gs, err := ap.bus.GougingSettings(ctx)
+	// fetch gouging settings, we fetch them through the gouging params so the
+	// price limits derived from the host set by the bus are applied
+	gp, err := ap.bus.GougingParams(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch gouging settings, err: %v", err)
 	}
+	gs := gp.GougingSettings
 
-	// fetch recommended transaction fee
-	fee, err := ap.bus.RecommendedFee(ctx)
+	// fetch the fee estimate, we budget renewals using the fast tier to avoid
+	// underestimating the fee
+	fees, err := ap.bus.WalletFeeEstimate(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch fee, err: %v", err)
 	}
@@ -893,8 +1100,9 @@ func (ap *Autopilot) buildState(ctx context.Context) (*contractor.MaintenanceSta
 		AP: autopilot,
 
 		Address:                address,
-		Fee:                    fee,
+		Fee:                    fees.Fast,
 		SkipContractFormations: skipContractFormations,
+		Spendable:              wi.Spendable,
 	}, nil
 }
 
@@ -932,9 +1140,9 @@ func compatV105Host(ctx context.Context, cfg api.ContractsConfig, b Bus, hk type
 	if err != nil {
 		return fmt.Errorf("failed to fetch consensus state from bus: %w", err)
 	}
-	_, err = b.RecommendedFee(ctx)
+	_, err = b.WalletFeeEstimate(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to fetch recommended fee from bus: %w", err)
+		return fmt.Errorf("failed to fetch fee estimate from bus: %w", err)
 	}
 	return nil
 }
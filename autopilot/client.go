@@ -52,6 +52,92 @@ func (c *Client) HostInfos(ctx context.Context, filterMode, usabilityMode, addre
 	return
 }
 
+// HostScore returns the breakdown of the given host's score.
+func (c *Client) HostScore(hostKey types.PublicKey) (resp api.HostScoreResponse, err error) {
+	err = c.c.GET(fmt.Sprintf("/host/%s/score", hostKey), &resp)
+	return
+}
+
+// MigrateSlabs migrates all slabs with sectors stored on the given hosts to
+// other hosts in the contract set.
+func (c *Client) MigrateSlabs(ctx context.Context, hostKeys []types.PublicKey) (resp api.SlabsMigrateResponse, err error) {
+	err = c.c.WithContext(ctx).POST("/slabs/migrate", api.SlabsMigrateRequest{HostKeys: hostKeys}, &resp)
+	return
+}
+
+// EstimateContractFormation estimates the upfront cost of forming contracts
+// with the best hosts without forming any.
+func (c *Client) EstimateContractFormation(ctx context.Context, req api.ContractFormationEstimateRequest) (resp api.ContractFormationEstimateResponse, err error) {
+	err = c.c.WithContext(ctx).POST("/formation/estimate", req, &resp)
+	return
+}
+
+// PreviewContracts returns the changes the next contract maintenance would
+// make to the contract set, without performing them.
+func (c *Client) PreviewContracts(ctx context.Context) (resp api.ContractsPreviewResponse, err error) {
+	err = c.c.WithContext(ctx).GET("/contracts/preview", &resp)
+	return
+}
+
+// PruneContracts prunes all prunable contracts in the autopilot's contract set
+// and returns the amount of data that was reclaimed per contract.
+func (c *Client) PruneContracts(ctx context.Context) (resp api.ContractsPruneResponse, err error) {
+	err = c.c.WithContext(ctx).POST("/contracts/prune", nil, &resp)
+	return
+}
+
+// RebalanceAccounts refills all of the workers' accounts that dropped below
+// the minimum balance and returns the outcome per account.
+func (c *Client) RebalanceAccounts(ctx context.Context) (resp api.AccountsRebalanceResponse, err error) {
+	err = c.c.WithContext(ctx).POST("/accounts/rebalance", nil, &resp)
+	return
+}
+
+// RecentScans returns the most recent host scan results, ordered from newest to
+// oldest.
+func (c *Client) RecentScans() (scans []api.ScanResult, err error) {
+	err = c.c.GET("/scanner/scans", &scans)
+	return
+}
+
+// RescanHost scans the host with given host key and returns its settings,
+// price table and the measured latency.
+func (c *Client) RescanHost(hostKey types.PublicKey) (resp api.RHPScanResponse, err error) {
+	err = c.c.POST(fmt.Sprintf("/host/%s/rescan", hostKey), nil, &resp)
+	return
+}
+
+// MigratorStatus returns the status of the migrator.
+func (c *Client) MigratorStatus() (status api.MigratorStatusResponse, err error) {
+	err = c.c.GET("/migrator/status", &status)
+	return
+}
+
+// PauseMigrations prevents the autopilot from starting new slab migrations
+// until they are resumed.
+func (c *Client) PauseMigrations(ctx context.Context) error {
+	return c.c.WithContext(ctx).POST("/migrator/pause", nil, nil)
+}
+
+// ResumeMigrations allows the autopilot to perform slab migrations again.
+func (c *Client) ResumeMigrations(ctx context.Context) error {
+	return c.c.WithContext(ctx).POST("/migrator/resume", nil, nil)
+}
+
+// Scan starts a scan over all hosts right away and returns whether it was
+// started, no scan is started if one is already ongoing.
+func (c *Client) Scan(ctx context.Context) (_ bool, err error) {
+	var resp api.AutopilotScanResponse
+	err = c.c.WithContext(ctx).POST("/scan", nil, &resp)
+	return resp.Started, err
+}
+
+// ScannerStatus returns the progress of the ongoing host scan.
+func (c *Client) ScannerStatus() (status api.ScannerStatusResponse, err error) {
+	err = c.c.GET("/scanner/status", &status)
+	return
+}
+
 // State returns the current state of the autopilot.
 func (c *Client) State() (state api.AutopilotStateResponse, err error) {
 	err = c.c.GET("/state", &state)
@@ -17,6 +17,7 @@ var (
 	errInvalidHandshake          = errors.New("couldn't read host's handshake")
 	errInvalidHandshakeSignature = errors.New("host's handshake signature was invalid")
 	errInvalidMerkleProof        = errors.New("host supplied invalid Merkle proof")
+	errPruningInProgress         = errors.New("contract pruning already in progress")
 )
 
 const (
@@ -82,6 +83,19 @@ func (pr pruneResult) toAlert() (id types.Hash256, alert *alerts.Alert) {
 	return
 }
 
+func (pr pruneResult) toResult() api.ContractPruneResult {
+	res := api.ContractPruneResult{
+		ContractID: pr.fcid,
+		HostKey:    pr.hk,
+		Pruned:     pr.pruned,
+		Remaining:  pr.remaining,
+	}
+	if pr.err != nil {
+		res.Error = pr.err.Error()
+	}
+	return res
+}
+
 func (pr pruneResult) toMetric() api.ContractPruneMetric {
 	return api.ContractPruneMetric{
 		Timestamp:  api.TimeRFC3339(pr.ts),
@@ -93,9 +107,9 @@ func (pr pruneResult) toMetric() api.ContractPruneMetric {
 	}
 }
 
-func (ap *Autopilot) fetchPrunableContracts() (prunable []api.ContractPrunableData, _ error) {
+func (ap *Autopilot) fetchPrunableContracts(ctx context.Context) (prunable []api.ContractPrunableData, _ error) {
 	// use a sane timeout
-	ctx, cancel := context.WithTimeout(ap.shutdownCtx, time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
 	defer cancel()
 
 	// fetch prunable data
@@ -145,17 +159,17 @@ func (ap *Autopilot) hostForContract(ctx context.Context, fcid types.FileContrac
 	return
 }
 
-func (ap *Autopilot) performContractPruning(wp *workerPool) {
+func (ap *Autopilot) performContractPruning(ctx context.Context, wp *workerPool) (results []pruneResult, _ error) {
 	ap.logger.Info("performing contract pruning")
 
 	// fetch prunable contracts
-	prunable, err := ap.fetchPrunableContracts()
+	prunable, err := ap.fetchPrunableContracts(ctx)
 	if err != nil {
 		ap.logger.Error(err)
-		return
+		return nil, err
 	} else if len(prunable) == 0 {
 		ap.logger.Info("no contracts to prune")
-		return
+		return nil, nil
 	}
 
 	// prune every contract individually, one at a time and for a maximum
@@ -164,13 +178,13 @@ func (ap *Autopilot) performContractPruning(wp *workerPool) {
 	var metrics pruneMetrics
 	wp.withWorker(func(w Worker) {
 		for _, contract := range prunable {
-			// return if we're stopped
-			if ap.isStopped() {
+			// return if we're stopped or the context is done
+			if ap.isStopped() || ctx.Err() != nil {
 				return
 			}
 
 			// prune contract
-			result := ap.pruneContract(w, contract.ID)
+			result := ap.pruneContract(ctx, w, contract.ID)
 			if result.err != nil {
 				ap.logger.Error(result)
 			} else {
@@ -188,6 +202,7 @@ func (ap *Autopilot) performContractPruning(wp *workerPool) {
 
 			// handle metrics
 			metrics = append(metrics, result.toMetric())
+			results = append(results, result)
 		}
 	})
 
@@ -200,11 +215,12 @@ func (ap *Autopilot) performContractPruning(wp *workerPool) {
 
 	// log metrics
 	ap.logger.Info(metrics)
+	return results, nil
 }
 
-func (ap *Autopilot) pruneContract(w Worker, fcid types.FileContractID) pruneResult {
+func (ap *Autopilot) pruneContract(ctx context.Context, w Worker, fcid types.FileContractID) pruneResult {
 	// create a sane timeout
-	ctx, cancel := context.WithTimeout(ap.shutdownCtx, 2*timeoutPruneContract)
+	ctx, cancel := context.WithTimeout(ctx, 2*timeoutPruneContract)
 	defer cancel()
 
 	// fetch the host
@@ -251,6 +267,40 @@ func humanReadableSize(b int) string {
 		float64(b)/float64(div), "KMGTPE"[exp])
 }
 
+// pruneContracts synchronously prunes all prunable contracts in the contract
+// set, it returns an error if pruning is already in progress. Pruning stops
+// when the given context is done.
+func (ap *Autopilot) pruneContracts(ctx context.Context, wp *workerPool) (api.ContractsPruneResponse, error) {
+	ap.mu.Lock()
+	if ap.pruning {
+		ap.mu.Unlock()
+		return api.ContractsPruneResponse{}, errPruningInProgress
+	}
+	ap.pruning = true
+	ap.pruningLastStart = time.Now()
+	ap.mu.Unlock()
+
+	defer func() {
+		ap.mu.Lock()
+		ap.pruning = false
+		ap.mu.Unlock()
+	}()
+
+	results, err := ap.performContractPruning(ctx, wp)
+	if err != nil {
+		return api.ContractsPruneResponse{}, err
+	} else if err := ctx.Err(); err != nil {
+		return api.ContractsPruneResponse{}, err
+	}
+
+	resp := api.ContractsPruneResponse{Contracts: make([]api.ContractPruneResult, 0, len(results))}
+	for _, result := range results {
+		resp.Contracts = append(resp.Contracts, result.toResult())
+		resp.TotalPruned += result.pruned
+	}
+	return resp, nil
+}
+
 func (ap *Autopilot) tryPerformPruning(wp *workerPool) {
 	ap.mu.Lock()
 	if ap.pruning || ap.isStopped() {
@@ -264,7 +314,7 @@ func (ap *Autopilot) tryPerformPruning(wp *workerPool) {
 	ap.wg.Add(1)
 	go func() {
 		defer ap.wg.Done()
-		ap.performContractPruning(wp)
+		_, _ = ap.performContractPruning(ap.shutdownCtx, wp)
 		ap.mu.Lock()
 		ap.pruning = false
 		ap.mu.Unlock()
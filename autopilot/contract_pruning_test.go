@@ -0,0 +1,105 @@
+package autopilot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/alerts"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+type mockPruningBus struct {
+	Bus
+}
+
+func (b *mockPruningBus) Autopilot(_ context.Context, id string) (api.Autopilot, error) {
+	return api.Autopilot{ID: id, Config: api.AutopilotConfig{Contracts: api.ContractsConfig{Set: "set"}}}, nil
+}
+
+func (b *mockPruningBus) Contract(_ context.Context, id types.FileContractID) (api.ContractMetadata, error) {
+	return api.ContractMetadata{ID: id}, nil
+}
+
+func (b *mockPruningBus) Contracts(_ context.Context, _ api.ContractsOpts) ([]api.ContractMetadata, error) {
+	return []api.ContractMetadata{{ID: types.FileContractID{1}}}, nil
+}
+
+func (b *mockPruningBus) DismissAlerts(_ context.Context, _ ...types.Hash256) error {
+	return nil
+}
+
+func (b *mockPruningBus) Host(_ context.Context, hk types.PublicKey) (api.Host, error) {
+	return api.Host{PublicKey: hk}, nil
+}
+
+func (b *mockPruningBus) PrunableData(_ context.Context) (api.ContractsPrunableDataResponse, error) {
+	return api.ContractsPrunableDataResponse{
+		Contracts:     []api.ContractPrunableData{{ID: types.FileContractID{1}, ContractSize: api.ContractSize{Prunable: 1}}},
+		TotalPrunable: 1,
+	}, nil
+}
+
+func (b *mockPruningBus) RecordContractPruneMetric(_ context.Context, _ ...api.ContractPruneMetric) error {
+	return nil
+}
+
+func (b *mockPruningBus) RegisterAlert(_ context.Context, _ alerts.Alert) error {
+	return nil
+}
+
+type mockPruningWorker struct {
+	Worker
+
+	ctxs []context.Context
+}
+
+func (w *mockPruningWorker) RHPPruneContract(ctx context.Context, _ types.FileContractID, _ time.Duration) (uint64, uint64, error) {
+	w.ctxs = append(w.ctxs, ctx)
+	return 1, 0, nil
+}
+
+func TestContractsPruneHandlerContext(t *testing.T) {
+	b := &mockPruningBus{}
+	w := &mockPruningWorker{}
+	ap := &Autopilot{
+		id:          "autopilot",
+		alerts:      alerts.WithOrigin(b, "autopilot"),
+		bus:         b,
+		logger:      zap.NewNop().Sugar(),
+		workers:     newWorkerPool([]Worker{w}),
+		shutdownCtx: context.Background(),
+	}
+
+	// prune contracts through the handler
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	req := httptest.NewRequest(http.MethodPost, "/contracts/prune", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	ap.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %v: %v", rec.Code, rec.Body.String())
+	}
+
+	// assert the contract was pruned using the request context
+	if len(w.ctxs) != 1 {
+		t.Fatalf("expected 1 contract to be pruned, got %v", len(w.ctxs))
+	} else if w.ctxs[0].Value(ctxKey{}) != "request" {
+		t.Fatal("expected the request context to be used")
+	}
+
+	// assert pruning is aborted once the request is cancelled
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	rec = httptest.NewRecorder()
+	ap.Handler().ServeHTTP(rec, req.WithContext(ctx))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status %v", rec.Code)
+	} else if len(w.ctxs) != 1 {
+		t.Fatal("expected no contracts to be pruned after the request was cancelled")
+	}
+}
@@ -55,3 +55,47 @@ func (c *Contractor) remainingFunds(contracts []api.Contract, state *Maintenance
 	}
 	return remaining
 }
+
+// periodSpending returns the cumulative spending of all contracts that were
+// formed or renewed in the period starting at the given height, including the
+// spending of the contracts they were renewed from within that period.
+func (c *Contractor) periodSpending(ctx context.Context, contracts []api.Contract, periodStart uint64) (spent types.Currency, _ error) {
+	for _, contract := range contracts {
+		if contract.StartHeight < periodStart {
+			continue
+		}
+		spending, err := c.contractSpending(ctx, contract, periodStart)
+		if err != nil {
+			return types.ZeroCurrency, err
+		}
+		spent = spent.Add(spending.Total())
+	}
+	return
+}
+
+// spendBudgetExceeded returns the spending in the current period and whether
+// it exceeds the given budget, a zero budget is never exceeded.
+func (c *Contractor) spendBudgetExceeded(ctx context.Context, budget types.Currency, contracts []api.Contract, periodStart uint64) (types.Currency, bool, error) {
+	if budget.IsZero() {
+		return types.ZeroCurrency, false, nil
+	}
+	spent, err := c.periodSpending(ctx, contracts, periodStart)
+	if err != nil {
+		return types.ZeroCurrency, false, err
+	}
+	return spent, spent.Cmp(budget) > 0, nil
+}
+
+// walletReserveBudget caps the given budget to the funds that can be spent
+// without the spendable wallet balance dipping below the reserve and returns
+// whether the reserve is reached, a zero reserve never caps the budget.
+func walletReserveBudget(budget, spendable, reserve types.Currency) (types.Currency, bool) {
+	if reserve.IsZero() {
+		return budget, false
+	} else if spendable.Cmp(reserve) <= 0 {
+		return types.ZeroCurrency, true
+	} else if available := spendable.Sub(reserve); available.Cmp(budget) < 0 {
+		return available, false
+	}
+	return budget, false
+}
@@ -2,6 +2,7 @@ package contractor
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
@@ -84,6 +85,10 @@ const (
 	timeoutBroadcastRevision = time.Minute
 )
 
+// errHostFundingTooLow is returned when the max funding per host doesn't cover
+// a host's contract price and the transaction fee.
+var errHostFundingTooLow = errors.New("max host funding doesn't cover the contract price and transaction fee")
+
 type Bus interface {
 	AddContract(ctx context.Context, c rhpv2.ContractRevision, contractPrice, totalCost types.Currency, startHeight uint64, state string) (api.ContractMetadata, error)
 	AddRenewedContract(ctx context.Context, c rhpv2.ContractRevision, contractPrice, totalCost types.Currency, startHeight uint64, renewedFrom types.FileContractID, state string) (api.ContractMetadata, error)
@@ -95,7 +100,7 @@ type Bus interface {
 	Host(ctx context.Context, hostKey types.PublicKey) (api.Host, error)
 	RecordContractSetChurnMetric(ctx context.Context, metrics ...api.ContractSetChurnMetric) error
 	SearchHosts(ctx context.Context, opts api.SearchHostOptions) ([]api.Host, error)
-	SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) error
+	SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) (api.ContractSetUpdateResponse, error)
 	UpdateHostCheck(ctx context.Context, autopilotID string, hostKey types.PublicKey, hostCheck api.HostCheck) error
 }
 
@@ -113,8 +118,10 @@ type (
 		alerter  alerts.Alerter
 		bus      Bus
 		churn    *accumulatedChurn
+		regions  RegionResolver
 		resolver *ipResolver
 		logger   *zap.SugaredLogger
+		rng      *selectionRNG
 
 		revisionBroadcastInterval time.Duration
 		revisionLastBroadcast     map[types.FileContractID]time.Time
@@ -134,11 +141,12 @@ type (
 	}
 
 	contractInfo struct {
-		contract    api.Contract
-		settings    rhpv2.HostSettings
-		priceTable  rhpv3.HostPriceTable
-		usable      bool
-		recoverable bool
+		contract      api.Contract
+		settings      rhpv2.HostSettings
+		priceTable    rhpv3.HostPriceTable
+		forceIncluded bool
+		usable        bool
+		recoverable   bool
 	}
 
 	contractSetAdditions struct {
@@ -169,14 +177,16 @@ type (
 	}
 )
 
-func New(bus Bus, alerter alerts.Alerter, logger *zap.SugaredLogger, revisionSubmissionBuffer uint64, revisionBroadcastInterval time.Duration) *Contractor {
+func New(bus Bus, alerter alerts.Alerter, logger *zap.SugaredLogger, revisionSubmissionBuffer uint64, revisionBroadcastInterval time.Duration, selectionSeed uint64) *Contractor {
 	logger = logger.Named("contractor")
 	ctx, cancel := context.WithCancel(context.Background())
+	resolver := newIPResolver(ctx, resolverLookupTimeout, logger.Named("resolver"))
 	return &Contractor{
 		bus:     bus,
 		alerter: alerter,
 		churn:   newAccumulatedChurn(),
 		logger:  logger,
+		rng:     newSelectionRNG(selectionSeed),
 
 		revisionBroadcastInterval: revisionBroadcastInterval,
 		revisionLastBroadcast:     make(map[types.FileContractID]time.Time),
@@ -184,7 +194,8 @@ func New(bus Bus, alerter alerts.Alerter, logger *zap.SugaredLogger, revisionSub
 
 		firstRefreshFailure: make(map[types.FileContractID]time.Time),
 
-		resolver: newIPResolver(ctx, resolverLookupTimeout, logger.Named("resolver")),
+		regions:  newSubnetRegionResolver(resolver),
+		resolver: resolver,
 
 		shutdownCtx:       ctx,
 		shutdownCtxCancel: cancel,
@@ -339,6 +350,9 @@ func (c *Contractor) performContractMaintenance(ctx *mCtx, w Worker) (bool, erro
 
 	// update host checks
 	for hk, check := range checks {
+		for _, reason := range check.Gouging.Reasons {
+			c.logger.Debugf("host %v is gouging, %v", hk, reason)
+		}
 		if err := c.bus.UpdateHostCheck(ctx, ctx.ApID(), hk, *check); err != nil {
 			c.logger.Errorf("failed to update host check for host %v, err: %v", hk, err)
 		}
@@ -352,37 +366,37 @@ func (c *Contractor) performContractMaintenance(ctx *mCtx, w Worker) (bool, erro
 		}
 	}
 
-	// calculate remaining funds
-	remaining := c.remainingFunds(contracts, mCtx.state)
-
-	// calculate 'limit' amount of contracts we want to renew
-	var limit int
-	if len(toRenew) > 0 {
-		// when renewing, prioritise contracts that have already been in the set
-		// before and out of those prefer the largest ones.
-		sort.Slice(toRenew, func(i, j int) bool {
-			_, icsI := isInCurrentSet[toRenew[i].contract.ID]
-			_, icsJ := isInCurrentSet[toRenew[j].contract.ID]
-			if icsI && !icsJ {
-				return true
-			} else if !icsI && icsJ {
-				return false
-			}
-			return toRenew[i].contract.FileSize() > toRenew[j].contract.FileSize()
-		})
-		for len(updatedSet)+limit < int(ctx.WantedContracts()) && limit < len(toRenew) {
-			// as long as we're missing contracts, increase the renewal limit
-			limit++
-		}
+	// compute the budgets that gate formations, renewals and refreshes
+	budgets, err := c.maintenanceBudgets(mCtx, contracts)
+	if err != nil {
+		return false, err
+	}
+	renewBudget := budgets.renew
+
+	// spread renewals across the renew window and calculate 'limit' amount of
+	// contracts we want to renew, contracts that aren't due yet are kept in
+	// the set
+	toRenew, deferred, limit := prioritizeRenewals(mCtx, toRenew, len(updatedSet), isInCurrentSet, cs.BlockHeight)
+	for _, ci := range deferred {
+		updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+	}
+	if len(deferred) > 0 {
+		c.logger.Infof("deferred renewing %d contracts", len(deferred))
 	}
 
 	// run renewals on contracts that are not in updatedSet yet. We only renew
 	// up to 'limit' of those to avoid having too many contracts in the updated
 	// set afterwards
 	var renewed []renewal
-	if limit > 0 {
+	if budgets.overBudget || budgets.skipRenewals {
+		for _, ci := range toRenew {
+			if ci.usable {
+				updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+			}
+		}
+	} else if limit > 0 {
 		var toKeep []api.ContractMetadata
-		renewed, toKeep = c.runContractRenewals(ctx, w, toRenew, &remaining, limit)
+		renewed, toKeep = c.runContractRenewals(ctx, w, toRenew, &renewBudget, limit)
 		for _, ri := range renewed {
 			if ri.ci.usable || ri.ci.recoverable {
 				updatedSet = append(updatedSet, ri.to)
@@ -393,30 +407,35 @@ func (c *Contractor) performContractMaintenance(ctx *mCtx, w Worker) (bool, erro
 	}
 
 	// run contract refreshes
-	refreshed, err := c.runContractRefreshes(ctx, w, toRefresh, &remaining)
-	if err != nil {
-		c.logger.Errorf("failed to refresh contracts, err: %v", err) // continue
+	var refreshed []renewal
+	if budgets.overBudget || budgets.skipRenewals {
+		for _, ci := range toRefresh {
+			if ci.usable {
+				updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+			}
+		}
 	} else {
-		for _, ri := range refreshed {
-			if ri.ci.usable || ri.ci.recoverable {
-				updatedSet = append(updatedSet, ri.to)
+		refreshed, err = c.runContractRefreshes(ctx, w, toRefresh, &renewBudget)
+		if err != nil {
+			c.logger.Errorf("failed to refresh contracts, err: %v", err) // continue
+		} else {
+			for _, ri := range refreshed {
+				if ri.ci.usable || ri.ci.recoverable {
+					updatedSet = append(updatedSet, ri.to)
+				}
+				contractData[ri.to.ID] = contractData[ri.from.ID]
 			}
-			contractData[ri.to.ID] = contractData[ri.from.ID]
 		}
 	}
 
-	// to avoid forming new contracts as soon as we dip below
-	// 'Contracts.Amount', we define a threshold but only if we have more
-	// contracts than 'Contracts.Amount' already
-	threshold := ctx.WantedContracts()
-	if uint64(len(contracts)) > ctx.WantedContracts() {
-		threshold = addLeeway(threshold, leewayPctRequiredContracts)
-	}
+	// cap the formation budget to the funds available above the wallet
+	// reserve, taking into account what we spent on renewals and refreshes
+	formationBudget, reserveReached := budgets.formationBudget(mCtx, budgets.renew.Sub(renewBudget))
 
 	// check if we need to form contracts and add them to the contract set
 	var formed []api.ContractMetadata
-	if uint64(len(updatedSet)) < threshold && !ctx.state.SkipContractFormations {
-		formed, err = c.runContractFormations(ctx, w, candidates, usedHosts, unusableHosts, ctx.WantedContracts()-uint64(len(updatedSet)), &remaining)
+	if uint64(len(updatedSet)) < formationThreshold(mCtx, len(contracts)) && !ctx.state.SkipContractFormations && !budgets.overBudget && !reserveReached {
+		formed, err = c.runContractFormations(ctx, w, candidates, usedHostsList(hosts, usedHosts), usedHosts, unusableHosts, ctx.WantedContracts()-uint64(len(updatedSet)), &formationBudget)
 		if err != nil {
 			c.logger.Errorf("failed to form contracts, err: %v", err) // continue
 		} else {
@@ -433,16 +452,7 @@ func (c *Contractor) performContractMaintenance(ctx *mCtx, w Worker) (bool, erro
 			c.logger.Errorf("contract %v not found in contractData", contract.ID)
 		}
 	}
-	if len(updatedSet) > int(ctx.WantedContracts()) {
-		// sort by contract size
-		sort.Slice(updatedSet, func(i, j int) bool {
-			return contractData[updatedSet[i].ID] > contractData[updatedSet[j].ID]
-		})
-		for _, contract := range updatedSet[ctx.WantedContracts():] {
-			toStopUsing[contract.ID] = "truncated"
-		}
-		updatedSet = updatedSet[:ctx.WantedContracts()]
-	}
+	updatedSet = capContractSet(mCtx, updatedSet, contractData, toStopUsing)
 
 	// convert to set of file contract ids
 	var newSet []types.FileContractID
@@ -451,7 +461,7 @@ func (c *Contractor) performContractMaintenance(ctx *mCtx, w Worker) (bool, erro
 	}
 
 	// update contract set
-	err = c.bus.SetContractSet(ctx, ctx.ContractSet(), newSet)
+	_, err = c.bus.SetContractSet(ctx, ctx.ContractSet(), newSet)
 	if err != nil {
 		return false, err
 	}
@@ -460,6 +470,126 @@ func (c *Contractor) performContractMaintenance(ctx *mCtx, w Worker) (bool, erro
 	return c.computeContractSetChanged(mCtx, currentSet, updatedSet, formed, refreshed, renewed, toStopUsing, contractData), nil
 }
 
+// maintenanceBudgets contains the budgets that gate contract formations,
+// renewals and refreshes during contract maintenance.
+type maintenanceBudgets struct {
+	remaining      types.Currency // remaining allowance
+	renew          types.Currency // budget for renewals and refreshes
+	overBudget     bool           // spend budget for the period is exceeded
+	reserveReached bool           // spendable balance reached the reserve
+	skipRenewals   bool           // renewals can't dip into the reserve
+}
+
+// maintenanceBudgets computes the budgets that gate contract formations,
+// renewals and refreshes.
+func (c *Contractor) maintenanceBudgets(ctx *mCtx, contracts []api.Contract) (b maintenanceBudgets, _ error) {
+	// calculate remaining funds
+	b.remaining = c.remainingFunds(contracts, ctx.state)
+
+	// check whether we've exceeded the spend budget for the current period,
+	// if so we don't form, renew or refresh contracts until the period rolls
+	// over
+	spent, overBudget, err := c.spendBudgetExceeded(ctx, ctx.ContractsConfig().SpendBudget, contracts, ctx.state.AP.CurrentPeriod)
+	if err != nil {
+		return maintenanceBudgets{}, fmt.Errorf("failed to compute spending in the current period: %w", err)
+	} else if overBudget {
+		c.logger.Warnf("spent %v in the current period which exceeds the spend budget of %v, skipping contract formations, renewals and refreshes until the period rolls over", spent, ctx.ContractsConfig().SpendBudget)
+	}
+	b.overBudget = overBudget
+
+	// check whether the spendable wallet balance reached the reserve, if so we
+	// don't form contracts and only renew them if that is explicitly allowed
+	reserve := ctx.ContractsConfig().MinWalletReserve
+	_, b.reserveReached = walletReserveBudget(b.remaining, ctx.state.Spendable, reserve)
+	b.skipRenewals = b.reserveReached && !ctx.ContractsConfig().ReserveAllowRenewals
+	if b.reserveReached {
+		c.logger.Warnf("spendable wallet balance of %v reached the minimum reserve of %v, skipping contract formations", ctx.state.Spendable, reserve)
+		if b.skipRenewals {
+			c.logger.Warn("renewals are not allowed to dip into the wallet reserve, skipping contract renewals and refreshes")
+		}
+	}
+
+	// unless explicitly allowed, renewals and refreshes can't dip into the
+	// reserve either
+	b.renew = b.remaining
+	if !ctx.ContractsConfig().ReserveAllowRenewals {
+		b.renew, _ = walletReserveBudget(b.remaining, ctx.state.Spendable, reserve)
+	}
+	return b, nil
+}
+
+// formationBudget returns the budget for contract formations, capped to the
+// funds available above the wallet reserve after spending 'renewSpent' on
+// renewals and refreshes, and whether the reserve was reached.
+func (b maintenanceBudgets) formationBudget(ctx *mCtx, renewSpent types.Currency) (types.Currency, bool) {
+	remaining := b.remaining.Sub(renewSpent)
+	spendable := ctx.state.Spendable
+	if spendable.Cmp(renewSpent) > 0 {
+		spendable = spendable.Sub(renewSpent)
+	} else {
+		spendable = types.ZeroCurrency
+	}
+	return walletReserveBudget(remaining, spendable, ctx.ContractsConfig().MinWalletReserve)
+}
+
+// prioritizeRenewals spreads the renewals across the renew window to avoid
+// renewing all contracts at once and returns the contracts that are due, the
+// ones that are deferred and the number of due contracts we want to renew
+// given the number of contracts that remain in the set. Due contracts are
+// sorted by priority, contracts that have already been in the set come first
+// and out of those the ones closest to expiring and then the largest ones.
+func prioritizeRenewals(ctx *mCtx, toRenew []contractInfo, inSet int, isInCurrentSet map[types.FileContractID]struct{}, bh uint64) (due, deferred []contractInfo, limit int) {
+	due, deferred = spreadRenewals(toRenew, bh, ctx.RenewWindow())
+	inSet += len(deferred)
+
+	sort.Slice(due, func(i, j int) bool {
+		_, icsI := isInCurrentSet[due[i].contract.ID]
+		_, icsJ := isInCurrentSet[due[j].contract.ID]
+		if icsI && !icsJ {
+			return true
+		} else if !icsI && icsJ {
+			return false
+		} else if ehI, ehJ := due[i].contract.EndHeight(), due[j].contract.EndHeight(); ehI != ehJ {
+			return ehI < ehJ
+		}
+		return due[i].contract.FileSize() > due[j].contract.FileSize()
+	})
+	for inSet+limit < int(ctx.WantedContracts()) && limit < len(due) {
+		// as long as we're missing contracts, increase the renewal limit
+		limit++
+	}
+	return
+}
+
+// formationThreshold returns the size of the contract set below which we form
+// new contracts. To avoid forming new contracts as soon as we dip below
+// 'Contracts.Amount', the threshold has some leeway but only if we have more
+// contracts than 'Contracts.Amount' already.
+func formationThreshold(ctx *mCtx, numContracts int) uint64 {
+	threshold := ctx.WantedContracts()
+	if uint64(numContracts) > ctx.WantedContracts() {
+		threshold = addLeeway(threshold, leewayPctRequiredContracts)
+	}
+	return threshold
+}
+
+// capContractSet caps the set at the wanted amount of contracts, the contracts
+// that store the least data are truncated.
+func capContractSet(ctx *mCtx, set []api.ContractMetadata, contractData map[types.FileContractID]uint64, toStopUsing map[types.FileContractID]string) []api.ContractMetadata {
+	if len(set) <= int(ctx.WantedContracts()) {
+		return set
+	}
+
+	// sort by contract size
+	sort.Slice(set, func(i, j int) bool {
+		return contractData[set[i].ID] > contractData[set[j].ID]
+	})
+	for _, contract := range set[ctx.WantedContracts():] {
+		toStopUsing[contract.ID] = "truncated"
+	}
+	return set[:ctx.WantedContracts()]
+}
+
 func (c *Contractor) computeContractSetChanged(ctx *mCtx, oldSet, newSet []api.ContractMetadata, formed []api.ContractMetadata, refreshed, renewed []renewal, toStopUsing map[types.FileContractID]string, contractData map[types.FileContractID]uint64) bool {
 	name := ctx.ContractSet()
 
@@ -699,7 +829,7 @@ LOOP:
 		if contract.Revision == nil {
 			if _, found := inCurrentSet[fcid]; !found || remainingKeepLeeway == 0 {
 				toStopUsing[fcid] = errContractNoRevision.Error()
-			} else if !ctx.AllowRedundantIPs() && ipFilter.IsRedundantIP(contract.HostIP, contract.HostKey) {
+			} else if !ctx.AllowRedundantIPs() && !host.ForceIncluded() && ipFilter.IsRedundantIP(contract.HostIP, contract.HostKey) {
 				toStopUsing[fcid] = fmt.Sprintf("%v; %v", api.ErrUsabilityHostRedundantIP, errContractNoRevision)
 				hostChecks[contract.HostKey].Usability.RedundantIP = true
 			} else {
@@ -710,7 +840,7 @@ LOOP:
 		}
 
 		// decide whether the contract is still good
-		ci := contractInfo{contract: contract, priceTable: host.PriceTable.HostPriceTable, settings: host.Settings}
+		ci := contractInfo{contract: contract, priceTable: host.PriceTable.HostPriceTable, settings: host.Settings, forceIncluded: host.ForceIncluded()}
 		usable, recoverable, refresh, renew, reasons := c.isUsableContract(ctx.AutopilotConfig(), ctx.state.RS, ci, bh, ipFilter)
 		ci.usable = usable
 		ci.recoverable = recoverable
@@ -760,7 +890,7 @@ func (c *Contractor) runHostChecks(ctx *mCtx, hosts []api.Host, minScore float64
 	return checks, nil
 }
 
-func (c *Contractor) runContractFormations(ctx *mCtx, w Worker, candidates scoredHosts, usedHosts map[types.PublicKey]struct{}, unusableHosts unusableHostsBreakdown, missing uint64, budget *types.Currency) (formed []api.ContractMetadata, _ error) {
+func (c *Contractor) runContractFormations(ctx *mCtx, w Worker, candidates scoredHosts, used []api.Host, usedHosts map[types.PublicKey]struct{}, unusableHosts unusableHostsBreakdown, missing uint64, budget *types.Currency) (formed []api.ContractMetadata, _ error) {
 	select {
 	case <-c.shutdownCtx.Done():
 		return nil, nil
@@ -787,7 +917,7 @@ func (c *Contractor) runContractFormations(ctx *mCtx, w Worker, candidates score
 
 	// select candidates
 	wanted := int(addLeeway(missing, leewayPctCandidateHosts))
-	selected := candidates.randSelectByScore(wanted)
+	selected := c.selectCandidates(ctx, ctx.AutopilotConfig().Hosts.DiversityWeight, candidates, used, wanted)
 
 	// print warning if we couldn't find enough hosts were found
 	c.logger.Infof("looking for %d candidate hosts", wanted)
@@ -861,8 +991,9 @@ LOOP:
 			continue
 		}
 
-		// check if we already have a contract with a host on that subnet
-		if shouldFilter && ipFilter.IsRedundantIP(host.NetAddress, host.PublicKey) {
+		// check if we already have a contract with a host on that subnet,
+		// force included hosts are exempt
+		if shouldFilter && !host.ForceIncluded() && ipFilter.IsRedundantIP(host.NetAddress, host.PublicKey) {
 			continue
 		}
 
@@ -1018,6 +1149,31 @@ func (c *Contractor) runContractRenewals(ctx *mCtx, w Worker, toRenew []contract
 	return renewals, toKeep
 }
 
+// spreadRenewals splits the contracts that are up for renewal into the ones
+// that are due for renewal at the given height and the ones that can be
+// deferred. Every contract is assigned a pseudo-random height within the first
+// half of the renew window based on its id, from that height onwards it's due
+// for renewal. Contracts in the second half of the window, as well as unusable
+// ones, are always due.
+func spreadRenewals(toRenew []contractInfo, bh, renewWindow uint64) (due, deferred []contractInfo) {
+	for _, ci := range toRenew {
+		endHeight := ci.contract.EndHeight()
+		if !ci.usable || renewWindow < 2 || endHeight < renewWindow {
+			due = append(due, ci)
+			continue
+		}
+
+		windowStart := endHeight - renewWindow
+		offset := binary.LittleEndian.Uint64(ci.contract.ID[:8]) % (renewWindow / 2)
+		if bh >= windowStart+offset {
+			due = append(due, ci)
+		} else {
+			deferred = append(deferred, ci)
+		}
+	}
+	return
+}
+
 func (c *Contractor) runContractRefreshes(ctx *mCtx, w Worker, toRefresh []contractInfo, budget *types.Currency) (refreshed []renewal, _ error) {
 	c.logger.Infow(
 		"run contracts refreshes",
@@ -1070,6 +1226,30 @@ func (c *Contractor) initialContractFunding(settings rhpv2.HostSettings, txnFee,
 	return funding
 }
 
+// refreshFunds returns the funds to refresh the given contract with, contracts
+// that ran out of funds are refreshed with more funds, others keep theirs.
+func (c *Contractor) refreshFunds(ctx *mCtx, ci contractInfo) (types.Currency, error) {
+	if !isOutOfFunds(ctx.AutopilotConfig(), ci.priceTable, ci.contract) {
+		return ci.contract.Revision.ValidRenterPayout(), nil // don't increase funds
+	}
+	renterFunds := c.refreshFundingEstimate(ctx.AutopilotConfig(), ci, ctx.state.Fee)
+	if err := checkHostFunding(ci.settings, ctx.state.Fee.Mul64(estimatedFileContractTransactionSetSize), renterFunds); err != nil {
+		return types.ZeroCurrency, err
+	}
+	return renterFunds, nil
+}
+
+// renewFunds returns the funds to renew the given contract with.
+func (c *Contractor) renewFunds(ctx *mCtx, ci contractInfo, renewing bool) (types.Currency, error) {
+	renterFunds, err := c.renewFundingEstimate(ctx, ci, ctx.state.Fee, renewing)
+	if err != nil {
+		return types.ZeroCurrency, fmt.Errorf("could not get renew funding estimate: %w", err)
+	} else if err := checkHostFunding(ci.settings, ctx.state.Fee.Mul64(estimatedFileContractTransactionSetSize), renterFunds); err != nil {
+		return types.ZeroCurrency, err
+	}
+	return renterFunds, nil
+}
+
 func (c *Contractor) refreshFundingEstimate(cfg api.AutopilotConfig, ci contractInfo, fee types.Currency) types.Currency {
 	// refresh with 1.2x the funds
 	refreshAmount := ci.contract.TotalCost.Mul64(6).Div64(5)
@@ -1085,6 +1265,7 @@ func (c *Contractor) refreshFundingEstimate(cfg api.AutopilotConfig, ci contract
 	if refreshAmountCapped.Cmp(minimum) < 0 {
 		refreshAmountCapped = minimum
 	}
+	refreshAmountCapped = capHostFunding(cfg.Contracts, refreshAmountCapped)
 	c.logger.Infow("refresh estimate",
 		"fcid", ci.contract.ID,
 		"refreshAmount", refreshAmount,
@@ -1115,7 +1296,7 @@ func (c *Contractor) renewFundingEstimate(ctx *mCtx, ci contractInfo, fee types.
 	prevUploadDataEstimate := types.NewCurrency64(dataStored) // default to assuming all data was uploaded
 	sectorUploadCost := sectorUploadCost(ci.priceTable, ctx.Period())
 	if !sectorUploadCost.IsZero() {
-		prevUploadDataEstimate = prevSpending.Uploads.Div(sectorUploadCost).Mul64(rhpv2.SectorSize)
+		prevUploadDataEstimate = prevSpending.Uploads.Add(prevSpending.Storage).Div(sectorUploadCost).Mul64(rhpv2.SectorSize)
 	}
 	if prevUploadDataEstimate.Cmp(types.NewCurrency64(dataStored)) > 0 {
 		prevUploadDataEstimate = types.NewCurrency64(dataStored)
@@ -1125,7 +1306,7 @@ func (c *Contractor) renewFundingEstimate(ctx *mCtx, ci contractInfo, fee types.
 	// - upload cost: previous uploads + prev storage
 	// - download cost: assumed to be the same
 	// - fund acount cost: assumed to be the same
-	newUploadsCost := prevSpending.Uploads.Add(sectorUploadCost.Mul(prevUploadDataEstimate.Div64(rhpv2.SectorSize)))
+	newUploadsCost := prevSpending.Uploads.Add(prevSpending.Storage).Add(sectorUploadCost.Mul(prevUploadDataEstimate.Div64(rhpv2.SectorSize)))
 	newDownloadsCost := prevSpending.Downloads
 	newFundAccountCost := prevSpending.FundAccount
 
@@ -1157,6 +1338,7 @@ func (c *Contractor) renewFundingEstimate(ctx *mCtx, ci contractInfo, fee types.
 	if cappedEstimatedCost.Cmp(minimum) < 0 {
 		cappedEstimatedCost = minimum
 	}
+	cappedEstimatedCost = capHostFunding(ctx.ContractsConfig(), cappedEstimatedCost)
 
 	if renewing {
 		c.logger.Infow("renew estimate",
@@ -1194,7 +1376,7 @@ func (c *Contractor) calculateMinScore(candidates []scoredHost, numContracts uin
 	var lowestScores []float64
 	for r := 0; r < 5; r++ {
 		lowestScore := math.MaxFloat64
-		for _, host := range scoredHosts(candidates).randSelectByScore(randSetSize) {
+		for _, host := range scoredHosts(candidates).randSelectByScore(c.rng, randSetSize) {
 			if host.score < lowestScore {
 				lowestScore = host.score
 			}
@@ -1283,7 +1465,7 @@ func (c *Contractor) candidateHosts(ctx *mCtx, hosts []api.Host, usedHosts map[t
 		h.PriceTable.HostBlockHeight = cs.BlockHeight
 		hc := checkHost(ctx.AutopilotConfig(), ctx.state.RS, gc, h, minScore)
 		if hc.Usability.IsUsable() {
-			candidates = append(candidates, scoredHost{h, hc.Score.Score()})
+			candidates = append(candidates, scoredHost{h, hc.Score.Score() * h.ScoreMultiplier()})
 			continue
 		}
 
@@ -1322,9 +1504,9 @@ func (c *Contractor) renewContract(ctx *mCtx, w Worker, ci contractInfo, budget
 	}
 
 	// calculate the renter funds
-	renterFunds, err := c.renewFundingEstimate(ctx, ci, ctx.state.Fee, true)
+	renterFunds, err := c.renewFunds(ctx, ci, true)
 	if err != nil {
-		c.logger.Errorw(fmt.Sprintf("could not get renew funding estimate, err: %v", err), "hk", hk, "fcid", fcid)
+		c.logger.Errorw(fmt.Sprintf("could not compute renew funds, err: %v", err), "hk", hk, "fcid", fcid)
 		return api.ContractMetadata{}, true, err
 	}
 
@@ -1342,7 +1524,7 @@ func (c *Contractor) renewContract(ctx *mCtx, w Worker, ci contractInfo, budget
 	}
 
 	// calculate the host collateral
-	expectedNewStorage := renterFundsToExpectedStorage(renterFunds, endHeight-cs.BlockHeight, ci.priceTable)
+	expectedNewStorage := capHostStorage(ctx.ContractsConfig(), renterFundsToExpectedStorage(renterFunds, endHeight-cs.BlockHeight, ci.priceTable))
 
 	// renew the contract
 	resp, err := w.RHPRenew(ctx, fcid, endHeight, hk, contract.SiamuxAddr, settings.Address, ctx.state.Address, renterFunds, types.ZeroCurrency, expectedNewStorage, settings.WindowSize)
@@ -1392,7 +1574,6 @@ func (c *Contractor) refreshContract(ctx *mCtx, w Worker, ci contractInfo, budge
 	contract := ci.contract
 	settings := ci.settings
 	fcid := contract.ID
-	rev := contract.Revision
 	hk := contract.HostKey
 
 	// fetch consensus state
@@ -1402,11 +1583,10 @@ func (c *Contractor) refreshContract(ctx *mCtx, w Worker, ci contractInfo, budge
 	}
 
 	// calculate the renter funds
-	var renterFunds types.Currency
-	if isOutOfFunds(ctx.AutopilotConfig(), ci.priceTable, ci.contract) {
-		renterFunds = c.refreshFundingEstimate(ctx.AutopilotConfig(), ci, ctx.state.Fee)
-	} else {
-		renterFunds = rev.ValidRenterPayout() // don't increase funds
+	renterFunds, err := c.refreshFunds(ctx, ci)
+	if err != nil {
+		c.logger.Infow(err.Error(), "hk", hk, "fcid", fcid)
+		return api.ContractMetadata{}, true, err
 	}
 
 	// check our budget
@@ -1415,7 +1595,7 @@ func (c *Contractor) refreshContract(ctx *mCtx, w Worker, ci contractInfo, budge
 		return api.ContractMetadata{}, false, fmt.Errorf("insufficient budget: %s < %s", budget.String(), renterFunds.String())
 	}
 
-	expectedStorage := renterFundsToExpectedStorage(renterFunds, contract.EndHeight()-cs.BlockHeight, ci.priceTable)
+	expectedStorage := capHostStorage(ctx.ContractsConfig(), renterFundsToExpectedStorage(renterFunds, contract.EndHeight()-cs.BlockHeight, ci.priceTable))
 	unallocatedCollateral := contract.RemainingCollateral()
 
 	// a refresh should always result in a contract that has enough collateral
@@ -1482,6 +1662,10 @@ func (c *Contractor) formContract(ctx *mCtx, w Worker, host api.Host, minInitial
 	// check our budget
 	txnFee := ctx.state.Fee.Mul64(estimatedFileContractTransactionSetSize)
 	renterFunds := initialContractFunding(scan.Settings, txnFee, minInitialContractFunds, maxInitialContractFunds)
+	if err := checkHostFunding(scan.Settings, txnFee, renterFunds); err != nil {
+		c.logger.Infow(err.Error(), "hk", hk)
+		return api.ContractMetadata{}, true, err
+	}
 	if budget.Cmp(renterFunds) < 0 {
 		c.logger.Infow("insufficient budget", "budget", budget, "needed", renterFunds)
 		return api.ContractMetadata{}, false, errors.New("insufficient budget")
@@ -1489,7 +1673,7 @@ func (c *Contractor) formContract(ctx *mCtx, w Worker, host api.Host, minInitial
 
 	// calculate the host collateral
 	endHeight := ctx.EndHeight()
-	expectedStorage := renterFundsToExpectedStorage(renterFunds, endHeight-cs.BlockHeight, scan.PriceTable)
+	expectedStorage := capHostStorage(ctx.ContractsConfig(), renterFundsToExpectedStorage(renterFunds, endHeight-cs.BlockHeight, scan.PriceTable))
 	hostCollateral := rhpv2.ContractFormationCollateral(ctx.Period(), expectedStorage, scan.Settings)
 
 	// form contract
@@ -1530,6 +1714,16 @@ func addLeeway(n uint64, pct float64) uint64 {
 	return uint64(math.Ceil(float64(n) * pct))
 }
 
+// usedHostsList returns the hosts we have contracts with.
+func usedHostsList(hosts []api.Host, usedHosts map[types.PublicKey]struct{}) (used []api.Host) {
+	for _, h := range hosts {
+		if _, ok := usedHosts[h.PublicKey]; ok {
+			used = append(used, h)
+		}
+	}
+	return
+}
+
 func initialContractFunding(settings rhpv2.HostSettings, txnFee, minFunding, maxFunding types.Currency) types.Currency {
 	if !maxFunding.IsZero() && minFunding.Cmp(maxFunding) > 0 {
 		panic("given min is larger than max") // developer error
@@ -1547,11 +1741,39 @@ func initialContractFunding(settings rhpv2.HostSettings, txnFee, minFunding, max
 
 func initialContractFundingMinMax(cfg api.AutopilotConfig) (minFunding types.Currency, maxFunding types.Currency) {
 	allowance := cfg.Contracts.Allowance.Div64(cfg.Contracts.Amount)
-	minFunding = allowance.Div64(minInitialContractFundingDivisor)
-	maxFunding = allowance.Div64(maxInitialContractFundingDivisor)
+	minFunding = capHostFunding(cfg.Contracts, allowance.Div64(minInitialContractFundingDivisor))
+	maxFunding = capHostFunding(cfg.Contracts, allowance.Div64(maxInitialContractFundingDivisor))
 	return
 }
 
+// capHostFunding caps the given funds at the max funding per host. The cap is
+// applied after the minimum funding was enforced, so it's never exceeded.
+func capHostFunding(cfg api.ContractsConfig, funds types.Currency) types.Currency {
+	if !cfg.MaxHostFunding.IsZero() && funds.Cmp(cfg.MaxHostFunding) > 0 {
+		return cfg.MaxHostFunding
+	}
+	return funds
+}
+
+// checkHostFunding returns an error if the given funds don't cover the host's
+// contract price and the transaction fee. This happens when the funds were
+// capped at the max funding per host, the host is skipped in that case since a
+// contract with those funds would be useless.
+func checkHostFunding(settings rhpv2.HostSettings, txnFee, funds types.Currency) error {
+	if required := settings.ContractPrice.Add(txnFee); funds.Cmp(required) <= 0 {
+		return fmt.Errorf("%w: %v <= %v", errHostFundingTooLow, funds, required)
+	}
+	return nil
+}
+
+// capHostStorage caps the given storage at the max storage per host.
+func capHostStorage(cfg api.ContractsConfig, storage uint64) uint64 {
+	if cfg.MaxHostStorage > 0 && storage > cfg.MaxHostStorage {
+		return cfg.MaxHostStorage
+	}
+	return storage
+}
+
 func refreshPriceTable(ctx context.Context, w Worker, host *api.Host) error {
 	// return early if the host's pricetable is not expired yet
 	if time.Now().Before(host.PriceTable.Expiry) {
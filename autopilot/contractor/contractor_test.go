@@ -1,12 +1,19 @@
 package contractor
 
 import (
+	"context"
+	"errors"
 	"math"
+	"sync"
 	"testing"
 	"time"
 
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	rhpv3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/alerts"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/test"
 	"go.uber.org/zap"
 	"lukechampine.com/frand"
 )
@@ -66,3 +73,536 @@ func TestShouldForgiveFailedRenewal(t *testing.T) {
 		t.Fatal("expected no failures")
 	}
 }
+
+func TestSpreadRenewals(t *testing.T) {
+	const (
+		bh          = 1000
+		renewWindow = 100
+	)
+
+	// helper to create a contract with an offset within the first half of the
+	// renew window, the offset is derived from the first 8 bytes of the id
+	var n byte
+	newContract := func(offset byte, endHeight uint64, usable bool) contractInfo {
+		n++
+		return contractInfo{
+			contract: api.Contract{ContractMetadata: api.ContractMetadata{
+				ID:          types.FileContractID{offset, 0, 0, 0, 0, 0, 0, 0, n},
+				WindowStart: endHeight,
+			}},
+			usable: usable,
+		}
+	}
+
+	toRenew := []contractInfo{
+		newContract(0, bh+renewWindow, true),     // due at window start
+		newContract(10, bh+renewWindow, true),    // due 10 blocks into the window
+		newContract(10, bh+renewWindow-10, true), // due right now
+		newContract(49, bh+renewWindow-20, true), // due in 29 blocks
+		newContract(49, bh+renewWindow/2, true),  // second half, always due
+		newContract(49, bh+renewWindow, false),   // unusable, always due
+	}
+
+	due, deferred := spreadRenewals(toRenew, bh, renewWindow)
+	if len(due) != 4 || len(deferred) != 2 {
+		t.Fatalf("unexpected split, %d due, %d deferred", len(due), len(deferred))
+	}
+	for i, ci := range []contractInfo{toRenew[0], toRenew[2], toRenew[4], toRenew[5]} {
+		if due[i].contract.ID != ci.contract.ID || due[i].contract.EndHeight() != ci.contract.EndHeight() {
+			t.Fatalf("unexpected due contract at index %d", i)
+		}
+	}
+	for i, ci := range []contractInfo{toRenew[1], toRenew[3]} {
+		if deferred[i].contract.ID != ci.contract.ID || deferred[i].contract.EndHeight() != ci.contract.EndHeight() {
+			t.Fatalf("unexpected deferred contract at index %d", i)
+		}
+	}
+
+	// assert all contracts are due once we reach the second half of the window
+	due, deferred = spreadRenewals(toRenew, bh+renewWindow/2, renewWindow)
+	if len(due) != len(toRenew) || len(deferred) != 0 {
+		t.Fatalf("unexpected split, %d due, %d deferred", len(due), len(deferred))
+	}
+}
+
+func TestSpendBudgetExceeded(t *testing.T) {
+	const periodStart = 100
+
+	newContract := func(startHeight uint64, spending api.ContractSpending) api.Contract {
+		var fcid types.FileContractID
+		frand.Read(fcid[:])
+		return api.Contract{ContractMetadata: api.ContractMetadata{
+			ID:          fcid,
+			StartHeight: startHeight,
+			Spending:    spending,
+		}}
+	}
+
+	b := &mockBus{ancestors: make(map[types.FileContractID][]api.ArchivedContract)}
+	c := &Contractor{bus: b}
+	exceeded := func(budget types.Currency, contracts []api.Contract, periodStart uint64) (types.Currency, bool) {
+		t.Helper()
+		spent, exceeded, err := c.spendBudgetExceeded(context.Background(), budget, contracts, periodStart)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return spent, exceeded
+	}
+
+	budget := types.Siacoins(10)
+	contracts := []api.Contract{
+		newContract(periodStart, api.ContractSpending{Uploads: types.Siacoins(3), Storage: types.Siacoins(2)}),
+		newContract(periodStart+10, api.ContractSpending{Downloads: types.Siacoins(2)}),
+		newContract(periodStart-1, api.ContractSpending{Uploads: types.Siacoins(100)}), // previous period
+	}
+
+	// the second contract was renewed from a contract in the current period
+	// and one in the previous period
+	b.ancestors[contracts[1].ID] = []api.ArchivedContract{
+		{StartHeight: periodStart, Spending: api.ContractSpending{Downloads: types.Siacoins(2)}},
+		{StartHeight: periodStart - 1, Spending: api.ContractSpending{Downloads: types.Siacoins(100)}},
+	}
+
+	// assert spending near the budget doesn't suppress formations
+	if spent, exceeded := exceeded(budget, contracts, periodStart); exceeded {
+		t.Fatal("budget shouldn't be exceeded")
+	} else if !spent.Equals(types.Siacoins(9)) {
+		t.Fatalf("unexpected spending %v", spent)
+	}
+
+	// assert spending exactly at the budget doesn't suppress formations
+	contracts = append(contracts, newContract(periodStart, api.ContractSpending{FundAccount: types.Siacoins(1)}))
+	if _, exceeded := exceeded(budget, contracts, periodStart); exceeded {
+		t.Fatal("budget shouldn't be exceeded")
+	}
+
+	// assert spending over the budget suppresses formations
+	contracts = append(contracts, newContract(periodStart, api.ContractSpending{Deletions: types.NewCurrency64(1)}))
+	if _, exceeded := exceeded(budget, contracts, periodStart); !exceeded {
+		t.Fatal("budget should be exceeded")
+	}
+
+	// assert a zero budget is never exceeded
+	if _, exceeded := exceeded(types.ZeroCurrency, contracts, periodStart); exceeded {
+		t.Fatal("zero budget should never be exceeded")
+	}
+
+	// assert the budget resets once the period rolls over
+	if spent, exceeded := exceeded(budget, contracts, periodStart+100); exceeded || !spent.IsZero() {
+		t.Fatalf("budget should reset after the period rolls over, spent %v", spent)
+	}
+}
+
+func TestWalletReserveBudget(t *testing.T) {
+	budget := types.Siacoins(50)
+	reserve := types.Siacoins(10)
+
+	// assert a near-empty wallet blocks formations
+	if available, reached := walletReserveBudget(budget, types.Siacoins(1), reserve); !reached {
+		t.Fatal("reserve should be reached")
+	} else if !available.IsZero() {
+		t.Fatalf("unexpected budget %v", available)
+	}
+
+	// assert a balance exactly at the reserve blocks formations
+	if _, reached := walletReserveBudget(budget, reserve, reserve); !reached {
+		t.Fatal("reserve should be reached")
+	}
+
+	// assert the budget is capped to the funds above the reserve
+	if available, reached := walletReserveBudget(budget, types.Siacoins(30), reserve); reached {
+		t.Fatal("reserve shouldn't be reached")
+	} else if !available.Equals(types.Siacoins(20)) {
+		t.Fatalf("unexpected budget %v", available)
+	}
+
+	// assert the budget is untouched if the wallet is well funded
+	if available, reached := walletReserveBudget(budget, types.Siacoins(100), reserve); reached {
+		t.Fatal("reserve shouldn't be reached")
+	} else if !available.Equals(budget) {
+		t.Fatalf("unexpected budget %v", available)
+	}
+
+	// assert a zero reserve never caps the budget
+	if available, reached := walletReserveBudget(budget, types.ZeroCurrency, types.ZeroCurrency); reached || !available.Equals(budget) {
+		t.Fatalf("zero reserve shouldn't cap the budget, budget %v", available)
+	}
+}
+
+func TestMaxHostFunding(t *testing.T) {
+	c := &Contractor{
+		logger: zap.NewNop().Sugar(),
+	}
+
+	// prepare a config with a budget of 1000SC across 5 contracts and a cap of
+	// 5SC per host, without the cap contracts are formed with up to 20SC
+	cfg := api.AutopilotConfig{Contracts: api.ContractsConfig{
+		Amount:         5,
+		Allowance:      types.Siacoins(1000),
+		MaxHostFunding: types.Siacoins(5),
+		MaxHostStorage: 1 << 30,
+	}}
+	minFunding, maxFunding := initialContractFundingMinMax(cfg)
+	if maxFunding.Cmp(cfg.Contracts.MaxHostFunding) > 0 || minFunding.Cmp(maxFunding) > 0 {
+		t.Fatalf("unexpected min and max funding %v %v", minFunding, maxFunding)
+	}
+
+	// form contracts with hosts charging increasingly more, none of the
+	// contracts should exceed the cap and the remainder of the budget stays
+	// available for other hosts
+	budget := cfg.Contracts.Allowance
+	txnFee := types.Siacoins(1)
+	for i := uint64(0); i < cfg.Contracts.Amount; i++ {
+		settings := rhpv2.HostSettings{ContractPrice: types.Siacoins(uint32(i * i))}
+		funding := c.initialContractFunding(settings, txnFee, minFunding, maxFunding)
+		if funding.Cmp(cfg.Contracts.MaxHostFunding) > 0 {
+			t.Fatalf("contract %d exceeds the per-host limit, %v > %v", i, funding, cfg.Contracts.MaxHostFunding)
+		}
+		budget = budget.Sub(funding)
+	}
+	if budget.Cmp(types.Siacoins(975)) < 0 {
+		t.Fatalf("unexpected remaining budget %v", budget)
+	}
+
+	// assert refreshes are capped as well
+	ci := contractInfo{contract: api.Contract{ContractMetadata: api.ContractMetadata{TotalCost: types.Siacoins(100)}}}
+	if funding := c.refreshFundingEstimate(cfg, ci, types.NewCurrency64(1)); !funding.Equals(cfg.Contracts.MaxHostFunding) {
+		t.Fatalf("expected refresh to be capped at %v, got %v", cfg.Contracts.MaxHostFunding, funding)
+	}
+
+	// assert a host whose contract price exceeds the cap is skipped rather than
+	// forming a contract with insufficient funds
+	expensive := rhpv2.HostSettings{ContractPrice: types.Siacoins(10)}
+	funding := c.initialContractFunding(expensive, txnFee, minFunding, maxFunding)
+	if !funding.Equals(cfg.Contracts.MaxHostFunding) {
+		t.Fatalf("expected funding to be capped at %v, got %v", cfg.Contracts.MaxHostFunding, funding)
+	} else if err := checkHostFunding(expensive, txnFee, funding); !errors.Is(err, errHostFundingTooLow) {
+		t.Fatal("expected errHostFundingTooLow, got", err)
+	} else if err := checkHostFunding(rhpv2.HostSettings{}, txnFee, funding); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the config is validated
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	invalid := cfg
+	invalid.Contracts.MaxHostFunding = types.Siacoins(1001)
+	if err := invalid.Validate(); err == nil {
+		t.Fatal("expected error for max host funding exceeding the allowance")
+	}
+	invalid = cfg
+	invalid.Contracts.MaxHostStorage = rhpv2.SectorSize - 1
+	if err := invalid.Validate(); err == nil {
+		t.Fatal("expected error for max host storage smaller than a sector")
+	}
+
+	// assert the expected storage is capped
+	if storage := capHostStorage(cfg.Contracts, 1<<40); storage != cfg.Contracts.MaxHostStorage {
+		t.Fatalf("expected storage to be capped at %v, got %v", cfg.Contracts.MaxHostStorage, storage)
+	} else if storage := capHostStorage(api.ContractsConfig{}, 1<<40); storage != 1<<40 {
+		t.Fatal("expected storage not to be capped", storage)
+	}
+}
+
+type mockBus struct {
+	ancestors map[types.FileContractID][]api.ArchivedContract
+	hosts     []api.Host
+
+	mu        sync.Mutex
+	contracts []api.ContractMetadata
+	set       []types.FileContractID
+}
+
+func (b *mockBus) AddContract(ctx context.Context, c rhpv2.ContractRevision, contractPrice, totalCost types.Currency, startHeight uint64, state string) (api.ContractMetadata, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	cm := api.ContractMetadata{ID: c.ID(), HostKey: c.HostKey(), StartHeight: startHeight, State: state, TotalCost: totalCost}
+	b.contracts = append(b.contracts, cm)
+	return cm, nil
+}
+
+func (b *mockBus) AddRenewedContract(ctx context.Context, c rhpv2.ContractRevision, contractPrice, totalCost types.Currency, startHeight uint64, renewedFrom types.FileContractID, state string) (api.ContractMetadata, error) {
+	cm, err := b.AddContract(ctx, c, contractPrice, totalCost, startHeight, state)
+	cm.RenewedFrom = renewedFrom
+	return cm, err
+}
+
+func (b *mockBus) AncestorContracts(ctx context.Context, id types.FileContractID, minStartHeight uint64) (ancestors []api.ArchivedContract, _ error) {
+	for _, ancestor := range b.ancestors[id] {
+		if ancestor.StartHeight >= minStartHeight {
+			ancestors = append(ancestors, ancestor)
+		}
+	}
+	return
+}
+
+func (b *mockBus) ArchiveContracts(ctx context.Context, toArchive map[types.FileContractID]string) error {
+	return nil
+}
+
+func (b *mockBus) ConsensusState(ctx context.Context) (api.ConsensusState, error) {
+	return api.ConsensusState{BlockHeight: 100, Synced: true}, nil
+}
+
+func (b *mockBus) Contracts(ctx context.Context, opts api.ContractsOpts) ([]api.ContractMetadata, error) {
+	return nil, nil
+}
+
+func (b *mockBus) FileContractTax(ctx context.Context, payout types.Currency) (types.Currency, error) {
+	return types.ZeroCurrency, nil
+}
+
+func (b *mockBus) Host(ctx context.Context, hostKey types.PublicKey) (api.Host, error) {
+	for _, h := range b.hosts {
+		if h.PublicKey == hostKey {
+			return h, nil
+		}
+	}
+	return api.Host{}, api.ErrHostNotFound
+}
+
+func (b *mockBus) RecordContractSetChurnMetric(ctx context.Context, metrics ...api.ContractSetChurnMetric) error {
+	return nil
+}
+
+func (b *mockBus) SearchHosts(ctx context.Context, opts api.SearchHostOptions) ([]api.Host, error) {
+	return b.hosts, nil
+}
+
+func (b *mockBus) SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) (api.ContractSetUpdateResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.set = contracts
+	return api.ContractSetUpdateResponse{}, nil
+}
+
+func (b *mockBus) UpdateHostCheck(ctx context.Context, autopilotID string, hostKey types.PublicKey, hostCheck api.HostCheck) error {
+	return nil
+}
+
+type mockWorker struct {
+	contracts []api.Contract
+
+	mu      sync.Mutex
+	formed  []types.PublicKey
+	renewed []types.FileContractID
+}
+
+func (w *mockWorker) Contracts(ctx context.Context, hostTimeout time.Duration) (api.ContractsResponse, error) {
+	return api.ContractsResponse{Contracts: w.contracts}, nil
+}
+
+func (w *mockWorker) RHPBroadcast(ctx context.Context, fcid types.FileContractID) error {
+	return nil
+}
+
+func (w *mockWorker) RHPForm(ctx context.Context, endHeight uint64, hk types.PublicKey, hostIP string, renterAddress types.Address, renterFunds types.Currency, hostCollateral types.Currency) (rhpv2.ContractRevision, []types.Transaction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.formed = append(w.formed, hk)
+	return newTestContractRevision(hk, renterFunds, hostCollateral, endHeight), nil, nil
+}
+
+func (w *mockWorker) RHPPriceTable(ctx context.Context, hostKey types.PublicKey, siamuxAddr string, timeout time.Duration) (api.HostPriceTable, error) {
+	return api.HostPriceTable{HostPriceTable: newTestPriceTable(), Expiry: time.Now().Add(time.Minute)}, nil
+}
+
+func (w *mockWorker) RHPRenew(ctx context.Context, fcid types.FileContractID, endHeight uint64, hk types.PublicKey, hostIP string, hostAddress, renterAddress types.Address, renterFunds, minNewCollateral types.Currency, expectedStorage, windowSize uint64) (api.RHPRenewResponse, error) {
+	w.mu.Lock()
+	w.renewed = append(w.renewed, fcid)
+	w.mu.Unlock()
+	return api.RHPRenewResponse{}, errors.New("not implemented")
+}
+
+func (w *mockWorker) RHPScan(ctx context.Context, hostKey types.PublicKey, hostIP string, timeout time.Duration) (api.RHPScanResponse, error) {
+	return api.RHPScanResponse{Settings: newTestHostSettings(), PriceTable: newTestPriceTable()}, nil
+}
+
+func (w *mockWorker) numFormed() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.formed)
+}
+
+func (w *mockWorker) numRenewed() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.renewed)
+}
+
+// newTestHosts returns hosts that pass the gouging checks of the test gouging
+// settings.
+func newTestHosts(n int) []api.Host {
+	hosts := test.NewHosts(n)
+	for i := range hosts {
+		hosts[i].PriceTable.HostPriceTable = newTestPriceTable()
+		hosts[i].Settings = newTestHostSettings()
+	}
+	return hosts
+}
+
+func newTestHostSettings() rhpv2.HostSettings {
+	settings := test.NewHostSettings()
+	settings.MaxEphemeralAccountBalance = types.Siacoins(1)
+	settings.EphemeralAccountExpiry = time.Hour
+	return settings
+}
+
+func newTestPriceTable() rhpv3.HostPriceTable {
+	pt := test.NewHostPriceTable()
+	pt.HostBlockHeight = 100
+	pt.MaxDuration = test.NewHostSettings().MaxDuration
+	return pt
+}
+
+func newTestContractRevision(hk types.PublicKey, renterFunds, hostCollateral types.Currency, endHeight uint64) rhpv2.ContractRevision {
+	var fcid types.FileContractID
+	frand.Read(fcid[:])
+	return rhpv2.ContractRevision{
+		Revision: types.FileContractRevision{
+			ParentID: fcid,
+			UnlockConditions: types.UnlockConditions{
+				PublicKeys: []types.UnlockKey{{}, hk.UnlockKey()},
+			},
+			FileContract: types.FileContract{
+				WindowStart:        endHeight,
+				WindowEnd:          endHeight + 144,
+				ValidProofOutputs:  []types.SiacoinOutput{{Value: renterFunds}, {Value: hostCollateral}},
+				MissedProofOutputs: []types.SiacoinOutput{{Value: renterFunds}, {Value: hostCollateral}, {}},
+			},
+		},
+	}
+}
+
+// newTestMaintenanceState returns a maintenance state for the given spendable
+// wallet balance using the test autopilot config.
+func newTestMaintenanceState(spendable types.Currency) *MaintenanceState {
+	return &MaintenanceState{
+		GS: test.GougingSettings,
+		RS: test.RedundancySettings,
+		AP: api.Autopilot{
+			ID:            "autopilot",
+			Config:        test.AutopilotConfig,
+			CurrentPeriod: 100,
+		},
+		Fee:       types.NewCurrency64(1),
+		Spendable: spendable,
+	}
+}
+
+// TestContractMaintenanceWalletReserve asserts contract maintenance doesn't
+// form contracts once the spendable wallet balance reached the reserve and
+// only renews contracts if renewals are allowed to dip into the reserve.
+func TestContractMaintenanceWalletReserve(t *testing.T) {
+	b := &mockBus{hosts: newTestHosts(10)}
+	c := New(b, alerts.NewManager(), zap.NewNop().Sugar(), 0, 0, 1)
+	defer c.Close()
+
+	// assert a near-empty wallet doesn't form contracts
+	w := &mockWorker{}
+	state := newTestMaintenanceState(types.Siacoins(1))
+	state.AP.Config.Contracts.MinWalletReserve = types.Siacoins(10)
+	if _, err := c.PerformContractMaintenance(context.Background(), w, state); err != nil {
+		t.Fatal(err)
+	} else if n := w.numFormed(); n != 0 {
+		t.Fatalf("expected no formations, got %v", n)
+	}
+
+	// assert the wallet forms contracts once it's funded
+	w = &mockWorker{}
+	state = newTestMaintenanceState(types.Siacoins(1e6))
+	state.AP.Config.Contracts.MinWalletReserve = types.Siacoins(10)
+	if _, err := c.PerformContractMaintenance(context.Background(), w, state); err != nil {
+		t.Fatal(err)
+	} else if n := w.numFormed(); n != int(state.AP.Config.Contracts.Amount) {
+		t.Fatalf("expected %v formations, got %v", state.AP.Config.Contracts.Amount, n)
+	}
+
+	// prepare a contract that's about to expire
+	rev := newTestContractRevision(b.hosts[0].PublicKey, types.Siacoins(1), types.Siacoins(1), 101)
+	expiring := api.Contract{
+		ContractMetadata: api.ContractMetadata{
+			ID:          rev.ID(),
+			HostKey:     b.hosts[0].PublicKey,
+			StartHeight: 1,
+			WindowStart: rev.Revision.WindowStart,
+			WindowEnd:   rev.Revision.WindowEnd,
+		},
+		Revision: &rev.Revision,
+	}
+
+	// assert the contract isn't renewed if that requires dipping into the
+	// reserve
+	w = &mockWorker{contracts: []api.Contract{expiring}}
+	state = newTestMaintenanceState(types.Siacoins(11))
+	state.AP.Config.Contracts.MinWalletReserve = types.Siacoins(10)
+	if _, err := c.PerformContractMaintenance(context.Background(), w, state); err != nil {
+		t.Fatal(err)
+	} else if n := w.numRenewed(); n != 0 {
+		t.Fatalf("expected no renewals, got %v", n)
+	}
+
+	// assert the contract is renewed if renewals are allowed to dip into the
+	// reserve
+	w = &mockWorker{contracts: []api.Contract{expiring}}
+	state.AP.Config.Contracts.ReserveAllowRenewals = true
+	if _, err := c.PerformContractMaintenance(context.Background(), w, state); err != nil {
+		t.Fatal(err)
+	} else if n := w.numRenewed(); n != 1 {
+		t.Fatalf("expected 1 renewal, got %v", n)
+	}
+}
+
+// TestContractMaintenanceSpendBudget asserts contract maintenance doesn't form
+// or refresh contracts once the spending in the current period, including the
+// spending of renewed contracts, exceeds the spend budget.
+func TestContractMaintenanceSpendBudget(t *testing.T) {
+	b := &mockBus{
+		ancestors: make(map[types.FileContractID][]api.ArchivedContract),
+		hosts:     newTestHosts(10),
+	}
+	c := New(b, alerts.NewManager(), zap.NewNop().Sugar(), 0, 0, 1)
+	defer c.Close()
+
+	// prepare a contract that ran out of funds and needs to be refreshed, it
+	// was renewed from a contract in the current period
+	state := newTestMaintenanceState(types.Siacoins(1e6))
+	state.AP.Config.Contracts.SpendBudget = types.Siacoins(10)
+	rev := newTestContractRevision(b.hosts[0].PublicKey, types.Siacoins(1), types.Siacoins(1), state.AP.EndHeight())
+	refresh := api.Contract{
+		ContractMetadata: api.ContractMetadata{
+			ID:          rev.ID(),
+			HostKey:     b.hosts[0].PublicKey,
+			StartHeight: state.AP.CurrentPeriod,
+			WindowStart: rev.Revision.WindowStart,
+			WindowEnd:   rev.Revision.WindowEnd,
+			Spending:    api.ContractSpending{Uploads: types.Siacoins(5)},
+		},
+		Revision: &rev.Revision,
+	}
+
+	// assert spending below the budget doesn't suppress refreshes and
+	// formations
+	b.ancestors[refresh.ID] = []api.ArchivedContract{{StartHeight: state.AP.CurrentPeriod, Spending: api.ContractSpending{Uploads: types.Siacoins(4)}}}
+	w := &mockWorker{contracts: []api.Contract{refresh}}
+	if _, err := c.PerformContractMaintenance(context.Background(), w, state); err != nil {
+		t.Fatal(err)
+	} else if n := w.numRenewed(); n != 1 {
+		t.Fatalf("expected 1 refresh, got %v", n)
+	} else if n := w.numFormed(); n == 0 {
+		t.Fatal("expected formations")
+	}
+
+	// assert spending over the budget suppresses refreshes and formations,
+	// the spending of the ancestor tips it over the budget
+	b.ancestors[refresh.ID] = []api.ArchivedContract{{StartHeight: state.AP.CurrentPeriod, Spending: api.ContractSpending{Uploads: types.Siacoins(6)}}}
+	w = &mockWorker{contracts: []api.Contract{refresh}}
+	if _, err := c.PerformContractMaintenance(context.Background(), w, state); err != nil {
+		t.Fatal(err)
+	} else if n := w.numRenewed(); n != 0 {
+		t.Fatalf("expected no refreshes, got %v", n)
+	} else if n := w.numFormed(); n != 0 {
+		t.Fatalf("expected no formations, got %v", n)
+	}
+}
@@ -0,0 +1,172 @@
+package contractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+const (
+	// number of bits that make up the region of a host's IP when using the
+	// default subnet based region resolver
+	ipv4RegionRange = 16
+	ipv6RegionRange = 24
+)
+
+var errNoAddresses = errors.New("host address did not resolve to any IP addresses")
+
+type (
+	// RegionResolver resolves a host's net address to the region it is
+	// located in, e.g. a country, an ASN or a subnet. The contractor uses it to
+	// avoid concentrating its contracts in a single region.
+	RegionResolver interface {
+		Region(ctx context.Context, netAddress string) (string, error)
+	}
+
+	// subnetRegionResolver is the default region resolver, it considers all
+	// hosts in the same (broad) subnet to be in the same region. It uses the
+	// contractor's IP resolver so lookups share its cache.
+	subnetRegionResolver struct {
+		resolver *ipResolver
+	}
+
+	// regionCounter keeps track of the number of hosts we have contracts with
+	// per region.
+	regionCounter struct {
+		rr     RegionResolver
+		logger *zap.SugaredLogger
+
+		counts  map[string]int
+		regions map[types.PublicKey]string
+	}
+)
+
+// SetRegionResolver overrides the resolver used to figure out a host's region
+// when applying the diversity penalty.
+func (c *Contractor) SetRegionResolver(r RegionResolver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.regions = r
+}
+
+func (c *Contractor) regionResolver() RegionResolver {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.regions
+}
+
+// selectCandidates selects n candidates at random, weighted by their score. If
+// a diversity weight is configured, candidates located in a region we already
+// have contracts in are penalized and the region counts are updated every time
+// a candidate is selected, that way a single round doesn't concentrate the
+// contracts in a region we weren't present in before.
+func (c *Contractor) selectCandidates(ctx context.Context, weight float64, candidates scoredHosts, used []api.Host, n int) []scoredHost {
+	if weight <= 0 {
+		return candidates.randSelectByScore(c.rng, n)
+	}
+
+	// count the hosts we have contracts with per region
+	rc := newRegionCounter(c.regionResolver(), c.logger)
+	for _, h := range used {
+		rc.track(ctx, h)
+	}
+
+	// select the candidates one by one
+	remaining := append(scoredHosts(nil), candidates...)
+	var selected []scoredHost
+	for len(selected) < n && len(remaining) > 0 {
+		s := scoredHosts(rc.penalize(ctx, weight, remaining)).randSelectByScore(c.rng, 1)
+		if len(s) == 0 {
+			break
+		}
+		for i, h := range remaining {
+			if h.host.PublicKey == s[0].host.PublicKey {
+				selected = append(selected, h)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+		rc.track(ctx, s[0].host)
+	}
+	return selected
+}
+
+func newSubnetRegionResolver(r *ipResolver) *subnetRegionResolver {
+	return &subnetRegionResolver{resolver: r}
+}
+
+func (r *subnetRegionResolver) Region(ctx context.Context, netAddress string) (string, error) {
+	subnets, err := r.resolver.lookup(netAddress)
+	if err != nil {
+		return "", err
+	} else if len(subnets) == 0 {
+		return "", errNoAddresses
+	}
+
+	// use the broader subnet of the first address as region
+	_, ipnet, err := net.ParseCIDR(subnets[0])
+	if err != nil {
+		return "", err
+	}
+	ipRange := ipv6RegionRange
+	if ipnet.IP.To4() != nil {
+		ipRange = ipv4RegionRange
+	}
+	_, ipnet, err = net.ParseCIDR(fmt.Sprintf("%s/%d", ipnet.IP.String(), ipRange))
+	if err != nil {
+		return "", err
+	}
+	return ipnet.String(), nil
+}
+
+func newRegionCounter(rr RegionResolver, logger *zap.SugaredLogger) *regionCounter {
+	return &regionCounter{
+		rr:     rr,
+		logger: logger,
+
+		counts:  make(map[string]int),
+		regions: make(map[types.PublicKey]string),
+	}
+}
+
+// region returns the region of the given host, hosts we fail to resolve are
+// not part of any region.
+func (rc *regionCounter) region(ctx context.Context, h api.Host) (string, bool) {
+	if r, ok := rc.regions[h.PublicKey]; ok {
+		return r, r != ""
+	}
+	r, err := rc.rr.Region(ctx, h.NetAddress)
+	if err != nil {
+		rc.logger.Debugw("failed to resolve region", "hk", h.PublicKey, "address", h.NetAddress, "err", err)
+	}
+	rc.regions[h.PublicKey] = r
+	return r, r != ""
+}
+
+// track adds the given host to the count of its region.
+func (rc *regionCounter) track(ctx context.Context, h api.Host) {
+	if r, ok := rc.region(ctx, h); ok {
+		rc.counts[r]++
+	}
+}
+
+// penalize returns a copy of the candidates where the score of every candidate
+// that is located in a region we already have contracts in is lowered. Every
+// host in the same region reduces the candidate's score by the given weight,
+// force included hosts are exempt.
+func (rc *regionCounter) penalize(ctx context.Context, weight float64, candidates []scoredHost) []scoredHost {
+	penalized := append([]scoredHost(nil), candidates...)
+	for i, c := range penalized {
+		if c.host.ForceIncluded() {
+			continue
+		} else if r, ok := rc.region(ctx, c.host); ok && rc.counts[r] > 0 {
+			penalized[i].score /= 1 + weight*float64(rc.counts[r])
+		}
+	}
+	return penalized
+}
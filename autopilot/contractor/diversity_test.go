@@ -0,0 +1,104 @@
+package contractor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/test"
+	"go.uber.org/zap"
+)
+
+func TestDiversityPenalty(t *testing.T) {
+	r := newTestResolver()
+	r.setAddr("host1.com", []net.IPAddr{{IP: net.IP{1, 2, 3, 4}}})
+	r.setAddr("host2.com", []net.IPAddr{{IP: net.IP{1, 2, 200, 4}}})
+	r.setAddr("host3.com", []net.IPAddr{{IP: net.IP{5, 6, 7, 8}}})
+	rr := newSubnetRegionResolver(newTestIPResolver(r))
+
+	// assert the default resolver groups hosts by subnet
+	if region, err := rr.Region(context.Background(), "host1.com:1234"); err != nil {
+		t.Fatal(err)
+	} else if region != "1.2.0.0/16" {
+		t.Fatal("unexpected region", region)
+	} else if _, err := rr.Region(context.Background(), "unknown.com:1234"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// prepare hosts, we have a contract with host 1
+	newHost := func(addr string) api.Host {
+		h := test.NewHost(test.RandomHostKey(), test.NewHostPriceTable(), test.NewHostSettings())
+		h.NetAddress = addr
+		return h
+	}
+	h1 := newHost("host1.com:1234")
+	h2 := newHost("host2.com:1234")
+	h3 := newHost("host3.com:1234")
+	candidates := []scoredHost{{h2, 1}, {h3, 1}}
+
+	// assert the second host in the region is penalized
+	rc := newRegionCounter(rr, zap.NewNop().Sugar())
+	rc.track(context.Background(), h1)
+	scored := rc.penalize(context.Background(), 1, candidates)
+	if scored[0].score != 0.5 {
+		t.Fatal("expected host in same region to be penalized", scored[0].score)
+	} else if scored[1].score != 1 {
+		t.Fatal("expected host in other region not to be penalized", scored[1].score)
+	} else if candidates[0].score != 1 {
+		t.Fatal("expected candidates to be left untouched")
+	}
+
+	// assert the penalty grows with the number of hosts in the region
+	rc.track(context.Background(), newHost("host1.com:5678"))
+	scored = rc.penalize(context.Background(), 1, candidates)
+	if scored[0].score != 1.0/3 {
+		t.Fatal("unexpected score", scored[0].score)
+	} else if scored[1].score != 1 {
+		t.Fatal("unexpected score", scored[1].score)
+	}
+}
+
+func TestSelectCandidatesDiversity(t *testing.T) {
+	r := newTestResolver()
+	r.setAddr("host1.com", []net.IPAddr{{IP: net.IP{1, 2, 3, 4}}})
+	r.setAddr("host2.com", []net.IPAddr{{IP: net.IP{1, 2, 200, 4}}})
+	r.setAddr("host3.com", []net.IPAddr{{IP: net.IP{5, 6, 7, 8}}})
+
+	c := New(&mockBus{}, nil, zap.NewNop().Sugar(), 0, 0, 1)
+	defer c.Close()
+	c.SetRegionResolver(newSubnetRegionResolver(newTestIPResolver(r)))
+
+	// prepare two candidates in the same region that score a lot better than
+	// the candidate in the other region, we don't have any contracts yet
+	newHost := func(addr string) api.Host {
+		h := test.NewHost(test.RandomHostKey(), test.NewHostPriceTable(), test.NewHostSettings())
+		h.NetAddress = addr
+		return h
+	}
+	h1 := newHost("host1.com:1234")
+	h2 := newHost("host2.com:1234")
+	h3 := newHost("host3.com:1234")
+	candidates := scoredHosts{{h1, 10}, {h2, 10}, {h3, 1}}
+
+	// assert selecting two candidates picks one host per region because the
+	// region count is updated as soon as the first host is selected
+	for i := 0; i < 10; i++ {
+		selected := c.selectCandidates(context.Background(), 1000, candidates, nil, 2)
+		if len(selected) != 2 {
+			t.Fatalf("expected 2 hosts, got %v", len(selected))
+		}
+		var other bool
+		for _, h := range selected {
+			other = other || h.host.PublicKey == h3.PublicKey
+		}
+		if !other {
+			t.Fatal("expected host in the other region to be selected")
+		}
+	}
+
+	// assert the selection only depends on the score without a weight
+	if selected := c.selectCandidates(context.Background(), 0, candidates, nil, 3); len(selected) != 3 {
+		t.Fatalf("expected 3 hosts, got %v", len(selected))
+	}
+}
@@ -0,0 +1,98 @@
+package contractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+// ErrInvalidFormationEstimate is returned when a formation estimate is
+// requested with invalid parameters.
+var ErrInvalidFormationEstimate = errors.New("invalid formation estimate request")
+
+// EstimateContractFormation estimates the upfront cost of forming contracts
+// with candidate hosts. It selects hosts the same way contract maintenance
+// does, at random weighted by their score and penalized by region, so the
+// estimate can vary between requests. No contracts are formed.
+func (c *Contractor) EstimateContractFormation(ctx context.Context, state *MaintenanceState, req api.ContractFormationEstimateRequest) (api.ContractFormationEstimateResponse, error) {
+	mCtx := newMaintenanceCtx(ctx, state)
+
+	// apply defaults
+	if req.Hosts == 0 {
+		req.Hosts = mCtx.WantedContracts()
+	}
+	if req.Redundancy == 0 {
+		req.Redundancy = state.RS.Redundancy()
+	}
+	if req.Period == 0 {
+		req.Period = mCtx.Period()
+	}
+
+	// validate the request
+	if req.Hosts == 0 {
+		return api.ContractFormationEstimateResponse{}, fmt.Errorf("%w: hosts must be greater than 0", ErrInvalidFormationEstimate)
+	} else if req.Redundancy < 1 {
+		return api.ContractFormationEstimateResponse{}, fmt.Errorf("%w: redundancy must be at least 1", ErrInvalidFormationEstimate)
+	}
+
+	// fetch all hosts
+	hosts, err := c.bus.SearchHosts(ctx, api.SearchHostOptions{Limit: -1, FilterMode: api.HostFilterModeAllowed})
+	if err != nil {
+		return api.ContractFormationEstimateResponse{}, err
+	}
+
+	// fetch candidate hosts
+	candidates, _, err := c.candidateHosts(mCtx, hosts, nil, minValidScore)
+	if err != nil {
+		return api.ContractFormationEstimateResponse{}, err
+	}
+
+	// select the hosts
+	selected := c.selectCandidates(mCtx, mCtx.AutopilotConfig().Hosts.DiversityWeight, candidates, nil, int(req.Hosts))
+	if uint64(len(selected)) < req.Hosts {
+		return api.ContractFormationEstimateResponse{}, fmt.Errorf("%w: only %d usable hosts, %d requested", ErrInvalidFormationEstimate, len(selected), req.Hosts)
+	}
+
+	// estimate the cost
+	txnFee := state.Fee.Mul64(estimatedFileContractTransactionSetSize)
+	return estimateContractFormation(selected, req, txnFee, func(payout types.Currency) (types.Currency, error) {
+		return c.bus.FileContractTax(ctx, payout)
+	})
+}
+
+// estimateContractFormation estimates the cost of forming a contract with each
+// of the selected hosts, the data is spread evenly across them.
+func estimateContractFormation(selected []scoredHost, req api.ContractFormationEstimateRequest, txnFee types.Currency, tax func(types.Currency) (types.Currency, error)) (resp api.ContractFormationEstimateResponse, _ error) {
+	// spread the redundant data across the hosts
+	perHost := uint64(math.Ceil(float64(req.Storage) * req.Redundancy / float64(req.Hosts)))
+
+	resp.Hosts = []api.HostFormationEstimate{}
+	for _, candidate := range selected {
+		h := candidate.host
+		funding := sectorUploadCost(h.PriceTable.HostPriceTable, req.Period).Mul64(bytesToSectors(perHost))
+
+		// NOTE: the transaction fee isn't taxed since it doesn't go into the
+		// file contract
+		siafundFee, err := tax(funding.Add(h.Settings.ContractPrice))
+		if err != nil {
+			return api.ContractFormationEstimateResponse{}, err
+		}
+		estimate := api.HostFormationEstimate{
+			HostKey:    h.PublicKey,
+			Storage:    perHost,
+			Collateral: rhpv2.ContractFormationCollateral(req.Period, perHost, h.Settings),
+			Fees:       h.Settings.ContractPrice.Add(siafundFee).Add(txnFee),
+			Funding:    funding,
+		}
+		resp.Hosts = append(resp.Hosts, estimate)
+		resp.Collateral = resp.Collateral.Add(estimate.Collateral)
+		resp.Fees = resp.Fees.Add(estimate.Fees)
+		resp.Funding = resp.Funding.Add(estimate.Funding)
+	}
+	return resp, nil
+}
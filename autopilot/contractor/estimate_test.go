@@ -0,0 +1,86 @@
+package contractor
+
+import (
+	"testing"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	rhpv3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+func TestEstimateContractFormation(t *testing.T) {
+	const period = 100
+
+	// newCandidate creates a candidate that charges 'storePrice' per byte per
+	// block and puts up collateral of 1H per byte per block, up to
+	// 'maxCollateral'
+	newCandidate := func(id byte, storePrice uint64, maxCollateral types.Currency) scoredHost {
+		return scoredHost{
+			host: api.Host{
+				PublicKey: types.PublicKey{id},
+				Settings: rhpv2.HostSettings{
+					ContractPrice: types.Siacoins(1),
+					Collateral:    types.NewCurrency64(1),
+					MaxCollateral: maxCollateral,
+				},
+				PriceTable: api.HostPriceTable{HostPriceTable: rhpv3.HostPriceTable{
+					WriteStoreCost: types.NewCurrency64(storePrice),
+				}},
+			},
+			score: 1,
+		}
+	}
+
+	// prepare 3 selected hosts, the collateral of the last one is capped
+	selected := []scoredHost{
+		newCandidate(1, 1, types.Siacoins(1)),
+		newCandidate(3, 2, types.Siacoins(1)),
+		newCandidate(4, 3, types.NewCurrency64(1)),
+	}
+
+	// estimate storing 10 sectors with 3x redundancy on 3 hosts, every host
+	// stores 10 sectors, the siafund tax is 10% and the txn fee is 1SC
+	req := api.ContractFormationEstimateRequest{
+		Hosts:      3,
+		Redundancy: 3,
+		Storage:    10 * rhpv2.SectorSize,
+		Period:     period,
+	}
+	txnFee := types.Siacoins(1)
+	resp, err := estimateContractFormation(selected, req, txnFee, func(payout types.Currency) (types.Currency, error) {
+		return payout.Div64(10), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the selected hosts
+	if len(resp.Hosts) != 3 {
+		t.Fatalf("expected 3 hosts, got %v", len(resp.Hosts))
+	}
+	for i, hk := range []types.PublicKey{{1}, {3}, {4}} {
+		if resp.Hosts[i].HostKey != hk {
+			t.Fatalf("unexpected host %d, %v != %v", i, resp.Hosts[i].HostKey, hk)
+		} else if resp.Hosts[i].Storage != 10*rhpv2.SectorSize {
+			t.Fatalf("unexpected storage %v", resp.Hosts[i].Storage)
+		}
+	}
+
+	// assert the aggregate estimate, storing 10 sectors costs 10*SectorSize*period
+	// per unit of storage price
+	unit := types.NewCurrency64(10 * rhpv2.SectorSize * period)
+	expectedFunding := unit.Mul64(1 + 2 + 3)
+	if !resp.Funding.Equals(expectedFunding) {
+		t.Fatalf("unexpected funding %v != %v", resp.Funding, expectedFunding)
+	}
+	expectedCollateral := unit.Mul64(2).Add(types.NewCurrency64(1))
+	if !resp.Collateral.Equals(expectedCollateral) {
+		t.Fatalf("unexpected collateral %v != %v", resp.Collateral, expectedCollateral)
+	}
+	contractPrices := types.Siacoins(3)
+	expectedFees := contractPrices.Add(expectedFunding.Add(contractPrices).Div64(10)).Add(txnFee.Mul64(3))
+	if !resp.Fees.Equals(expectedFees) {
+		t.Fatalf("unexpected fees %v != %v", resp.Fees, expectedFees)
+	}
+}
@@ -142,8 +142,9 @@ func (c *Contractor) isUsableContract(cfg api.AutopilotConfig, rs api.Redundancy
 		}
 	}
 
-	// IP check should be last since it modifies the filter
-	shouldFilter := !cfg.Hosts.AllowRedundantIPs && (usable || recoverable)
+	// IP check should be last since it modifies the filter, force included
+	// hosts are exempt
+	shouldFilter := !cfg.Hosts.AllowRedundantIPs && !ci.forceIncluded && (usable || recoverable)
 	if shouldFilter && f.IsRedundantIP(contract.HostIP, contract.HostKey) {
 		reasons = append(reasons, api.ErrUsabilityHostRedundantIP.Error())
 		usable = false
@@ -246,8 +247,9 @@ func checkHost(cfg api.AutopilotConfig, rs api.RedundancySettings, gc worker.Gou
 	var sb api.HostScoreBreakdown
 	var ub api.HostUsabilityBreakdown
 
-	// blocked status does not influence what host info is calculated
-	if h.Blocked {
+	// blocked status does not influence what host info is calculated, force
+	// excluded hosts are treated as blocked
+	if h.Blocked || h.ForceExcluded() {
 		ub.Blocked = true
 	}
 
@@ -278,8 +280,12 @@ func checkHost(cfg api.AutopilotConfig, rs api.RedundancySettings, gc worker.Gou
 			// not gouging, this because the core package does not have overflow
 			// checks in its cost calculations needed to calculate the period
 			// cost
+			//
+			// NOTE: the host's score multiplier is applied before comparing
+			// against the min score, force included hosts are never considered
+			// to have a low score
 			sb = hostScore(cfg, h, rs.Redundancy())
-			if sb.Score() < minScore {
+			if sb.Score()*h.ScoreMultiplier() < minScore && !h.ForceIncluded() {
 				ub.LowScore = true
 			}
 		}
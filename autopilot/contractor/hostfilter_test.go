@@ -8,8 +8,22 @@ import (
 	rhpv3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/test"
 )
 
+type mockGougingChecker struct {
+	gouging bool
+}
+
+func (gc mockGougingChecker) Check(_ *rhpv2.HostSettings, _ *rhpv3.HostPriceTable) (gb api.HostGougingBreakdown) {
+	if gc.gouging {
+		gb.GougingErr = "gouging"
+	}
+	return
+}
+
+func (gc mockGougingChecker) BlocksUntilBlockHeightGouging(_ uint64) int64 { return 0 }
+
 func TestMinRemainingCollateral(t *testing.T) {
 	t.Parallel()
 
@@ -86,3 +100,51 @@ func TestMinRemainingCollateral(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckHostOverrides(t *testing.T) {
+	rs := api.RedundancySettings{MinShards: 1, TotalShards: 3}
+	h := test.NewHost(test.RandomHostKey(), test.NewHostPriceTable(), test.NewHostSettings())
+
+	// pick a min score the host doesn't reach without an override
+	score := hostScore(cfg, h, rs.Redundancy()).Score()
+	minScore := score * 2
+
+	// assert the host isn't usable due to its low score
+	hc := checkHost(cfg, rs, mockGougingChecker{}, h, minScore)
+	if !hc.Usability.LowScore || hc.Usability.IsUsable() {
+		t.Fatal("expected host to have a low score", hc.Usability)
+	}
+
+	// boost its score, it should become usable
+	h.Override = &api.HostOverride{HostKey: h.PublicKey, ScoreMultiplier: 3}
+	hc = checkHost(cfg, rs, mockGougingChecker{}, h, minScore)
+	if !hc.Usability.IsUsable() {
+		t.Fatal("expected host to be usable", hc.Usability.UnusableReasons())
+	}
+
+	// a multiplier below 1 makes an otherwise usable host unusable
+	h.Override.ScoreMultiplier = 0.5
+	if hc := checkHost(cfg, rs, mockGougingChecker{}, h, score); !hc.Usability.LowScore {
+		t.Fatal("expected host to have a low score", hc.Usability)
+	}
+
+	// force including the host bypasses the score check
+	h.Override = &api.HostOverride{HostKey: h.PublicKey, ForceInclude: true}
+	hc = checkHost(cfg, rs, mockGougingChecker{}, h, minScore)
+	if !hc.Usability.IsUsable() {
+		t.Fatal("expected host to be usable", hc.Usability.UnusableReasons())
+	}
+
+	// but not the gouging checks
+	hc = checkHost(cfg, rs, mockGougingChecker{gouging: true}, h, minScore)
+	if !hc.Usability.Gouging || hc.Usability.IsUsable() {
+		t.Fatal("expected host to be gouging", hc.Usability)
+	}
+
+	// force excluding the host makes it unusable regardless of its score
+	h.Override = &api.HostOverride{HostKey: h.PublicKey, ForceExclude: true}
+	hc = checkHost(cfg, rs, mockGougingChecker{}, h, 0)
+	if !hc.Usability.Blocked || hc.Usability.IsUsable() {
+		t.Fatal("expected host to be blocked", hc.Usability)
+	}
+}
@@ -1,12 +1,47 @@
 package contractor
 
-import "lukechampine.com/frand"
+import (
+	"encoding/binary"
+	"sync"
+
+	"lukechampine.com/frand"
+)
 
 type (
 	scoredHosts []scoredHost
+
+	// selectionRNG is the source of randomness used when selecting hosts, it
+	// can be seeded to make the selection reproducible
+	selectionRNG struct {
+		mu  sync.Mutex
+		rng *frand.RNG
+	}
 )
 
-func (hosts scoredHosts) randSelectByScore(n int) (selected []scoredHost) {
+// newSelectionRNG returns a selection RNG seeded with the given seed, a seed of
+// 0 means the RNG is seeded randomly.
+func newSelectionRNG(seed uint64) *selectionRNG {
+	var entropy [32]byte
+	if seed == 0 {
+		entropy = frand.Entropy256()
+	} else {
+		binary.LittleEndian.PutUint64(entropy[:], seed)
+	}
+	return &selectionRNG{rng: frand.NewCustom(entropy[:], 1024, 12)}
+}
+
+// Float64 returns a random float64 in [0, 1), if the RNG is nil it falls back
+// to the global RNG.
+func (r *selectionRNG) Float64() float64 {
+	if r == nil {
+		return frand.Float64()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rng.Float64()
+}
+
+func (hosts scoredHosts) randSelectByScore(rng *selectionRNG, n int) (selected []scoredHost) {
 	if len(hosts) < n {
 		n = len(hosts)
 	} else if n < 0 {
@@ -38,7 +73,7 @@ func (hosts scoredHosts) randSelectByScore(n int) (selected []scoredHost) {
 
 		// select
 		sI := len(candidates) - 1
-		r := frand.Float64()
+		r := rng.Float64()
 		var sum float64
 		for i, host := range candidates {
 			sum += host.score
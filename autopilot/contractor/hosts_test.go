@@ -2,6 +2,7 @@ package contractor
 
 import (
 	"math"
+	"reflect"
 	"testing"
 
 	"go.sia.tech/core/types"
@@ -23,7 +24,7 @@ func TestScoredHostsRandSelectByScore(t *testing.T) {
 
 	for i := 0; i < 1000; i++ {
 		seen := make(map[types.PublicKey]struct{})
-		for _, h := range hosts.randSelectByScore(3) {
+		for _, h := range hosts.randSelectByScore(nil, 3) {
 			// assert we get non-normalized scores
 			if hostToScores[h.host.PublicKey] != h.score {
 				t.Fatal("unexpected")
@@ -38,17 +39,17 @@ func TestScoredHostsRandSelectByScore(t *testing.T) {
 
 		// assert min float is never selected
 		frand.Shuffle(len(hosts), func(i, j int) { hosts[i], hosts[j] = hosts[j], hosts[i] })
-		if hosts.randSelectByScore(1)[0].score == math.SmallestNonzeroFloat64 {
+		if hosts.randSelectByScore(nil, 1)[0].score == math.SmallestNonzeroFloat64 {
 			t.Fatal("unexpected")
 		}
 	}
 
 	// assert we can pass any value for n
-	if len(hosts.randSelectByScore(0)) != 0 {
+	if len(hosts.randSelectByScore(nil, 0)) != 0 {
 		t.Fatal("unexpected")
-	} else if len(hosts.randSelectByScore(-1)) != 0 {
+	} else if len(hosts.randSelectByScore(nil, -1)) != 0 {
 		t.Fatal("unexpected")
-	} else if len(hosts.randSelectByScore(4)) != 3 {
+	} else if len(hosts.randSelectByScore(nil, 4)) != 3 {
 		t.Fatal("unexpected")
 	}
 
@@ -59,7 +60,7 @@ func TestScoredHostsRandSelectByScore(t *testing.T) {
 		{score: .1, host: api.Host{PublicKey: types.PublicKey{2}}},
 	}
 	for i := 0; i < 100; i++ {
-		if hosts.randSelectByScore(1)[0].host.PublicKey == (types.PublicKey{1}) {
+		if hosts.randSelectByScore(nil, 1)[0].host.PublicKey == (types.PublicKey{1}) {
 			counts[0]++
 		} else {
 			counts[1]++
@@ -69,3 +70,37 @@ func TestScoredHostsRandSelectByScore(t *testing.T) {
 		t.Fatal("unexpected", counts[0], counts[1], diff)
 	}
 }
+
+func TestScoredHostsRandSelectByScoreSeeded(t *testing.T) {
+	var hosts scoredHosts
+	for i := 0; i < 100; i++ {
+		hosts = append(hosts, scoredHost{score: frand.Float64(), host: api.Host{PublicKey: types.PublicKey{byte(i)}}})
+	}
+
+	// selectHosts selects a couple of hosts using an RNG with the given seed,
+	// it selects multiple times to make sure the whole sequence is reproducible
+	selectHosts := func(seed uint64) (selected []types.PublicKey) {
+		rng := newSelectionRNG(seed)
+		for i := 0; i < 3; i++ {
+			for _, h := range hosts.randSelectByScore(rng, 10) {
+				selected = append(selected, h.host.PublicKey)
+			}
+		}
+		return
+	}
+
+	// assert the selection is identical for the same seed
+	if s1, s2 := selectHosts(1), selectHosts(1); !reflect.DeepEqual(s1, s2) {
+		t.Fatal("expected identical selection", s1, s2)
+	}
+
+	// assert the selection differs for a different seed
+	if s1, s2 := selectHosts(1), selectHosts(2); reflect.DeepEqual(s1, s2) {
+		t.Fatal("expected different selection")
+	}
+
+	// assert a random seed is used by default
+	if s1, s2 := selectHosts(0), selectHosts(0); reflect.DeepEqual(s1, s2) {
+		t.Fatal("expected different selection")
+	}
+}
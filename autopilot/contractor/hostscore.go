@@ -22,6 +22,12 @@ const (
 	minValidScore = math.SmallestNonzeroFloat64
 )
 
+// HostScore computes the score breakdown of the given host using the
+// autopilot's config and redundancy settings.
+func HostScore(state *MaintenanceState, h api.Host) api.HostScoreBreakdown {
+	return hostScore(state.AP.Config, h, state.RS.Redundancy())
+}
+
 func hostScore(cfg api.AutopilotConfig, h api.Host, expectedRedundancy float64) api.HostScoreBreakdown {
 	cCfg := cfg.Contracts
 	// idealDataPerHost is the amount of data that we would have to put on each
@@ -47,6 +53,7 @@ func hostScore(cfg api.AutopilotConfig, h api.Host, expectedRedundancy float64)
 		StorageRemaining: storageRemainingScore(h.Settings, h.StoredData, allocationPerHost),
 		Uptime:           uptimeScore(h),
 		Version:          versionScore(h.Settings, cfg.Hosts.MinProtocolVersion),
+		SuccessRate:      successRateScore(h),
 	}
 }
 
@@ -194,11 +201,20 @@ func interactionScore(h api.Host) float64 {
 	return math.Pow(success/(success+fail), 10)
 }
 
+// successRateScore computes a score between 0 and 1 based on the rolling
+// success rate of the sector uploads and downloads performed on the host. The
+// prior of successful operations avoids heavily penalizing a host for a
+// couple of failures.
+func successRateScore(h api.Host) float64 {
+	success, fail := 30.0, 0.0
+	success += h.Interactions.SuccessfulOperations
+	fail += h.Interactions.FailedOperations
+	return math.Pow(success/(success+fail), 10)
+}
+
 func uptimeScore(h api.Host) float64 {
 	secondToLastScanSuccess := h.Interactions.SecondToLastScanSuccess
 	lastScanSuccess := h.Interactions.LastScanSuccess
-	uptime := h.Interactions.Uptime
-	downtime := h.Interactions.Downtime
 	totalScans := h.Interactions.TotalScans
 
 	// special cases
@@ -223,13 +239,14 @@ func uptimeScore(h api.Host) float64 {
 
 	// account for the interval between the most recent interaction and the
 	// current time
-	finalInterval := time.Since(h.Interactions.LastScan)
+	interactions := h.Interactions
+	finalInterval := time.Since(interactions.LastScan)
 	if lastScanSuccess {
-		uptime += finalInterval
+		interactions.Uptime += finalInterval
 	} else {
-		downtime += finalInterval
+		interactions.Downtime += finalInterval
 	}
-	ratio := float64(uptime) / float64(uptime+downtime)
+	ratio := interactions.UptimeRatio()
 
 	// unconditionally forgive up to 2% downtime
 	if ratio >= 0.98 {
@@ -105,6 +105,98 @@ func TestHostScore(t *testing.T) {
 	}
 }
 
+func TestHostScoreBreakdown(t *testing.T) {
+	state := &MaintenanceState{
+		AP: api.Autopilot{Config: cfg},
+		RS: api.RedundancySettings{MinShards: 10, TotalShards: 30},
+	}
+
+	// prepare a host with known settings
+	settings := test.NewHostSettings()
+	settings.Version = "1.6.0"
+	h := test.NewHost(test.RandomHostKey(), test.NewHostPriceTable(), settings)
+	h.KnownSince = time.Now().Add(-200 * 24 * time.Hour)
+
+	// assert the breakdown matches the individual components
+	sb := HostScore(state, h)
+	if sb != hostScore(cfg, h, 3) {
+		t.Fatal("unexpected breakdown", sb)
+	} else if sb.Age != 1 {
+		t.Fatal("unexpected age score", sb.Age)
+	} else if sb.Version != 1 {
+		t.Fatal("unexpected version score", sb.Version)
+	} else if sb.Uptime != 0.85 {
+		t.Fatal("unexpected uptime score", sb.Uptime)
+	} else if sb.Score() != sb.Age*sb.Collateral*sb.Interactions*sb.StorageRemaining*sb.Uptime*sb.Version*sb.Prices*sb.SuccessRate {
+		t.Fatal("unexpected score", sb.Score())
+	}
+
+	// assert an outdated version is the penalizing component
+	h.Settings.Version = "1.5.8"
+	sb = HostScore(state, h)
+	if sb.Version != 0 {
+		t.Fatal("unexpected version score", sb.Version)
+	} else if sb.Lowest() != "version" {
+		t.Fatal("unexpected lowest component", sb.Lowest(), sb)
+	}
+
+	// assert a new host is penalized by its age
+	h.Settings.Version = "1.6.0"
+	h.KnownSince = time.Now()
+	sb = HostScore(state, h)
+	if sb.Age != ageScore(h) || sb.Age >= 0.01 {
+		t.Fatal("unexpected age score", sb.Age)
+	} else if sb.Lowest() != "age" {
+		t.Fatal("unexpected lowest component", sb.Lowest(), sb)
+	}
+}
+
+func TestSuccessRateScore(t *testing.T) {
+	settings := test.NewHostSettings()
+	settings.Version = "1.6.0"
+	h := test.NewHost(test.RandomHostKey(), test.NewHostPriceTable(), settings)
+	h.KnownSince = time.Now().Add(-200 * 24 * time.Hour)
+
+	// assert a host without operations isn't penalized
+	if rate := h.Interactions.OperationSuccessRate(); rate != 1 {
+		t.Fatal("unexpected success rate", rate)
+	} else if score := successRateScore(h); score != 1 {
+		t.Fatal("unexpected score", score)
+	}
+
+	// assert a host that mostly succeeds is barely penalized
+	h.Interactions.SuccessfulOperations = 95
+	h.Interactions.FailedOperations = 5
+	if rate := h.Interactions.OperationSuccessRate(); rate != 0.95 {
+		t.Fatal("unexpected success rate", rate)
+	}
+	good := successRateScore(h)
+	if good <= 0.5 || good >= 1 {
+		t.Fatal("unexpected score", good)
+	}
+
+	// assert a host that frequently fails is heavily penalized, even if its
+	// scans pass
+	h.Interactions.SuccessfulOperations = 50
+	h.Interactions.FailedOperations = 50
+	h.Interactions.SuccessfulInteractions = 100
+	if rate := h.Interactions.OperationSuccessRate(); rate != 0.5 {
+		t.Fatal("unexpected success rate", rate)
+	}
+	bad := successRateScore(h)
+	if bad >= good || bad >= 0.01 {
+		t.Fatal("unexpected score", bad)
+	}
+
+	// assert the success rate is exposed in the breakdown
+	sb := hostScore(cfg, h, 3)
+	if sb.SuccessRate != bad {
+		t.Fatal("unexpected success rate score", sb.SuccessRate)
+	} else if sb.Lowest() != "successRate" {
+		t.Fatal("unexpected lowest component", sb.Lowest(), sb)
+	}
+}
+
 func TestPriceAdjustmentScore(t *testing.T) {
 	score := func(cpp uint32) float64 {
 		t.Helper()
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"go.sia.tech/core/types"
@@ -89,10 +90,12 @@ type (
 
 	ipResolver struct {
 		resolver    resolver
-		cache       map[string]ipCacheEntry
 		timeout     time.Duration
 		shutdownCtx context.Context
 		logger      *zap.SugaredLogger
+
+		mu    sync.Mutex
+		cache map[string]ipCacheEntry
 	}
 
 	ipCacheEntry struct {
@@ -115,6 +118,8 @@ func newIPResolver(ctx context.Context, timeout time.Duration, logger *zap.Sugar
 }
 
 func (r *ipResolver) pruneCache() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for hostIP, entry := range r.cache {
 		if time.Since(entry.created) > ipCacheEntryValidity {
 			delete(r.cache, hostIP)
@@ -138,7 +143,10 @@ func (r *ipResolver) lookup(hostIP string) ([]string, error) {
 	if err != nil {
 		// check the cache if it's an i/o timeout or server misbehaving error
 		if utils.IsErr(err, ErrIOTimeout) || utils.IsErr(err, errServerMisbehaving) {
-			if entry, found := r.cache[hostIP]; found && time.Since(entry.created) < ipCacheEntryValidity {
+			r.mu.Lock()
+			entry, found := r.cache[hostIP]
+			r.mu.Unlock()
+			if found && time.Since(entry.created) < ipCacheEntryValidity {
 				r.logger.Infof("using cached IP addresses for %v, err: %v", hostIP, err)
 				return entry.subnets, nil
 			}
@@ -156,10 +164,12 @@ func (r *ipResolver) lookup(hostIP string) ([]string, error) {
 
 	// add to cache
 	if len(subnets) > 0 {
+		r.mu.Lock()
 		r.cache[hostIP] = ipCacheEntry{
 			created: time.Now(),
 			subnets: subnets,
 		}
+		r.mu.Unlock()
 	}
 
 	return subnets, nil
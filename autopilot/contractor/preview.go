@@ -0,0 +1,285 @@
+package contractor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+const (
+	previewReasonMissing = "contract set is missing contracts"
+	previewReasonRefresh = "contract needs to be refreshed"
+	previewReasonRenew   = "contract is due for renewal"
+	previewReasonUnknown = "contract failed contract checks"
+)
+
+// PreviewContractMaintenance computes the changes contract maintenance would
+// make to the contract set. It runs the same host and contract checks, applies
+// the same budgets and selects hosts the same way but doesn't form, renew,
+// refresh or archive any contracts. Since hosts are selected at random,
+// weighted by their score, the additions can vary between previews.
+func (c *Contractor) PreviewContractMaintenance(ctx context.Context, w Worker, state *MaintenanceState) (api.ContractsPreviewResponse, error) {
+	mCtx := newMaintenanceCtx(ctx, state)
+
+	// check if maintenance would be skipped
+	if reason, skip := canSkipContractMaintenance(ctx, state.ContractsConfig()); skip {
+		if reason == "" {
+			return api.ContractsPreviewResponse{}, context.Cause(ctx)
+		}
+		return api.ContractsPreviewResponse{}, fmt.Errorf("no changes to preview, %s", reason)
+	}
+
+	// fetch current contract set
+	currentSet, err := c.bus.Contracts(ctx, api.ContractsOpts{ContractSet: mCtx.ContractSet()})
+	if err != nil && !strings.Contains(err.Error(), api.ErrContractSetNotFound.Error()) {
+		return api.ContractsPreviewResponse{}, err
+	}
+	isInCurrentSet := make(map[types.FileContractID]struct{})
+	for _, c := range currentSet {
+		isInCurrentSet[c.ID] = struct{}{}
+	}
+
+	// fetch all contracts from the worker
+	resp, err := w.Contracts(ctx, timeoutHostRevision)
+	if err != nil {
+		return api.ContractsPreviewResponse{}, err
+	}
+	contracts := resp.Contracts
+	sort.Slice(contracts, func(i, j int) bool {
+		return contracts[i].FileSize() > contracts[j].FileSize()
+	})
+	usedHosts := make(map[types.PublicKey]struct{})
+	contractData := make(map[types.FileContractID]uint64)
+	for _, contract := range contracts {
+		usedHosts[contract.HostKey] = struct{}{}
+		contractData[contract.ID] = contract.FileSize()
+	}
+
+	// fetch all hosts
+	hosts, err := c.bus.SearchHosts(ctx, api.SearchHostOptions{Limit: -1, FilterMode: api.HostFilterModeAllowed})
+	if err != nil {
+		return api.ContractsPreviewResponse{}, err
+	}
+
+	// fetch candidate hosts and run host checks
+	candidates, _, err := c.candidateHosts(mCtx, hosts, usedHosts, minValidScore)
+	if err != nil {
+		return api.ContractsPreviewResponse{}, err
+	}
+	var minScore float64
+	if len(hosts) > 0 {
+		minScore = c.calculateMinScore(candidates, mCtx.WantedContracts())
+	}
+	checks, err := c.runHostChecks(mCtx, hosts, minScore)
+	if err != nil {
+		return api.ContractsPreviewResponse{}, fmt.Errorf("failed to run host checks, err: %v", err)
+	}
+
+	// run contract checks
+	cs, err := c.bus.ConsensusState(ctx)
+	if err != nil {
+		return api.ContractsPreviewResponse{}, fmt.Errorf("failed to fetch consensus state, err: %v", err)
+	}
+	updatedSet, _, toStopUsing, toRefresh, toRenew := c.runContractChecks(mCtx, checks, contracts, isInCurrentSet, cs.BlockHeight)
+
+	// compute the budgets
+	budgets, err := c.maintenanceBudgets(mCtx, contracts)
+	if err != nil {
+		return api.ContractsPreviewResponse{}, err
+	}
+	renewBudget := budgets.renew
+
+	// spread and prioritize renewals
+	toRenew, deferred, limit := prioritizeRenewals(mCtx, toRenew, len(updatedSet), isInCurrentSet, cs.BlockHeight)
+	for _, ci := range deferred {
+		updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+	}
+
+	// preview renewals and refreshes
+	var renewed, refreshed []contractInfo
+	if budgets.overBudget || budgets.skipRenewals {
+		for _, ci := range toRenew {
+			if ci.usable {
+				updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+			}
+		}
+		for _, ci := range toRefresh {
+			if ci.usable {
+				updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+			}
+		}
+	} else {
+		var toKeep []api.ContractMetadata
+		renewed, toKeep = c.previewRenewals(mCtx, toRenew, &renewBudget, limit)
+		refreshed = c.previewRefreshes(mCtx, toRefresh, &renewBudget)
+		for _, ci := range append(append([]contractInfo{}, renewed...), refreshed...) {
+			if ci.usable || ci.recoverable {
+				updatedSet = append(updatedSet, ci.contract.ContractMetadata)
+			}
+		}
+		updatedSet = append(updatedSet, toKeep...)
+	}
+
+	// preview formations
+	var formed []api.Host
+	formationBudget, reserveReached := budgets.formationBudget(mCtx, budgets.renew.Sub(renewBudget))
+	if uint64(len(updatedSet)) < formationThreshold(mCtx, len(contracts)) && !state.SkipContractFormations && !budgets.overBudget && !reserveReached {
+		formed = c.previewFormations(mCtx, candidates, usedHostsList(hosts, usedHosts), usedHosts, mCtx.WantedContracts()-uint64(len(updatedSet)), formationBudget)
+	}
+
+	// cap the set, formations never exceed the wanted amount of contracts
+	updatedSet = capContractSet(mCtx, updatedSet, contractData, toStopUsing)
+
+	return previewContractSet(currentSet, updatedSet, toStopUsing, renewed, refreshed, formed), nil
+}
+
+// previewRenewals mirrors runContractRenewals without renewing any contracts.
+// Contracts are renewed until 'limit' is reached or the budget runs out, the
+// usable contracts that aren't renewed are kept.
+func (c *Contractor) previewRenewals(ctx *mCtx, toRenew []contractInfo, budget *types.Currency, limit int) (renewed []contractInfo, toKeep []api.ContractMetadata) {
+	for i, ci := range toRenew {
+		if len(renewed)+len(toKeep) >= limit {
+			break
+		}
+
+		// contracts without a revision can't be renewed
+		var renterFunds types.Currency
+		err := errContractNoRevision
+		if ci.contract.Revision != nil {
+			renterFunds, err = c.renewFunds(ctx, ci, false)
+		}
+		if err == nil && budget.Cmp(renterFunds) >= 0 {
+			*budget = budget.Sub(renterFunds)
+			renewed = append(renewed, ci)
+			continue
+		} else if ci.usable {
+			toKeep = append(toKeep, ci.contract.ContractMetadata)
+		}
+
+		// stop renewing if we ran out of budget and keep the remaining usable
+		// contracts if we have 'limit' left
+		if err == nil {
+			for _, ci := range toRenew[i+1:] {
+				if len(renewed)+len(toKeep) < limit && ci.usable {
+					toKeep = append(toKeep, ci.contract.ContractMetadata)
+				}
+			}
+			break
+		}
+	}
+	return
+}
+
+// previewRefreshes mirrors runContractRefreshes without refreshing any
+// contracts, contracts are refreshed until the budget runs out.
+func (c *Contractor) previewRefreshes(ctx *mCtx, toRefresh []contractInfo, budget *types.Currency) (refreshed []contractInfo) {
+	for _, ci := range toRefresh {
+		if ci.contract.Revision == nil {
+			continue
+		}
+		renterFunds, err := c.refreshFunds(ctx, ci)
+		if err != nil {
+			continue
+		} else if budget.Cmp(renterFunds) < 0 {
+			break
+		}
+		*budget = budget.Sub(renterFunds)
+		refreshed = append(refreshed, ci)
+	}
+	return
+}
+
+// previewFormations mirrors runContractFormations without forming any
+// contracts. Candidates are selected the same way but aren't rescanned, the
+// funding is based on their last known settings.
+func (c *Contractor) previewFormations(ctx *mCtx, candidates scoredHosts, used []api.Host, usedHosts map[types.PublicKey]struct{}, missing uint64, budget types.Currency) (formed []api.Host) {
+	wanted := int(addLeeway(missing, leewayPctCandidateHosts))
+	selected := c.selectCandidates(ctx, ctx.AutopilotConfig().Hosts.DiversityWeight, candidates, used, wanted)
+
+	// prepare an IP filter that contains all used hosts
+	shouldFilter := !ctx.AllowRedundantIPs()
+	ipFilter := c.newIPFilter()
+	if shouldFilter {
+		for _, h := range candidates {
+			if _, used := usedHosts[h.host.PublicKey]; used {
+				_ = ipFilter.IsRedundantIP(h.host.NetAddress, h.host.PublicKey)
+			}
+		}
+	}
+
+	minInitialContractFunds, maxInitialContractFunds := initialContractFundingMinMax(ctx.AutopilotConfig())
+	txnFee := ctx.state.Fee.Mul64(estimatedFileContractTransactionSetSize)
+	for _, candidate := range selected {
+		host := candidate.host
+		if uint64(len(formed)) == missing {
+			break
+		} else if shouldFilter && !host.ForceIncluded() && ipFilter.IsRedundantIP(host.NetAddress, host.PublicKey) {
+			continue
+		}
+
+		renterFunds := initialContractFunding(host.Settings, txnFee, minInitialContractFunds, maxInitialContractFunds)
+		if checkHostFunding(host.Settings, txnFee, renterFunds) != nil {
+			continue
+		} else if budget.Cmp(renterFunds) < 0 {
+			break
+		}
+		budget = budget.Sub(renterFunds)
+		formed = append(formed, host)
+	}
+	return
+}
+
+// previewContractSet computes the diff between the current contract set and
+// the updated set. Renewed and refreshed contracts are part of the updated set
+// under their current id.
+func previewContractSet(currentSet, updatedSet []api.ContractMetadata, toStopUsing map[types.FileContractID]string, renewed, refreshed []contractInfo, formed []api.Host) (resp api.ContractsPreviewResponse) {
+	resp.Add = []api.ContractSetChange{}
+	resp.Renew = []api.ContractSetChange{}
+	resp.Drop = []api.ContractSetChange{}
+
+	// add renewals and refreshes
+	addRenewals := func(cis []contractInfo, reason string) {
+		for _, ci := range cis {
+			resp.Renew = append(resp.Renew, api.ContractSetChange{
+				ContractID: ci.contract.ID,
+				HostKey:    ci.contract.HostKey,
+				Reason:     reason,
+			})
+		}
+	}
+	addRenewals(renewed, previewReasonRenew)
+	addRenewals(refreshed, previewReasonRefresh)
+
+	// contracts in the current set that don't remain are dropped
+	remaining := make(map[types.FileContractID]struct{})
+	for _, c := range updatedSet {
+		remaining[c.ID] = struct{}{}
+	}
+	for _, c := range currentSet {
+		if _, ok := remaining[c.ID]; ok {
+			continue
+		}
+		reason, ok := toStopUsing[c.ID]
+		if !ok {
+			reason = previewReasonUnknown
+		}
+		resp.Drop = append(resp.Drop, api.ContractSetChange{
+			ContractID: c.ID,
+			HostKey:    c.HostKey,
+			Reason:     reason,
+		})
+	}
+
+	// add formations
+	for _, h := range formed {
+		resp.Add = append(resp.Add, api.ContractSetChange{
+			HostKey: h.PublicKey,
+			Reason:  previewReasonMissing,
+		})
+	}
+	return
+}
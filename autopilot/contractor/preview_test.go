@@ -0,0 +1,117 @@
+package contractor
+
+import (
+	"context"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/alerts"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+func TestPreviewContractSet(t *testing.T) {
+	newContract := func(id byte) api.ContractMetadata {
+		return api.ContractMetadata{ID: types.FileContractID{id}, HostKey: types.PublicKey{id}}
+	}
+	newContractInfo := func(id byte) contractInfo {
+		return contractInfo{contract: api.Contract{ContractMetadata: newContract(id)}, usable: true}
+	}
+
+	// prepare a current set of 5 contracts, 1 and 2 are kept, 3 is renewed, 4
+	// is refreshed and 5 has gone bad
+	currentSet := []api.ContractMetadata{newContract(1), newContract(2), newContract(3), newContract(4), newContract(5)}
+	updatedSet := []api.ContractMetadata{newContract(1), newContract(2), newContract(3), newContract(4)}
+	renewed := []contractInfo{newContractInfo(3)}
+	refreshed := []contractInfo{newContractInfo(4)}
+	toStopUsing := map[types.FileContractID]string{
+		{5}: api.ErrUsabilityHostOffline.Error(),
+	}
+	formed := []api.Host{{PublicKey: types.PublicKey{10}}, {PublicKey: types.PublicKey{11}}}
+
+	resp := previewContractSet(currentSet, updatedSet, toStopUsing, renewed, refreshed, formed)
+
+	// assert the additions
+	if len(resp.Add) != 2 {
+		t.Fatalf("expected 2 additions, got %v", len(resp.Add))
+	}
+	for i, hk := range []types.PublicKey{{10}, {11}} {
+		if resp.Add[i].HostKey != hk {
+			t.Fatalf("unexpected addition %d, %v != %v", i, resp.Add[i].HostKey, hk)
+		} else if resp.Add[i].ContractID != (types.FileContractID{}) {
+			t.Fatal("additions shouldn't have a contract id")
+		}
+	}
+
+	// assert the renewals
+	if len(resp.Renew) != 2 {
+		t.Fatalf("expected 2 renewals, got %v", len(resp.Renew))
+	} else if resp.Renew[0].ContractID != (types.FileContractID{3}) || resp.Renew[0].Reason != previewReasonRenew {
+		t.Fatal("unexpected renewal", resp.Renew[0])
+	} else if resp.Renew[1].ContractID != (types.FileContractID{4}) || resp.Renew[1].Reason != previewReasonRefresh {
+		t.Fatal("unexpected renewal", resp.Renew[1])
+	}
+
+	// assert the removals
+	if len(resp.Drop) != 1 {
+		t.Fatalf("expected 1 removal, got %v", len(resp.Drop))
+	} else if resp.Drop[0].ContractID != (types.FileContractID{5}) || resp.Drop[0].Reason != api.ErrUsabilityHostOffline.Error() {
+		t.Fatal("unexpected removal", resp.Drop[0])
+	}
+}
+
+func TestPreviewContractMaintenance(t *testing.T) {
+	b := &mockBus{
+		ancestors: make(map[types.FileContractID][]api.ArchivedContract),
+		hosts:     newTestHosts(10),
+	}
+	c := New(b, alerts.NewManager(), zap.NewNop().Sugar(), 0, 0, 1)
+	defer c.Close()
+
+	// prepare a contract that ran out of funds and needs to be refreshed
+	state := newTestMaintenanceState(types.Siacoins(1e6))
+	state.AP.Config.Contracts.SpendBudget = types.Siacoins(10)
+	rev := newTestContractRevision(b.hosts[0].PublicKey, types.Siacoins(1), types.Siacoins(1), state.AP.EndHeight())
+	refresh := api.Contract{
+		ContractMetadata: api.ContractMetadata{
+			ID:          rev.ID(),
+			HostKey:     b.hosts[0].PublicKey,
+			StartHeight: state.AP.CurrentPeriod,
+			WindowStart: rev.Revision.WindowStart,
+			WindowEnd:   rev.Revision.WindowEnd,
+			Spending:    api.ContractSpending{Uploads: types.Siacoins(5)},
+		},
+		Revision: &rev.Revision,
+	}
+	w := &mockWorker{contracts: []api.Contract{refresh}}
+
+	// assert the preview contains the refresh and fills the set with hosts
+	// we don't have a contract with yet
+	resp, err := c.PreviewContractMaintenance(context.Background(), w, state)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(resp.Renew) != 1 || resp.Renew[0].ContractID != refresh.ID || resp.Renew[0].Reason != previewReasonRefresh {
+		t.Fatalf("unexpected renewals %+v", resp.Renew)
+	} else if len(resp.Add) != int(state.AP.Config.Contracts.Amount)-1 {
+		t.Fatalf("unexpected additions %+v", resp.Add)
+	}
+	for _, add := range resp.Add {
+		if add.HostKey == refresh.HostKey {
+			t.Fatal("expected host we have a contract with not to be added")
+		}
+	}
+
+	// assert no contracts were formed or renewed
+	if w.numFormed() != 0 || w.numRenewed() != 0 {
+		t.Fatal("expected no contracts to be formed or renewed")
+	}
+
+	// assert the preview respects the spend budget
+	b.ancestors[refresh.ID] = []api.ArchivedContract{{StartHeight: state.AP.CurrentPeriod, Spending: api.ContractSpending{Uploads: types.Siacoins(6)}}}
+	resp, err = c.PreviewContractMaintenance(context.Background(), w, state)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(resp.Renew) != 0 || len(resp.Add) != 0 {
+		t.Fatalf("expected no changes over budget, got %+v", resp)
+	}
+}
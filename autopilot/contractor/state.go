@@ -21,6 +21,7 @@ type (
 		Address                types.Address
 		Fee                    types.Currency
 		SkipContractFormations bool
+		Spendable              types.Currency
 	}
 
 	mCtx struct {
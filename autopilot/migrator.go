@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/alerts"
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/internal/utils"
@@ -33,13 +34,19 @@ type (
 		mu                 sync.Mutex
 		migrating          bool
 		migratingLastStart time.Time
+		paused             bool
+		settingsLoaded     bool
+		queued             uint64
+		migrated           uint64
+		failed             uint64
 	}
 
 	job struct {
 		api.UnhealthySlab
-		slabIdx   int
-		batchSize int
-		set       string
+		slabIdx       int
+		batchSize     int
+		set           string
+		excludedHosts []types.PublicKey
 
 		b Bus
 	}
@@ -51,7 +58,7 @@ func (j *job) execute(ctx context.Context, w Worker) (_ api.MigrateSlabResponse,
 		return api.MigrateSlabResponse{}, fmt.Errorf("failed to fetch slab; %w", err)
 	}
 
-	res, err := w.MigrateSlab(ctx, slab, j.set)
+	res, err := w.MigrateSlab(ctx, slab, j.set, j.excludedHosts)
 	if err != nil {
 		return api.MigrateSlabResponse{}, fmt.Errorf("failed to migrate slab; %w", err)
 	} else if res.Error != "" {
@@ -85,6 +92,84 @@ func (m *migrator) Status() (bool, time.Time) {
 	return m.migrating, m.migratingLastStart
 }
 
+// Pause prevents the migrator from starting new migrations, ongoing slab
+// migrations are allowed to finish. The migrator remains paused across
+// restarts until it's resumed.
+func (m *migrator) Pause(ctx context.Context) error {
+	return m.setPaused(ctx, true)
+}
+
+// Resume allows the migrator to perform migrations again, migrations resume the
+// next time they are triggered.
+func (m *migrator) Resume(ctx context.Context) error {
+	return m.setPaused(ctx, false)
+}
+
+// loadSettings restores the persisted migrator state, it's a no-op once the
+// state was loaded.
+func (m *migrator) loadSettings(ctx context.Context) error {
+	m.mu.Lock()
+	loaded := m.settingsLoaded
+	m.mu.Unlock()
+	if loaded {
+		return nil
+	}
+
+	var ms api.MigratorSettings
+	if err := m.ap.bus.Setting(ctx, api.SettingMigrator, &ms); err != nil && !utils.IsErr(err, api.ErrSettingNotFound) {
+		return fmt.Errorf("failed to fetch migrator settings: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.settingsLoaded {
+		m.paused = ms.Paused
+		m.settingsLoaded = true
+	}
+	return nil
+}
+
+func (m *migrator) setPaused(ctx context.Context, paused bool) error {
+	if err := m.ap.bus.UpdateSetting(ctx, api.SettingMigrator, api.MigratorSettings{Paused: paused}); err != nil {
+		return fmt.Errorf("failed to update migrator settings: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.paused = paused
+	m.settingsLoaded = true
+	return nil
+}
+
+func (m *migrator) isPaused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.paused
+}
+
+func (m *migrator) status() api.MigratorStatusResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return api.MigratorStatusResponse{
+		Migrating: m.migrating,
+		Paused:    m.paused,
+		Queued:    m.queued,
+		Migrated:  m.migrated,
+		Failed:    m.failed,
+		StartTime: api.TimeRFC3339(m.migratingLastStart),
+	}
+}
+
+func (m *migrator) trackMigration(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.failed++
+	} else {
+		m.migrated++
+	}
+}
+
 func (m *migrator) slabMigrationEstimate(remaining int) time.Duration {
 	// recompute p90
 	m.statsSlabMigrationSpeedMS.Recompute()
@@ -100,13 +185,20 @@ func (m *migrator) slabMigrationEstimate(remaining int) time.Duration {
 }
 
 func (m *migrator) tryPerformMigrations(wp *workerPool) {
+	// make sure a pause survives a restart
+	if err := m.loadSettings(m.ap.shutdownCtx); err != nil {
+		m.logger.Errorf("failed to load migrator settings, skipping migrations: %v", err)
+		return
+	}
+
 	m.mu.Lock()
-	if m.migrating || m.ap.isStopped() {
+	if m.migrating || m.paused || m.ap.isStopped() {
 		m.mu.Unlock()
 		return
 	}
 	m.migrating = true
 	m.migratingLastStart = time.Now()
+	m.queued, m.migrated, m.failed = 0, 0, 0
 	m.mu.Unlock()
 
 	m.ap.wg.Add(1)
@@ -115,6 +207,7 @@ func (m *migrator) tryPerformMigrations(wp *workerPool) {
 		m.performMigrations(wp)
 		m.mu.Lock()
 		m.migrating = false
+		m.queued = 0
 		m.mu.Unlock()
 	}()
 }
@@ -155,6 +248,7 @@ func (m *migrator) performMigrations(p *workerPool) {
 						start := time.Now()
 						res, err := j.execute(ctx, w)
 						m.statsSlabMigrationSpeedMS.Track(float64(time.Since(start).Milliseconds()))
+						m.trackMigration(err)
 						if err != nil {
 							m.logger.Errorf("%v: migration %d/%d failed, key: %v, health: %v, overpaid: %v, err: %v", id, j.slabIdx+1, j.batchSize, j.Key, j.Health, res.SurchargeApplied, err)
 							skipAlert := utils.IsErr(err, api.ErrSlabNotFound)
@@ -275,13 +369,23 @@ OUTER:
 		}
 
 		for i, slab := range toMigrate {
+			// stop dispatching jobs if the migrator was paused
+			if m.isPaused() {
+				m.logger.Info("migrations paused")
+				return
+			}
+
+			m.mu.Lock()
+			m.queued = uint64(len(toMigrate) - i)
+			m.mu.Unlock()
+
 			select {
 			case <-m.ap.shutdownCtx.Done():
 				return
 			case <-m.signalMaintenanceFinished:
 				m.logger.Info("migrations interrupted - updating slabs for migration")
 				continue OUTER
-			case jobs <- job{slab, i, len(toMigrate), set, b}:
+			case jobs <- job{slab, i, len(toMigrate), set, nil, b}:
 			}
 		}
 
@@ -289,6 +393,54 @@ OUTER:
 	}
 }
 
+// evacuateHosts migrates all slabs with sectors stored on any of the given
+// hosts to other hosts in the autopilot's contract set. The hosts themselves
+// are not considered as targets for the migrated sectors.
+func (m *migrator) evacuateHosts(ctx context.Context, wp *workerPool, hostKeys []types.PublicKey) (resp api.SlabsMigrateResponse, err error) {
+	// fetch currently configured set
+	autopilot, err := m.ap.Config(ctx)
+	if err != nil {
+		return api.SlabsMigrateResponse{}, fmt.Errorf("failed to fetch autopilot config: %w", err)
+	}
+	set := autopilot.Config.Contracts.Set
+	if set == "" {
+		return api.SlabsMigrateResponse{}, api.ErrContractSetNotSpecified
+	}
+
+	// fetch slabs for evacuation
+	toMigrate, err := m.ap.bus.SlabsForEvacuation(ctx, hostKeys, -1)
+	if err != nil {
+		return api.SlabsMigrateResponse{}, fmt.Errorf("failed to fetch slabs for evacuation: %w", err)
+	}
+
+	// migrate them one by one
+	resp.Slabs = make([]api.SlabMigrationResult, 0, len(toMigrate))
+	wp.withWorker(func(w Worker) {
+		for i, slab := range toMigrate {
+			j := job{
+				UnhealthySlab: slab,
+				slabIdx:       i,
+				batchSize:     len(toMigrate),
+				set:           set,
+				excludedHosts: hostKeys,
+				b:             m.ap.bus,
+			}
+			res, err := j.execute(ctx, w)
+			result := api.SlabMigrationResult{
+				Key:               slab.Key,
+				NumShardsMigrated: res.NumShardsMigrated,
+			}
+			if err != nil {
+				m.logger.Errorf("evacuation %d/%d failed, key: %v, err: %v", i+1, len(toMigrate), slab.Key, err)
+				result.Error = err.Error()
+			}
+			resp.TotalShardsMigrated += res.NumShardsMigrated
+			resp.Slabs = append(resp.Slabs, result)
+		}
+	})
+	return
+}
+
 func (m *migrator) objectIDsForSlabKey(ctx context.Context, key object.EncryptionKey) (map[string][]string, error) {
 	// fetch all buckets
 	//
@@ -0,0 +1,161 @@
+package autopilot
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/alerts"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/object"
+	"go.uber.org/zap"
+)
+
+type mockMigratorBus struct {
+	Bus
+
+	mu       sync.Mutex
+	health   map[object.EncryptionKey]float64
+	settings map[string][]byte
+}
+
+func (b *mockMigratorBus) Autopilot(_ context.Context, id string) (api.Autopilot, error) {
+	return api.Autopilot{ID: id, Config: api.AutopilotConfig{Contracts: api.ContractsConfig{Set: "set"}}}, nil
+}
+
+func (b *mockMigratorBus) DismissAlerts(_ context.Context, _ ...types.Hash256) error {
+	return nil
+}
+
+func (b *mockMigratorBus) RefreshHealth(_ context.Context) error {
+	return nil
+}
+
+func (b *mockMigratorBus) RegisterAlert(_ context.Context, _ alerts.Alert) error {
+	return nil
+}
+
+func (b *mockMigratorBus) Setting(_ context.Context, key string, value interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setting, ok := b.settings[key]
+	if !ok {
+		return api.ErrSettingNotFound
+	}
+	return json.Unmarshal(setting, value)
+}
+
+func (b *mockMigratorBus) UpdateSetting(_ context.Context, key string, value interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	setting, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	b.settings[key] = setting
+	return nil
+}
+
+func (b *mockMigratorBus) Slab(_ context.Context, key object.EncryptionKey) (object.Slab, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return object.Slab{Key: key, Health: b.health[key]}, nil
+}
+
+func (b *mockMigratorBus) SlabsForMigration(_ context.Context, healthCutoff float64, _ string, _ int) (slabs []api.UnhealthySlab, _ error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, health := range b.health {
+		if health <= healthCutoff {
+			slabs = append(slabs, api.UnhealthySlab{Key: key, Health: health})
+		}
+	}
+	sort.Slice(slabs, func(i, j int) bool { return slabs[i].Health < slabs[j].Health })
+	return
+}
+
+type mockMigratorWorker struct {
+	Worker
+
+	b        *mockMigratorBus
+	migrated []object.EncryptionKey
+}
+
+func (w *mockMigratorWorker) ID(_ context.Context) (string, error) {
+	return "worker", nil
+}
+
+func (w *mockMigratorWorker) MigrateSlab(_ context.Context, s object.Slab, _ string, _ []types.PublicKey) (api.MigrateSlabResponse, error) {
+	w.b.mu.Lock()
+	defer w.b.mu.Unlock()
+	w.b.health[s.Key] = 1
+	w.migrated = append(w.migrated, s.Key)
+	return api.MigrateSlabResponse{NumShardsMigrated: 1}, nil
+}
+
+func TestMigrator(t *testing.T) {
+	// prepare a healthy and a degraded slab
+	healthy := object.GenerateEncryptionKey()
+	degraded := object.GenerateEncryptionKey()
+	b := &mockMigratorBus{
+		health: map[object.EncryptionKey]float64{
+			healthy:  1,
+			degraded: .5,
+		},
+		settings: make(map[string][]byte),
+	}
+	w := &mockMigratorWorker{b: b}
+
+	// create the autopilot and migrator
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ap := &Autopilot{
+		id:          "autopilot",
+		alerts:      alerts.WithOrigin(b, "autopilot"),
+		bus:         b,
+		logger:      zap.NewNop().Sugar(),
+		workers:     newWorkerPool([]Worker{w}),
+		shutdownCtx: ctx,
+	}
+	m := newMigrator(ap, 0.99, 1)
+	wp := ap.workers
+
+	// pause the migrator and assert no migrations are started
+	if err := m.Pause(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.tryPerformMigrations(wp)
+	ap.wg.Wait()
+	if status := m.status(); !status.Paused || status.Migrating {
+		t.Fatalf("unexpected status %+v", status)
+	} else if len(w.migrated) != 0 {
+		t.Fatal("expected no migrations while paused")
+	}
+
+	// recreate the migrator to simulate a restart and assert it's still paused
+	m = newMigrator(ap, 0.99, 1)
+	m.tryPerformMigrations(wp)
+	ap.wg.Wait()
+	if status := m.status(); !status.Paused || status.Migrating {
+		t.Fatalf("unexpected status %+v", status)
+	} else if len(w.migrated) != 0 {
+		t.Fatal("expected no migrations while paused")
+	}
+
+	// resume the migrator and assert the degraded slab is restored
+	if err := m.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	m.tryPerformMigrations(wp)
+	ap.wg.Wait()
+	if status := m.status(); status.Paused || status.Migrating || status.Migrated != 1 || status.Failed != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	} else if len(w.migrated) != 1 || w.migrated[0].String() != degraded.String() {
+		t.Fatalf("expected degraded slab to be migrated, got %v", w.migrated)
+	} else if b.health[degraded] != 1 {
+		t.Fatal("expected degraded slab to be restored")
+	}
+}
@@ -3,6 +3,7 @@ package autopilot
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,15 +15,39 @@ import (
 	"go.sia.tech/renterd/autopilot/contractor"
 	"go.sia.tech/renterd/internal/utils"
 	"go.uber.org/zap"
+	"lukechampine.com/frand"
 )
 
 const (
 	scannerTimeoutInterval   = 10 * time.Minute
 	scannerTimeoutMinTimeout = 10 * time.Second
 
+	// scannerBatchFailureRateHigh is the failure rate at which the scanner
+	// halves its batch size, scannerBatchFailureRateLow is the failure rate
+	// below which the scanner is allowed to increase its batch size
+	scannerBatchFailureRateHigh = 0.25
+	scannerBatchFailureRateLow  = 0.1
+
+	// scannerBatchLatencyFactor is the factor by which the median latency of
+	// a batch has to be lower than the scan timeout for the batch size to be
+	// increased
+	scannerBatchLatencyFactor = 4
+
+	// scannerBackoffMaxDefault is the maximum amount of time a host that
+	// repeatedly fails its scans is skipped for when the max downtime of the
+	// hosts config isn't known yet
+	scannerBackoffMaxDefault = 7 * 24 * time.Hour
+
+	// scannerHostTimeoutMin is the lowest timeout a host is scanned with when
+	// its timeout is derived from its own scan history
+	scannerHostTimeoutMin = 2 * time.Second
+
 	trackerMinDataPoints     = 25
 	trackerNumDataPoints     = 1000
 	trackerTimeoutPercentile = 99
+
+	trackerHostMinDataPoints = 3
+	trackerHostNumDataPoints = 10
 )
 
 type (
@@ -33,24 +58,34 @@ type (
 		bus interface {
 			SearchHosts(ctx context.Context, opts api.SearchHostOptions) ([]api.Host, error)
 			HostsForScanning(ctx context.Context, opts api.HostsForScanningOptions) ([]api.HostAddress, error)
-			RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime time.Duration) (uint64, error)
+			RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime, gracePeriod time.Duration) (uint64, error)
 		}
 
 		tracker *tracker
+		history *scanHistory
 		logger  *zap.SugaredLogger
 		ap      *Autopilot
 		wg      sync.WaitGroup
 
-		scanBatchSize   uint64
-		scanThreads     uint64
-		scanMinInterval time.Duration
+		scanBatchSizeMin uint64
+		scanBatchSizeMax uint64
+		scanThreads      uint64
+		scanMinInterval  time.Duration
+
+		minRecentScanFailuresFloor uint64
 
 		timeoutMinInterval time.Duration
 		timeoutMinTimeout  time.Duration
 
 		mu                sync.Mutex
+		backoffs          map[types.PublicKey]hostBackoff
+		backoffMax        time.Duration
+		scanBatchSize     uint64
 		scanning          bool
 		scanningLastStart time.Time
+		scanningOffset    int
+		scanningQueued    uint64
+		scanningScanned   uint64
 		timeout           time.Duration
 		timeoutLastUpdate time.Time
 		interruptScanChan chan struct{}
@@ -59,6 +94,13 @@ type (
 		RHPScan(ctx context.Context, hostKey types.PublicKey, hostIP string, timeout time.Duration) (api.RHPScanResponse, error)
 	}
 
+	// hostBackoff contains the number of consecutive scan failures of a host
+	// and the time until which the host isn't scanned.
+	hostBackoff struct {
+		failures uint64
+		until    time.Time
+	}
+
 	scanReq struct {
 		hostKey types.PublicKey
 		hostIP  string
@@ -67,9 +109,20 @@ type (
 	scanResp struct {
 		hostKey  types.PublicKey
 		settings rhpv2.HostSettings
+		ping     time.Duration
+		failed   bool
 		err      error
 	}
 
+	// scanHistory is a bounded ring buffer containing the most recent scan
+	// results, it's used for diagnostics only.
+	scanHistory struct {
+		mu      sync.Mutex
+		next    int
+		full    bool
+		results []api.ScanResult
+	}
+
 	tracker struct {
 		threshold  uint64
 		percentile float64
@@ -77,18 +130,61 @@ type (
 		mu      sync.Mutex
 		count   uint64
 		timings []float64
+		hosts   map[types.PublicKey]*hostTimings
+	}
+
+	// hostTimings contains the most recent scan timings of a single host.
+	hostTimings struct {
+		count   uint64
+		timings []float64
 	}
 )
 
+func newScanHistory(size uint64) *scanHistory {
+	return &scanHistory{results: make([]api.ScanResult, size)}
+}
+
+func (h *scanHistory) add(result api.ScanResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.results) == 0 {
+		return
+	}
+
+	h.results[h.next] = result
+	h.next = (h.next + 1) % len(h.results)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// recent returns the results in the buffer, ordered from newest to oldest.
+func (h *scanHistory) recent() []api.ScanResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n := h.next
+	if h.full {
+		n = len(h.results)
+	}
+
+	results := make([]api.ScanResult, 0, n)
+	for i := 1; i <= n; i++ {
+		results = append(results, h.results[(h.next-i+len(h.results))%len(h.results)])
+	}
+	return results
+}
+
 func newTracker(threshold, total uint64, percentile float64) *tracker {
 	return &tracker{
 		threshold:  threshold,
 		percentile: percentile,
 		timings:    make([]float64, total),
+		hosts:      make(map[types.PublicKey]*hostTimings),
 	}
 }
 
-func (t *tracker) addDataPoint(duration time.Duration) {
+func (t *tracker) addDataPoint(hk types.PublicKey, duration time.Duration) {
 	if duration == 0 {
 		return
 	}
@@ -102,6 +198,59 @@ func (t *tracker) addDataPoint(duration time.Duration) {
 	// when we overflow entirely, since we only ever increment the count with 1
 	// it will never happen
 	t.count += 1
+
+	// keep track of the host's timings
+	ht, exists := t.hosts[hk]
+	if !exists {
+		ht = &hostTimings{timings: make([]float64, trackerHostNumDataPoints)}
+		t.hosts[hk] = ht
+	}
+	ht.timings[ht.count%uint64(len(ht.timings))] = float64(duration.Milliseconds())
+	ht.count += 1
+}
+
+// average returns the average of the tracked timings, it returns 0 if no
+// timings were tracked yet.
+func (t *tracker) average() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.count
+	if n > uint64(len(t.timings)) {
+		n = uint64(len(t.timings))
+	}
+	if n == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, timing := range t.timings[:n] {
+		sum += timing
+	}
+	return time.Duration(sum/float64(n)) * time.Millisecond
+}
+
+// hostTimeout returns the timeout derived from the host's own scan timings, it
+// returns 0 if not enough timings were tracked for the host.
+func (t *tracker) hostTimeout(hk types.PublicKey) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ht, exists := t.hosts[hk]
+	if !exists || ht.count < trackerHostMinDataPoints {
+		return 0
+	}
+
+	timings := ht.timings
+	if ht.count < uint64(len(timings)) {
+		timings = timings[:ht.count]
+	}
+	percentile, err := percentile(timings, t.percentile)
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(percentile) * time.Millisecond
 }
 
 func (t *tracker) timeout() time.Duration {
@@ -119,10 +268,16 @@ func (t *tracker) timeout() time.Duration {
 	return time.Duration(percentile) * time.Millisecond
 }
 
-func newScanner(ap *Autopilot, scanBatchSize, scanThreads uint64, scanMinInterval, timeoutMinInterval, timeoutMinTimeout time.Duration) (*scanner, error) {
+func newScanner(ap *Autopilot, scanBatchSize, scanBatchSizeMin, scanBatchSizeMax, scanThreads, scanHistorySize, minRecentScanFailuresFloor uint64, scanMinInterval, timeoutMinInterval, timeoutMinTimeout time.Duration) (*scanner, error) {
 	if scanBatchSize == 0 {
 		return nil, errors.New("scanner batch size has to be greater than zero")
 	}
+	if scanBatchSizeMin == 0 || scanBatchSizeMin > scanBatchSize {
+		return nil, errors.New("scanner min batch size has to be greater than zero and can't exceed the batch size")
+	}
+	if scanBatchSizeMax < scanBatchSize {
+		return nil, errors.New("scanner max batch size can't be lower than the batch size")
+	}
 	if scanThreads == 0 {
 		return nil, errors.New("scanner threads has to be greater than zero")
 	}
@@ -134,14 +289,20 @@ func newScanner(ap *Autopilot, scanBatchSize, scanThreads uint64, scanMinInterva
 			trackerNumDataPoints,
 			trackerTimeoutPercentile,
 		),
-		logger: ap.logger.Named("scanner"),
-		ap:     ap,
+		history: newScanHistory(scanHistorySize),
+		logger:  ap.logger.Named("scanner"),
+		ap:      ap,
 
 		interruptScanChan: make(chan struct{}),
 
-		scanBatchSize:   scanBatchSize,
-		scanThreads:     scanThreads,
-		scanMinInterval: scanMinInterval,
+		backoffs:         make(map[types.PublicKey]hostBackoff),
+		scanBatchSize:    scanBatchSize,
+		scanBatchSizeMin: scanBatchSizeMin,
+		scanBatchSizeMax: scanBatchSizeMax,
+		scanThreads:      scanThreads,
+		scanMinInterval:  scanMinInterval,
+
+		minRecentScanFailuresFloor: minRecentScanFailuresFloor,
 
 		timeoutMinInterval: timeoutMinInterval,
 		timeoutMinTimeout:  timeoutMinTimeout,
@@ -154,6 +315,37 @@ func (s *scanner) Status() (bool, time.Time) {
 	return s.scanning, s.scanningLastStart
 }
 
+// status returns the progress of the ongoing scan, or the last scan if no scan
+// is ongoing. The estimated completion time is derived from the average scan
+// duration and is only set while scanning.
+func (s *scanner) status() api.ScannerStatusResponse {
+	avg := s.tracker.average()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var eta time.Time
+	if s.scanning && avg > 0 {
+		remaining := s.scanningQueued - s.scanningScanned
+		eta = time.Now().Add(time.Duration(remaining) * avg / time.Duration(s.scanThreads))
+	}
+
+	return api.ScannerStatusResponse{
+		Scanning:            s.scanning,
+		Queued:              s.scanningQueued,
+		Scanned:             s.scanningScanned,
+		Offset:              s.scanningOffset,
+		StartTime:           api.TimeRFC3339(s.scanningLastStart),
+		EstimatedCompletion: api.TimeRFC3339(eta),
+	}
+}
+
+// recentScans returns the most recent scan results, ordered from newest to
+// oldest.
+func (s *scanner) recentScans() []api.ScanResult {
+	return s.history.recent()
+}
+
 func (s *scanner) isInterrupted() bool {
 	select {
 	case <-s.interruptScanChan:
@@ -163,61 +355,135 @@ func (s *scanner) isInterrupted() bool {
 	}
 }
 
+// tryPerformHostScan performs a host scan. A forced scan interrupts any ongoing
+// scan and sweeps over all hosts, otherwise an incremental scan is performed.
 func (s *scanner) tryPerformHostScan(ctx context.Context, w scanWorker, force bool) {
-	if s.ap.isStopped() {
+	if !force {
+		s.tryPerformIncrementalScan(ctx, w)
+		return
+	} else if s.ap.isStopped() {
 		return
 	}
 
-	scanType := "host scan"
-	if force {
-		scanType = "forced scan"
-	}
+	s.mu.Lock()
+	close(s.interruptScanChan)
+	s.mu.Unlock()
+
+	s.logger.Infof("waiting for ongoing scan to complete")
+	s.wg.Wait()
 
 	s.mu.Lock()
-	if force {
-		close(s.interruptScanChan)
-		s.mu.Unlock()
+	s.interruptScanChan = make(chan struct{})
+	s.startScan(ctx, w, "forced scan", time.Now())
+	s.mu.Unlock()
+}
 
-		s.logger.Infof("waiting for ongoing scan to complete")
-		s.wg.Wait()
+// tryPerformIncrementalScan performs a host scan that only considers hosts that
+// haven't been scanned within the scanner's minimum scan interval. It's a no-op
+// if a scan is ongoing or if a scan was started recently.
+func (s *scanner) tryPerformIncrementalScan(ctx context.Context, w scanWorker) {
+	if s.ap.isStopped() {
+		return
+	}
 
-		s.mu.Lock()
-		s.interruptScanChan = make(chan struct{})
-	} else if s.scanning || !s.isScanRequired() {
-		s.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scanning || !s.isScanRequired() {
 		return
 	}
+	s.startScan(ctx, w, "host scan", time.Now().Add(-s.scanMinInterval))
+}
+
+// tryStartScan starts a scan over all hosts right away, regardless of when the
+// last scan was started. It returns whether a scan was started, which isn't the
+// case if a scan is already ongoing.
+func (s *scanner) tryStartScan(ctx context.Context, w scanWorker) bool {
+	if s.ap.isStopped() {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scanning {
+		return false
+	}
+	s.startScan(ctx, w, "manual scan", time.Now())
+	return true
+}
+
+// startScan launches a scan of all hosts that were last scanned before the
+// given cutoff, the caller must hold the scanner's lock.
+func (s *scanner) startScan(ctx context.Context, w scanWorker, scanType string, cutoff time.Time) {
 	s.scanningLastStart = time.Now()
 	s.scanning = true
-	s.mu.Unlock()
+	s.scanningOffset = 0
+	s.scanningQueued = 0
+	s.scanningScanned = 0
 
 	s.logger.Infof("%s started", scanType)
 
 	s.wg.Add(1)
-	go func(st string) {
+	go func() {
 		defer s.wg.Done()
 
-		for resp := range s.launchScanWorkers(ctx, w, s.launchHostScans()) {
-			if s.isInterrupted() || s.ap.isStopped() {
-				break
-			}
-			if resp.err != nil && !strings.Contains(resp.err.Error(), "connection refused") {
-				s.logger.Error(resp.err)
-			}
-		}
+		scanned := s.scanHosts(ctx, w, cutoff)
+
 		s.mu.Lock()
 		s.scanning = false
-		s.logger.Infof("%s finished after %v", st, time.Since(s.scanningLastStart))
+		s.logger.Infof("%s finished after %v, scanned %d hosts", scanType, time.Since(s.scanningLastStart), scanned)
+		s.mu.Unlock()
+	}()
+}
+
+// scanHosts scans all hosts that were last scanned before the given cutoff and
+// returns the number of hosts that were scanned. The scan is aborted as soon as the context is
+// cancelled, the scan is interrupted or the autopilot is stopped, in which
+// case hosts that are still queued are abandoned.
+func (s *scanner) scanHosts(ctx context.Context, w scanWorker, cutoff time.Time) (scanned uint64) {
+	// cancelling the context on return ensures the scan workers and the
+	// goroutine queueing the hosts exit if we return early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var batch []scanResp
+	for resp := range s.launchScanWorkers(ctx, w, s.launchHostScans(ctx, cutoff)) {
+		if s.isInterrupted() || s.ap.isStopped() || ctx.Err() != nil {
+			break
+		}
+		if resp.err != nil && !strings.Contains(resp.err.Error(), "connection refused") {
+			s.logger.Error(resp.err)
+		}
+		scanned++
+		s.mu.Lock()
+		s.scanningScanned++
 		s.mu.Unlock()
-	}(scanType)
+		s.updateBackoff(resp.hostKey, resp.failed)
+
+		// adjust the batch size every time a batch worth of hosts has been
+		// scanned
+		batch = append(batch, resp)
+		if uint64(len(batch)) >= s.batchSize() {
+			s.adjustBatchSize(batch)
+			batch = batch[:0]
+		}
+	}
+	return
 }
 
 func (s *scanner) PruneHosts(ctx context.Context, cfg api.HostsConfig) {
 	maxDowntime := time.Duration(cfg.MaxDowntimeHours) * time.Hour
-	minRecentScanFailures := cfg.MinRecentScanFailures
+	gracePeriod := time.Duration(cfg.RemovalGracePeriodHours) * time.Hour
+	minRecentScanFailures := minRecentScanFailures(cfg, s.minRecentScanFailuresFloor)
+
+	// the backoff of failing hosts is capped at the max downtime to ensure
+	// they are scanned before they are considered for removal
+	s.mu.Lock()
+	s.backoffMax = maxDowntime
+	s.mu.Unlock()
+
 	if maxDowntime > 0 {
 		s.logger.Debugf("removing hosts that have been offline for more than %v and have failed at least %d scans", maxDowntime, minRecentScanFailures)
-		removed, err := s.bus.RemoveOfflineHosts(ctx, minRecentScanFailures, maxDowntime)
+		removed, err := s.bus.RemoveOfflineHosts(ctx, minRecentScanFailures, maxDowntime, gracePeriod)
 		if err != nil {
 			s.logger.Errorf("error occurred while removing offline hosts, err: %v", err)
 		} else if removed > 0 {
@@ -226,6 +492,16 @@ func (s *scanner) PruneHosts(ctx context.Context, cfg api.HostsConfig) {
 	}
 }
 
+// minRecentScanFailures returns the number of recent scan failures a host needs
+// to have before it's considered for removal, the configured value is clamped
+// to the given floor to avoid removing hosts after only a few failed scans.
+func minRecentScanFailures(cfg api.HostsConfig, floor uint64) uint64 {
+	if cfg.MinRecentScanFailures < floor {
+		return floor
+	}
+	return cfg.MinRecentScanFailures
+}
+
 func (s *scanner) tryUpdateTimeout() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -246,23 +522,207 @@ func (s *scanner) tryUpdateTimeout() {
 	s.timeoutLastUpdate = time.Now()
 }
 
-func (s *scanner) launchHostScans() chan scanReq {
-	reqChan := make(chan scanReq, s.scanBatchSize)
+func (s *scanner) adjustBatchSize(batch []scanResp) {
+	if len(batch) == 0 {
+		return
+	}
+
+	// collect the failures and latencies of the batch
+	var failures int
+	var pings []float64
+	for _, resp := range batch {
+		if resp.failed {
+			failures++
+		} else if resp.ping > 0 {
+			pings = append(pings, float64(resp.ping.Milliseconds()))
+		}
+	}
+	failureRate := float64(failures) / float64(len(batch))
+
+	timeout := s.currentTimeout()
+	if timeout == 0 {
+		timeout = s.timeoutMinTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// halve the batch size if failures rise, increase it if failures are low
+	// and the hosts respond well within the timeout
+	updated := s.scanBatchSize
+	if failureRate >= scannerBatchFailureRateHigh {
+		updated /= 2
+	} else if failureRate <= scannerBatchFailureRateLow && len(pings) > 0 && timeout > 0 {
+		median, err := percentile(pings, 50)
+		if err == nil && time.Duration(median)*time.Millisecond < timeout/scannerBatchLatencyFactor {
+			updated += updated/2 + 1
+		}
+	}
+
+	// apply the bounds
+	if updated < s.scanBatchSizeMin {
+		updated = s.scanBatchSizeMin
+	} else if updated > s.scanBatchSizeMax {
+		updated = s.scanBatchSizeMax
+	}
+
+	if s.scanBatchSize != updated {
+		s.logger.Debugf("updated batch size %v->%v, failure rate %.2f", s.scanBatchSize, updated, failureRate)
+		s.scanBatchSize = updated
+	}
+}
+
+// isBackingOff returns true if the host failed its recent scans and shouldn't
+// be scanned again until its backoff has elapsed.
+func (s *scanner) isBackingOff(hk types.PublicKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, exists := s.backoffs[hk]
+	return exists && time.Now().Before(b.until)
+}
+
+// updateBackoff updates the backoff of the given host after it was scanned.
+// Hosts that fail K consecutive scans are skipped for interval*2^K with
+// jitter, capped at the max downtime, a successful scan resets the backoff.
+func (s *scanner) updateBackoff(hk types.PublicKey, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !failed {
+		delete(s.backoffs, hk)
+		return
+	}
+
+	maxBackoff := s.backoffMax
+	if maxBackoff == 0 {
+		maxBackoff = scannerBackoffMaxDefault
+	}
+
+	b := s.backoffs[hk]
+	b.failures++
+	backoff := s.scanMinInterval
+	for i := uint64(0); i < b.failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > 0 {
+		backoff = backoff/2 + time.Duration(frand.Uint64n(uint64(backoff)))
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	b.until = time.Now().Add(backoff)
+	s.backoffs[hk] = b
+}
+
+func (s *scanner) batchSize() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scanBatchSize
+}
+
+// maxInFlightScans returns the number of scans that may be in flight at the
+// same time, it's the adaptive batch size capped by the number of scan threads.
+func (s *scanner) maxInFlightScans() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return min(s.scanBatchSize, s.scanThreads)
+}
+
+// scanLimiter limits the number of scans that are in flight at the same time
+// to a limit that can change while scanning.
+type scanLimiter struct {
+	limit func() uint64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight uint64
+}
+
+func newScanLimiter(limit func() uint64) *scanLimiter {
+	l := &scanLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until a scan can be started, it returns false if the context
+// was cancelled while waiting.
+func (l *scanLimiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.inFlight >= l.limit() && ctx.Err() == nil {
+		l.cond.Wait()
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// release marks a scan as finished. Waiting scans re-evaluate the limit, so
+// they pick up changes to it as soon as an in-flight scan finishes.
+func (l *scanLimiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// launchHostScans queues all hosts that were last scanned before the given
+// cutoff for scanning. The queue is sized by the number of scan threads rather
+// than the batch size, batches are fetched one batch ahead so the next batch is
+// ready to be queued before the current one finishes draining.
+func (s *scanner) launchHostScans(ctx context.Context, cutoff time.Time) chan scanReq {
+	reqChan := make(chan scanReq, s.scanThreads)
+	batchChan := s.fetchHostBatches(ctx, cutoff)
 
 	s.ap.wg.Add(1)
 	go func() {
 		defer s.ap.wg.Done()
 		defer close(reqChan)
 
+		// add batches to scan queue
+		for hosts := range batchChan {
+			for _, h := range hosts {
+				select {
+				case <-s.ap.shutdownCtx.Done():
+					return
+				case <-ctx.Done():
+					return
+				case reqChan <- scanReq{
+					hostKey: h.PublicKey,
+					hostIP:  h.NetAddress,
+				}:
+				}
+			}
+		}
+	}()
+
+	return reqChan
+}
+
+// fetchHostBatches fetches batches of hosts that were last scanned before the
+// given cutoff. The returned channel is unbuffered, so the next batch is fetched
+// while the previous one is being queued but never more than one batch ahead.
+func (s *scanner) fetchHostBatches(ctx context.Context, cutoff time.Time) chan []api.HostAddress {
+	batchChan := make(chan []api.HostAddress)
+
+	s.ap.wg.Add(1)
+	go func() {
+		defer s.ap.wg.Done()
+		defer close(batchChan)
+
 		var offset int
 		var exhausted bool
-		cutoff := time.Now().Add(-s.scanMinInterval)
-		for !s.ap.isStopped() && !exhausted {
+		for !s.ap.isStopped() && !exhausted && ctx.Err() == nil {
 			// fetch next batch
-			hosts, err := s.bus.HostsForScanning(s.ap.shutdownCtx, api.HostsForScanningOptions{
+			limit := int(s.batchSize())
+			hosts, err := s.bus.HostsForScanning(ctx, api.HostsForScanningOptions{
 				MaxLastScan: api.TimeRFC3339(cutoff),
+				SortBy:      api.HostSortByLastScan,
+				SortDir:     api.HostSortDirAsc,
 				Offset:      offset,
-				Limit:       int(s.scanBatchSize),
+				Limit:       limit,
 			})
 			if err != nil {
 				s.logger.Errorf("could not get hosts for scanning, err: %v", err)
@@ -271,49 +731,78 @@ func (s *scanner) launchHostScans() chan scanReq {
 			if len(hosts) == 0 {
 				break
 			}
-			if len(hosts) < int(s.scanBatchSize) {
+			if len(hosts) < limit {
 				exhausted = true
 			}
 
-			s.logger.Infof("scanning %d hosts in range %d-%d", len(hosts), offset, offset+int(s.scanBatchSize))
-			offset += int(s.scanBatchSize)
-
-			// add batch to scan queue
-			for _, h := range hosts {
-				select {
-				case <-s.ap.shutdownCtx.Done():
-					return
-				case reqChan <- scanReq{
-					hostKey: h.PublicKey,
-					hostIP:  h.NetAddress,
-				}:
+			// skip hosts that are backing off
+			var skipped int
+			for i := 0; i < len(hosts); i++ {
+				if s.isBackingOff(hosts[i].PublicKey) {
+					hosts = append(hosts[:i], hosts[i+1:]...)
+					skipped++
+					i--
 				}
 			}
+
+			s.logger.Infof("scanning %d hosts in range %d-%d, skipped %d hosts that are backing off", len(hosts), offset, offset+limit, skipped)
+			s.mu.Lock()
+			s.scanningOffset = offset
+			s.scanningQueued += uint64(len(hosts))
+			s.mu.Unlock()
+			offset += limit
+
+			// hand off the batch
+			select {
+			case <-s.ap.shutdownCtx.Done():
+				return
+			case <-ctx.Done():
+				return
+			case batchChan <- hosts:
+			}
 		}
 	}()
 
-	return reqChan
+	return batchChan
 }
 
+// launchScanWorkers launches the scan threads, the number of scans in flight is
+// limited by the adaptive batch size so a batch size below the number of
+// threads lowers the scan concurrency.
 func (s *scanner) launchScanWorkers(ctx context.Context, w scanWorker, reqs chan scanReq) chan scanResp {
 	respChan := make(chan scanResp, s.scanThreads)
 	liveThreads := s.scanThreads
+	limiter := newScanLimiter(s.maxInFlightScans)
 
 	for i := uint64(0); i < s.scanThreads; i++ {
 		go func() {
 			for req := range reqs {
-				if s.ap.isStopped() {
+				if s.ap.isStopped() || ctx.Err() != nil {
+					break // shutdown
+				} else if !limiter.acquire(ctx) {
 					break // shutdown
 				}
 
-				scan, err := w.RHPScan(ctx, req.hostKey, req.hostIP, s.currentTimeout())
+				start := time.Now()
+				scan, err := w.RHPScan(ctx, req.hostKey, req.hostIP, s.hostTimeout(req.hostKey))
+				limiter.release()
+				s.recordScan(req.hostKey, start, scan, err)
 				if err != nil {
 					break // abort
 				} else if !utils.IsErr(errors.New(scan.ScanError), contractor.ErrIOTimeout) && scan.Ping > 0 {
-					s.tracker.addDataPoint(time.Duration(scan.Ping))
+					s.tracker.addDataPoint(req.hostKey, time.Duration(scan.Ping))
 				}
 
-				respChan <- scanResp{req.hostKey, scan.Settings, err}
+				select {
+				case <-ctx.Done():
+				case respChan <- scanResp{
+					hostKey:  req.hostKey,
+					settings: scan.Settings,
+					ping:     time.Duration(scan.Ping),
+					failed:   scan.ScanError != "",
+					err:      err,
+				}:
+				}
 			}
 
 			if atomic.AddUint64(&liveThreads, ^uint64(0)) == 0 {
@@ -325,6 +814,37 @@ func (s *scanner) launchScanWorkers(ctx context.Context, w scanWorker, reqs chan
 	return respChan
 }
 
+// rescanHost synchronously scans a single host, the scan result is persisted
+// by the worker performing the scan. Failed scans are returned as an error.
+func (s *scanner) rescanHost(ctx context.Context, w scanWorker, hk types.PublicKey, hostIP string) (api.RHPScanResponse, error) {
+	start := time.Now()
+	scan, err := w.RHPScan(ctx, hk, hostIP, s.hostTimeout(hk))
+	s.recordScan(hk, start, scan, err)
+	if err != nil {
+		return api.RHPScanResponse{}, fmt.Errorf("failed to scan host %v: %w", hk, err)
+	}
+	s.updateBackoff(hk, scan.ScanError != "")
+	if scan.ScanError != "" {
+		return api.RHPScanResponse{}, fmt.Errorf("failed to scan host %v: %s", hk, scan.ScanError)
+	} else if scan.Ping > 0 {
+		s.tracker.addDataPoint(hk, time.Duration(scan.Ping))
+	}
+	return scan, nil
+}
+
+func (s *scanner) recordScan(hk types.PublicKey, timestamp time.Time, scan api.RHPScanResponse, err error) {
+	result := api.ScanResult{
+		HostKey:   hk,
+		Timestamp: api.TimeRFC3339(timestamp),
+		Latency:   scan.Ping,
+		Error:     scan.ScanError,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.history.add(result)
+}
+
 func (s *scanner) isScanRequired() bool {
 	return s.scanningLastStart.IsZero() || time.Since(s.scanningLastStart) > s.scanMinInterval/20 // check 20 times per minInterval, so every 30 minutes
 }
@@ -333,6 +853,26 @@ func (s *scanner) isTimeoutUpdateRequired() bool {
 	return s.timeoutLastUpdate.IsZero() || time.Since(s.timeoutLastUpdate) > s.timeoutMinInterval
 }
 
+// hostTimeout returns the timeout to scan the given host with. Hosts with
+// enough scan history get a timeout derived from their own timings, bounded by
+// scannerHostTimeoutMin and the current timeout, other hosts are scanned using
+// the current timeout.
+func (s *scanner) hostTimeout(hk types.PublicKey) time.Duration {
+	timeout := s.currentTimeout()
+	hostTimeout := s.tracker.hostTimeout(hk)
+	if hostTimeout == 0 {
+		return timeout
+	}
+
+	if hostTimeout < scannerHostTimeoutMin {
+		hostTimeout = scannerHostTimeoutMin
+	}
+	if timeout > 0 && hostTimeout > timeout {
+		hostTimeout = timeout
+	}
+	return hostTimeout
+}
+
 func (s *scanner) currentTimeout() time.Duration {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -3,23 +3,37 @@ package autopilot
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	rhpv3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/internal/test"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"lukechampine.com/frand"
 )
 
 type mockBus struct {
+	mu    sync.Mutex
 	hosts []api.Host
 	reqs  []string
 }
 
+func (b *mockBus) requests() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.reqs...)
+}
+
 func (b *mockBus) SearchHosts(ctx context.Context, opts api.SearchHostOptions) ([]api.Host, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	b.reqs = append(b.reqs, fmt.Sprintf("%d-%d", opts.Offset, opts.Offset+opts.Limit))
 
 	start := opts.Offset
@@ -36,44 +50,86 @@ func (b *mockBus) SearchHosts(ctx context.Context, opts api.SearchHostOptions) (
 }
 
 func (b *mockBus) HostsForScanning(ctx context.Context, opts api.HostsForScanningOptions) ([]api.HostAddress, error) {
-	hosts, err := b.SearchHosts(ctx, api.SearchHostOptions{
-		Offset: opts.Offset,
-		Limit:  opts.Limit,
-	})
-	if err != nil {
-		return nil, err
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reqs = append(b.reqs, fmt.Sprintf("%d-%d", opts.Offset, opts.Offset+opts.Limit))
+
+	if opts.SortBy == api.HostSortByLastScan {
+		sort.SliceStable(b.hosts, func(i, j int) bool {
+			return b.hosts[i].Interactions.LastScan.Before(b.hosts[j].Interactions.LastScan)
+		})
 	}
+
+	// filter out hosts that were scanned after the cutoff
 	var hostAddresses []api.HostAddress
-	for _, h := range hosts {
+	for _, h := range b.hosts {
+		if !opts.MaxLastScan.IsZero() && !h.Interactions.LastScan.Before(time.Time(opts.MaxLastScan)) {
+			continue
+		}
 		hostAddresses = append(hostAddresses, api.HostAddress{
 			NetAddress: h.NetAddress,
 			PublicKey:  h.PublicKey,
 		})
 	}
-	return hostAddresses, nil
+
+	start := opts.Offset
+	if start > len(hostAddresses) {
+		return nil, nil
+	}
+	end := opts.Offset + opts.Limit
+	if end > len(hostAddresses) {
+		end = len(hostAddresses)
+	}
+	return hostAddresses[start:end], nil
 }
 
-func (b *mockBus) RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime time.Duration) (uint64, error) {
+func (b *mockBus) RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime, gracePeriod time.Duration) (uint64, error) {
 	return 0, nil
 }
 
 type mockWorker struct {
-	blockChan chan struct{}
+	blockChan  chan struct{}
+	scanError  string
+	settings   rhpv2.HostSettings
+	priceTable rhpv3.HostPriceTable
 
-	mu        sync.Mutex
-	scanCount int
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	scanCount   int
+	scanned     []types.PublicKey
 }
 
 func (w *mockWorker) RHPScan(ctx context.Context, hostKey types.PublicKey, hostIP string, _ time.Duration) (api.RHPScanResponse, error) {
+	w.mu.Lock()
+	w.inFlight++
+	w.maxInFlight = max(w.maxInFlight, w.inFlight)
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.inFlight--
+		w.mu.Unlock()
+	}()
+
 	if w.blockChan != nil {
-		<-w.blockChan
+		select {
+		case <-w.blockChan:
+		case <-ctx.Done():
+			return api.RHPScanResponse{}, ctx.Err()
+		}
 	}
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	w.scanCount++
+	w.scanned = append(w.scanned, hostKey)
 
-	return api.RHPScanResponse{}, nil
+	return api.RHPScanResponse{
+		Ping:       api.DurationMS(time.Millisecond),
+		ScanError:  w.scanError,
+		Settings:   w.settings,
+		PriceTable: w.priceTable,
+	}, nil
 }
 
 func (w *mockWorker) RHPPriceTable(ctx context.Context, hostKey types.PublicKey, siamuxAddr string) (api.HostPriceTable, error) {
@@ -133,12 +189,486 @@ func TestScanner(t *testing.T) {
 	}
 }
 
+func TestScannerTryStartScan(t *testing.T) {
+	// prepare 100 hosts
+	hosts := test.NewHosts(100)
+
+	// init new scanner with a worker that blocks
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{blockChan: make(chan struct{})}
+	s := newTestScanner(b)
+
+	// assert a scan is started when idle
+	if !s.tryStartScan(context.Background(), w) {
+		t.Fatal("expected scan to be started")
+	} else if !s.isScanning() {
+		t.Fatal("unexpected")
+	}
+
+	// assert no scan is started while one is ongoing
+	if s.tryStartScan(context.Background(), w) {
+		t.Fatal("expected scan to be busy")
+	}
+
+	// unblock the worker and wait for the scan to finish
+	close(w.blockChan)
+	s.wg.Wait()
+	if w.scanCount != 100 {
+		t.Fatalf("unexpected number of scans, %v != 100", w.scanCount)
+	}
+
+	// assert a scan is started right after the previous one, all hosts are
+	// scanned again even though they were scanned recently
+	if !s.tryStartScan(context.Background(), w) {
+		t.Fatal("expected scan to be started")
+	}
+	s.wg.Wait()
+	if w.scanCount != 200 {
+		t.Fatalf("unexpected number of scans, %v != 200", w.scanCount)
+	}
+}
+
+func TestScannerContextCancellation(t *testing.T) {
+	// prepare 100 hosts
+	hosts := test.NewHosts(100)
+
+	// init new scanner with a worker that blocks
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{blockChan: make(chan struct{})}
+	s := newTestScanner(b)
+
+	// start a scan
+	ctx, cancel := context.WithCancel(context.Background())
+	s.tryPerformHostScan(ctx, w, false)
+	if !s.isScanning() {
+		t.Fatal("unexpected")
+	}
+
+	// cancel the context and assert the scan stops promptly
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scan didn't stop after the context was cancelled")
+	}
+	if s.isScanning() {
+		t.Fatal("unexpected")
+	} else if w.scanCount != 0 {
+		t.Fatalf("unexpected number of scans, %v != 0", w.scanCount)
+	}
+
+	// assert an uninterrupted scan returns the number of scanned hosts
+	if scanned := s.scanHosts(context.Background(), &mockWorker{}, time.Now()); scanned != 100 {
+		t.Fatalf("unexpected number of scanned hosts, %v != 100", scanned)
+	}
+}
+
+func TestScannerPrefetch(t *testing.T) {
+	// prepare 100 hosts
+	hosts := test.NewHosts(100)
+
+	// init new scanner with a worker that blocks
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{blockChan: make(chan struct{})}
+	s := newTestScanner(b)
+
+	// start a scan
+	s.tryPerformHostScan(context.Background(), w, false)
+
+	// assert the next batch gets fetched while the threads are still busy
+	// scanning the first batch
+	tt := test.NewTT(t)
+	tt.Retry(100, 10*time.Millisecond, func() error {
+		if reqs := b.requests(); len(reqs) != 2 {
+			return fmt.Errorf("unexpected number of requests, %v != 2", len(reqs))
+		}
+		return nil
+	})
+
+	// assert the scanner doesn't fetch more than one batch ahead
+	time.Sleep(100 * time.Millisecond)
+	if reqs := b.requests(); len(reqs) != 2 || reqs[0] != "0-40" || reqs[1] != "40-80" {
+		t.Fatalf("unexpected requests, %v", reqs)
+	} else if status := s.status(); status.Scanned != 0 || status.Queued != 80 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// unblock the worker and assert all hosts get scanned
+	close(w.blockChan)
+	s.wg.Wait()
+	if w.scanCount != 100 {
+		t.Fatalf("unexpected number of scans, %v != 100", w.scanCount)
+	}
+}
+
+func TestScannerBatchSizeLimitsConcurrency(t *testing.T) {
+	// prepare 20 hosts
+	hosts := test.NewHosts(20)
+
+	// init new scanner with more threads than the batch size and a worker
+	// that blocks
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{blockChan: make(chan struct{})}
+	s := newTestScanner(b)
+	s.scanThreads = 10
+	s.scanBatchSize = 2
+	s.scanBatchSizeMin = 2
+	s.scanBatchSizeMax = 2
+
+	// start a scan and assert only a batch worth of scans is in flight
+	s.tryPerformHostScan(context.Background(), w, false)
+	tt := test.NewTT(t)
+	tt.Retry(100, 10*time.Millisecond, func() error {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.inFlight != 2 {
+			return fmt.Errorf("unexpected number of scans in flight, %v != 2", w.inFlight)
+		}
+		return nil
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	// unblock the worker and assert all hosts get scanned without exceeding
+	// the batch size
+	close(w.blockChan)
+	s.wg.Wait()
+	if w.scanCount != 20 {
+		t.Fatalf("unexpected number of scans, %v != 20", w.scanCount)
+	} else if w.maxInFlight != 2 {
+		t.Fatalf("unexpected max number of scans in flight, %v != 2", w.maxInFlight)
+	}
+}
+
+func TestScannerStatus(t *testing.T) {
+	// prepare 100 hosts
+	hosts := test.NewHosts(100)
+
+	// init new scanner with a worker that blocks
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{blockChan: make(chan struct{})}
+	s := newTestScanner(b)
+
+	// assert the status before scanning
+	if status := s.status(); status.Scanning || status.Queued != 0 || status.Scanned != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// start a scan and assert hosts are queued but not scanned
+	s.tryPerformHostScan(context.Background(), w, false)
+	time.Sleep(100 * time.Millisecond)
+	if status := s.status(); !status.Scanning {
+		t.Fatal("expected scanner to be scanning")
+	} else if status.Queued == 0 {
+		t.Fatal("expected hosts to be queued")
+	} else if status.Scanned != 0 {
+		t.Fatalf("unexpected number of scanned hosts, %v != 0", status.Scanned)
+	} else if status.StartTime.IsZero() {
+		t.Fatal("expected start time to be set")
+	}
+
+	// unblock the worker and wait for the scan to finish
+	close(w.blockChan)
+	s.wg.Wait()
+
+	// assert the scanned count was incremented
+	if status := s.status(); status.Scanning {
+		t.Fatal("expected scanner to be done scanning")
+	} else if status.Queued != 100 || status.Scanned != 100 {
+		t.Fatalf("unexpected status %+v", status)
+	} else if status.Offset != 80 {
+		t.Fatalf("unexpected offset, %v != 80", status.Offset)
+	}
+}
+
+func TestScannerHostTimeout(t *testing.T) {
+	s := newTestScanner(&mockBus{})
+	s.timeout = 10 * time.Second
+
+	// assert hosts without history use the current timeout
+	fast, slow, unknown := types.PublicKey{1}, types.PublicKey{2}, types.PublicKey{3}
+	if timeout := s.hostTimeout(unknown); timeout != s.timeout {
+		t.Fatalf("unexpected timeout, %v != %v", timeout, s.timeout)
+	}
+
+	// track some timings
+	for i := 0; i < trackerHostNumDataPoints; i++ {
+		s.tracker.addDataPoint(fast, 200*time.Millisecond)
+		s.tracker.addDataPoint(slow, time.Minute)
+	}
+
+	// assert the fast host gets a tighter timeout, bounded by the floor
+	if timeout := s.hostTimeout(fast); timeout != scannerHostTimeoutMin {
+		t.Fatalf("unexpected timeout, %v != %v", timeout, scannerHostTimeoutMin)
+	}
+
+	// assert the slow host's timeout doesn't exceed the current timeout
+	if timeout := s.hostTimeout(slow); timeout != s.timeout {
+		t.Fatalf("unexpected timeout, %v != %v", timeout, s.timeout)
+	}
+
+	// assert the timeout is derived from the host's timings
+	medium := types.PublicKey{4}
+	for i := 0; i < trackerHostMinDataPoints; i++ {
+		s.tracker.addDataPoint(medium, 5*time.Second)
+	}
+	if timeout := s.hostTimeout(medium); timeout != 5*time.Second {
+		t.Fatalf("unexpected timeout, %v != %v", timeout, 5*time.Second)
+	}
+	if timeout := s.hostTimeout(unknown); timeout != s.timeout {
+		t.Fatalf("unexpected timeout, %v != %v", timeout, s.timeout)
+	}
+}
+
+func TestScannerBackoff(t *testing.T) {
+	// prepare a single host
+	hosts := test.NewHosts(1)
+	hk := hosts[0].PublicKey
+
+	// init new scanner with a worker that fails all scans
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{scanError: "failed"}
+	s := newTestScanner(b)
+	s.backoffMax = time.Hour
+
+	// assert the host is scanned and backing off after a failed scan
+	if scanned := s.scanHosts(context.Background(), w, time.Now()); scanned != 1 {
+		t.Fatalf("unexpected number of scanned hosts, %v != 1", scanned)
+	} else if !s.isBackingOff(hk) {
+		t.Fatal("expected host to be backing off")
+	} else if b := s.backoffs[hk]; b.failures != 1 || time.Until(b.until) < s.scanMinInterval {
+		t.Fatalf("unexpected backoff %+v", b)
+	}
+
+	// assert the host is skipped while backing off
+	if scanned := s.scanHosts(context.Background(), w, time.Now()); scanned != 0 {
+		t.Fatalf("unexpected number of scanned hosts, %v != 0", scanned)
+	} else if w.scanCount != 1 {
+		t.Fatalf("unexpected number of scans, %v != 1", w.scanCount)
+	}
+
+	// simulate the backoff elapsing and assert the host is scanned again
+	s.backoffs[hk] = hostBackoff{failures: 1, until: time.Now()}
+	if scanned := s.scanHosts(context.Background(), w, time.Now()); scanned != 1 {
+		t.Fatalf("unexpected number of scanned hosts, %v != 1", scanned)
+	} else if b := s.backoffs[hk]; b.failures != 2 || time.Until(b.until) < 2*s.scanMinInterval {
+		t.Fatalf("unexpected backoff %+v", b)
+	}
+
+	// assert the backoff is capped
+	for i := 0; i < 10; i++ {
+		s.updateBackoff(hk, true)
+	}
+	if b := s.backoffs[hk]; time.Until(b.until) > s.backoffMax {
+		t.Fatalf("backoff exceeds max, %v > %v", time.Until(b.until), s.backoffMax)
+	}
+
+	// simulate the backoff elapsing and assert a successful scan resets it
+	s.backoffs[hk] = hostBackoff{failures: 12, until: time.Now()}
+	w.scanError = ""
+	if scanned := s.scanHosts(context.Background(), w, time.Now()); scanned != 1 {
+		t.Fatalf("unexpected number of scanned hosts, %v != 1", scanned)
+	} else if _, exists := s.backoffs[hk]; exists {
+		t.Fatal("expected backoff to be reset")
+	}
+}
+
+func TestScanHistory(t *testing.T) {
+	h := newScanHistory(3)
+	if len(h.recent()) != 0 {
+		t.Fatal("expected no results")
+	}
+
+	// fill the buffer past its capacity
+	for i := 1; i <= 5; i++ {
+		h.add(api.ScanResult{HostKey: types.PublicKey{byte(i)}})
+	}
+
+	// assert the oldest entries were evicted
+	results := h.recent()
+	if len(results) != 3 {
+		t.Fatalf("unexpected number of results, %v != 3", len(results))
+	}
+	for i, result := range results {
+		if expected := (types.PublicKey{byte(5 - i)}); result.HostKey != expected {
+			t.Fatalf("unexpected result at index %v, %v != %v", i, result.HostKey, expected)
+		}
+	}
+
+	// assert a buffer without capacity doesn't record results
+	h = newScanHistory(0)
+	h.add(api.ScanResult{})
+	if len(h.recent()) != 0 {
+		t.Fatal("expected no results")
+	}
+}
+
+func TestScannerScanOrder(t *testing.T) {
+	// prepare hosts with different last scan times, in random order
+	hosts := test.NewHosts(10)
+	for i, j := range frand.Perm(len(hosts)) {
+		hosts[i].Interactions.LastScan = time.Now().Add(-time.Duration(j+1) * time.Hour)
+	}
+
+	// init new scanner with a single thread to make the scan order
+	// deterministic
+	b := &mockBus{hosts: append([]api.Host(nil), hosts...)}
+	w := &mockWorker{}
+	s := newTestScanner(b)
+	s.scanThreads = 1
+
+	// perform a scan and wait for it to finish
+	s.tryPerformHostScan(context.Background(), w, false)
+	s.wg.Wait()
+
+	// assert the hosts were scanned in order of their last scan
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Interactions.LastScan.Before(hosts[j].Interactions.LastScan)
+	})
+	if len(w.scanned) != len(hosts) {
+		t.Fatalf("unexpected number of scans, %v != %v", len(w.scanned), len(hosts))
+	}
+	for i, h := range hosts {
+		if w.scanned[i] != h.PublicKey {
+			t.Fatalf("unexpected scan order at index %d", i)
+		}
+	}
+}
+
+func TestScannerAdaptiveBatchSize(t *testing.T) {
+	s := newTestScanner(&mockBus{})
+	s.scanBatchSizeMin = 10
+	s.scanBatchSizeMax = 100
+	s.timeout = 10 * time.Second
+
+	newBatch := func(n, failures int, ping time.Duration) (batch []scanResp) {
+		for i := 0; i < n; i++ {
+			batch = append(batch, scanResp{ping: ping, failed: i < failures})
+		}
+		return
+	}
+
+	// assert slow hosts don't increase the batch size
+	s.adjustBatchSize(newBatch(40, 0, 5*time.Second))
+	if s.batchSize() != 40 {
+		t.Fatal("unexpected batch size", s.batchSize())
+	}
+
+	// assert fast hosts increase the batch size
+	s.adjustBatchSize(newBatch(40, 0, 100*time.Millisecond))
+	if s.batchSize() != 61 {
+		t.Fatal("unexpected batch size", s.batchSize())
+	}
+
+	// assert the batch size is capped by the max
+	for i := 0; i < 10; i++ {
+		s.adjustBatchSize(newBatch(40, 0, 100*time.Millisecond))
+	}
+	if s.batchSize() != 100 {
+		t.Fatal("unexpected batch size", s.batchSize())
+	}
+
+	// assert fast hosts with a moderate failure rate keep the batch size
+	s.adjustBatchSize(newBatch(100, 20, 100*time.Millisecond))
+	if s.batchSize() != 100 {
+		t.Fatal("unexpected batch size", s.batchSize())
+	}
+
+	// assert failures halve the batch size
+	s.adjustBatchSize(newBatch(100, 50, 100*time.Millisecond))
+	if s.batchSize() != 50 {
+		t.Fatal("unexpected batch size", s.batchSize())
+	}
+
+	// assert the batch size is capped by the min
+	for i := 0; i < 10; i++ {
+		s.adjustBatchSize(newBatch(100, 50, 100*time.Millisecond))
+	}
+	if s.batchSize() != 10 {
+		t.Fatal("unexpected batch size", s.batchSize())
+	}
+}
+
 func (s *scanner) isScanning() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.scanning
 }
 
+func TestScannerIncrementalScan(t *testing.T) {
+	// prepare 10 hosts, half of which were scanned recently
+	hosts := test.NewHosts(10)
+	stale := make(map[types.PublicKey]struct{})
+	for i := range hosts {
+		if i%2 == 0 {
+			hosts[i].Interactions.LastScan = time.Now().Add(-time.Hour)
+			stale[hosts[i].PublicKey] = struct{}{}
+		} else {
+			hosts[i].Interactions.LastScan = time.Now()
+		}
+	}
+
+	b := &mockBus{hosts: hosts}
+	w := &mockWorker{}
+	s := newTestScanner(b)
+
+	// assert an incremental scan only scans the stale hosts
+	s.tryPerformIncrementalScan(context.Background(), w)
+	s.wg.Wait()
+	if len(w.scanned) != len(stale) {
+		t.Fatalf("unexpected number of scans, %v != %v", len(w.scanned), len(stale))
+	}
+	for _, hk := range w.scanned {
+		if _, ok := stale[hk]; !ok {
+			t.Fatal("unexpected host scanned", hk)
+		}
+	}
+
+	// assert a forced scan sweeps over all hosts
+	w.scanned = nil
+	s.tryPerformHostScan(context.Background(), w, true)
+	s.wg.Wait()
+	if len(w.scanned) != len(hosts) {
+		t.Fatalf("unexpected number of scans, %v != %v", len(w.scanned), len(hosts))
+	}
+}
+
+func TestScannerRescanHost(t *testing.T) {
+	hk := types.PublicKey{1}
+	w := &mockWorker{
+		settings:   test.NewHostSettings(),
+		priceTable: test.NewHostPriceTable(),
+	}
+	s := newTestScanner(&mockBus{})
+
+	// assert the scan returns the host's settings and price table
+	scan, err := s.rescanHost(context.Background(), w, hk, "host.com:9982")
+	if err != nil {
+		t.Fatal(err)
+	} else if scan.Settings.Version != w.settings.Version || scan.Settings.RemainingStorage != w.settings.RemainingStorage {
+		t.Fatal("unexpected settings", scan.Settings)
+	} else if scan.PriceTable.Validity != w.priceTable.Validity {
+		t.Fatal("unexpected price table", scan.PriceTable)
+	} else if scan.Ping != api.DurationMS(time.Millisecond) {
+		t.Fatal("unexpected latency", scan.Ping)
+	} else if scans := s.recentScans(); len(scans) != 1 || scans[0].HostKey != hk || scans[0].Error != "" {
+		t.Fatal("unexpected history", scans)
+	}
+
+	// assert a failed scan returns the scan error with the host key
+	w.scanError = "connection refused"
+	_, err = s.rescanHost(context.Background(), w, hk, "host.com:9982")
+	if err == nil || !strings.Contains(err.Error(), hk.String()) || !strings.Contains(err.Error(), w.scanError) {
+		t.Fatal("unexpected error", err)
+	} else if !s.isBackingOff(hk) {
+		t.Fatal("expected host to be backing off")
+	}
+}
+
 func newTestScanner(b *mockBus) *scanner {
 	ap := &Autopilot{}
 	ap.shutdownCtx, ap.shutdownCtxCancel = context.WithCancel(context.Background())
@@ -151,11 +681,36 @@ func newTestScanner(b *mockBus) *scanner {
 			trackerNumDataPoints,
 			trackerTimeoutPercentile,
 		),
+		history: newScanHistory(100),
 
 		interruptScanChan: make(chan struct{}),
 
-		scanBatchSize:   40,
-		scanThreads:     3,
-		scanMinInterval: time.Minute,
+		backoffs:         make(map[types.PublicKey]hostBackoff),
+		scanBatchSize:    40,
+		scanBatchSizeMin: 40,
+		scanBatchSizeMax: 40,
+		scanThreads:      3,
+		scanMinInterval:  time.Minute,
+	}
+}
+
+func TestMinRecentScanFailures(t *testing.T) {
+	tests := []struct {
+		configured uint64
+		floor      uint64
+		expected   uint64
+	}{
+		{0, 0, 0},
+		{0, 5, 5},
+		{1, 5, 5},
+		{5, 5, 5},
+		{10, 5, 10},
+		{10, 0, 10},
+	}
+	for _, test := range tests {
+		cfg := api.HostsConfig{MinRecentScanFailures: test.configured}
+		if got := minRecentScanFailures(cfg, test.floor); got != test.expected {
+			t.Fatalf("unexpected value for configured %v and floor %v, %v != %v", test.configured, test.floor, got, test.expected)
+		}
 	}
 }
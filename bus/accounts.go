@@ -215,6 +215,7 @@ func (a *account) convert() api.Account {
 		Balance:       new(big.Int).Set(a.Balance),
 		CleanShutdown: a.CleanShutdown,
 		Drift:         new(big.Int).Set(a.Drift),
+		Drifted:       a.Drifted,
 		HostKey:       a.HostKey,
 		RequiresSync:  a.RequiresSync,
 	}
@@ -241,7 +242,31 @@ func (a *accounts) Accounts() []api.Account {
 	return accounts
 }
 
-// ResetDrift resets the drift on an account.
+// MarkDrifted flags an account as drifted, drifted accounts can't be used for
+// spending until their drift is reset.
+func (a *accounts) MarkDrifted(id rhpv3.Account) error {
+	a.mu.Lock()
+	acc, exists := a.byID[id]
+	a.mu.Unlock()
+	if !exists {
+		return errAccountsNotFound
+	}
+
+	acc.mu.Lock()
+	acc.Drifted = true
+	drift := acc.Drift.String()
+	acc.mu.Unlock()
+
+	// Log drift.
+	a.logger.Warnw("account was flagged as drifted",
+		"account", acc.ID,
+		"host", acc.HostKey.String(),
+		"drift", drift)
+	return nil
+}
+
+// ResetDrift resets the drift on an account and clears its drifted flag,
+// allowing it to be used for spending again.
 func (a *accounts) ResetDrift(id rhpv3.Account) error {
 	a.mu.Lock()
 	account, exists := a.byID[id]
@@ -251,6 +276,10 @@ func (a *accounts) ResetDrift(id rhpv3.Account) error {
 	}
 	a.mu.Unlock()
 	account.resetDrift()
+
+	account.mu.Lock()
+	account.Drifted = false
+	account.mu.Unlock()
 	return nil
 }
 
@@ -267,6 +296,7 @@ func (a *accounts) ToPersist() []api.Account {
 			Balance:       new(big.Int).Set(acc.Balance),
 			CleanShutdown: acc.CleanShutdown,
 			Drift:         new(big.Int).Set(acc.Drift),
+			Drifted:       acc.Drifted,
 			HostKey:       acc.HostKey,
 			RequiresSync:  acc.RequiresSync,
 		})
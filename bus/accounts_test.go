@@ -2,6 +2,8 @@ package bus
 
 import (
 	"context"
+	"errors"
+	"math/big"
 	"testing"
 	"time"
 
@@ -85,3 +87,49 @@ func TestAccountLocking(t *testing.T) {
 		t.Fatal("should not have any locks", len(acc.locks))
 	}
 }
+
+func TestAccountDrift(t *testing.T) {
+	accounts := newAccounts(nil, zap.NewNop().Sugar())
+
+	var accountID rhpv3.Account
+	frand.Read(accountID[:])
+	var hk types.PublicKey
+	frand.Read(hk[:])
+
+	// Flagging an unknown account fails.
+	if err := accounts.MarkDrifted(accountID); !errors.Is(err, errAccountsNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// The first sync after creating the account doesn't apply drift.
+	balance := types.Siacoins(1).Big()
+	accounts.SetBalance(accountID, hk, balance)
+	if acc, _ := accounts.Account(accountID, hk); acc.Drifted || acc.Drift.Sign() != 0 {
+		t.Fatal("account shouldn't have drifted", acc.Drift)
+	}
+
+	// Syncing with a different balance applies drift but doesn't flag the
+	// account, that's up to the autopilot.
+	divergent := new(big.Int).Sub(balance, types.Siacoins(1).Div64(2).Big())
+	accounts.SetBalance(accountID, hk, divergent)
+	acc, _ := accounts.Account(accountID, hk)
+	if acc.Drifted {
+		t.Fatal("account shouldn't be flagged")
+	} else if expected := new(big.Int).Sub(divergent, balance); acc.Drift.Cmp(expected) != 0 {
+		t.Fatalf("unexpected drift %v, expected %v", acc.Drift, expected)
+	}
+
+	// Flag the account.
+	if err := accounts.MarkDrifted(accountID); err != nil {
+		t.Fatal(err)
+	} else if acc, _ := accounts.Account(accountID, hk); !acc.Drifted {
+		t.Fatal("account should be flagged")
+	}
+
+	// Resetting the drift clears the flag.
+	if err := accounts.ResetDrift(accountID); err != nil {
+		t.Fatal(err)
+	} else if acc, _ := accounts.Account(accountID, hk); acc.Drifted || acc.Drift.Sign() != 0 {
+		t.Fatal("drift wasn't reset", acc.Drift)
+	}
+}
@@ -0,0 +1,49 @@
+package bus
+
+import (
+	"context"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/alerts"
+	"go.sia.tech/renterd/api"
+)
+
+var (
+	alertAbortedUploadID = alerts.RandomAlertID() // constant until restarted
+	alertPrunedUploadID  = alerts.RandomAlertID() // constant until restarted
+)
+
+func (b *bus) registerAlert(ctx context.Context, a alerts.Alert) {
+	if err := b.alerts.RegisterAlert(ctx, a); err != nil {
+		b.logger.Errorf("failed to register alert: %v", err)
+	}
+}
+
+func newAbortedUploadAlert(uID api.UploadID, deadline time.Time) alerts.Alert {
+	return alerts.Alert{
+		ID:       types.HashBytes(append(alertAbortedUploadID[:], uID[:]...)),
+		Severity: alerts.SeverityWarning,
+		Message:  "Upload was aborted because it exceeded its deadline",
+		Data: map[string]any{
+			"uploadID": uID.String(),
+			"deadline": deadline,
+			"hint":     "The upload was not finished by its deadline, this indicates the upload got stuck.",
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+func newPrunedUploadAlert(uID api.UploadID, started time.Time) alerts.Alert {
+	return alerts.Alert{
+		ID:       types.HashBytes(append(alertPrunedUploadID[:], uID[:]...)),
+		Severity: alerts.SeverityWarning,
+		Message:  "Upload was pruned before it finished",
+		Data: map[string]any{
+			"uploadID": uID.String(),
+			"started":  started,
+			"hint":     "The upload was never marked as finished by the worker, this indicates the worker either crashed or failed to finish the upload.",
+		},
+		Timestamp: time.Now(),
+	}
+}
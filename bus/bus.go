@@ -11,6 +11,7 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"go.sia.tech/core/consensus"
@@ -23,6 +24,8 @@ import (
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/build"
 	"go.sia.tech/renterd/bus/client"
+	"go.sia.tech/renterd/internal/prometheus"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/object"
 	"go.sia.tech/renterd/wallet"
 	"go.sia.tech/renterd/webhooks"
@@ -55,6 +58,7 @@ type (
 		Subscribe(s modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error
 		Synced() bool
 		TipState() consensus.State
+		Unsubscribe(s modules.ConsensusSetSubscriber)
 	}
 
 	// A Syncer can connect to other peers and synchronize the blockchain.
@@ -69,7 +73,7 @@ type (
 	TransactionPool interface {
 		AcceptTransactionSet(txns []types.Transaction) error
 		Close() error
-		RecommendedFee() types.Currency
+		FeeEstimation() (minFee, maxFee types.Currency)
 		Subscribe(subscriber modules.TransactionPoolSubscriber)
 		Transactions() []types.Transaction
 		UnconfirmedParents(txn types.Transaction) ([]types.Transaction, error)
@@ -78,30 +82,47 @@ type (
 	// A Wallet can spend and receive siacoins.
 	Wallet interface {
 		Address() types.Address
+		AddressBalances() ([]api.WalletAddressBalance, error)
 		Balance() (spendable, confirmed, unconfirmed types.Currency, _ error)
+		CoinSelectionStrategy() api.CoinSelectionStrategy
+		Defrag(cs consensus.State, threshold, maxInputs int, feePerByte, maxFee types.Currency, pool []types.Transaction) (types.Transaction, []types.Hash256, error)
 		FundTransaction(cs consensus.State, txn *types.Transaction, amount types.Currency, useUnconfirmedTxns bool) ([]types.Hash256, error)
+		FundTransactionFromAddress(cs consensus.State, txn *types.Transaction, amount types.Currency, useUnconfirmedTxns bool, from types.Address) ([]types.Hash256, error)
 		Height() uint64
 		Redistribute(cs consensus.State, outputs int, amount, feePerByte types.Currency, pool []types.Transaction) ([]types.Transaction, []types.Hash256, error)
 		ReleaseInputs(txn ...types.Transaction)
+		SetCoinSelectionStrategy(strategy api.CoinSelectionStrategy) error
 		SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error
-		Transactions(before, since time.Time, offset, limit int) ([]wallet.Transaction, error)
+		Transactions(before, since time.Time, txnType string, marker types.TransactionID, offset, limit int) ([]wallet.Transaction, error)
 		UnspentOutputs() ([]wallet.SiacoinElement, error)
 	}
 
 	// A HostDB stores information about hosts.
 	HostDB interface {
+		modules.ConsensusSetSubscriber
+
+		ChainIndex(ctx context.Context) (types.ChainIndex, error)
 		Host(ctx context.Context, hostKey types.PublicKey) (api.Host, error)
 		HostAllowlist(ctx context.Context) ([]types.PublicKey, error)
+		HostBandwidth(ctx context.Context) ([]api.HostBandwidth, error)
 		HostBlocklist(ctx context.Context) ([]string, error)
-		HostsForScanning(ctx context.Context, maxLastScan time.Time, offset, limit int) ([]api.HostAddress, error)
+		HostOverrides(ctx context.Context) ([]api.HostOverride, error)
+		HostsForScanning(ctx context.Context, maxLastScan time.Time, sortBy, sortDir string, offset, limit int) ([]api.HostAddress, error)
+		RecordHostBandwidth(ctx context.Context, records []api.HostBandwidth) error
 		RecordHostScans(ctx context.Context, scans []api.HostScan) error
+		RecordHostOperations(ctx context.Context, records []api.HostOperationRecord) error
 		RecordPriceTables(ctx context.Context, priceTableUpdate []api.HostPriceTableUpdate) error
-		RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime time.Duration) (uint64, error)
+		HostsPendingRemoval(ctx context.Context) ([]api.HostPendingRemoval, error)
+		RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime, gracePeriod time.Duration) (uint64, error)
+		ResetConsensusSubscription(ctx context.Context) error
+		ResetHostBandwidth(ctx context.Context) error
 		ResetLostSectors(ctx context.Context, hk types.PublicKey) error
 		SearchHosts(ctx context.Context, autopilotID, filterMode, usabilityMode, addressContains string, keyIn []types.PublicKey, offset, limit int) ([]api.Host, error)
+		HostCounts(ctx context.Context) (allowed, blocked uint64, err error)
 		UpdateHostAllowlistEntries(ctx context.Context, add, remove []types.PublicKey, clear bool) error
 		UpdateHostBlocklistEntries(ctx context.Context, add, remove []string, clear bool) error
 		UpdateHostCheck(ctx context.Context, autopilotID string, hk types.PublicKey, check api.HostCheck) error
+		UpdateHostOverrides(ctx context.Context, add []api.HostOverride, remove []types.PublicKey, clear bool) error
 	}
 
 	// A MetadataStore stores information about contracts and objects.
@@ -112,17 +133,21 @@ type (
 		ArchiveContract(ctx context.Context, id types.FileContractID, reason string) error
 		ArchiveContracts(ctx context.Context, toArchive map[types.FileContractID]string) error
 		ArchiveAllContracts(ctx context.Context, reason string) error
+		ArchivedContracts(ctx context.Context, reason string) ([]api.ArchivedContract, error)
 		Contract(ctx context.Context, id types.FileContractID) (api.ContractMetadata, error)
 		Contracts(ctx context.Context, opts api.ContractsOpts) ([]api.ContractMetadata, error)
 		ContractSets(ctx context.Context) ([]string, error)
+		ContractCounts(ctx context.Context) (total uint64, sets map[string]uint64, _ error)
+		ContractSetStats(ctx context.Context, set, autopilotID string) (api.ContractSetStats, error)
 		RecordContractSpending(ctx context.Context, records []api.ContractSpendingRecord) error
 		RemoveContractSet(ctx context.Context, name string) error
 		RenewedContract(ctx context.Context, renewedFrom types.FileContractID) (api.ContractMetadata, error)
-		SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) error
+		SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) (api.ContractSetUpdateResponse, error)
 
 		ContractRoots(ctx context.Context, id types.FileContractID) ([]types.Hash256, error)
 		ContractSizes(ctx context.Context) (map[types.FileContractID]api.ContractSize, error)
 		ContractSize(ctx context.Context, id types.FileContractID) (api.ContractSize, error)
+		ExpiringContracts(ctx context.Context, height, window uint64) ([]api.ExpiringContract, error)
 
 		DeleteHostSector(ctx context.Context, hk types.PublicKey, root types.Hash256) (int, error)
 
@@ -133,7 +158,7 @@ type (
 		UpdateBucketPolicy(ctx context.Context, bucketName string, policy api.BucketPolicy) error
 
 		CopyObject(ctx context.Context, srcBucket, dstBucket, srcPath, dstPath, mimeType string, metadata api.ObjectUserMetadata) (api.ObjectMetadata, error)
-		ListObjects(ctx context.Context, bucketName, prefix, sortBy, sortDir, marker string, limit int) (api.ObjectsListResponse, error)
+		ListObjects(ctx context.Context, bucketName, prefix, delimiter, sortBy, sortDir, marker string, limit int) (api.ObjectsListResponse, error)
 		Object(ctx context.Context, bucketName, path string) (api.Object, error)
 		ObjectMetadata(ctx context.Context, bucketName, path string) (api.Object, error)
 		ObjectEntries(ctx context.Context, bucketName, path, prefix, sortBy, sortDir, marker string, offset, limit int) ([]api.ObjectMetadata, bool, error)
@@ -145,6 +170,8 @@ type (
 		RenameObjects(ctx context.Context, bucketName, from, to string, force bool) error
 		SearchObjects(ctx context.Context, bucketName, substring string, offset, limit int) ([]api.ObjectMetadata, error)
 		UpdateObject(ctx context.Context, bucketName, path, contractSet, ETag, mimeType string, metadata api.ObjectUserMetadata, o object.Object) error
+		UpdateObjectIfMatch(ctx context.Context, bucketName, path, contractSet, expectedETag, ETag, mimeType string, metadata api.ObjectUserMetadata, o object.Object) error
+		UpdateObjectMetadata(ctx context.Context, bucketName, path string, metadata api.ObjectUserMetadata) error
 
 		AbortMultipartUpload(ctx context.Context, bucketName, path string, uploadID string) (err error)
 		AddMultipartPart(ctx context.Context, bucketName, path, contractSet, eTag, uploadID string, partNumber int, slices []object.SlabSlice) (err error)
@@ -161,9 +188,13 @@ type (
 		AddPartialSlab(ctx context.Context, data []byte, minShards, totalShards uint8, contractSet string) (slabs []object.SlabSlice, bufferSize int64, err error)
 		FetchPartialSlab(ctx context.Context, key object.EncryptionKey, offset, length uint32) ([]byte, error)
 		Slab(ctx context.Context, key object.EncryptionKey) (object.Slab, error)
+		SlabLocations(ctx context.Context, key object.EncryptionKey, autopilotID string) ([]api.SlabLocation, error)
 		RefreshHealth(ctx context.Context) error
+		SlabsForEvacuation(ctx context.Context, hostKeys []types.PublicKey, limit int) ([]api.UnhealthySlab, error)
+		SlabsHealth(ctx context.Context, limit int) ([]api.SlabHealth, error)
 		UnhealthySlabs(ctx context.Context, healthCutoff float64, set string, limit int) ([]api.UnhealthySlab, error)
 		UpdateSlab(ctx context.Context, s object.Slab, contractSet string) error
+		RekeySlab(ctx context.Context, oldKey object.EncryptionKey, s object.Slab, contractSet string) error
 	}
 
 	// An AutopilotStore stores autopilots.
@@ -177,8 +208,11 @@ type (
 	SettingStore interface {
 		DeleteSetting(ctx context.Context, key string) error
 		Setting(ctx context.Context, key string) (string, error)
+		SettingHistory(ctx context.Context, key string) ([]api.SettingHistoryEntry, error)
 		Settings(ctx context.Context) ([]string, error)
 		UpdateSetting(ctx context.Context, key, value string) error
+		UpdateSettingIfVersion(ctx context.Context, key, value string, version uint64) error
+		VersionedSetting(ctx context.Context, key string) (string, uint64, error)
 	}
 
 	// EphemeralAccountStore persists information about accounts. Since accounts
@@ -214,6 +248,9 @@ type bus struct {
 	s  Syncer
 	tp TransactionPool
 
+	// maxFee caps the fee per byte, zero means no cap
+	maxFee types.Currency
+
 	as    AutopilotStore
 	eas   EphemeralAccountStore
 	hdb   HostDB
@@ -225,6 +262,18 @@ type bus struct {
 	accounts         *accounts
 	contractLocks    *contractLocks
 	uploadingSectors *uploadingSectorsCache
+	percentilePTs    percentilePriceTables
+
+	// contractExpiryWindow is the number of blocks before a contract expires
+	// within which the bus warns about it, zero disables the warnings
+	contractExpiryWindow uint64
+	expiryMu             sync.Mutex
+	expiring             []api.ExpiringContract
+	expiryErr            error
+	expiryWarned         map[types.FileContractID]struct{}
+
+	shutdownCtx       context.Context
+	shutdownCtxCancel context.CancelFunc
 
 	alerts   alerts.Alerter
 	alertMgr *alerts.Manager
@@ -234,7 +283,7 @@ type bus struct {
 
 // Handler returns an HTTP handler that serves the bus API.
 func (b *bus) Handler() http.Handler {
-	return jape.Mux(map[string]jape.Handler{
+	return tracing.Middleware(jape.Mux(map[string]jape.Handler{
 		"GET    /accounts":                 b.accountsHandlerGET,
 		"POST   /account/:id":              b.accountHandlerGET,
 		"POST   /account/:id/add":          b.accountsAddHandlerPOST,
@@ -242,6 +291,7 @@ func (b *bus) Handler() http.Handler {
 		"POST   /account/:id/unlock":       b.accountsUnlockHandlerPOST,
 		"POST   /account/:id/update":       b.accountsUpdateHandlerPOST,
 		"POST   /account/:id/requiressync": b.accountsRequiresSyncHandlerPOST,
+		"POST   /account/:id/drifted":      b.accountsDriftedHandlerPOST,
 		"POST   /account/:id/resetdrift":   b.accountsResetDriftHandlerPOST,
 
 		"GET    /alerts":          b.handleGETAlerts,
@@ -262,15 +312,18 @@ func (b *bus) Handler() http.Handler {
 
 		"POST   /consensus/acceptblock":        b.consensusAcceptBlock,
 		"GET    /consensus/network":            b.consensusNetworkHandler,
+		"POST   /consensus/resync":             b.consensusResyncHandlerPOST,
 		"GET    /consensus/siafundfee/:payout": b.contractTaxHandlerGET,
 		"GET    /consensus/state":              b.consensusStateHandler,
 
 		"GET    /contracts":              b.contractsHandlerGET,
 		"DELETE /contracts/all":          b.contractsAllHandlerDELETE,
 		"POST   /contracts/archive":      b.contractsArchiveHandlerPOST,
+		"GET    /contracts/archived":     b.contractsArchivedHandlerGET,
 		"GET    /contracts/prunable":     b.contractsPrunableDataHandlerGET,
 		"GET    /contracts/renewed/:id":  b.contractsRenewedIDHandlerGET,
 		"GET    /contracts/sets":         b.contractsSetsHandlerGET,
+		"GET    /contracts/sets/compare": b.contractsSetsCompareHandlerGET,
 		"PUT    /contracts/set/:set":     b.contractsSetHandlerPUT,
 		"DELETE /contracts/set/:set":     b.contractsSetHandlerDELETE,
 		"POST   /contracts/spending":     b.contractsSpendingHandlerPOST,
@@ -280,16 +333,27 @@ func (b *bus) Handler() http.Handler {
 		"POST   /contract/:id/acquire":   b.contractAcquireHandlerPOST,
 		"GET    /contract/:id/ancestors": b.contractIDAncestorsHandler,
 		"POST   /contract/:id/keepalive": b.contractKeepaliveHandlerPOST,
+		"GET    /contract/:id/pending":   b.contractIDPendingHandlerGET,
 		"POST   /contract/:id/renewed":   b.contractIDRenewedHandlerPOST,
 		"POST   /contract/:id/release":   b.contractReleaseHandlerPOST,
 		"GET    /contract/:id/roots":     b.contractIDRootsHandlerGET,
 		"GET    /contract/:id/size":      b.contractSizeHandlerGET,
+		"GET    /contract/:id/spending":  b.contractSpendingHandlerGET,
+
+		"GET    /health": b.healthHandlerGET,
 
 		"GET    /hosts":                          b.hostsHandlerGETDeprecated,
 		"GET    /hosts/allowlist":                b.hostsAllowlistHandlerGET,
 		"PUT    /hosts/allowlist":                b.hostsAllowlistHandlerPUT,
+		"GET    /hosts/bandwidth":                b.hostsBandwidthHandlerGET,
+		"POST   /hosts/bandwidth":                b.hostsBandwidthHandlerPOST,
+		"POST   /hosts/bandwidth/reset":          b.hostsBandwidthResetHandlerPOST,
 		"GET    /hosts/blocklist":                b.hostsBlocklistHandlerGET,
 		"PUT    /hosts/blocklist":                b.hostsBlocklistHandlerPUT,
+		"POST   /hosts/operations":               b.hostsOperationsHandlerPOST,
+		"GET    /hosts/overrides":                b.hostsOverridesHandlerGET,
+		"PUT    /hosts/overrides":                b.hostsOverridesHandlerPUT,
+		"GET    /hosts/pendingremoval":           b.hostsPendingRemovalHandlerGET,
 		"POST   /hosts/pricetables":              b.hostsPricetableHandlerPOST,
 		"POST   /hosts/remove":                   b.hostsRemoveHandlerPOST,
 		"POST   /hosts/scans":                    b.hostsScanHandlerPOST,
@@ -297,6 +361,7 @@ func (b *bus) Handler() http.Handler {
 		"GET    /host/:hostkey":                  b.hostsPubkeyHandlerGET,
 		"POST   /host/:hostkey/resetlostsectors": b.hostsResetLostSectorsPOST,
 
+		"GET    /metrics":     b.metricsPrometheusHandlerGET,
 		"PUT    /metric/:key": b.metricsHandlerPUT,
 		"GET    /metric/:key": b.metricsHandlerGET,
 		"DELETE /metric/:key": b.metricsHandlerDELETE,
@@ -309,12 +374,13 @@ func (b *bus) Handler() http.Handler {
 		"POST   /multipart/listuploads": b.multipartHandlerListUploadsPOST,
 		"POST   /multipart/listparts":   b.multipartHandlerListPartsPOST,
 
-		"GET    /objects/*path":  b.objectsHandlerGET,
-		"PUT    /objects/*path":  b.objectsHandlerPUT,
-		"DELETE /objects/*path":  b.objectsHandlerDELETE,
-		"POST   /objects/copy":   b.objectsCopyHandlerPOST,
-		"POST   /objects/rename": b.objectsRenameHandlerPOST,
-		"POST   /objects/list":   b.objectsListHandlerPOST,
+		"GET    /objects/*path":    b.objectsHandlerGET,
+		"PUT    /objects/*path":    b.objectsHandlerPUT,
+		"DELETE /objects/*path":    b.objectsHandlerDELETE,
+		"POST   /objects/copy":     b.objectsCopyHandlerPOST,
+		"POST   /objects/rename":   b.objectsRenameHandlerPOST,
+		"POST   /objects/list":     b.objectsListHandlerPOST,
+		"POST   /objects/metadata": b.objectsMetadataHandlerPOST,
 
 		"GET    /params/gouging": b.paramsHandlerGougingGET,
 		"GET    /params/upload":  b.paramsHandlerUploadGET,
@@ -328,17 +394,23 @@ func (b *bus) Handler() http.Handler {
 
 		"DELETE /sectors/:hk/:root": b.sectorsHostRootHandlerDELETE,
 
-		"GET    /settings":     b.settingsHandlerGET,
-		"GET    /setting/:key": b.settingKeyHandlerGET,
-		"PUT    /setting/:key": b.settingKeyHandlerPUT,
-		"DELETE /setting/:key": b.settingKeyHandlerDELETE,
+		"GET    /settings":             b.settingsHandlerGET,
+		"GET    /setting/:key":         b.settingKeyHandlerGET,
+		"PUT    /setting/:key":         b.settingKeyHandlerPUT,
+		"DELETE /setting/:key":         b.settingKeyHandlerDELETE,
+		"GET    /setting/:key/history": b.settingKeyHistoryHandlerGET,
+		"GET    /setting/:key/version": b.settingKeyVersionHandlerGET,
 
+		"POST   /slabs/evacuation":    b.slabsEvacuationHandlerPOST,
+		"GET    /slabs/health":        b.slabsHealthHandlerGET,
 		"POST   /slabs/migration":     b.slabsMigrationHandlerPOST,
 		"GET    /slabs/partial/:key":  b.slabsPartialHandlerGET,
 		"POST   /slabs/partial":       b.slabsPartialHandlerPOST,
 		"POST   /slabs/refreshhealth": b.slabsRefreshHealthHandlerPOST,
 		"GET    /slab/:key":           b.slabHandlerGET,
+		"GET    /slab/:key/locations": b.slabLocationsHandlerGET,
 		"GET    /slab/:key/objects":   b.slabObjectsHandlerGET,
+		"POST   /slab/:key/rekey":     b.slabRekeyHandlerPOST,
 		"PUT    /slab":                b.slabHandlerPUT,
 
 		"GET    /state":         b.stateHandlerGET,
@@ -355,9 +427,15 @@ func (b *bus) Handler() http.Handler {
 		"POST   /upload/:id":        b.uploadTrackHandlerPOST,
 		"DELETE /upload/:id":        b.uploadFinishedHandlerDELETE,
 		"POST   /upload/:id/sector": b.uploadAddSectorHandlerPOST,
+		"GET    /uploads/active":    b.uploadsActiveHandlerGET,
 
 		"GET    /wallet":               b.walletHandler,
+		"GET    /wallet/addresses":     b.walletAddressesHandler,
+		"GET    /wallet/coinselection": b.walletCoinSelectionHandlerGET,
+		"PUT    /wallet/coinselection": b.walletCoinSelectionHandlerPUT,
+		"POST   /wallet/defrag":        b.walletDefragHandler,
 		"POST   /wallet/discard":       b.walletDiscardHandler,
+		"GET    /wallet/fee-estimate":  b.walletFeeEstimateHandler,
 		"POST   /wallet/fund":          b.walletFundHandler,
 		"GET    /wallet/outputs":       b.walletOutputsHandler,
 		"GET    /wallet/pending":       b.walletPendingHandler,
@@ -371,12 +449,16 @@ func (b *bus) Handler() http.Handler {
 		"POST   /webhooks":        b.webhookHandlerPost,
 		"POST   /webhooks/action": b.webhookActionHandlerPost,
 		"POST   /webhook/delete":  b.webhookHandlerDelete,
-	})
+	}))
 }
 
 // Shutdown shuts down the bus.
 func (b *bus) Shutdown(ctx context.Context) error {
+	if b.shutdownCtxCancel != nil {
+		b.shutdownCtxCancel()
+	}
 	b.hooks.Close()
+	b.uploadingSectors.Close()
 	accounts := b.accounts.ToPersist()
 	err := b.eas.SaveAccounts(ctx, accounts)
 	if err != nil {
@@ -406,6 +488,41 @@ func (b *bus) consensusAcceptBlock(jc jape.Context) {
 	}
 }
 
+// consensusResyncHandlerPOST resets the consensus state of the store and
+// resubscribes it to the consensus set from genesis. Resyncing from an
+// arbitrary height is not supported since the store might contain contracts
+// and wallet data that reference earlier heights, for the same reason the
+// consensus checkpoint is only used for empty databases. Uploads are paused
+// until the resync has finished.
+func (b *bus) consensusResyncHandlerPOST(jc jape.Context) {
+	err := b.uploadingSectors.Pause()
+	if errors.Is(err, api.ErrUploadsInProgress) || errors.Is(err, api.ErrConsensusResyncInProgress) {
+		jc.Error(err, http.StatusConflict)
+		return
+	} else if jc.Check("failed to pause uploads", err) != nil {
+		return
+	}
+
+	// unsubscribe the store before resetting its consensus state
+	b.cm.Unsubscribe(b.hdb)
+	if err := b.hdb.ResetConsensusSubscription(jc.Request.Context()); err != nil {
+		b.uploadingSectors.Resume()
+		jc.Error(fmt.Errorf("failed to reset consensus subscription: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	// resubscribe from genesis in the background, this might take a while so
+	// the subscription is cancelled when the bus shuts down
+	go func() {
+		defer b.uploadingSectors.Resume()
+		if err := b.cm.Subscribe(b.hdb, modules.ConsensusChangeBeginning, b.shutdownCtx.Done()); err != nil {
+			b.logger.Errorf("failed to resubscribe to consensus set: %v", err)
+			return
+		}
+		b.logger.Info("consensus resync finished")
+	}()
+}
+
 func (b *bus) syncerAddrHandler(jc jape.Context) {
 	addr, err := b.s.SyncerAddress(jc.Request.Context())
 	if jc.Check("failed to fetch syncer's address", err) != nil {
@@ -429,6 +546,14 @@ func (b *bus) consensusStateHandler(jc jape.Context) {
 	jc.Encode(b.consensusState())
 }
 
+func (b *bus) healthHandlerGET(jc jape.Context) {
+	health := b.health(jc.Request.Context())
+	if !health.Healthy {
+		jc.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	}
+	jc.Encode(health)
+}
+
 func (b *bus) consensusNetworkHandler(jc jape.Context) {
 	jc.Encode(api.ConsensusNetwork{
 		Name: b.cm.TipState().Network.Name,
@@ -436,7 +561,7 @@ func (b *bus) consensusNetworkHandler(jc jape.Context) {
 }
 
 func (b *bus) txpoolFeeHandler(jc jape.Context) {
-	fee := b.tp.RecommendedFee()
+	fee := b.recommendedFee()
 	jc.Encode(fee)
 }
 
@@ -528,18 +653,36 @@ func (b *bus) walletHandler(jc jape.Context) {
 	})
 }
 
+func (b *bus) walletAddressesHandler(jc jape.Context) {
+	balances, err := b.w.AddressBalances()
+	if jc.Check("couldn't fetch address balances", err) != nil {
+		return
+	}
+	jc.Encode(balances)
+}
+
 func (b *bus) walletTransactionsHandler(jc jape.Context) {
 	var before, since time.Time
+	var txnType string
+	var marker types.TransactionID
 	offset := 0
 	limit := -1
 	if jc.DecodeForm("before", (*api.TimeRFC3339)(&before)) != nil ||
 		jc.DecodeForm("since", (*api.TimeRFC3339)(&since)) != nil ||
+		jc.DecodeForm("type", &txnType) != nil ||
+		jc.DecodeForm("marker", &marker) != nil ||
 		jc.DecodeForm("offset", &offset) != nil ||
 		jc.DecodeForm("limit", &limit) != nil {
 		return
+	} else if txnType != "" && !wallet.ValidTransactionType(txnType) {
+		jc.Error(fmt.Errorf("invalid transaction type '%v'", txnType), http.StatusBadRequest)
+		return
 	}
-	txns, err := b.w.Transactions(before, since, offset, limit)
-	if jc.Check("couldn't load transactions", err) == nil {
+	txns, err := b.w.Transactions(before, since, txnType, marker, offset, limit)
+	if errors.Is(err, wallet.ErrUnknownTransaction) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("couldn't load transactions", err) == nil {
 		jc.Encode(txns)
 	}
 }
@@ -559,11 +702,14 @@ func (b *bus) walletFundHandler(jc jape.Context) {
 	txn := wfr.Transaction
 	if len(txn.MinerFees) == 0 {
 		// if no fees are specified, we add some
-		fee := b.tp.RecommendedFee().Mul64(b.cm.TipState().TransactionWeight(txn))
+		fee := b.recommendedFee().Mul64(b.cm.TipState().TransactionWeight(txn))
 		txn.MinerFees = []types.Currency{fee}
 	}
-	toSign, err := b.w.FundTransaction(b.cm.TipState(), &txn, wfr.Amount.Add(txn.MinerFees[0]), wfr.UseUnconfirmedTxns)
-	if jc.Check("couldn't fund transaction", err) != nil {
+	toSign, err := b.w.FundTransactionFromAddress(b.cm.TipState(), &txn, wfr.Amount.Add(txn.MinerFees[0]), wfr.UseUnconfirmedTxns, wfr.Address)
+	if errors.Is(err, wallet.ErrUnknownAddress) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("couldn't fund transaction", err) != nil {
 		return
 	}
 	parents, err := b.tp.UnconfirmedParents(txn)
@@ -600,7 +746,7 @@ func (b *bus) walletRedistributeHandler(jc jape.Context) {
 	}
 
 	cs := b.cm.TipState()
-	txns, toSign, err := b.w.Redistribute(cs, wfr.Outputs, wfr.Amount, b.tp.RecommendedFee(), b.tp.Transactions())
+	txns, toSign, err := b.w.Redistribute(cs, wfr.Outputs, wfr.Amount, b.recommendedFee(), b.tp.Transactions())
 	if jc.Check("couldn't redistribute money in the wallet into the desired outputs", err) != nil {
 		return
 	}
@@ -628,6 +774,67 @@ func (b *bus) walletRedistributeHandler(jc jape.Context) {
 	jc.Encode(ids)
 }
 
+func (b *bus) walletDefragHandler(jc jape.Context) {
+	var req api.WalletDefragRequest
+	if jc.Decode(&req) != nil {
+		return
+	} else if req.MaxInputs < 2 {
+		jc.Error(errors.New("'maxInputs' has to be at least 2"), http.StatusBadRequest)
+		return
+	} else if req.MaxFee.IsZero() {
+		jc.Error(errors.New("'maxFee' has to be greater than zero"), http.StatusBadRequest)
+		return
+	}
+
+	cs := b.cm.TipState()
+	txn, toSign, err := b.w.Defrag(cs, req.Threshold, req.MaxInputs, b.recommendedFee(), req.MaxFee, b.tp.Transactions())
+	if errors.Is(err, wallet.ErrDefragFeeTooHigh) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("couldn't defrag the wallet", err) != nil {
+		return
+	} else if len(toSign) == 0 {
+		jc.Encode(api.WalletDefragResponse{})
+		return
+	}
+
+	err = b.w.SignTransaction(cs, &txn, toSign, types.CoveredFields{WholeTransaction: true})
+	if jc.Check("couldn't sign the transaction", err) != nil {
+		b.w.ReleaseInputs(txn)
+		return
+	}
+	if jc.Check("couldn't broadcast the transaction", b.tp.AcceptTransactionSet([]types.Transaction{txn})) != nil {
+		b.w.ReleaseInputs(txn)
+		return
+	}
+
+	jc.Encode(api.WalletDefragResponse{
+		ID:     txn.ID(),
+		Fee:    txn.MinerFees[0],
+		Inputs: len(txn.SiacoinInputs),
+	})
+}
+
+func (b *bus) walletFeeEstimateHandler(jc jape.Context) {
+	jc.Encode(b.feeEstimate())
+}
+
+func (b *bus) walletCoinSelectionHandlerGET(jc jape.Context) {
+	jc.Encode(api.WalletCoinSelectionResponse{Strategy: b.w.CoinSelectionStrategy()})
+}
+
+func (b *bus) walletCoinSelectionHandlerPUT(jc jape.Context) {
+	var req api.WalletCoinSelectionRequest
+	if jc.Decode(&req) != nil {
+		return
+	} else if err := b.w.SetCoinSelectionStrategy(req.Strategy); errors.Is(err, api.ErrInvalidCoinSelectionStrategy) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("failed to update coin selection strategy", err) != nil {
+		return
+	}
+}
+
 func (b *bus) walletDiscardHandler(jc jape.Context) {
 	var txn types.Transaction
 	if jc.Decode(&txn) == nil {
@@ -655,7 +862,7 @@ func (b *bus) walletPrepareFormHandler(jc jape.Context) {
 	txn := types.Transaction{
 		FileContracts: []types.FileContract{fc},
 	}
-	txn.MinerFees = []types.Currency{b.tp.RecommendedFee().Mul64(cs.TransactionWeight(txn))}
+	txn.MinerFees = []types.Currency{b.recommendedFee().Mul64(cs.TransactionWeight(txn))}
 	toSign, err := b.w.FundTransaction(cs, &txn, cost.Add(txn.MinerFees[0]), true)
 	if jc.Check("couldn't fund transaction", err) != nil {
 		return
@@ -787,6 +994,14 @@ func (b *bus) searchHostsHandlerPOST(jc jape.Context) {
 	jc.Encode(hosts)
 }
 
+func (b *bus) hostsPendingRemovalHandlerGET(jc jape.Context) {
+	pending, err := b.hdb.HostsPendingRemoval(jc.Request.Context())
+	if jc.Check("couldn't fetch hosts pending removal", err) != nil {
+		return
+	}
+	jc.Encode(pending)
+}
+
 func (b *bus) hostsRemoveHandlerPOST(jc jape.Context) {
 	var hrr api.HostsRemoveRequest
 	if jc.Decode(&hrr) != nil {
@@ -800,10 +1015,17 @@ func (b *bus) hostsRemoveHandlerPOST(jc jape.Context) {
 		jc.Error(errors.New("minRecentScanFailures must be non-zero"), http.StatusBadRequest)
 		return
 	}
-	removed, err := b.hdb.RemoveOfflineHosts(jc.Request.Context(), hrr.MinRecentScanFailures, time.Duration(hrr.MaxDowntimeHours))
+	removed, err := b.hdb.RemoveOfflineHosts(jc.Request.Context(), hrr.MinRecentScanFailures, time.Duration(hrr.MaxDowntimeHours), time.Duration(hrr.GracePeriodHours))
 	if jc.Check("couldn't remove offline hosts", err) != nil {
 		return
 	}
+	if removed > 0 {
+		b.broadcastAction(webhooks.Event{
+			Module:  api.WebhookModuleHost,
+			Event:   api.WebhookEventHostRemoved,
+			Payload: api.EventHostsRemoved{Removed: removed},
+		})
+	}
 	jc.Encode(removed)
 }
 
@@ -811,11 +1033,17 @@ func (b *bus) hostsScanningHandlerGET(jc jape.Context) {
 	offset := 0
 	limit := -1
 	maxLastScan := time.Now()
+	var sortBy, sortDir string
 	if jc.DecodeForm("offset", &offset) != nil || jc.DecodeForm("limit", &limit) != nil || jc.DecodeForm("lastScan", (*api.TimeRFC3339)(&maxLastScan)) != nil {
 		return
+	} else if jc.DecodeForm("sortBy", &sortBy) != nil || jc.DecodeForm("sortDir", &sortDir) != nil {
+		return
 	}
-	hosts, err := b.hdb.HostsForScanning(jc.Request.Context(), maxLastScan, offset, limit)
-	if jc.Check(fmt.Sprintf("couldn't fetch hosts %d-%d", offset, offset+limit), err) != nil {
+	hosts, err := b.hdb.HostsForScanning(jc.Request.Context(), maxLastScan, sortBy, sortDir, offset, limit)
+	if errors.Is(err, api.ErrInvalidHostSortParameters) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check(fmt.Sprintf("couldn't fetch hosts %d-%d", offset, offset+limit), err) != nil {
 		return
 	}
 	jc.Encode(hosts)
@@ -853,6 +1081,37 @@ func (b *bus) hostsScanHandlerPOST(jc jape.Context) {
 	}
 }
 
+func (b *bus) hostsOperationsHandlerPOST(jc jape.Context) {
+	var req api.HostsOperationsRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	if jc.Check("failed to record operations", b.hdb.RecordHostOperations(jc.Request.Context(), req.Operations)) != nil {
+		return
+	}
+}
+
+func (b *bus) hostsBandwidthHandlerGET(jc jape.Context) {
+	bandwidth, err := b.hdb.HostBandwidth(jc.Request.Context())
+	if jc.Check("failed to fetch host bandwidth", err) == nil {
+		jc.Encode(bandwidth)
+	}
+}
+
+func (b *bus) hostsBandwidthHandlerPOST(jc jape.Context) {
+	var req api.HostsBandwidthRequest
+	if jc.Decode(&req) != nil {
+		return
+	}
+	if jc.Check("failed to record bandwidth", b.hdb.RecordHostBandwidth(jc.Request.Context(), req.Bandwidth)) != nil {
+		return
+	}
+}
+
+func (b *bus) hostsBandwidthResetHandlerPOST(jc jape.Context) {
+	jc.Check("failed to reset host bandwidth", b.hdb.ResetHostBandwidth(jc.Request.Context()))
+}
+
 func (b *bus) hostsPricetableHandlerPOST(jc jape.Context) {
 	var req api.HostsPriceTablesRequest
 	if jc.Decode(&req) != nil {
@@ -893,6 +1152,31 @@ func (b *bus) hostsAllowlistHandlerPUT(jc jape.Context) {
 	}
 }
 
+func (b *bus) hostsOverridesHandlerGET(jc jape.Context) {
+	overrides, err := b.hdb.HostOverrides(jc.Request.Context())
+	if jc.Check("couldn't load host overrides", err) == nil {
+		jc.Encode(overrides)
+	}
+}
+
+func (b *bus) hostsOverridesHandlerPUT(jc jape.Context) {
+	ctx := jc.Request.Context()
+	var req api.UpdateHostOverridesRequest
+	if jc.Decode(&req) == nil {
+		if len(req.Add)+len(req.Remove) > 0 && req.Clear {
+			jc.Error(errors.New("cannot add or remove overrides while clearing them"), http.StatusBadRequest)
+			return
+		}
+		for _, o := range req.Add {
+			if err := o.Validate(); err != nil {
+				jc.Error(err, http.StatusBadRequest)
+				return
+			}
+		}
+		jc.Check("couldn't update host overrides", b.hdb.UpdateHostOverrides(ctx, req.Add, req.Remove, req.Clear))
+	}
+}
+
 func (b *bus) hostsBlocklistHandlerGET(jc jape.Context) {
 	blocklist, err := b.hdb.HostBlocklist(jc.Request.Context())
 	if jc.Check("couldn't load blocklist", err) == nil {
@@ -938,13 +1222,28 @@ func (b *bus) contractsRenewedIDHandlerGET(jc jape.Context) {
 	}
 }
 
+func (b *bus) contractsArchivedHandlerGET(jc jape.Context) {
+	var reason string
+	if jc.DecodeForm("reason", &reason) != nil {
+		return
+	}
+	contracts, err := b.ms.ArchivedContracts(jc.Request.Context(), reason)
+	if jc.Check("failed to fetch archived contracts", err) != nil {
+		return
+	}
+	jc.Encode(contracts)
+}
+
 func (b *bus) contractsArchiveHandlerPOST(jc jape.Context) {
 	var toArchive api.ContractsArchiveRequest
 	if jc.Decode(&toArchive) != nil {
 		return
 	}
 
-	jc.Check("failed to archive contracts", b.ms.ArchiveContracts(jc.Request.Context(), toArchive))
+	if jc.Check("failed to archive contracts", b.ms.ArchiveContracts(jc.Request.Context(), toArchive)) != nil {
+		return
+	}
+	b.broadcastContractsArchived(toArchive)
 }
 
 func (b *bus) contractsSetsHandlerGET(jc jape.Context) {
@@ -954,12 +1253,54 @@ func (b *bus) contractsSetsHandlerGET(jc jape.Context) {
 	}
 }
 
+func (b *bus) contractsSetsCompareHandlerGET(jc jape.Context) {
+	var setA, setB, autopilotID string
+	if jc.DecodeForm("a", &setA) != nil || jc.DecodeForm("b", &setB) != nil || jc.DecodeForm("autopilot", &autopilotID) != nil {
+		return
+	} else if setA == "" || setB == "" {
+		jc.Error(errors.New("query parameters 'a' and 'b' can not be empty"), http.StatusBadRequest)
+		return
+	}
+
+	var rs api.RedundancySettings
+	if jc.Check("failed to fetch redundancy settings", b.fetchSetting(jc.Request.Context(), api.SettingRedundancy, &rs)) != nil {
+		return
+	}
+
+	var resp api.ContractSetsCompareResponse
+	for _, s := range []struct {
+		name  string
+		stats *api.ContractSetStats
+	}{
+		{setA, &resp.A},
+		{setB, &resp.B},
+	} {
+		stats, err := b.ms.ContractSetStats(jc.Request.Context(), s.name, autopilotID)
+		if errors.Is(err, api.ErrContractSetNotFound) {
+			jc.Error(fmt.Errorf("%w: %v", err, s.name), http.StatusNotFound)
+			return
+		} else if jc.Check("failed to fetch contract set stats", err) != nil {
+			return
+		}
+
+		// the redundancy is capped by the number of hosts in the set
+		if rs.MinShards > 0 {
+			stats.MaxRedundancy = float64(stats.Hosts) / float64(rs.MinShards)
+		}
+		*s.stats = stats
+	}
+	jc.Encode(resp)
+}
+
 func (b *bus) contractsSetHandlerPUT(jc jape.Context) {
 	var contractIds []types.FileContractID
 	if set := jc.PathParam("set"); set == "" {
 		jc.Error(errors.New("path parameter 'set' can not be empty"), http.StatusBadRequest)
-	} else if jc.Decode(&contractIds) == nil {
-		jc.Check("could not add contracts to set", b.ms.SetContractSet(jc.Request.Context(), set, contractIds))
+		return
+	} else if jc.Decode(&contractIds) != nil {
+		return
+	} else if resp, err := b.ms.SetContractSet(jc.Request.Context(), set, contractIds); jc.Check("could not add contracts to set", err) == nil {
+		jc.Encode(resp)
 	}
 }
 
@@ -1101,6 +1442,24 @@ func (b *bus) contractIDHandlerGET(jc jape.Context) {
 	}
 }
 
+func (b *bus) contractSpendingHandlerGET(jc jape.Context) {
+	var id types.FileContractID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	c, err := b.ms.Contract(jc.Request.Context(), id)
+	if errors.Is(err, api.ErrContractNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if jc.Check("couldn't load contract", err) != nil {
+		return
+	}
+	jc.Encode(api.ContractSpendingResponse{
+		ContractSpending: c.Spending,
+		Total:            c.Spending.Total(),
+	})
+}
+
 func (b *bus) contractIDHandlerPOST(jc jape.Context) {
 	var id types.FileContractID
 	var req api.ContractAddRequest
@@ -1117,9 +1476,15 @@ func (b *bus) contractIDHandlerPOST(jc jape.Context) {
 	}
 
 	a, err := b.ms.AddContract(jc.Request.Context(), req.Contract, req.ContractPrice, req.TotalCost, req.StartHeight, req.State)
-	if jc.Check("couldn't store contract", err) == nil {
-		jc.Encode(a)
+	if jc.Check("couldn't store contract", err) != nil {
+		return
 	}
+	b.broadcastAction(webhooks.Event{
+		Module:  api.WebhookModuleContract,
+		Event:   api.WebhookEventContractFormed,
+		Payload: a,
+	})
+	jc.Encode(a)
 }
 
 func (b *bus) contractIDRenewedHandlerPOST(jc jape.Context) {
@@ -1141,6 +1506,14 @@ func (b *bus) contractIDRenewedHandlerPOST(jc jape.Context) {
 	}
 	r, err := b.ms.AddRenewedContract(jc.Request.Context(), req.Contract, req.ContractPrice, req.TotalCost, req.StartHeight, req.RenewedFrom, req.State)
 	if jc.Check("couldn't store contract", err) == nil {
+		b.broadcastAction(webhooks.Event{
+			Module: api.WebhookModuleContract,
+			Event:  api.WebhookEventContractRenewed,
+			Payload: api.EventContractRenewed{
+				Renewal:     r,
+				RenewedFrom: req.RenewedFrom,
+			},
+		})
 		jc.Encode(r)
 	}
 	b.uploadingSectors.HandleRenewal(req.Contract.ID(), req.RenewedFrom)
@@ -1161,16 +1534,47 @@ func (b *bus) contractIDRootsHandlerGET(jc jape.Context) {
 	}
 }
 
+func (b *bus) contractIDPendingHandlerGET(jc jape.Context) {
+	var id types.FileContractID
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+
+	// the cache resolves renewals, so a renewed contract reports the pending
+	// sectors of its parent too
+	roots := b.uploadingSectors.Sectors(id)
+	jc.Encode(api.ContractPendingResponse{
+		Size:  uint64(len(roots)) * rhpv2.SectorSize,
+		Roots: roots,
+	})
+}
+
 func (b *bus) contractIDHandlerDELETE(jc jape.Context) {
 	var id types.FileContractID
 	if jc.DecodeParam("id", &id) != nil {
 		return
 	}
-	jc.Check("couldn't remove contract", b.ms.ArchiveContract(jc.Request.Context(), id, api.ContractArchivalReasonRemoved))
+	if jc.Check("couldn't remove contract", b.ms.ArchiveContract(jc.Request.Context(), id, api.ContractArchivalReasonRemoved)) != nil {
+		return
+	}
+	b.broadcastContractsArchived(map[types.FileContractID]string{id: api.ContractArchivalReasonRemoved})
 }
 
 func (b *bus) contractsAllHandlerDELETE(jc jape.Context) {
-	jc.Check("couldn't remove contracts", b.ms.ArchiveAllContracts(jc.Request.Context(), api.ContractArchivalReasonRemoved))
+	// fetch the contracts first so we know which ones were archived
+	contracts, err := b.ms.Contracts(jc.Request.Context(), api.ContractsOpts{})
+	if jc.Check("couldn't fetch contracts", err) != nil {
+		return
+	}
+	if jc.Check("couldn't remove contracts", b.ms.ArchiveAllContracts(jc.Request.Context(), api.ContractArchivalReasonRemoved)) != nil {
+		return
+	}
+
+	archived := make(map[types.FileContractID]string)
+	for _, c := range contracts {
+		archived[c.ID] = api.ContractArchivalReasonRemoved
+	}
+	b.broadcastContractsArchived(archived)
 }
 
 func (b *bus) searchObjectsHandlerGET(jc jape.Context) {
@@ -1277,13 +1681,34 @@ func (b *bus) objectsHandlerPUT(jc jape.Context) {
 	} else if aor.Bucket == "" {
 		aor.Bucket = api.DefaultBucketName
 	}
-	jc.Check("couldn't store object", b.ms.UpdateObject(jc.Request.Context(), aor.Bucket, jc.PathParam("path"), aor.ContractSet, aor.ETag, aor.MimeType, aor.Metadata, aor.Object))
+	if err := aor.Metadata.Validate(); err != nil {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if aor.ExpectedETag != "" {
+		err = b.ms.UpdateObjectIfMatch(jc.Request.Context(), aor.Bucket, jc.PathParam("path"), aor.ContractSet, aor.ExpectedETag, aor.ETag, aor.MimeType, aor.Metadata, aor.Object)
+	} else {
+		err = b.ms.UpdateObject(jc.Request.Context(), aor.Bucket, jc.PathParam("path"), aor.ContractSet, aor.ETag, aor.MimeType, aor.Metadata, aor.Object)
+	}
+	if errors.Is(err, api.ErrObjectNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if errors.Is(err, api.ErrObjectModified) {
+		jc.Error(err, http.StatusPreconditionFailed)
+		return
+	}
+	jc.Check("couldn't store object", err)
 }
 
 func (b *bus) objectsCopyHandlerPOST(jc jape.Context) {
 	var orr api.CopyObjectsRequest
 	if jc.Decode(&orr) != nil {
 		return
+	} else if err := orr.Metadata.Validate(); err != nil {
+		jc.Error(err, http.StatusBadRequest)
+		return
 	}
 	om, err := b.ms.CopyObject(jc.Request.Context(), orr.SourceBucket, orr.DestinationBucket, orr.SourcePath, orr.DestinationPath, orr.MimeType, orr.Metadata)
 	if jc.Check("couldn't copy object", err) != nil {
@@ -1303,13 +1728,35 @@ func (b *bus) objectsListHandlerPOST(jc jape.Context) {
 	if req.Bucket == "" {
 		req.Bucket = api.DefaultBucketName
 	}
-	resp, err := b.ms.ListObjects(jc.Request.Context(), req.Bucket, req.Prefix, req.SortBy, req.SortDir, req.Marker, req.Limit)
-	if jc.Check("couldn't list objects", err) != nil {
+	resp, err := b.ms.ListObjects(jc.Request.Context(), req.Bucket, req.Prefix, req.Delimiter, req.SortBy, req.SortDir, req.Marker, req.Limit)
+	if errors.Is(err, api.ErrInvalidObjectSortParameters) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if jc.Check("couldn't list objects", err) != nil {
 		return
 	}
 	jc.Encode(resp)
 }
 
+func (b *bus) objectsMetadataHandlerPOST(jc jape.Context) {
+	var req api.ObjectsMetadataRequest
+	if jc.Decode(&req) != nil {
+		return
+	} else if req.Bucket == "" {
+		req.Bucket = api.DefaultBucketName
+	}
+	if err := req.Metadata.Validate(); err != nil {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	}
+	err := b.ms.UpdateObjectMetadata(jc.Request.Context(), req.Bucket, req.Path, req.Metadata)
+	if errors.Is(err, api.ErrObjectNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	}
+	jc.Check("couldn't update object metadata", err)
+}
+
 func (b *bus) objectsRenameHandlerPOST(jc jape.Context) {
 	var orr api.ObjectsRenameRequest
 	if jc.Decode(&orr) != nil {
@@ -1411,7 +1858,17 @@ func (b *bus) packedSlabsHandlerDonePOST(jc jape.Context) {
 	if jc.Decode(&psrp) != nil {
 		return
 	}
-	jc.Check("failed to mark packed slab(s) as uploaded", b.ms.MarkPackedSlabsUploaded(jc.Request.Context(), psrp.Slabs))
+
+	var shards []object.Sector
+	for _, slab := range psrp.Slabs {
+		shards = append(shards, slab.Shards...)
+	}
+	ctx, span := tracing.StartSpan(jc.Request.Context(), "bus.MarkPackedSlabsUploaded",
+		tracing.AttributeContractID.StringSlice(shardContractIDs(shards)),
+	)
+	err := b.ms.MarkPackedSlabsUploaded(ctx, psrp.Slabs)
+	tracing.EndSpan(span, err)
+	jc.Check("failed to mark packed slab(s) as uploaded", err)
 }
 
 func (b *bus) sectorsHostRootHandlerDELETE(jc jape.Context) {
@@ -1430,6 +1887,23 @@ func (b *bus) sectorsHostRootHandlerDELETE(jc jape.Context) {
 	}
 }
 
+// shardContractIDs returns the ids of all contracts the given shards are
+// stored on.
+func shardContractIDs(shards []object.Sector) (fcids []string) {
+	seen := make(map[types.FileContractID]struct{})
+	for _, shard := range shards {
+		for _, ids := range shard.Contracts {
+			for _, fcid := range ids {
+				if _, ok := seen[fcid]; !ok {
+					seen[fcid] = struct{}{}
+					fcids = append(fcids, fcid.String())
+				}
+			}
+		}
+	}
+	return
+}
+
 func (b *bus) slabObjectsHandlerGET(jc jape.Context) {
 	var key object.EncryptionKey
 	if jc.DecodeParam("key", &key) != nil {
@@ -1446,6 +1920,25 @@ func (b *bus) slabObjectsHandlerGET(jc jape.Context) {
 	jc.Encode(objects)
 }
 
+func (b *bus) slabLocationsHandlerGET(jc jape.Context) {
+	var key object.EncryptionKey
+	if jc.DecodeParam("key", &key) != nil {
+		return
+	}
+	var autopilotID string
+	if jc.DecodeForm("autopilotID", &autopilotID) != nil {
+		return
+	}
+	locations, err := b.ms.SlabLocations(jc.Request.Context(), key, autopilotID)
+	if errors.Is(err, api.ErrSlabNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if jc.Check("failed to fetch slab locations", err) != nil {
+		return
+	}
+	jc.Encode(locations)
+}
+
 func (b *bus) slabHandlerGET(jc jape.Context) {
 	var key object.EncryptionKey
 	if jc.DecodeParam("key", &key) != nil {
@@ -1464,15 +1957,69 @@ func (b *bus) slabHandlerGET(jc jape.Context) {
 
 func (b *bus) slabHandlerPUT(jc jape.Context) {
 	var usr api.UpdateSlabRequest
-	if jc.Decode(&usr) == nil {
-		jc.Check("couldn't update slab", b.ms.UpdateSlab(jc.Request.Context(), usr.Slab, usr.ContractSet))
+	if jc.Decode(&usr) != nil {
+		return
+	}
+
+	ctx, span := tracing.StartSpan(jc.Request.Context(), "bus.UpdateSlab",
+		tracing.AttributeSlabKey.String(usr.Slab.Key.String()),
+		tracing.AttributeContractID.StringSlice(shardContractIDs(usr.Slab.Shards)),
+	)
+	err := b.ms.UpdateSlab(ctx, usr.Slab, usr.ContractSet)
+	tracing.EndSpan(span, err)
+	jc.Check("couldn't update slab", err)
+}
+
+func (b *bus) slabRekeyHandlerPOST(jc jape.Context) {
+	var key object.EncryptionKey
+	if jc.DecodeParam("key", &key) != nil {
+		return
+	}
+	var usr api.UpdateSlabRequest
+	if jc.Decode(&usr) != nil {
+		return
+	}
+
+	ctx, span := tracing.StartSpan(jc.Request.Context(), "bus.RekeySlab",
+		tracing.AttributeSlabKey.String(key.String()),
+		tracing.AttributeContractID.StringSlice(shardContractIDs(usr.Slab.Shards)),
+	)
+	err := b.ms.RekeySlab(ctx, key, usr.Slab, usr.ContractSet)
+	tracing.EndSpan(span, err)
+	if errors.Is(err, api.ErrSlabNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
 	}
+	jc.Check("couldn't rekey slab", err)
 }
 
 func (b *bus) slabsRefreshHealthHandlerPOST(jc jape.Context) {
 	jc.Check("failed to recompute health", b.ms.RefreshHealth(jc.Request.Context()))
 }
 
+func (b *bus) slabsEvacuationHandlerPOST(jc jape.Context) {
+	var esr api.EvacuationSlabsRequest
+	if jc.Decode(&esr) == nil {
+		if slabs, err := b.ms.SlabsForEvacuation(jc.Request.Context(), esr.HostKeys, esr.Limit); jc.Check("couldn't fetch slabs for evacuation", err) == nil {
+			jc.Encode(api.UnhealthySlabsResponse{
+				Slabs: slabs,
+			})
+		}
+	}
+}
+
+func (b *bus) slabsHealthHandlerGET(jc jape.Context) {
+	limit := -1
+	if jc.DecodeForm("limit", &limit) != nil {
+		return
+	}
+	slabs, err := b.ms.SlabsHealth(jc.Request.Context(), limit)
+	if jc.Check("couldn't fetch slab health", err) != nil {
+		return
+	}
+	jc.Encode(slabs)
+}
+
 func (b *bus) slabsMigrationHandlerPOST(jc jape.Context) {
 	var msr api.MigrationSlabsRequest
 	if jc.Decode(&msr) == nil {
@@ -1543,7 +2090,10 @@ func (b *bus) slabsPartialHandlerPOST(jc jape.Context) {
 	if jc.Check("failed to read request body", err) != nil {
 		return
 	}
-	slabs, bufferSize, err := b.ms.AddPartialSlab(jc.Request.Context(), data, uint8(minShards), uint8(totalShards), contractSet)
+
+	ctx, span := tracing.StartSpan(jc.Request.Context(), "bus.AddPartialSlab")
+	slabs, bufferSize, err := b.ms.AddPartialSlab(ctx, data, uint8(minShards), uint8(totalShards), contractSet)
+	tracing.EndSpan(span, err)
 	if jc.Check("failed to add partial slab", err) != nil {
 		return
 	}
@@ -1598,6 +2148,11 @@ func (b *bus) settingKeyHandlerPUT(jc jape.Context) {
 		return
 	}
 
+	var version uint64
+	if jc.DecodeForm("version", &version) != nil {
+		return
+	}
+
 	var value interface{}
 	if jc.Decode(&value) != nil {
 		return
@@ -1609,37 +2164,40 @@ func (b *bus) settingKeyHandlerPUT(jc jape.Context) {
 		return
 	}
 
-	switch key {
-	case api.SettingGouging:
-		var gs api.GougingSettings
-		if err := json.Unmarshal(data, &gs); err != nil {
-			jc.Error(fmt.Errorf("couldn't update gouging settings, invalid request body, %t", value), http.StatusBadRequest)
-			return
-		} else if err := gs.Validate(); err != nil {
-			jc.Error(fmt.Errorf("couldn't update gouging settings, error: %v", err), http.StatusBadRequest)
-			return
-		}
-	case api.SettingRedundancy:
-		var rs api.RedundancySettings
-		if err := json.Unmarshal(data, &rs); err != nil {
-			jc.Error(fmt.Errorf("couldn't update redundancy settings, invalid request body"), http.StatusBadRequest)
-			return
-		} else if err := rs.Validate(); err != nil {
-			jc.Error(fmt.Errorf("couldn't update redundancy settings, error: %v", err), http.StatusBadRequest)
-			return
-		}
-	case api.SettingS3Authentication:
-		var s3as api.S3AuthenticationSettings
-		if err := json.Unmarshal(data, &s3as); err != nil {
-			jc.Error(fmt.Errorf("couldn't update s3 authentication settings, invalid request body"), http.StatusBadRequest)
-			return
-		} else if err := s3as.Validate(); err != nil {
-			jc.Error(fmt.Errorf("couldn't update s3 authentication settings, error: %v", err), http.StatusBadRequest)
-			return
-		}
+	// only update the setting if the version matches when a version is given
+	if jc.Request.URL.Query().Has("version") {
+		err = b.ss.UpdateSettingIfVersion(jc.Request.Context(), key, string(data), version)
+	} else {
+		err = b.ss.UpdateSetting(jc.Request.Context(), key, string(data))
+	}
+	if errors.Is(err, api.ErrInvalidSetting) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if errors.Is(err, api.ErrSettingConflict) {
+		jc.Error(err, http.StatusConflict)
+		return
+	}
+	jc.Check("could not update setting", err)
+}
+
+func (b *bus) settingKeyVersionHandlerGET(jc jape.Context) {
+	key := jc.PathParam("key")
+	if key == "" {
+		jc.Error(errors.New("path parameter 'key' can not be empty"), http.StatusBadRequest)
+		return
 	}
 
-	jc.Check("could not update setting", b.ss.UpdateSetting(jc.Request.Context(), key, string(data)))
+	value, version, err := b.ss.VersionedSetting(jc.Request.Context(), key)
+	if errors.Is(err, api.ErrSettingNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if jc.Check("could not fetch setting", err) != nil {
+		return
+	}
+	jc.Encode(api.VersionedSetting{
+		Value:   json.RawMessage(value),
+		Version: version,
+	})
 }
 
 func (b *bus) settingKeyHandlerDELETE(jc jape.Context) {
@@ -1651,6 +2209,18 @@ func (b *bus) settingKeyHandlerDELETE(jc jape.Context) {
 	jc.Check("could not delete setting", b.ss.DeleteSetting(jc.Request.Context(), key))
 }
 
+func (b *bus) settingKeyHistoryHandlerGET(jc jape.Context) {
+	key := jc.PathParam("key")
+	if key == "" {
+		jc.Error(errors.New("path parameter 'key' can not be empty"), http.StatusBadRequest)
+		return
+	}
+	history, err := b.ss.SettingHistory(jc.Request.Context(), key)
+	if jc.Check("could not fetch setting history", err) == nil {
+		jc.Encode(history)
+	}
+}
+
 func (b *bus) contractIDAncestorsHandler(jc jape.Context) {
 	var fcid types.FileContractID
 	if jc.DecodeParam("id", &fcid) != nil {
@@ -1722,6 +2292,10 @@ func (b *bus) gougingParams(ctx context.Context) (api.GougingParams, error) {
 	} else if err := json.Unmarshal([]byte(gss), &gs); err != nil {
 		b.logger.Panicf("failed to unmarshal gouging settings '%s': %v", gss, err)
 	}
+	gs, err := b.applyPricePercentile(ctx, gs)
+	if err != nil {
+		return api.GougingParams{}, fmt.Errorf("failed to derive percentile gouging limits: %w", err)
+	}
 
 	var rs api.RedundancySettings
 	if rss, err := b.ss.Setting(ctx, api.SettingRedundancy); err != nil {
@@ -1736,7 +2310,7 @@ func (b *bus) gougingParams(ctx context.Context) (api.GougingParams, error) {
 		ConsensusState:     cs,
 		GougingSettings:    gs,
 		RedundancySettings: rs,
-		TransactionFee:     b.tp.RecommendedFee(),
+		TransactionFee:     b.recommendedFee(),
 	}, nil
 }
 
@@ -1832,6 +2406,21 @@ func (b *bus) accountsAddHandlerPOST(jc jape.Context) {
 	b.accounts.AddAmount(id, req.HostKey, req.Amount)
 }
 
+func (b *bus) accountsDriftedHandlerPOST(jc jape.Context) {
+	var id rhpv3.Account
+	if jc.DecodeParam("id", &id) != nil {
+		return
+	}
+	err := b.accounts.MarkDrifted(id)
+	if errors.Is(err, errAccountsNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	}
+	if jc.Check("failed to mark account as drifted", err) != nil {
+		return
+	}
+}
+
 func (b *bus) accountsResetDriftHandlerPOST(jc jape.Context) {
 	var id rhpv3.Account
 	if jc.DecodeParam("id", &id) != nil {
@@ -2016,9 +2605,36 @@ func (b *bus) stateHandlerGET(jc jape.Context) {
 
 func (b *bus) uploadTrackHandlerPOST(jc jape.Context) {
 	var id api.UploadID
-	if jc.DecodeParam("id", &id) == nil {
-		jc.Check("failed to track upload", b.uploadingSectors.StartUpload(id))
+	if jc.DecodeParam("id", &id) != nil {
+		return
 	}
+	// NOTE: older workers don't send a request body, their uploads are
+	// tracked with the default priority
+	var req api.UploadTrackRequest
+	if jc.Request.ContentLength != 0 && jc.Decode(&req) != nil {
+		return
+	}
+	jc.Check("failed to track upload", b.uploadingSectors.StartUpload(id, req.Priority, req.Deadline.Std()))
+}
+
+func (b *bus) uploadsActiveHandlerGET(jc jape.Context) {
+	jc.Encode(b.uploadingSectors.OngoingUploads())
+}
+
+func (b *bus) handleAbortedUpload(uID api.UploadID, deadline time.Time) {
+	b.logger.Warnw("aborted upload that exceeded its deadline", "uploadID", uID, "deadline", deadline)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	b.registerAlert(ctx, newAbortedUploadAlert(uID, deadline))
+}
+
+func (b *bus) handlePrunedUpload(uID api.UploadID, started time.Time) {
+	b.logger.Warnw("pruned expired upload", "uploadID", uID, "started", started)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	b.registerAlert(ctx, newPrunedUploadAlert(uID, started))
 }
 
 func (b *bus) uploadAddSectorHandlerPOST(jc jape.Context) {
@@ -2035,9 +2651,34 @@ func (b *bus) uploadAddSectorHandlerPOST(jc jape.Context) {
 
 func (b *bus) uploadFinishedHandlerDELETE(jc jape.Context) {
 	var id api.UploadID
-	if jc.DecodeParam("id", &id) == nil {
-		b.uploadingSectors.FinishUpload(id)
+	if jc.DecodeParam("id", &id) != nil {
+		return
 	}
+
+	// an unknown upload indicates a bug in the worker, but since there's
+	// nothing left to clean up we log it rather than fail the request
+	if err := b.uploadingSectors.FinishUpload(id); err != nil {
+		b.logger.Warnf("failed to finish upload: %v", err)
+	}
+}
+
+// broadcastAction broadcasts the given event to all matching webhooks.
+func (b *bus) broadcastAction(e webhooks.Event) {
+	if err := b.hooks.BroadcastAction(context.Background(), e); err != nil {
+		b.logger.Errorf("failed to broadcast %v event: %v", e, err)
+	}
+}
+
+// broadcastContractsArchived broadcasts a contract.archived event.
+func (b *bus) broadcastContractsArchived(archived map[types.FileContractID]string) {
+	if len(archived) == 0 {
+		return
+	}
+	b.broadcastAction(webhooks.Event{
+		Module:  api.WebhookModuleContract,
+		Event:   api.WebhookEventContractArchived,
+		Payload: api.EventContractArchived{Contracts: archived},
+	})
 }
 
 func (b *bus) webhookActionHandlerPost(jc jape.Context) {
@@ -2107,6 +2748,25 @@ func (b *bus) metricsHandlerDELETE(jc jape.Context) {
 	}
 }
 
+func (b *bus) metricsPrometheusHandlerGET(jc jape.Context) {
+	jc.Custom(nil, "")
+
+	metrics, err := b.prometheusMetrics(jc.Request.Context())
+	if jc.Check("failed to collect prometheus metrics", err) != nil {
+		return
+	}
+	for _, c := range []prometheus.Collector{
+		b.uploadingSectors,
+	} {
+		metrics = append(metrics, c.PrometheusMetrics()...)
+	}
+
+	jc.ResponseWriter.Header().Set("Content-Type", prometheus.ContentType)
+	if err := prometheus.Encode(jc.ResponseWriter, metrics); err != nil {
+		b.logger.Errorf("failed to encode prometheus metrics: %v", err)
+	}
+}
+
 func (b *bus) metricsHandlerPUT(jc jape.Context) {
 	jc.Custom((*interface{})(nil), nil)
 
@@ -2241,6 +2901,9 @@ func (b *bus) multipartHandlerCreatePOST(jc jape.Context) {
 	var req api.MultipartCreateRequest
 	if jc.Decode(&req) != nil {
 		return
+	} else if err := req.Metadata.Validate(); err != nil {
+		jc.Error(err, http.StatusBadRequest)
+		return
 	}
 
 	var key object.EncryptionKey
@@ -2342,29 +3005,91 @@ func (b *bus) multipartHandlerListPartsPOST(jc jape.Context) {
 	jc.Encode(resp)
 }
 
+type (
+	// An Option configures optional parameters of the bus.
+	Option func(*options)
+
+	options struct {
+		contractExpiryWindow uint64
+		maxFee               types.Currency
+		uploadingSectorsOpts []uploadingSectorsCacheOption
+	}
+)
+
+// WithContractExpiryWarningWindow sets the number of blocks before a contract
+// expires within which the bus warns about it, if it still stores referenced
+// sectors, through its health endpoint and webhooks. Defaults to one week, a
+// window of zero disables the warnings.
+func WithContractExpiryWarningWindow(blocks uint64) Option {
+	return func(o *options) {
+		o.contractExpiryWindow = blocks
+	}
+}
+
+// WithMaxFee caps the fee per byte the bus estimates and pays for
+// transactions, protecting the wallet against runaway fee markets. A cap of
+// zero means no cap.
+func WithMaxFee(maxFee types.Currency) Option {
+	return func(o *options) {
+		o.maxFee = maxFee
+	}
+}
+
+// WithUploadingSectorsCacheExpiry sets the amount of time after which an
+// ongoing upload is pruned from the uploading sectors cache, defaults to 24h.
+func WithUploadingSectorsCacheExpiry(expiry time.Duration) Option {
+	return func(o *options) {
+		o.uploadingSectorsOpts = append(o.uploadingSectorsOpts, withCacheExpiry(expiry))
+	}
+}
+
+// WithUploadingSectorsMaxCachedRoots limits the number of sector roots the
+// uploading sectors cache tracks, when exceeded the oldest uploads are evicted.
+func WithUploadingSectorsMaxCachedRoots(n int) Option {
+	return func(o *options) {
+		o.uploadingSectorsOpts = append(o.uploadingSectorsOpts, withMaxCachedRoots(n))
+	}
+}
+
 // New returns a new Bus.
-func New(s Syncer, am *alerts.Manager, hm *webhooks.Manager, cm ChainManager, tp TransactionPool, w Wallet, hdb HostDB, as AutopilotStore, ms MetadataStore, ss SettingStore, eas EphemeralAccountStore, mtrcs MetricsStore, l *zap.Logger) (*bus, error) {
+func New(s Syncer, am *alerts.Manager, hm *webhooks.Manager, cm ChainManager, tp TransactionPool, w Wallet, hdb HostDB, as AutopilotStore, ms MetadataStore, ss SettingStore, eas EphemeralAccountStore, mtrcs MetricsStore, l *zap.Logger, opts ...Option) (*bus, error) {
+	o := options{contractExpiryWindow: defaultContractExpiryWarningWindow}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	b := &bus{
-		alerts:           alerts.WithOrigin(am, "bus"),
-		alertMgr:         am,
-		hooks:            hm,
-		s:                s,
-		cm:               cm,
-		tp:               tp,
-		w:                w,
-		hdb:              hdb,
-		as:               as,
-		ms:               ms,
-		mtrcs:            mtrcs,
-		ss:               ss,
-		eas:              eas,
-		contractLocks:    newContractLocks(),
-		uploadingSectors: newUploadingSectorsCache(),
-		logger:           l.Sugar().Named("bus"),
+		alerts:        alerts.WithOrigin(am, "bus"),
+		alertMgr:      am,
+		hooks:         hm,
+		s:             s,
+		cm:            cm,
+		tp:            tp,
+		w:             w,
+		hdb:           hdb,
+		as:            as,
+		ms:            ms,
+		mtrcs:         mtrcs,
+		ss:            ss,
+		eas:           eas,
+		contractLocks: newContractLocks(),
+		maxFee:        o.maxFee,
+		logger:        l.Sugar().Named("bus"),
+
+		contractExpiryWindow: o.contractExpiryWindow,
+		expiryWarned:         make(map[types.FileContractID]struct{}),
 
 		startTime: time.Now(),
 	}
 
+	// create the uploading sectors cache, expired uploads indicate a worker
+	// that never finished its upload so we register an alert for those
+	var err error
+	b.uploadingSectors, err = newUploadingSectorsCache(b.logger, append(o.uploadingSectorsOpts, withAbortCallback(b.handleAbortedUpload), withPruneCallback(b.handlePrunedUpload))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create uploading sectors cache: %w", err)
+	}
+
 	// ensure we don't hang indefinitely
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
@@ -2446,5 +3171,11 @@ func New(s Syncer, am *alerts.Manager, hm *webhooks.Manager, cm ChainManager, tp
 	if err := eas.SetUncleanShutdown(ctx); err != nil {
 		return nil, fmt.Errorf("failed to mark account shutdown as unclean: %w", err)
 	}
+
+	// start warning about contracts that are about to expire
+	b.shutdownCtx, b.shutdownCtxCancel = context.WithCancel(context.Background())
+	if b.contractExpiryWindow > 0 {
+		go b.contractExpiryLoop()
+	}
 	return b, nil
 }
@@ -45,6 +45,12 @@ func (c *Client) LockAccount(ctx context.Context, id rhpv3.Account, hostKey type
 	return resp.Account, resp.LockID, err
 }
 
+// MarkDrifted flags an account as drifted.
+func (c *Client) MarkDrifted(ctx context.Context, id rhpv3.Account) (err error) {
+	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/account/%s/drifted", id), nil, nil)
+	return
+}
+
 // ResetDrift resets the drift of an account to zero.
 func (c *Client) ResetDrift(ctx context.Context, id rhpv3.Account) (err error) {
 	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/account/%s/resetdrift", id), nil, nil)
@@ -32,6 +32,13 @@ func (c *Client) ConsensusState(ctx context.Context) (resp api.ConsensusState, e
 	return
 }
 
+// ConsensusResync resets the consensus state of the bus and resyncs it from
+// genesis, it fails if there are uploads in progress.
+func (c *Client) ConsensusResync(ctx context.Context) (err error) {
+	err = c.c.WithContext(ctx).POST("/consensus/resync", nil, nil)
+	return
+}
+
 // FileContractTax asks the bus for the siafund fee that has to be paid for a
 // contract with a given payout.
 func (c *Client) FileContractTax(ctx context.Context, payout types.Currency) (tax types.Currency, err error) {
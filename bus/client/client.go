@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
@@ -23,6 +24,13 @@ func New(addr, password string) *Client {
 	}}
 }
 
+// Health returns the health of the bus's subsystems, it returns an error if
+// any of the critical subsystems is unhealthy.
+func (c *Client) Health(ctx context.Context) (health api.BusHealthResponse, err error) {
+	err = c.c.WithContext(ctx).GET("/health", &health)
+	return
+}
+
 // State returns the current state of the bus.
 func (c *Client) State() (state api.BusStateResponse, err error) {
 	err = c.c.GET("/state", &state)
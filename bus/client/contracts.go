@@ -1,8 +1,13 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -62,6 +67,17 @@ func (c *Client) ArchiveContracts(ctx context.Context, toArchive map[types.FileC
 	return
 }
 
+// ArchivedContracts returns the archived contracts, optionally filtered by
+// archival reason.
+func (c *Client) ArchivedContracts(ctx context.Context, reason string) (contracts []api.ArchivedContract, err error) {
+	values := url.Values{}
+	if reason != "" {
+		values.Set("reason", reason)
+	}
+	err = c.c.WithContext(ctx).GET("/contracts/archived?"+values.Encode(), &contracts)
+	return
+}
+
 // Contract returns the contract with the given ID.
 func (c *Client) Contract(ctx context.Context, id types.FileContractID) (contract api.ContractMetadata, err error) {
 	err = c.c.WithContext(ctx).GET(fmt.Sprintf("/contract/%s", id), &contract)
@@ -90,6 +106,13 @@ func (c *Client) ContractSize(ctx context.Context, contractID types.FileContract
 	return
 }
 
+// ContractSpending returns the cumulative spending of the contract with given
+// id, broken down by category.
+func (c *Client) ContractSpending(ctx context.Context, contractID types.FileContractID) (resp api.ContractSpendingResponse, err error) {
+	err = c.c.WithContext(ctx).GET(fmt.Sprintf("/contract/%s/spending", contractID), &resp)
+	return
+}
+
 // Contracts retrieves contracts from the metadata store. If no filter is set,
 // all contracts are returned.
 func (c *Client) Contracts(ctx context.Context, opts api.ContractsOpts) (contracts []api.ContractMetadata, err error) {
@@ -140,6 +163,13 @@ func (c *Client) KeepaliveContract(ctx context.Context, contractID types.FileCon
 	return
 }
 
+// PendingSectors returns the size and roots of the sectors that are currently
+// being uploaded to the contract with given id.
+func (c *Client) PendingSectors(ctx context.Context, contractID types.FileContractID) (resp api.ContractPendingResponse, err error) {
+	err = c.c.WithContext(ctx).GET(fmt.Sprintf("/contract/%s/pending", contractID), &resp)
+	return
+}
+
 // PrunableData returns an overview of all contract sizes, the total size and
 // the amount of data that can be pruned.
 func (c *Client) PrunableData(ctx context.Context) (prunableData api.ContractsPrunableDataResponse, err error) {
@@ -167,8 +197,46 @@ func (c *Client) ReleaseContract(ctx context.Context, contractID types.FileContr
 	return
 }
 
-// SetContractSet adds the given contracts to the given set.
-func (c *Client) SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) (err error) {
-	err = c.c.WithContext(ctx).PUT(fmt.Sprintf("/contracts/set/%s", set), contracts)
+// CompareContractSets returns aggregate metrics for the given contract sets.
+// The average host score is computed using the host checks of the given
+// autopilot.
+func (c *Client) CompareContractSets(ctx context.Context, setA, setB, autopilotID string) (resp api.ContractSetsCompareResponse, err error) {
+	values := url.Values{}
+	values.Set("a", setA)
+	values.Set("b", setB)
+	if autopilotID != "" {
+		values.Set("autopilot", autopilotID)
+	}
+	err = c.c.WithContext(ctx).GET("/contracts/sets/compare?"+values.Encode(), &resp)
+	return
+}
+
+// SetContractSet replaces the contracts in the given set, it returns the ids of
+// the contracts that were added to and removed from the set.
+func (c *Client) SetContractSet(ctx context.Context, set string, contracts []types.FileContractID) (resp api.ContractSetUpdateResponse, err error) {
+	c.c.Custom("PUT", fmt.Sprintf("/contracts/set/%s", set), []types.FileContractID{}, &api.ContractSetUpdateResponse{})
+
+	// NOTE: jape's PUT doesn't decode a response so we perform the request
+	// ourselves
+	js, err := json.Marshal(contracts)
+	if err != nil {
+		return api.ContractSetUpdateResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", fmt.Sprintf("%s/contracts/set/%s", c.c.BaseURL, set), bytes.NewReader(js))
+	if err != nil {
+		panic(err)
+	}
+	req.SetBasicAuth("", c.c.WithContext(ctx).Password)
+	r, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return api.ContractSetUpdateResponse{}, err
+	}
+	defer io.Copy(io.Discard, r.Body)
+	defer r.Body.Close()
+	if r.StatusCode != 200 {
+		err, _ := io.ReadAll(r.Body)
+		return api.ContractSetUpdateResponse{}, errors.New(string(err))
+	}
+	err = json.NewDecoder(r.Body).Decode(&resp)
 	return
 }
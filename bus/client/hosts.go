@@ -22,6 +22,13 @@ func (c *Client) HostAllowlist(ctx context.Context) (allowlist []types.PublicKey
 	return
 }
 
+// HostBandwidth returns the number of bytes uploaded to and downloaded from
+// every host since the bandwidth counters were last reset.
+func (c *Client) HostBandwidth(ctx context.Context) (bandwidth []api.HostBandwidth, err error) {
+	err = c.c.WithContext(ctx).GET("/hosts/bandwidth", &bandwidth)
+	return
+}
+
 // HostBlocklist returns a host blocklist.
 func (c *Client) HostBlocklist(ctx context.Context) (blocklist []string, err error) {
 	err = c.c.WithContext(ctx).GET("/hosts/blocklist", &blocklist)
@@ -53,6 +60,23 @@ func (c *Client) RecordHostScans(ctx context.Context, scans []api.HostScan) (err
 	return
 }
 
+// RecordHostBandwidth adds the given number of transferred bytes to the
+// bandwidth counters of the given hosts.
+func (c *Client) RecordHostBandwidth(ctx context.Context, records []api.HostBandwidth) (err error) {
+	err = c.c.WithContext(ctx).POST("/hosts/bandwidth", api.HostsBandwidthRequest{
+		Bandwidth: records,
+	}, nil)
+	return
+}
+
+// RecordHostOperations records the outcome of operations performed on hosts.
+func (c *Client) RecordHostOperations(ctx context.Context, records []api.HostOperationRecord) (err error) {
+	err = c.c.WithContext(ctx).POST("/hosts/operations", api.HostsOperationsRequest{
+		Operations: records,
+	}, nil)
+	return
+}
+
 // RecordHostInteraction records an interaction for the supplied host.
 func (c *Client) RecordPriceTables(ctx context.Context, priceTableUpdates []api.HostPriceTableUpdate) (err error) {
 	err = c.c.WithContext(ctx).POST("/hosts/pricetables", api.HostsPriceTablesRequest{
@@ -61,15 +85,37 @@ func (c *Client) RecordPriceTables(ctx context.Context, priceTableUpdates []api.
 	return
 }
 
-// RemoveOfflineHosts removes all hosts that have been offline for longer than the given max downtime.
-func (c *Client) RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime time.Duration) (removed uint64, err error) {
+// HostOverrides returns the host overrides.
+func (c *Client) HostOverrides(ctx context.Context) (overrides []api.HostOverride, err error) {
+	err = c.c.WithContext(ctx).GET("/hosts/overrides", &overrides)
+	return
+}
+
+// HostsPendingRemoval returns the hosts that are pending removal.
+func (c *Client) HostsPendingRemoval(ctx context.Context) (pending []api.HostPendingRemoval, err error) {
+	err = c.c.WithContext(ctx).GET("/hosts/pendingremoval", &pending)
+	return
+}
+
+// RemoveOfflineHosts removes all hosts that have been offline for longer than
+// the given max downtime. If a grace period is given, hosts are marked as
+// pending removal first and only removed if they are still offline once the
+// grace period has passed.
+func (c *Client) RemoveOfflineHosts(ctx context.Context, minRecentScanFailures uint64, maxDowntime, gracePeriod time.Duration) (removed uint64, err error) {
 	err = c.c.WithContext(ctx).POST("/hosts/remove", api.HostsRemoveRequest{
 		MaxDowntimeHours:      api.DurationH(maxDowntime),
 		MinRecentScanFailures: minRecentScanFailures,
+		GracePeriodHours:      api.DurationH(gracePeriod),
 	}, &removed)
 	return
 }
 
+// ResetHostBandwidth resets the bandwidth counters of all hosts.
+func (c *Client) ResetHostBandwidth(ctx context.Context) (err error) {
+	err = c.c.WithContext(ctx).POST("/hosts/bandwidth/reset", nil, nil)
+	return
+}
+
 // ResetLostSectors resets the lost sector count for a host.
 func (c *Client) ResetLostSectors(ctx context.Context, hostKey types.PublicKey) (err error) {
 	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/host/%s/resetlostsectors", hostKey), nil, nil)
@@ -102,6 +148,13 @@ func (c *Client) UpdateHostBlocklist(ctx context.Context, add, remove []string,
 	return
 }
 
+// UpdateHostOverrides updates the host overrides, adding or replacing and
+// removing the given overrides.
+func (c *Client) UpdateHostOverrides(ctx context.Context, add []api.HostOverride, remove []types.PublicKey, clear bool) (err error) {
+	err = c.c.WithContext(ctx).PUT("/hosts/overrides", api.UpdateHostOverridesRequest{Add: add, Remove: remove, Clear: clear})
+	return
+}
+
 // UpdateHostCheck updates the host with the most recent check performed by the
 // autopilot with given id.
 func (c *Client) UpdateHostCheck(ctx context.Context, autopilotID string, hostKey types.PublicKey, hostCheck api.HostCheck) (err error) {
@@ -19,6 +19,8 @@ func (c *Client) AddObject(ctx context.Context, bucket, path, contractSet string
 		ETag:        opts.ETag,
 		MimeType:    opts.MimeType,
 		Metadata:    opts.Metadata,
+
+		ExpectedETag: opts.ExpectedETag,
 	})
 	return
 }
@@ -52,10 +54,11 @@ func (c *Client) DeleteObject(ctx context.Context, bucket, path string, opts api
 // ListOBjects lists objects in the given bucket.
 func (c *Client) ListObjects(ctx context.Context, bucket string, opts api.ListObjectOptions) (resp api.ObjectsListResponse, err error) {
 	err = c.c.WithContext(ctx).POST("/objects/list", api.ObjectsListRequest{
-		Bucket: bucket,
-		Limit:  opts.Limit,
-		Prefix: opts.Prefix,
-		Marker: opts.Marker,
+		Bucket:    bucket,
+		Limit:     opts.Limit,
+		Prefix:    opts.Prefix,
+		Delimiter: opts.Delimiter,
+		Marker:    opts.Marker,
 	}, &resp)
 	return
 }
@@ -111,6 +114,17 @@ func (c *Client) SearchObjects(ctx context.Context, bucket string, opts api.Sear
 	return
 }
 
+// UpdateObjectMetadata replaces the user-defined metadata of the object at the
+// given path.
+func (c *Client) UpdateObjectMetadata(ctx context.Context, bucket, path string, metadata api.ObjectUserMetadata) (err error) {
+	err = c.c.WithContext(ctx).POST("/objects/metadata", api.ObjectsMetadataRequest{
+		Bucket:   bucket,
+		Path:     path,
+		Metadata: metadata,
+	}, nil)
+	return
+}
+
 func (c *Client) renameObjects(ctx context.Context, bucket, from, to, mode string, force bool) (err error) {
 	err = c.c.WithContext(ctx).POST("/objects/rename", api.ObjectsRenameRequest{
 		Bucket: bucket,
@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"go.sia.tech/renterd/api"
@@ -42,6 +43,24 @@ func (c *Client) Setting(ctx context.Context, key string, value interface{}) (er
 	return
 }
 
+// SettingHistory returns all changes made to the setting with given key.
+func (c *Client) SettingHistory(ctx context.Context, key string) (history []api.SettingHistoryEntry, err error) {
+	err = c.c.WithContext(ctx).GET(fmt.Sprintf("/setting/%s/history", key), &history)
+	return
+}
+
+// VersionedSetting decodes the setting with given key into value and returns
+// the setting's current version.
+func (c *Client) VersionedSetting(ctx context.Context, key string, value interface{}) (version uint64, err error) {
+	var vs api.VersionedSetting
+	if err = c.c.WithContext(ctx).GET(fmt.Sprintf("/setting/%s/version", key), &vs); err != nil {
+		return
+	} else if err = json.Unmarshal(vs.Value, value); err != nil {
+		return
+	}
+	return vs.Version, nil
+}
+
 // Settings returns the keys of all settings.
 func (c *Client) Settings(ctx context.Context) (settings []string, err error) {
 	err = c.c.WithContext(ctx).GET("/settings", &settings)
@@ -53,6 +72,13 @@ func (c *Client) UpdateSetting(ctx context.Context, key string, value interface{
 	return c.c.WithContext(ctx).PUT(fmt.Sprintf("/setting/%s", key), value)
 }
 
+// UpdateSettingIfVersion will update the given setting under the given key,
+// but only if the setting's current version matches the given version. A
+// version of 0 indicates the setting must not exist yet.
+func (c *Client) UpdateSettingIfVersion(ctx context.Context, key string, value interface{}, version uint64) error {
+	return c.c.WithContext(ctx).PUT(fmt.Sprintf("/setting/%s?version=%d", key, version), value)
+}
+
 // UploadPackingSettings returns the upload packing settings.
 func (c *Client) UploadPackingSettings(ctx context.Context) (ups api.UploadPackingSettings, err error) {
 	err = c.Setting(ctx, api.SettingUploadPacking, &ups)
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"time"
 
+	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/object"
 )
@@ -112,12 +113,35 @@ func (c *Client) Slab(ctx context.Context, key object.EncryptionKey) (slab objec
 	return
 }
 
+// SlabLocations returns the locations of the shards of the slab with the given
+// key, a location is usable if its host is usable for the given autopilot. If
+// no autopilot is given, the host has to be usable for all autopilots.
+func (c *Client) SlabLocations(ctx context.Context, key object.EncryptionKey, autopilotID string) (locations []api.SlabLocation, err error) {
+	values := url.Values{}
+	if autopilotID != "" {
+		values.Set("autopilotID", autopilotID)
+	}
+	err = c.c.WithContext(ctx).GET(fmt.Sprintf("/slab/%s/locations?%s", key, values.Encode()), &locations)
+	return
+}
+
 // SlabBuffers returns information about the number of objects and their size.
 func (c *Client) SlabBuffers() (buffers []api.SlabBuffer, err error) {
 	err = c.c.GET("/slabbuffers", &buffers)
 	return
 }
 
+// SlabsForEvacuation returns up to 'limit' slabs which have sectors stored on
+// any of the given hosts.
+func (c *Client) SlabsForEvacuation(ctx context.Context, hostKeys []types.PublicKey, limit int) (slabs []api.UnhealthySlab, err error) {
+	var usr api.UnhealthySlabsResponse
+	err = c.c.WithContext(ctx).POST("/slabs/evacuation", api.EvacuationSlabsRequest{HostKeys: hostKeys, Limit: limit}, &usr)
+	if err != nil {
+		return
+	}
+	return usr.Slabs, nil
+}
+
 // SlabsForMigration returns up to 'limit' slabs which require migration. A slab
 // needs to be migrated if it has sectors on contracts that are not part of the
 // given 'set'.
@@ -130,6 +154,25 @@ func (c *Client) SlabsForMigration(ctx context.Context, healthCutoff float64, se
 	return usr.Slabs, nil
 }
 
+// SlabsHealth returns up to 'limit' slabs that don't reach full redundancy on
+// usable contracts, sorted by severity.
+func (c *Client) SlabsHealth(ctx context.Context, limit int) (slabs []api.SlabHealth, err error) {
+	values := url.Values{}
+	values.Set("limit", fmt.Sprint(limit))
+	err = c.c.WithContext(ctx).GET("/slabs/health?"+values.Encode(), &slabs)
+	return
+}
+
+// RekeySlab replaces the slab with given key by the given slab, which contains
+// the same data encrypted with a new key.
+func (c *Client) RekeySlab(ctx context.Context, oldKey object.EncryptionKey, slab object.Slab, contractSet string) (err error) {
+	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/slab/%s/rekey", oldKey), api.UpdateSlabRequest{
+		ContractSet: contractSet,
+		Slab:        slab,
+	}, nil)
+	return
+}
+
 // UpdateSlab updates the given slab in the database.
 func (c *Client) UpdateSlab(ctx context.Context, slab object.Slab, contractSet string) (err error) {
 	err = c.c.WithContext(ctx).PUT("/slab", api.UpdateSlabRequest{
@@ -3,11 +3,19 @@ package client
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
 )
 
+// ActiveUploads returns the uploads that are currently being tracked by the
+// bus.
+func (c *Client) ActiveUploads(ctx context.Context) (uploads []api.ActiveUpload, err error) {
+	err = c.c.WithContext(ctx).GET("/uploads/active", &uploads)
+	return
+}
+
 // AddUploadingSector adds the given sector to the upload with given id.
 func (c *Client) AddUploadingSector(ctx context.Context, uID api.UploadID, id types.FileContractID, root types.Hash256) (err error) {
 	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/upload/%s/sector", uID), api.UploadSectorRequest{
@@ -23,8 +31,12 @@ func (c *Client) FinishUpload(ctx context.Context, uID api.UploadID) (err error)
 	return
 }
 
-// TrackUpload tracks the upload with given id in the bus.
-func (c *Client) TrackUpload(ctx context.Context, uID api.UploadID) (err error) {
-	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/upload/%s", uID), nil, nil)
+// TrackUpload tracks the upload with given id and priority in the bus. If a
+// deadline is given, the bus aborts the upload if it's not finished by then.
+func (c *Client) TrackUpload(ctx context.Context, uID api.UploadID, priority int, deadline time.Time) (err error) {
+	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/upload/%s", uID), api.UploadTrackRequest{
+		Deadline: api.TimeRFC3339(deadline),
+		Priority: priority,
+	}, nil)
 	return
 }
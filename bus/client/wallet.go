@@ -44,12 +44,51 @@ func (c *Client) Wallet(ctx context.Context) (resp api.WalletResponse, err error
 	return
 }
 
+// WalletAddresses returns the balance of every address managed by the wallet.
+func (c *Client) WalletAddresses(ctx context.Context) (resp []api.WalletAddressBalance, err error) {
+	err = c.c.WithContext(ctx).GET("/wallet/addresses", &resp)
+	return
+}
+
+// WalletCoinSelection returns the strategy the wallet uses to select the
+// outputs that fund a transaction.
+func (c *Client) WalletCoinSelection(ctx context.Context) (strategy api.CoinSelectionStrategy, err error) {
+	var resp api.WalletCoinSelectionResponse
+	err = c.c.WithContext(ctx).GET("/wallet/coinselection", &resp)
+	return resp.Strategy, err
+}
+
+// UpdateWalletCoinSelection updates the strategy the wallet uses to select the
+// outputs that fund a transaction.
+func (c *Client) UpdateWalletCoinSelection(ctx context.Context, strategy api.CoinSelectionStrategy) error {
+	return c.c.WithContext(ctx).PUT("/wallet/coinselection", api.WalletCoinSelectionRequest{Strategy: strategy})
+}
+
+// WalletDefrag broadcasts a transaction that consolidates up to maxInputs of the
+// wallet's smallest outputs into a single output if the wallet has more than
+// threshold outputs. The transaction's fee doesn't exceed maxFee.
+func (c *Client) WalletDefrag(ctx context.Context, threshold, maxInputs int, maxFee types.Currency) (resp api.WalletDefragResponse, err error) {
+	err = c.c.WithContext(ctx).POST("/wallet/defrag", api.WalletDefragRequest{
+		MaxFee:    maxFee,
+		MaxInputs: maxInputs,
+		Threshold: threshold,
+	}, &resp)
+	return
+}
+
 // WalletDiscard discards the provided txn, make its inputs usable again. This
 // should only be called on transactions that will never be broadcast.
 func (c *Client) WalletDiscard(ctx context.Context, txn types.Transaction) error {
 	return c.c.WithContext(ctx).POST("/wallet/discard", txn, nil)
 }
 
+// WalletFeeEstimate returns the recommended fees per byte for fast, standard
+// and slow confirmation of a transaction.
+func (c *Client) WalletFeeEstimate(ctx context.Context) (resp api.FeeEstimate, err error) {
+	err = c.c.WithContext(ctx).GET("/wallet/fee-estimate", &resp)
+	return
+}
+
 // WalletFund funds txn using inputs controlled by the wallet.
 func (c *Client) WalletFund(ctx context.Context, txn *types.Transaction, amount types.Currency, useUnconfirmedTransactions bool) ([]types.Hash256, []types.Transaction, error) {
 	req := api.WalletFundRequest{
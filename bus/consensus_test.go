@@ -0,0 +1,112 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/bus/client"
+	"go.sia.tech/siad/modules"
+	"go.uber.org/zap"
+)
+
+type mockResyncChainManager struct {
+	ChainManager
+	subscribed chan modules.ConsensusChangeID
+	cancel     <-chan struct{}
+}
+
+func (cm *mockResyncChainManager) Subscribe(_ modules.ConsensusSetSubscriber, ccID modules.ConsensusChangeID, cancel <-chan struct{}) error {
+	cm.cancel = cancel
+	cm.subscribed <- ccID
+	return nil
+}
+
+func (cm *mockResyncChainManager) Unsubscribe(_ modules.ConsensusSetSubscriber) {}
+
+type mockResyncHostDB struct {
+	HostDB
+	height uint64
+}
+
+func (hdb *mockResyncHostDB) ChainIndex(_ context.Context) (types.ChainIndex, error) {
+	return types.ChainIndex{Height: hdb.height}, nil
+}
+
+func (hdb *mockResyncHostDB) ResetConsensusSubscription(_ context.Context) error {
+	hdb.height = 0
+	return nil
+}
+
+func TestConsensusResync(t *testing.T) {
+	usc, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := &mockResyncChainManager{subscribed: make(chan modules.ConsensusChangeID, 1)}
+	hdb := &mockResyncHostDB{height: 100}
+	b := &bus{
+		cm:               cm,
+		hdb:              hdb,
+		uploadingSectors: usc,
+		logger:           zap.NewNop().Sugar(),
+	}
+	b.shutdownCtx, b.shutdownCtxCancel = context.WithCancel(context.Background())
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+	c := client.New(srv.URL, "")
+
+	// assert the resync is refused while an upload is in progress
+	uID := api.NewUploadID()
+	if err := usc.StartUpload(uID, 0, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if err := c.ConsensusResync(context.Background()); err == nil || !strings.Contains(err.Error(), api.ErrUploadsInProgress.Error()) {
+		t.Fatalf("expected resync to fail with %v, got %v", api.ErrUploadsInProgress, err)
+	} else if hdb.height != 100 {
+		t.Fatalf("unexpected height %v", hdb.height)
+	} else if err := usc.FinishUpload(uID); err != nil {
+		t.Fatal(err)
+	}
+
+	// resync and assert the stored height was reset
+	if err := c.ConsensusResync(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if ci, err := hdb.ChainIndex(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if ci.Height != 0 {
+		t.Fatalf("expected height to be reset, got %v", ci.Height)
+	}
+
+	// assert the store was resubscribed from the beginning
+	select {
+	case ccID := <-cm.subscribed:
+		if ccID != modules.ConsensusChangeBeginning {
+			t.Fatalf("unexpected consensus change id %v", ccID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("store wasn't resubscribed")
+	}
+
+	// assert the subscription is cancelled when the bus shuts down
+	b.shutdownCtxCancel()
+	select {
+	case <-cm.cancel:
+	default:
+		t.Fatal("expected subscription to be cancelled")
+	}
+
+	// assert uploads are allowed again once the resync finished
+	for i := 0; ; i++ {
+		if err := usc.StartUpload(api.NewUploadID(), 0, time.Time{}); err == nil {
+			break
+		} else if !errors.Is(err, api.ErrConsensusResyncInProgress) || i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -3,12 +3,14 @@ package bus
 import (
 	"context"
 	"errors"
+	"net/http/httptest"
 	"sort"
 	"sync"
 	"testing"
 	"time"
 
 	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/bus/client"
 )
 
 // TestContractAcquire is a unit test for contractLocks.Acquire.
@@ -204,3 +206,38 @@ func TestContractRelease(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestContractLockAPI verifies a contract lock can be acquired, extended and
+// released through the bus API.
+func TestContractLockAPI(t *testing.T) {
+	b := &bus{contractLocks: newContractLocks()}
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+	c := client.New(srv.URL, "")
+
+	// acquire the lock
+	fcid := types.FileContractID{1}
+	lockID, err := c.AcquireContract(context.Background(), fcid, 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// extend the lease
+	if err := c.KeepaliveContract(context.Background(), fcid, lockID, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// re-acquiring the lock should time out while it's held
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := c.AcquireContract(ctx, fcid, 0, time.Minute); err == nil {
+		t.Fatal("expected acquiring a held lock to fail")
+	}
+
+	// release the lock and re-acquire it
+	if err := c.ReleaseContract(context.Background(), fcid, lockID); err != nil {
+		t.Fatal(err)
+	} else if _, err := c.AcquireContract(context.Background(), fcid, 0, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,80 @@
+package bus
+
+import (
+	"context"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/webhooks"
+)
+
+const (
+	// defaultContractExpiryWarningWindow is the default number of blocks
+	// before a contract's proof window starts within which the bus warns
+	// about the contract if it still stores referenced sectors, one week.
+	defaultContractExpiryWarningWindow = 144 * 7
+
+	// contractExpiryCheckInterval is the interval at which the bus checks for
+	// expiring contracts to broadcast.
+	contractExpiryCheckInterval = 10 * time.Minute
+)
+
+// expiringContracts returns the contracts that expired within the expiry
+// warning window while still storing referenced sectors as of the last check
+// performed by the contract expiry loop.
+func (b *bus) expiringContracts() ([]api.ExpiringContract, error) {
+	b.expiryMu.Lock()
+	defer b.expiryMu.Unlock()
+	return b.expiring, b.expiryErr
+}
+
+// contractExpiryLoop periodically checks for expiring contracts and
+// broadcasts an event for every contract that entered the warning window.
+func (b *bus) contractExpiryLoop() {
+	t := time.NewTicker(contractExpiryCheckInterval)
+	defer t.Stop()
+
+	for {
+		b.warnExpiringContracts(b.shutdownCtx)
+
+		select {
+		case <-b.shutdownCtx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// warnExpiringContracts updates the cached expiring contracts and broadcasts
+// a contract.expiring event for every expiring contract the bus hasn't warned
+// about yet.
+func (b *bus) warnExpiringContracts(ctx context.Context) {
+	contracts, err := b.ms.ExpiringContracts(ctx, b.cm.TipState().Index.Height, b.contractExpiryWindow)
+
+	b.expiryMu.Lock()
+	defer b.expiryMu.Unlock()
+
+	b.expiring, b.expiryErr = contracts, err
+	if err != nil {
+		b.logger.Errorf("failed to fetch expiring contracts: %v", err)
+		return
+	}
+
+	// forget about contracts that are no longer expiring, e.g. because they
+	// were renewed, so we warn again should they re-enter the window
+	warned := make(map[types.FileContractID]struct{})
+	for _, c := range contracts {
+		warned[c.ID] = struct{}{}
+		if _, ok := b.expiryWarned[c.ID]; ok {
+			continue
+		}
+		b.logger.Warnw("contract is about to expire while still storing data", "fcid", c.ID, "hk", c.HostKey, "windowStart", c.WindowStart, "blocksRemaining", c.BlocksRemaining, "sectors", c.Sectors)
+		b.broadcastAction(webhooks.Event{
+			Module:  api.WebhookModuleContract,
+			Event:   api.WebhookEventContractExpiring,
+			Payload: api.EventContractExpiring{Contract: c},
+		})
+	}
+	b.expiryWarned = warned
+}
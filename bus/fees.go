@@ -0,0 +1,40 @@
+package bus
+
+import (
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+// estimateFees derives the fee tiers from the minimum and maximum fee
+// estimated by the transaction pool, which are based on the fees paid in
+// recent blocks. The slow tier pays the minimum, the fast tier the maximum
+// and the standard tier pays the average of both. If maxFee is not zero, all
+// tiers are capped at maxFee.
+func estimateFees(minFee, maxFee, feeCap types.Currency) api.FeeEstimate {
+	if minFee.Cmp(maxFee) > 0 {
+		minFee, maxFee = maxFee, minFee
+	}
+	capFee := func(fee types.Currency) types.Currency {
+		if !feeCap.IsZero() && fee.Cmp(feeCap) > 0 {
+			return feeCap
+		}
+		return fee
+	}
+	return api.FeeEstimate{
+		Fast:     capFee(maxFee),
+		Standard: capFee(minFee.Add(maxFee).Div64(2)),
+		Slow:     capFee(minFee),
+	}
+}
+
+// feeEstimate returns the current fee estimate.
+func (b *bus) feeEstimate() api.FeeEstimate {
+	minFee, maxFee := b.tp.FeeEstimation()
+	return estimateFees(minFee, maxFee, b.maxFee)
+}
+
+// recommendedFee returns the fee per byte used for transactions created by the
+// bus, it's the fast tier of the fee estimate.
+func (b *bus) recommendedFee() types.Currency {
+	return b.feeEstimate().Fast
+}
@@ -0,0 +1,40 @@
+package bus
+
+import (
+	"testing"
+
+	"go.sia.tech/core/types"
+)
+
+func TestEstimateFees(t *testing.T) {
+	minFee := types.NewCurrency64(10)
+	maxFee := types.NewCurrency64(30)
+
+	// assert the tiers are ordered
+	fees := estimateFees(minFee, maxFee, types.ZeroCurrency)
+	if !fees.Slow.Equals(minFee) || !fees.Standard.Equals(types.NewCurrency64(20)) || !fees.Fast.Equals(maxFee) {
+		t.Fatalf("unexpected fees %+v", fees)
+	}
+
+	// assert the tiers are ordered if the estimates are swapped
+	if swapped := estimateFees(maxFee, minFee, types.ZeroCurrency); swapped != fees {
+		t.Fatalf("unexpected fees %+v", swapped)
+	}
+
+	// assert the tiers are capped
+	feeCap := types.NewCurrency64(25)
+	fees = estimateFees(minFee, maxFee, feeCap)
+	if !fees.Slow.Equals(minFee) || !fees.Standard.Equals(types.NewCurrency64(20)) || !fees.Fast.Equals(feeCap) {
+		t.Fatalf("unexpected fees %+v", fees)
+	}
+
+	// assert all tiers are capped in a runaway fee market
+	feeCap = types.NewCurrency64(5)
+	fees = estimateFees(minFee, maxFee, feeCap)
+	if !fees.Slow.Equals(feeCap) || !fees.Standard.Equals(feeCap) || !fees.Fast.Equals(feeCap) {
+		t.Fatalf("unexpected fees %+v", fees)
+	}
+	if fees.Slow.Cmp(fees.Standard) > 0 || fees.Standard.Cmp(fees.Fast) > 0 {
+		t.Fatalf("fees aren't ordered %+v", fees)
+	}
+}
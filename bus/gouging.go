@@ -0,0 +1,61 @@
+package bus
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	rhpv3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/worker"
+)
+
+// percentilePriceTablesRefreshInterval is the interval after which the price
+// tables used to derive percentile based gouging limits are refreshed.
+const percentilePriceTablesRefreshInterval = 10 * time.Minute
+
+// percentilePriceTables caches the price tables of the host set that are used
+// to derive percentile based gouging limits. The limits are derived by the bus
+// so that the autopilot and the workers always agree on them.
+type percentilePriceTables struct {
+	mu      sync.Mutex
+	pts     []rhpv3.HostPriceTable
+	updated time.Time
+}
+
+// applyPricePercentile replaces the price limits of the given gouging settings
+// with the configured percentile of the prices of the host set. Settings
+// without a percentile are returned unaltered.
+func (b *bus) applyPricePercentile(ctx context.Context, gs api.GougingSettings) (api.GougingSettings, error) {
+	if gs.PricePercentile == 0 {
+		return gs, nil
+	}
+
+	b.percentilePTs.mu.Lock()
+	defer b.percentilePTs.mu.Unlock()
+
+	if time.Since(b.percentilePTs.updated) >= percentilePriceTablesRefreshInterval {
+		hosts, err := b.hdb.SearchHosts(ctx, "", api.HostFilterModeAllowed, api.UsabilityFilterModeAll, "", nil, 0, -1)
+		if err != nil {
+			return api.GougingSettings{}, err
+		}
+		b.percentilePTs.pts = percentileHostPriceTables(hosts, time.Now())
+		b.percentilePTs.updated = time.Now()
+	}
+	return worker.PercentileGougingSettings(gs, b.percentilePTs.pts), nil
+}
+
+// percentileHostPriceTables returns the price tables of the online hosts that
+// are still valid, expired or unset price tables are ignored to avoid deriving
+// the limits from outdated prices.
+func percentileHostPriceTables(hosts []api.Host, now time.Time) (pts []rhpv3.HostPriceTable) {
+	for _, h := range hosts {
+		if !h.Scanned || !h.IsOnline() || h.Blocked {
+			continue
+		} else if h.PriceTable.UID == (rhpv3.SettingsID{}) || !now.Before(h.PriceTable.Expiry) {
+			continue
+		}
+		pts = append(pts, h.PriceTable.HostPriceTable)
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package bus
+
+import (
+	"testing"
+	"time"
+
+	rhpv3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+func TestPercentileHostPriceTables(t *testing.T) {
+	now := time.Now()
+	newHost := func(storagePrice uint32, expiry time.Time) api.Host {
+		return api.Host{
+			Scanned:      true,
+			Interactions: api.HostInteractions{TotalScans: 1, LastScanSuccess: true},
+			PriceTable: api.HostPriceTable{
+				HostPriceTable: rhpv3.HostPriceTable{
+					UID:            rhpv3.SettingsID{1},
+					WriteStoreCost: types.Siacoins(storagePrice),
+				},
+				Expiry: expiry,
+			},
+		}
+	}
+
+	// prepare hosts, only the first one is valid
+	valid := newHost(1, now.Add(time.Minute))
+	expired := newHost(2, now.Add(-time.Minute))
+	unset := newHost(3, now.Add(time.Minute))
+	unset.PriceTable.UID = rhpv3.SettingsID{}
+	offline := newHost(4, now.Add(time.Minute))
+	offline.Interactions.LastScanSuccess = false
+	blocked := newHost(5, now.Add(time.Minute))
+	blocked.Blocked = true
+
+	// assert only the price table of the valid host is used
+	pts := percentileHostPriceTables([]api.Host{valid, expired, unset, offline, blocked}, now)
+	if len(pts) != 1 {
+		t.Fatalf("expected 1 price table, got %v", len(pts))
+	} else if !pts[0].WriteStoreCost.Equals(types.Siacoins(1)) {
+		t.Fatal("unexpected price table", pts[0].WriteStoreCost)
+	}
+}
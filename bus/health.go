@@ -0,0 +1,58 @@
+package bus
+
+import (
+	"context"
+	"errors"
+
+	"go.sia.tech/renterd/api"
+)
+
+// healthMaxBlocksBehind is the number of blocks the stores are allowed to lag
+// behind the chain before consensus is considered unhealthy, the stores
+// persist consensus changes in batches so they are expected to lag behind a
+// little.
+const healthMaxBlocksBehind = 6
+
+// health checks the health of the bus's subsystems.
+func (b *bus) health(ctx context.Context) (health api.BusHealthResponse) {
+	// database and consensus
+	tip := b.cm.TipState().Index
+	health.Consensus.ChainHeight = tip.Height
+	if index, err := b.hdb.ChainIndex(ctx); err != nil {
+		health.Database.Error = err.Error()
+	} else {
+		health.Database.Reachable = true
+		health.Consensus.StoreHeight = index.Height
+		health.Consensus.Synced = b.cm.Synced() && index.Height+healthMaxBlocksBehind >= tip.Height
+	}
+
+	// wallet
+	if spendable, _, _, err := b.w.Balance(); err != nil {
+		health.Wallet.Error = err.Error()
+	} else {
+		health.Wallet.Spendable = spendable
+		health.Wallet.Funded = !spendable.IsZero()
+	}
+
+	// contracts
+	var css api.ContractSetSetting
+	if err := b.fetchSetting(ctx, api.SettingContractSet, &css); err != nil && !errors.Is(err, api.ErrSettingNotFound) {
+		health.Contracts.Error = err.Error()
+	} else if css.Default != "" {
+		if contracts, err := b.ms.Contracts(ctx, api.ContractsOpts{ContractSet: css.Default}); err != nil {
+			health.Contracts.Error = err.Error()
+		} else {
+			health.Contracts.Usable = len(contracts)
+		}
+	}
+	if b.contractExpiryWindow > 0 && health.Contracts.Error == "" {
+		if expiring, err := b.expiringContracts(); err != nil {
+			health.Contracts.Error = err.Error()
+		} else {
+			health.Contracts.Expiring = expiring
+		}
+	}
+
+	health.Healthy = health.Database.Reachable && health.Consensus.Synced
+	return
+}
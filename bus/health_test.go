@@ -0,0 +1,109 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.sia.tech/core/consensus"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+type mockHealthChainManager struct {
+	ChainManager
+	height uint64
+}
+
+func (cm *mockHealthChainManager) Synced() bool { return true }
+
+func (cm *mockHealthChainManager) TipState() consensus.State {
+	return consensus.State{Index: types.ChainIndex{Height: cm.height}}
+}
+
+type mockHealthHostDB struct {
+	HostDB
+	height uint64
+}
+
+func (hdb *mockHealthHostDB) ChainIndex(_ context.Context) (types.ChainIndex, error) {
+	return types.ChainIndex{Height: hdb.height}, nil
+}
+
+type mockHealthSettingStore struct {
+	SettingStore
+}
+
+func (ss *mockHealthSettingStore) Setting(_ context.Context, key string) (string, error) {
+	if key != api.SettingContractSet {
+		return "", api.ErrSettingNotFound
+	}
+	return `{"default":"autopilot"}`, nil
+}
+
+func TestHealth(t *testing.T) {
+	cm := &mockHealthChainManager{height: 100}
+	hdb := &mockHealthHostDB{height: 100 - healthMaxBlocksBehind - 1}
+	b := &bus{
+		cm:     cm,
+		hdb:    hdb,
+		ms:     &mockPrometheusMetadataStore{},
+		ss:     &mockHealthSettingStore{},
+		w:      &mockPrometheusWallet{},
+		logger: zap.NewNop().Sugar(),
+	}
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+
+	health := func(expectedStatus int) (health api.BusHealthResponse) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("unexpected status code %v, expected %v", resp.StatusCode, expectedStatus)
+		} else if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// assert the bus is unhealthy if the store is behind the chain
+	h := health(http.StatusServiceUnavailable)
+	if h.Healthy {
+		t.Fatal("expected bus to be unhealthy")
+	} else if h.Consensus.Synced {
+		t.Fatal("expected consensus to not be synced")
+	} else if h.Consensus.ChainHeight != cm.height || h.Consensus.StoreHeight != hdb.height {
+		t.Fatalf("unexpected heights %+v", h.Consensus)
+	} else if !h.Database.Reachable {
+		t.Fatal("expected database to be reachable")
+	}
+
+	// assert the bus is healthy once the store caught up
+	hdb.height = cm.height - healthMaxBlocksBehind
+	h = health(http.StatusOK)
+	if !h.Healthy || !h.Consensus.Synced {
+		t.Fatal("expected bus to be healthy", h)
+	} else if !h.Wallet.Funded {
+		t.Fatal("expected wallet to be funded")
+	} else if h.Contracts.Usable != 3 {
+		t.Fatalf("unexpected number of usable contracts %v", h.Contracts.Usable)
+	} else if len(h.Contracts.Expiring) != 0 {
+		t.Fatal("expected no expiring contracts", h.Contracts.Expiring)
+	}
+
+	// assert the expiring contracts found by the expiry loop are reported
+	// without querying the store
+	b.contractExpiryWindow = 144
+	b.expiring = []api.ExpiringContract{{ID: types.FileContractID{1}, WindowStart: 110, BlocksRemaining: 10, Sectors: 1}}
+	h = health(http.StatusOK)
+	if len(h.Contracts.Expiring) != 1 || h.Contracts.Expiring[0] != b.expiring[0] {
+		t.Fatalf("unexpected expiring contracts %+v", h.Contracts.Expiring)
+	}
+}
@@ -0,0 +1,92 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/prometheus"
+)
+
+// prometheusMetrics returns the bus metrics that are derived from the state
+// of its stores and wallet.
+func (b *bus) prometheusMetrics(ctx context.Context) (metrics []prometheus.Metric, _ error) {
+	// host counts
+	allowed, blocked, err := b.hdb.HostCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count hosts: %w", err)
+	}
+	for _, count := range []struct {
+		status string
+		value  uint64
+	}{
+		{api.HostFilterModeAllowed, allowed},
+		{api.HostFilterModeBlocked, blocked},
+	} {
+		metrics = append(metrics, prometheus.Metric{
+			Name:   "renterd_bus_hosts",
+			Help:   "Number of hosts by status.",
+			Type:   prometheus.MetricTypeGauge,
+			Labels: map[string]string{"status": count.status},
+			Value:  float64(count.value),
+		})
+	}
+
+	// contract counts
+	total, sets, err := b.ms.ContractCounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count contracts: %w", err)
+	}
+	names := make([]string, 0, len(sets))
+	for set := range sets {
+		names = append(names, set)
+	}
+	sort.Strings(names)
+	for _, set := range names {
+		metrics = append(metrics, prometheus.Metric{
+			Name:   "renterd_bus_contracts",
+			Help:   "Number of contracts by contract set.",
+			Type:   prometheus.MetricTypeGauge,
+			Labels: map[string]string{"set": set},
+			Value:  float64(sets[set]),
+		})
+	}
+	metrics = append(metrics, prometheus.Metric{
+		Name:  "renterd_bus_contracts_total",
+		Help:  "Total number of contracts.",
+		Type:  prometheus.MetricTypeGauge,
+		Value: float64(total),
+	})
+
+	// wallet balance
+	spendable, confirmed, unconfirmed, err := b.w.Balance()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch wallet balance: %w", err)
+	}
+	for _, balance := range []struct {
+		typ   string
+		value types.Currency
+	}{
+		{"spendable", spendable},
+		{"confirmed", confirmed},
+		{"unconfirmed", unconfirmed},
+	} {
+		metrics = append(metrics, prometheus.Metric{
+			Name:   "renterd_bus_wallet_balance_siacoins",
+			Help:   "Balance of the wallet in siacoins.",
+			Type:   prometheus.MetricTypeGauge,
+			Labels: map[string]string{"type": balance.typ},
+			Value:  siacoinsFloat(balance.value),
+		})
+	}
+	return metrics, nil
+}
+
+// siacoinsFloat converts the given currency to a float in siacoins.
+func siacoinsFloat(c types.Currency) float64 {
+	f, _ := new(big.Rat).SetFrac(c.Big(), types.Siacoins(1).Big()).Float64()
+	return f
+}
@@ -0,0 +1,90 @@
+package bus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+type mockPrometheusHostDB struct {
+	HostDB
+}
+
+func (hdb *mockPrometheusHostDB) HostCounts(_ context.Context) (allowed, blocked uint64, _ error) {
+	return 2, 1, nil
+}
+
+type mockPrometheusMetadataStore struct {
+	MetadataStore
+}
+
+func (ms *mockPrometheusMetadataStore) ContractCounts(_ context.Context) (uint64, map[string]uint64, error) {
+	return 3, map[string]uint64{"autopilot": 2, "empty": 0}, nil
+}
+
+func (ms *mockPrometheusMetadataStore) Contracts(_ context.Context, _ api.ContractsOpts) ([]api.ContractMetadata, error) {
+	return []api.ContractMetadata{{}, {}, {}}, nil
+}
+
+type mockPrometheusWallet struct {
+	Wallet
+}
+
+func (w *mockPrometheusWallet) Balance() (spendable, confirmed, unconfirmed types.Currency, _ error) {
+	return types.Siacoins(1), types.Siacoins(2), types.Siacoins(3), nil
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	usc, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bus{
+		hdb:              &mockPrometheusHostDB{},
+		ms:               &mockPrometheusMetadataStore{},
+		w:                &mockPrometheusWallet{},
+		uploadingSectors: usc,
+		logger:           zap.NewNop().Sugar(),
+	}
+
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status code %v", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the expected metrics are present
+	for _, metric := range []string{
+		`renterd_bus_tracked_uploads 0`,
+		`renterd_bus_pending_upload_bytes 0`,
+		`renterd_bus_hosts{status="allowed"} 2`,
+		`renterd_bus_hosts{status="blocked"} 1`,
+		`renterd_bus_contracts{set="autopilot"} 2`,
+		`renterd_bus_contracts{set="empty"} 0`,
+		`renterd_bus_contracts_total 3`,
+		`renterd_bus_wallet_balance_siacoins{type="spendable"} 1`,
+		`renterd_bus_wallet_balance_siacoins{type="confirmed"} 2`,
+		`renterd_bus_wallet_balance_siacoins{type="unconfirmed"} 3`,
+	} {
+		if !strings.Contains(string(body), metric+"\n") {
+			t.Fatalf("metric %q not found in\n%s", metric, body)
+		}
+	}
+}
@@ -1,49 +1,181 @@
 package bus
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/prometheus"
+	"go.uber.org/zap"
 )
 
 const (
-	// cacheExpiry is the amount of time after which an upload is pruned from
-	// the cache, since the workers are expected to finish their uploads this is
-	// there to prevent leaking memory, which is why it's set at 24h
-	cacheExpiry = 24 * time.Hour
+	// defaultCacheExpiry is the default amount of time after which an upload
+	// is pruned from the cache, since the workers are expected to finish their
+	// uploads this is there to prevent leaking memory, which is why it's set
+	// at 24h
+	defaultCacheExpiry = 24 * time.Hour
+
+	// defaultDeadlineCheckInterval is the default interval at which the cache
+	// checks for uploads that exceeded their deadline
+	defaultDeadlineCheckInterval = time.Minute
+
+	// defaultMaxRenewalDepth is the default number of renewals that are
+	// tracked per contract, contracts are renewed infrequently so an upload
+	// spanning more than a couple of renewals is not expected
+	defaultMaxRenewalDepth = 10
 )
 
 type (
 	uploadingSectorsCache struct {
-		mu        sync.Mutex
-		uploads   map[api.UploadID]*ongoingUpload
-		renewedTo map[types.FileContractID]types.FileContractID
+		cacheExpiry           time.Duration
+		deadlineCheckInterval time.Duration
+		maxCachedRoots        int
+		maxRenewalDepth       int
+		logger                *zap.SugaredLogger
+		now                   func() time.Time
+		onAbort               func(api.UploadID, time.Time)
+		onPrune               func(api.UploadID, time.Time)
+
+		closedChan    chan struct{}
+		closeOnce     sync.Once
+		deadlinesOnce sync.Once
+
+		mu       sync.Mutex
+		uploads  map[api.UploadID]*ongoingUpload
+		chains   map[types.FileContractID]*renewalChain
+		numRoots int
+		paused   bool
 	}
 
+	// renewalChain contains the ids of a contract and its renewals, ordered
+	// from oldest to newest.
+	renewalChain struct {
+		fcids []types.FileContractID
+	}
+
+	// uploadingSectorsCacheOption is a functional option that configures the
+	// uploading sectors cache.
+	uploadingSectorsCacheOption func(*uploadingSectorsCache)
+
 	ongoingUpload struct {
+		deadline        time.Time
+		priority        int
 		started         time.Time
 		contractSectors map[types.FileContractID][]types.Hash256
+		uploaded        map[uploadedSector]struct{}
+	}
+
+	// uploadedSector identifies a sector that was uploaded to a contract, it's
+	// used to deduplicate sectors that are added more than once due to retries.
+	uploadedSector struct {
+		fcid types.FileContractID
+		root types.Hash256
 	}
 )
 
-func newUploadingSectorsCache() *uploadingSectorsCache {
-	return &uploadingSectorsCache{
-		uploads:   make(map[api.UploadID]*ongoingUpload),
-		renewedTo: make(map[types.FileContractID]types.FileContractID),
+// withCacheExpiry overrides the amount of time after which an upload is
+// pruned from the cache.
+func withCacheExpiry(expiry time.Duration) uploadingSectorsCacheOption {
+	return func(usc *uploadingSectorsCache) {
+		usc.cacheExpiry = expiry
+	}
+}
+
+// withDeadlineCheckInterval overrides the interval at which the cache checks for
+// uploads that exceeded their deadline.
+func withDeadlineCheckInterval(interval time.Duration) uploadingSectorsCacheOption {
+	return func(usc *uploadingSectorsCache) {
+		usc.deadlineCheckInterval = interval
+	}
+}
+
+// withMaxRenewalDepth overrides the number of renewals that are tracked per
+// contract, the sectors of contracts that fall off the chain are attributed to
+// the oldest contract still tracked.
+func withMaxRenewalDepth(depth int) uploadingSectorsCacheOption {
+	return func(usc *uploadingSectorsCache) {
+		usc.maxRenewalDepth = depth
+	}
+}
+
+// withMaxCachedRoots limits the number of sector roots the cache tracks across
+// all uploads, when the limit is exceeded the oldest uploads are evicted. A
+// value of 0 means the number of roots is not limited.
+func withMaxCachedRoots(n int) uploadingSectorsCacheOption {
+	return func(usc *uploadingSectorsCache) {
+		usc.maxCachedRoots = n
 	}
 }
 
+// withAbortCallback registers a callback that is called for every upload that
+// is aborted because it exceeded its deadline, it's never called while holding
+// the lock.
+func withAbortCallback(fn func(uID api.UploadID, deadline time.Time)) uploadingSectorsCacheOption {
+	return func(usc *uploadingSectorsCache) {
+		usc.onAbort = fn
+	}
+}
+
+// withPruneCallback registers a callback that is called for every upload that
+// is pruned because it expired, it's never called while holding the lock.
+func withPruneCallback(fn func(uID api.UploadID, started time.Time)) uploadingSectorsCacheOption {
+	return func(usc *uploadingSectorsCache) {
+		usc.onPrune = fn
+	}
+}
+
+func newUploadingSectorsCache(logger *zap.SugaredLogger, opts ...uploadingSectorsCacheOption) (*uploadingSectorsCache, error) {
+	usc := &uploadingSectorsCache{
+		cacheExpiry:           defaultCacheExpiry,
+		deadlineCheckInterval: defaultDeadlineCheckInterval,
+		maxRenewalDepth:       defaultMaxRenewalDepth,
+		logger:                logger,
+		now:                   time.Now,
+		closedChan:            make(chan struct{}),
+		uploads:               make(map[api.UploadID]*ongoingUpload),
+		chains:                make(map[types.FileContractID]*renewalChain),
+	}
+	for _, opt := range opts {
+		opt(usc)
+	}
+	if usc.cacheExpiry <= 0 {
+		return nil, errors.New("cache expiry must be greater than zero")
+	} else if usc.deadlineCheckInterval <= 0 {
+		return nil, errors.New("deadline check interval must be greater than zero")
+	} else if usc.maxRenewalDepth <= 0 {
+		return nil, errors.New("max renewal depth must be greater than zero")
+	} else if usc.maxCachedRoots < 0 {
+		return nil, errors.New("max cached roots can't be negative")
+	}
+	return usc, nil
+}
+
 func (ou *ongoingUpload) addSector(fcid types.FileContractID, root types.Hash256) {
 	ou.contractSectors[fcid] = append(ou.contractSectors[fcid], root)
+	ou.uploaded[uploadedSector{fcid, root}] = struct{}{}
+}
+
+func (ou *ongoingUpload) hasSector(fcid types.FileContractID, root types.Hash256) bool {
+	_, exists := ou.uploaded[uploadedSector{fcid, root}]
+	return exists
+}
+
+func (ou *ongoingUpload) numSectors() (n int) {
+	for _, roots := range ou.contractSectors {
+		n += len(roots)
+	}
+	return
 }
 
-func (ou *ongoingUpload) sectors(fcid types.FileContractID) (roots []types.Hash256) {
-	if sectors, exists := ou.contractSectors[fcid]; exists && time.Since(ou.started) < cacheExpiry {
-		roots = append(roots, sectors...)
+func (ou *ongoingUpload) sectors(fcids []types.FileContractID) (roots []types.Hash256) {
+	for _, fcid := range fcids {
+		roots = append(roots, ou.contractSectors[fcid]...)
 	}
 	return
 }
@@ -57,85 +189,375 @@ func (usc *uploadingSectorsCache) AddSector(uID api.UploadID, fcid types.FileCon
 		return fmt.Errorf("%w; id '%v'", api.ErrUnknownUpload, uID)
 	}
 
-	fcid = usc.latestFCID(fcid)
+	// ignore sectors that were already added, e.g. when a sector upload was
+	// retried, to avoid counting them twice
+	if ongoing.hasSector(fcid, root) {
+		return nil
+	}
+
+	// evict the oldest uploads if we're about to exceed the max number of
+	// cached roots, the upload we're adding to is never evicted
+	if usc.maxCachedRoots > 0 {
+		for usc.numRoots+1 > usc.maxCachedRoots {
+			oldestID, oldest := usc.oldestUpload(uID)
+			if oldest == nil {
+				break
+			}
+			usc.logger.Warnw("evicting upload from the uploading sectors cache, max cached roots exceeded", "uploadID", oldestID, "started", oldest.started, "sectors", oldest.numSectors(), "maxCachedRoots", usc.maxCachedRoots)
+			usc.removeUpload(oldestID)
+		}
+	}
+
 	ongoing.addSector(fcid, root)
+	usc.numRoots++
 	return nil
 }
 
-func (usc *uploadingSectorsCache) FinishUpload(uID api.UploadID) {
+// Close stops the goroutine that aborts uploads that exceeded their deadline.
+func (usc *uploadingSectorsCache) Close() {
+	usc.closeOnce.Do(func() { close(usc.closedChan) })
+}
+
+func (usc *uploadingSectorsCache) FinishUpload(uID api.UploadID) (err error) {
 	usc.mu.Lock()
-	defer usc.mu.Unlock()
-	delete(usc.uploads, uID)
+	if _, exists := usc.uploads[uID]; !exists {
+		err = fmt.Errorf("%w; id '%v'", api.ErrUnknownUpload, uID)
+	}
+	usc.removeUpload(uID)
 
 	// prune expired uploads
+	pruned := make(map[api.UploadID]time.Time)
 	for uID, ongoing := range usc.uploads {
-		if time.Since(ongoing.started) > cacheExpiry {
-			delete(usc.uploads, uID)
+		if usc.isExpired(ongoing) {
+			pruned[uID] = ongoing.started
+			usc.removeUpload(uID)
 		}
 	}
 
-	// prune renewed to map
-	for old, new := range usc.renewedTo {
-		if _, exists := usc.renewedTo[new]; exists {
-			delete(usc.renewedTo, old)
+	// prune renewal chains, the latest renewal is always kept but older
+	// contracts are only kept if they still have sectors being uploaded
+	seen := make(map[*renewalChain]struct{})
+	for _, chain := range usc.chains {
+		if _, exists := seen[chain]; exists {
+			continue
+		}
+		seen[chain] = struct{}{}
+		for len(chain.fcids) > 2 && !usc.isUploading(chain.fcids[0]) {
+			delete(usc.chains, chain.fcids[0])
+			chain.fcids = chain.fcids[1:]
 		}
 	}
+	usc.mu.Unlock()
+
+	// notify the callback outside of the lock, allowing it to call back
+	// into the cache
+	if usc.onPrune != nil {
+		for uID, started := range pruned {
+			usc.onPrune(uID, started)
+		}
+	}
+	return
 }
 
 func (usc *uploadingSectorsCache) HandleRenewal(fcid, renewedFrom types.FileContractID) {
 	usc.mu.Lock()
 	defer usc.mu.Unlock()
 
-	for _, upload := range usc.uploads {
-		if _, exists := upload.contractSectors[renewedFrom]; exists {
-			upload.contractSectors[fcid] = upload.contractSectors[renewedFrom]
-			upload.contractSectors[renewedFrom] = nil
+	// ignore renewals we've already seen
+	if _, exists := usc.chains[fcid]; exists {
+		return
+	}
+
+	// extend the chain of the contract that got renewed
+	chain, exists := usc.chains[renewedFrom]
+	if !exists {
+		chain = &renewalChain{fcids: []types.FileContractID{renewedFrom}}
+		usc.chains[renewedFrom] = chain
+	}
+	chain.fcids = append(chain.fcids, fcid)
+	usc.chains[fcid] = chain
+
+	// if the chain exceeds the max depth we drop the oldest contract, its
+	// sectors are moved to the next contract in the chain to ensure they
+	// remain accounted for, sectors already uploaded to the next contract are
+	// dropped
+	if len(chain.fcids) > usc.maxRenewalDepth+1 {
+		oldest, next := chain.fcids[0], chain.fcids[1]
+		for _, upload := range usc.uploads {
+			sectors, exists := upload.contractSectors[oldest]
+			if !exists {
+				continue
+			}
+			moved := make([]types.Hash256, 0, len(sectors))
+			for _, root := range sectors {
+				delete(upload.uploaded, uploadedSector{oldest, root})
+				if upload.hasSector(next, root) {
+					usc.numRoots--
+					continue
+				}
+				upload.uploaded[uploadedSector{next, root}] = struct{}{}
+				moved = append(moved, root)
+			}
+			upload.contractSectors[next] = append(moved, upload.contractSectors[next]...)
+			delete(upload.contractSectors, oldest)
 		}
+		delete(usc.chains, oldest)
+		chain.fcids = chain.fcids[1:]
+	}
+}
+
+// OngoingUploads returns a snapshot of all uploads that are currently being
+// tracked, ordered by the time they were started.
+func (usc *uploadingSectorsCache) OngoingUploads() []api.ActiveUpload {
+	usc.mu.Lock()
+	defer usc.mu.Unlock()
+
+	uploads := make([]api.ActiveUpload, 0, len(usc.uploads))
+	for uID, ongoing := range usc.uploads {
+		contractSectors := make(map[types.FileContractID]int, len(ongoing.contractSectors))
+		for fcid, roots := range ongoing.contractSectors {
+			contractSectors[fcid] = len(roots)
+		}
+		uploads = append(uploads, api.ActiveUpload{
+			ID:              uID,
+			Started:         api.TimeRFC3339(ongoing.started),
+			Deadline:        api.TimeRFC3339(ongoing.deadline),
+			Priority:        ongoing.priority,
+			Sectors:         ongoing.numSectors(),
+			ContractSectors: contractSectors,
+		})
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		return uploads[i].Started.Std().Before(uploads[j].Started.Std())
+	})
+	return uploads
+}
+
+func (usc *uploadingSectorsCache) Pending(fcid types.FileContractID) uint64 {
+	usc.mu.Lock()
+	defer usc.mu.Unlock()
+	return usc.pendingSize(usc.fcids(fcid))
+}
+
+// PrometheusMetrics implements the prometheus.Collector interface.
+func (usc *uploadingSectorsCache) PrometheusMetrics() []prometheus.Metric {
+	usc.mu.Lock()
+	numUploads := len(usc.uploads)
+	usc.mu.Unlock()
+
+	return []prometheus.Metric{
+		{
+			Name:  "renterd_bus_tracked_uploads",
+			Help:  "Number of uploads that are currently being tracked.",
+			Type:  prometheus.MetricTypeGauge,
+			Value: float64(numUploads),
+		},
+		{
+			Name:  "renterd_bus_pending_upload_bytes",
+			Help:  "Total number of bytes of sectors that are currently being uploaded.",
+			Type:  prometheus.MetricTypeGauge,
+			Value: float64(usc.TotalPending()),
+		},
 	}
-	usc.renewedTo[renewedFrom] = fcid
 }
 
-func (usc *uploadingSectorsCache) Pending(fcid types.FileContractID) (size uint64) {
+// Pause prevents new uploads from being started, it fails if there are
+// uploads in progress or if the cache is already paused.
+func (usc *uploadingSectorsCache) Pause() error {
 	usc.mu.Lock()
 	defer usc.mu.Unlock()
 
-	fcid = usc.latestFCID(fcid)
+	if usc.paused {
+		return api.ErrConsensusResyncInProgress
+	}
 	for _, ongoing := range usc.uploads {
-		size += uint64(len(ongoing.sectors(fcid))) * rhp.SectorSize
+		if !usc.isExpired(ongoing) {
+			return api.ErrUploadsInProgress
+		}
 	}
-	return
+	usc.paused = true
+	return nil
+}
+
+// Resume allows new uploads to be started again.
+func (usc *uploadingSectorsCache) Resume() {
+	usc.mu.Lock()
+	defer usc.mu.Unlock()
+	usc.paused = false
 }
 
 func (usc *uploadingSectorsCache) Sectors(fcid types.FileContractID) (roots []types.Hash256) {
 	usc.mu.Lock()
 	defer usc.mu.Unlock()
 
-	fcid = usc.latestFCID(fcid)
+	fcids := usc.fcids(fcid)
 	for _, ongoing := range usc.uploads {
-		roots = append(roots, ongoing.sectors(fcid)...)
+		if !usc.isExpired(ongoing) {
+			roots = append(roots, ongoing.sectors(fcids)...)
+		}
 	}
 	return
 }
 
-func (usc *uploadingSectorsCache) StartUpload(uID api.UploadID) error {
+// TotalPending returns the size of all sectors that are currently being
+// uploaded, across all contracts.
+func (usc *uploadingSectorsCache) TotalPending() (size uint64) {
 	usc.mu.Lock()
 	defer usc.mu.Unlock()
 
+	// collect the renewal chains, keyed by their latest contract id, to
+	// avoid counting the sectors of a renewed contract twice
+	chains := make(map[types.FileContractID][]types.FileContractID)
+	for _, ongoing := range usc.uploads {
+		for fcid := range ongoing.contractSectors {
+			fcids := usc.fcids(fcid)
+			chains[fcids[len(fcids)-1]] = fcids
+		}
+	}
+	for _, fcids := range chains {
+		size += usc.pendingSize(fcids)
+	}
+	return
+}
+
+// StartUpload starts tracking the upload with given id, uploads with a higher
+// priority are scheduled before those with a lower one. If the deadline is not
+// zero, the upload is aborted when it's not finished by the deadline.
+func (usc *uploadingSectorsCache) StartUpload(uID api.UploadID, priority int, deadline time.Time) error {
+	usc.mu.Lock()
+	defer usc.mu.Unlock()
+
+	// check if uploads are paused
+	if usc.paused {
+		return api.ErrConsensusResyncInProgress
+	}
+
 	// check if upload already exists
 	if _, exists := usc.uploads[uID]; exists {
 		return fmt.Errorf("%w; id '%v'", api.ErrUploadAlreadyExists, uID)
 	}
 
+	// lazily start checking deadlines
+	if !deadline.IsZero() {
+		usc.deadlinesOnce.Do(func() { go usc.threadedAbortOverdueUploads() })
+	}
+
 	usc.uploads[uID] = &ongoingUpload{
-		started:         time.Now(),
+		deadline:        deadline,
+		priority:        priority,
+		started:         usc.now(),
 		contractSectors: make(map[types.FileContractID][]types.Hash256),
+		uploaded:        make(map[uploadedSector]struct{}),
 	}
 	return nil
 }
 
-func (usc *uploadingSectorsCache) latestFCID(fcid types.FileContractID) types.FileContractID {
-	if latest, ok := usc.renewedTo[fcid]; ok {
-		return latest
+// abortOverdueUploads finishes all uploads that exceeded their deadline and
+// notifies the abort callback.
+func (usc *uploadingSectorsCache) abortOverdueUploads() {
+	usc.mu.Lock()
+	overdue := make(map[api.UploadID]time.Time)
+	for uID, ongoing := range usc.uploads {
+		if !ongoing.deadline.IsZero() && usc.now().After(ongoing.deadline) {
+			overdue[uID] = ongoing.deadline
+		}
+	}
+	usc.mu.Unlock()
+
+	for uID, deadline := range overdue {
+		if usc.onAbort != nil {
+			usc.onAbort(uID, deadline)
+		}
+		if err := usc.FinishUpload(uID); err != nil && !errors.Is(err, api.ErrUnknownUpload) {
+			usc.logger.Errorw("failed to finish aborted upload", "uploadID", uID, "error", err)
+		}
+	}
+}
+
+// ongoingUploadsByPriority returns the ids of all uploads that haven't
+// expired, ordered by priority from highest to lowest. Uploads with equal
+// priority are ordered by the time they were started.
+func (usc *uploadingSectorsCache) ongoingUploadsByPriority() []api.UploadID {
+	usc.mu.Lock()
+	defer usc.mu.Unlock()
+
+	var uIDs []api.UploadID
+	for uID, ongoing := range usc.uploads {
+		if !usc.isExpired(ongoing) {
+			uIDs = append(uIDs, uID)
+		}
+	}
+	sort.Slice(uIDs, func(i, j int) bool {
+		ui, uj := usc.uploads[uIDs[i]], usc.uploads[uIDs[j]]
+		if ui.priority != uj.priority {
+			return ui.priority > uj.priority
+		}
+		return ui.started.Before(uj.started)
+	})
+	return uIDs
+}
+
+// oldestUpload returns the upload that was started first, ignoring the upload
+// with given id.
+func (usc *uploadingSectorsCache) oldestUpload(ignore api.UploadID) (oldestID api.UploadID, oldest *ongoingUpload) {
+	for uID, ongoing := range usc.uploads {
+		if uID == ignore {
+			continue
+		} else if oldest == nil || ongoing.started.Before(oldest.started) {
+			oldestID, oldest = uID, ongoing
+		}
+	}
+	return
+}
+
+func (usc *uploadingSectorsCache) threadedAbortOverdueUploads() {
+	t := time.NewTicker(usc.deadlineCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-usc.closedChan:
+			return
+		case <-t.C:
+		}
+		usc.abortOverdueUploads()
+	}
+}
+
+func (usc *uploadingSectorsCache) removeUpload(uID api.UploadID) {
+	if ongoing, exists := usc.uploads[uID]; exists {
+		usc.numRoots -= ongoing.numSectors()
+		delete(usc.uploads, uID)
+	}
+}
+
+func (usc *uploadingSectorsCache) pendingSize(fcids []types.FileContractID) (size uint64) {
+	for _, ongoing := range usc.uploads {
+		if !usc.isExpired(ongoing) {
+			size += uint64(len(ongoing.sectors(fcids))) * rhp.SectorSize
+		}
+	}
+	return
+}
+
+// fcids returns the ids of all contracts in the renewal chain of the given
+// contract, ordered from oldest to newest.
+func (usc *uploadingSectorsCache) fcids(fcid types.FileContractID) []types.FileContractID {
+	if chain, ok := usc.chains[fcid]; ok {
+		return append([]types.FileContractID(nil), chain.fcids...)
+	}
+	return []types.FileContractID{fcid}
+}
+
+func (usc *uploadingSectorsCache) isExpired(ongoing *ongoingUpload) bool {
+	return usc.now().Sub(ongoing.started) >= usc.cacheExpiry
+}
+
+func (usc *uploadingSectorsCache) isUploading(fcid types.FileContractID) bool {
+	for _, ongoing := range usc.uploads {
+		if len(ongoing.contractSectors[fcid]) > 0 {
+			return true
+		}
 	}
-	return fcid
+	return false
 }
@@ -1,17 +1,26 @@
 package bus
 
 import (
+	"context"
 	"errors"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/bus/client"
+	"go.uber.org/zap"
 	"lukechampine.com/frand"
 )
 
 func TestUploadingSectorsCache(t *testing.T) {
-	c := newUploadingSectorsCache()
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	uID1 := newTestUploadID()
 	uID2 := newTestUploadID()
@@ -20,8 +29,8 @@ func TestUploadingSectorsCache(t *testing.T) {
 	fcid2 := types.FileContractID{2}
 	fcid3 := types.FileContractID{3}
 
-	c.StartUpload(uID1)
-	c.StartUpload(uID2)
+	c.StartUpload(uID1, 0, time.Time{})
+	c.StartUpload(uID2, 0, time.Time{})
 
 	_ = c.AddSector(uID1, fcid1, types.Hash256{1})
 	_ = c.AddSector(uID1, fcid2, types.Hash256{2})
@@ -57,21 +66,29 @@ func TestUploadingSectorsCache(t *testing.T) {
 		t.Fatal("unexpected cached sectors")
 	}
 
+	// assert finishing an upload twice returns an error
+	if err := c.FinishUpload(uID2); !errors.Is(err, api.ErrUnknownUpload) {
+		t.Fatal("unexpected error", err)
+	}
+
 	if err := c.AddSector(uID1, fcid1, types.Hash256{1}); !errors.Is(err, api.ErrUnknownUpload) {
 		t.Fatal("unexpected error", err)
 	}
-	if err := c.StartUpload(uID1); err != nil {
+	if err := c.StartUpload(uID1, 0, time.Time{}); err != nil {
 		t.Fatal("unexpected error", err)
 	}
-	if err := c.StartUpload(uID1); !errors.Is(err, api.ErrUploadAlreadyExists) {
+	if err := c.StartUpload(uID1, 0, time.Time{}); !errors.Is(err, api.ErrUploadAlreadyExists) {
 		t.Fatal("unexpected error", err)
 	}
 
 	// reset cache
-	c = newUploadingSectorsCache()
+	c, err = newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// track upload that uploads across two contracts
-	c.StartUpload(uID1)
+	c.StartUpload(uID1, 0, time.Time{})
 	c.AddSector(uID1, fcid1, types.Hash256{1})
 	c.AddSector(uID1, fcid1, types.Hash256{2})
 	c.HandleRenewal(fcid2, fcid1)
@@ -104,12 +121,120 @@ func TestUploadingSectorsCache(t *testing.T) {
 	c.HandleRenewal(fcid3, fcid2)
 
 	// trigger pruning
-	c.StartUpload(uID2)
+	c.StartUpload(uID2, 0, time.Time{})
+	c.FinishUpload(uID2)
+
+	// assert the renewal chain gets pruned to the latest renewal
+	if len(c.chains) != 2 {
+		t.Fatal("unexpected", len(c.chains))
+	} else if fcids := c.fcids(fcid3); len(fcids) != 2 || fcids[0] != fcid2 || fcids[1] != fcid3 {
+		t.Fatal("unexpected", fcids)
+	}
+}
+
+func TestUploadingSectorsCacheRenewalChain(t *testing.T) {
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withMaxRenewalDepth(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uID := newTestUploadID()
+	fcid1 := types.FileContractID{1}
+	fcid2 := types.FileContractID{2}
+	fcid3 := types.FileContractID{3}
+	fcid4 := types.FileContractID{4}
+
+	// renew twice during one upload
+	c.StartUpload(uID, 0, time.Time{})
+	c.AddSector(uID, fcid1, types.Hash256{1})
+	c.HandleRenewal(fcid2, fcid1)
+	c.AddSector(uID, fcid2, types.Hash256{2})
+	c.HandleRenewal(fcid3, fcid2)
+	c.AddSector(uID, fcid3, types.Hash256{3})
+
+	// assert all sectors are reported for every contract in the chain
+	for _, fcid := range []types.FileContractID{fcid1, fcid2, fcid3} {
+		if sectors := c.Sectors(fcid); len(sectors) != 3 {
+			t.Fatal("unexpected sectors", len(sectors))
+		} else if pending := c.Pending(fcid); pending != 3*rhpv2.SectorSize {
+			t.Fatal("unexpected pending size", pending/rhpv2.SectorSize)
+		}
+	}
+
+	// assert pruning doesn't drop contracts that still have pending sectors
+	uID2 := newTestUploadID()
+	c.StartUpload(uID2, 0, time.Time{})
 	c.FinishUpload(uID2)
+	if fcids := c.fcids(fcid3); len(fcids) != 3 {
+		t.Fatal("unexpected chain", fcids)
+	}
+
+	// renew a third time, exceeding the max depth
+	c.HandleRenewal(fcid4, fcid3)
+	if fcids := c.fcids(fcid4); len(fcids) != 3 || fcids[0] != fcid2 {
+		t.Fatal("unexpected chain", fcids)
+	}
+
+	// assert the sectors of the dropped contract are still accounted for
+	if sectors := c.Sectors(fcid4); len(sectors) != 3 {
+		t.Fatal("unexpected sectors", len(sectors))
+	} else if sectors := c.Sectors(fcid1); len(sectors) != 0 {
+		t.Fatal("unexpected sectors", len(sectors))
+	}
 
-	// assert renewedTo gets pruned
-	if len(c.renewedTo) != 1 {
-		t.Fatal("unexpected", len(c.renewedTo))
+	// assert invalid depths are rejected
+	if _, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withMaxRenewalDepth(0)); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestUploadingSectorsCacheExpiry(t *testing.T) {
+	// assert invalid expiries are rejected
+	if _, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withCacheExpiry(0)); err == nil {
+		t.Fatal("expected error")
+	} else if _, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withCacheExpiry(-time.Hour)); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// create a cache with the default expiry and one with a raised expiry
+	cDefault, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cRaised, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withCacheExpiry(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// track an upload that started 25h ago in both caches
+	uID := newTestUploadID()
+	fcid := types.FileContractID{1}
+	for _, c := range []*uploadingSectorsCache{cDefault, cRaised} {
+		if err := c.StartUpload(uID, 0, time.Time{}); err != nil {
+			t.Fatal(err)
+		} else if err := c.AddSector(uID, fcid, types.Hash256{1}); err != nil {
+			t.Fatal(err)
+		}
+		c.uploads[uID].started = time.Now().Add(-25 * time.Hour)
+	}
+
+	// assert the upload expired in the default cache but not in the other
+	if pending := cDefault.Pending(fcid); pending != 0 {
+		t.Fatal("unexpected pending size", pending)
+	} else if pending := cRaised.Pending(fcid); pending != rhpv2.SectorSize {
+		t.Fatal("unexpected pending size", pending)
+	}
+
+	// trigger pruning and assert the upload survived in the raised cache
+	for _, c := range []*uploadingSectorsCache{cDefault, cRaised} {
+		uID := newTestUploadID()
+		c.StartUpload(uID, 0, time.Time{})
+		c.FinishUpload(uID)
+	}
+	if _, exists := cDefault.uploads[uID]; exists {
+		t.Fatal("expected upload to be pruned")
+	} else if _, exists := cRaised.uploads[uID]; !exists {
+		t.Fatal("expected upload to survive")
 	}
 }
 
@@ -118,3 +243,365 @@ func newTestUploadID() api.UploadID {
 	frand.Read(uID[:])
 	return uID
 }
+
+func TestUploadingSectorsCacheTotalPending(t *testing.T) {
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uID1 := newTestUploadID()
+	uID2 := newTestUploadID()
+
+	fcid1 := types.FileContractID{1}
+	fcid2 := types.FileContractID{2}
+	fcid3 := types.FileContractID{3}
+
+	// track two overlapping uploads
+	c.StartUpload(uID1, 0, time.Time{})
+	c.StartUpload(uID2, 0, time.Time{})
+	c.AddSector(uID1, fcid1, types.Hash256{1})
+	c.AddSector(uID1, fcid2, types.Hash256{2})
+	c.AddSector(uID2, fcid2, types.Hash256{3})
+	c.AddSector(uID2, fcid1, types.Hash256{4})
+
+	// renew one of the contracts halfway through
+	c.HandleRenewal(fcid3, fcid1)
+	c.AddSector(uID1, fcid3, types.Hash256{5})
+	c.AddSector(uID2, fcid1, types.Hash256{6})
+
+	// assert the total matches the number of distinct sectors
+	if total := c.TotalPending(); total != 6*rhpv2.SectorSize {
+		t.Fatal("unexpected total pending size", total/rhpv2.SectorSize)
+	}
+
+	// assert the total equals the sum of the pending sizes of the latest
+	// contracts
+	if total := c.TotalPending(); total != c.Pending(fcid2)+c.Pending(fcid3) {
+		t.Fatal("unexpected total pending size", total/rhpv2.SectorSize)
+	}
+
+	// finish one upload and assert the total is updated
+	c.FinishUpload(uID1)
+	if total := c.TotalPending(); total != 3*rhpv2.SectorSize {
+		t.Fatal("unexpected total pending size", total/rhpv2.SectorSize)
+	}
+}
+
+func TestUploadingSectorsCacheEviction(t *testing.T) {
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withMaxCachedRoots(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uID1 := newTestUploadID()
+	uID2 := newTestUploadID()
+	uID3 := newTestUploadID()
+	fcid := types.FileContractID{1}
+
+	// start three uploads, the first one being the oldest
+	for i, uID := range []api.UploadID{uID1, uID2, uID3} {
+		c.StartUpload(uID, 0, time.Time{})
+		c.uploads[uID].started = time.Now().Add(-time.Duration(3-i) * time.Minute)
+	}
+
+	// fill up the cache
+	c.AddSector(uID1, fcid, types.Hash256{1})
+	c.AddSector(uID2, fcid, types.Hash256{2})
+	c.AddSector(uID3, fcid, types.Hash256{3})
+	if len(c.uploads) != 3 || c.numRoots != 3 {
+		t.Fatal("unexpected", len(c.uploads), c.numRoots)
+	}
+
+	// exceed the budget and assert the oldest upload got evicted
+	if err := c.AddSector(uID3, fcid, types.Hash256{4}); err != nil {
+		t.Fatal(err)
+	} else if _, exists := c.uploads[uID1]; exists {
+		t.Fatal("expected oldest upload to be evicted")
+	} else if c.numRoots != 3 {
+		t.Fatal("unexpected number of roots", c.numRoots)
+	} else if sectors := c.Sectors(fcid); len(sectors) != 3 {
+		t.Fatal("unexpected sectors", len(sectors))
+	}
+
+	// assert the evicted upload behaves as if it was finished
+	if err := c.AddSector(uID1, fcid, types.Hash256{5}); !errors.Is(err, api.ErrUnknownUpload) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// assert the upload we're adding to is never evicted
+	if err := c.AddSector(uID2, fcid, types.Hash256{6}); err != nil {
+		t.Fatal(err)
+	} else if _, exists := c.uploads[uID2]; !exists {
+		t.Fatal("expected upload to be kept")
+	} else if _, exists := c.uploads[uID3]; exists {
+		t.Fatal("expected upload to be evicted")
+	}
+
+	// assert finishing an upload frees up its roots
+	c.FinishUpload(uID2)
+	if c.numRoots != 0 {
+		t.Fatal("unexpected number of roots", c.numRoots)
+	}
+}
+
+func TestUploadingSectorsCachePruneCallback(t *testing.T) {
+	type prunedUpload struct {
+		id      api.UploadID
+		started time.Time
+	}
+
+	var c *uploadingSectorsCache
+	var pruned []prunedUpload
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withCacheExpiry(time.Hour), withPruneCallback(func(uID api.UploadID, started time.Time) {
+		// calling back into the cache would deadlock if we held the lock
+		_ = c.Pending(types.FileContractID{1})
+		pruned = append(pruned, prunedUpload{uID, started})
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// use a fake clock
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// start two uploads, 30 minutes apart
+	uID1 := newTestUploadID()
+	uID2 := newTestUploadID()
+	c.StartUpload(uID1, 0, time.Time{})
+	started := now
+	now = now.Add(30 * time.Minute)
+	c.StartUpload(uID2, 0, time.Time{})
+
+	// advance the clock past the expiry of the first upload
+	now = now.Add(45 * time.Minute)
+
+	// finish the second upload, which triggers pruning
+	if err := c.FinishUpload(uID2); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the callback was called for the expired upload only
+	if len(pruned) != 1 {
+		t.Fatal("unexpected number of pruned uploads", len(pruned))
+	} else if pruned[0].id != uID1 || !pruned[0].started.Equal(started) {
+		t.Fatal("unexpected pruned upload", pruned[0])
+	}
+}
+
+func TestUploadingSectorsCachePriority(t *testing.T) {
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// use a fake clock
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	// start background and interactive uploads, interleaved
+	bg1, bg2 := newTestUploadID(), newTestUploadID()
+	ia1, ia2 := newTestUploadID(), newTestUploadID()
+	for _, upload := range []struct {
+		id       api.UploadID
+		priority int
+	}{
+		{bg1, 5},
+		{ia1, 10},
+		{bg2, 5},
+		{ia2, 10},
+	} {
+		if err := c.StartUpload(upload.id, upload.priority, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	// assert interactive uploads come first, ordered by start time
+	uIDs := c.ongoingUploadsByPriority()
+	if len(uIDs) != 4 {
+		t.Fatal("unexpected number of uploads", len(uIDs))
+	}
+	for i, expected := range []api.UploadID{ia1, ia2, bg1, bg2} {
+		if uIDs[i] != expected {
+			t.Fatalf("unexpected upload at index %d", i)
+		}
+	}
+
+	// assert finished uploads are no longer returned
+	if err := c.FinishUpload(ia1); err != nil {
+		t.Fatal(err)
+	} else if uIDs := c.ongoingUploadsByPriority(); len(uIDs) != 3 || uIDs[0] != ia2 {
+		t.Fatal("unexpected uploads", uIDs)
+	}
+}
+
+func TestUploadingSectorsCacheDeadline(t *testing.T) {
+	type abortedUpload struct {
+		id       api.UploadID
+		deadline time.Time
+	}
+	aborted := make(chan abortedUpload, 1)
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar(), withDeadlineCheckInterval(10*time.Millisecond), withAbortCallback(func(uID api.UploadID, deadline time.Time) {
+		aborted <- abortedUpload{uID, deadline}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// use a fake clock, guarded by a mutex since it's read by the background
+	// goroutine
+	var mu sync.Mutex
+	now := time.Now()
+	c.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	// start an upload with a short deadline and one without a deadline
+	uID1 := newTestUploadID()
+	uID2 := newTestUploadID()
+	deadline := now.Add(time.Minute)
+	fcid := types.FileContractID{1}
+	if err := c.StartUpload(uID1, 0, deadline); err != nil {
+		t.Fatal(err)
+	} else if err := c.StartUpload(uID2, 0, time.Time{}); err != nil {
+		t.Fatal(err)
+	} else if err := c.AddSector(uID1, fcid, types.Hash256{1}); err != nil {
+		t.Fatal(err)
+	} else if err := c.AddSector(uID2, fcid, types.Hash256{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the upload isn't aborted before its deadline
+	select {
+	case <-aborted:
+		t.Fatal("upload was aborted before its deadline")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// advance the clock past the deadline
+	mu.Lock()
+	now = now.Add(2 * time.Minute)
+	mu.Unlock()
+
+	// assert the callback fires for the overdue upload
+	select {
+	case a := <-aborted:
+		if a.id != uID1 || !a.deadline.Equal(deadline) {
+			t.Fatal("unexpected aborted upload", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("upload wasn't aborted")
+	}
+
+	// assert the aborted upload no longer counts towards the pending size
+	for start := time.Now(); c.Pending(fcid) != rhpv2.SectorSize; {
+		if time.Since(start) > time.Second {
+			t.Fatal("unexpected pending size", c.Pending(fcid))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestActiveUploads(t *testing.T) {
+	usc, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bus{uploadingSectors: usc}
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+	c := client.New(srv.URL, "")
+
+	// track two uploads, the first one spanning two contracts
+	ctx := context.Background()
+	uID1, uID2 := newTestUploadID(), newTestUploadID()
+	fcid1, fcid2 := types.FileContractID{1}, types.FileContractID{2}
+	for _, uID := range []api.UploadID{uID1, uID2} {
+		if err := c.TrackUpload(ctx, uID, 0, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sector := range []struct {
+		uID  api.UploadID
+		fcid types.FileContractID
+	}{
+		{uID1, fcid1},
+		{uID1, fcid1},
+		{uID1, fcid2},
+		{uID2, fcid2},
+	} {
+		if err := c.AddUploadingSector(ctx, sector.uID, sector.fcid, frand.Entropy256()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assert the endpoint reports the sector counts
+	uploads, err := c.ActiveUploads(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(uploads) != 2 {
+		t.Fatal("unexpected number of uploads", len(uploads))
+	}
+	for _, upload := range uploads {
+		switch upload.ID {
+		case uID1:
+			if upload.Sectors != 3 || upload.ContractSectors[fcid1] != 2 || upload.ContractSectors[fcid2] != 1 {
+				t.Fatal("unexpected sectors", upload.Sectors, upload.ContractSectors)
+			}
+		case uID2:
+			if upload.Sectors != 1 || len(upload.ContractSectors) != 1 || upload.ContractSectors[fcid2] != 1 {
+				t.Fatal("unexpected sectors", upload.Sectors, upload.ContractSectors)
+			}
+		default:
+			t.Fatal("unexpected upload", upload.ID)
+		}
+		if upload.Started.IsZero() {
+			t.Fatal("expected started time to be set")
+		}
+	}
+
+	// finish an upload and assert it's no longer reported
+	if err := c.FinishUpload(ctx, uID1); err != nil {
+		t.Fatal(err)
+	} else if uploads, err := c.ActiveUploads(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(uploads) != 1 || uploads[0].ID != uID2 {
+		t.Fatal("unexpected uploads", uploads)
+	}
+}
+
+func TestUploadingSectorsCacheDeduplication(t *testing.T) {
+	c, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add the same root twice to one contract and once to another
+	uID := newTestUploadID()
+	fcid1, fcid2 := types.FileContractID{1}, types.FileContractID{2}
+	root := types.Hash256{1}
+	if err := c.StartUpload(uID, 0, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, fcid := range []types.FileContractID{fcid1, fcid1, fcid2} {
+		if err := c.AddSector(uID, fcid, root); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assert the root is only counted once per contract
+	if pending := c.Pending(fcid1); pending != rhpv2.SectorSize {
+		t.Fatal("unexpected pending size", pending)
+	} else if pending := c.Pending(fcid2); pending != rhpv2.SectorSize {
+		t.Fatal("unexpected pending size", pending)
+	} else if roots := c.Sectors(fcid1); len(roots) != 1 {
+		t.Fatal("unexpected number of roots", len(roots))
+	} else if c.numRoots != 2 {
+		t.Fatal("unexpected number of cached roots", c.numRoots)
+	}
+}
@@ -0,0 +1,128 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/bus/client"
+	"go.sia.tech/renterd/webhooks"
+	"go.uber.org/zap"
+)
+
+type mockWebhookStore struct{}
+
+func (s *mockWebhookStore) AddWebhook(_ context.Context, _ webhooks.Webhook) error    { return nil }
+func (s *mockWebhookStore) DeleteWebhook(_ context.Context, _ webhooks.Webhook) error { return nil }
+func (s *mockWebhookStore) Webhooks(_ context.Context) ([]webhooks.Webhook, error)    { return nil, nil }
+
+type mockRenewalMetadataStore struct {
+	MetadataStore
+}
+
+func (ms *mockRenewalMetadataStore) AddRenewedContract(_ context.Context, c rhpv2.ContractRevision, _, totalCost types.Currency, startHeight uint64, renewedFrom types.FileContractID, state string) (api.ContractMetadata, error) {
+	return api.ContractMetadata{
+		ID:          c.ID(),
+		RenewedFrom: renewedFrom,
+		StartHeight: startHeight,
+		State:       state,
+		TotalCost:   totalCost,
+	}, nil
+}
+
+func TestWebhookContractRenewed(t *testing.T) {
+	// create a stub server that fails the first delivery of every event
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	delivered := make(chan webhooks.Event, 1)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event webhooks.Event
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		} else if event.Event == webhooks.WebhookEventPing {
+			return
+		}
+
+		mu.Lock()
+		attempts[event.String()]++
+		n := attempts[event.String()]
+		mu.Unlock()
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		delivered <- event
+	}))
+	defer stub.Close()
+
+	// create the webhook manager and register a webhook
+	hooks, err := webhooks.NewManager(zap.NewNop().Sugar(), &mockWebhookStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hooks.Close()
+	err = hooks.Register(context.Background(), webhooks.Webhook{
+		Module: api.WebhookModuleContract,
+		Event:  api.WebhookEventContractRenewed,
+		URL:    stub.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create the bus
+	usc, err := newUploadingSectorsCache(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &bus{
+		hooks:            hooks,
+		ms:               &mockRenewalMetadataStore{},
+		uploadingSectors: usc,
+		logger:           zap.NewNop().Sugar(),
+	}
+	srv := httptest.NewServer(b.Handler())
+	defer srv.Close()
+
+	// renew a contract
+	renewedFrom := types.FileContractID{1}
+	rev := rhpv2.ContractRevision{Revision: types.FileContractRevision{ParentID: types.FileContractID{2}}}
+	_, err = client.New(srv.URL, "").AddRenewedContract(context.Background(), rev, types.ZeroCurrency, types.Siacoins(1), 100, renewedFrom, api.ContractStatePending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the event was delivered after being retried
+	var event webhooks.Event
+	select {
+	case event = <-delivered:
+	case <-time.After(10 * time.Second):
+		t.Fatal("event wasn't delivered")
+	}
+	if event.Module != api.WebhookModuleContract || event.Event != api.WebhookEventContractRenewed {
+		t.Fatalf("unexpected event %v", event)
+	}
+	mu.Lock()
+	if n := attempts[event.String()]; n != 2 {
+		t.Fatalf("expected 2 delivery attempts, got %v", n)
+	}
+	mu.Unlock()
+
+	// assert the payload contains the renewal
+	var payload api.EventContractRenewed
+	if b, err := json.Marshal(event.Payload); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(b, &payload); err != nil {
+		t.Fatal(err)
+	} else if payload.Renewal.ID != rev.ID() || payload.RenewedFrom != renewedFrom {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"go.sia.tech/renterd/config"
 	"go.sia.tech/renterd/internal/node"
 	"go.sia.tech/renterd/internal/utils"
+	"go.sia.tech/renterd/stores"
 	"go.sia.tech/renterd/worker"
 	"go.sia.tech/renterd/worker/s3"
 	"go.sia.tech/web/renterd"
@@ -107,7 +108,14 @@ var (
 			GatewayAddr:                   build.DefaultGatewayAddress,
 			PersistInterval:               time.Minute,
 			UsedUTXOExpiry:                24 * time.Hour,
+			CoinSelection:                 string(api.CoinSelectionLargestFirst),
+			WalletAddresses:               1,
 			SlabBufferCompletionThreshold: 1 << 12,
+			ContractExpiryWarningWindow:   144 * 7, // 1 week
+			UploadingSectorsCacheExpiry:   24 * time.Hour,
+			UploadingSectorsMaxRoots:      1 << 24, // 512 MiB of roots
+			HostCacheSize:                 10000,
+			HostCacheTTL:                  time.Minute,
 		},
 		Worker: config.Worker{
 			Enabled: true,
@@ -128,12 +136,17 @@ var (
 			Enabled:                        true,
 			RevisionSubmissionBuffer:       144,
 			AccountsRefillInterval:         defaultAccountRefillInterval,
+			AccountsMinBalance:             types.Siacoins(1).Div64(2),
+			AccountsMaxBalance:             types.Siacoins(1),
 			Heartbeat:                      30 * time.Minute,
 			MigrationHealthCutoff:          0.75,
 			RevisionBroadcastInterval:      7 * 24 * time.Hour,
 			ScannerBatchSize:               1000,
+			ScannerBatchSizeMin:            100,
+			ScannerBatchSizeMax:            5000,
 			ScannerInterval:                24 * time.Hour,
 			ScannerNumThreads:              100,
+			ScannerHistorySize:             2500,
 			MigratorParallelSlabsPerWorker: 1,
 		},
 		S3: config.S3{
@@ -263,6 +276,7 @@ func main() {
 	flag.DurationVar(&cfg.Log.Database.SlowThreshold, "log.database.slowThreshold", cfg.Log.Database.SlowThreshold, "Threshold for slow queries in logger. Defaults to 100ms (overrides with RENTERD_LOG_DATABASE_SLOW_THRESHOLD)")
 
 	// db
+	flag.BoolVar(&cfg.Database.MigrationsDryRun, "db.migrationsDryRun", cfg.Database.MigrationsDryRun, "Logs the pending database migrations and exits without applying them")
 	flag.StringVar(&cfg.Database.MySQL.URI, "db.uri", cfg.Database.MySQL.URI, "Database URI for the bus (overrides with RENTERD_DB_URI)")
 	flag.StringVar(&cfg.Database.MySQL.User, "db.user", cfg.Database.MySQL.User, "Database username for the bus (overrides with RENTERD_DB_USER)")
 	flag.StringVar(&cfg.Database.MySQL.Database, "db.name", cfg.Database.MySQL.Database, "Database name for the bus (overrides with RENTERD_DB_NAME)")
@@ -274,18 +288,34 @@ func main() {
 	flag.StringVar(&cfg.Bus.GatewayAddr, "bus.gatewayAddr", cfg.Bus.GatewayAddr, "Address for Sia peer connections (overrides with RENTERD_BUS_GATEWAY_ADDR)")
 	flag.DurationVar(&cfg.Bus.PersistInterval, "bus.persistInterval", cfg.Bus.PersistInterval, "Interval for persisting consensus updates")
 	flag.DurationVar(&cfg.Bus.UsedUTXOExpiry, "bus.usedUTXOExpiry", cfg.Bus.UsedUTXOExpiry, "Expiry for used UTXOs in transactions")
+	flag.Uint64Var(&cfg.Bus.WalletAddresses, "bus.walletAddresses", cfg.Bus.WalletAddresses, "Number of addresses derived from the wallet seed that the wallet manages")
+	flag.StringVar(&cfg.Bus.CoinSelection, "bus.coinSelection", cfg.Bus.CoinSelection, "Strategy for selecting the outputs that fund transactions (largestFirst, smallestFirst or minimizeChange)")
+	flag.Uint64Var(&cfg.Bus.ContractExpiryWarningWindow, "bus.contractExpiryWarningWindow", cfg.Bus.ContractExpiryWarningWindow, "Number of blocks before a contract storing data expires within which the bus warns about it, 0 disables the warnings")
+	flag.DurationVar(&cfg.Bus.UploadingSectorsCacheExpiry, "bus.uploadingSectorsCacheExpiry", cfg.Bus.UploadingSectorsCacheExpiry, "Expiry for sectors of ongoing uploads that were never finished")
+	flag.IntVar(&cfg.Bus.UploadingSectorsMaxRoots, "bus.uploadingSectorsMaxRoots", cfg.Bus.UploadingSectorsMaxRoots, "Max number of sector roots of ongoing uploads kept in memory, 0 means no limit")
+	flag.IntVar(&cfg.Bus.HostCacheSize, "bus.hostCacheSize", cfg.Bus.HostCacheSize, "Max number of hosts cached by the bus, 0 disables the cache")
+	flag.DurationVar(&cfg.Bus.HostCacheTTL, "bus.hostCacheTTL", cfg.Bus.HostCacheTTL, "Duration after which a cached host expires, 0 disables the cache")
 	flag.Int64Var(&cfg.Bus.SlabBufferCompletionThreshold, "bus.slabBufferCompletionThreshold", cfg.Bus.SlabBufferCompletionThreshold, "Threshold for slab buffer upload (overrides with RENTERD_BUS_SLAB_BUFFER_COMPLETION_THRESHOLD)")
+	flag.DurationVar(&cfg.Bus.SlabBufferMaxAge, "bus.slabBufferMaxAge", cfg.Bus.SlabBufferMaxAge, "Max age of a partially filled slab buffer before it is sealed and picked up by the next packed slab upload, 0 means buffers are only uploaded once full")
 
 	// worker
 	flag.BoolVar(&cfg.Worker.AllowPrivateIPs, "worker.allowPrivateIPs", cfg.Worker.AllowPrivateIPs, "Allows hosts with private IPs")
 	flag.DurationVar(&cfg.Worker.BusFlushInterval, "worker.busFlushInterval", cfg.Worker.BusFlushInterval, "Interval for flushing data to bus")
 	flag.Uint64Var(&cfg.Worker.DownloadMaxOverdrive, "worker.downloadMaxOverdrive", cfg.Worker.DownloadMaxOverdrive, "Max overdrive workers for downloads")
+	flag.Uint64Var(&cfg.Worker.DownloadOverfetch, "worker.downloadOverfetch", cfg.Worker.DownloadOverfetch, "Number of extra sectors to request up front when downloading a slab")
+	flag.Uint64Var(&cfg.Worker.DownloadCacheSize, "worker.downloadCacheSize", cfg.Worker.DownloadCacheSize, "Max amount of RAM the worker uses to cache downloaded sectors, 0 disables the cache")
 	flag.StringVar(&cfg.Worker.ID, "worker.id", cfg.Worker.ID, "Unique ID for worker (overrides with RENTERD_WORKER_ID)")
 	flag.DurationVar(&cfg.Worker.DownloadOverdriveTimeout, "worker.downloadOverdriveTimeout", cfg.Worker.DownloadOverdriveTimeout, "Timeout for overdriving slab downloads")
 	flag.Uint64Var(&cfg.Worker.UploadMaxMemory, "worker.uploadMaxMemory", cfg.Worker.UploadMaxMemory, "Max amount of RAM the worker allocates for slabs when uploading (overrides with RENTERD_WORKER_UPLOAD_MAX_MEMORY)")
 	flag.Uint64Var(&cfg.Worker.UploadMaxOverdrive, "worker.uploadMaxOverdrive", cfg.Worker.UploadMaxOverdrive, "Max overdrive workers for uploads")
+	flag.Uint64Var(&cfg.Worker.UploadMaxConcurrentSlabs, "worker.uploadMaxConcurrentSlabs", cfg.Worker.UploadMaxConcurrentSlabs, "Max number of slabs of a single object that are uploaded concurrently, 0 means it's only limited by uploadMaxMemory. It can only lower the concurrency, raising it beyond what uploadMaxMemory allows has no effect")
+	flag.Uint64Var(&cfg.Worker.UploadMaxSectorRetries, "worker.uploadMaxSectorRetries", cfg.Worker.UploadMaxSectorRetries, "Max number of times a failed sector upload is retried on another host per slab, 0 means it's retried until no hosts are left")
 	flag.DurationVar(&cfg.Worker.UploadOverdriveTimeout, "worker.uploadOverdriveTimeout", cfg.Worker.UploadOverdriveTimeout, "Timeout for overdriving slab uploads")
 	flag.BoolVar(&cfg.Worker.Enabled, "worker.enabled", cfg.Worker.Enabled, "Enables/disables worker (overrides with RENTERD_WORKER_ENABLED)")
+	flag.Uint64Var(&cfg.Worker.DownloadRateLimit, "worker.downloadRateLimit", cfg.Worker.DownloadRateLimit, "Max download bandwidth in bytes per second across all hosts, 0 means unlimited")
+	flag.Uint64Var(&cfg.Worker.DownloadRateLimitPerConn, "worker.downloadRateLimitPerConn", cfg.Worker.DownloadRateLimitPerConn, "Max download bandwidth in bytes per second per host connection, 0 means unlimited")
+	flag.Uint64Var(&cfg.Worker.UploadRateLimit, "worker.uploadRateLimit", cfg.Worker.UploadRateLimit, "Max upload bandwidth in bytes per second across all hosts, 0 means unlimited")
+	flag.Uint64Var(&cfg.Worker.UploadRateLimitPerConn, "worker.uploadRateLimitPerConn", cfg.Worker.UploadRateLimitPerConn, "Max upload bandwidth in bytes per second per host connection, 0 means unlimited")
 	flag.BoolVar(&cfg.Worker.AllowUnauthenticatedDownloads, "worker.unauthenticatedDownloads", cfg.Worker.AllowUnauthenticatedDownloads, "Allows unauthenticated downloads (overrides with RENTERD_WORKER_UNAUTHENTICATED_DOWNLOADS)")
 
 	// autopilot
@@ -293,9 +323,14 @@ func main() {
 	flag.DurationVar(&cfg.Autopilot.Heartbeat, "autopilot.heartbeat", cfg.Autopilot.Heartbeat, "Interval for autopilot loop execution")
 	flag.Float64Var(&cfg.Autopilot.MigrationHealthCutoff, "autopilot.migrationHealthCutoff", cfg.Autopilot.MigrationHealthCutoff, "Threshold for migrating slabs based on health")
 	flag.DurationVar(&cfg.Autopilot.RevisionBroadcastInterval, "autopilot.revisionBroadcastInterval", cfg.Autopilot.RevisionBroadcastInterval, "Interval for broadcasting contract revisions (overrides with RENTERD_AUTOPILOT_REVISION_BROADCAST_INTERVAL)")
-	flag.Uint64Var(&cfg.Autopilot.ScannerBatchSize, "autopilot.scannerBatchSize", cfg.Autopilot.ScannerBatchSize, "Batch size for host scanning")
+	flag.Uint64Var(&cfg.Autopilot.ScannerBatchSize, "autopilot.scannerBatchSize", cfg.Autopilot.ScannerBatchSize, "Initial number of hosts fetched per batch when scanning, it also limits the number of scans in flight when it's lower than the number of scan threads")
+	flag.Uint64Var(&cfg.Autopilot.ScannerBatchSizeMin, "autopilot.scannerBatchSizeMin", cfg.Autopilot.ScannerBatchSizeMin, "Lower bound for the adaptive host scanning batch size, which limits the number of scans in flight")
+	flag.Uint64Var(&cfg.Autopilot.ScannerBatchSizeMax, "autopilot.scannerBatchSizeMax", cfg.Autopilot.ScannerBatchSizeMax, "Upper bound for the adaptive host scanning batch size, the number of scans in flight never exceeds the number of scan threads")
 	flag.DurationVar(&cfg.Autopilot.ScannerInterval, "autopilot.scannerInterval", cfg.Autopilot.ScannerInterval, "Interval for scanning hosts")
-	flag.Uint64Var(&cfg.Autopilot.ScannerNumThreads, "autopilot.scannerNumThreads", cfg.Autopilot.ScannerNumThreads, "Number of threads for scanning hosts")
+	flag.Uint64Var(&cfg.Autopilot.ScannerNumThreads, "autopilot.scannerNumThreads", cfg.Autopilot.ScannerNumThreads, "Number of hosts scanned concurrently")
+	flag.Uint64Var(&cfg.Autopilot.ScannerHistorySize, "autopilot.scannerHistorySize", cfg.Autopilot.ScannerHistorySize, "Number of recent host scan results kept for diagnostics")
+	flag.Uint64Var(&cfg.Autopilot.ScannerMinRecentScanFailures, "autopilot.scannerMinRecentScanFailures", cfg.Autopilot.ScannerMinRecentScanFailures, "Lower bound for the number of recent scan failures before an offline host is removed, overrides lower values in the autopilot config (0 disables it)")
+	flag.Uint64Var(&cfg.Autopilot.ContractSelectionSeed, "autopilot.contractSelectionSeed", cfg.Autopilot.ContractSelectionSeed, "Seed for the RNG used to select hosts to form contracts with, makes host selection reproducible for testing, 0 means a random seed is used")
 	flag.Uint64Var(&cfg.Autopilot.MigratorParallelSlabsPerWorker, "autopilot.migratorParallelSlabsPerWorker", cfg.Autopilot.MigratorParallelSlabsPerWorker, "Parallel slab migrations per worker (overrides with RENTERD_MIGRATOR_PARALLEL_SLABS_PER_WORKER)")
 	flag.BoolVar(&cfg.Autopilot.Enabled, "autopilot.enabled", cfg.Autopilot.Enabled, "Enables/disables autopilot (overrides with RENTERD_AUTOPILOT_ENABLED)")
 	flag.DurationVar(&cfg.ShutdownTimeout, "node.shutdownTimeout", cfg.ShutdownTimeout, "Timeout for node shutdown")
@@ -499,7 +534,10 @@ func main() {
 	busAddr, busPassword := cfg.Bus.RemoteAddr, cfg.Bus.RemotePassword
 	if cfg.Bus.RemoteAddr == "" {
 		b, fn, err := node.NewBus(busCfg, cfg.Directory, seed, logger)
-		if err != nil {
+		if errors.Is(err, stores.ErrMigrationsDryRun) {
+			logger.Info("database migrations dry run finished, no migrations were applied")
+			return
+		} else if err != nil {
 			logger.Fatal("failed to create bus, err: " + err.Error())
 		}
 		shutdownFns = append(shutdownFns, shutdownFn{
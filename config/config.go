@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"time"
+
+	"go.sia.tech/core/types"
 )
 
 type (
@@ -40,20 +42,42 @@ type (
 
 	Database struct {
 		Log DatabaseLog `yaml:"log,omitempty"` // deprecated. included for compatibility.
+		// MigrationsDryRun logs the pending migrations and exits without
+		// applying them.
+		MigrationsDryRun bool `yaml:"migrationsDryRun,omitempty"`
 		// optional fields depending on backend
 		MySQL MySQL `yaml:"mysql,omitempty"`
 	}
 
 	// Bus contains the configuration for a bus.
 	Bus struct {
-		AnnouncementMaxAgeHours       uint64        `yaml:"announcementMaxAgeHours,omitempty"`
-		Bootstrap                     bool          `yaml:"bootstrap,omitempty"`
-		GatewayAddr                   string        `yaml:"gatewayAddr,omitempty"`
-		RemoteAddr                    string        `yaml:"remoteAddr,omitempty"`
-		RemotePassword                string        `yaml:"remotePassword,omitempty"`
-		PersistInterval               time.Duration `yaml:"persistInterval,omitempty"`
-		UsedUTXOExpiry                time.Duration `yaml:"usedUtxoExpiry,omitempty"`
-		SlabBufferCompletionThreshold int64         `yaml:"slabBufferCompleionThreshold,omitempty"`
+		AnnouncementMaxAgeHours       uint64         `yaml:"announcementMaxAgeHours,omitempty"`
+		Bootstrap                     bool           `yaml:"bootstrap,omitempty"`
+		GatewayAddr                   string         `yaml:"gatewayAddr,omitempty"`
+		RemoteAddr                    string         `yaml:"remoteAddr,omitempty"`
+		RemotePassword                string         `yaml:"remotePassword,omitempty"`
+		PersistInterval               time.Duration  `yaml:"persistInterval,omitempty"`
+		UsedUTXOExpiry                time.Duration  `yaml:"usedUtxoExpiry,omitempty"`
+		CoinSelection                 string         `yaml:"coinSelection,omitempty"`
+		MaxFee                        types.Currency `yaml:"maxFee,omitempty"`
+		ContractExpiryWarningWindow   uint64         `yaml:"contractExpiryWarningWindow,omitempty"`
+		WalletAddresses               uint64         `yaml:"walletAddresses,omitempty"`
+		SlabBufferCompletionThreshold int64          `yaml:"slabBufferCompleionThreshold,omitempty"`
+		SlabBufferMaxAge              time.Duration  `yaml:"slabBufferMaxAge,omitempty"`
+		UploadingSectorsCacheExpiry   time.Duration  `yaml:"uploadingSectorsCacheExpiry,omitempty"`
+		UploadingSectorsMaxRoots      int            `yaml:"uploadingSectorsMaxRoots,omitempty"`
+		HostCacheSize                 int            `yaml:"hostCacheSize,omitempty"`
+		HostCacheTTL                  time.Duration  `yaml:"hostCacheTTL,omitempty"`
+
+		// ConsensusCheckpoint is a trusted chain index the bus subscribes to
+		// consensus from instead of the genesis block when its database is
+		// empty, i.e. it contains no consensus state, contracts or wallet
+		// data. Blocks up to and including the checkpoint are not processed,
+		// so host announcements and wallet outputs from before the
+		// checkpoint are unknown to the bus. It should therefore predate the
+		// wallet's first transaction. The checkpoint is ignored when the
+		// consensus state is reset, the bus then resyncs from genesis.
+		ConsensusCheckpoint types.ChainIndex `yaml:"consensusCheckpoint,omitempty"`
 	}
 
 	// LogFile configures the file output of the logger.
@@ -120,24 +144,45 @@ type (
 		DownloadOverdriveTimeout      time.Duration  `yaml:"downloadOverdriveTimeout,omitempty"`
 		UploadOverdriveTimeout        time.Duration  `yaml:"uploadOverdriveTimeout,omitempty"`
 		DownloadMaxOverdrive          uint64         `yaml:"downloadMaxOverdrive,omitempty"`
+		DownloadOverfetch             uint64         `yaml:"downloadOverfetch,omitempty"`
 		DownloadMaxMemory             uint64         `yaml:"downloadMaxMemory,omitempty"`
+		DownloadCacheSize             uint64         `yaml:"downloadCacheSize,omitempty"`
 		UploadMaxMemory               uint64         `yaml:"uploadMaxMemory,omitempty"`
 		UploadMaxOverdrive            uint64         `yaml:"uploadMaxOverdrive,omitempty"`
+		UploadMaxConcurrentSlabs      uint64         `yaml:"uploadMaxConcurrentSlabs,omitempty"`
+		UploadMaxSectorRetries        uint64         `yaml:"uploadMaxSectorRetries,omitempty"`
 		AllowUnauthenticatedDownloads bool           `yaml:"allowUnauthenticatedDownloads,omitempty"`
+
+		// rate limits in bytes per second, 0 means unlimited
+		DownloadRateLimit        uint64 `yaml:"downloadRateLimit,omitempty"`
+		DownloadRateLimitPerConn uint64 `yaml:"downloadRateLimitPerConn,omitempty"`
+		UploadRateLimit          uint64 `yaml:"uploadRateLimit,omitempty"`
+		UploadRateLimitPerConn   uint64 `yaml:"uploadRateLimitPerConn,omitempty"`
 	}
 
 	// Autopilot contains the configuration for an autopilot.
 	Autopilot struct {
-		Enabled                        bool          `yaml:"enabled,omitempty"`
-		AccountsRefillInterval         time.Duration `yaml:"accountsRefillInterval,omitempty"`
-		Heartbeat                      time.Duration `yaml:"heartbeat,omitempty"`
-		MigrationHealthCutoff          float64       `yaml:"migrationHealthCutoff,omitempty"`
-		RevisionBroadcastInterval      time.Duration `yaml:"revisionBroadcastInterval,omitempty"`
-		RevisionSubmissionBuffer       uint64        `yaml:"revisionSubmissionBuffer,omitempty"`
-		ScannerInterval                time.Duration `yaml:"scannerInterval,omitempty"`
-		ScannerBatchSize               uint64        `yaml:"scannerBatchSize,omitempty"`
-		ScannerNumThreads              uint64        `yaml:"scannerNumThreads,omitempty"`
-		MigratorParallelSlabsPerWorker uint64        `yaml:"migratorParallelSlabsPerWorker,omitempty"`
+		Enabled                        bool           `yaml:"enabled,omitempty"`
+		AccountsRefillInterval         time.Duration  `yaml:"accountsRefillInterval,omitempty"`
+		AccountsMinBalance             types.Currency `yaml:"accountsMinBalance,omitempty"`
+		AccountsMaxBalance             types.Currency `yaml:"accountsMaxBalance,omitempty"`
+		AccountsMaxDrift               types.Currency `yaml:"accountsMaxDrift,omitempty"`
+		Heartbeat                      time.Duration  `yaml:"heartbeat,omitempty"`
+		MigrationHealthCutoff          float64        `yaml:"migrationHealthCutoff,omitempty"`
+		RevisionBroadcastInterval      time.Duration  `yaml:"revisionBroadcastInterval,omitempty"`
+		RevisionSubmissionBuffer       uint64         `yaml:"revisionSubmissionBuffer,omitempty"`
+		ScannerInterval                time.Duration  `yaml:"scannerInterval,omitempty"`
+		ScannerBatchSize               uint64         `yaml:"scannerBatchSize,omitempty"`
+		ScannerBatchSizeMin            uint64         `yaml:"scannerBatchSizeMin,omitempty"`
+		ScannerBatchSizeMax            uint64         `yaml:"scannerBatchSizeMax,omitempty"`
+		ScannerNumThreads              uint64         `yaml:"scannerNumThreads,omitempty"`
+		ScannerHistorySize             uint64         `yaml:"scannerHistorySize,omitempty"`
+		ScannerMinRecentScanFailures   uint64         `yaml:"scannerMinRecentScanFailures,omitempty"`
+		MigratorParallelSlabsPerWorker uint64         `yaml:"migratorParallelSlabsPerWorker,omitempty"`
+
+		// ContractSelectionSeed seeds the RNG used to select hosts to form
+		// contracts with, 0 means a random seed is used
+		ContractSelectionSeed uint64 `yaml:"contractSelectionSeed,omitempty"`
 	}
 )
 
@@ -127,6 +127,11 @@ func (m *chainManager) Subscribe(s modules.ConsensusSetSubscriber, ccID modules.
 	return nil
 }
 
+// Unsubscribe removes a subscriber from the consensus set.
+func (m *chainManager) Unsubscribe(s modules.ConsensusSetSubscriber) {
+	m.cs.Unsubscribe(s)
+}
+
 // PoolTransactions returns all transactions in the transaction pool
 func (m *chainManager) PoolTransactions() []types.Transaction {
 	return m.tp.Transactions()
@@ -14,6 +14,7 @@ import (
 	"go.sia.tech/core/consensus"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/alerts"
+	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/autopilot"
 	"go.sia.tech/renterd/bus"
 	"go.sia.tech/renterd/config"
@@ -22,11 +23,13 @@ import (
 	"go.sia.tech/renterd/webhooks"
 	"go.sia.tech/renterd/worker"
 	"go.sia.tech/renterd/worker/s3"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	mconsensus "go.sia.tech/siad/modules/consensus"
 	"go.sia.tech/siad/modules/gateway"
 	"go.sia.tech/siad/modules/transactionpool"
 	"go.sia.tech/siad/sync"
+	stypes "go.sia.tech/siad/types"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/blake2b"
 	"gorm.io/gorm"
@@ -96,6 +99,7 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 
 	// create database connections
 	var dbConn, dbMetricsConn gorm.Dialector
+	var migrationBackupPath string
 	if cfg.Database.MySQL.URI != "" {
 		// create MySQL connections
 		dbConn = stores.NewMySQLConnection(
@@ -120,6 +124,9 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 		// create SQLite connections
 		dbConn = stores.NewSQLiteConnection(filepath.Join(dbDir, "db.sqlite"))
 		dbMetricsConn = stores.NewSQLiteConnection(filepath.Join(dbDir, "metrics.sqlite"))
+
+		// back up the database before destructive migrations are applied
+		migrationBackupPath = filepath.Join(dbDir, fmt.Sprintf("db.sqlite.%d.bak", time.Now().Unix()))
 	}
 
 	// create database logger
@@ -133,7 +140,11 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 	}
 
 	alertsMgr := alerts.NewManager()
-	walletAddr := wallet.StandardAddress(seed.PublicKey())
+	numWalletAddrs := cfg.WalletAddresses
+	if numWalletAddrs == 0 {
+		numWalletAddrs = 1 // primary address only
+	}
+	walletAddrs := wallet.DeriveAddresses(seed, numWalletAddrs)
 	sqlStoreDir := filepath.Join(dir, "partial_slabs")
 	announcementMaxAge := time.Duration(cfg.AnnouncementMaxAgeHours) * time.Hour
 	sqlStore, ccid, err := stores.NewSQLStore(stores.Config{
@@ -144,13 +155,18 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 		Migrate:                       true,
 		AnnouncementMaxAge:            announcementMaxAge,
 		PersistInterval:               cfg.PersistInterval,
-		WalletAddress:                 walletAddr,
+		WalletAddresses:               walletAddrs,
 		SlabBufferCompletionThreshold: cfg.SlabBufferCompletionThreshold,
+		SlabBufferMaxAge:              cfg.SlabBufferMaxAge,
 		Logger:                        l.Sugar(),
 		GormLogger:                    dbLogger,
 		RetryTransactionIntervals:     []time.Duration{200 * time.Millisecond, 500 * time.Millisecond, time.Second, 3 * time.Second, 10 * time.Second, 10 * time.Second},
 		LongQueryDuration:             cfg.DatabaseLog.SlowThreshold,
 		LongTxDuration:                cfg.DatabaseLog.SlowThreshold,
+		MigrationBackupPath:           migrationBackupPath,
+		MigrationsDryRun:              cfg.Database.MigrationsDryRun,
+		HostCacheSize:                 cfg.HostCacheSize,
+		HostCacheTTL:                  cfg.HostCacheTTL,
 	})
 	if err != nil {
 		return nil, nil, err
@@ -163,12 +179,48 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 	// Hook up webhooks to alerts.
 	alertsMgr.RegisterWebhookBroadcaster(hooksMgr)
 
+	// subscribeFromCheckpoint subscribes the store from the configured
+	// checkpoint, falling back to the beginning if the checkpoint can't be
+	// used, it must only be used for stores that are empty
+	subscribeFromCheckpoint := func(cancel <-chan struct{}) error {
+		checkpointCCID, err := checkpointConsensusChangeID(cs, cfg.ConsensusCheckpoint)
+		if err == nil {
+			l.Info(fmt.Sprintf("subscribing to consensus from checkpoint %v", cfg.ConsensusCheckpoint))
+			err = cs.ConsensusSetSubscribe(sqlStore, checkpointCCID, cancel)
+			if !errors.Is(err, modules.ErrInvalidConsensusChangeID) {
+				return err
+			}
+		}
+		l.Warn(fmt.Sprintf("unable to subscribe from consensus checkpoint %v, subscribing from the beginning: %v", cfg.ConsensusCheckpoint, err))
+		return cs.ConsensusSetSubscribe(sqlStore, modules.ConsensusChangeBeginning, cancel)
+	}
+
+	// only use the checkpoint if the store is empty, otherwise we'd lose all
+	// data that references heights before the checkpoint
+	var useCheckpoint bool
+	if cfg.ConsensusCheckpoint != (types.ChainIndex{}) && ccid == modules.ConsensusChangeBeginning {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		empty, err := sqlStore.IsEmpty(ctx)
+		cancel()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to check whether the database is empty: %w", err)
+		} else if !empty {
+			l.Warn(fmt.Sprintf("ignoring consensus checkpoint %v, the database is not empty", cfg.ConsensusCheckpoint))
+		}
+		useCheckpoint = empty
+	}
+
 	cancelSubscribe := make(chan struct{})
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 		defer cancel()
 
-		subscribeErr := cs.ConsensusSetSubscribe(sqlStore, ccid, cancelSubscribe)
+		var subscribeErr error
+		if useCheckpoint {
+			subscribeErr = subscribeFromCheckpoint(cancelSubscribe)
+		} else {
+			subscribeErr = cs.ConsensusSetSubscribe(sqlStore, ccid, cancelSubscribe)
+		}
 		if errors.Is(subscribeErr, modules.ErrInvalidConsensusChangeID) {
 			l.Warn("Invalid consensus change ID detected - resyncing consensus")
 			// Reset the consensus state within the database and rescan.
@@ -176,7 +228,9 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 				l.Fatal(fmt.Sprintf("Failed to reset consensus subscription of SQLStore: %v", err))
 				return
 			}
-			// Subscribe from the beginning.
+			// Subscribe from the beginning, the checkpoint is never used
+			// after a reset since the store might still contain contracts
+			// that reference earlier heights.
 			subscribeErr = cs.ConsensusSetSubscribe(sqlStore, modules.ConsensusChangeBeginning, cancelSubscribe)
 		}
 		if subscribeErr != nil && !errors.Is(subscribeErr, sync.ErrStopped) {
@@ -184,7 +238,12 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 		}
 	}()
 
-	w := wallet.NewSingleAddressWallet(seed, sqlStore, cfg.UsedUTXOExpiry, zap.NewNop().Sugar())
+	w := wallet.NewSingleAddressWalletWithAddresses(seed, numWalletAddrs, sqlStore, cfg.UsedUTXOExpiry, zap.NewNop().Sugar())
+	if cfg.CoinSelection != "" {
+		if err := w.SetCoinSelectionStrategy(api.CoinSelectionStrategy(cfg.CoinSelection)); err != nil {
+			return nil, nil, err
+		}
+	}
 	tp.TransactionPoolSubscribe(w)
 	if err := cs.ConsensusSetSubscribe(w, modules.ConsensusChangeRecent, nil); err != nil {
 		return nil, nil, err
@@ -202,7 +261,18 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 		return nil, nil, err
 	}
 
-	b, err := bus.New(syncer{g, tp}, alertsMgr, hooksMgr, cm, NewTransactionPool(tp), w, sqlStore, sqlStore, sqlStore, sqlStore, sqlStore, sqlStore, l)
+	busOpts := []bus.Option{bus.WithContractExpiryWarningWindow(cfg.ContractExpiryWarningWindow)}
+	if !cfg.MaxFee.IsZero() {
+		busOpts = append(busOpts, bus.WithMaxFee(cfg.MaxFee))
+	}
+	if cfg.UploadingSectorsCacheExpiry != 0 {
+		busOpts = append(busOpts, bus.WithUploadingSectorsCacheExpiry(cfg.UploadingSectorsCacheExpiry))
+	}
+	if cfg.UploadingSectorsMaxRoots != 0 {
+		busOpts = append(busOpts, bus.WithUploadingSectorsMaxCachedRoots(cfg.UploadingSectorsMaxRoots))
+	}
+
+	b, err := bus.New(syncer{g, tp}, alertsMgr, hooksMgr, cm, NewTransactionPool(tp), w, sqlStore, sqlStore, sqlStore, sqlStore, sqlStore, sqlStore, l, busOpts...)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -222,10 +292,16 @@ func NewBus(cfg BusConfig, dir string, seed types.PrivateKey, l *zap.Logger) (ht
 
 func NewWorker(cfg config.Worker, s3Opts s3.Opts, b Bus, seed types.PrivateKey, l *zap.Logger) (http.Handler, http.Handler, ShutdownFn, error) {
 	workerKey := blake2b.Sum256(append([]byte("worker"), seed...))
-	w, err := worker.New(workerKey, cfg.ID, b, cfg.ContractLockTimeout, cfg.BusFlushInterval, cfg.DownloadOverdriveTimeout, cfg.UploadOverdriveTimeout, cfg.DownloadMaxOverdrive, cfg.UploadMaxOverdrive, cfg.DownloadMaxMemory, cfg.UploadMaxMemory, cfg.AllowPrivateIPs, l)
+	w, err := worker.New(cfg, workerKey, b, l)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	w.UpdateRateLimits(api.WorkerRateLimits{
+		Download:        cfg.DownloadRateLimit,
+		DownloadPerConn: cfg.DownloadRateLimitPerConn,
+		Upload:          cfg.UploadRateLimit,
+		UploadPerConn:   cfg.UploadRateLimitPerConn,
+	})
 	s3Handler, err := s3.New(b, w, l.Named("s3").Sugar(), s3Opts)
 	if err != nil {
 		err = errors.Join(err, w.Shutdown(context.Background()))
@@ -235,13 +311,39 @@ func NewWorker(cfg config.Worker, s3Opts s3.Opts, b Bus, seed types.PrivateKey,
 }
 
 func NewAutopilot(cfg AutopilotConfig, b autopilot.Bus, workers []autopilot.Worker, l *zap.Logger) (http.Handler, RunFn, ShutdownFn, error) {
-	ap, err := autopilot.New(cfg.ID, b, workers, l, cfg.Heartbeat, cfg.ScannerInterval, cfg.ScannerBatchSize, cfg.ScannerNumThreads, cfg.MigrationHealthCutoff, cfg.AccountsRefillInterval, cfg.RevisionSubmissionBuffer, cfg.MigratorParallelSlabsPerWorker, cfg.RevisionBroadcastInterval)
+	ap, err := autopilot.New(cfg.Autopilot, cfg.ID, b, workers, l)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	return ap.Handler(), ap.Run, ap.Shutdown, nil
 }
 
+// checkpointConsensusChangeID returns the ID of the consensus change that
+// applied the block at the given checkpoint, subscribing with that ID skips all
+// blocks up to and including the checkpoint. The consensus set has to be synced
+// past the checkpoint for its block to be validated against the checkpoint.
+//
+// NOTE: the ID is only valid if the block wasn't applied together with other
+// blocks, e.g. during a reorg, in which case subscribing with it fails with
+// modules.ErrInvalidConsensusChangeID.
+func checkpointConsensusChangeID(cs modules.ConsensusSet, checkpoint types.ChainIndex) (modules.ConsensusChangeID, error) {
+	b, exists := cs.BlockAtHeight(stypes.BlockHeight(checkpoint.Height))
+	if !exists {
+		return modules.ConsensusChangeID{}, fmt.Errorf("consensus set is not synced to height %d", checkpoint.Height)
+	} else if types.BlockID(b.ID()) != checkpoint.ID {
+		return modules.ConsensusChangeID{}, fmt.Errorf("block at height %d has id %v", checkpoint.Height, types.BlockID(b.ID()))
+	}
+
+	// NOTE: this mirrors the ID of a change entry in siad's consensus change
+	// log, which is the hash of a pointer to the entry
+	return modules.ConsensusChangeID(crypto.HashObject(&struct {
+		RevertedBlocks []stypes.BlockID
+		AppliedBlocks  []stypes.BlockID
+	}{
+		AppliedBlocks: []stypes.BlockID{b.ID()},
+	})), nil
+}
+
 func gormLogLevel(cfg config.DatabaseLog) logger.LogLevel {
 	level := logger.Silent
 	if cfg.Enabled {
@@ -0,0 +1,58 @@
+package node
+
+import (
+	"testing"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/siad/modules"
+	mconsensus "go.sia.tech/siad/modules/consensus"
+	"go.sia.tech/siad/modules/gateway"
+)
+
+type ccidRecorder struct {
+	ids []modules.ConsensusChangeID
+}
+
+func (r *ccidRecorder) ProcessConsensusChange(cc modules.ConsensusChange) {
+	r.ids = append(r.ids, cc.ID)
+}
+
+// TestCheckpointConsensusChangeID asserts the consensus change id derived from
+// a checkpoint matches the id of the corresponding entry in siad's change log.
+func TestCheckpointConsensusChangeID(t *testing.T) {
+	dir := t.TempDir()
+	g, err := gateway.New("localhost:0", false, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	cs, errCh := mconsensus.New(g, false, dir)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	// record the id of the change that applied the genesis block
+	var r ccidRecorder
+	if err := cs.ConsensusSetSubscribe(&r, modules.ConsensusChangeBeginning, nil); err != nil {
+		t.Fatal(err)
+	} else if len(r.ids) != 1 {
+		t.Fatalf("unexpected number of changes, %v != 1", len(r.ids))
+	}
+
+	// assert the checkpoint id matches
+	genesis, _ := cs.BlockAtHeight(0)
+	ccid, err := checkpointConsensusChangeID(cs, types.ChainIndex{Height: 0, ID: types.BlockID(genesis.ID())})
+	if err != nil {
+		t.Fatal(err)
+	} else if ccid != r.ids[0] {
+		t.Fatalf("unexpected consensus change id, %v != %v", ccid, r.ids[0])
+	}
+
+	// assert checkpoints that don't match the chain are rejected
+	if _, err := checkpointConsensusChangeID(cs, types.ChainIndex{Height: 0, ID: types.BlockID{1}}); err == nil {
+		t.Fatal("expected error")
+	} else if _, err := checkpointConsensusChangeID(cs, types.ChainIndex{Height: 1, ID: types.BlockID(genesis.ID())}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -20,6 +20,13 @@ func (tp txpool) RecommendedFee() (fee types.Currency) {
 	return
 }
 
+func (tp txpool) FeeEstimation() (minFee, maxFee types.Currency) {
+	siadMin, siadMax := tp.tp.FeeEstimation()
+	convertToCore(&siadMin, (*types.V1Currency)(&minFee))
+	convertToCore(&siadMax, (*types.V1Currency)(&maxFee))
+	return
+}
+
 func (tp txpool) Transactions() []types.Transaction {
 	stxns := tp.tp.Transactions()
 	txns := make([]types.Transaction, len(stxns))
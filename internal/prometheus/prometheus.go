@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ContentType is the content type of the text-based exposition format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	MetricTypeCounter MetricType = "counter"
+	MetricTypeGauge   MetricType = "gauge"
+)
+
+type (
+	// MetricType is the type of a metric as defined by Prometheus.
+	MetricType string
+
+	// A Metric is a single sample of a metric.
+	Metric struct {
+		Name   string
+		Help   string
+		Type   MetricType
+		Labels map[string]string
+		Value  float64
+	}
+
+	// A Collector is a type that exposes Prometheus metrics.
+	Collector interface {
+		PrometheusMetrics() []Metric
+	}
+)
+
+// Encode writes the given metrics to w using the Prometheus text-based
+// exposition format. Metrics with the same name are grouped together and
+// share a single HELP and TYPE line.
+func Encode(w io.Writer, metrics []Metric) error {
+	// group metrics by name, preserving the order in which they were added
+	var names []string
+	groups := make(map[string][]Metric)
+	for _, m := range metrics {
+		if _, exists := groups[m.Name]; !exists {
+			names = append(names, m.Name)
+		}
+		groups[m.Name] = append(groups[m.Name], m)
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		group := groups[name]
+		if help := group[0].Help; help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, escape(help, false))
+		}
+		if typ := group[0].Type; typ != "" {
+			fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		}
+		for _, m := range group {
+			bw.WriteString(name)
+			writeLabels(bw, m.Labels)
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(m.Value, 'g', -1, 64))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+func writeLabels(bw *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	bw.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		fmt.Fprintf(bw, "%s=\"%s\"", k, escape(labels[k], true))
+	}
+	bw.WriteByte('}')
+}
+
+func escape(s string, quotes bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quotes {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
@@ -0,0 +1,29 @@
+package prometheus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	err := Encode(&buf, []Metric{
+		{Name: "foo", Help: "foo help", Type: MetricTypeGauge, Labels: map[string]string{"set": "a"}, Value: 1},
+		{Name: "bar", Type: MetricTypeCounter, Value: 1.5},
+		{Name: "foo", Help: "foo help", Type: MetricTypeGauge, Labels: map[string]string{"set": "b\"", "z": "1"}, Value: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP foo foo help
+# TYPE foo gauge
+foo{set="a"} 1
+foo{set="b\"",z="1"} 2
+# TYPE bar counter
+bar 1.5
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected output\n%s", buf.String())
+	}
+}
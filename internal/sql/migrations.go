@@ -1,11 +1,17 @@
 package sql
 
 import (
+	"context"
+	"database/sql"
 	"embed"
+	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 	"unicode/utf8"
 
+	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/internal/utils"
 	"go.uber.org/zap"
 )
@@ -14,6 +20,22 @@ type (
 	Migration struct {
 		ID      string
 		Migrate func(tx Tx) error
+
+		// Destructive indicates the migration drops or rewrites existing
+		// data, the database is backed up before it's applied if a backup
+		// hook is configured.
+		Destructive bool
+	}
+
+	// MigrationOptions configure how migrations are performed.
+	MigrationOptions struct {
+		// DryRun causes the migrations to be planned and logged without
+		// executing them.
+		DryRun bool
+
+		// Backup is called before the first destructive migration is
+		// applied, migrations are aborted if it fails.
+		Backup func(ctx context.Context) error
 	}
 
 	// Migrator is an interface for defining database-specific helper methods
@@ -22,6 +44,11 @@ type (
 		ApplyMigration(func(tx Tx) (bool, error)) error
 		CreateMigrationTable() error
 		DB() *DB
+		HasMigrationTable() (bool, error)
+
+		// SupportsTransactionalDDL returns true if schema changes can be
+		// rolled back as part of a transaction.
+		SupportsTransactionalDDL() bool
 	}
 
 	MainMigrator interface {
@@ -30,6 +57,11 @@ type (
 	}
 )
 
+const (
+	backupStep               = "back up database"
+	createMigrationTableStep = "create migrations table"
+)
+
 var (
 	MainMigrations = func(m MainMigrator, migrationsFs embed.FS, log *zap.SugaredLogger) []Migration {
 		dbIdentifier := "main"
@@ -45,7 +77,8 @@ var (
 				},
 			},
 			{
-				ID: "00002_prune_slabs_trigger",
+				ID:          "00002_prune_slabs_trigger",
+				Destructive: true,
 				Migrate: func(tx Tx) error {
 					err := performMigration(tx, migrationsFs, dbIdentifier, "00002_prune_slabs_trigger", log)
 					if utils.IsErr(err, ErrMySQLNoSuperPrivilege) {
@@ -61,7 +94,8 @@ var (
 				},
 			},
 			{
-				ID: "00004_prune_slabs_cascade",
+				ID:          "00004_prune_slabs_cascade",
+				Destructive: true,
 				Migrate: func(tx Tx) error {
 					return performMigration(tx, migrationsFs, dbIdentifier, "00004_prune_slabs_cascade", log)
 				},
@@ -85,7 +119,8 @@ var (
 				},
 			},
 			{
-				ID: "00008_directories",
+				ID:          "00008_directories",
+				Destructive: true,
 				Migrate: func(tx Tx) error {
 					if err := performMigration(tx, migrationsFs, dbIdentifier, "00008_directories_1", log); err != nil {
 						return fmt.Errorf("failed to migrate: %v", err)
@@ -158,6 +193,220 @@ var (
 					return nil
 				},
 			},
+			{
+				ID: "00009_migrations_applied_at",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00009_migrations_applied_at", log)
+				},
+			},
+			{
+				ID: "00010_archived_contracts_reason",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00010_archived_contracts_reason", log)
+				},
+			},
+			{
+				ID: "00011_account_drifted",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00011_account_drifted", log)
+				},
+			},
+			{
+				ID: "00012_siacoin_elements_address",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00012_siacoin_elements_address", log)
+				},
+			},
+			{
+				ID: "00013_settings_history",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00013_settings_history", log)
+				},
+			},
+			{
+				ID: "00014_settings_version",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00014_settings_version", log)
+				},
+			},
+			{
+				ID: "00015_contract_storage_spending",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00015_contract_storage_spending", log)
+				},
+			},
+			{
+				ID: "00016_host_operations",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00016_host_operations", log)
+				},
+			},
+			{
+				ID: "00017_blocklist_subnets",
+				Migrate: func(tx Tx) error {
+					// exact-match and domain entries are already associated
+					// with their hosts, only subnet entries need recomputing
+					type entry struct {
+						ID     uint
+						Subnet *net.IPNet
+					}
+					var subnets []entry
+					rows, err := tx.Query("SELECT id, entry FROM host_blocklist_entries")
+					if err != nil {
+						return fmt.Errorf("failed to fetch blocklist entries: %w", err)
+					}
+					for rows.Next() {
+						var e entry
+						var value string
+						if err := rows.Scan(&e.ID, &value); err != nil {
+							_ = rows.Close()
+							return fmt.Errorf("failed to scan blocklist entry: %w", err)
+						} else if _, subnet, err := net.ParseCIDR(value); err == nil {
+							e.Subnet = subnet
+							subnets = append(subnets, e)
+						}
+					}
+					if err := rows.Close(); err != nil {
+						return fmt.Errorf("failed to close rows: %w", err)
+					} else if len(subnets) == 0 {
+						return nil
+					}
+
+					// fetch all hosts that announced an IP address
+					type host struct {
+						ID uint
+						IP net.IP
+					}
+					var hosts []host
+					rows, err = tx.Query("SELECT id, net_address FROM hosts")
+					if err != nil {
+						return fmt.Errorf("failed to fetch hosts: %w", err)
+					}
+					for rows.Next() {
+						var h host
+						var netAddress string
+						if err := rows.Scan(&h.ID, &netAddress); err != nil {
+							_ = rows.Close()
+							return fmt.Errorf("failed to scan host: %w", err)
+						}
+						addr, _, err := net.SplitHostPort(netAddress)
+						if err != nil {
+							addr = netAddress
+						}
+						if h.IP = net.ParseIP(addr); h.IP != nil {
+							hosts = append(hosts, h)
+						}
+					}
+					if err := rows.Close(); err != nil {
+						return fmt.Errorf("failed to close rows: %w", err)
+					}
+
+					// associate the hosts with the subnets they lie in
+					for _, e := range subnets {
+						if _, err := tx.Exec("DELETE FROM host_blocklist_entry_hosts WHERE db_blocklist_entry_id = ?", e.ID); err != nil {
+							return fmt.Errorf("failed to delete associations for blocklist entry %d: %w", e.ID, err)
+						}
+						for _, h := range hosts {
+							if !e.Subnet.Contains(h.IP) {
+								continue
+							} else if _, err := tx.Exec("INSERT INTO host_blocklist_entry_hosts (db_blocklist_entry_id, db_host_id) VALUES (?, ?)", e.ID, h.ID); err != nil {
+								return fmt.Errorf("failed to associate host %d with blocklist entry %d: %w", h.ID, e.ID, err)
+							}
+						}
+					}
+					log.Infof("recomputed host associations for %d subnet blocklist entries", len(subnets))
+					return nil
+				},
+			},
+			{
+				ID: "00019_host_checks_gouging_reasons",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00019_host_checks_gouging_reasons", log)
+				},
+			},
+			{
+				ID: "00020_host_bandwidth",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00020_host_bandwidth", log)
+				},
+			},
+			{
+				ID: "00021_composite_indexes",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00021_composite_indexes", log)
+				},
+			},
+			{
+				ID: "00022_host_pending_removal",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00022_host_pending_removal", log)
+				},
+			},
+			{
+				ID: "00023_host_overrides",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00023_host_overrides", log)
+				},
+			},
+			{
+				ID: "00024_transaction_types",
+				Migrate: func(tx Tx) error {
+					if err := performMigration(tx, migrationsFs, dbIdentifier, "00024_transaction_types", log); err != nil {
+						return err
+					}
+
+					// infer the type of existing transactions
+					type txn struct {
+						ID  uint
+						Typ string
+					}
+					var txns []txn
+					rows, err := tx.Query("SELECT id, raw, inflow, outflow FROM transactions")
+					if err != nil {
+						return fmt.Errorf("failed to fetch transactions: %w", err)
+					}
+					for rows.Next() {
+						var t txn
+						var raw, inflow, outflow sql.NullString
+						if err := rows.Scan(&t.ID, &raw, &inflow, &outflow); err != nil {
+							_ = rows.Close()
+							return fmt.Errorf("failed to scan transaction: %w", err)
+						}
+						var rawTxn types.Transaction
+						if raw.Valid && raw.String != "" {
+							if err := json.Unmarshal([]byte(raw.String), &rawTxn); err != nil {
+								_ = rows.Close()
+								return fmt.Errorf("failed to decode transaction %d: %w", t.ID, err)
+							}
+						}
+						var in, out types.Currency
+						if inflow.Valid && inflow.String != "" {
+							if in, err = types.ParseCurrency(inflow.String); err != nil {
+								_ = rows.Close()
+								return fmt.Errorf("failed to parse inflow of transaction %d: %w", t.ID, err)
+							}
+						}
+						if outflow.Valid && outflow.String != "" {
+							if out, err = types.ParseCurrency(outflow.String); err != nil {
+								_ = rows.Close()
+								return fmt.Errorf("failed to parse outflow of transaction %d: %w", t.ID, err)
+							}
+						}
+						t.Typ = transactionTypeV00024(rawTxn, in, out)
+						txns = append(txns, t)
+					}
+					if err := rows.Close(); err != nil {
+						return fmt.Errorf("failed to close rows: %w", err)
+					}
+					for _, t := range txns {
+						if _, err := tx.Exec("UPDATE transactions SET `type` = ? WHERE id = ?", t.Typ, t.ID); err != nil {
+							return fmt.Errorf("failed to update type of transaction %d: %w", t.ID, err)
+						}
+					}
+					log.Infof("inferred the type of %d transactions", len(txns))
+					return nil
+				},
+			},
 		}
 	}
 	MetricsMigrations = func(migrationsFs embed.FS, log *zap.SugaredLogger) []Migration {
@@ -173,50 +422,239 @@ var (
 					return performMigration(tx, migrationsFs, dbIdentifier, "00001_idx_contracts_fcid_timestamp", log)
 				},
 			},
+			{
+				ID: "00002_migrations_applied_at",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00002_migrations_applied_at", log)
+				},
+			},
+			{
+				ID: "00003_contract_storage_spending",
+				Migrate: func(tx Tx) error {
+					return performMigration(tx, migrationsFs, dbIdentifier, "00003_contract_storage_spending", log)
+				},
+			},
 		}
 	}
 )
 
-func PerformMigrations(m Migrator, fs embed.FS, identifier string, migrations []Migration) error {
+// PerformMigrations initializes the schema of an empty database or applies the
+// migrations that weren't applied yet. It returns the ordered list of steps it
+// performed, when performing a dry run the steps are only logged and returned.
+func PerformMigrations(ctx context.Context, m Migrator, fs embed.FS, identifier string, migrations []Migration, log *zap.SugaredLogger, opts MigrationOptions) (plan []string, err error) {
+	if opts.DryRun {
+		return planMigrations(m, identifier, migrations, log, opts)
+	}
+
 	// try to create migrations table
-	err := m.CreateMigrationTable()
+	if exists, err := m.HasMigrationTable(); err != nil {
+		return nil, fmt.Errorf("failed to check for migrations table: %w", err)
+	} else if !exists {
+		plan = append(plan, createMigrationTableStep)
+	}
+	err = m.CreateMigrationTable()
 	if err != nil {
-		return fmt.Errorf("failed to create migrations table: %w", err)
+		return nil, fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
 	// check if the migrations table is empty
 	var isEmpty bool
 	if err := m.DB().QueryRow("SELECT COUNT(*) = 0 FROM migrations").Scan(&isEmpty); err != nil {
-		return fmt.Errorf("failed to count rows in migrations table: %w", err)
+		return nil, fmt.Errorf("failed to count rows in migrations table: %w", err)
 	} else if isEmpty {
 		// table is empty, init schema
-		return initSchema(m.DB(), fs, identifier, migrations)
+		if err := initSchema(m.DB(), fs, identifier, migrations); err != nil {
+			return nil, err
+		}
+		return append(plan, initSchemaPlan(identifier, migrations)...), nil
 	}
 
-	// apply missing migrations
-	for _, migration := range migrations {
-		if err := m.ApplyMigration(func(tx Tx) (bool, error) {
-			// check if migration was already applied
-			var applied bool
-			if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM migrations WHERE id = ?)", migration.ID).Scan(&applied); err != nil {
-				return false, fmt.Errorf("failed to check if migration '%s' was already applied: %w", migration.ID, err)
-			} else if applied {
-				return false, nil
-			}
-			// run migration
-			if err := migration.Migrate(tx); err != nil {
-				return false, fmt.Errorf("migration '%s' failed: %w", migration.ID, err)
+	// back up the database before applying destructive migrations
+	pending, err := pendingMigrations(m.DB(), migrations)
+	if err != nil {
+		return nil, err
+	} else if id, ok := requiresBackup(pending, opts); ok {
+		log.Infof("backing up %s database before applying destructive migration '%s'", identifier, id)
+		if err := opts.Backup(ctx); err != nil {
+			return nil, fmt.Errorf("failed to back up database before applying migration '%s': %w", id, err)
+		}
+		plan = append(plan, backupStep)
+	}
+
+	// apply missing migrations, if the database supports transactional DDL
+	// all migrations are applied in a single transaction so a failure rolls
+	// the database back to its state before migrating
+	if m.SupportsTransactionalDDL() {
+		var steps []string
+		if err := m.ApplyMigration(func(tx Tx) (migrated bool, err error) {
+			steps = steps[:0]
+			for _, migration := range pending {
+				if performed, err := applyMigrationTx(tx, migration); err != nil {
+					return false, err
+				} else if performed {
+					steps = append(steps, applyMigrationStep(migration.ID))
+					migrated = true
+				}
 			}
-			// insert migration
-			if _, err := tx.Exec("INSERT INTO migrations (id) VALUES (?)", migration.ID); err != nil {
-				return false, fmt.Errorf("failed to insert migration '%s': %w", migration.ID, err)
+			return
+		}); err != nil {
+			return nil, fmt.Errorf("%s migrations were rolled back: %w", identifier, err)
+		}
+		return append(plan, steps...), nil
+	}
+
+	// otherwise every migration is applied in its own transaction, we log a
+	// checkpoint after every migration so it's clear where a failure occurred
+	lastApplied := "none"
+	for i, migration := range pending {
+		var performed bool
+		if err := m.ApplyMigration(func(tx Tx) (_ bool, err error) {
+			performed, err = applyMigrationTx(tx, migration)
+			return performed, err
+		}); err != nil {
+			log.Errorf("%s migration '%s' failed, the last migration that was applied is '%s'", identifier, migration.ID, lastApplied)
+			return nil, err
+		} else if performed {
+			log.Infof("checkpoint: applied %s migration '%s' (%d/%d)", identifier, migration.ID, i+1, len(pending))
+			plan = append(plan, applyMigrationStep(migration.ID))
+			lastApplied = migration.ID
+		}
+	}
+	return plan, nil
+}
+
+// applyMigrationTx applies the given migration and records it as applied, it
+// returns false if the migration was already applied.
+func applyMigrationTx(tx Tx, migration Migration) (bool, error) {
+	// check if migration was already applied
+	var applied bool
+	if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM migrations WHERE id = ?)", migration.ID).Scan(&applied); err != nil {
+		return false, fmt.Errorf("failed to check if migration '%s' was already applied: %w", migration.ID, err)
+	} else if applied {
+		return false, nil
+	}
+	// run migration
+	if err := migration.Migrate(tx); err != nil {
+		return false, fmt.Errorf("migration '%s' failed: %w", migration.ID, err)
+	}
+	// insert migration
+	if err := insertMigration(tx, migration.ID); err != nil {
+		return false, fmt.Errorf("failed to insert migration '%s': %w", migration.ID, err)
+	}
+	return true, nil
+}
+
+// planMigrations returns the steps PerformMigrations would perform without
+// modifying the database.
+func planMigrations(m Migrator, identifier string, migrations []Migration, log *zap.SugaredLogger, opts MigrationOptions) (plan []string, err error) {
+	defer func() {
+		if err == nil {
+			for i, step := range plan {
+				log.Infof("dry run: %s migration step %d/%d: %s", identifier, i+1, len(plan), step)
 			}
+		}
+	}()
+
+	// check whether the migrations table exists and is empty
+	exists, err := m.HasMigrationTable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for migrations table: %w", err)
+	} else if !exists {
+		return append([]string{createMigrationTableStep}, initSchemaPlan(identifier, migrations)...), nil
+	}
+	var isEmpty bool
+	if err := m.DB().QueryRow("SELECT COUNT(*) = 0 FROM migrations").Scan(&isEmpty); err != nil {
+		return nil, fmt.Errorf("failed to count rows in migrations table: %w", err)
+	} else if isEmpty {
+		return initSchemaPlan(identifier, migrations), nil
+	}
+
+	// plan missing migrations
+	pending, err := pendingMigrations(m.DB(), migrations)
+	if err != nil {
+		return nil, err
+	} else if _, ok := requiresBackup(pending, opts); ok {
+		plan = append(plan, backupStep)
+	}
+	for _, migration := range pending {
+		plan = append(plan, applyMigrationStep(migration.ID))
+	}
+	return plan, nil
+}
+
+// pendingMigrations returns the migrations that weren't applied yet.
+func pendingMigrations(db *DB, migrations []Migration) (pending []Migration, err error) {
+	for _, migration := range migrations {
+		var applied bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM migrations WHERE id = ?)", migration.ID).Scan(&applied); err != nil {
+			return nil, fmt.Errorf("failed to check if migration '%s' was already applied: %w", migration.ID, err)
+		} else if !applied {
+			pending = append(pending, migration)
+		}
+	}
+	return
+}
+
+// requiresBackup returns the id of the first destructive migration if a backup
+// hook is configured.
+func requiresBackup(pending []Migration, opts MigrationOptions) (string, bool) {
+	if opts.Backup == nil {
+		return "", false
+	}
+	for _, migration := range pending {
+		if migration.Destructive {
+			return migration.ID, true
+		}
+	}
+	return "", false
+}
+
+func applyMigrationStep(id string) string {
+	return fmt.Sprintf("apply migration '%s'", id)
+}
+
+func initSchemaPlan(identifier string, migrations []Migration) []string {
+	plan := []string{fmt.Sprintf("initialize %s schema", identifier)}
+	for _, migration := range migrations {
+		plan = append(plan, fmt.Sprintf("mark migration '%s' as applied", migration.ID))
+	}
+	return plan
+}
+
+// insertMigration records the migration with given id as applied. The time it
+// was applied is only recorded if the migrations table has the applied_at
+// column, databases created before migration 00009 only get it once that
+// migration was applied.
+func insertMigration(tx Tx, id string) error {
+	hasAppliedAt, err := hasMigrationsAppliedAt(tx)
+	if err != nil {
+		return err
+	} else if !hasAppliedAt {
+		_, err = tx.Exec("INSERT INTO migrations (id) VALUES (?)", id)
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO migrations (id, applied_at) VALUES (?, ?)", id, time.Now().UTC())
+	return err
+}
+
+// hasMigrationsAppliedAt returns whether the migrations table has the
+// applied_at column.
+func hasMigrationsAppliedAt(tx Tx) (bool, error) {
+	rows, err := tx.Query("SELECT * FROM migrations LIMIT 0")
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch migrations table columns: %w", err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch migrations table columns: %w", err)
+	}
+	for _, col := range cols {
+		if strings.EqualFold(col, "applied_at") {
 			return true, nil
-		}); err != nil {
-			return fmt.Errorf("migration '%s' failed: %w", migration.ID, err)
 		}
 	}
-	return nil
+	return false, nil
 }
 
 func execSQLFile(tx Tx, fs embed.FS, folder, filename string) error {
@@ -243,7 +681,7 @@ func initSchema(db *DB, fs embed.FS, identifier string, migrations []Migration)
 		}
 		// insert migration ids
 		for _, migration := range migrations {
-			if _, err := tx.Exec("INSERT INTO migrations (id) VALUES (?)", migration.ID); err != nil {
+			if err := insertMigration(tx, migration.ID); err != nil {
 				return fmt.Errorf("failed to insert migration '%s': %w", migration.ID, err)
 			}
 		}
@@ -259,3 +697,22 @@ func performMigration(tx Tx, fs embed.FS, kind, migration string, logger *zap.Su
 	logger.Infof("migration '%s' complete", migration)
 	return nil
 }
+
+// transactionTypeV00024 infers the type of a transaction for migration
+// 00024_transaction_types. It's a frozen copy of the classification at the
+// time of the migration, changes to wallet.TransactionType must not alter the
+// outcome of the migration.
+func transactionTypeV00024(txn types.Transaction, inflow, outflow types.Currency) string {
+	switch {
+	case len(txn.FileContracts) > 0 && len(txn.FileContractRevisions) > 0:
+		return "renewal"
+	case len(txn.FileContracts) > 0:
+		return "contract"
+	case outflow.Cmp(inflow) > 0:
+		return "send"
+	case inflow.Cmp(outflow) > 0:
+		return "receive"
+	default:
+		return "other"
+	}
+}
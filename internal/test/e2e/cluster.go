@@ -418,7 +418,7 @@ func newTestCluster(t *testing.T, opts testClusterOptions) *TestCluster {
 
 	// Set the test contract set to make sure we can add objects at the
 	// beginning of a test right away.
-	tt.OK(busClient.SetContractSet(ctx, test.ContractSet, []types.FileContractID{}))
+	tt.OKAll(busClient.SetContractSet(ctx, test.ContractSet, []types.FileContractID{}))
 
 	// Update the autopilot to use test settings
 	if !opts.skipSettingAutopilot {
@@ -513,7 +513,9 @@ func announceHosts(hosts []*Host) error {
 }
 
 // MineToRenewWindow is a helper which mines enough blocks for the autopilot to
-// reach its renew window.
+// reach its renew window. Since renewals are spread across the first half of
+// the renew window, it mines to the end of that first half so all contracts are
+// due for renewal.
 func (c *TestCluster) MineToRenewWindow() {
 	c.tt.Helper()
 	cs, err := c.Bus.ConsensusState(context.Background())
@@ -526,7 +528,11 @@ func (c *TestCluster) MineToRenewWindow() {
 	if cs.BlockHeight >= renewWindowStart {
 		c.tt.Fatalf("already in renew window: bh: %v, currentPeriod: %v, periodLength: %v, renewWindow: %v", cs.BlockHeight, ap.CurrentPeriod, ap.Config.Contracts.Period, renewWindowStart)
 	}
-	c.MineBlocks(int(renewWindowStart - cs.BlockHeight))
+	renewAllHeight := renewWindowStart
+	if ap.Config.Contracts.RenewWindow >= 2 {
+		renewAllHeight += ap.Config.Contracts.RenewWindow/2 - 1
+	}
+	c.MineBlocks(int(renewAllHeight - cs.BlockHeight))
 	c.Sync()
 }
 
@@ -932,6 +938,8 @@ func testApCfg() node.AutopilotConfig {
 		ID: api.DefaultAutopilotID,
 		Autopilot: config.Autopilot{
 			AccountsRefillInterval:         time.Second,
+			AccountsMinBalance:             types.Siacoins(1).Div64(2),
+			AccountsMaxBalance:             types.Siacoins(1),
 			Heartbeat:                      time.Second,
 			MigrationHealthCutoff:          0.99,
 			MigratorParallelSlabsPerWorker: 1,
@@ -10,6 +10,8 @@ import (
 	"io"
 	"math"
 	"math/big"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
@@ -23,6 +25,7 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/alerts"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/config"
 	"go.sia.tech/renterd/internal/test"
 	"go.sia.tech/renterd/object"
 	"go.sia.tech/renterd/wallet"
@@ -760,7 +763,7 @@ func TestUploadDownloadExtended(t *testing.T) {
 	cfg, _ := cluster.AutopilotConfig(context.Background())
 	cfg.Contracts.Set = t.Name()
 	cluster.UpdateAutopilotConfig(context.Background(), cfg)
-	tt.OK(b.SetContractSet(context.Background(), t.Name(), nil))
+	tt.OKAll(b.SetContractSet(context.Background(), t.Name(), nil))
 
 	// assert there are no contracts in the set
 	csc, err := b.Contracts(context.Background(), api.ContractsOpts{ContractSet: t.Name()})
@@ -2528,23 +2531,27 @@ func TestHostScan(t *testing.T) {
 	hk := host.PublicKey()
 	hostIP := settings.NetAddress
 
+	// NOTE: scans are recorded asynchronously by the worker so we retry
 	assertHost := func(ls time.Time, lss, slss bool, ts uint64) {
 		t.Helper()
 
-		hi, err := b.Host(context.Background(), host.PublicKey())
-		tt.OK(err)
+		tt.Retry(100, 50*time.Millisecond, func() error {
+			hi, err := b.Host(context.Background(), host.PublicKey())
+			tt.OK(err)
 
-		if ls.IsZero() && !hi.Interactions.LastScan.IsZero() {
-			t.Fatal("expected last scan to be zero")
-		} else if !ls.IsZero() && !hi.Interactions.LastScan.After(ls) {
-			t.Fatal("expected last scan to be after", ls)
-		} else if hi.Interactions.LastScanSuccess != lss {
-			t.Fatalf("expected last scan success to be %v, got %v", lss, hi.Interactions.LastScanSuccess)
-		} else if hi.Interactions.SecondToLastScanSuccess != slss {
-			t.Fatalf("expected second to last scan success to be %v, got %v", slss, hi.Interactions.SecondToLastScanSuccess)
-		} else if hi.Interactions.TotalScans != ts {
-			t.Fatalf("expected total scans to be %v, got %v", ts, hi.Interactions.TotalScans)
-		}
+			if ls.IsZero() && !hi.Interactions.LastScan.IsZero() {
+				return errors.New("expected last scan to be zero")
+			} else if !ls.IsZero() && !hi.Interactions.LastScan.After(ls) {
+				return fmt.Errorf("expected last scan to be after %v", ls)
+			} else if hi.Interactions.LastScanSuccess != lss {
+				return fmt.Errorf("expected last scan success to be %v, got %v", lss, hi.Interactions.LastScanSuccess)
+			} else if hi.Interactions.SecondToLastScanSuccess != slss {
+				return fmt.Errorf("expected second to last scan success to be %v, got %v", slss, hi.Interactions.SecondToLastScanSuccess)
+			} else if hi.Interactions.TotalScans != ts {
+				return fmt.Errorf("expected total scans to be %v, got %v", ts, hi.Interactions.TotalScans)
+			}
+			return nil
+		})
 	}
 
 	scanHost := func() error {
@@ -2609,3 +2616,80 @@ func TestHostScan(t *testing.T) {
 		t.Fatalf("expected 1 hosts, got %v", len(toScan))
 	}
 }
+
+// TestConsensusCheckpoint verifies the bus subscribes to consensus from the
+// configured checkpoint if its database doesn't contain any consensus state.
+func TestConsensusCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	// create a cluster with a host
+	dir := t.TempDir()
+	cluster := newTestCluster(t, testClusterOptions{
+		dir:    dir,
+		hosts:  1,
+		logger: zap.NewNop(),
+	})
+	tt := cluster.tt
+
+	// assert the host's announcement was processed
+	hosts, err := cluster.Bus.Hosts(context.Background(), api.GetHostsOptions{})
+	tt.OK(err)
+	if len(hosts) != 1 {
+		t.Fatalf("expected 1 host, got %v", len(hosts))
+	}
+
+	// use the current tip, which comes after the announcement, as checkpoint
+	cluster.Sync()
+	cs := cluster.hosts[0].cs
+	checkpoint := types.ChainIndex{
+		Height: uint64(cs.Height()),
+		ID:     types.BlockID(cs.CurrentBlock().ID()),
+	}
+	cluster.Shutdown()
+
+	// remove the bus database
+	var dbName string
+	if config.MySQLConfigFromEnv().URI != "" {
+		dbName = "db" + hex.EncodeToString(frand.Bytes(16))
+	} else {
+		tt.OK(os.RemoveAll(filepath.Join(dir, "bus", "db")))
+	}
+
+	// restart the cluster with the checkpoint
+	busCfg := testBusCfg()
+	busCfg.ConsensusCheckpoint = checkpoint
+	cluster = newTestCluster(t, testClusterOptions{
+		busCfg: &busCfg,
+		dbName: dbName,
+		dir:    dir,
+		logger: zap.NewNop(),
+	})
+	defer cluster.Shutdown()
+
+	// assert blocks after the checkpoint were processed, the cluster is funded
+	// by mining blocks after the restart
+	cluster.MineBlocks(1)
+	tt.Retry(100, 100*time.Millisecond, func() error {
+		cs, err := cluster.Bus.ConsensusState(context.Background())
+		if err != nil {
+			return err
+		} else if cs.BlockHeight <= checkpoint.Height {
+			return fmt.Errorf("expected height > %v, got %v", checkpoint.Height, cs.BlockHeight)
+		}
+		return nil
+	})
+	wallet, err := cluster.Bus.Wallet(context.Background())
+	tt.OK(err)
+	if wallet.Confirmed.IsZero() {
+		t.Fatal("expected wallet to be funded")
+	}
+
+	// assert the announcement before the checkpoint wasn't processed
+	hosts, err = cluster.Bus.Hosts(context.Background(), api.GetHostsOptions{})
+	tt.OK(err)
+	if len(hosts) != 0 {
+		t.Fatalf("expected no hosts, got %v", len(hosts))
+	}
+}
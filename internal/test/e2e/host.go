@@ -48,6 +48,13 @@ type stubDataMonitor struct{}
 func (stubDataMonitor) ReadBytes(n int)  {}
 func (stubDataMonitor) WriteBytes(n int) {}
 
+// hostTransactionPool is the transaction pool used by the test hosts, unlike
+// the bus, hostd also needs the pool's recommended fee.
+type hostTransactionPool interface {
+	bus.TransactionPool
+	RecommendedFee() types.Currency
+}
+
 // A Host is an ephemeral host that can be used for testing.
 type Host struct {
 	dir     string
@@ -55,7 +62,7 @@ type Host struct {
 
 	g  modules.Gateway
 	cs modules.ConsensusSet
-	tp bus.TransactionPool
+	tp hostTransactionPool
 
 	store     *sqlite.Store
 	wallet    *wallet.SingleAddressWallet
@@ -176,7 +183,7 @@ func NewHost(privKey types.PrivateKey, dir string, network *consensus.Network, d
 	if err != nil {
 		return nil, fmt.Errorf("failed to create transaction pool: %w", err)
 	}
-	tp := node.NewTransactionPool(tpool)
+	tp := node.NewTransactionPool(tpool).(hostTransactionPool)
 	cm, err := node.NewChainManager(cs, tp, network)
 	if err != nil {
 		return nil, err
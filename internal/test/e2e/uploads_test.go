@@ -100,6 +100,17 @@ func TestUploadingSectorsCache(t *testing.T) {
 		return nil
 	})
 
+	// assert the pending endpoint reports the same sectors
+	for _, c := range contracts {
+		resp, err := b.PendingSectors(context.Background(), c.ID)
+		tt.OK(err)
+		if len(resp.Roots) != len(pending[c.ID]) {
+			t.Fatalf("expected %v pending sectors, got %v", len(pending[c.ID]), len(resp.Roots))
+		} else if resp.Size != uint64(len(resp.Roots))*rhpv2.SectorSize {
+			t.Fatalf("unexpected pending size %v", resp.Size)
+		}
+	}
+
 	// unblock the upload
 	close(br.blockChan)
 
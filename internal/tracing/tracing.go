@@ -0,0 +1,51 @@
+// Package tracing contains the OpenTelemetry instrumentation shared by the
+// bus and the worker. Spans are created using the global tracer provider,
+// which is a no-op unless the embedding application registers one using
+// otel.SetTracerProvider.
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "go.sia.tech/renterd"
+
+// Attribute keys used in renterd spans.
+const (
+	AttributeContractID = attribute.Key("renterd.contract_id")
+	AttributeHostKey    = attribute.Key("renterd.host_key")
+	AttributeSlabKey    = attribute.Key("renterd.slab_key")
+	AttributeUploadID   = attribute.Key("renterd.upload_id")
+)
+
+// StartSpan starts a new span with the given name and attributes using the
+// global tracer provider. The span is a child of the span in ctx, if any.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// Middleware extracts the W3C trace context propagated by the caller from the
+// incoming request, spans created using the request's context become children
+// of the caller's span.
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := propagation.TraceContext{}.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		h.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// EndSpan ends the given span, marking it as failed if err is not nil.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
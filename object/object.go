@@ -68,9 +68,6 @@ func (k *EncryptionKey) UnmarshalText(b []byte) error {
 // Encrypt returns a cipher.StreamReader that encrypts r with k starting at the
 // given offset.
 func (k EncryptionKey) Encrypt(r io.Reader, offset uint64) (cipher.StreamReader, error) {
-	if offset%64 != 0 {
-		return cipher.StreamReader{}, fmt.Errorf("offset must be a multiple of 64, got %v", offset)
-	}
 	if k.IsNoopKey() {
 		return cipher.StreamReader{S: &noOpStream{}, R: r}, nil
 	}
@@ -81,7 +78,12 @@ func (k EncryptionKey) Encrypt(r io.Reader, offset uint64) (cipher.StreamReader,
 	binary.LittleEndian.PutUint64(nonce[16:], nonce64)
 	c, _ := chacha20.NewUnauthenticatedCipher(k.entropy[:], nonce)
 	c.SetCounter(uint32(offset / 64))
-	rs := &rekeyStream{key: k.entropy[:], c: c}
+
+	// discard the keystream up until the offset within the block, this allows
+	// encrypting from offsets that aren't a multiple of 64
+	var buf [64]byte
+	c.XORKeyStream(buf[:offset%64], buf[:offset%64])
+	rs := &rekeyStream{key: k.entropy[:], c: c, counter: offset, nonce: nonce64}
 	return cipher.StreamReader{S: rs, R: r}, nil
 }
 
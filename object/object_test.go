@@ -40,6 +40,14 @@ func TestEncryptionOffset(t *testing.T) {
 	} else if bytes.Equal(data, decrypt(offset, encrypt(128, data))) {
 		t.Fatal("expected mismatch")
 	}
+
+	// assert we can encrypt from an offset that isn't a multiple of 64
+	offset = 100
+	if !bytes.Equal(data, decrypt(offset, encrypt(offset, data))) {
+		t.Fatal("mismatch")
+	} else if !bytes.Equal(encrypt(0, data)[offset:], encrypt(offset, data[offset:])) {
+		t.Fatal("mismatch")
+	}
 }
 
 func TestEncryptionOverflow(t *testing.T) {
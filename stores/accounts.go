@@ -31,6 +31,10 @@ type (
 		// an account and the balance reported by a host.
 		Drift *balance
 
+		// Drifted indicates whether the balance reported by the host diverged
+		// from the tracked balance by more than the allowed threshold.
+		Drifted bool `gorm:"default:false"`
+
 		// RequiresSync indicates whether an account needs to be synced with the
 		// host before it can be used again.
 		RequiresSync bool `gorm:"index"`
@@ -48,6 +52,7 @@ func (a dbAccount) convert() api.Account {
 		HostKey:       types.PublicKey(a.Host),
 		Balance:       (*big.Int)(a.Balance),
 		Drift:         (*big.Int)(a.Drift),
+		Drifted:       a.Drifted,
 		RequiresSync:  a.RequiresSync,
 	}
 }
@@ -94,6 +99,7 @@ func (s *SQLStore) SaveAccounts(ctx context.Context, accounts []api.Account) err
 			Host:         publicKey(acc.HostKey),
 			Balance:      (*balance)(acc.Balance),
 			Drift:        (*balance)(acc.Drift),
+			Drifted:      acc.Drifted,
 			RequiresSync: acc.RequiresSync,
 		}
 	}
@@ -0,0 +1,134 @@
+package stores
+
+import (
+	"sync"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+type (
+	// hostCache is a bounded read-through cache for hosts fetched by their
+	// public key. Entries are invalidated whenever the host is updated and
+	// expire after a TTL, which bounds the staleness of fields that are derived
+	// from other tables, e.g. the amount of data stored on the host.
+	hostCache struct {
+		size int
+		ttl  time.Duration
+
+		mu      sync.Mutex
+		gen     uint64
+		entries map[types.PublicKey]hostCacheEntry
+	}
+
+	hostCacheEntry struct {
+		host   api.Host
+		expiry time.Time
+	}
+)
+
+// newHostCache returns a new host cache, it returns nil if either the size or
+// the TTL is zero, which disables caching.
+func newHostCache(size int, ttl time.Duration) *hostCache {
+	if size <= 0 || ttl <= 0 {
+		return nil
+	}
+	return &hostCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[types.PublicKey]hostCacheEntry),
+	}
+}
+
+// Generation returns the current generation of the cache. It has to be fetched
+// before reading the host from the database and passed to Set, this ensures a
+// host that was updated in the meantime isn't cached.
+func (c *hostCache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// Get returns the cached host with the given key.
+func (c *hostCache) Get(hk types.PublicKey) (api.Host, bool) {
+	if c == nil {
+		return api.Host{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[hk]
+	if !ok {
+		return api.Host{}, false
+	} else if time.Now().After(entry.expiry) {
+		delete(c.entries, hk)
+		return api.Host{}, false
+	}
+	return entry.host, true
+}
+
+// Set caches the given host unless the cache was invalidated since the given
+// generation. If the cache is full, expired entries are removed first and the
+// entry closest to expiring is evicted if that didn't free up any space.
+func (c *hostCache) Set(gen uint64, h api.Host) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return // host might be outdated
+	}
+
+	now := time.Now()
+	if _, exists := c.entries[h.PublicKey]; !exists && len(c.entries) >= c.size {
+		var oldest types.PublicKey
+		var oldestExpiry time.Time
+		for hk, entry := range c.entries {
+			if now.After(entry.expiry) {
+				delete(c.entries, hk)
+			} else if oldestExpiry.IsZero() || entry.expiry.Before(oldestExpiry) {
+				oldest, oldestExpiry = hk, entry.expiry
+			}
+		}
+		if len(c.entries) >= c.size {
+			delete(c.entries, oldest)
+		}
+	}
+	c.entries[h.PublicKey] = hostCacheEntry{
+		host:   h,
+		expiry: now.Add(c.ttl),
+	}
+}
+
+// Invalidate removes the hosts with the given keys from the cache.
+func (c *hostCache) Invalidate(hks ...types.PublicKey) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, hk := range hks {
+		delete(c.entries, hk)
+	}
+}
+
+// Clear removes all hosts from the cache, it's used for updates that affect
+// all hosts, e.g. changes to the allowlist or blocklist.
+func (c *hostCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.entries = make(map[types.PublicKey]hostCacheEntry)
+}
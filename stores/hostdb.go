@@ -5,8 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	rhpv2 "go.sia.tech/core/rhp/v2"
@@ -29,15 +31,37 @@ const (
 	// table.
 	consensusInfoID = 1
 
+	// hostInsertionBatchSize is the number of hosts and announcements per
+	// batch when we upsert them. Hosts have a lot of columns so the batch size
+	// is chosen to stay well below the maximum number of SQL variables.
+	hostInsertionBatchSize = 500
+
 	// hostRetrievalBatchSize is the number of hosts we fetch from the
 	// database per batch. Empirically tested to verify that this is a value
 	// that performs reasonably well.
 	hostRetrievalBatchSize = 10000
+
+	// hostOperationsDecay is the factor by which a host's rolling operation
+	// counts decay with every newly recorded operation.
+	hostOperationsDecay = 0.99
+
+	// hostResolveThreads is the number of hostnames we resolve in parallel
+	// when matching hosts against a subnet blocklist entry.
+	hostResolveThreads = 20
+
+	// hostResolveTimeout is the amount of time we wait for a hostname to
+	// resolve when matching it against a subnet blocklist entry.
+	hostResolveTimeout = 5 * time.Second
 )
 
+// lookupIPAddr resolves hostnames, it's a variable so it can be overridden in
+// testing.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
 var (
 	ErrNegativeOffset      = errors.New("offset can not be negative")
 	ErrNegativeMaxDowntime = errors.New("max downtime can not be negative")
+	ErrNegativeGracePeriod = errors.New("grace period can not be negative")
 )
 
 type (
@@ -68,9 +92,20 @@ type (
 		RecentDowntime     time.Duration `gorm:"index"`
 		RecentScanFailures uint64        `gorm:"index"`
 
+		// PendingRemovalSince is set when the host first became eligible for
+		// pruning, it's only removed if it's still eligible once the grace
+		// period has passed. Zero if the host isn't pending removal.
+		PendingRemovalSince int64 // unix nano
+
 		SuccessfulInteractions float64
 		FailedInteractions     float64
 
+		SuccessfulOperations float64
+		FailedOperations     float64
+
+		UploadedBytes   uint64
+		DownloadedBytes uint64
+
 		LostSectors uint64
 
 		LastAnnouncement time.Time
@@ -110,6 +145,7 @@ type (
 		ScoreUptime           float64
 		ScoreVersion          float64
 		ScorePrices           float64
+		ScoreSuccessRate      float64
 
 		// gouging
 		GougingContractErr string
@@ -117,6 +153,7 @@ type (
 		GougingGougingErr  string
 		GougingPruneErr    string
 		GougingUploadErr   string
+		GougingReasons     gougingReasons
 	}
 
 	// dbAllowlistEntry defines a table that stores the host blocklist.
@@ -133,6 +170,24 @@ type (
 		Hosts []dbHost `gorm:"many2many:host_blocklist_entry_hosts;constraint:OnDelete:CASCADE"`
 	}
 
+	// dbHostOverride defines a table that stores the manual overrides for
+	// hosts. It's keyed by public key rather than referencing the hosts table
+	// so overrides can be configured for hosts we haven't seen yet.
+	dbHostOverride struct {
+		Model
+		PublicKey       publicKey `gorm:"unique;NOT NULL;size:32"`
+		ForceInclude    bool      `gorm:"NOT NULL"`
+		ForceExclude    bool      `gorm:"NOT NULL"`
+		ScoreMultiplier float64   `gorm:"NOT NULL"`
+	}
+
+	// dbHostBlocklistEntryHost defines the join table between hosts and
+	// blocklist entries.
+	dbHostBlocklistEntryHost struct {
+		DBBlocklistEntryID uint `gorm:"primaryKey"`
+		DBHostID           uint `gorm:"primaryKey"`
+	}
+
 	dbConsensusInfo struct {
 		Model
 		CCID    []byte
@@ -254,6 +309,12 @@ func (dbAllowlistEntry) TableName() string { return "host_allowlist_entries" }
 // TableName implements the gorm.Tabler interface.
 func (dbBlocklistEntry) TableName() string { return "host_blocklist_entries" }
 
+// TableName implements the gorm.Tabler interface.
+func (dbHostBlocklistEntryHost) TableName() string { return "host_blocklist_entry_hosts" }
+
+// TableName implements the gorm.Tabler interface.
+func (dbHostOverride) TableName() string { return "host_overrides" }
+
 // convert converts a host into a api.HostInfo
 func (h dbHost) convert(blocked bool, storedData uint64) api.Host {
 	var lastScan time.Time
@@ -277,6 +338,8 @@ func (h dbHost) convert(blocked bool, storedData uint64) api.Host {
 			Downtime:                h.Downtime,
 			SuccessfulInteractions:  h.SuccessfulInteractions,
 			FailedInteractions:      h.FailedInteractions,
+			SuccessfulOperations:    h.SuccessfulOperations,
+			FailedOperations:        h.FailedOperations,
 			LostSectors:             h.LostSectors,
 		},
 		PriceTable: api.HostPriceTable{
@@ -292,6 +355,15 @@ func (h dbHost) convert(blocked bool, storedData uint64) api.Host {
 	}
 }
 
+func (o dbHostOverride) convert() api.HostOverride {
+	return api.HostOverride{
+		HostKey:         types.PublicKey(o.PublicKey),
+		ForceInclude:    o.ForceInclude,
+		ForceExclude:    o.ForceExclude,
+		ScoreMultiplier: o.ScoreMultiplier,
+	}
+}
+
 func (hi dbHostCheck) convert() api.HostCheck {
 	return api.HostCheck{
 		Gouging: api.HostGougingBreakdown{
@@ -300,6 +372,7 @@ func (hi dbHostCheck) convert() api.HostCheck {
 			GougingErr:  hi.GougingGougingErr,
 			PruneErr:    hi.GougingPruneErr,
 			UploadErr:   hi.GougingUploadErr,
+			Reasons:     []api.GougingReason(hi.GougingReasons),
 		},
 		Score: api.HostScoreBreakdown{
 			Age:              hi.ScoreAge,
@@ -309,6 +382,7 @@ func (hi dbHostCheck) convert() api.HostCheck {
 			Uptime:           hi.ScoreUptime,
 			Version:          hi.ScoreVersion,
 			Prices:           hi.ScorePrices,
+			SuccessRate:      hi.ScoreSuccessRate,
 		},
 		Usability: api.HostUsabilityBreakdown{
 			Blocked:               hi.UsabilityBlocked,
@@ -374,6 +448,11 @@ func (e *dbBlocklistEntry) AfterCreate(tx *gorm.DB) error {
 		return nil
 	}
 
+	// subnet entries can't be matched in SQL, so we match them in memory
+	if _, subnet, err := net.ParseCIDR(e.Entry); err == nil {
+		return e.associateSubnet(tx, subnet)
+	}
+
 	params := map[string]interface{}{
 		"entry_id":    e.ID,
 		"exact_entry": e.Entry,
@@ -412,7 +491,58 @@ func (e *dbBlocklistEntry) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+// associateSubnet associates the entry with all hosts whose net address
+// resolves to an IP address within the given subnet.
+func (e *dbBlocklistEntry) associateSubnet(tx *gorm.DB, subnet *net.IPNet) error {
+	var batch, hosts []dbHost
+	if err := tx.
+		Model(&dbHost{}).
+		Select("id, net_address").
+		FindInBatches(&batch, hostRetrievalBatchSize, func(_ *gorm.DB, _ int) error {
+			hosts = append(hosts, batch...)
+			return nil
+		}).Error; err != nil {
+		return fmt.Errorf("failed to fetch hosts: %w", err)
+	}
+
+	// resolve the hosts in parallel, hostnames might take a while to resolve
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var blocked []dbHostBlocklistEntryHost
+	sem := make(chan struct{}, hostResolveThreads)
+	for _, h := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(h dbHost) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if hostInSubnet(h.NetAddress, subnet) {
+				mu.Lock()
+				blocked = append(blocked, dbHostBlocklistEntryHost{
+					DBBlocklistEntryID: e.ID,
+					DBHostID:           h.ID,
+				})
+				mu.Unlock()
+			}
+		}(h)
+	}
+	wg.Wait()
+	if len(blocked) == 0 {
+		return nil
+	}
+	return tx.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(&blocked, 100).
+		Error
+}
+
 func (e *dbBlocklistEntry) blocks(h dbHost) bool {
+	if _, subnet, err := net.ParseCIDR(e.Entry); err == nil {
+		return hostInSubnet(h.NetAddress, subnet)
+	}
+
 	values := []string{h.NetAddress}
 	host, _, err := net.SplitHostPort(h.NetAddress)
 	if err == nil {
@@ -427,19 +557,64 @@ func (e *dbBlocklistEntry) blocks(h dbHost) bool {
 	return false
 }
 
+// hostInSubnet returns true if the given net address lies within the given
+// subnet. Hostnames are resolved and the host is considered to be within the
+// subnet if any of its addresses is. Since the association is made when the
+// entry is added or the host announces, a host that changes its DNS records
+// is only reevaluated on its next announcement.
+func hostInSubnet(netAddress string, subnet *net.IPNet) bool {
+	for _, ip := range resolveHostIPs(netAddress) {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveHostIPs returns the IP addresses the given net address resolves to,
+// hostnames that fail to resolve yield no addresses.
+func resolveHostIPs(netAddress string) []net.IP {
+	host, _, err := net.SplitHostPort(netAddress)
+	if err != nil {
+		host = netAddress
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hostResolveTimeout)
+	defer cancel()
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	return ips
+}
+
 // Host returns information about a host.
 func (ss *SQLStore) Host(ctx context.Context, hostKey types.PublicKey) (api.Host, error) {
+	// check the cache first
+	gen := ss.hostCache.Generation()
+	if h, ok := ss.hostCache.Get(hostKey); ok {
+		return h, nil
+	}
+
 	hosts, err := ss.SearchHosts(ctx, "", api.HostFilterModeAll, api.UsabilityFilterModeAll, "", []types.PublicKey{hostKey}, 0, 1)
 	if err != nil {
 		return api.Host{}, err
 	} else if len(hosts) == 0 {
 		return api.Host{}, api.ErrHostNotFound
-	} else {
-		return hosts[0], nil
 	}
+	ss.hostCache.Set(gen, hosts[0])
+	return hosts[0], nil
 }
 
 func (ss *SQLStore) UpdateHostCheck(ctx context.Context, autopilotID string, hk types.PublicKey, hc api.HostCheck) (err error) {
+	defer ss.hostCache.Invalidate(hk)
 	err = ss.retryTransaction(ctx, (func(tx *gorm.DB) error {
 		// fetch ap id
 		var apID uint
@@ -493,12 +668,14 @@ func (ss *SQLStore) UpdateHostCheck(ctx context.Context, autopilotID string, hk
 				ScoreUptime:           hc.Score.Uptime,
 				ScoreVersion:          hc.Score.Version,
 				ScorePrices:           hc.Score.Prices,
+				ScoreSuccessRate:      hc.Score.SuccessRate,
 
 				GougingContractErr: hc.Gouging.ContractErr,
 				GougingDownloadErr: hc.Gouging.DownloadErr,
 				GougingGougingErr:  hc.Gouging.GougingErr,
 				GougingPruneErr:    hc.Gouging.PruneErr,
 				GougingUploadErr:   hc.Gouging.UploadErr,
+				GougingReasons:     gougingReasons(hc.Gouging.Reasons),
 			}).
 			Error
 	}))
@@ -506,11 +683,29 @@ func (ss *SQLStore) UpdateHostCheck(ctx context.Context, autopilotID string, hk
 }
 
 // HostsForScanning returns the address of hosts for scanning.
-func (ss *SQLStore) HostsForScanning(ctx context.Context, maxLastScan time.Time, offset, limit int) ([]api.HostAddress, error) {
+func (ss *SQLStore) HostsForScanning(ctx context.Context, maxLastScan time.Time, sortBy, sortDir string, offset, limit int) ([]api.HostAddress, error) {
 	if offset < 0 {
 		return nil, ErrNegativeOffset
 	}
 
+	// build order clause, hosts are ordered by id by default to ensure the
+	// pagination is stable
+	orderBy := "id ASC"
+	switch sortBy {
+	case "":
+	case api.HostSortByLastScan:
+		switch strings.ToLower(sortDir) {
+		case "", api.HostSortDirAsc:
+			orderBy = "last_scan ASC, id ASC"
+		case api.HostSortDirDesc:
+			orderBy = "last_scan DESC, id ASC"
+		default:
+			return nil, fmt.Errorf("invalid dir '%v', allowed values are '%v' and '%v'; %w", sortDir, api.HostSortDirAsc, api.HostSortDirDesc, api.ErrInvalidHostSortParameters)
+		}
+	default:
+		return nil, fmt.Errorf("invalid sort by '%v', allowed value is '%v'; %w", sortBy, api.HostSortByLastScan, api.ErrInvalidHostSortParameters)
+	}
+
 	var hosts []struct {
 		PublicKey  publicKey `gorm:"unique;index;NOT NULL"`
 		NetAddress string
@@ -521,23 +716,22 @@ func (ss *SQLStore) HostsForScanning(ctx context.Context, maxLastScan time.Time,
 		WithContext(ctx).
 		Model(&dbHost{}).
 		Where("last_scan < ?", maxLastScan.UnixNano()).
+		Scopes(hostFilter(api.HostFilterModeAllowed, ss.hasAllowlist(), ss.hasBlocklist())).
 		Offset(offset).
 		Limit(limit).
-		Order("last_scan ASC").
-		FindInBatches(&hosts, hostRetrievalBatchSize, func(tx *gorm.DB, batch int) error {
-			for _, h := range hosts {
-				hostAddresses = append(hostAddresses, api.HostAddress{
-					PublicKey:  types.PublicKey(h.PublicKey),
-					NetAddress: h.NetAddress,
-				})
-			}
-			return nil
-		}).
+		Order(orderBy).
+		Find(&hosts).
 		Error
 	if err != nil {
 		return nil, err
 	}
-	return hostAddresses, err
+	for _, h := range hosts {
+		hostAddresses = append(hostAddresses, api.HostAddress{
+			PublicKey:  types.PublicKey(h.PublicKey),
+			NetAddress: h.NetAddress,
+		})
+	}
+	return hostAddresses, nil
 }
 
 func (ss *SQLStore) SearchHosts(ctx context.Context, autopilotID, filterMode, usabilityMode, addressContains string, keyIn []types.PublicKey, offset, limit int) ([]api.Host, error) {
@@ -594,6 +788,24 @@ func (ss *SQLStore) SearchHosts(ctx context.Context, autopilotID, filterMode, us
 		Offset(offset).
 		Limit(limit).
 		FindInBatches(&fullHosts, hostRetrievalBatchSize, func(tx *gorm.DB, batch int) error {
+			// fetch the overrides of the hosts in this batch
+			keys := make([]publicKey, len(fullHosts))
+			for i, fh := range fullHosts {
+				keys[i] = fh.PublicKey
+			}
+			var overrides []dbHostOverride
+			if err := ss.db.
+				WithContext(ctx).
+				Where("public_key IN ?", keys).
+				Find(&overrides).
+				Error; err != nil {
+				return fmt.Errorf("failed to fetch host overrides: %w", err)
+			}
+			overridesMap := make(map[publicKey]api.HostOverride, len(overrides))
+			for _, o := range overrides {
+				overridesMap[o.PublicKey] = o.convert()
+			}
+
 			for _, fh := range fullHosts {
 				var blocked bool
 				if filterMode == api.HostFilterModeAll {
@@ -601,7 +813,11 @@ func (ss *SQLStore) SearchHosts(ctx context.Context, autopilotID, filterMode, us
 				} else {
 					blocked = filterMode == api.HostFilterModeBlocked
 				}
-				hosts = append(hosts, fh.convert(blocked, storedDataMap[fh.ID]))
+				h := fh.convert(blocked, storedDataMap[fh.ID])
+				if o, ok := overridesMap[fh.PublicKey]; ok {
+					h.Override = &o
+				}
+				hosts = append(hosts, h)
 			}
 			return nil
 		}).
@@ -612,15 +828,78 @@ func (ss *SQLStore) SearchHosts(ctx context.Context, autopilotID, filterMode, us
 	return hosts, err
 }
 
+// HostsPendingRemoval returns the hosts that are pending removal, ordered by
+// the time they were marked.
+func (ss *SQLStore) HostsPendingRemoval(ctx context.Context) ([]api.HostPendingRemoval, error) {
+	var hosts []dbHost
+	if err := ss.db.
+		WithContext(ctx).
+		Model(&dbHost{}).
+		Select("public_key, net_address, pending_removal_since").
+		Where("pending_removal_since > 0").
+		Order("pending_removal_since ASC").
+		Find(&hosts).
+		Error; err != nil {
+		return nil, err
+	}
+
+	pending := make([]api.HostPendingRemoval, len(hosts))
+	for i, h := range hosts {
+		pending[i] = api.HostPendingRemoval{
+			HostKey:    types.PublicKey(h.PublicKey),
+			NetAddress: h.NetAddress,
+			Since:      time.Unix(0, h.PendingRemovalSince).UTC(),
+		}
+	}
+	return pending, nil
+}
+
+// HostCounts returns the number of allowed and blocked hosts.
+func (ss *SQLStore) HostCounts(ctx context.Context) (allowed, blocked uint64, err error) {
+	count := func(filterMode string) (uint64, error) {
+		var n int64
+		err := ss.db.
+			WithContext(ctx).
+			Model(&dbHost{}).
+			Scopes(hostFilter(filterMode, ss.hasAllowlist(), ss.hasBlocklist())).
+			Count(&n).
+			Error
+		return uint64(n), err
+	}
+	if allowed, err = count(api.HostFilterModeAllowed); err != nil {
+		return 0, 0, fmt.Errorf("failed to count allowed hosts: %w", err)
+	} else if blocked, err = count(api.HostFilterModeBlocked); err != nil {
+		return 0, 0, fmt.Errorf("failed to count blocked hosts: %w", err)
+	}
+	return
+}
+
 // Hosts returns non-blocked hosts at given offset and limit.
 func (ss *SQLStore) Hosts(ctx context.Context, offset, limit int) ([]api.Host, error) {
 	return ss.SearchHosts(ctx, "", api.HostFilterModeAllowed, api.UsabilityFilterModeAll, "", nil, offset, limit)
 }
 
-func (ss *SQLStore) RemoveOfflineHosts(ctx context.Context, minRecentFailures uint64, maxDowntime time.Duration) (removed uint64, err error) {
-	// sanity check 'maxDowntime'
+// RemoveOfflineHosts removes hosts that exceed the given downtime and number of
+// recent scan failures. If a grace period is given, such hosts are first marked
+// as pending removal and are only removed if they still exceed both thresholds
+// once the grace period has passed.
+func (ss *SQLStore) RemoveOfflineHosts(ctx context.Context, minRecentFailures uint64, maxDowntime, gracePeriod time.Duration) (removed uint64, err error) {
+	// sanity check 'maxDowntime' and 'gracePeriod'
 	if maxDowntime < 0 {
 		return 0, ErrNegativeMaxDowntime
+	} else if gracePeriod < 0 {
+		return 0, ErrNegativeGracePeriod
+	}
+
+	// hosts that no longer exceed the thresholds aren't pending removal
+	if err := ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		return tx.
+			Model(&dbHost{}).
+			Where("pending_removal_since > 0 AND NOT (recent_downtime >= ? AND recent_scan_failures >= ?)", maxDowntime, minRecentFailures).
+			Update("pending_removal_since", 0).
+			Error
+	}); err != nil {
+		return 0, err
 	}
 
 	// fetch all hosts outside of the transaction
@@ -634,6 +913,33 @@ func (ss *SQLStore) RemoveOfflineHosts(ctx context.Context, minRecentFailures ui
 		return 0, err
 	}
 
+	// mark hosts as pending removal and skip the ones that are still within
+	// their grace period
+	if gracePeriod > 0 {
+		now := time.Now()
+		var pending []uint
+		var expired []dbHost
+		for _, h := range hosts {
+			if h.PendingRemovalSince == 0 {
+				pending = append(pending, h.ID)
+			} else if now.Sub(time.Unix(0, h.PendingRemovalSince)) >= gracePeriod {
+				expired = append(expired, h)
+			}
+		}
+		if len(pending) > 0 {
+			if err := ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+				return tx.
+					Model(&dbHost{}).
+					Where("id IN (?)", pending).
+					Update("pending_removal_since", now.UnixNano()).
+					Error
+			}); err != nil {
+				return 0, err
+			}
+		}
+		hosts = expired
+	}
+
 	// return early
 	if len(hosts) == 0 {
 		return 0, nil
@@ -669,6 +975,7 @@ func (ss *SQLStore) RemoveOfflineHosts(ctx context.Context, minRecentFailures ui
 		}); err != nil {
 			errs = append(errs, err)
 		}
+		ss.hostCache.Invalidate(types.PublicKey(h.PublicKey))
 	}
 
 	if len(errs) > 0 {
@@ -687,6 +994,7 @@ func (ss *SQLStore) UpdateHostAllowlistEntries(ctx context.Context, add, remove
 		return nil
 	}
 	defer ss.updateHasAllowlist(&err)
+	defer ss.hostCache.Clear()
 
 	// clear allowlist
 	if clear {
@@ -726,6 +1034,7 @@ func (ss *SQLStore) UpdateHostBlocklistEntries(ctx context.Context, add, remove
 		return nil
 	}
 	defer ss.updateHasBlocklist(&err)
+	defer ss.hostCache.Clear()
 
 	// clear blocklist
 	if clear {
@@ -734,14 +1043,44 @@ func (ss *SQLStore) UpdateHostBlocklistEntries(ctx context.Context, add, remove
 		})
 	}
 
-	var toInsert []dbBlocklistEntry
+	// deduplicate the entries to add
+	seen := make(map[string]struct{})
+	var toAdd []string
 	for _, entry := range add {
-		toInsert = append(toInsert, dbBlocklistEntry{Entry: entry})
+		if _, exists := seen[entry]; !exists {
+			seen[entry] = struct{}{}
+			toAdd = append(toAdd, entry)
+		}
 	}
 
 	return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		// filter out entries that already exist, their host associations are
+		// up to date which makes adding them again a no-op
+		var existing []string
+		for i := 0; i < len(toAdd); i += maxSQLVars {
+			end := i + maxSQLVars
+			if end > len(toAdd) {
+				end = len(toAdd)
+			}
+			var batch []string
+			if err := tx.Model(&dbBlocklistEntry{}).Where("entry IN ?", toAdd[i:end]).Pluck("entry", &batch).Error; err != nil {
+				return err
+			}
+			existing = append(existing, batch...)
+		}
+		exists := make(map[string]struct{})
+		for _, entry := range existing {
+			exists[entry] = struct{}{}
+		}
+		var toInsert []dbBlocklistEntry
+		for _, entry := range toAdd {
+			if _, ok := exists[entry]; !ok {
+				toInsert = append(toInsert, dbBlocklistEntry{Entry: entry})
+			}
+		}
+
 		if len(toInsert) > 0 {
-			if err := tx.Create(&toInsert).Error; err != nil {
+			if err := tx.CreateInBatches(&toInsert, 1000).Error; err != nil {
 				return err
 			}
 		}
@@ -754,6 +1093,75 @@ func (ss *SQLStore) UpdateHostBlocklistEntries(ctx context.Context, add, remove
 	})
 }
 
+// UpdateHostOverrides adds, updates and removes host overrides. Adding an
+// override for a host that already has one replaces it.
+func (ss *SQLStore) UpdateHostOverrides(ctx context.Context, add []api.HostOverride, remove []types.PublicKey, clear bool) error {
+	// nothing to do
+	if len(add)+len(remove) == 0 && !clear {
+		return nil
+	}
+	defer ss.hostCache.Clear()
+
+	// clear overrides
+	if clear {
+		return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+			return tx.Where("TRUE").Delete(&dbHostOverride{}).Error
+		})
+	}
+
+	toInsert := make([]dbHostOverride, len(add))
+	for i, o := range add {
+		if err := o.Validate(); err != nil {
+			return err
+		}
+		toInsert[i] = dbHostOverride{
+			PublicKey:       publicKey(o.HostKey),
+			ForceInclude:    o.ForceInclude,
+			ForceExclude:    o.ForceExclude,
+			ScoreMultiplier: o.ScoreMultiplier,
+		}
+	}
+
+	toDelete := make([]publicKey, len(remove))
+	for i, hk := range remove {
+		toDelete[i] = publicKey(hk)
+	}
+
+	return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		if len(toInsert) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "public_key"}},
+				DoUpdates: clause.AssignmentColumns([]string{"force_include", "force_exclude", "score_multiplier"}),
+			}).Create(&toInsert).Error; err != nil {
+				return err
+			}
+		}
+		if len(toDelete) > 0 {
+			if err := tx.Delete(&dbHostOverride{}, "public_key IN ?", toDelete).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HostOverrides returns all host overrides.
+func (ss *SQLStore) HostOverrides(ctx context.Context) ([]api.HostOverride, error) {
+	var overrides []dbHostOverride
+	if err := ss.db.
+		WithContext(ctx).
+		Order("id ASC").
+		Find(&overrides).
+		Error; err != nil {
+		return nil, err
+	}
+	resp := make([]api.HostOverride, len(overrides))
+	for i, o := range overrides {
+		resp[i] = o.convert()
+	}
+	return resp, nil
+}
+
 func (ss *SQLStore) HostAllowlist(ctx context.Context) (allowlist []types.PublicKey, err error) {
 	var pubkeys []publicKey
 	err = ss.db.
@@ -792,6 +1200,13 @@ func (ss *SQLStore) RecordHostScans(ctx context.Context, scans []api.HostScan) e
 		}
 	}
 
+	// invalidate the cached hosts once the scans are recorded
+	defer func() {
+		for _, hk := range hks {
+			ss.hostCache.Invalidate(types.PublicKey(hk))
+		}
+	}()
+
 	// Fetch hosts for which to add scans. This can be done outsisde the
 	// transaction to reduce the time we spend in the transaction since we don't
 	// need it to be perfectly consistent.
@@ -832,6 +1247,7 @@ func (ss *SQLStore) RecordHostScans(ctx context.Context, scans []api.HostScan) e
 				}
 				host.RecentDowntime = 0
 				host.RecentScanFailures = 0
+				host.PendingRemovalSince = 0
 
 				// overwrite the NetAddress in the settings with the one we
 				// received through the host announcement
@@ -881,6 +1297,7 @@ func (ss *SQLStore) RecordHostScans(ctx context.Context, scans []api.HostScan) e
 					"last_scan_success":           h.LastScanSuccess,
 					"recent_downtime":             h.RecentDowntime,
 					"recent_scan_failures":        h.RecentScanFailures,
+					"pending_removal_since":       h.PendingRemovalSince,
 					"downtime":                    h.Downtime,
 					"uptime":                      h.Uptime,
 					"last_scan":                   h.LastScan,
@@ -913,6 +1330,13 @@ func (ss *SQLStore) RecordPriceTables(ctx context.Context, priceTableUpdate []ap
 		}
 	}
 
+	// invalidate the cached hosts once the price tables are recorded
+	defer func() {
+		for _, hk := range hks {
+			ss.hostCache.Invalidate(types.PublicKey(hk))
+		}
+	}()
+
 	// Fetch hosts for which to add interactions. This can be done
 	// outsisde the transaction to reduce the time we spend in the
 	// transaction since we don't need it to be perfectly
@@ -949,6 +1373,7 @@ func (ss *SQLStore) RecordPriceTables(ctx context.Context, priceTableUpdate []ap
 				host.SuccessfulInteractions++
 				host.RecentDowntime = 0
 				host.RecentScanFailures = 0
+				host.PendingRemovalSince = 0
 
 				// Update pricetable.
 				host.PriceTable = convertHostPriceTable(ptu.PriceTable.HostPriceTable)
@@ -972,6 +1397,7 @@ func (ss *SQLStore) RecordPriceTables(ctx context.Context, priceTableUpdate []ap
 				Updates(map[string]interface{}{
 					"recent_downtime":         h.RecentDowntime,
 					"recent_scan_failures":    h.RecentScanFailures,
+					"pending_removal_since":   h.PendingRemovalSince,
 					"price_table":             h.PriceTable,
 					"price_table_expiry":      h.PriceTableExpiry,
 					"successful_interactions": h.SuccessfulInteractions,
@@ -985,6 +1411,108 @@ func (ss *SQLStore) RecordPriceTables(ctx context.Context, priceTableUpdate []ap
 	})
 }
 
+// RecordHostOperations updates the rolling operation counts of the given
+// hosts, every recorded operation decays the previously recorded ones.
+func (ss *SQLStore) RecordHostOperations(ctx context.Context, records []api.HostOperationRecord) error {
+	if len(records) == 0 {
+		return nil // nothing to do
+	}
+
+	// invalidate the cached hosts once the operations are recorded
+	defer func() {
+		for _, r := range records {
+			ss.hostCache.Invalidate(r.HostKey)
+		}
+	}()
+
+	return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		for _, r := range records {
+			var host dbHost
+			err := tx.Model(&dbHost{}).
+				Where("public_key", publicKey(r.HostKey)).
+				Take(&host).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue // host doesn't exist
+			} else if err != nil {
+				return err
+			}
+
+			decay := math.Pow(hostOperationsDecay, float64(r.Successful+r.Failed))
+			err = tx.Model(&dbHost{}).
+				Where("public_key", host.PublicKey).
+				Updates(map[string]interface{}{
+					"successful_operations": host.SuccessfulOperations*decay + float64(r.Successful),
+					"failed_operations":     host.FailedOperations*decay + float64(r.Failed),
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RecordHostBandwidth adds the given number of uploaded and downloaded bytes to
+// the bandwidth counters of the given hosts.
+func (ss *SQLStore) RecordHostBandwidth(ctx context.Context, records []api.HostBandwidth) error {
+	if len(records) == 0 {
+		return nil // nothing to do
+	}
+
+	return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		for _, r := range records {
+			err := tx.Model(&dbHost{}).
+				Where("public_key", publicKey(r.HostKey)).
+				Updates(map[string]interface{}{
+					"uploaded_bytes":   gorm.Expr("uploaded_bytes + ?", r.Uploaded),
+					"downloaded_bytes": gorm.Expr("downloaded_bytes + ?", r.Downloaded),
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HostBandwidth returns the bandwidth counters of all hosts we've transferred
+// data to or from since the counters were last reset.
+func (ss *SQLStore) HostBandwidth(ctx context.Context) ([]api.HostBandwidth, error) {
+	var hosts []dbHost
+	if err := ss.db.
+		WithContext(ctx).
+		Model(&dbHost{}).
+		Select("public_key, uploaded_bytes, downloaded_bytes").
+		Where("uploaded_bytes > 0 OR downloaded_bytes > 0").
+		Order("id ASC").
+		Find(&hosts).
+		Error; err != nil {
+		return nil, err
+	}
+
+	bandwidth := make([]api.HostBandwidth, len(hosts))
+	for i, h := range hosts {
+		bandwidth[i] = api.HostBandwidth{
+			HostKey:    types.PublicKey(h.PublicKey),
+			Uploaded:   h.UploadedBytes,
+			Downloaded: h.DownloadedBytes,
+		}
+	}
+	return bandwidth, nil
+}
+
+// ResetHostBandwidth resets the bandwidth counters of all hosts.
+func (ss *SQLStore) ResetHostBandwidth(ctx context.Context) error {
+	return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		return tx.Model(&dbHost{}).
+			Where("uploaded_bytes > 0 OR downloaded_bytes > 0").
+			Updates(map[string]interface{}{
+				"uploaded_bytes":   0,
+				"downloaded_bytes": 0,
+			}).Error
+	})
+}
+
 func (ss *SQLStore) processConsensusChangeHostDB(cc modules.ConsensusChange) {
 	height := uint64(cc.InitialHeight())
 	for range cc.RevertedBlocks {
@@ -1122,26 +1650,74 @@ func updateCCID(tx *gorm.DB, newCCID modules.ConsensusChangeID, newTip types.Cha
 	}).Error
 }
 
+// insertAnnouncements stores the given announcements and updates the hosts
+// they belong to. A host's net address is always resolved to its latest
+// announcement, announcements at a lower height than an announcement we've
+// already stored for the same host don't update the host.
 func insertAnnouncements(tx *gorm.DB, as []announcement) error {
-	var hosts []dbHost
+	var hks []publicKey
 	var announcements []dbAnnouncement
+	latest := make(map[publicKey]announcement)
 	for _, a := range as {
-		hosts = append(hosts, dbHost{
-			PublicKey:        a.hostKey,
-			LastAnnouncement: a.announcement.Timestamp.UTC(),
-			NetAddress:       a.announcement.NetAddress,
-		})
 		announcements = append(announcements, dbAnnouncement{
 			HostKey:     a.hostKey,
 			BlockHeight: a.announcement.Index.Height,
 			BlockID:     a.announcement.Index.ID.String(),
 			NetAddress:  a.announcement.NetAddress,
 		})
+		if prev, exists := latest[a.hostKey]; !exists {
+			hks = append(hks, a.hostKey)
+			latest[a.hostKey] = a
+		} else if a.announcement.Index.Height >= prev.announcement.Index.Height {
+			latest[a.hostKey] = a
+		}
+	}
+
+	// fetch the height of the latest announcement we've stored for every host
+	storedHeights := make(map[publicKey]uint64)
+	for i := 0; i < len(hks); i += maxSQLVars {
+		end := i + maxSQLVars
+		if end > len(hks) {
+			end = len(hks)
+		}
+		var stored []struct {
+			HostKey     publicKey
+			BlockHeight uint64
+		}
+		if err := tx.Model(&dbAnnouncement{}).
+			Select("host_key, MAX(block_height) as block_height").
+			Where("host_key IN ?", hks[i:end]).
+			Group("host_key").
+			Scan(&stored).Error; err != nil {
+			return err
+		}
+		for _, s := range stored {
+			storedHeights[s.HostKey] = s.BlockHeight
+		}
+	}
+
+	// only update hosts for which the announcement is the latest one
+	var hosts []dbHost
+	for _, hk := range hks {
+		a := latest[hk]
+		if height, exists := storedHeights[hk]; exists && height > a.announcement.Index.Height {
+			continue
+		}
+		hosts = append(hosts, dbHost{
+			PublicKey:        a.hostKey,
+			LastAnnouncement: a.announcement.Timestamp.UTC(),
+			NetAddress:       a.announcement.NetAddress,
+		})
 	}
-	if err := tx.Create(&announcements).Error; err != nil {
+
+	// NOTE: hosts are upserted on conflict with their public key, see
+	// dbHost.BeforeCreate
+	if err := tx.CreateInBatches(&announcements, hostInsertionBatchSize).Error; err != nil {
 		return err
+	} else if len(hosts) == 0 {
+		return nil
 	}
-	return tx.Create(&hosts).Error
+	return tx.CreateInBatches(&hosts, hostInsertionBatchSize).Error
 }
 
 func applyRevisionUpdate(db *gorm.DB, fcid types.FileContractID, rev revisionUpdate) error {
@@ -1219,6 +1795,7 @@ func updateBlocklist(tx *gorm.DB, hk types.PublicKey, allowlist []dbAllowlistEnt
 }
 
 func (s *SQLStore) ResetLostSectors(ctx context.Context, hk types.PublicKey) error {
+	defer s.hostCache.Invalidate(hk)
 	return s.retryTransaction(ctx, func(tx *gorm.DB) error {
 		return tx.Model(&dbHost{}).
 			Where("public_key", publicKey(hk)).
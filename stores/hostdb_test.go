@@ -3,8 +3,11 @@ package stores
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"reflect"
 	"testing"
 	"time"
@@ -204,7 +207,7 @@ func TestSQLHosts(t *testing.T) {
 	}
 
 	// Fetch all hosts using the HostsForScanning method.
-	hostAddresses, err := ss.HostsForScanning(ctx, n, 0, 3)
+	hostAddresses, err := ss.HostsForScanning(ctx, n, api.HostSortByLastScan, api.HostSortDirAsc, 0, 3)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -221,8 +224,39 @@ func TestSQLHosts(t *testing.T) {
 		t.Fatal("wrong key")
 	}
 
+	// Fetch all hosts, most recently scanned first.
+	hostAddresses, err = ss.HostsForScanning(ctx, n, api.HostSortByLastScan, api.HostSortDirDesc, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostAddresses) != 3 {
+		t.Fatal("wrong number of addresses")
+	}
+	if hostAddresses[0].PublicKey != hk1 || hostAddresses[1].PublicKey != hk2 || hostAddresses[2].PublicKey != hk3 {
+		t.Fatal("wrong order")
+	}
+
+	// Fetch all hosts without sorting, they should be ordered by id.
+	hostAddresses, err = ss.HostsForScanning(ctx, n, "", "", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostAddresses) != 3 {
+		t.Fatal("wrong number of addresses")
+	}
+	if hostAddresses[0].PublicKey != hk1 || hostAddresses[1].PublicKey != hk2 || hostAddresses[2].PublicKey != hk3 {
+		t.Fatal("wrong order")
+	}
+
+	// Assert invalid sort parameters are rejected.
+	if _, err := ss.HostsForScanning(ctx, n, "foo", "", 0, 3); !errors.Is(err, api.ErrInvalidHostSortParameters) {
+		t.Fatal("unexpected error", err)
+	} else if _, err := ss.HostsForScanning(ctx, n, api.HostSortByLastScan, "foo", 0, 3); !errors.Is(err, api.ErrInvalidHostSortParameters) {
+		t.Fatal("unexpected error", err)
+	}
+
 	// Fetch one host by setting the cutoff exactly to hk2.
-	hostAddresses, err = ss.HostsForScanning(ctx, n.Add(-2*time.Minute), 0, 3)
+	hostAddresses, err = ss.HostsForScanning(ctx, n.Add(-2*time.Minute), "", "", 0, 3)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -231,13 +265,39 @@ func TestSQLHosts(t *testing.T) {
 	}
 
 	// Fetch no hosts.
-	hostAddresses, err = ss.HostsForScanning(ctx, time.Time{}, 0, 3)
+	hostAddresses, err = ss.HostsForScanning(ctx, time.Time{}, "", "", 0, 3)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if len(hostAddresses) != 0 {
 		t.Fatal("wrong number of addresses")
 	}
+
+	// Blocklist hk1 and assert it's not returned for scanning.
+	if err := ss.addCustomTestHost(hk1, "foo.com:1000"); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateHostBlocklistEntries(ctx, []string{"foo.com"}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	hostAddresses, err = ss.HostsForScanning(ctx, n, "", "", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostAddresses) != 2 || hostAddresses[0].PublicKey != hk2 || hostAddresses[1].PublicKey != hk3 {
+		t.Fatal("unexpected hosts", hostAddresses)
+	}
+
+	// Allowlist hk2 and assert only hk2 is returned for scanning.
+	if err := ss.UpdateHostAllowlistEntries(ctx, []types.PublicKey{hk2}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	hostAddresses, err = ss.HostsForScanning(ctx, n, "", "", 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hostAddresses) != 1 || hostAddresses[0].PublicKey != hk2 {
+		t.Fatal("unexpected hosts", hostAddresses)
+	}
 }
 
 // TestSearchHosts is a unit test for SearchHosts.
@@ -319,6 +379,13 @@ func TestSearchHosts(t *testing.T) {
 	} else if his[0].PublicKey != (types.PublicKey{1}) {
 		t.Fatal("unexpected", his)
 	}
+
+	// assert the host counts match the filter modes
+	if allowed, blocked, err := ss.HostCounts(ctx); err != nil {
+		t.Fatal(err)
+	} else if allowed != 2 || blocked != 1 {
+		t.Fatal("unexpected counts", allowed, blocked)
+	}
 	err = ss.UpdateHostBlocklistEntries(context.Background(), nil, nil, true)
 	if err != nil {
 		t.Fatal(err)
@@ -374,11 +441,11 @@ func TestSearchHosts(t *testing.T) {
 	}
 
 	// assert h1 and h2 have the expected checks
-	if c1, ok := his[0].Checks[ap1]; !ok || c1 != h1c {
+	if c1, ok := his[0].Checks[ap1]; !ok || !reflect.DeepEqual(c1, h1c) {
 		t.Fatal("unexpected", c1, ok)
-	} else if c2, ok := his[1].Checks[ap1]; !ok || c2 != h2c1 {
+	} else if c2, ok := his[1].Checks[ap1]; !ok || !reflect.DeepEqual(c2, h2c1) {
 		t.Fatal("unexpected", c2, ok)
-	} else if c3, ok := his[1].Checks[ap2]; !ok || c3 != h2c2 {
+	} else if c3, ok := his[1].Checks[ap2]; !ok || !reflect.DeepEqual(c3, h2c2) {
 		t.Fatal("unexpected", c3, ok)
 	}
 
@@ -391,9 +458,9 @@ func TestSearchHosts(t *testing.T) {
 	}
 
 	// assert h1 and h2 have the expected checks
-	if c1, ok := his[0].Checks[ap1]; !ok || c1 != h1c {
+	if c1, ok := his[0].Checks[ap1]; !ok || !reflect.DeepEqual(c1, h1c) {
 		t.Fatal("unexpected", c1, ok)
-	} else if c2, ok := his[1].Checks[ap1]; !ok || c2 != h2c1 {
+	} else if c2, ok := his[1].Checks[ap1]; !ok || !reflect.DeepEqual(c2, h2c1) {
 		t.Fatal("unexpected", c2, ok)
 	} else if _, ok := his[1].Checks[ap2]; ok {
 		t.Fatal("unexpected")
@@ -413,7 +480,7 @@ func TestSearchHosts(t *testing.T) {
 	}
 
 	// assert h1 has the expected checks
-	if c1, ok := his[0].Checks[ap1]; !ok || c1 != h1c {
+	if c1, ok := his[0].Checks[ap1]; !ok || !reflect.DeepEqual(c1, h1c) {
 		t.Fatal("unexpected", c1, ok)
 	}
 
@@ -581,6 +648,169 @@ func TestRecordScan(t *testing.T) {
 	}
 }
 
+func TestRecordScanUptime(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// Add a host.
+	hk := types.GeneratePrivateKey().PublicKey()
+	err := ss.addCustomTestHost(hk, "host.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert a host that wasn't scanned has a ratio of 0.
+	ctx := context.Background()
+	host, err := ss.Host(ctx, hk)
+	if err != nil {
+		t.Fatal(err)
+	} else if ratio := host.Interactions.UptimeRatio(); ratio != 0 {
+		t.Fatalf("unexpected ratio %v", ratio)
+	}
+
+	// Record alternating scan outcomes, every scan accounts for the time
+	// since the previous scan, a successful scan as uptime and a failed scan
+	// as downtime.
+	var uptime, downtime time.Duration
+	scanTime := time.Now().UTC()
+	settings := rhpv2.HostSettings{NetAddress: "host.com"}
+	for i, success := range []bool{true, false, true, false, true, true} {
+		interval := time.Duration(i) * time.Hour
+		scanTime = scanTime.Add(interval)
+		if err := ss.RecordHostScans(ctx, []api.HostScan{newTestScan(hk, scanTime, settings, success)}); err != nil {
+			t.Fatal(err)
+		}
+		if success {
+			uptime += interval
+		} else {
+			downtime += interval
+		}
+
+		host, err := ss.Host(ctx, hk)
+		if err != nil {
+			t.Fatal(err)
+		} else if host.Interactions.Uptime != uptime {
+			t.Fatalf("unexpected uptime %v != %v", host.Interactions.Uptime, uptime)
+		} else if host.Interactions.Downtime != downtime {
+			t.Fatalf("unexpected downtime %v != %v", host.Interactions.Downtime, downtime)
+		}
+	}
+
+	// Assert the ratio, the host was up for 0+2+4+5 hours and down for 1+3.
+	host, err = ss.Host(ctx, hk)
+	if err != nil {
+		t.Fatal(err)
+	} else if ratio := host.Interactions.UptimeRatio(); ratio != 11.0/15.0 {
+		t.Fatalf("unexpected ratio %v", ratio)
+	}
+
+	// Assert the ratio is included when encoding the interactions.
+	var encoded struct {
+		Uptime      time.Duration `json:"uptime"`
+		UptimeRatio float64       `json:"uptimeRatio"`
+	}
+	if b, err := json.Marshal(host.Interactions); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(b, &encoded); err != nil {
+		t.Fatal(err)
+	} else if encoded.UptimeRatio != 11.0/15.0 {
+		t.Fatalf("unexpected ratio %v", encoded.UptimeRatio)
+	} else if encoded.Uptime != uptime {
+		t.Fatalf("unexpected uptime %v", encoded.Uptime)
+	}
+}
+
+func TestRecordHostOperations(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add a host
+	hk := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addTestHost(hk); err != nil {
+		t.Fatal(err)
+	}
+
+	// record mixed outcomes, including a record for an unknown host
+	ctx := context.Background()
+	if err := ss.RecordHostOperations(ctx, []api.HostOperationRecord{
+		{HostKey: hk, Successful: 3, Failed: 1},
+		{HostKey: types.PublicKey{1}, Successful: 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the counts
+	h, err := ss.Host(ctx, hk)
+	if err != nil {
+		t.Fatal(err)
+	} else if h.Interactions.SuccessfulOperations != 3 || h.Interactions.FailedOperations != 1 {
+		t.Fatalf("unexpected operations %v %v", h.Interactions.SuccessfulOperations, h.Interactions.FailedOperations)
+	} else if rate := h.Interactions.OperationSuccessRate(); rate != 0.75 {
+		t.Fatal("unexpected success rate", rate)
+	}
+
+	// record more failures and assert the older operations decayed
+	if err := ss.RecordHostOperations(ctx, []api.HostOperationRecord{
+		{HostKey: hk, Successful: 1, Failed: 3},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	decay := math.Pow(hostOperationsDecay, 4)
+	expectedSuccessful, expectedFailed := 3*decay+1, 1*decay+3
+	h, err = ss.Host(ctx, hk)
+	if err != nil {
+		t.Fatal(err)
+	} else if math.Abs(h.Interactions.SuccessfulOperations-expectedSuccessful) > 1e-9 || math.Abs(h.Interactions.FailedOperations-expectedFailed) > 1e-9 {
+		t.Fatalf("unexpected operations %v %v", h.Interactions.SuccessfulOperations, h.Interactions.FailedOperations)
+	} else if rate := h.Interactions.OperationSuccessRate(); rate >= 0.5 {
+		t.Fatal("unexpected success rate", rate)
+	}
+}
+
+func TestRecordHostBandwidth(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add three hosts
+	hks, err := ss.addTestHosts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// record transfers against the first two hosts, including a record for
+	// an unknown host
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := ss.RecordHostBandwidth(ctx, []api.HostBandwidth{
+			{HostKey: hks[0], Uploaded: 10, Downloaded: 20},
+			{HostKey: hks[1], Downloaded: 5},
+			{HostKey: types.GeneratePrivateKey().PublicKey(), Uploaded: 1},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assert the counts were accumulated and the third host is omitted
+	bandwidth, err := ss.HostBandwidth(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(bandwidth, []api.HostBandwidth{
+		{HostKey: hks[0], Uploaded: 20, Downloaded: 40},
+		{HostKey: hks[1], Downloaded: 10},
+	}) {
+		t.Fatalf("unexpected bandwidth %+v", bandwidth)
+	}
+
+	// reset the counters and assert they're gone
+	if err := ss.ResetHostBandwidth(ctx); err != nil {
+		t.Fatal(err)
+	} else if bandwidth, err := ss.HostBandwidth(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(bandwidth) != 0 {
+		t.Fatalf("unexpected bandwidth %+v", bandwidth)
+	}
+}
+
 func TestRemoveHosts(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -602,7 +832,7 @@ func TestRemoveHosts(t *testing.T) {
 	}
 
 	// assert no hosts are removed
-	removed, err := ss.RemoveOfflineHosts(context.Background(), 0, time.Hour)
+	removed, err := ss.RemoveOfflineHosts(context.Background(), 0, time.Hour, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -634,7 +864,7 @@ func TestRemoveHosts(t *testing.T) {
 	}
 
 	// assert no hosts are removed
-	removed, err = ss.RemoveOfflineHosts(context.Background(), 0, time.Hour)
+	removed, err = ss.RemoveOfflineHosts(context.Background(), 0, time.Hour, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -650,7 +880,7 @@ func TestRemoveHosts(t *testing.T) {
 	}
 
 	// assert no hosts are removed at 61 minutes
-	removed, err = ss.RemoveOfflineHosts(context.Background(), 0, time.Minute*61)
+	removed, err = ss.RemoveOfflineHosts(context.Background(), 0, time.Minute*61, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -659,7 +889,7 @@ func TestRemoveHosts(t *testing.T) {
 	}
 
 	// assert no hosts are removed at 60 minutes if we require at least 4 failed scans
-	removed, err = ss.RemoveOfflineHosts(context.Background(), 4, time.Minute*60)
+	removed, err = ss.RemoveOfflineHosts(context.Background(), 4, time.Minute*60, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -668,7 +898,7 @@ func TestRemoveHosts(t *testing.T) {
 	}
 
 	// assert hosts gets removed at 60 minutes if we require at least 3 failed scans
-	removed, err = ss.RemoveOfflineHosts(context.Background(), 3, time.Minute*60)
+	removed, err = ss.RemoveOfflineHosts(context.Background(), 3, time.Minute*60, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -682,6 +912,95 @@ func TestRemoveHosts(t *testing.T) {
 	}
 }
 
+func TestRemoveHostsGracePeriod(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add two hosts
+	hks, err := ss.addTestHosts(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hk1, hk2 := hks[0], hks[1]
+
+	// both hosts fail two scans an hour apart
+	now := time.Now().UTC()
+	for _, hk := range hks {
+		if err := ss.RecordHostScans(context.Background(), []api.HostScan{
+			newTestScan(hk, now.Add(-2*time.Hour), rhpv2.HostSettings{}, false),
+			newTestScan(hk, now.Add(-time.Hour), rhpv2.HostSettings{}, false),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assertPending asserts the given hosts are pending removal
+	assertPending := func(expected ...types.PublicKey) {
+		t.Helper()
+		pending, err := ss.HostsPendingRemoval(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		} else if len(pending) != len(expected) {
+			t.Fatalf("expected %d hosts pending removal, got %d", len(expected), len(pending))
+		}
+		for i, hk := range expected {
+			if pending[i].HostKey != hk {
+				t.Fatalf("unexpected host pending removal %v != %v", pending[i].HostKey, hk)
+			} else if pending[i].Since.IsZero() {
+				t.Fatal("expected pending removal timestamp to be set")
+			}
+		}
+	}
+	assertPending()
+
+	// assert the hosts aren't removed but marked as pending removal
+	removed, err := ss.RemoveOfflineHosts(context.Background(), 2, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	} else if removed != 0 {
+		t.Fatalf("expected no hosts to be removed, got %d", removed)
+	}
+	assertPending(hk1, hk2)
+
+	// the first host recovers within the grace period
+	if err := ss.RecordHostScans(context.Background(), []api.HostScan{
+		newTestScan(hk1, now, rhpv2.HostSettings{}, true),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertPending(hk2)
+
+	// assert nothing is removed while the grace period hasn't passed
+	removed, err = ss.RemoveOfflineHosts(context.Background(), 2, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	} else if removed != 0 {
+		t.Fatalf("expected no hosts to be removed, got %d", removed)
+	}
+
+	// let the grace period pass
+	if err := ss.db.
+		Model(&dbHost{}).
+		Where("public_key", publicKey(hk2)).
+		Update("pending_removal_since", now.Add(-2*time.Hour).UnixNano()).
+		Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// assert only the second host is removed
+	removed, err = ss.RemoveOfflineHosts(context.Background(), 2, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 host to be removed, got %d", removed)
+	} else if _, err := hostByPubKey(ss.db, hk1); err != nil {
+		t.Fatal(err)
+	} else if _, err := hostByPubKey(ss.db, hk2); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatal("expected record not found error", err)
+	}
+	assertPending()
+}
+
 // TestInsertAnnouncements is a test for insertAnnouncements.
 func TestInsertAnnouncements(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
@@ -762,6 +1081,119 @@ func TestInsertAnnouncements(t *testing.T) {
 	}
 }
 
+// TestInsertAnnouncementsLatestWins asserts a host's net address resolves to
+// the announcement at the highest height, regardless of insertion order.
+func TestInsertAnnouncementsLatestWins(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// helper to create an announcement at a given height
+	hk := types.GeneratePrivateKey().PublicKey()
+	newAnnouncement := func(addr string, height uint64) announcement {
+		ha := newTestHostDBAnnouncement(addr)
+		ha.Index = types.ChainIndex{Height: height, ID: types.BlockID{byte(height)}}
+		return announcement{hostKey: publicKey(hk), announcement: ha}
+	}
+	assertNetAddress := func(expected string) {
+		t.Helper()
+		h, err := ss.Host(context.Background(), hk)
+		if err != nil {
+			t.Fatal(err)
+		} else if h.NetAddress != expected {
+			t.Fatalf("unexpected net address %v, expected %v", h.NetAddress, expected)
+		}
+	}
+
+	// insert the newer announcement first, then the older one
+	if err := insertAnnouncements(ss.db, []announcement{newAnnouncement("new.host:9982", 20)}); err != nil {
+		t.Fatal(err)
+	} else if err := insertAnnouncements(ss.db, []announcement{newAnnouncement("old.host:9982", 10)}); err != nil {
+		t.Fatal(err)
+	}
+	assertNetAddress("new.host:9982")
+
+	// insert both in a single batch, with the newest one first
+	if err := insertAnnouncements(ss.db, []announcement{
+		newAnnouncement("newest.host:9982", 30),
+		newAnnouncement("old.host:9982", 10),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	assertNetAddress("newest.host:9982")
+
+	// assert all announcements were stored
+	var count int64
+	if err := ss.db.Model(&dbAnnouncement{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	} else if count != 4 {
+		t.Fatalf("expected 4 announcements, got %v", count)
+	}
+}
+
+// TestInsertAnnouncementsBatch asserts that a large number of announcements can
+// be inserted at once and that hosts are upserted with their latest address.
+func TestInsertAnnouncementsBatch(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// prepare announcements for more hosts than fit in a single batch, the
+	// number of SQL variables would exceed the limit if we didn't batch
+	const n = 5*hostInsertionBatchSize + 1
+	hks := make([]types.PublicKey, n)
+	newAnnouncements := func(prefix string, height uint64) []announcement {
+		as := make([]announcement, n)
+		for i, hk := range hks {
+			ha := newTestHostDBAnnouncement(fmt.Sprintf("%s-%d.host:9982", prefix, i))
+			ha.Index = types.ChainIndex{Height: height, ID: types.BlockID{byte(height)}}
+			as[i] = announcement{hostKey: publicKey(hk), announcement: ha}
+		}
+		return as
+	}
+	for i := range hks {
+		hks[i] = types.GeneratePrivateKey().PublicKey()
+	}
+
+	// assertHosts asserts all hosts exist and have the expected address
+	assertHosts := func(prefix string) {
+		t.Helper()
+		var hosts []dbHost
+		if err := ss.db.Find(&hosts).Error; err != nil {
+			t.Fatal(err)
+		} else if len(hosts) != n {
+			t.Fatalf("expected %d hosts, got %d", n, len(hosts))
+		}
+		addrs := make(map[types.PublicKey]string)
+		for _, h := range hosts {
+			addrs[types.PublicKey(h.PublicKey)] = h.NetAddress
+		}
+		for i, hk := range hks {
+			if expected := fmt.Sprintf("%s-%d.host:9982", prefix, i); addrs[hk] != expected {
+				t.Fatalf("unexpected net address for host %d, %v != %v", i, addrs[hk], expected)
+			}
+		}
+	}
+
+	// insert the announcements
+	if err := insertAnnouncements(ss.db, newAnnouncements("foo", 1)); err != nil {
+		t.Fatal(err)
+	}
+	assertHosts("foo")
+
+	// re-announce all hosts, their addresses should be updated
+	if err := insertAnnouncements(ss.db, newAnnouncements("bar", 2)); err != nil {
+		t.Fatal(err)
+	}
+	assertHosts("bar")
+
+	// assert all announcements were stored
+	var count int64
+	if err := ss.db.Model(&dbAnnouncement{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	} else if count != 2*n {
+		t.Fatalf("expected %d announcements, got %v", 2*n, count)
+	}
+}
+
 func TestSQLHostAllowlist(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -934,6 +1366,79 @@ func TestSQLHostAllowlist(t *testing.T) {
 	}
 }
 
+func TestSQLHostBlocklistBulk(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+	ctx := context.Background()
+
+	numBlocklistRelations := func() (cnt int64) {
+		t.Helper()
+		err := ss.db.Table("host_blocklist_entry_hosts").Count(&cnt).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// add three hosts
+	hk1, hk2, hk3 := types.PublicKey{1}, types.PublicKey{2}, types.PublicKey{3}
+	if err := ss.addCustomTestHost(hk1, "foo.bar.com:1000"); err != nil {
+		t.Fatal(err)
+	} else if err := ss.addCustomTestHost(hk2, "bar.baz.com:2000"); err != nil {
+		t.Fatal(err)
+	} else if err := ss.addCustomTestHost(hk3, "foobar.com:3000"); err != nil {
+		t.Fatal(err)
+	}
+
+	// add one entry up front
+	if err := ss.UpdateHostBlocklistEntries(ctx, []string{"foo.bar.com"}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// add a batch of entries containing duplicates and the existing entry
+	var batch []string
+	for i := 0; i < 100; i++ {
+		batch = append(batch, fmt.Sprintf("host%d.com", i))
+	}
+	batch = append(batch, "foo.bar.com", "baz.com", "baz.com")
+	if err := ss.UpdateHostBlocklistEntries(ctx, batch, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the entries and host associations were created
+	if bl, err := ss.HostBlocklist(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(bl) != 102 {
+		t.Fatalf("unexpected number of entries in blocklist, %v != 102", len(bl))
+	}
+	if n := numBlocklistRelations(); n != 2 {
+		t.Fatalf("unexpected number of entries in join table, %v != 2", n)
+	}
+	for _, hk := range []types.PublicKey{hk1, hk2} {
+		if h, err := ss.Host(ctx, hk); err != nil {
+			t.Fatal(err)
+		} else if !h.Blocked {
+			t.Fatal("expected host to be blocked", hk)
+		}
+	}
+	if h, err := ss.Host(ctx, hk3); err != nil {
+		t.Fatal(err)
+	} else if h.Blocked {
+		t.Fatal("expected host not to be blocked")
+	}
+
+	// assert adding the batch again is a no-op
+	if err := ss.UpdateHostBlocklistEntries(ctx, batch, nil, false); err != nil {
+		t.Fatal(err)
+	} else if bl, err := ss.HostBlocklist(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(bl) != 102 {
+		t.Fatalf("unexpected number of entries in blocklist, %v != 102", len(bl))
+	} else if n := numBlocklistRelations(); n != 2 {
+		t.Fatalf("unexpected number of entries in join table, %v != 2", n)
+	}
+}
+
 func TestSQLHostBlocklist(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -1179,6 +1684,106 @@ func TestSQLHostBlocklist(t *testing.T) {
 	}
 }
 
+func TestSQLHostBlocklistSubnet(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// mock the resolver, foo.com lies outside the subnet and bar.com inside
+	lookupIPAddr = func(_ context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "foo.com":
+			return []net.IPAddr{{IP: net.IP{1, 2, 4, 1}}}, nil
+		case "bar.com":
+			return []net.IPAddr{{IP: net.IP{5, 5, 5, 5}}, {IP: net.IP{1, 2, 3, 100}}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	defer func() { lookupIPAddr = net.DefaultResolver.LookupIPAddr }()
+
+	ctx := context.Background()
+	isBlocked := func(hk types.PublicKey) bool {
+		t.Helper()
+		host, err := ss.Host(ctx, hk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return host.Blocked
+	}
+
+	// add a host inside the subnet, one outside of it and one with a hostname
+	hk1 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk1, "1.2.3.4:9982"); err != nil {
+		t.Fatal(err)
+	}
+	hk2 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk2, "1.2.4.4:9982"); err != nil {
+		t.Fatal(err)
+	}
+	hk3 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk3, "foo.com:9982"); err != nil {
+		t.Fatal(err)
+	}
+	hk6 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk6, "bar.com:9982"); err != nil {
+		t.Fatal(err)
+	}
+
+	// block the subnet alongside an exact-match entry
+	err := ss.UpdateHostBlocklistEntries(ctx, []string{"1.2.3.0/24", "1.2.5.5"}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assert only the host inside the subnet is blocked
+	if !isBlocked(hk1) {
+		t.Fatal("expected host inside the subnet to be blocked")
+	} else if isBlocked(hk2) {
+		t.Fatal("expected host outside the subnet not to be blocked")
+	} else if isBlocked(hk3) {
+		t.Fatal("expected host with hostname outside the subnet not to be blocked")
+	} else if !isBlocked(hk6) {
+		t.Fatal("expected host with hostname inside the subnet to be blocked")
+	}
+
+	// announce a new host with a hostname that resolves into the subnet
+	hk7 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk7, "bar.com:9983"); err != nil {
+		t.Fatal(err)
+	} else if !isBlocked(hk7) {
+		t.Fatal("expected newly announced host with hostname inside the subnet to be blocked")
+	}
+
+	// announce a new host inside the subnet and one matching the exact entry
+	hk4 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk4, "1.2.3.200:9982"); err != nil {
+		t.Fatal(err)
+	}
+	hk5 := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addCustomTestHost(hk5, "1.2.5.5:9982"); err != nil {
+		t.Fatal(err)
+	}
+	if !isBlocked(hk4) {
+		t.Fatal("expected newly announced host inside the subnet to be blocked")
+	} else if !isBlocked(hk5) {
+		t.Fatal("expected host matching the exact entry to be blocked")
+	}
+
+	// move the first host out of the subnet
+	if err := ss.addCustomTestHost(hk1, "1.2.4.5:9982"); err != nil {
+		t.Fatal(err)
+	} else if isBlocked(hk1) {
+		t.Fatal("expected host that moved out of the subnet not to be blocked")
+	}
+
+	// remove the subnet entry
+	err = ss.UpdateHostBlocklistEntries(ctx, nil, []string{"1.2.3.0/24"}, false)
+	if err != nil {
+		t.Fatal(err)
+	} else if isBlocked(hk4) {
+		t.Fatal("expected host not to be blocked after removing the subnet")
+	}
+}
+
 func TestSQLHostBlocklistBasic(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -1216,6 +1821,78 @@ func TestSQLHostBlocklistBasic(t *testing.T) {
 }
 
 // TestAnnouncementMaxAge verifies old announcements are ignored.
+func TestHostOverrides(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+	ss.hostCache = newHostCache(10, time.Hour)
+
+	// add 2 hosts
+	hks, err := ss.addTestHosts(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hk1, hk2 := hks[0], hks[1]
+
+	// assertOverride asserts the override of the host with the given key
+	assertOverride := func(hk types.PublicKey, expected *api.HostOverride) {
+		t.Helper()
+		if h, err := ss.Host(context.Background(), hk); err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(h.Override, expected) {
+			t.Fatalf("unexpected override %+v, expected %+v", h.Override, expected)
+		}
+	}
+	assertOverride(hk1, nil)
+
+	// add overrides for both hosts and one we don't know yet
+	o1 := api.HostOverride{HostKey: hk1, ForceInclude: true, ScoreMultiplier: 2}
+	o2 := api.HostOverride{HostKey: hk2, ForceExclude: true}
+	o3 := api.HostOverride{HostKey: types.PublicKey{3}, ScoreMultiplier: 0.5}
+	if err := ss.UpdateHostOverrides(context.Background(), []api.HostOverride{o1, o2, o3}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	assertOverride(hk1, &o1)
+	assertOverride(hk2, &o2)
+	if overrides, err := ss.HostOverrides(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(overrides, []api.HostOverride{o1, o2, o3}) {
+		t.Fatalf("unexpected overrides %+v", overrides)
+	}
+
+	// assert the overrides are returned when searching hosts
+	hosts, err := ss.SearchHosts(context.Background(), "", api.HostFilterModeAll, api.UsabilityFilterModeAll, "", nil, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(hosts) != 2 || hosts[0].Override == nil || hosts[1].Override == nil {
+		t.Fatal("expected overrides to be set", hosts)
+	}
+
+	// replace the first override and remove the second
+	o1.ForceInclude = false
+	if err := ss.UpdateHostOverrides(context.Background(), []api.HostOverride{o1}, []types.PublicKey{hk2}, false); err != nil {
+		t.Fatal(err)
+	}
+	assertOverride(hk1, &o1)
+	assertOverride(hk2, nil)
+
+	// assert invalid overrides are rejected
+	invalid := api.HostOverride{HostKey: hk2, ForceInclude: true, ForceExclude: true}
+	if err := ss.UpdateHostOverrides(context.Background(), []api.HostOverride{invalid}, nil, false); !errors.Is(err, api.ErrInvalidHostOverride) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// clear the overrides
+	if err := ss.UpdateHostOverrides(context.Background(), nil, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	assertOverride(hk1, nil)
+	if overrides, err := ss.HostOverrides(context.Background()); err != nil {
+		t.Fatal(err)
+	} else if len(overrides) != 0 {
+		t.Fatalf("unexpected overrides %+v", overrides)
+	}
+}
+
 func TestAnnouncementMaxAge(t *testing.T) {
 	db := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer db.Close()
@@ -1350,6 +2027,14 @@ func newTestHostCheck() api.HostCheck {
 			GougingErr:  "baz",
 			PruneErr:    "qux",
 			UploadErr:   "quuz",
+			Reasons: []api.GougingReason{
+				{
+					Price:  api.GougingPriceDownload,
+					Value:  types.Siacoins(2),
+					Limit:  types.Siacoins(1),
+					Excess: types.Siacoins(1),
+				},
+			},
 		},
 		Score: api.HostScoreBreakdown{
 			Age:              .1,
@@ -1359,6 +2044,7 @@ func newTestHostCheck() api.HostCheck {
 			Uptime:           .5,
 			Version:          .6,
 			Prices:           .7,
+			SuccessRate:      .8,
 		},
 		Usability: api.HostUsabilityBreakdown{
 			Blocked:               false,
@@ -1372,3 +2058,78 @@ func newTestHostCheck() api.HostCheck {
 		},
 	}
 }
+
+func TestHostCache(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+	ss.hostCache = newHostCache(2, time.Hour)
+
+	// add 3 hosts
+	hks, err := ss.addTestHosts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hk := hks[0]
+
+	// updateNetAddress updates the host's net address without going through
+	// the store, bypassing the cache invalidation
+	updateNetAddress := func(addr string) {
+		t.Helper()
+		if err := ss.db.Model(&dbHost{}).Where("public_key", publicKey(hk)).Update("net_address", addr).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertNetAddress := func(expected string) {
+		t.Helper()
+		if h, err := ss.Host(context.Background(), hk); err != nil {
+			t.Fatal(err)
+		} else if h.NetAddress != expected {
+			t.Fatalf("unexpected net address %v, expected %v", h.NetAddress, expected)
+		}
+	}
+
+	// fetch the host, then update it in the database, repeated reads should
+	// hit the cache
+	h, err := ss.Host(context.Background(), hk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updateNetAddress("foo.bar:9982")
+	assertNetAddress(h.NetAddress)
+	assertNetAddress(h.NetAddress)
+
+	// recording a scan invalidates the entry
+	if err := ss.RecordHostScans(context.Background(), []api.HostScan{{HostKey: hk, Success: true, Timestamp: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	assertNetAddress("foo.bar:9982")
+
+	// updating the blocklist invalidates all entries
+	updateNetAddress("bar.baz:9982")
+	assertNetAddress("foo.bar:9982")
+	if err := ss.UpdateHostBlocklistEntries(context.Background(), []string{"blocked.host"}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	assertNetAddress("bar.baz:9982")
+
+	// fetching the other hosts evicts the first host since the cache only
+	// holds 2 hosts
+	for _, hk := range hks[1:] {
+		if _, err := ss.Host(context.Background(), hk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := ss.hostCache.Get(hk); ok {
+		t.Fatal("expected host to be evicted")
+	} else if len(ss.hostCache.entries) != 2 {
+		t.Fatalf("expected 2 cached hosts, got %v", len(ss.hostCache.entries))
+	}
+
+	// a host that was fetched before the cache was invalidated isn't cached
+	gen := ss.hostCache.Generation()
+	ss.hostCache.Invalidate(hk)
+	ss.hostCache.Set(gen, api.Host{PublicKey: hk})
+	if _, ok := ss.hostCache.Get(hk); ok {
+		t.Fatal("expected outdated host to not be cached")
+	}
+}
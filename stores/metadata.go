@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -29,6 +30,10 @@ const (
 	// increase the batch size.
 	batchDurationThreshold = time.Second
 
+	// objectsListBatchSize is the number of objects we fetch per query when
+	// listing objects with a delimiter.
+	objectsListBatchSize = 1000
+
 	// refreshHealthBatchSize is the number of slabs for which we update the
 	// health per db transaction. 10000 equals roughtly 1.2TiB of slabs at a
 	// 10/30 erasure coding and takes <1s to execute on an SSD in SQLite.
@@ -106,6 +111,7 @@ type (
 		FundAccountSpending currency
 		DeleteSpending      currency
 		ListSpending        currency
+		StorageSpending     currency
 	}
 
 	dbContractSet struct {
@@ -339,9 +345,10 @@ func (c dbArchivedContract) convert() api.ArchivedContract {
 	var revisionNumber uint64
 	_, _ = fmt.Sscan(c.RevisionNumber, &revisionNumber)
 	return api.ArchivedContract{
-		ID:        types.FileContractID(c.FCID),
-		HostKey:   types.PublicKey(c.Host),
-		RenewedTo: types.FileContractID(c.RenewedTo),
+		ID:             types.FileContractID(c.FCID),
+		HostKey:        types.PublicKey(c.Host),
+		RenewedTo:      types.FileContractID(c.RenewedTo),
+		ArchivalReason: c.Reason,
 
 		ProofHeight:    c.ProofHeight,
 		RevisionHeight: c.RevisionHeight,
@@ -358,6 +365,7 @@ func (c dbArchivedContract) convert() api.ArchivedContract {
 			FundAccount: types.Currency(c.FundAccountSpending),
 			Deletions:   types.Currency(c.DeleteSpending),
 			SectorRoots: types.Currency(c.ListSpending),
+			Storage:     types.Currency(c.StorageSpending),
 		},
 	}
 }
@@ -385,6 +393,7 @@ func (c dbContract) convert() api.ContractMetadata {
 			FundAccount: types.Currency(c.FundAccountSpending),
 			Deletions:   types.Currency(c.DeleteSpending),
 			SectorRoots: types.Currency(c.ListSpending),
+			Storage:     types.Currency(c.StorageSpending),
 		},
 		ProofHeight:    c.ProofHeight,
 		RevisionHeight: c.RevisionHeight,
@@ -884,6 +893,25 @@ func (s *SQLStore) AncestorContracts(ctx context.Context, id types.FileContractI
 	return contracts, nil
 }
 
+// ArchivedContracts returns the archived contracts, if a reason is given only
+// contracts archived for that reason are returned.
+func (s *SQLStore) ArchivedContracts(ctx context.Context, reason string) ([]api.ArchivedContract, error) {
+	query := s.db.WithContext(ctx).Model(&dbArchivedContract{})
+	if reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+
+	var archived []dbArchivedContract
+	if err := query.Order("id ASC").Find(&archived).Error; err != nil {
+		return nil, err
+	}
+	contracts := make([]api.ArchivedContract, len(archived))
+	for i, c := range archived {
+		contracts[i] = c.convert()
+	}
+	return contracts, nil
+}
+
 func (s *SQLStore) ArchiveContract(ctx context.Context, id types.FileContractID, reason string) error {
 	return s.ArchiveContracts(ctx, map[types.FileContractID]string{id: reason})
 }
@@ -971,6 +999,101 @@ func (s *SQLStore) ContractSets(ctx context.Context) ([]string, error) {
 	return sets, err
 }
 
+// ContractCounts returns the total number of contracts and the number of
+// contracts in every contract set, empty sets are included.
+func (s *SQLStore) ContractCounts(ctx context.Context) (total uint64, sets map[string]uint64, _ error) {
+	db := s.db.WithContext(ctx)
+
+	var n int64
+	if err := db.Model(&dbContract{}).Count(&n).Error; err != nil {
+		return 0, nil, fmt.Errorf("failed to count contracts: %w", err)
+	}
+
+	var rows []struct {
+		Name      string
+		Contracts uint64
+	}
+	if err := db.Raw(`
+SELECT cs.name, COUNT(csc.db_contract_id) AS contracts
+FROM contract_sets cs
+LEFT JOIN contract_set_contracts csc ON csc.db_contract_set_id = cs.id
+GROUP BY cs.id, cs.name
+`).
+		Scan(&rows).
+		Error; err != nil {
+		return 0, nil, fmt.Errorf("failed to count contracts per set: %w", err)
+	}
+
+	sets = make(map[string]uint64, len(rows))
+	for _, row := range rows {
+		sets[row.Name] = row.Contracts
+	}
+	return uint64(n), sets, nil
+}
+
+// ContractSetStats returns aggregate metrics of the contracts in the given
+// contract set. The average host score is computed using the host checks of
+// the given autopilot, if no autopilot is given it's left at zero. The monthly
+// cost is the cost of storing the contracts' data at the hosts' current
+// storage prices for a month.
+func (s *SQLStore) ContractSetStats(ctx context.Context, set, autopilotID string) (api.ContractSetStats, error) {
+	stats := api.ContractSetStats{Name: set}
+
+	// check whether the contract set exists
+	db := s.db.WithContext(ctx)
+	if err := db.Where("name", set).Take(&dbContractSet{}).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		return api.ContractSetStats{}, api.ErrContractSetNotFound
+	} else if err != nil {
+		return api.ContractSetStats{}, err
+	}
+
+	// fetch the contracts in the set along with their hosts
+	var contracts []dbContract
+	if err := db.
+		Model(&dbContract{}).
+		Joins("INNER JOIN contract_set_contracts csc ON csc.db_contract_id = contracts.id").
+		Joins("INNER JOIN contract_sets cs ON cs.id = csc.db_contract_set_id AND cs.name = ?", set).
+		Preload("Host").
+		Find(&contracts).
+		Error; err != nil {
+		return api.ContractSetStats{}, fmt.Errorf("failed to fetch contracts: %w", err)
+	}
+
+	hosts := make(map[uint]struct{})
+	for _, c := range contracts {
+		hosts[c.HostID] = struct{}{}
+		stats.TotalStorage += c.Size
+		stats.MonthlyCost = stats.MonthlyCost.Add(c.Host.Settings.StoragePrice.Mul64(c.Size).Mul64(30 * api.BlocksPerDay))
+	}
+	stats.Contracts = len(contracts)
+	stats.Hosts = len(hosts)
+	if autopilotID == "" || len(hosts) == 0 {
+		return stats, nil
+	}
+
+	// fetch the host checks to compute the average score
+	hostIDs := make([]uint, 0, len(hosts))
+	for hostID := range hosts {
+		hostIDs = append(hostIDs, hostID)
+	}
+	var checks []dbHostCheck
+	if err := db.
+		Model(&dbHostCheck{}).
+		Joins("INNER JOIN autopilots a ON a.id = host_checks.db_autopilot_id AND a.identifier = ?", autopilotID).
+		Where("host_checks.db_host_id IN (?)", hostIDs).
+		Find(&checks).
+		Error; err != nil {
+		return api.ContractSetStats{}, fmt.Errorf("failed to fetch host checks: %w", err)
+	}
+	for _, check := range checks {
+		stats.AvgHostScore += check.convert().Score.Score()
+	}
+	if len(checks) > 0 {
+		stats.AvgHostScore /= float64(len(checks))
+	}
+	return stats, nil
+}
+
 func (s *SQLStore) ContractSizes(ctx context.Context) (map[types.FileContractID]api.ContractSize, error) {
 	type size struct {
 		Fcid     fileContractID `json:"fcid"`
@@ -1047,17 +1170,62 @@ SELECT MAX(c.size) as contract_size, COUNT(cs.db_sector_id) * ? as sector_size F
 	}, nil
 }
 
-func (s *SQLStore) SetContractSet(ctx context.Context, name string, contractIds []types.FileContractID) error {
+// ExpiringContracts returns the contracts that still store sectors referenced
+// by slabs and whose proof window starts within the given number of blocks of
+// the given height. Contracts that don't store any sectors are omitted since
+// their expiry doesn't put any data at risk.
+func (s *SQLStore) ExpiringContracts(ctx context.Context, height, window uint64) ([]api.ExpiringContract, error) {
+	var rows []struct {
+		Fcid        fileContractID
+		PublicKey   publicKey
+		WindowStart uint64
+		Sectors     uint64
+	}
+	if err := s.db.
+		WithContext(ctx).
+		Raw(`
+SELECT c.fcid, h.public_key, c.window_start, COUNT(cs.db_sector_id) as sectors FROM contracts c
+INNER JOIN hosts h ON h.id = c.host_id
+INNER JOIN contract_sectors cs ON cs.db_contract_id = c.id
+WHERE c.window_start <= ?
+GROUP BY c.id, c.fcid, h.public_key, c.window_start
+ORDER BY c.window_start ASC
+`, height+window).
+		Scan(&rows).
+		Error; err != nil {
+		return nil, err
+	}
+
+	contracts := make([]api.ExpiringContract, len(rows))
+	for i, row := range rows {
+		var remaining uint64
+		if row.WindowStart > height {
+			remaining = row.WindowStart - height
+		}
+		contracts[i] = api.ExpiringContract{
+			ID:              types.FileContractID(row.Fcid),
+			HostKey:         types.PublicKey(row.PublicKey),
+			WindowStart:     row.WindowStart,
+			BlocksRemaining: remaining,
+			Sectors:         row.Sectors,
+		}
+	}
+	return contracts, nil
+}
+
+// SetContractSet replaces the contracts in the set with given name in a single
+// transaction, it returns the ids of the contracts that were added to and
+// removed from the set.
+func (s *SQLStore) SetContractSet(ctx context.Context, name string, contractIds []types.FileContractID) (resp api.ContractSetUpdateResponse, err error) {
 	var wantedIds []fileContractID
-	wanted := make(map[fileContractID]struct{})
 	for _, fcid := range contractIds {
 		wantedIds = append(wantedIds, fileContractID(fcid))
-		wanted[fileContractID(fcid)] = struct{}{}
 	}
 
-	var diff []fileContractID
 	var nContractsAfter int
-	err := s.retryTransaction(ctx, func(tx *gorm.DB) error {
+	err = s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		resp = api.ContractSetUpdateResponse{}
+
 		// fetch contract set
 		var cs dbContractSet
 		err := tx.
@@ -1082,16 +1250,22 @@ func (s *SQLStore) SetContractSet(ctx context.Context, name string, contractIds
 		nContractsAfter = len(dbContracts)
 
 		// add removals to the diff
+		wanted := make(map[fileContractID]struct{})
+		for _, contract := range dbContracts {
+			wanted[contract.FCID] = struct{}{}
+		}
 		for _, contract := range cs.Contracts {
 			if _, ok := wanted[contract.FCID]; !ok {
-				diff = append(diff, contract.FCID)
+				resp.Removed = append(resp.Removed, types.FileContractID(contract.FCID))
 			}
 			delete(wanted, contract.FCID)
 		}
 
 		// add additions to the diff
-		for fcid := range wanted {
-			diff = append(diff, fcid)
+		for _, contract := range dbContracts {
+			if _, ok := wanted[contract.FCID]; ok {
+				resp.Added = append(resp.Added, types.FileContractID(contract.FCID))
+			}
 		}
 
 		// update the association
@@ -1102,13 +1276,17 @@ func (s *SQLStore) SetContractSet(ctx context.Context, name string, contractIds
 		return nil
 	})
 	if err != nil {
-		return fmt.Errorf("failed to set contract set: %w", err)
+		return api.ContractSetUpdateResponse{}, fmt.Errorf("failed to set contract set: %w", err)
 	}
 
 	// Invalidate slab health.
+	var diff []fileContractID
+	for _, fcid := range append(append([]types.FileContractID{}, resp.Added...), resp.Removed...) {
+		diff = append(diff, fileContractID(fcid))
+	}
 	err = s.invalidateSlabHealthByFCID(ctx, diff)
 	if err != nil {
-		return fmt.Errorf("failed to invalidate slab health: %w", err)
+		return api.ContractSetUpdateResponse{}, fmt.Errorf("failed to invalidate slab health: %w", err)
 	}
 
 	// Record the update.
@@ -1118,9 +1296,9 @@ func (s *SQLStore) SetContractSet(ctx context.Context, name string, contractIds
 		Timestamp: api.TimeNow(),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to record contract set metric: %w", err)
+		return api.ContractSetUpdateResponse{}, fmt.Errorf("failed to record contract set metric: %w", err)
 	}
-	return nil
+	return resp, nil
 }
 
 func (s *SQLStore) RemoveContractSet(ctx context.Context, name string) error {
@@ -1444,6 +1622,7 @@ func (s *SQLStore) RecordContractSpending(ctx context.Context, records []api.Con
 				FundAccountSpending: types.Currency(contract.FundAccountSpending).Add(newSpending.FundAccount),
 				DeleteSpending:      types.Currency(contract.DeleteSpending).Add(newSpending.Deletions),
 				ListSpending:        types.Currency(contract.ListSpending).Add(newSpending.SectorRoots),
+				StorageSpending:     types.Currency(contract.StorageSpending).Add(newSpending.Storage),
 			}
 			metrics = append(metrics, m)
 
@@ -1463,6 +1642,9 @@ func (s *SQLStore) RecordContractSpending(ctx context.Context, records []api.Con
 			if !newSpending.SectorRoots.IsZero() {
 				updates["list_spending"] = currency(m.ListSpending)
 			}
+			if !newSpending.Storage.IsZero() {
+				updates["storage_spending"] = currency(m.StorageSpending)
+			}
 			updates["revision_number"] = latestValues[fcid].revision
 			updates["size"] = latestValues[fcid].size
 			return tx.Model(&contract).Updates(updates).Error
@@ -1693,6 +1875,7 @@ func (s *SQLStore) CopyObject(ctx context.Context, srcBucket, dstBucket, srcPath
 }
 
 func (s *SQLStore) DeleteHostSector(ctx context.Context, hk types.PublicKey, root types.Hash256) (int, error) {
+	defer s.hostCache.Invalidate(hk) // lost sectors
 	var deletedSectors int
 	err := s.retryTransaction(ctx, func(tx *gorm.DB) error {
 		// Fetch contract_sectors to delete.
@@ -1832,7 +2015,36 @@ func makeDirsForPath(tx *gorm.DB, path string) (uint, error) {
 	return dirID, nil
 }
 
+// UpdateObjectMetadata replaces the user-defined metadata of the object at the
+// given path.
+func (s *SQLStore) UpdateObjectMetadata(ctx context.Context, bucket, path string, metadata api.ObjectUserMetadata) error {
+	return s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		var obj dbObject
+		err := tx.Where("objects.object_id = ? AND DBBucket.name = ?", path, bucket).
+			Joins("DBBucket").
+			Take(&obj).
+			Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.ErrObjectNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to fetch object: %w", err)
+		}
+		return s.updateUserMetadata(tx, obj.ID, metadata)
+	})
+}
+
 func (s *SQLStore) UpdateObject(ctx context.Context, bucket, path, contractSet, eTag, mimeType string, metadata api.ObjectUserMetadata, o object.Object) error {
+	return s.updateObject(ctx, bucket, path, contractSet, nil, eTag, mimeType, metadata, o)
+}
+
+// UpdateObjectIfMatch updates the object like UpdateObject but only if it
+// exists and its current ETag matches the expected ETag, otherwise
+// api.ErrObjectNotFound or api.ErrObjectModified is returned.
+func (s *SQLStore) UpdateObjectIfMatch(ctx context.Context, bucket, path, contractSet, expectedETag, eTag, mimeType string, metadata api.ObjectUserMetadata, o object.Object) error {
+	return s.updateObject(ctx, bucket, path, contractSet, &expectedETag, eTag, mimeType, metadata, o)
+}
+
+func (s *SQLStore) updateObject(ctx context.Context, bucket, path, contractSet string, expectedETag *string, eTag, mimeType string, metadata api.ObjectUserMetadata, o object.Object) error {
 	// Sanity check input.
 	for _, s := range o.Slabs {
 		for i, shard := range s.Shards {
@@ -1848,6 +2060,23 @@ func (s *SQLStore) UpdateObject(ctx context.Context, bucket, path, contractSet,
 
 	// UpdateObject is ACID.
 	return s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		// Check the object wasn't modified in the meantime.
+		if expectedETag != nil {
+			var objs []dbObject
+			if err := tx.
+				Where("object_id = ? AND ?", path, sqlWhereBucket("objects", bucket)).
+				Select("etag").
+				Limit(1).
+				Find(&objs).
+				Error; err != nil {
+				return fmt.Errorf("failed to fetch object etag: %w", err)
+			} else if len(objs) == 0 {
+				return api.ErrObjectNotFound
+			} else if objs[0].Etag != *expectedETag {
+				return fmt.Errorf("%w: expected etag %v, got %v", api.ErrObjectModified, *expectedETag, objs[0].Etag)
+			}
+		}
+
 		// Try to delete. We want to get rid of the object and its slices if it
 		// exists.
 		//
@@ -1971,6 +2200,72 @@ func (s *SQLStore) Slab(ctx context.Context, key object.EncryptionKey) (object.S
 	return slab.convert()
 }
 
+// SlabLocations returns the locations of the shards of the slab with the given
+// key. A location is usable if its host isn't blocked and it's usable according
+// to the host checks of the given autopilot, or all autopilots if no autopilot
+// is given.
+func (s *SQLStore) SlabLocations(ctx context.Context, key object.EncryptionKey, autopilotID string) ([]api.SlabLocation, error) {
+	k, err := key.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var slab dbSlab
+	err = s.db.
+		WithContext(ctx).
+		Where(&dbSlab{Key: k}).
+		Preload("Shards.Contracts.Host.Allowlist").
+		Preload("Shards.Contracts.Host.Blocklist").
+		Preload("Shards.Contracts.Host.Checks.DBAutopilot").
+		Take(&slab).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, api.ErrSlabNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	// sort the shards by their index
+	sort.Slice(slab.Shards, func(i, j int) bool {
+		return slab.Shards[i].SlabIndex < slab.Shards[j].SlabIndex
+	})
+
+	// NOTE: the slab index of a sector starts at 1
+	locations := make([]api.SlabLocation, 0, len(slab.Shards))
+	for _, shard := range slab.Shards {
+		root := *(*types.Hash256)(shard.Root)
+		if len(shard.Contracts) == 0 {
+			locations = append(locations, api.SlabLocation{ShardIndex: shard.SlabIndex - 1, Root: root})
+			continue
+		}
+		for _, c := range shard.Contracts {
+			locations = append(locations, api.SlabLocation{
+				ShardIndex: shard.SlabIndex - 1,
+				Root:       root,
+				ContractID: types.FileContractID(c.FCID),
+				HostKey:    types.PublicKey(c.Host.PublicKey),
+				NetAddress: c.Host.NetAddress,
+				Usable:     !s.isBlocked(c.Host) && isUsable(c.Host.Checks, autopilotID),
+			})
+		}
+	}
+	return locations, nil
+}
+
+// isUsable returns true if the host checks of the given autopilot consider the
+// host usable, if no autopilot is given all autopilots have to consider the
+// host usable. A host without checks is never usable.
+func isUsable(checks []dbHostCheck, autopilotID string) (usable bool) {
+	for _, check := range checks {
+		if autopilotID != "" && check.DBAutopilot.Identifier != autopilotID {
+			continue
+		} else if !check.convert().Usability.IsUsable() {
+			return false
+		}
+		usable = true
+	}
+	return
+}
+
 func (ss *SQLStore) UpdateSlab(ctx context.Context, s object.Slab, contractSet string) error {
 	// sanity check the shards don't contain an empty root
 	for _, s := range s.Shards {
@@ -2043,58 +2338,139 @@ func (ss *SQLStore) UpdateSlab(ctx context.Context, s object.Slab, contractSet s
 			}
 		}
 
-		// prepare sectors to update
-		sectors := make([]dbSector, len(s.Shards))
-		for i := range s.Shards {
-			sectors[i] = dbSector{
-				DBSlabID:   slab.ID,
-				SlabIndex:  i + 1,
-				LatestHost: publicKey(s.Shards[i].LatestHost),
-				Root:       s.Shards[i].Root[:],
-			}
-		}
+		return upsertSlabSectors(tx, slab.ID, s.Shards, contracts)
+	})
+}
 
-		// ensure the sectors exists
-		sectorIDs, err := upsertSectors(tx, sectors)
-		if err != nil {
-			return fmt.Errorf("failed to create sector: %w", err)
+// RekeySlab replaces the key and shards of the slab with given key. The shards
+// are expected to contain the slab's data encrypted with the new key, the old
+// sectors are removed from the slab so they become prunable.
+func (ss *SQLStore) RekeySlab(ctx context.Context, oldKey object.EncryptionKey, s object.Slab, contractSet string) error {
+	// sanity check the new slab
+	if s.Key == oldKey {
+		return errors.New("new slab key must differ from the old one")
+	}
+	for i, shard := range s.Shards {
+		if shard.Root == (types.Hash256{}) {
+			return errors.New("shard root can never be the empty root")
+		} else if len(shard.Contracts) == 0 {
+			return fmt.Errorf("missing hosts for slab %d", i)
 		}
+	}
 
-		// build contract <-> sector links
-		var contractSectors []dbContractSector
-		for i, shard := range s.Shards {
-			sectorID := sectorIDs[i]
+	// extract the slab keys
+	key, err := oldKey.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	newKey, err := s.Key.MarshalBinary()
+	if err != nil {
+		return err
+	}
 
-			// ensure the associations are updated
-			for _, fcids := range shard.Contracts {
-				for _, fcid := range fcids {
-					if _, ok := contracts[fcid]; ok {
-						contractSectors = append(contractSectors, dbContractSector{
-							DBSectorID:   sectorID,
-							DBContractID: contracts[fcid].ID,
-						})
-					}
-				}
-			}
+	// collect all used contracts
+	usedContracts := s.Contracts()
+
+	return ss.retryTransaction(ctx, func(tx *gorm.DB) error {
+		// find existing slab
+		var slab dbSlab
+		if err := tx.
+			Where(&dbSlab{Key: key}).
+			Take(&slab).
+			Error; errors.Is(err, gorm.ErrRecordNotFound) {
+			return api.ErrSlabNotFound
+		} else if err != nil {
+			return err
 		}
 
-		// if there are no associations we are done
-		if len(contractSectors) == 0 {
-			return nil
+		// make sure the layout of the slab doesn't change
+		if s.MinShards != slab.MinShards {
+			return fmt.Errorf("min shards can't change from %v to %v", slab.MinShards, s.MinShards)
+		} else if len(s.Shards) != int(slab.TotalShards) {
+			return fmt.Errorf("%w: expected %v shards but got %v", errInvalidNumberOfShards, slab.TotalShards, len(s.Shards))
 		}
 
-		// create associations
-		if err := tx.Table("contract_sectors").
-			Clauses(clause.OnConflict{
-				DoNothing: true,
+		// update the key
+		if err := tx.Model(&dbSlab{}).
+			Where("id", slab.ID).
+			Updates(map[string]interface{}{
+				"key":                newKey,
+				"db_contract_set_id": gorm.Expr("(SELECT id FROM contract_sets WHERE name = ?)", contractSet),
+				"health_valid_until": time.Now().Unix(),
+				"health":             1,
 			}).
-			Create(&contractSectors).Error; err != nil {
+			Error; err != nil {
+			return fmt.Errorf("failed to update slab key: %w", err)
+		}
+
+		// remove the sectors encrypted with the old key
+		if err := tx.
+			Where("db_slab_id", slab.ID).
+			Delete(&dbSector{}).
+			Error; err != nil {
+			return fmt.Errorf("failed to delete old sectors: %w", err)
+		}
+
+		// find all used contracts
+		contracts, err := fetchUsedContracts(tx, usedContracts)
+		if err != nil {
 			return err
 		}
-		return nil
+		return upsertSlabSectors(tx, slab.ID, s.Shards, contracts)
 	})
 }
 
+// upsertSlabSectors upserts the given shards as the sectors of the slab with
+// given id and links them to the contracts they are stored on.
+func upsertSlabSectors(tx *gorm.DB, slabID uint, shards []object.Sector, contracts map[types.FileContractID]dbContract) error {
+	// prepare sectors to update
+	sectors := make([]dbSector, len(shards))
+	for i := range shards {
+		sectors[i] = dbSector{
+			DBSlabID:   slabID,
+			SlabIndex:  i + 1,
+			LatestHost: publicKey(shards[i].LatestHost),
+			Root:       shards[i].Root[:],
+		}
+	}
+
+	// ensure the sectors exists
+	sectorIDs, err := upsertSectors(tx, sectors)
+	if err != nil {
+		return fmt.Errorf("failed to create sector: %w", err)
+	}
+
+	// build contract <-> sector links
+	var contractSectors []dbContractSector
+	for i, shard := range shards {
+		sectorID := sectorIDs[i]
+
+		// ensure the associations are updated
+		for _, fcids := range shard.Contracts {
+			for _, fcid := range fcids {
+				if _, ok := contracts[fcid]; ok {
+					contractSectors = append(contractSectors, dbContractSector{
+						DBSectorID:   sectorID,
+						DBContractID: contracts[fcid].ID,
+					})
+				}
+			}
+		}
+	}
+
+	// if there are no associations we are done
+	if len(contractSectors) == 0 {
+		return nil
+	}
+
+	// create associations
+	return tx.Table("contract_sectors").
+		Clauses(clause.OnConflict{
+			DoNothing: true,
+		}).
+		Create(&contractSectors).Error
+}
+
 func (s *SQLStore) RefreshHealth(ctx context.Context) error {
 	var nSlabs int64
 	if err := s.db.Model(&dbSlab{}).Count(&nSlabs).Error; err != nil {
@@ -2185,6 +2561,7 @@ func (s *SQLStore) UnhealthySlabs(ctx context.Context, healthCutoff float64, set
 			Joins("INNER JOIN contract_sets cs ON slabs.db_contract_set_id = cs.id").
 			Model(&dbSlab{}).
 			Where("health <= ? AND cs.name = ?", healthCutoff, set).
+			Where("EXISTS (SELECT 1 FROM slices sli WHERE sli.db_slab_id = slabs.id)").
 			Order("health ASC").
 			Limit(limit).
 			Find(&rows).
@@ -2207,6 +2584,130 @@ func (s *SQLStore) UnhealthySlabs(ctx context.Context, healthCutoff float64, set
 	return slabs, nil
 }
 
+// SlabsHealth returns up to 'limit' slabs that don't reach full redundancy on
+// usable contracts, a contract is considered usable if it's not archived and
+// its host isn't blocked. The least healthy slabs are returned first.
+func (s *SQLStore) SlabsHealth(ctx context.Context, limit int) ([]api.SlabHealth, error) {
+	var rows []struct {
+		Key          []byte
+		MinShards    uint8
+		TotalShards  uint8
+		UsableShards int
+	}
+
+	if err := s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		allowed := tx.Model(&dbHost{}).
+			Select("hosts.id").
+			Scopes(hostFilter(api.HostFilterModeAllowed, s.hasAllowlist(), s.hasBlocklist()))
+		query := `
+SELECT h.key, h.min_shards, h.total_shards, h.usable_shards
+FROM (
+	SELECT slabs.id, slabs.key, slabs.min_shards, slabs.total_shards, u.usable_shards, CASE WHEN (slabs.min_shards = slabs.total_shards)
+	THEN
+		CASE WHEN (u.usable_shards < slabs.min_shards)
+		THEN -1
+		ELSE 1
+		END
+	ELSE (CAST(u.usable_shards AS FLOAT) - CAST(slabs.min_shards AS FLOAT)) / CAST(slabs.total_shards - slabs.min_shards AS FLOAT)
+	END AS health
+	FROM slabs
+	INNER JOIN (
+		SELECT s.db_slab_id, COUNT(DISTINCT(CASE WHEN c.id IS NULL THEN NULL ELSE s.id END)) AS usable_shards
+		FROM sectors s
+		LEFT JOIN contract_sectors se ON s.id = se.db_sector_id
+		LEFT JOIN contracts c ON se.db_contract_id = c.id AND c.host_id IN (?)
+		GROUP BY s.db_slab_id
+	) u ON u.db_slab_id = slabs.id
+	WHERE u.usable_shards < slabs.total_shards
+) h
+ORDER BY h.health ASC, h.usable_shards ASC, h.id ASC`
+		args := []interface{}{allowed}
+		if limit >= 0 {
+			query += "\nLIMIT ?"
+			args = append(args, limit)
+		}
+		return tx.Raw(query, args...).
+			Scan(&rows).
+			Error
+	}); err != nil {
+		return nil, err
+	}
+
+	slabs := make([]api.SlabHealth, len(rows))
+	for i, row := range rows {
+		var key object.EncryptionKey
+		if err := key.UnmarshalBinary(row.Key); err != nil {
+			return nil, err
+		}
+		slabs[i] = api.SlabHealth{
+			Key:          key,
+			Health:       slabHealth(row.UsableShards, row.MinShards, row.TotalShards),
+			MinShards:    row.MinShards,
+			TotalShards:  row.TotalShards,
+			UsableShards: row.UsableShards,
+		}
+	}
+	return slabs, nil
+}
+
+// SlabsForEvacuation returns up to 'limit' slabs that have sectors stored on
+// any of the given hosts. These slabs need to be migrated before the hosts can
+// be dropped without losing redundancy.
+func (s *SQLStore) SlabsForEvacuation(ctx context.Context, hostKeys []types.PublicKey, limit int) ([]api.UnhealthySlab, error) {
+	if len(hostKeys) == 0 {
+		return nil, nil
+	} else if limit <= -1 {
+		limit = math.MaxInt
+	}
+
+	hks := make([]publicKey, len(hostKeys))
+	for i, hk := range hostKeys {
+		hks[i] = publicKey(hk)
+	}
+
+	var rows []struct {
+		Key    []byte
+		Health float64
+	}
+
+	if err := s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		return tx.Select("slabs.key, slabs.health").
+			Model(&dbSlab{}).
+			Where("EXISTS (SELECT 1 FROM sectors sec WHERE sec.db_slab_id = slabs.id AND sec.latest_host IN (?))", hks).
+			Order("slabs.health ASC").
+			Limit(limit).
+			Find(&rows).
+			Error
+	}); err != nil {
+		return nil, err
+	}
+
+	slabs := make([]api.UnhealthySlab, len(rows))
+	for i, row := range rows {
+		var key object.EncryptionKey
+		if err := key.UnmarshalBinary(row.Key); err != nil {
+			return nil, err
+		}
+		slabs[i] = api.UnhealthySlab{
+			Key:    key,
+			Health: row.Health,
+		}
+	}
+	return slabs, nil
+}
+
+// slabHealth mirrors the health computation in RefreshHealth, a slab with
+// fewer than 'minShards' usable shards has a negative health.
+func slabHealth(usableShards int, minShards, totalShards uint8) float64 {
+	if minShards == totalShards {
+		if usableShards < int(minShards) {
+			return -1
+		}
+		return 1
+	}
+	return float64(usableShards-int(minShards)) / float64(totalShards-minShards)
+}
+
 func (s *SQLStore) createUserMetadata(tx *gorm.DB, objID uint, metadata api.ObjectUserMetadata) error {
 	entities := make([]*dbObjectUserMetadata, 0, len(metadata))
 	for k, v := range metadata {
@@ -2764,6 +3265,7 @@ func newContract(hostID uint, fcid, renewedFrom types.FileContractID, contractPr
 			FundAccountSpending: zeroCurrency,
 			DeleteSpending:      zeroCurrency,
 			ListSpending:        zeroCurrency,
+			StorageSpending:     zeroCurrency,
 		},
 	}
 }
@@ -2814,9 +3316,13 @@ func archiveContracts(tx *gorm.DB, contracts []dbContract, toArchive map[types.F
 		}
 
 		// create a copy in the archive
+		reason := toArchive[types.FileContractID(contract.FCID)]
+		if reason == "" {
+			reason = api.ContractArchivalReasonUnknown
+		}
 		if err := tx.Create(&dbArchivedContract{
 			Host:   publicKey(contract.Host.PublicKey),
-			Reason: toArchive[types.FileContractID(contract.FCID)],
+			Reason: reason,
 
 			ContractCommon: contract.ContractCommon,
 		}).Error; err != nil {
@@ -2875,6 +3381,29 @@ func (s *SQLStore) pruneSlabsLoop() {
 	}
 }
 
+func (s *SQLStore) sealSlabBuffersLoop(maxAge time.Duration) {
+	// check twice per max age to make sure buffers are sealed in time
+	t := time.NewTicker(maxAge / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-s.shutdownCtx.Done():
+			return
+		}
+
+		if sealed := s.slabBufferMgr.SealStaleBuffers(maxAge); sealed > 0 {
+			s.logger.Debugf("sealed %d stale slab buffers", sealed)
+		}
+	}
+}
+
+// pruneSlabs deletes all slabs that are no longer referenced by a slice. Slabs
+// are shared between objects, e.g. when copying an object or when packing
+// uploads, so rather than keeping track of a reference count we count the
+// referencing slices, which is cheap since the slab id of a slice is indexed.
+// A slab's sectors are deleted together with the slab.
 func pruneSlabs(tx *gorm.DB) error {
 	return tx.Exec(`
 DELETE
@@ -3055,10 +3584,18 @@ func sqlWhereBucket(objTable string, bucket string) clause.Expr {
 	return gorm.Expr(fmt.Sprintf("%s.db_bucket_id = (SELECT id FROM buckets WHERE buckets.name = ?)", objTable), bucket)
 }
 
-// TODO: we can use ObjectEntries instead of ListObject if we want to use '/' as
-// a delimiter for now (see backend.go) but it would be interesting to have
-// arbitrary 'delim' support in ListObjects.
-func (s *SQLStore) ListObjects(ctx context.Context, bucket, prefix, sortBy, sortDir, marker string, limit int) (api.ObjectsListResponse, error) {
+// ListObjects lists the objects in a bucket that start with the given prefix.
+// If a delimiter is given, objects that share the part of their path up to the
+// next occurrence of the delimiter are collapsed into a common prefix.
+func (s *SQLStore) ListObjects(ctx context.Context, bucket, prefix, delimiter, sortBy, sortDir, marker string, limit int) (api.ObjectsListResponse, error) {
+	// collapse objects into common prefixes if a delimiter is given
+	if delimiter != "" {
+		if (sortBy != "" && sortBy != api.ObjectSortByName) || strings.EqualFold(sortDir, api.ObjectSortDirDesc) {
+			return api.ObjectsListResponse{}, fmt.Errorf("%w: listing objects with a delimiter requires sorting by name in ascending order", api.ErrInvalidObjectSortParameters)
+		}
+		return s.listObjectsWithDelimiter(ctx, bucket, prefix, delimiter, marker, limit)
+	}
+
 	// fetch one more to see if there are more entries
 	if limit <= -1 {
 		limit = math.MaxInt
@@ -3116,6 +3653,96 @@ func (s *SQLStore) ListObjects(ctx context.Context, bucket, prefix, sortBy, sort
 	}, nil
 }
 
+// listObjectsWithDelimiter lists the objects in a bucket that start with the
+// given prefix, objects that contain the delimiter after the prefix are
+// collapsed into a single common prefix. Both objects and common prefixes count
+// towards the limit and can be used as marker.
+func (s *SQLStore) listObjectsWithDelimiter(ctx context.Context, bucket, prefix, delimiter, marker string, limit int) (api.ObjectsListResponse, error) {
+	if limit <= -1 {
+		limit = math.MaxInt
+	}
+
+	// skipMarker returns a marker that skips all objects that share the given
+	// common prefix
+	skipMarker := func(cp string) string {
+		return cp + string(utf8.MaxRune)
+	}
+
+	// if the marker is a common prefix, skip all objects that share it
+	if cp, ok := commonPrefix(marker, prefix, delimiter); ok && cp == marker {
+		marker = skipMarker(cp)
+	}
+
+	var resp api.ObjectsListResponse
+	var lastPrefix string
+	for {
+		var rows []rawObjectMetadata
+		if err := s.db.
+			WithContext(ctx).
+			Select("o.object_id as ObjectName, o.size as Size, o.health as Health, o.mime_type as MimeType, o.created_at as ModTime, o.etag as ETag").
+			Model(&dbObject{}).
+			Table("objects o").
+			Where("o.db_bucket_id = (SELECT id FROM buckets b WHERE b.name = ?)", bucket).
+			Where("?", buildPrefixExpr(prefix)).
+			Where("o.object_id > ?", marker).
+			Order("o.object_id ASC").
+			Limit(objectsListBatchSize).
+			Scan(&rows).Error; err != nil {
+			return api.ObjectsListResponse{}, err
+		} else if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			// skip objects that were collapsed already
+			if lastPrefix != "" && strings.HasPrefix(row.ObjectName, lastPrefix) {
+				continue
+			}
+
+			// check whether we have more entries than requested
+			if len(resp.Objects)+len(resp.CommonPrefixes) == limit {
+				resp.HasMore = true
+				return resp, nil
+			}
+
+			if cp, ok := commonPrefix(row.ObjectName, prefix, delimiter); ok {
+				resp.CommonPrefixes = append(resp.CommonPrefixes, cp)
+				resp.NextMarker = cp
+				lastPrefix = cp
+			} else {
+				resp.Objects = append(resp.Objects, row.convert())
+				resp.NextMarker = row.ObjectName
+			}
+		}
+
+		// continue after the last row, skipping the last common prefix
+		marker = rows[len(rows)-1].ObjectName
+		if lastPrefix != "" && strings.HasPrefix(marker, lastPrefix) {
+			marker = skipMarker(lastPrefix)
+		}
+		if len(rows) < objectsListBatchSize {
+			break
+		}
+	}
+
+	// the marker is only set if there are more entries
+	resp.NextMarker = ""
+	return resp, nil
+}
+
+// commonPrefix returns the common prefix the given key is collapsed into when
+// listing objects with the given prefix and delimiter.
+func commonPrefix(key, prefix, delimiter string) (string, bool) {
+	if !strings.HasPrefix(key, prefix) {
+		return "", false
+	}
+	idx := strings.Index(key[len(prefix):], delimiter)
+	if idx == -1 {
+		return "", false
+	}
+	return key[:len(prefix)+idx+len(delimiter)], true
+}
+
 func (ss *SQLStore) processConsensusChangeContracts(cc modules.ConsensusChange) {
 	height := uint64(cc.InitialHeight())
 	for _, sb := range cc.RevertedBlocks {
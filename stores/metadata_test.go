@@ -263,6 +263,72 @@ func TestObjectMetadata(t *testing.T) {
 	}
 }
 
+// TestUpdateObjectMetadata tests replacing the user metadata of an existing
+// object.
+func TestUpdateObjectMetadata(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add an object
+	if _, err := ss.addTestObject(t.Name(), object.Object{Key: object.GenerateEncryptionKey()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// replace its metadata
+	want := api.ObjectUserMetadata{"Baz": "qux"}
+	if err := ss.UpdateObjectMetadata(context.Background(), api.DefaultBucketName, t.Name(), want); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the old metadata is gone
+	obj, err := ss.Object(context.Background(), api.DefaultBucketName, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(obj.Metadata, want) {
+		t.Fatal("meta mismatch", cmp.Diff(obj.Metadata, want))
+	}
+
+	// assert updating a missing object fails
+	err = ss.UpdateObjectMetadata(context.Background(), api.DefaultBucketName, "missing", want)
+	if !errors.Is(err, api.ErrObjectNotFound) {
+		t.Fatal("expected ErrObjectNotFound", err)
+	}
+}
+
+func TestUpdateObjectIfMatch(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add an object
+	ctx := context.Background()
+	obj := newTestObject(1)
+	if err := ss.UpdateObject(ctx, api.DefaultBucketName, t.Name(), testContractSet, testETag, testMimeType, testMetadata, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert updating a missing object fails
+	err := ss.UpdateObjectIfMatch(ctx, api.DefaultBucketName, "missing", testContractSet, testETag, "etag2", testMimeType, testMetadata, obj)
+	if !errors.Is(err, api.ErrObjectNotFound) {
+		t.Fatal("expected ErrObjectNotFound", err)
+	}
+
+	// assert updating the object with the wrong etag fails
+	err = ss.UpdateObjectIfMatch(ctx, api.DefaultBucketName, t.Name(), testContractSet, "wrong", "etag2", testMimeType, testMetadata, obj)
+	if !errors.Is(err, api.ErrObjectModified) {
+		t.Fatal("expected ErrObjectModified", err)
+	}
+
+	// assert updating the object with the right etag succeeds
+	err = ss.UpdateObjectIfMatch(ctx, api.DefaultBucketName, t.Name(), testContractSet, testETag, "etag2", testMimeType, testMetadata, obj)
+	if err != nil {
+		t.Fatal(err)
+	} else if o, err := ss.Object(ctx, api.DefaultBucketName, t.Name()); err != nil {
+		t.Fatal(err)
+	} else if o.ETag != "etag2" {
+		t.Fatal("unexpected etag", o.ETag)
+	}
+}
+
 // TestSQLContractStore tests SQLContractStore functionality.
 func TestSQLContractStore(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
@@ -395,7 +461,7 @@ func TestSQLContractStore(t *testing.T) {
 	}
 
 	// Add a contract set with our contract and assert we can fetch it using the set name
-	if err := ss.SetContractSet(ctx, "foo", []types.FileContractID{contracts[0].ID}); err != nil {
+	if _, err := ss.SetContractSet(ctx, "foo", []types.FileContractID{contracts[0].ID}); err != nil {
 		t.Fatal(err)
 	}
 	if contracts, err := ss.Contracts(ctx, api.ContractsOpts{ContractSet: "foo"}); err != nil {
@@ -408,7 +474,7 @@ func TestSQLContractStore(t *testing.T) {
 	}
 
 	// Add another contract set.
-	if err := ss.SetContractSet(ctx, "foo2", []types.FileContractID{contracts[0].ID}); err != nil {
+	if _, err := ss.SetContractSet(ctx, "foo2", []types.FileContractID{contracts[0].ID}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -638,7 +704,7 @@ func TestRenewedContract(t *testing.T) {
 	}
 
 	// create a contract set with both contracts.
-	if err := ss.SetContractSet(context.Background(), "test", []types.FileContractID{fcid1, fcid2}); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), "test", []types.FileContractID{fcid1, fcid2}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -862,20 +928,77 @@ func TestAncestorsContracts(t *testing.T) {
 	}
 	for i := 0; i < len(contracts)-1; i++ {
 		if !reflect.DeepEqual(contracts[i], api.ArchivedContract{
-			ID:          fcids[len(fcids)-2-i],
-			HostKey:     hk,
-			RenewedTo:   fcids[len(fcids)-1-i],
-			StartHeight: 2,
-			Size:        4096,
-			State:       api.ContractStatePending,
-			WindowStart: 400,
-			WindowEnd:   500,
+			ID:             fcids[len(fcids)-2-i],
+			HostKey:        hk,
+			RenewedTo:      fcids[len(fcids)-1-i],
+			ArchivalReason: api.ContractArchivalReasonRenewed,
+			StartHeight:    2,
+			Size:           4096,
+			State:          api.ContractStatePending,
+			WindowStart:    400,
+			WindowEnd:      500,
 		}) {
 			t.Fatal("wrong contract", i, contracts[i])
 		}
 	}
 }
 
+func TestArchivedContracts(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add 4 hosts and contracts
+	hks, err := ss.addTestHosts(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// archive them with different reasons
+	if err := ss.ArchiveContracts(context.Background(), map[types.FileContractID]string{
+		fcids[0]: api.ContractArchivalReasonHostPruned,
+		fcids[1]: api.ContractArchivalReasonRemoved,
+		fcids[2]: api.ContractArchivalReasonRemoved,
+		fcids[3]: "",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert all contracts are returned without a filter
+	archived, err := ss.ArchivedContracts(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(archived) != 4 {
+		t.Fatal("unexpected number of archived contracts", len(archived))
+	}
+
+	// assert filtering by reason
+	for _, tc := range []struct {
+		reason   string
+		expected []types.FileContractID
+	}{
+		{api.ContractArchivalReasonHostPruned, []types.FileContractID{fcids[0]}},
+		{api.ContractArchivalReasonRemoved, []types.FileContractID{fcids[1], fcids[2]}},
+		{api.ContractArchivalReasonUnknown, []types.FileContractID{fcids[3]}},
+		{api.ContractArchivalReasonRenewed, nil},
+	} {
+		archived, err := ss.ArchivedContracts(context.Background(), tc.reason)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(archived) != len(tc.expected) {
+			t.Fatalf("unexpected number of contracts archived for reason '%s', %d != %d", tc.reason, len(archived), len(tc.expected))
+		}
+		for i, c := range archived {
+			if c.ID != tc.expected[i] || c.ArchivalReason != tc.reason {
+				t.Fatalf("unexpected contract archived for reason '%s', %+v", tc.reason, c)
+			}
+		}
+	}
+}
+
 func TestArchiveContracts(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -1308,7 +1431,7 @@ func TestObjectHealth(t *testing.T) {
 	}
 
 	// all contracts are good
-	if err := ss.SetContractSet(context.Background(), testContractSet, fcids); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, fcids); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1361,7 +1484,7 @@ func TestObjectHealth(t *testing.T) {
 	}
 
 	// update contract to impact the object's health
-	if err := ss.SetContractSet(context.Background(), testContractSet, []types.FileContractID{fcids[0], fcids[2], fcids[3], fcids[4]}); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, []types.FileContractID{fcids[0], fcids[2], fcids[3], fcids[4]}); err != nil {
 		t.Fatal(err)
 	}
 	if err := ss.RefreshHealth(context.Background()); err != nil {
@@ -1398,7 +1521,7 @@ func TestObjectHealth(t *testing.T) {
 	}
 
 	// update contract set again to make sure the 2nd slab has even worse health
-	if err := ss.SetContractSet(context.Background(), testContractSet, []types.FileContractID{fcids[0], fcids[2], fcids[3]}); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, []types.FileContractID{fcids[0], fcids[2], fcids[3]}); err != nil {
 		t.Fatal(err)
 	}
 	if err := ss.RefreshHealth(context.Background()); err != nil {
@@ -1671,7 +1794,7 @@ func TestUnhealthySlabs(t *testing.T) {
 
 	// update the contract set
 	goodContracts := []types.FileContractID{fcid1, fcid2, fcid3}
-	if err := ss.SetContractSet(context.Background(), testContractSet, goodContracts); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, goodContracts); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1809,6 +1932,25 @@ func TestUnhealthySlabs(t *testing.T) {
 	if len(slabs) != 0 {
 		t.Fatal("expected no slabs to migrate", len(slabs))
 	}
+
+	// remove the slices that reference the least healthy slab, this is the
+	// case for slabs of deleted objects that haven't been pruned yet
+	key, _ := obj.Slabs[2].Key.MarshalBinary()
+	if err := ss.db.Exec("DELETE FROM slices WHERE db_slab_id = (SELECT id FROM slabs WHERE slabs.key = ?)", secretKey(key)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the slab is no longer returned for migration
+	slabs, err = ss.UnhealthySlabs(context.Background(), 0.49, testContractSet, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []api.UnhealthySlab{
+		{Key: obj.Slabs[4].Key, Health: 0},
+	}
+	if !reflect.DeepEqual(slabs, expected) {
+		t.Fatal("unexpected slabs", slabs, expected)
+	}
 }
 
 func TestUnhealthySlabsNegHealth(t *testing.T) {
@@ -1831,7 +1973,7 @@ func TestUnhealthySlabsNegHealth(t *testing.T) {
 	fcid1 := fcids[0]
 
 	// add it to the contract set
-	if err := ss.SetContractSet(context.Background(), testContractSet, fcids); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, fcids); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1890,7 +2032,7 @@ func TestUnhealthySlabsNoContracts(t *testing.T) {
 	fcid1 := fcids[0]
 
 	// add it to the contract set
-	if err := ss.SetContractSet(context.Background(), testContractSet, fcids); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, fcids); err != nil {
 		t.Fatal(err)
 	}
 
@@ -1970,7 +2112,7 @@ func TestUnhealthySlabsNoRedundancy(t *testing.T) {
 
 	// select the first two contracts as good contracts
 	goodContracts := []types.FileContractID{fcid1, fcid2}
-	if err := ss.SetContractSet(context.Background(), testContractSet, goodContracts); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, goodContracts); err != nil {
 		t.Fatal(err)
 	}
 
@@ -2105,6 +2247,181 @@ func TestContractSectors(t *testing.T) {
 	}
 }
 
+// TestSlabsHealth verifies slabs with shards on archived contracts or blocked
+// hosts are reported as degraded, with the least healthy slabs first.
+func TestSlabsHealth(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add 3 hosts and a fourth one we can block
+	hks, err := ss.addTestHosts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hk4 := types.PublicKey{4}
+	if err := ss.addCustomTestHost(hk4, "foo.com:1000"); err != nil {
+		t.Fatal(err)
+	}
+	hks = append(hks, hk4)
+	hk1, hk2, hk3 := hks[0], hks[1], hks[2]
+
+	// add 4 contracts
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcid1, fcid2, fcid3, fcid4 := fcids[0], fcids[1], fcids[2], fcids[3]
+
+	// add an object with 3 slabs
+	newSlab := func(minShards uint8, shards ...object.Sector) object.SlabSlice {
+		return object.SlabSlice{Slab: object.Slab{
+			Key:       object.GenerateEncryptionKey(),
+			MinShards: minShards,
+			Shards:    shards,
+		}}
+	}
+	obj := object.Object{
+		Key: object.GenerateEncryptionKey(),
+		Slabs: []object.SlabSlice{
+			newSlab(1,
+				newTestShard(hk1, fcid1, types.Hash256{1}),
+				newTestShard(hk2, fcid2, types.Hash256{2}),
+				newTestShard(hk3, fcid3, types.Hash256{3}),
+			),
+			newSlab(2,
+				newTestShard(hk1, fcid1, types.Hash256{4}),
+				newTestShard(hk2, fcid2, types.Hash256{5}),
+				newTestShard(hk4, fcid4, types.Hash256{6}),
+			),
+			newSlab(1,
+				newTestShard(hk1, fcid1, types.Hash256{7}),
+				newTestShard(hk2, fcid2, types.Hash256{8}),
+			),
+		},
+	}
+	ctx := context.Background()
+	if _, err := ss.addTestObject(t.Name(), obj); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert all slabs are healthy
+	if slabs, err := ss.SlabsHealth(ctx, -1); err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 0 {
+		t.Fatal("unexpected number of slabs", len(slabs))
+	}
+
+	// archive the contract with h3 and block h4
+	if err := ss.ArchiveContract(ctx, fcid3, api.ContractArchivalReasonHostPruned); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateHostBlocklistEntries(ctx, []string{"foo.com"}, nil, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the first two slabs are degraded, the second one being the worst
+	slabs, err := ss.SlabsHealth(ctx, -1)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 2 {
+		t.Fatal("unexpected number of slabs", len(slabs))
+	} else if slabs[0].Key.String() != obj.Slabs[1].Key.String() || slabs[0].Health != 0 || slabs[0].UsableShards != 2 {
+		t.Fatalf("unexpected slab %+v", slabs[0])
+	} else if slabs[1].Key.String() != obj.Slabs[0].Key.String() || slabs[1].Health != 0.5 || slabs[1].UsableShards != 2 {
+		t.Fatalf("unexpected slab %+v", slabs[1])
+	}
+
+	// assert the limit is applied
+	if slabs, err := ss.SlabsHealth(ctx, 1); err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 1 || slabs[0].Key.String() != obj.Slabs[1].Key.String() {
+		t.Fatal("unexpected slabs", slabs)
+	}
+}
+
+// TestSlabsForEvacuation verifies slabs with sectors on evacuated hosts are
+// returned until their sectors are migrated to other hosts.
+func TestSlabsForEvacuation(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add 3 hosts
+	hks, err := ss.addTestHosts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hk1, hk2, hk3 := hks[0], hks[1], hks[2]
+
+	// add 3 contracts
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcid1, fcid2, fcid3 := fcids[0], fcids[1], fcids[2]
+
+	// add an object with a slab on h1 and h2
+	obj := object.Object{
+		Key: object.GenerateEncryptionKey(),
+		Slabs: []object.SlabSlice{
+			{
+				Slab: object.Slab{
+					Key:       object.GenerateEncryptionKey(),
+					MinShards: 1,
+					Shards: []object.Sector{
+						newTestShard(hk1, fcid1, types.Hash256{1}),
+						newTestShard(hk2, fcid2, types.Hash256{2}),
+					},
+				},
+			},
+		},
+	}
+	ctx := context.Background()
+	if _, err := ss.addTestObject(t.Name(), obj); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert no slabs are returned for h3 or when no hosts are passed
+	if slabs, err := ss.SlabsForEvacuation(ctx, []types.PublicKey{hk3}, -1); err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 0 {
+		t.Fatal("unexpected number of slabs", len(slabs))
+	} else if slabs, err := ss.SlabsForEvacuation(ctx, nil, -1); err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 0 {
+		t.Fatal("unexpected number of slabs", len(slabs))
+	}
+
+	// assert the slab needs to be evacuated from h2
+	slabs, err := ss.SlabsForEvacuation(ctx, []types.PublicKey{hk2}, -1)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 1 {
+		t.Fatal("unexpected number of slabs", len(slabs))
+	} else if slabs[0].Key.String() != obj.Slabs[0].Key.String() {
+		t.Fatal("unexpected slab", slabs[0].Key)
+	}
+
+	// migrate the sector from h2 to h3
+	slab := obj.Slabs[0].Slab
+	slab.Shards[1] = newTestShard(hk3, fcid3, types.Hash256{2})
+	if err := ss.UpdateSlab(ctx, slab, testContractSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the sector was reassigned to h3
+	if updated, err := ss.Slab(ctx, slab.Key); err != nil {
+		t.Fatal(err)
+	} else if updated.Shards[1].LatestHost != hk3 {
+		t.Fatal("sector wasn't reassigned to h3", updated.Shards[1].LatestHost)
+	}
+
+	// assert the slab no longer needs to be evacuated from h2
+	if slabs, err := ss.SlabsForEvacuation(ctx, []types.PublicKey{hk2}, -1); err != nil {
+		t.Fatal(err)
+	} else if len(slabs) != 0 {
+		t.Fatal("unexpected number of slabs", len(slabs))
+	}
+}
+
 // TestUpdateSlab verifies the functionality of UpdateSlab.
 func TestUpdateSlab(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
@@ -2186,7 +2503,7 @@ func TestUpdateSlab(t *testing.T) {
 
 	// select contracts h1 and h3 as good contracts (h2 is bad)
 	goodContracts := []types.FileContractID{fcid1, fcid3}
-	if err := ss.SetContractSet(ctx, testContractSet, goodContracts); err != nil {
+	if _, err := ss.SetContractSet(ctx, testContractSet, goodContracts); err != nil {
 		t.Fatal(err)
 	}
 
@@ -2264,7 +2581,7 @@ func TestUpdateSlab(t *testing.T) {
 	}
 
 	// update the slab to change its contract set.
-	if err := ss.SetContractSet(ctx, "other", nil); err != nil {
+	if _, err := ss.SetContractSet(ctx, "other", nil); err != nil {
 		t.Fatal(err)
 	}
 	err = ss.UpdateSlab(ctx, slab, "other")
@@ -2283,6 +2600,96 @@ func TestUpdateSlab(t *testing.T) {
 	}
 }
 
+func TestRekeySlab(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add 4 hosts and contracts
+	hks, err := ss.addTestHosts(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add an object
+	oldKey := object.GenerateEncryptionKey()
+	obj := object.Object{
+		Key: object.GenerateEncryptionKey(),
+		Slabs: []object.SlabSlice{
+			{
+				Slab: object.Slab{
+					Key:       oldKey,
+					MinShards: 1,
+					Shards: []object.Sector{
+						newTestShard(hks[0], fcids[0], types.Hash256{1}),
+						newTestShard(hks[1], fcids[1], types.Hash256{2}),
+					},
+				},
+				Length: 100,
+			},
+		},
+	}
+	ctx := context.Background()
+	if _, err := ss.addTestObject(t.Name(), obj); err != nil {
+		t.Fatal(err)
+	}
+
+	// rekey the slab, moving it to the other hosts
+	rekeyed := object.Slab{
+		Key:       object.GenerateEncryptionKey(),
+		MinShards: 1,
+		Shards: []object.Sector{
+			newTestShard(hks[2], fcids[2], types.Hash256{3}),
+			newTestShard(hks[3], fcids[3], types.Hash256{4}),
+		},
+	}
+	if err := ss.RekeySlab(ctx, oldKey, rekeyed, testContractSet); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the old key is gone and the slab is stored under the new key
+	if _, err := ss.Slab(ctx, oldKey); !errors.Is(err, api.ErrSlabNotFound) {
+		t.Fatal("expected slab to be not found", err)
+	} else if slab, err := ss.Slab(ctx, rekeyed.Key); err != nil {
+		t.Fatal(err)
+	} else if slab.Shards[0].Root != (types.Hash256{3}) || slab.Shards[1].Root != (types.Hash256{4}) {
+		t.Fatal("unexpected shards", slab.Shards)
+	}
+
+	// assert the object references the rekeyed slab
+	o, err := ss.Object(ctx, api.DefaultBucketName, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	} else if o.Object.Slabs[0].Key.String() != rekeyed.Key.String() {
+		t.Fatal("unexpected slab key")
+	} else if o.Object.Slabs[0].Length != 100 {
+		t.Fatal("unexpected slice length", o.Object.Slabs[0].Length)
+	}
+
+	// assert the old sectors are no longer linked to their contracts
+	for i, fcid := range fcids {
+		roots, err := ss.ContractRoots(ctx, fcid)
+		if err != nil {
+			t.Fatal(err)
+		} else if i < 2 && len(roots) != 0 {
+			t.Fatalf("expected no roots for contract %d, got %v", i, len(roots))
+		} else if i >= 2 && len(roots) != 1 {
+			t.Fatalf("expected 1 root for contract %d, got %v", i, len(roots))
+		}
+	}
+
+	// assert the layout of the slab can't change
+	invalid := rekeyed
+	invalid.Key = object.GenerateEncryptionKey()
+	invalid.Shards = invalid.Shards[:1]
+	if err := ss.RekeySlab(ctx, rekeyed.Key, invalid, testContractSet); !errors.Is(err, errInvalidNumberOfShards) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 func newTestObject(slabs int) object.Object {
 	obj := object.Object{}
 
@@ -2344,6 +2751,7 @@ func TestRecordContractSpending(t *testing.T) {
 		FundAccount: types.Siacoins(3),
 		Deletions:   types.Siacoins(4),
 		SectorRoots: types.Siacoins(5),
+		Storage:     types.Siacoins(6),
 	}
 	err = ss.RecordContractSpending(context.Background(), []api.ContractSpendingRecord{
 		// non-existent contract
@@ -2387,24 +2795,264 @@ func TestRecordContractSpending(t *testing.T) {
 	}
 }
 
-// TestRenameObjects is a unit test for RenameObject and RenameObjects.
-func TestRenameObjects(t *testing.T) {
+// TestContractSpendingBreakdown asserts spending recorded in separate
+// categories is accumulated per category and sums up to the total.
+func TestSetContractSet(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
 
-	// Create a few objects.
-	objects := []string{
-		"/fileś/1a",
-		"/fileś/2a",
-		"/fileś/3a",
-		"/fileś/CASE",
-		"/fileś/case",
-		"/fileś/dir/1b",
-		"/fileś/dir/2b",
-		"/fileś/dir/3b",
-		"/foo",
-		"/bar",
-		"/baz",
+	// create 4 contracts
+	hks, err := ss.addTestHosts(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// assertMembers asserts the join table contains exactly the given members
+	assertMembers := func(want []types.FileContractID) {
+		t.Helper()
+		var got []fileContractID
+		if err := ss.db.
+			Model(&dbContract{}).
+			Select("contracts.fcid").
+			Joins("INNER JOIN contract_set_contracts csc ON csc.db_contract_id = contracts.id").
+			Joins("INNER JOIN contract_sets cs ON cs.id = csc.db_contract_set_id").
+			Where("cs.name = ?", testContractSet).
+			Order("contracts.fcid ASC").
+			Pluck("fcid", &got).
+			Error; err != nil {
+			t.Fatal(err)
+		} else if len(got) != len(want) {
+			t.Fatalf("expected %d members, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if types.FileContractID(got[i]) != want[i] {
+				t.Fatalf("unexpected member %d: %v != %v", i, got[i], want[i])
+			}
+		}
+	}
+
+	// assertIDs asserts the given ids match, ignoring the order
+	assertIDs := func(got, want []types.FileContractID) {
+		t.Helper()
+		sort.Slice(got, func(i, j int) bool { return bytes.Compare(got[i][:], got[j][:]) < 0 })
+		if len(got) != len(want) {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("expected %v, got %v", want, got)
+			}
+		}
+	}
+
+	// create the set
+	resp, err := ss.SetContractSet(context.Background(), testContractSet, fcids[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMembers(fcids[:2])
+	assertIDs(resp.Added, fcids[:2])
+	assertIDs(resp.Removed, nil)
+
+	// replace its contents, including an unknown contract which is ignored
+	resp, err = ss.SetContractSet(context.Background(), testContractSet, []types.FileContractID{fcids[1], fcids[2], fcids[3], {99}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMembers(fcids[1:])
+	assertIDs(resp.Added, fcids[2:])
+	assertIDs(resp.Removed, fcids[:1])
+
+	// empty the set
+	resp, err = ss.SetContractSet(context.Background(), testContractSet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMembers(nil)
+	assertIDs(resp.Added, nil)
+	assertIDs(resp.Removed, fcids[1:])
+}
+
+func TestContractSetStats(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+	ctx := context.Background()
+
+	// create 3 hosts with different storage prices
+	hks, err := ss.addTestHosts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, hk := range hks {
+		settings := rhpv2.HostSettings{StoragePrice: types.NewCurrency64(uint64(i + 1))}
+		if err := ss.RecordHostScans(ctx, []api.HostScan{newTestScan(hk, time.Now(), settings, true)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// create a contract with each host, of different sizes
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, fcid := range fcids {
+		if err := ss.db.
+			Model(&dbContract{}).
+			Where("fcid", fileContractID(fcid)).
+			Update("size", (i+1)*10).
+			Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// add host checks with different scores
+	ap := "ap"
+	if err := ss.UpdateAutopilot(ctx, api.Autopilot{ID: ap}); err != nil {
+		t.Fatal(err)
+	}
+	for i, age := range []float64{.5, 1, .25} {
+		hc := newTestHostCheck()
+		hc.Score = api.HostScoreBreakdown{Age: age, Collateral: 1, Interactions: 1, StorageRemaining: 1, Uptime: 1, Version: 1, Prices: 1, SuccessRate: 1}
+		if err := ss.UpdateHostCheck(ctx, ap, hks[i], hc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// create two overlapping sets
+	if _, err := ss.SetContractSet(ctx, "a", fcids[:2]); err != nil {
+		t.Fatal(err)
+	} else if _, err := ss.SetContractSet(ctx, "b", fcids[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the stats of both sets
+	for _, want := range []api.ContractSetStats{
+		{
+			Name:         "a",
+			Contracts:    2,
+			Hosts:        2,
+			TotalStorage: 30,
+			AvgHostScore: .75,
+			MonthlyCost:  types.NewCurrency64((1*10 + 2*20) * 30 * api.BlocksPerDay),
+		},
+		{
+			Name:         "b",
+			Contracts:    2,
+			Hosts:        2,
+			TotalStorage: 50,
+			AvgHostScore: .625,
+			MonthlyCost:  types.NewCurrency64((2*20 + 3*30) * 30 * api.BlocksPerDay),
+		},
+	} {
+		got, err := ss.ContractSetStats(ctx, want.Name, ap)
+		if err != nil {
+			t.Fatal(err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Fatalf("unexpected stats for set %v: %+v != %+v", want.Name, got, want)
+		}
+	}
+
+	// assert the score is omitted without autopilot
+	if got, err := ss.ContractSetStats(ctx, "a", ""); err != nil {
+		t.Fatal(err)
+	} else if got.AvgHostScore != 0 || got.TotalStorage != 30 {
+		t.Fatalf("unexpected stats %+v", got)
+	}
+
+	// assert an unknown set is reported
+	if _, err := ss.ContractSetStats(ctx, "c", ap); !errors.Is(err, api.ErrContractSetNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// assert the contract counts include empty sets
+	if _, err := ss.SetContractSet(ctx, "empty", nil); err != nil {
+		t.Fatal(err)
+	} else if total, sets, err := ss.ContractCounts(ctx); err != nil {
+		t.Fatal(err)
+	} else if total != 3 {
+		t.Fatal("unexpected total", total)
+	} else if !reflect.DeepEqual(sets, map[string]uint64{"a": 2, "b": 2, "empty": 0, testContractSet: 0}) {
+		t.Fatal("unexpected counts", sets)
+	}
+}
+
+func TestContractSpendingBreakdown(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// add a contract
+	hk := types.GeneratePrivateKey().PublicKey()
+	if err := ss.addTestHost(hk); err != nil {
+		t.Fatal(err)
+	}
+	fcid := types.FileContractID{1}
+	if _, err := ss.addTestContract(fcid, hk); err != nil {
+		t.Fatal(err)
+	}
+
+	// record spending in multiple categories, across multiple records
+	records := []api.ContractSpendingRecord{
+		{ContractID: fcid, ContractSpending: api.ContractSpending{Uploads: types.Siacoins(1), Storage: types.Siacoins(2)}},
+		{ContractID: fcid, ContractSpending: api.ContractSpending{Downloads: types.Siacoins(3)}},
+		{ContractID: fcid, ContractSpending: api.ContractSpending{FundAccount: types.Siacoins(4)}},
+		{ContractID: fcid, ContractSpending: api.ContractSpending{Uploads: types.Siacoins(5), Storage: types.Siacoins(6)}},
+	}
+	for _, r := range records {
+		if err := ss.RecordContractSpending(context.Background(), []api.ContractSpendingRecord{r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assert the breakdown
+	c, err := ss.Contract(context.Background(), fcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := api.ContractSpending{
+		Uploads:     types.Siacoins(6),
+		Downloads:   types.Siacoins(3),
+		FundAccount: types.Siacoins(4),
+		Storage:     types.Siacoins(8),
+	}
+	if c.Spending != expected {
+		t.Fatalf("unexpected spending, %+v != %+v", c.Spending, expected)
+	} else if total := c.Spending.Total(); !total.Equals(types.Siacoins(21)) {
+		t.Fatalf("unexpected total spending %v", total)
+	}
+
+	// assert the storage spending is part of the contract metric
+	metrics, err := ss.ContractMetrics(context.Background(), time.UnixMilli(0), 1, time.Hour*24*365*100, api.ContractMetricsQueryOpts{ContractID: fcid})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(metrics) != 1 {
+		t.Fatalf("expected 1 metric, got %v", len(metrics))
+	} else if !metrics[0].StorageSpending.Equals(types.Siacoins(8)) {
+		t.Fatal("unexpected storage spending", metrics[0].StorageSpending)
+	}
+}
+
+// TestRenameObjects is a unit test for RenameObject and RenameObjects.
+func TestRenameObjects(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// Create a few objects.
+	objects := []string{
+		"/fileś/1a",
+		"/fileś/2a",
+		"/fileś/3a",
+		"/fileś/CASE",
+		"/fileś/case",
+		"/fileś/dir/1b",
+		"/fileś/dir/2b",
+		"/fileś/dir/3b",
+		"/foo",
+		"/bar",
+		"/baz",
 		"/baz2",
 		"/baz3",
 	}
@@ -2961,6 +3609,73 @@ func TestPartialSlab(t *testing.T) {
 	}
 }
 
+func TestExpiringContracts(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// create hosts
+	hks, err := ss.addTestHosts(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create contracts
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add an object to the first two contracts
+	for i := 0; i < 2; i++ {
+		if _, err := ss.addTestObject(fmt.Sprintf("obj_%d", i+1), object.Object{
+			Key: object.GenerateEncryptionKey(),
+			Slabs: []object.SlabSlice{
+				{
+					Slab: object.Slab{
+						Key:       object.GenerateEncryptionKey(),
+						MinShards: 1,
+						Shards:    newTestShards(hks[i], fcids[i], types.Hash256{byte(i)}),
+					},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the first and the last contract expire soon, the second one doesn't
+	for i, windowStart := range []uint64{110, 500, 110} {
+		if err := ss.db.
+			Model(&dbContract{}).
+			Where("fcid", fileContractID(fcids[i])).
+			Update("window_start", windowStart).
+			Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assert only the first contract is expiring, the last one doesn't store
+	// any sectors
+	expiring, err := ss.ExpiringContracts(context.Background(), 100, 144)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(expiring) != 1 {
+		t.Fatalf("expected 1 expiring contract, got %v", len(expiring))
+	} else if c := expiring[0]; c.ID != fcids[0] || c.HostKey != hks[0] || c.WindowStart != 110 || c.BlocksRemaining != 10 || c.Sectors != 1 {
+		t.Fatalf("unexpected expiring contract %+v", c)
+	}
+
+	// assert contracts past their window start are reported as well
+	expiring, err = ss.ExpiringContracts(context.Background(), 600, 144)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(expiring) != 2 {
+		t.Fatalf("expected 2 expiring contracts, got %v", len(expiring))
+	} else if expiring[0].BlocksRemaining != 0 || expiring[1].BlocksRemaining != 0 {
+		t.Fatal("expected no blocks remaining", expiring)
+	}
+}
+
 func TestContractSizes(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -3436,6 +4151,165 @@ func TestCopyObject(t *testing.T) {
 	}
 }
 
+// TestPruneSharedSlabs asserts that deleting an object removes all rows that
+// depend on it while slabs that are still referenced by another object, either
+// because they were uploaded as part of both objects or because the object was
+// copied, are only pruned after the last object referencing them is deleted.
+func TestPruneSharedSlabs(t *testing.T) {
+	type counts struct {
+		slices, slabs, sectors, contractSectors int64
+	}
+	type objectRef struct {
+		bucket, path string
+	}
+
+	tests := []struct {
+		name string
+		// create creates two objects that share at least the shared slab
+		create func(ctx context.Context, ss *testSQLStore, shared, unique object.Slab) (first, second objectRef, err error)
+		// remaining are the slabs of the second object
+		remaining  func(shared, unique object.Slab) []object.Slab
+		before     counts
+		afterFirst counts
+	}{
+		{
+			name: "shared slab",
+			create: func(ctx context.Context, ss *testSQLStore, shared, unique object.Slab) (first, second objectRef, err error) {
+				if _, err = ss.addTestObject("/foo", object.Object{
+					Key: object.GenerateEncryptionKey(),
+					Slabs: []object.SlabSlice{
+						{Slab: shared, Length: 100},
+						{Slab: unique, Length: 100},
+					},
+				}); err != nil {
+					return
+				} else if _, err = ss.addTestObject("/bar", object.Object{
+					Key:   object.GenerateEncryptionKey(),
+					Slabs: []object.SlabSlice{{Slab: shared, Length: 100}},
+				}); err != nil {
+					return
+				}
+				return objectRef{api.DefaultBucketName, "/foo"}, objectRef{api.DefaultBucketName, "/bar"}, nil
+			},
+			remaining:  func(shared, _ object.Slab) []object.Slab { return []object.Slab{shared} },
+			before:     counts{slices: 3, slabs: 2, sectors: 2, contractSectors: 2},
+			afterFirst: counts{slices: 1, slabs: 1, sectors: 1, contractSectors: 1},
+		},
+		{
+			name: "copied object",
+			create: func(ctx context.Context, ss *testSQLStore, shared, unique object.Slab) (first, second objectRef, err error) {
+				if err = ss.CreateBucket(ctx, "dst", api.BucketPolicy{}); err != nil {
+					return
+				} else if _, err = ss.addTestObject("/foo", object.Object{
+					Key: object.GenerateEncryptionKey(),
+					Slabs: []object.SlabSlice{
+						{Slab: shared, Length: 100},
+						{Slab: unique, Length: 100},
+					},
+				}); err != nil {
+					return
+				} else if _, err = ss.CopyObject(ctx, api.DefaultBucketName, "dst", "/foo", "/bar", "", nil); err != nil {
+					return
+				}
+				return objectRef{api.DefaultBucketName, "/foo"}, objectRef{"dst", "/bar"}, nil
+			},
+			remaining:  func(shared, unique object.Slab) []object.Slab { return []object.Slab{shared, unique} },
+			before:     counts{slices: 4, slabs: 2, sectors: 2, contractSectors: 2},
+			afterFirst: counts{slices: 2, slabs: 2, sectors: 2, contractSectors: 2},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+			defer ss.Close()
+
+			// create 2 hosts with a contract each
+			hks, err := ss.addTestHosts(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fcids, _, err := ss.addTestContracts(hks)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// create the objects
+			ctx := context.Background()
+			shared := object.Slab{
+				Health:    1.0,
+				Key:       object.GenerateEncryptionKey(),
+				MinShards: 1,
+				Shards:    newTestShards(hks[0], fcids[0], types.Hash256{1}),
+			}
+			unique := object.Slab{
+				Health:    1.0,
+				Key:       object.GenerateEncryptionKey(),
+				MinShards: 1,
+				Shards:    newTestShards(hks[1], fcids[1], types.Hash256{2}),
+			}
+			first, second, err := test.create(ctx, ss, shared, unique)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// assertCounts asserts the number of rows in the affected tables
+			assertCounts := func(expected counts) {
+				t.Helper()
+				for _, c := range []struct {
+					table    string
+					expected int64
+				}{
+					{"slices", expected.slices},
+					{"slabs", expected.slabs},
+					{"sectors", expected.sectors},
+					{"contract_sectors", expected.contractSectors},
+				} {
+					var n int64
+					if err := ss.db.Table(c.table).Count(&n).Error; err != nil {
+						t.Fatal(err)
+					} else if n != c.expected {
+						t.Fatalf("expected %d rows in %s, got %d", c.expected, c.table, n)
+					}
+				}
+			}
+			assertCounts(test.before)
+
+			// delete the first object, the slabs of the second object should
+			// remain intact
+			if err := ss.RemoveObjectBlocking(ctx, first.bucket, first.path); err != nil {
+				t.Fatal(err)
+			}
+			assertCounts(test.afterFirst)
+
+			remaining := test.remaining(shared, unique)
+			obj, err := ss.Object(ctx, second.bucket, second.path)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(obj.Slabs) != len(remaining) {
+				t.Fatalf("expected %v slabs, got %v", len(remaining), len(obj.Slabs))
+			}
+			for i, slab := range obj.Slabs {
+				if slab.Key.String() != remaining[i].Key.String() {
+					t.Fatalf("slab %d doesn't match the original", i)
+				} else if len(slab.Shards) != len(remaining[i].Shards) {
+					t.Fatalf("expected %v shards, got %v", len(remaining[i].Shards), len(slab.Shards))
+				}
+				for j, sector := range slab.Shards {
+					if sector.Root != remaining[i].Shards[j].Root {
+						t.Fatalf("sector %d of slab %d doesn't match the original", j, i)
+					}
+				}
+			}
+
+			// delete the second object, nothing should remain
+			if err := ss.RemoveObjectBlocking(ctx, second.bucket, second.path); err != nil {
+				t.Fatal(err)
+			}
+			assertCounts(counts{})
+		})
+	}
+}
+
 func TestMarkSlabUploadedAfterRenew(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -3590,7 +4464,7 @@ func TestListObjects(t *testing.T) {
 		}
 	}
 	for _, test := range tests {
-		res, err := ss.ListObjects(ctx, api.DefaultBucketName, test.prefix, test.sortBy, test.sortDir, "", -1)
+		res, err := ss.ListObjects(ctx, api.DefaultBucketName, test.prefix, "", test.sortBy, test.sortDir, "", -1)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -3605,7 +4479,7 @@ func TestListObjects(t *testing.T) {
 		if len(res.Objects) > 0 {
 			marker := ""
 			for offset := 0; offset < len(test.want); offset++ {
-				res, err := ss.ListObjects(ctx, api.DefaultBucketName, test.prefix, test.sortBy, test.sortDir, marker, 1)
+				res, err := ss.ListObjects(ctx, api.DefaultBucketName, test.prefix, "", test.sortBy, test.sortDir, marker, 1)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -3625,6 +4499,88 @@ func TestListObjects(t *testing.T) {
 	}
 }
 
+func TestListObjectsWithDelimiter(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	ctx := context.Background()
+	for _, path := range []string{
+		"/foo/bar",
+		"/foo/baz/quux",
+		"/foo/baz/quuz",
+		"/foo/baz/qux/corge",
+		"/foo/qux",
+		"/foo/zap/zip",
+		"/gab/guub",
+		"/zoo",
+	} {
+		obj := newTestObject(1)
+		if _, err := ss.addTestObject(path, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(objects []api.ObjectMetadata) (names []string) {
+		for _, o := range objects {
+			names = append(names, o.Name)
+		}
+		return
+	}
+
+	tests := []struct {
+		prefix   string
+		objects  []string
+		prefixes []string
+	}{
+		{"/", []string{"/zoo"}, []string{"/foo/", "/gab/"}},
+		{"/foo/", []string{"/foo/bar", "/foo/qux"}, []string{"/foo/baz/", "/foo/zap/"}},
+		{"/foo/baz/", []string{"/foo/baz/quux", "/foo/baz/quuz"}, []string{"/foo/baz/qux/"}},
+		{"/foo/ba", []string{"/foo/bar"}, []string{"/foo/baz/"}},
+		{"/nope/", nil, nil},
+	}
+	for _, test := range tests {
+		// assert listing all entries at once collapses common prefixes
+		res, err := ss.ListObjects(ctx, api.DefaultBucketName, test.prefix, "/", "", "", "", -1)
+		if err != nil {
+			t.Fatal(err)
+		} else if res.HasMore || res.NextMarker != "" {
+			t.Fatal("unexpected pagination", res.HasMore, res.NextMarker)
+		} else if got := names(res.Objects); !reflect.DeepEqual(got, test.objects) {
+			t.Fatalf("unexpected objects for prefix %v, %v != %v", test.prefix, got, test.objects)
+		} else if !reflect.DeepEqual(res.CommonPrefixes, test.prefixes) {
+			t.Fatalf("unexpected prefixes for prefix %v, %v != %v", test.prefix, res.CommonPrefixes, test.prefixes)
+		}
+
+		// assert paging through the entries one by one yields the same result
+		var objects, prefixes []string
+		var marker string
+		for {
+			res, err := ss.ListObjects(ctx, api.DefaultBucketName, test.prefix, "/", "", "", marker, 1)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(res.Objects)+len(res.CommonPrefixes) > 1 {
+				t.Fatal("unexpected number of entries", res)
+			}
+			objects = append(objects, names(res.Objects)...)
+			prefixes = append(prefixes, res.CommonPrefixes...)
+			if !res.HasMore {
+				break
+			}
+			marker = res.NextMarker
+		}
+		if !reflect.DeepEqual(objects, test.objects) {
+			t.Fatalf("unexpected objects for prefix %v, %v != %v", test.prefix, objects, test.objects)
+		} else if !reflect.DeepEqual(prefixes, test.prefixes) {
+			t.Fatalf("unexpected prefixes for prefix %v, %v != %v", test.prefix, prefixes, test.prefixes)
+		}
+	}
+
+	// assert a delimiter requires sorting by name
+	if _, err := ss.ListObjects(ctx, api.DefaultBucketName, "/", "/", api.ObjectSortByHealth, "", "", -1); !errors.Is(err, api.ErrInvalidObjectSortParameters) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 func TestDeleteHostSector(t *testing.T) {
 	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
 	defer ss.Close()
@@ -3734,6 +4690,113 @@ func TestDeleteHostSector(t *testing.T) {
 		t.Fatalf("expected 0 lost sector, got %v", hi.Interactions.LostSectors)
 	}
 }
+
+func TestSlabLocations(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+	ctx := context.Background()
+
+	// create 4 hosts with a contract each
+	var hks []types.PublicKey
+	for i := 1; i <= 4; i++ {
+		hk := types.PublicKey{byte(i)}
+		if err := ss.addCustomTestHost(hk, fmt.Sprintf("host%d.com", i)); err != nil {
+			t.Fatal(err)
+		}
+		hks = append(hks, hk)
+	}
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first host is usable for autopilot 'ap1' but not for 'ap2', the
+	// second host is unusable and the third host has no checks
+	usable, unusable := newTestHostCheck(), newTestHostCheck()
+	unusable.Usability.Gouging = true
+	for _, ap := range []string{"ap1", "ap2"} {
+		if err := ss.UpdateAutopilot(ctx, api.Autopilot{ID: ap}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ss.UpdateHostCheck(ctx, "ap1", hks[0], usable); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateHostCheck(ctx, "ap2", hks[0], unusable); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateHostCheck(ctx, "ap1", hks[1], unusable); err != nil {
+		t.Fatal(err)
+	}
+
+	// create a slab with 3 shards, the second shard is stored on two hosts and
+	// the last shard is lost once we archive the fourth contract
+	slab := object.Slab{
+		Key:       object.GenerateEncryptionKey(),
+		MinShards: 1,
+		Shards: []object.Sector{
+			newTestShard(hks[0], fcids[0], types.Hash256{1}),
+			{
+				LatestHost: hks[1],
+				Contracts: map[types.PublicKey][]types.FileContractID{
+					hks[1]: {fcids[1]},
+					hks[2]: {fcids[2]},
+				},
+				Root: types.Hash256{2},
+			},
+			newTestShard(hks[3], fcids[3], types.Hash256{3}),
+		},
+	}
+	if _, err := ss.addTestObject("foo", object.Object{
+		Key:   object.GenerateEncryptionKey(),
+		Slabs: []object.SlabSlice{{Slab: slab, Length: 1}},
+	}); err != nil {
+		t.Fatal(err)
+	} else if err := ss.ArchiveContract(ctx, fcids[3], api.ContractArchivalReasonRemoved); err != nil {
+		t.Fatal(err)
+	}
+
+	// assertLocations asserts the locations of the slab for the given
+	// autopilot, the host with the given key is the only usable one
+	assertLocations := func(autopilotID string, usableHost types.PublicKey) {
+		t.Helper()
+		locations, err := ss.SlabLocations(ctx, slab.Key, autopilotID)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(locations) != 4 {
+			t.Fatalf("expected 4 locations, got %v", len(locations))
+		}
+
+		// sort locations by shard index and contract
+		sort.Slice(locations, func(i, j int) bool {
+			if locations[i].ShardIndex != locations[j].ShardIndex {
+				return locations[i].ShardIndex < locations[j].ShardIndex
+			}
+			return locations[i].HostKey.String() < locations[j].HostKey.String()
+		})
+
+		for i, want := range []api.SlabLocation{
+			{ShardIndex: 0, Root: types.Hash256{1}, ContractID: fcids[0], HostKey: hks[0], NetAddress: "host1.com"},
+			{ShardIndex: 1, Root: types.Hash256{2}, ContractID: fcids[1], HostKey: hks[1], NetAddress: "host2.com"},
+			{ShardIndex: 1, Root: types.Hash256{2}, ContractID: fcids[2], HostKey: hks[2], NetAddress: "host3.com"},
+			{ShardIndex: 2, Root: types.Hash256{3}},
+		} {
+			want.Usable = want.HostKey != (types.PublicKey{}) && want.HostKey == usableHost
+			if !reflect.DeepEqual(locations[i], want) {
+				t.Fatalf("unexpected location %d, %+v != %+v", i, locations[i], want)
+			}
+		}
+	}
+
+	// assert the first host is only usable for the first autopilot
+	assertLocations("ap1", hks[0])
+	assertLocations("ap2", types.PublicKey{})
+	assertLocations("", types.PublicKey{})
+
+	// assert unknown slabs return the right error
+	if _, err := ss.SlabLocations(ctx, object.GenerateEncryptionKey(), ""); !errors.Is(err, api.ErrSlabNotFound) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 func newTestShards(hk types.PublicKey, fcid types.FileContractID, root types.Hash256) []object.Sector {
 	return []object.Sector{
 		newTestShard(hk, fcid, root),
@@ -3903,7 +4966,7 @@ func TestSlabHealthInvalidation(t *testing.T) {
 	refreshHealth(s1, s2)
 
 	// add 2 contracts to the contract set
-	if err := ss.SetContractSet(context.Background(), testContractSet, fcids[:2]); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, fcids[:2]); err != nil {
 		t.Fatal(err)
 	}
 	assertHealthValid(s1, false)
@@ -3913,7 +4976,7 @@ func TestSlabHealthInvalidation(t *testing.T) {
 	refreshHealth(s1, s2)
 
 	// switch out the contract set with two new contracts
-	if err := ss.SetContractSet(context.Background(), testContractSet, fcids[2:]); err != nil {
+	if _, err := ss.SetContractSet(context.Background(), testContractSet, fcids[2:]); err != nil {
 		t.Fatal(err)
 	}
 	assertHealthValid(s1, false)
@@ -4002,7 +5065,7 @@ func TestRefreshHealth(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = ss.SetContractSet(context.Background(), testContractSet, fcids)
+	_, err = ss.SetContractSet(context.Background(), testContractSet, fcids)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -4041,7 +5104,7 @@ func TestRefreshHealth(t *testing.T) {
 	}
 
 	// update contract set and refresh health, assert health is .5
-	err = ss.SetContractSet(context.Background(), testContractSet, fcids[:1])
+	_, err = ss.SetContractSet(context.Background(), testContractSet, fcids[:1])
 	if err != nil {
 		t.Fatal(err)
 	}
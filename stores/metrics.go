@@ -45,6 +45,8 @@ type (
 		DeleteSpendingHi      unsigned64 `gorm:"index:idx_delete_spending;NOT NULL"`
 		ListSpendingLo        unsigned64 `gorm:"index:idx_list_spending;NOT NULL"`
 		ListSpendingHi        unsigned64 `gorm:"index:idx_list_spending;NOT NULL"`
+		StorageSpendingLo     unsigned64 `gorm:"index:idx_storage_spending;NOT NULL"`
+		StorageSpendingHi     unsigned64 `gorm:"index:idx_storage_spending;NOT NULL"`
 	}
 
 	// dbContractPruneMetric tracks information about contract pruning. Such as
@@ -145,6 +147,7 @@ func (s *SQLStore) ContractMetrics(ctx context.Context, start time.Time, n uint6
 			FundAccountSpending: toCurr(metrics[i].FundAccountSpendingLo, metrics[i].FundAccountSpendingHi),
 			DeleteSpending:      toCurr(metrics[i].DeleteSpendingLo, metrics[i].DeleteSpendingHi),
 			ListSpending:        toCurr(metrics[i].ListSpendingLo, metrics[i].ListSpendingHi),
+			StorageSpending:     toCurr(metrics[i].StorageSpendingLo, metrics[i].StorageSpendingHi),
 		}
 	}
 	return resp, nil
@@ -247,6 +250,8 @@ func (s *SQLStore) RecordContractMetric(ctx context.Context, metrics ...api.Cont
 			DeleteSpendingHi:      unsigned64(metric.DeleteSpending.Hi),
 			ListSpendingLo:        unsigned64(metric.ListSpending.Lo),
 			ListSpendingHi:        unsigned64(metric.ListSpending.Hi),
+			StorageSpendingLo:     unsigned64(metric.StorageSpending.Lo),
+			StorageSpendingHi:     unsigned64(metric.StorageSpending.Hi),
 		}
 	}
 	return s.dbMetrics.Transaction(func(tx *gorm.DB) error {
@@ -389,6 +394,8 @@ func (m dbContractMetric) Aggregate(o dbContractMetric) (out dbContractMetric) {
 	deleteSpendingHi, _ := bits.Add64(uint64(m.DeleteSpendingHi), uint64(o.DeleteSpendingHi), carry)
 	listSpendingLo, carry := bits.Add64(uint64(m.ListSpendingLo), uint64(o.ListSpendingLo), 0)
 	listSpendingHi, _ := bits.Add64(uint64(m.ListSpendingHi), uint64(o.ListSpendingHi), carry)
+	storageSpendingLo, carry := bits.Add64(uint64(m.StorageSpendingLo), uint64(o.StorageSpendingLo), 0)
+	storageSpendingHi, _ := bits.Add64(uint64(m.StorageSpendingHi), uint64(o.StorageSpendingHi), carry)
 
 	out.RemainingCollateralLo = unsigned64(remainingCollateralLo)
 	out.RemainingCollateralHi = unsigned64(remainingCollateralHi)
@@ -404,6 +411,8 @@ func (m dbContractMetric) Aggregate(o dbContractMetric) (out dbContractMetric) {
 	out.DeleteSpendingHi = unsigned64(deleteSpendingHi)
 	out.ListSpendingLo = unsigned64(listSpendingLo)
 	out.ListSpendingHi = unsigned64(listSpendingHi)
+	out.StorageSpendingLo = unsigned64(storageSpendingLo)
+	out.StorageSpendingHi = unsigned64(storageSpendingHi)
 	return
 }
 
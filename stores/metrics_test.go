@@ -404,6 +404,7 @@ func TestContractMetrics(t *testing.T) {
 				FundAccountSpending: types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
 				DeleteSpending:      types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
 				ListSpending:        types.NewCurrency64(1),
+				StorageSpending:     types.NewCurrency(frand.Uint64n(math.MaxUint64), frand.Uint64n(math.MaxUint64)),
 			}
 			fcid2Metric[metric.ContractID] = metric
 			metricsTimeAsc = append(metricsTimeAsc, metric)
@@ -475,6 +476,7 @@ func TestContractMetrics(t *testing.T) {
 		expectedMetric.FundAccountSpending, _ = metricsTimeAsc[2*i].FundAccountSpending.AddWithOverflow(metricsTimeAsc[2*i+1].FundAccountSpending)
 		expectedMetric.DeleteSpending, _ = metricsTimeAsc[2*i].DeleteSpending.AddWithOverflow(metricsTimeAsc[2*i+1].DeleteSpending)
 		expectedMetric.ListSpending, _ = metricsTimeAsc[2*i].ListSpending.AddWithOverflow(metricsTimeAsc[2*i+1].ListSpending)
+		expectedMetric.StorageSpending, _ = metricsTimeAsc[2*i].StorageSpending.AddWithOverflow(metricsTimeAsc[2*i+1].StorageSpending)
 		if !cmp.Equal(m, expectedMetric, cmp.Comparer(api.CompareTimeRFC3339)) {
 			t.Fatal(i, "unexpected metric", cmp.Diff(m, expectedMetric, cmp.Comparer(api.CompareTimeRFC3339)))
 		}
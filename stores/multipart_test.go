@@ -269,3 +269,79 @@ func TestMultipartUploads(t *testing.T) {
 		t.Fatal("expected 3 iterations")
 	}
 }
+
+func TestMultipartUploadAssembly(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// create a host and a contract
+	hks, err := ss.addTestHosts(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcids, _, err := ss.addTestContracts(hks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	objName := "/foo"
+	resp, err := ss.CreateMultipartUpload(ctx, api.DefaultBucketName, objName, object.NoOpKey, testMimeType, testMetadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add three parts out of order, each containing a single slab
+	nParts := 3
+	slabs := make([]object.Slab, nParts)
+	for i := range slabs {
+		slabs[i] = object.Slab{
+			Health:    1.0,
+			Key:       object.GenerateEncryptionKey(),
+			MinShards: 1,
+			Shards:    newTestShards(hks[0], fcids[0], types.Hash256{byte(i + 1)}),
+		}
+	}
+	var parts []api.MultipartCompletedPart
+	for _, partNumber := range []int{3, 1, 2} {
+		etag := hex.EncodeToString(frand.Bytes(16))
+		slices := []object.SlabSlice{{Slab: slabs[partNumber-1], Offset: 0, Length: uint32(partNumber * 10)}}
+		if err := ss.AddMultipartPart(ctx, api.DefaultBucketName, objName, testContractSet, etag, resp.UploadID, partNumber, slices); err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, api.MultipartCompletedPart{PartNumber: partNumber, ETag: etag})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	// complete the upload
+	if _, err := ss.CompleteMultipartUpload(ctx, api.DefaultBucketName, objName, resp.UploadID, parts, api.CompleteMultipartOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the slices of the assembled object are ordered by part number
+	obj, err := ss.Object(ctx, api.DefaultBucketName, objName)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(obj.Slabs) != nParts {
+		t.Fatalf("expected %v slices, got %v", nParts, len(obj.Slabs))
+	} else if obj.Size != 60 {
+		t.Fatalf("expected object size to be 60, got %v", obj.Size)
+	}
+	for i, slice := range obj.Slabs {
+		if slice.Key.String() != slabs[i].Key.String() {
+			t.Fatalf("slice %v: unexpected slab", i)
+		} else if slice.Length != uint32((i+1)*10) {
+			t.Fatalf("slice %v: expected length %v, got %v", i, (i+1)*10, slice.Length)
+		}
+	}
+
+	// assert the upload and its parts were cleaned up
+	var nUploads, nPartsLeft int64
+	if err := ss.db.Model(&dbMultipartUpload{}).Count(&nUploads).Error; err != nil {
+		t.Fatal(err)
+	} else if err := ss.db.Model(&dbMultipartPart{}).Count(&nPartsLeft).Error; err != nil {
+		t.Fatal(err)
+	} else if nUploads != 0 || nPartsLeft != 0 {
+		t.Fatalf("expected multipart upload to be removed, got %v uploads and %v parts", nUploads, nPartsLeft)
+	}
+}
@@ -2,6 +2,7 @@ package stores
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -10,18 +11,58 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+const (
+	// settingHistoryRetention is the number of history entries that are kept
+	// per setting, older entries are pruned when a setting changes.
+	settingHistoryRetention = 100
+)
+
+// secretSettings contains the keys of settings that contain secrets, changes
+// to these settings are not recorded in the setting history.
+var secretSettings = map[string]struct{}{
+	api.SettingS3Authentication: {},
+}
+
 type (
 	dbSetting struct {
 		Model
 
-		Key   string  `gorm:"unique;index;NOT NULL"`
-		Value setting `gorm:"NOT NULL"`
+		Key     string  `gorm:"unique;index;NOT NULL"`
+		Value   setting `gorm:"NOT NULL"`
+		Version uint64  `gorm:"NOT NULL;default:1"`
+	}
+
+	// dbSettingHistory records a change to a setting, an empty value
+	// indicates the setting didn't exist before or was deleted.
+	dbSettingHistory struct {
+		Model
+
+		Key      string  `gorm:"index;NOT NULL"`
+		OldValue setting `gorm:"NOT NULL"`
+		NewValue setting `gorm:"NOT NULL"`
 	}
 )
 
 // TableName implements the gorm.Tabler interface.
 func (dbSetting) TableName() string { return "settings" }
 
+// TableName implements the gorm.Tabler interface.
+func (dbSettingHistory) TableName() string { return "settings_history" }
+
+func (h dbSettingHistory) convert() api.SettingHistoryEntry {
+	entry := api.SettingHistoryEntry{
+		Key:       h.Key,
+		Timestamp: h.CreatedAt.UTC(),
+	}
+	if h.OldValue != "" {
+		entry.OldValue = json.RawMessage(h.OldValue)
+	}
+	if h.NewValue != "" {
+		entry.NewValue = json.RawMessage(h.NewValue)
+	}
+	return entry
+}
+
 // DeleteSetting implements the bus.SettingStore interface.
 func (s *SQLStore) DeleteSetting(ctx context.Context, key string) error {
 	// Delete from cache.
@@ -30,7 +71,19 @@ func (s *SQLStore) DeleteSetting(ctx context.Context, key string) error {
 	s.settingsMu.Unlock()
 
 	// Delete from database.
-	return s.db.Where(&dbSetting{Key: key}).Delete(&dbSetting{}).Error
+	return s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		var entry dbSetting
+		err := tx.Where(&dbSetting{Key: key}).Take(&entry).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := tx.Delete(&entry).Error; err != nil {
+			return err
+		}
+		return recordSettingHistory(tx, key, entry.Value, "")
+	})
 }
 
 // Setting implements the bus.SettingStore interface.
@@ -56,6 +109,26 @@ func (s *SQLStore) Setting(ctx context.Context, key string) (string, error) {
 	return string(entry.Value), nil
 }
 
+// SettingHistory implements the bus.SettingStore interface.
+func (s *SQLStore) SettingHistory(ctx context.Context, key string) ([]api.SettingHistoryEntry, error) {
+	var entries []dbSettingHistory
+	err := s.db.
+		WithContext(ctx).
+		Where(&dbSettingHistory{Key: key}).
+		Order("id ASC").
+		Find(&entries).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]api.SettingHistoryEntry, len(entries))
+	for i, entry := range entries {
+		history[i] = entry.convert()
+	}
+	return history, nil
+}
+
 // Settings implements the bus.SettingStore interface.
 func (s *SQLStore) Settings(ctx context.Context) ([]string, error) {
 	var keys []string
@@ -65,17 +138,96 @@ func (s *SQLStore) Settings(ctx context.Context) ([]string, error) {
 
 // UpdateSetting implements the bus.SettingStore interface.
 func (s *SQLStore) UpdateSetting(ctx context.Context, key, value string) error {
+	return s.updateSetting(ctx, key, value, nil)
+}
+
+// UpdateSettingIfVersion implements the bus.SettingStore interface. The
+// setting is only updated if its current version matches the given version, a
+// version of 0 indicates the setting must not exist yet.
+func (s *SQLStore) UpdateSettingIfVersion(ctx context.Context, key, value string, version uint64) error {
+	return s.updateSetting(ctx, key, value, &version)
+}
+
+// VersionedSetting implements the bus.SettingStore interface.
+func (s *SQLStore) VersionedSetting(ctx context.Context, key string) (string, uint64, error) {
+	var entry dbSetting
+	err := s.db.
+		WithContext(ctx).
+		Where(&dbSetting{Key: key}).
+		Take(&entry).
+		Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", 0, fmt.Errorf("key '%s' err: %w", key, api.ErrSettingNotFound)
+	} else if err != nil {
+		return "", 0, err
+	}
+	return string(entry.Value), entry.Version, nil
+}
+
+func (s *SQLStore) updateSetting(ctx context.Context, key, value string, version *uint64) error {
+	// Validate the setting.
+	if err := api.ValidateSetting(key, []byte(value)); err != nil {
+		return err
+	}
+
 	// Update db first.
 	s.settingsMu.Lock()
 	defer s.settingsMu.Unlock()
 
-	err := s.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "key"}},
-		DoUpdates: clause.AssignmentColumns([]string{"value"}),
-	}).Create(&dbSetting{
-		Key:   key,
-		Value: setting(value),
-	}).Error
+	err := s.retryTransaction(ctx, func(tx *gorm.DB) error {
+		var old dbSetting
+		err := tx.Where(&dbSetting{Key: key}).Take(&old).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// nothing to do if the value doesn't change
+		unchanged := err == nil && old.Value == setting(value)
+		if unchanged && version == nil {
+			return nil
+		}
+
+		var res *gorm.DB
+		if version == nil {
+			res = tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "key"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"value":   setting(value),
+					"version": gorm.Expr("version + 1"),
+				}),
+			}).Create(&dbSetting{
+				Key:     key,
+				Value:   setting(value),
+				Version: 1,
+			})
+		} else if *version == 0 {
+			res = tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "key"}},
+				DoNothing: true,
+			}).Create(&dbSetting{
+				Key:     key,
+				Value:   setting(value),
+				Version: 1,
+			})
+		} else {
+			res = tx.Model(&dbSetting{}).
+				Where(&dbSetting{Key: key, Version: *version}).
+				Updates(map[string]interface{}{
+					"value":   setting(value),
+					"version": gorm.Expr("version + 1"),
+				})
+		}
+		if res.Error != nil {
+			return res.Error
+		} else if version != nil && res.RowsAffected == 0 {
+			return fmt.Errorf("key '%s' version %d err: %w", key, *version, api.ErrSettingConflict)
+		}
+
+		if unchanged {
+			return nil
+		}
+		return recordSettingHistory(tx, key, old.Value, setting(value))
+	})
 	if err != nil {
 		return err
 	}
@@ -84,3 +236,36 @@ func (s *SQLStore) UpdateSetting(ctx context.Context, key, value string) error {
 	s.settings[key] = value
 	return nil
 }
+
+// recordSettingHistory records a change to the setting with given key and
+// prunes the oldest entries that exceed the retention limit. Changes to secret
+// settings are not recorded.
+func recordSettingHistory(tx *gorm.DB, key string, oldValue, newValue setting) error {
+	if _, secret := secretSettings[key]; secret {
+		return nil
+	}
+	if err := tx.Create(&dbSettingHistory{
+		Key:      key,
+		OldValue: oldValue,
+		NewValue: newValue,
+	}).Error; err != nil {
+		return err
+	}
+
+	// prune entries beyond the retention limit
+	var cutoff []uint
+	if err := tx.Model(&dbSettingHistory{}).
+		Where(&dbSettingHistory{Key: key}).
+		Order("id DESC").
+		Offset(settingHistoryRetention-1).
+		Limit(1).
+		Pluck("id", &cutoff).
+		Error; err != nil {
+		return err
+	} else if len(cutoff) == 0 {
+		return nil
+	}
+	return tx.Where("`key` = ? AND id < ?", key, cutoff[0]).
+		Delete(&dbSettingHistory{}).
+		Error
+}
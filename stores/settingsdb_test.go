@@ -2,7 +2,11 @@ package stores
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
 	"go.sia.tech/renterd/api"
@@ -62,3 +66,189 @@ func TestSQLSettingStore(t *testing.T) {
 		t.Fatalf("unexpected number of settings, %v != 0", len(keys))
 	}
 }
+
+// TestSQLSettingStoreHistory asserts updates to a setting are recorded in the
+// setting's history.
+func TestSQLSettingStoreHistory(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// create, update and delete a setting
+	ctx := context.Background()
+	if err := ss.UpdateSetting(ctx, "foo", `"bar"`); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateSetting(ctx, "foo", `"baz"`); err != nil {
+		t.Fatal(err)
+	} else if err := ss.DeleteSetting(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert every write produced a history entry
+	history, err := ss.SettingHistory(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(history) != 3 {
+		t.Fatalf("unexpected number of history entries, %v != 3", len(history))
+	}
+
+	expected := []struct{ oldValue, newValue string }{
+		{"", `"bar"`},
+		{`"bar"`, `"baz"`},
+		{`"baz"`, ""},
+	}
+	for i, entry := range history {
+		if entry.Key != "foo" {
+			t.Fatalf("unexpected key, %s != 'foo'", entry.Key)
+		} else if string(entry.OldValue) != expected[i].oldValue {
+			t.Fatalf("unexpected old value for entry %d, %s != %s", i, entry.OldValue, expected[i].oldValue)
+		} else if string(entry.NewValue) != expected[i].newValue {
+			t.Fatalf("unexpected new value for entry %d, %s != %s", i, entry.NewValue, expected[i].newValue)
+		} else if entry.Timestamp.IsZero() {
+			t.Fatalf("entry %d has no timestamp", i)
+		}
+	}
+
+	// assert other settings have no history
+	if history, err := ss.SettingHistory(ctx, "bar"); err != nil {
+		t.Fatal(err)
+	} else if len(history) != 0 {
+		t.Fatalf("unexpected number of history entries, %v != 0", len(history))
+	}
+
+	// assert writes that don't change the value aren't recorded
+	if err := ss.UpdateSetting(ctx, "bar", `"bar"`); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateSetting(ctx, "bar", `"bar"`); err != nil {
+		t.Fatal(err)
+	} else if history, err := ss.SettingHistory(ctx, "bar"); err != nil {
+		t.Fatal(err)
+	} else if len(history) != 1 {
+		t.Fatalf("unexpected number of history entries, %v != 1", len(history))
+	}
+
+	// assert changes to secret settings aren't recorded
+	for i := 0; i < 2; i++ {
+		auth := fmt.Sprintf(`{"v4Keypairs":{"%s":"%s"}}`, strings.Repeat(fmt.Sprint(i), api.S3MinAccessKeyLen), strings.Repeat(fmt.Sprint(i), api.S3SecretKeyLen))
+		if err := ss.UpdateSetting(ctx, api.SettingS3Authentication, auth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history, err := ss.SettingHistory(ctx, api.SettingS3Authentication); err != nil {
+		t.Fatal(err)
+	} else if len(history) != 0 {
+		t.Fatalf("unexpected number of history entries, %v != 0", len(history))
+	}
+
+	// assert the history is pruned beyond the retention limit
+	for i := 0; i < settingHistoryRetention+10; i++ {
+		if err := ss.UpdateSetting(ctx, "baz", fmt.Sprint(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if history, err := ss.SettingHistory(ctx, "baz"); err != nil {
+		t.Fatal(err)
+	} else if len(history) != settingHistoryRetention {
+		t.Fatalf("unexpected number of history entries, %v != %v", len(history), settingHistoryRetention)
+	} else if last := history[len(history)-1]; string(last.NewValue) != fmt.Sprint(settingHistoryRetention+9) {
+		t.Fatalf("unexpected last entry %s", last.NewValue)
+	}
+}
+
+// TestSQLSettingStoreValidation asserts settings with a known key are validated
+// before they are persisted.
+func TestSQLSettingStoreValidation(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// write an invalid redundancy setting
+	ctx := context.Background()
+	invalid, _ := json.Marshal(api.RedundancySettings{MinShards: 10, TotalShards: 5})
+	if err := ss.UpdateSetting(ctx, api.SettingRedundancy, string(invalid)); !errors.Is(err, api.ErrInvalidSetting) {
+		t.Fatal("expected ErrInvalidSetting, got", err)
+	} else if _, err := ss.Setting(ctx, api.SettingRedundancy); !errors.Is(err, api.ErrSettingNotFound) {
+		t.Fatal("expected setting to not be persisted, got", err)
+	}
+
+	// write a malformed redundancy setting
+	if err := ss.UpdateSetting(ctx, api.SettingRedundancy, `"foo"`); !errors.Is(err, api.ErrInvalidSetting) {
+		t.Fatal("expected ErrInvalidSetting, got", err)
+	}
+
+	// write a valid redundancy setting
+	valid, _ := json.Marshal(api.RedundancySettings{MinShards: 10, TotalShards: 30})
+	if err := ss.UpdateSetting(ctx, api.SettingRedundancy, string(valid)); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert unknown keys are not validated
+	if err := ss.UpdateSetting(ctx, "foo", "bar"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSQLSettingStoreCAS asserts that out of two concurrent conditional
+// updates to the same version of a setting only one succeeds.
+func TestSQLSettingStoreCAS(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// create the setting
+	ctx := context.Background()
+	if err := ss.UpdateSettingIfVersion(ctx, "foo", `"bar"`, 0); err != nil {
+		t.Fatal(err)
+	} else if err := ss.UpdateSettingIfVersion(ctx, "foo", `"bar"`, 0); !errors.Is(err, api.ErrSettingConflict) {
+		t.Fatal("expected ErrSettingConflict, got", err)
+	}
+
+	// fetch its version
+	_, version, err := ss.VersionedSetting(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	} else if version != 1 {
+		t.Fatalf("unexpected version, %v != 1", version)
+	}
+
+	// perform two concurrent updates
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ss.UpdateSettingIfVersion(ctx, "foo", fmt.Sprintf(`"baz%d"`, i), version)
+		}(i)
+	}
+	wg.Wait()
+
+	// assert one succeeded and one conflicted
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, api.ErrSettingConflict) {
+			t.Fatal("expected ErrSettingConflict, got", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("expected exactly one update to succeed, got %v", succeeded)
+	}
+
+	// assert the version was incremented
+	value, version, err := ss.VersionedSetting(ctx, "foo")
+	if err != nil {
+		t.Fatal(err)
+	} else if version != 2 {
+		t.Fatalf("unexpected version, %v != 2", version)
+	} else if value != `"baz0"` && value != `"baz1"` {
+		t.Fatalf("unexpected value, %s", value)
+	}
+
+	// assert unconditional updates increment the version as well
+	if err := ss.UpdateSetting(ctx, "foo", `"qux"`); err != nil {
+		t.Fatal(err)
+	} else if _, version, err := ss.VersionedSetting(ctx, "foo"); err != nil {
+		t.Fatal(err)
+	} else if version != 3 {
+		t.Fatalf("unexpected version, %v != 3", version)
+	}
+}
@@ -30,11 +30,14 @@ type SlabBuffer struct {
 	slabKey  object.EncryptionKey
 	maxSize  int64
 
-	mu          sync.Mutex
-	file        *os.File
-	lockedUntil time.Time
-	size        int64
-	syncErr     error
+	mu             sync.Mutex
+	created        time.Time
+	file           *os.File
+	lockedUntil    time.Time
+	pendingAppends int
+	sealed         bool
+	size           int64
+	syncErr        error
 }
 
 type bufferGroupID [6]byte
@@ -122,6 +125,7 @@ func newSlabBufferManager(sqlStore *SQLStore, slabBufferCompletionThreshold int6
 			filename: buffer.Filename,
 			slabKey:  ec,
 			maxSize:  int64(bufferedSlabSize(buffer.DBSlab.MinShards)),
+			created:  buffer.CreatedAt,
 			file:     file,
 			size:     size,
 		}
@@ -186,10 +190,20 @@ func (mgr *SlabBufferManager) AddPartialSlab(ctx context.Context, data []byte, m
 	var slabs []object.SlabSlice
 	var err error
 	var usedBuffers []*SlabBuffer
+
+	// Every append we record is pending until it's committed, buffers with
+	// pending appends aren't sealed.
+	abortAppends := func(buffers []*SlabBuffer) {
+		for _, buffer := range buffers {
+			buffer.abortAppend()
+		}
+	}
+
 	for _, buffer := range buffers {
 		var used bool
 		slab, data, used, err = buffer.recordAppend(data, len(usedBuffers) > 0, minShards, mgr.bufferedSlabCompletionThreshold)
 		if err != nil {
+			abortAppends(usedBuffers)
 			return nil, 0, err
 		}
 		if used {
@@ -209,11 +223,13 @@ func (mgr *SlabBufferManager) AddPartialSlab(ctx context.Context, data []byte, m
 			return err
 		})
 		if err != nil {
+			abortAppends(usedBuffers)
 			return nil, 0, err
 		}
 		var used bool
 		slab, data, used, err = sb.recordAppend(data, true, minShards, mgr.bufferedSlabCompletionThreshold)
 		if err != nil {
+			abortAppends(usedBuffers)
 			return nil, 0, err
 		}
 		if len(data) > 0 || !used {
@@ -230,9 +246,10 @@ func (mgr *SlabBufferManager) AddPartialSlab(ctx context.Context, data []byte, m
 	}
 
 	// Commit all used buffers to disk.
-	for _, buffer := range usedBuffers {
+	for i, buffer := range usedBuffers {
 		complete, err := buffer.commitAppend(mgr.bufferedSlabCompletionThreshold)
 		if err != nil {
+			abortAppends(usedBuffers[i+1:])
 			return nil, 0, err
 		}
 		// Move the buffer from incomplete to complete if it is now complete.
@@ -311,10 +328,11 @@ func (mgr *SlabBufferManager) SlabsForUpload(ctx context.Context, lockingDuratio
 	mgr.mu.Unlock()
 
 	for _, buffer := range buffers {
-		if !buffer.acquireForUpload(lockingDuration) {
+		size, acquired := buffer.acquireForUpload(lockingDuration)
+		if !acquired {
 			continue
 		}
-		data := make([]byte, buffer.size)
+		data := make([]byte, size)
 		_, err := buffer.file.ReadAt(data, 0)
 		if err != nil {
 			mgr.s.alerts.RegisterAlert(ctx, alerts.Alert{
@@ -372,14 +390,22 @@ func (mgr *SlabBufferManager) RemoveBuffers(fileNames ...string) {
 	}
 }
 
-func (buf *SlabBuffer) acquireForUpload(lockingDuration time.Duration) bool {
+func (buf *SlabBuffer) acquireForUpload(lockingDuration time.Duration) (int64, bool) {
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
 	if time.Now().Before(buf.lockedUntil) {
-		return false
+		return 0, false
 	}
 	buf.lockedUntil = time.Now().Add(lockingDuration)
-	return true
+	return buf.size, true
+}
+
+// abortAppend marks an append that was recorded but won't be committed as no
+// longer pending.
+func (buf *SlabBuffer) abortAppend() {
+	buf.mu.Lock()
+	defer buf.mu.Unlock()
+	buf.pendingAppends--
 }
 
 func isCompleteBuffer(size, maxSize, completionThreshold int64) bool {
@@ -390,7 +416,7 @@ func (buf *SlabBuffer) recordAppend(data []byte, mustFit bool, minShards uint8,
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
 	remainingSpace := buf.maxSize - buf.size
-	if isCompleteBuffer(buf.size, buf.maxSize, completionThreshold) {
+	if buf.sealed || isCompleteBuffer(buf.size, buf.maxSize, completionThreshold) {
 		return object.SlabSlice{}, data, false, nil
 	} else if int64(len(data)) <= remainingSpace {
 		_, err := buf.file.WriteAt(data, buf.size)
@@ -403,6 +429,7 @@ func (buf *SlabBuffer) recordAppend(data []byte, mustFit bool, minShards uint8,
 			Length: uint32(len(data)),
 		}
 		buf.size += int64(len(data))
+		buf.pendingAppends++
 		return slab, nil, true, nil
 	} else if !mustFit {
 		_, err := buf.file.WriteAt(data[:remainingSpace], buf.size)
@@ -415,6 +442,7 @@ func (buf *SlabBuffer) recordAppend(data []byte, mustFit bool, minShards uint8,
 			Length: uint32(remainingSpace),
 		}
 		buf.size += remainingSpace
+		buf.pendingAppends++
 		return slab, data[remainingSpace:], true, nil
 	} else {
 		return object.SlabSlice{}, data, false, nil
@@ -426,6 +454,7 @@ func (buf *SlabBuffer) commitAppend(completionThreshold int64) (bool, error) {
 	// buffer up to this point upon success.
 	buf.mu.Lock()
 	if buf.syncErr != nil {
+		buf.pendingAppends--
 		buf.mu.Unlock()
 		return false, buf.syncErr
 	}
@@ -437,10 +466,40 @@ func (buf *SlabBuffer) commitAppend(completionThreshold int64) (bool, error) {
 
 	buf.mu.Lock()
 	defer buf.mu.Unlock()
+	buf.pendingAppends--
 	buf.syncErr = err
 	return isCompleteBuffer(syncSize, buf.maxSize, completionThreshold), nil
 }
 
+// SealStaleBuffers marks all incomplete buffers that were created more than
+// 'maxAge' ago as complete. Sealed buffers no longer accept new data and are
+// returned by SlabsForUpload even though they are not full, this makes sure
+// small objects don't linger in the buffers indefinitely. Buffers with appends
+// that weren't committed yet are sealed on a later call.
+func (mgr *SlabBufferManager) SealStaleBuffers(maxAge time.Duration) (sealed int) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	for gid, buffers := range mgr.incompleteBuffers {
+		var incomplete []*SlabBuffer
+		for _, buffer := range buffers {
+			// NOTE: the buffer is locked while it's sealed and moved to the
+			// complete buffers, that way no append can sneak in
+			buffer.mu.Lock()
+			if buffer.size > 0 && buffer.pendingAppends == 0 && time.Since(buffer.created) >= maxAge {
+				buffer.sealed = true
+				mgr.completeBuffers[gid] = append(mgr.completeBuffers[gid], buffer)
+				sealed++
+			} else {
+				incomplete = append(incomplete, buffer)
+			}
+			buffer.mu.Unlock()
+		}
+		mgr.incompleteBuffers[gid] = incomplete
+	}
+	return
+}
+
 func (mgr *SlabBufferManager) markBufferComplete(buffer *SlabBuffer, gid bufferGroupID) error {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
@@ -498,6 +557,7 @@ func createSlabBuffer(tx *gorm.DB, contractSetID uint, dir string, minShards, to
 		filename: fileName,
 		slabKey:  ec,
 		maxSize:  int64(bufferedSlabSize(minShards)),
+		created:  createdSlab.CreatedAt,
 		file:     file,
 	}, err
 }
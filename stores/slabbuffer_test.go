@@ -1,10 +1,14 @@
 package stores
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
+	"go.sia.tech/renterd/object"
 	"lukechampine.com/frand"
 )
 
@@ -113,3 +117,103 @@ func TestMarkBufferCompleteTwice(t *testing.T) {
 		t.Fatal("expected error marking buffer complete twice", err)
 	}
 }
+
+func TestSealStaleBuffers(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// upload several small objects
+	ctx := context.Background()
+	var datas [][]byte
+	var offset uint32
+	var slabKey object.EncryptionKey
+	for i := 0; i < 3; i++ {
+		data := frand.Bytes(64 * (i + 1))
+		datas = append(datas, data)
+
+		slices, _, err := ss.AddPartialSlab(ctx, data, 1, 2, testContractSet)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(slices) != 1 {
+			t.Fatalf("expected 1 slice, got %d", len(slices))
+		}
+
+		obj, err := ss.addTestObject(fmt.Sprintf("obj%d", i), object.Object{
+			Key:   object.GenerateEncryptionKey(),
+			Slabs: slices,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// assert the object's slice points to its data in the shared slab
+		slice := obj.Object.Slabs[0]
+		if slice.Offset != offset || slice.Length != uint32(len(data)) {
+			t.Fatalf("unexpected slice %v-%v, expected %v-%v", slice.Offset, slice.Length, offset, len(data))
+		} else if i == 0 {
+			slabKey = slice.Key
+		} else if slice.Key.String() != slabKey.String() {
+			t.Fatal("expected objects to share a slab")
+		}
+		offset += uint32(len(data))
+	}
+
+	// assert there's nothing to upload yet
+	packed, err := ss.PackedSlabsForUpload(ctx, time.Minute, 1, 2, testContractSet, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(packed) != 0 {
+		t.Fatalf("expected no packed slabs, got %d", len(packed))
+	}
+
+	// assert young buffers aren't sealed
+	if sealed := ss.slabBufferMgr.SealStaleBuffers(time.Hour); sealed != 0 {
+		t.Fatalf("expected no buffers to be sealed, got %d", sealed)
+	}
+
+	// assert the buffer's creation time survives a restart
+	buffer := ss.slabBufferMgr.buffersByKey[slabKey.String()]
+	if err := ss.db.Model(&dbBufferedSlab{}).Where("id", buffer.dbID).Update("created_at", time.Now().Add(-time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := newSlabBufferManager(ss.SQLStore, ss.slabBufferMgr.bufferedSlabCompletionThreshold, ss.slabBufferMgr.dir)
+	if err != nil {
+		t.Fatal(err)
+	} else if created := mgr.buffersByKey[slabKey.String()].created; time.Since(created) < time.Hour {
+		t.Fatalf("expected buffer to be created an hour ago, got %v", created)
+	} else if err := mgr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert buffers with pending appends aren't sealed
+	buffer.mu.Lock()
+	buffer.pendingAppends++
+	buffer.mu.Unlock()
+	if sealed := ss.slabBufferMgr.SealStaleBuffers(0); sealed != 0 {
+		t.Fatalf("expected no buffers to be sealed, got %d", sealed)
+	}
+	buffer.abortAppend()
+
+	// seal the buffer and assert it's ready for upload
+	if sealed := ss.slabBufferMgr.SealStaleBuffers(0); sealed != 1 {
+		t.Fatalf("expected 1 buffer to be sealed, got %d", sealed)
+	}
+	packed, err = ss.PackedSlabsForUpload(ctx, time.Minute, 1, 2, testContractSet, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(packed) != 1 {
+		t.Fatalf("expected 1 packed slab, got %d", len(packed))
+	} else if !bytes.Equal(packed[0].Data, bytes.Join(datas, nil)) {
+		t.Fatal("unexpected data")
+	} else if packed[0].Key.String() != slabKey.String() {
+		t.Fatal("unexpected slab key")
+	}
+
+	// assert new data doesn't end up in the sealed buffer
+	slices, _, err := ss.AddPartialSlab(ctx, frand.Bytes(64), 1, 2, testContractSet)
+	if err != nil {
+		t.Fatal(err)
+	} else if slices[0].Key.String() == packed[0].Key.String() || slices[0].Offset != 0 {
+		t.Fatal("expected data to be added to a new buffer")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/alerts"
 	"go.sia.tech/renterd/api"
+	isql "go.sia.tech/renterd/internal/sql"
 	"go.sia.tech/renterd/stores/sql"
 	"go.sia.tech/renterd/stores/sql/mysql"
 	"go.sia.tech/renterd/stores/sql/sqlite"
@@ -32,6 +33,10 @@ const (
 
 var (
 	exprTRUE = gorm.Expr("TRUE")
+
+	// ErrMigrationsDryRun is returned by NewSQLStore after the pending
+	// migrations were logged in dry-run mode.
+	ErrMigrationsDryRun = errors.New("migrations were planned but not applied")
 )
 
 type (
@@ -51,13 +56,25 @@ type (
 		Migrate                       bool
 		AnnouncementMaxAge            time.Duration
 		PersistInterval               time.Duration
-		WalletAddress                 types.Address
+		WalletAddresses               []types.Address
 		SlabBufferCompletionThreshold int64
+		SlabBufferMaxAge              time.Duration
 		Logger                        *zap.SugaredLogger
 		GormLogger                    glogger.Interface
 		RetryTransactionIntervals     []time.Duration
 		LongQueryDuration             time.Duration
 		LongTxDuration                time.Duration
+		MigrationBackupPath           string
+
+		// MigrationsDryRun causes the pending migrations to be logged
+		// without applying them, NewSQLStore returns ErrMigrationsDryRun
+		// afterwards.
+		MigrationsDryRun bool
+
+		// HostCacheSize and HostCacheTTL configure the cache for hosts
+		// fetched by their public key, a zero value disables caching.
+		HostCacheSize int
+		HostCacheTTL  time.Duration
 	}
 
 	// SQLStore is a helper type for interacting with a SQL-based backend.
@@ -88,13 +105,14 @@ type (
 
 		// HostDB related fields
 		announcementMaxAge time.Duration
+		hostCache          *hostCache
 
 		// SettingsDB related fields.
 		settingsMu sync.Mutex
 		settings   map[string]string
 
 		// WalletDB related fields.
-		walletAddress types.Address
+		walletAddresses map[types.Address]struct{}
 
 		// Consensus related fields.
 		ccid       modules.ConsensusChangeID
@@ -216,11 +234,30 @@ func NewSQLStore(cfg Config) (*SQLStore, modules.ConsensusChangeID, error) {
 
 	// Perform migrations.
 	if cfg.Migrate {
-		if err := dbMain.Migrate(); err != nil {
+		var opts isql.MigrationOptions
+		if cfg.MigrationBackupPath != "" {
+			opts.Backup = func(ctx context.Context) error {
+				return dbMain.Backup(ctx, cfg.MigrationBackupPath)
+			}
+		}
+		opts.DryRun = cfg.MigrationsDryRun
+		plan, err := dbMain.Migrate(context.Background(), opts)
+		if err != nil {
 			return nil, modules.ConsensusChangeID{}, fmt.Errorf("failed to perform migrations: %v", err)
-		} else if err := bMetrics.Migrate(); err != nil {
+		}
+		planMetrics, err := bMetrics.Migrate(context.Background(), isql.MigrationOptions{DryRun: cfg.MigrationsDryRun})
+		if err != nil {
 			return nil, modules.ConsensusChangeID{}, fmt.Errorf("failed to perform migrations for metrics db: %v", err)
 		}
+		if cfg.MigrationsDryRun {
+			for _, step := range plan {
+				l.Infof("planned migration step for main db: %v", step)
+			}
+			for _, step := range planMetrics {
+				l.Infof("planned migration step for metrics db: %v", step)
+			}
+			return nil, modules.ConsensusChangeID{}, ErrMigrationsDryRun
+		}
 	}
 
 	// Get latest consensus change ID or init db.
@@ -278,8 +315,9 @@ func NewSQLStore(cfg Config) (*SQLStore, modules.ConsensusChangeID, error) {
 		unappliedProofs:        make(map[types.FileContractID]uint64),
 
 		announcementMaxAge: cfg.AnnouncementMaxAge,
+		hostCache:          newHostCache(cfg.HostCacheSize, cfg.HostCacheTTL),
 
-		walletAddress: cfg.WalletAddress,
+		walletAddresses: make(map[types.Address]struct{}),
 		chainIndex: types.ChainIndex{
 			Height: ci.Height,
 			ID:     types.BlockID(ci.BlockID),
@@ -291,6 +329,9 @@ func NewSQLStore(cfg Config) (*SQLStore, modules.ConsensusChangeID, error) {
 		shutdownCtx:       shutdownCtx,
 		shutdownCtxCancel: shutdownCtxCancel,
 	}
+	for _, addr := range cfg.WalletAddresses {
+		ss.walletAddresses[addr] = struct{}{}
+	}
 
 	ss.slabBufferMgr, err = newSlabBufferManager(ss, cfg.SlabBufferCompletionThreshold, cfg.PartialSlabDir)
 	if err != nil {
@@ -299,6 +340,7 @@ func NewSQLStore(cfg Config) (*SQLStore, modules.ConsensusChangeID, error) {
 	if err := ss.initSlabPruning(); err != nil {
 		return nil, modules.ConsensusChangeID{}, err
 	}
+	ss.initSlabBufferSealing(cfg.SlabBufferMaxAge)
 	return ss, ccid, nil
 }
 
@@ -331,6 +373,19 @@ func (s *SQLStore) initSlabPruning() error {
 	return s.retryTransaction(s.shutdownCtx, pruneSlabs)
 }
 
+func (s *SQLStore) initSlabBufferSealing(maxAge time.Duration) {
+	if maxAge == 0 {
+		return // disabled
+	}
+
+	// start sealing loop
+	s.wg.Add(1)
+	go func() {
+		s.sealSlabBuffersLoop(maxAge)
+		s.wg.Done()
+	}()
+}
+
 func (ss *SQLStore) updateHasAllowlist(err *error) {
 	if *err != nil {
 		return
@@ -531,6 +586,11 @@ func (ss *SQLStore) applyUpdates(force bool) error {
 		return fmt.Errorf("%w; failed to apply updates", err)
 	}
 
+	// invalidate the cached hosts that were announced
+	for hk := range ss.unappliedHostKeys {
+		ss.hostCache.Invalidate(hk)
+	}
+
 	ss.unappliedContractState = make(map[types.FileContractID]contractState)
 	ss.unappliedProofs = make(map[types.FileContractID]uint64)
 	ss.unappliedRevisions = make(map[types.FileContractID]revisionUpdate)
@@ -562,7 +622,8 @@ func (s *SQLStore) retryTransaction(ctx context.Context, fc func(tx *gorm.DB) er
 			strings.Contains(err.Error(), "no such table") ||
 			strings.Contains(err.Error(), "Duplicate entry") ||
 			errors.Is(err, api.ErrPartNotFound) ||
-			errors.Is(err, api.ErrSlabNotFound) {
+			errors.Is(err, api.ErrSlabNotFound) ||
+			errors.Is(err, api.ErrSettingConflict) {
 			return true
 		}
 		return false
@@ -608,6 +669,23 @@ func initConsensusInfo(db *gorm.DB) (dbConsensusInfo, modules.ConsensusChangeID,
 	return ci, ccid, nil
 }
 
+// ChainIndex returns the chain index up to which the store has processed
+// consensus changes, as persisted in the database.
+func (s *SQLStore) ChainIndex(ctx context.Context) (types.ChainIndex, error) {
+	var ci dbConsensusInfo
+	if err := s.db.
+		WithContext(ctx).
+		Where(&dbConsensusInfo{Model: Model{ID: consensusInfoID}}).
+		Take(&ci).
+		Error; err != nil {
+		return types.ChainIndex{}, err
+	}
+	return types.ChainIndex{
+		Height: ci.Height,
+		ID:     types.BlockID(ci.BlockID),
+	}, nil
+}
+
 func (s *SQLStore) ResetConsensusSubscription(ctx context.Context) error {
 	// empty tables and reinit consensus_infos
 	var ci dbConsensusInfo
@@ -636,6 +714,26 @@ func (s *SQLStore) ResetConsensusSubscription(ctx context.Context) error {
 	return nil
 }
 
+// IsEmpty returns true if the store holds no contracts and no wallet data,
+// such a store can safely be subscribed to consensus from a checkpoint since
+// there is no data that references earlier heights.
+func (s *SQLStore) IsEmpty(ctx context.Context) (bool, error) {
+	for _, model := range []interface{}{
+		&dbContract{},
+		&dbArchivedContract{},
+		&dbSiacoinElement{},
+		&dbTransaction{},
+	} {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(model).Limit(1).Count(&count).Error; err != nil {
+			return false, err
+		} else if count > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func sumDurations(durations []time.Duration) time.Duration {
 	var sum time.Duration
 	for _, d := range durations {
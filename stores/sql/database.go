@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 
+	isql "go.sia.tech/renterd/internal/sql"
 	"go.sia.tech/renterd/stores/sql/mysql"
 	"go.sia.tech/renterd/stores/sql/sqlite"
 )
@@ -19,13 +20,14 @@ var _ MetricsDatabase = (*sqlite.MetricsDatabase)(nil)
 type (
 	Database interface {
 		io.Closer
-		Migrate() error
+		Backup(ctx context.Context, path string) error
+		Migrate(ctx context.Context, opts isql.MigrationOptions) ([]string, error)
 		Version(ctx context.Context) (string, string, error)
 	}
 
 	MetricsDatabase interface {
 		io.Closer
-		Migrate() error
+		Migrate(ctx context.Context, opts isql.MigrationOptions) ([]string, error)
 		Version(ctx context.Context) (string, string, error)
 	}
 )
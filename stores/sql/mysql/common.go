@@ -21,6 +21,7 @@ func createMigrationTable(db *sql.DB) error {
 	if _, err := db.Exec(`
 			CREATE TABLE IF NOT EXISTS migrations (
 				id varchar(255) NOT NULL,
+				applied_at datetime(3) NULL,
 				PRIMARY KEY (id)
 			) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;`); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
@@ -28,6 +29,11 @@ func createMigrationTable(db *sql.DB) error {
 	return nil
 }
 
+func hasMigrationTable(db *sql.DB) (exists bool, err error) {
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'migrations')").Scan(&exists)
+	return
+}
+
 func version(db *sql.DB) (string, string, error) {
 	var version string
 	if err := db.QueryRow("select version()").Scan(&version); err != nil {
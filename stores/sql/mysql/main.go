@@ -31,6 +31,13 @@ func (b *MainDatabase) ApplyMigration(fn func(tx sql.Tx) (bool, error)) error {
 	return applyMigration(b.db, fn)
 }
 
+// Backup is a no-op for MySQL databases, which are expected to be backed up by
+// the operator.
+func (b *MainDatabase) Backup(_ context.Context, _ string) error {
+	b.log.Warn("backing up MySQL databases is not supported, make sure to back up the database manually before upgrading")
+	return nil
+}
+
 func (b *MainDatabase) Close() error {
 	return b.db.Close()
 }
@@ -89,8 +96,16 @@ func (b *MainDatabase) MakeDirsForPath(tx sql.Tx, path string) (uint, error) {
 	return dirID, nil
 }
 
-func (b *MainDatabase) Migrate() error {
-	return sql.PerformMigrations(b, migrationsFs, "main", sql.MainMigrations(b, migrationsFs, b.log))
+func (b *MainDatabase) HasMigrationTable() (bool, error) {
+	return hasMigrationTable(b.db)
+}
+
+func (b *MainDatabase) SupportsTransactionalDDL() bool {
+	return false
+}
+
+func (b *MainDatabase) Migrate(ctx context.Context, opts sql.MigrationOptions) ([]string, error) {
+	return sql.PerformMigrations(ctx, b, migrationsFs, "main", sql.MainMigrations(b, migrationsFs, b.log), b.log, opts)
 }
 
 func (b *MainDatabase) Version(_ context.Context) (string, string, error) {
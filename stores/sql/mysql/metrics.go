@@ -41,8 +41,16 @@ func (b *MetricsDatabase) CreateMigrationTable() error {
 	return createMigrationTable(b.db)
 }
 
-func (b *MetricsDatabase) Migrate() error {
-	return sql.PerformMigrations(b, migrationsFs, "metrics", sql.MetricsMigrations(migrationsFs, b.log))
+func (b *MetricsDatabase) HasMigrationTable() (bool, error) {
+	return hasMigrationTable(b.db)
+}
+
+func (b *MetricsDatabase) SupportsTransactionalDDL() bool {
+	return false
+}
+
+func (b *MetricsDatabase) Migrate(ctx context.Context, opts sql.MigrationOptions) ([]string, error) {
+	return sql.PerformMigrations(ctx, b, migrationsFs, "metrics", sql.MetricsMigrations(migrationsFs, b.log), b.log, opts)
 }
 
 func (b *MetricsDatabase) Version(_ context.Context) (string, string, error) {
@@ -0,0 +1 @@
+ALTER TABLE `ephemeral_accounts` ADD COLUMN `drifted` tinyint(1) DEFAULT '0';
@@ -0,0 +1 @@
+CREATE INDEX `idx_siacoin_elements_address` ON `siacoin_elements`(`address`);
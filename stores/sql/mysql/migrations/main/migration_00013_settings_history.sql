@@ -0,0 +1,10 @@
+-- dbSettingHistory
+CREATE TABLE IF NOT EXISTS `settings_history` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `key` varchar(191) NOT NULL,
+  `old_value` longtext NOT NULL,
+  `new_value` longtext NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_settings_history_key` (`key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
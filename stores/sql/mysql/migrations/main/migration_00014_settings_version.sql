@@ -0,0 +1 @@
+ALTER TABLE `settings` ADD COLUMN `version` bigint unsigned NOT NULL DEFAULT '1';
@@ -0,0 +1,4 @@
+ALTER TABLE `contracts` ADD COLUMN `storage_spending` longtext AFTER `list_spending`;
+ALTER TABLE `archived_contracts` ADD COLUMN `storage_spending` longtext AFTER `list_spending`;
+UPDATE `contracts` SET `storage_spending` = '0';
+UPDATE `archived_contracts` SET `storage_spending` = '0';
@@ -0,0 +1,3 @@
+ALTER TABLE `hosts` ADD COLUMN `successful_operations` double DEFAULT 0;
+ALTER TABLE `hosts` ADD COLUMN `failed_operations` double DEFAULT 0;
+ALTER TABLE `host_checks` ADD COLUMN `score_success_rate` double NOT NULL DEFAULT 1;
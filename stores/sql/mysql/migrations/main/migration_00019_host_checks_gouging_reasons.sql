@@ -0,0 +1 @@
+ALTER TABLE `host_checks` ADD COLUMN `gouging_reasons` text AFTER `gouging_upload_err`;
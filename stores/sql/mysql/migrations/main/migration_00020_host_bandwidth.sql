@@ -0,0 +1,2 @@
+ALTER TABLE `hosts` ADD COLUMN `uploaded_bytes` bigint unsigned NOT NULL DEFAULT 0;
+ALTER TABLE `hosts` ADD COLUMN `downloaded_bytes` bigint unsigned NOT NULL DEFAULT 0;
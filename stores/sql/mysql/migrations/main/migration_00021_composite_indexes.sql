@@ -0,0 +1,8 @@
+-- speeds up fetching the slices of an object in order, the composite index
+-- covers the foreign key on db_object_id so the single column index is dropped
+CREATE INDEX `idx_slices_db_object_id_object_index` ON `slices`(`db_object_id`,`object_index`);
+DROP INDEX `idx_slices_db_object_id` ON `slices`;
+
+-- InnoDB secondary indices include the primary key so contract_sectors doesn't
+-- need a composite index on (db_contract_id, db_sector_id), the existing index
+-- on db_contract_id is kept since the foreign key requires it
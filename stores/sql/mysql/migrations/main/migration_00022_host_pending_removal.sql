@@ -0,0 +1 @@
+ALTER TABLE `hosts` ADD COLUMN `pending_removal_since` bigint NOT NULL DEFAULT 0;
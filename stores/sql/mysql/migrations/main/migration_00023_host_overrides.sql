@@ -0,0 +1,11 @@
+-- dbHostOverride
+CREATE TABLE IF NOT EXISTS `host_overrides` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `public_key` varbinary(32) NOT NULL,
+  `force_include` tinyint(1) NOT NULL DEFAULT '0',
+  `force_exclude` tinyint(1) NOT NULL DEFAULT '0',
+  `score_multiplier` double NOT NULL DEFAULT '1',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `public_key` (`public_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,2 @@
+ALTER TABLE `transactions` ADD COLUMN `type` varchar(191) NOT NULL DEFAULT '';
+CREATE INDEX `idx_transactions_type` ON `transactions`(`type`);
@@ -19,6 +19,7 @@ CREATE TABLE `archived_contracts` (
   `fund_account_spending` longtext,
   `delete_spending` longtext,
   `list_spending` longtext,
+  `storage_spending` longtext,
   `renewed_to` varbinary(32) DEFAULT NULL,
   `host` varbinary(32) NOT NULL,
   `reason` longtext,
@@ -93,8 +94,13 @@ CREATE TABLE `hosts` (
   `downtime` bigint DEFAULT NULL,
   `recent_downtime` bigint DEFAULT NULL,
   `recent_scan_failures` bigint unsigned DEFAULT NULL,
+  `pending_removal_since` bigint NOT NULL DEFAULT 0,
   `successful_interactions` double DEFAULT NULL,
   `failed_interactions` double DEFAULT NULL,
+  `successful_operations` double DEFAULT 0,
+  `failed_operations` double DEFAULT 0,
+  `uploaded_bytes` bigint unsigned NOT NULL DEFAULT 0,
+  `downloaded_bytes` bigint unsigned NOT NULL DEFAULT 0,
   `lost_sectors` bigint unsigned DEFAULT NULL,
   `last_announcement` datetime(3) DEFAULT NULL,
   `net_address` varchar(191) DEFAULT NULL,
@@ -129,6 +135,7 @@ CREATE TABLE `contracts` (
   `fund_account_spending` longtext,
   `delete_spending` longtext,
   `list_spending` longtext,
+  `storage_spending` longtext,
   `host_id` bigint unsigned DEFAULT NULL,
   PRIMARY KEY (`id`),
   UNIQUE KEY `fcid` (`fcid`),
@@ -224,6 +231,7 @@ CREATE TABLE `ephemeral_accounts` (
   `host` longblob NOT NULL,
   `balance` longtext,
   `drift` longtext,
+  `drifted` tinyint(1) DEFAULT '0',
   `requires_sync` tinyint(1) DEFAULT NULL,
   PRIMARY KEY (`id`),
   UNIQUE KEY `account_id` (`account_id`),
@@ -250,6 +258,18 @@ CREATE TABLE `host_allowlist_entry_hosts` (
   CONSTRAINT `fk_host_allowlist_entry_hosts_db_host` FOREIGN KEY (`db_host_id`) REFERENCES `hosts` (`id`) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
+-- dbHostOverride
+CREATE TABLE `host_overrides` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `public_key` varbinary(32) NOT NULL,
+  `force_include` tinyint(1) NOT NULL DEFAULT '0',
+  `force_exclude` tinyint(1) NOT NULL DEFAULT '0',
+  `score_multiplier` double NOT NULL DEFAULT '1',
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `public_key` (`public_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
 -- dbHostAnnouncement
 CREATE TABLE `host_announcements` (
   `id` bigint unsigned NOT NULL AUTO_INCREMENT,
@@ -356,11 +376,23 @@ CREATE TABLE `settings` (
   `created_at` datetime(3) DEFAULT NULL,
   `key` varchar(191) NOT NULL,
   `value` longtext NOT NULL,
+  `version` bigint unsigned NOT NULL DEFAULT '1',
   PRIMARY KEY (`id`),
   UNIQUE KEY `key` (`key`),
   KEY `idx_settings_key` (`key`)
 ) ENGINE=InnoDB AUTO_INCREMENT=5 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
+-- dbSettingHistory
+CREATE TABLE `settings_history` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `created_at` datetime(3) DEFAULT NULL,
+  `key` varchar(191) NOT NULL,
+  `old_value` longtext NOT NULL,
+  `new_value` longtext NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_settings_history_key` (`key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
 -- dbSiacoinElement
 CREATE TABLE `siacoin_elements` (
   `id` bigint unsigned NOT NULL AUTO_INCREMENT,
@@ -372,7 +404,8 @@ CREATE TABLE `siacoin_elements` (
   PRIMARY KEY (`id`),
   UNIQUE KEY `output_id` (`output_id`),
   KEY `idx_siacoin_elements_output_id` (`output_id`),
-  KEY `idx_siacoin_elements_maturity_height` (`maturity_height`)
+  KEY `idx_siacoin_elements_maturity_height` (`maturity_height`),
+  KEY `idx_siacoin_elements_address` (`address`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
 -- dbSlice
@@ -386,10 +419,10 @@ CREATE TABLE `slices` (
   `offset` int unsigned DEFAULT NULL,
   `length` int unsigned DEFAULT NULL,
   PRIMARY KEY (`id`),
-  KEY `idx_slices_db_object_id` (`db_object_id`),
   KEY `idx_slices_object_index` (`object_index`),
   KEY `idx_slices_db_multipart_part_id` (`db_multipart_part_id`),
   KEY `idx_slices_db_slab_id` (`db_slab_id`),
+  KEY `idx_slices_db_object_id_object_index` (`db_object_id`,`object_index`),
   CONSTRAINT `fk_multipart_parts_slabs` FOREIGN KEY (`db_multipart_part_id`) REFERENCES `multipart_parts` (`id`) ON DELETE CASCADE,
   CONSTRAINT `fk_objects_slabs` FOREIGN KEY (`db_object_id`) REFERENCES `objects` (`id`) ON DELETE CASCADE,
   CONSTRAINT `fk_slabs_slices` FOREIGN KEY (`db_slab_id`) REFERENCES `slabs` (`id`)
@@ -406,10 +439,12 @@ CREATE TABLE `transactions` (
   `inflow` longtext,
   `outflow` longtext,
   `timestamp` bigint DEFAULT NULL,
+  `type` varchar(191) NOT NULL DEFAULT '',
   PRIMARY KEY (`id`),
   UNIQUE KEY `transaction_id` (`transaction_id`),
   KEY `idx_transactions_transaction_id` (`transaction_id`),
-  KEY `idx_transactions_timestamp` (`timestamp`)
+  KEY `idx_transactions_timestamp` (`timestamp`),
+  KEY `idx_transactions_type` (`type`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
 -- dbWebhook
@@ -461,12 +496,14 @@ CREATE TABLE `host_checks` (
   `score_uptime` double NOT NULL,
   `score_version` double NOT NULL,
   `score_prices` double NOT NULL,
+  `score_success_rate` double NOT NULL DEFAULT 1,
 
   `gouging_contract_err` text,
   `gouging_download_err` text,
   `gouging_gouging_err` text,
   `gouging_prune_err` text,
   `gouging_upload_err` text,
+  `gouging_reasons` text,
 
   PRIMARY KEY (`id`),
   UNIQUE KEY `idx_host_checks_id` (`db_autopilot_id`, `db_host_id`),
@@ -0,0 +1 @@
+ALTER TABLE `migrations` ADD COLUMN `applied_at` datetime(3) NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE `contracts` ADD COLUMN `storage_spending_lo` bigint NOT NULL DEFAULT 0 AFTER `list_spending_hi`;
+ALTER TABLE `contracts` ADD COLUMN `storage_spending_hi` bigint NOT NULL DEFAULT 0 AFTER `storage_spending_lo`;
+CREATE INDEX `idx_storage_spending` ON `contracts`(`storage_spending_lo`,`storage_spending_hi`);
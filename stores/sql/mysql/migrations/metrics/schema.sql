@@ -71,6 +71,8 @@ CREATE TABLE `contracts` (
   `delete_spending_hi` bigint NOT NULL,
   `list_spending_lo` bigint NOT NULL,
   `list_spending_hi` bigint NOT NULL,
+  `storage_spending_lo` bigint NOT NULL DEFAULT 0,
+  `storage_spending_hi` bigint NOT NULL DEFAULT 0,
   PRIMARY KEY (`id`),
   KEY `idx_contracts_fc_id` (`fcid`),
   KEY `idx_contracts_host` (`host`),
@@ -83,6 +85,7 @@ CREATE TABLE `contracts` (
   KEY `idx_remaining_funds` (`remaining_funds_lo`,`remaining_funds_hi`),
   KEY `idx_delete_spending` (`delete_spending_lo`,`delete_spending_hi`),
   KEY `idx_list_spending` (`list_spending_lo`,`list_spending_hi`),
+  KEY `idx_storage_spending` (`storage_spending_lo`,`storage_spending_hi`),
   KEY `idx_contracts_fcid_timestamp` (`fcid`,`timestamp`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
 
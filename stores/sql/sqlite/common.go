@@ -36,12 +36,17 @@ func applyMigration(db *sql.DB, fn func(tx sql.Tx) (bool, error)) (err error) {
 }
 
 func createMigrationTable(db *sql.DB) error {
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS `migrations` (`id` text,PRIMARY KEY (`id`))"); err != nil {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS `migrations` (`id` text,`applied_at` datetime,PRIMARY KEY (`id`))"); err != nil {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 	return nil
 }
 
+func hasMigrationTable(db *sql.DB) (exists bool, err error) {
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'migrations')").Scan(&exists)
+	return
+}
+
 func version(db *sql.DB) (string, string, error) {
 	var version string
 	if err := db.QueryRow("select sqlite_version()").Scan(&version); err != nil {
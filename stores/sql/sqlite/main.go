@@ -31,6 +31,14 @@ func (b *MainDatabase) ApplyMigration(fn func(tx sql.Tx) (bool, error)) error {
 	return applyMigration(b.db, fn)
 }
 
+// Backup writes a copy of the database to the given path.
+func (b *MainDatabase) Backup(_ context.Context, path string) error {
+	if _, err := b.db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database to %v: %w", path, err)
+	}
+	return nil
+}
+
 func (b *MainDatabase) Close() error {
 	return b.db.Close()
 }
@@ -89,8 +97,16 @@ func (b *MainDatabase) MakeDirsForPath(tx sql.Tx, path string) (uint, error) {
 	return dirID, nil
 }
 
-func (b *MainDatabase) Migrate() error {
-	return sql.PerformMigrations(b, migrationsFs, "main", sql.MainMigrations(b, migrationsFs, b.log))
+func (b *MainDatabase) HasMigrationTable() (bool, error) {
+	return hasMigrationTable(b.db)
+}
+
+func (b *MainDatabase) SupportsTransactionalDDL() bool {
+	return true
+}
+
+func (b *MainDatabase) Migrate(ctx context.Context, opts sql.MigrationOptions) ([]string, error) {
+	return sql.PerformMigrations(ctx, b, migrationsFs, "main", sql.MainMigrations(b, migrationsFs, b.log), b.log, opts)
 }
 
 func (b *MainDatabase) Version(_ context.Context) (string, string, error) {
@@ -0,0 +1 @@
+UPDATE `archived_contracts` SET `reason` = 'unknown' WHERE `reason` IS NULL OR `reason` = '';
@@ -0,0 +1 @@
+ALTER TABLE `ephemeral_accounts` ADD COLUMN `drifted` numeric DEFAULT false;
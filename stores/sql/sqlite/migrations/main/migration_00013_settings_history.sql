@@ -0,0 +1,3 @@
+-- dbSettingHistory
+CREATE TABLE IF NOT EXISTS `settings_history` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`key` text NOT NULL,`old_value` text NOT NULL,`new_value` text NOT NULL);
+CREATE INDEX IF NOT EXISTS `idx_settings_history_key` ON `settings_history`(`key`);
@@ -0,0 +1 @@
+ALTER TABLE `settings` ADD COLUMN `version` integer NOT NULL DEFAULT 1;
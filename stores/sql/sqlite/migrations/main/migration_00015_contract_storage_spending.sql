@@ -0,0 +1,4 @@
+ALTER TABLE `contracts` ADD COLUMN `storage_spending` text;
+ALTER TABLE `archived_contracts` ADD COLUMN `storage_spending` text;
+UPDATE `contracts` SET `storage_spending` = '0';
+UPDATE `archived_contracts` SET `storage_spending` = '0';
@@ -0,0 +1,3 @@
+ALTER TABLE `hosts` ADD COLUMN `successful_operations` real DEFAULT 0;
+ALTER TABLE `hosts` ADD COLUMN `failed_operations` real DEFAULT 0;
+ALTER TABLE `host_checks` ADD COLUMN `score_success_rate` REAL NOT NULL DEFAULT 1;
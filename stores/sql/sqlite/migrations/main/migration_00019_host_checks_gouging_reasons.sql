@@ -0,0 +1 @@
+ALTER TABLE `host_checks` ADD COLUMN `gouging_reasons` TEXT;
@@ -0,0 +1,8 @@
+-- speeds up fetching the slices of an object in order
+CREATE INDEX IF NOT EXISTS `idx_slices_db_object_id_object_index` ON `slices`(`db_object_id`,`object_index`);
+DROP INDEX IF EXISTS `idx_slices_db_object_id`;
+
+-- sqlite indices only reference the rowid, a composite index allows fetching
+-- a contract's sectors without looking up every row
+CREATE INDEX IF NOT EXISTS `idx_contract_sectors_db_contract_id_db_sector_id` ON `contract_sectors`(`db_contract_id`,`db_sector_id`);
+DROP INDEX IF EXISTS `idx_contract_sectors_db_contract_id`;
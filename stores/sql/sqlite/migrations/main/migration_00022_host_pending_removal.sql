@@ -0,0 +1 @@
+ALTER TABLE `hosts` ADD COLUMN `pending_removal_since` integer NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- dbHostOverride
+CREATE TABLE IF NOT EXISTS `host_overrides` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`public_key` blob NOT NULL UNIQUE,`force_include` numeric NOT NULL DEFAULT false,`force_exclude` numeric NOT NULL DEFAULT false,`score_multiplier` real NOT NULL DEFAULT 1);
@@ -0,0 +1,2 @@
+ALTER TABLE `transactions` ADD COLUMN `type` text NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS `idx_transactions_type` ON `transactions`(`type`);
@@ -0,0 +1,3 @@
+ALTER TABLE `contracts` ADD COLUMN `storage_spending_lo` BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE `contracts` ADD COLUMN `storage_spending_hi` BIGINT NOT NULL DEFAULT 0;
+CREATE INDEX `idx_storage_spending` ON `contracts`(`storage_spending_lo`,`storage_spending_hi`);
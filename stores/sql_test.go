@@ -3,6 +3,7 @@ package stores
 import (
 	"bytes"
 	"context"
+	"embed"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -18,7 +19,9 @@ import (
 	"go.sia.tech/renterd/alerts"
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/config"
+	isql "go.sia.tech/renterd/internal/sql"
 	"go.sia.tech/renterd/object"
+	"go.sia.tech/renterd/stores/sql/sqlite"
 	"go.sia.tech/siad/modules"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -140,7 +143,7 @@ func newTestSQLStore(t *testing.T, cfg testSQLStoreConfig) *testSQLStore {
 		Migrate:                       !cfg.skipMigrate,
 		AnnouncementMaxAge:            time.Hour,
 		PersistInterval:               time.Second,
-		WalletAddress:                 walletAddrs,
+		WalletAddresses:               []types.Address{walletAddrs},
 		SlabBufferCompletionThreshold: 0,
 		Logger:                        zap.NewNop().Sugar(),
 		GormLogger:                    newTestLogger(),
@@ -151,7 +154,7 @@ func newTestSQLStore(t *testing.T, cfg testSQLStoreConfig) *testSQLStore {
 	}
 
 	if !cfg.skipContractSet {
-		err = sqlStore.SetContractSet(context.Background(), testContractSet, []types.FileContractID{})
+		_, err = sqlStore.SetContractSet(context.Background(), testContractSet, []types.FileContractID{})
 		if err != nil {
 			t.Fatal("failed to set contract set", err)
 		}
@@ -286,6 +289,17 @@ func TestConsensusReset(t *testing.T) {
 		t.Fatal("wrong ccid", ss.ccid, modules.ConsensusChangeBeginning)
 	}
 
+	// assertEmpty asserts whether the store is empty
+	assertEmpty := func(expected bool) {
+		t.Helper()
+		if empty, err := ss.IsEmpty(context.Background()); err != nil {
+			t.Fatal(err)
+		} else if empty != expected {
+			t.Fatalf("expected empty to be %v", expected)
+		}
+	}
+	assertEmpty(true)
+
 	// Manually insert into the consenus_infos, the transactions and siacoin_elements tables.
 	ccid2 := modules.ConsensusChangeID{1}
 	ss.db.Create(&dbConsensusInfo{
@@ -297,6 +311,7 @@ func TestConsensusReset(t *testing.T) {
 	ss.db.Create(&dbTransaction{
 		TransactionID: hash256{3},
 	})
+	assertEmpty(false)
 
 	// Reset the consensus.
 	if err := ss.ResetConsensusSubscription(context.Background()); err != nil {
@@ -333,6 +348,17 @@ func TestConsensusReset(t *testing.T) {
 	} else if ss.chainIndex.ID != (types.BlockID{}) {
 		t.Fatal("wrong id", ss.chainIndex.ID, types.BlockID{})
 	}
+
+	// Assert the store is empty after the reset but not once it contains a
+	// contract.
+	assertEmpty(true)
+	hk := types.PublicKey{1}
+	if err := ss.addTestHost(hk); err != nil {
+		t.Fatal(err)
+	} else if _, err := ss.addTestContract(types.FileContractID{1}, hk); err != nil {
+		t.Fatal(err)
+	}
+	assertEmpty(false)
 }
 
 type sqliteQueryPlan struct {
@@ -492,3 +518,368 @@ func TestRetryTransaction(t *testing.T) {
 		t.Fatal("expected no logs")
 	}
 }
+
+func newTestSQLiteMainDatabase(t *testing.T) *sqlite.MainDatabase {
+	t.Helper()
+	db, err := gorm.Open(NewEphemeralSQLiteConnection(randomDBName()), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainDB := sqlite.NewMainDatabase(sqlDB, zap.NewNop().Sugar(), 100*time.Millisecond, 100*time.Millisecond)
+	t.Cleanup(func() { mainDB.Close() })
+	return mainDB
+}
+
+func TestMigrationsDryRun(t *testing.T) {
+	db := newTestSQLiteMainDatabase(t)
+
+	// assert a dry run against an empty schema plans the schema init
+	plan, err := db.Migrate(context.Background(), isql.MigrationOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations := isql.MainMigrations(db, embed.FS{}, zap.NewNop().Sugar())
+	if len(plan) != len(migrations)+2 {
+		t.Fatalf("unexpected number of steps, %v != %v", len(plan), len(migrations)+2)
+	} else if plan[0] != "create migrations table" || plan[1] != "initialize main schema" {
+		t.Fatalf("unexpected plan %v", plan)
+	}
+	for i, migration := range migrations {
+		if expected := fmt.Sprintf("mark migration '%s' as applied", migration.ID); plan[i+2] != expected {
+			t.Fatalf("unexpected step %v, %v != %v", i+2, plan[i+2], expected)
+		}
+	}
+
+	// assert no tables were created
+	var n int
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected no tables, got %v", n)
+	}
+
+	// assert performing the migrations performs the planned steps
+	performed, err := db.Migrate(context.Background(), isql.MigrationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(plan, performed) {
+		t.Fatalf("unexpected steps, %v != %v", performed, plan)
+	}
+
+	// assert there's nothing left to plan
+	if plan, err := db.Migrate(context.Background(), isql.MigrationOptions{DryRun: true}); err != nil {
+		t.Fatal(err)
+	} else if len(plan) != 0 {
+		t.Fatalf("unexpected plan %v", plan)
+	}
+}
+
+func TestMigrationsIdempotent(t *testing.T) {
+	db := newTestSQLiteMainDatabase(t)
+
+	// perform the migrations
+	if _, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert all migrations were recorded with the time they were applied
+	var n int
+	migrations := isql.MainMigrations(db, embed.FS{}, zap.NewNop().Sugar())
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM migrations WHERE applied_at IS NOT NULL").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != len(migrations) {
+		t.Fatalf("unexpected number of applied migrations, %v != %v", n, len(migrations))
+	}
+
+	// assert performing the migrations again is a no-op
+	if performed, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	} else if len(performed) != 0 {
+		t.Fatalf("unexpected steps %v", performed)
+	}
+
+	// revert the last migration and assert it's the only one that's applied
+	if _, err := db.DB().Exec("ALTER TABLE migrations DROP COLUMN applied_at"); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.DB().Exec("DELETE FROM migrations WHERE id = ?", "00009_migrations_applied_at"); err != nil {
+		t.Fatal(err)
+	}
+	if performed, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(performed, []string{"apply migration '00009_migrations_applied_at'"}) {
+		t.Fatalf("unexpected steps %v", performed)
+	}
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM migrations WHERE applied_at IS NOT NULL").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatalf("unexpected number of migrations with a timestamp, %v != 1", n)
+	}
+}
+
+func TestMigrationsWithoutAppliedAt(t *testing.T) {
+	db := newTestSQLiteMainDatabase(t)
+	if _, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// revert a migration from before the applied_at column was added as well
+	// as the migration that added it, leaving the migrations table in its old
+	// format
+	if _, err := db.DB().Exec("DROP INDEX `idx_objects_created_at`"); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.DB().Exec("ALTER TABLE migrations DROP COLUMN applied_at"); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.DB().Exec("DELETE FROM migrations WHERE id IN (?, ?)", "00006_idx_objects_created_at", "00009_migrations_applied_at"); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert both migrations are applied in order
+	if performed, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(performed, []string{
+		"apply migration '00006_idx_objects_created_at'",
+		"apply migration '00009_migrations_applied_at'",
+	}) {
+		t.Fatalf("unexpected steps %v", performed)
+	}
+
+	// assert only the migration applied after the column was added has a
+	// timestamp
+	for id, expected := range map[string]bool{
+		"00006_idx_objects_created_at": false,
+		"00009_migrations_applied_at":  true,
+	} {
+		var hasTimestamp bool
+		if err := db.DB().QueryRow("SELECT applied_at IS NOT NULL FROM migrations WHERE id = ?", id).Scan(&hasTimestamp); err != nil {
+			t.Fatal(err)
+		} else if hasTimestamp != expected {
+			t.Fatalf("unexpected timestamp for migration '%s', %v != %v", id, hasTimestamp, expected)
+		}
+	}
+}
+
+func TestNewSQLStoreMigrationsDryRun(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "db.sqlite")
+
+	// create a store in dry-run mode and assert it's not created
+	_, _, err := NewSQLStore(Config{
+		Conn:               NewSQLiteConnection(dbPath),
+		ConnMetrics:        NewSQLiteConnection(filepath.Join(dir, "metrics.sqlite")),
+		PartialSlabDir:     dir,
+		Migrate:            true,
+		MigrationsDryRun:   true,
+		AnnouncementMaxAge: time.Hour,
+		PersistInterval:    time.Second,
+		Logger:             zap.NewNop().Sugar(),
+		GormLogger:         newTestLogger(),
+	})
+	if !errors.Is(err, ErrMigrationsDryRun) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// assert no tables were created
+	db, err := gorm.Open(NewSQLiteConnection(dbPath), &gorm.Config{Logger: newTestLogger()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&n).Error; err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected no tables, got %v", n)
+	}
+	if sqlDB, err := db.DB(); err != nil {
+		t.Fatal(err)
+	} else if err := sqlDB.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrationsCompositeIndexes(t *testing.T) {
+	db := newTestSQLiteMainDatabase(t)
+
+	// assertIndexes asserts the composite indexes exist on the given columns
+	// and the single column indexes they cover were dropped
+	expected := map[string]string{
+		"idx_slices_db_object_id_object_index":             "db_object_id,object_index",
+		"idx_contract_sectors_db_contract_id_db_sector_id": "db_contract_id,db_sector_id",
+	}
+	redundant := map[string]string{
+		"idx_slices_db_object_id":             "`slices`(`db_object_id`)",
+		"idx_contract_sectors_db_contract_id": "`contract_sectors`(`db_contract_id`)",
+	}
+	assertIndexes := func() {
+		t.Helper()
+		for index := range redundant {
+			var n int
+			if err := db.DB().QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", index).Scan(&n); err != nil {
+				t.Fatal(err)
+			} else if n != 0 {
+				t.Fatalf("expected index %v to be dropped", index)
+			}
+		}
+		for index, columns := range expected {
+			rows, err := db.DB().Query(fmt.Sprintf("SELECT name FROM pragma_index_info('%s') ORDER BY seqno", index))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cols []string
+			for rows.Next() {
+				var col string
+				if err := rows.Scan(&col); err != nil {
+					t.Fatal(err)
+				}
+				cols = append(cols, col)
+			}
+			if err := rows.Close(); err != nil {
+				t.Fatal(err)
+			} else if strings.Join(cols, ",") != columns {
+				t.Fatalf("unexpected columns for index %v, %v != %v", index, cols, columns)
+			}
+		}
+	}
+
+	// assert the indexes exist after initializing the schema
+	if _, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assertIndexes()
+
+	// restore the previous indexes and revert the migration
+	for index := range expected {
+		if _, err := db.DB().Exec(fmt.Sprintf("DROP INDEX `%s`", index)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for index, table := range redundant {
+		if _, err := db.DB().Exec(fmt.Sprintf("CREATE INDEX `%s` ON %s", index, table)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.DB().Exec("DELETE FROM migrations WHERE id = ?", "00021_composite_indexes"); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the migration recreates them and drops the redundant ones
+	if performed, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(performed, []string{"apply migration '00021_composite_indexes'"}) {
+		t.Fatalf("unexpected steps %v", performed)
+	}
+	assertIndexes()
+}
+
+func TestMigrationsBackup(t *testing.T) {
+	db := newTestSQLiteMainDatabase(t)
+
+	// prepare a backup hook
+	var backups int
+	backupErr := errors.New("backup failed")
+	opts := isql.MigrationOptions{Backup: func(ctx context.Context) error {
+		backups++
+		return backupErr
+	}}
+
+	// perform the migrations, the hook isn't called for an empty database
+	if _, err := db.Migrate(context.Background(), opts); err != nil {
+		t.Fatal(err)
+	} else if backups != 0 {
+		t.Fatalf("unexpected number of backups, %v != 0", backups)
+	}
+
+	// revert a destructive and a non-destructive migration
+	if _, err := db.DB().Exec("ALTER TABLE migrations DROP COLUMN applied_at"); err != nil {
+		t.Fatal(err)
+	} else if _, err := db.DB().Exec("DELETE FROM migrations WHERE id IN (?, ?)", "00008_directories", "00009_migrations_applied_at"); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the dry run plans the backup
+	if plan, err := db.Migrate(context.Background(), isql.MigrationOptions{DryRun: true, Backup: opts.Backup}); err != nil {
+		t.Fatal(err)
+	} else if len(plan) != 3 || plan[0] != "back up database" {
+		t.Fatalf("unexpected plan %v", plan)
+	}
+
+	// assert the migrations are aborted if the backup fails
+	if _, err := db.Migrate(context.Background(), opts); !errors.Is(err, backupErr) {
+		t.Fatalf("expected backup error, got %v", err)
+	} else if backups != 1 {
+		t.Fatalf("unexpected number of backups, %v != 1", backups)
+	}
+
+	// assert the schema wasn't modified
+	var n int
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM pragma_table_info('migrations') WHERE name = 'applied_at'").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal("expected schema to be unchanged")
+	}
+	if plan, err := db.Migrate(context.Background(), isql.MigrationOptions{DryRun: true}); err != nil {
+		t.Fatal(err)
+	} else if len(plan) != 2 {
+		t.Fatalf("unexpected plan %v", plan)
+	}
+
+	// assert the backup is written to disk
+	path := filepath.Join(t.TempDir(), "db.sqlite.bak")
+	if err := db.Backup(context.Background(), path); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrationsRollback(t *testing.T) {
+	db := newTestSQLiteMainDatabase(t)
+	if _, err := db.Migrate(context.Background(), isql.MigrationOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// append migrations where the middle one fails
+	log := zap.NewNop().Sugar()
+	migrationErr := errors.New("migration failed")
+	migrations := append(isql.MainMigrations(db, embed.FS{}, log),
+		isql.Migration{
+			ID: "test_1",
+			Migrate: func(tx isql.Tx) error {
+				_, err := tx.Exec("CREATE TABLE test (id integer PRIMARY KEY)")
+				return err
+			},
+		},
+		isql.Migration{
+			ID:      "test_2",
+			Migrate: func(tx isql.Tx) error { return migrationErr },
+		},
+		isql.Migration{
+			ID: "test_3",
+			Migrate: func(tx isql.Tx) error {
+				_, err := tx.Exec("CREATE TABLE test_3 (id integer PRIMARY KEY)")
+				return err
+			},
+		},
+	)
+
+	// assert the migrations fail
+	if _, err := isql.PerformMigrations(context.Background(), db, embed.FS{}, "main", migrations, log, isql.MigrationOptions{}); !errors.Is(err, migrationErr) {
+		t.Fatalf("expected migration error, got %v", err)
+	}
+
+	// assert the schema is unchanged
+	var n int
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'test%'").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected no test tables, got %v", n)
+	}
+	if err := db.DB().QueryRow("SELECT COUNT(*) FROM migrations WHERE id LIKE 'test%'").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatalf("expected no test migrations, got %v", n)
+	}
+}
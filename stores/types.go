@@ -14,6 +14,7 @@ import (
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	rhpv3 "go.sia.tech/core/rhp/v3"
 	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
 )
 
 const (
@@ -32,6 +33,7 @@ type (
 	publicKey      types.PublicKey
 	hostSettings   rhpv2.HostSettings
 	hostPriceTable rhpv3.HostPriceTable
+	gougingReasons []api.GougingReason
 	balance        big.Int
 	unsigned64     uint64 // used for storing large uint64 values in sqlite
 	secretKey      []byte
@@ -212,6 +214,35 @@ func (hs hostSettings) Value() (driver.Value, error) {
 	return json.Marshal(hs)
 }
 
+func (gougingReasons) GormDataType() string {
+	return "string"
+}
+
+// Scan scan value into gougingReasons, implements sql.Scanner interface.
+func (gr *gougingReasons) Scan(value interface{}) error {
+	var bytes []byte
+	switch value := value.(type) {
+	case nil:
+		*gr = nil
+		return nil
+	case string:
+		bytes = []byte(value)
+	case []byte:
+		bytes = value
+	default:
+		return errors.New(fmt.Sprint("failed to unmarshal gougingReasons value:", value))
+	}
+	return json.Unmarshal(bytes, gr)
+}
+
+// Value returns a gougingReasons value, implements driver.Valuer interface.
+func (gr gougingReasons) Value() (driver.Value, error) {
+	if len(gr) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(gr)
+}
+
 func (hs hostPriceTable) GormDataType() string {
 	return "string"
 }
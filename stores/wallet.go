@@ -2,6 +2,7 @@ package stores
 
 import (
 	"bytes"
+	"errors"
 	"math"
 	"time"
 
@@ -16,7 +17,7 @@ type (
 	dbSiacoinElement struct {
 		Model
 		Value          currency
-		Address        hash256 `gorm:"size:32"`
+		Address        hash256 `gorm:"index;size:32"`
 		OutputID       hash256 `gorm:"unique;index;NOT NULL;size:32"`
 		MaturityHeight uint64  `gorm:"index"`
 	}
@@ -29,7 +30,8 @@ type (
 		TransactionID hash256 `gorm:"unique;index;NOT NULL;size:32"`
 		Inflow        currency
 		Outflow       currency
-		Timestamp     int64 `gorm:"index:idx_transactions_timestamp"`
+		Timestamp     int64  `gorm:"index:idx_transactions_timestamp"`
+		Type          string `gorm:"index:idx_transactions_type;NOT NULL;default:''"`
 	}
 
 	outputChange struct {
@@ -87,7 +89,7 @@ func (s *SQLStore) UnspentSiacoinElements(matured bool) ([]wallet.SiacoinElement
 }
 
 // Transactions implements wallet.SingleAddressStore.
-func (s *SQLStore) Transactions(before, since time.Time, offset, limit int) ([]wallet.Transaction, error) {
+func (s *SQLStore) Transactions(before, since time.Time, txnType string, marker types.TransactionID, offset, limit int) ([]wallet.Transaction, error) {
 	beforeX := int64(math.MaxInt64)
 	sinceX := int64(0)
 	if !before.IsZero() {
@@ -100,9 +102,34 @@ func (s *SQLStore) Transactions(before, since time.Time, offset, limit int) ([]w
 		limit = math.MaxInt64
 	}
 
+	query := s.db.
+		Model(&dbTransaction{}).
+		Where("timestamp >= ? AND timestamp < ?", sinceX, beforeX)
+	if txnType != "" {
+		query = query.Where("`type` = ?", txnType)
+	}
+
+	// only return transactions that come after the marker
+	if marker != (types.TransactionID{}) {
+		var m dbTransaction
+		err := s.db.
+			Where("transaction_id = ?", hash256(marker)).
+			Take(&m).
+			Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, wallet.ErrUnknownTransaction
+		} else if err != nil {
+			return nil, err
+		}
+		query = query.Where("timestamp < ? OR (timestamp = ? AND id < ?)", m.Timestamp, m.Timestamp, m.ID)
+	}
+
 	var dbTxns []dbTransaction
-	err := s.db.Raw("SELECT * FROM transactions WHERE timestamp >= ? AND timestamp < ? ORDER BY timestamp DESC LIMIT ? OFFSET ?",
-		sinceX, beforeX, limit, offset).Scan(&dbTxns).
+	err := query.
+		Order("timestamp DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&dbTxns).
 		Error
 	if err != nil {
 		return nil, err
@@ -120,6 +147,7 @@ func (s *SQLStore) Transactions(before, since time.Time, offset, limit int) ([]w
 			Inflow:    types.Currency(dbTxns[i].Inflow),
 			Outflow:   types.Currency(dbTxns[i].Outflow),
 			Timestamp: time.Unix(dbTxns[i].Timestamp, 0),
+			Type:      dbTxns[i].Type,
 		}
 	}
 	return txns, nil
@@ -131,7 +159,7 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 	for _, diff := range cc.SiacoinOutputDiffs {
 		var sco types.SiacoinOutput
 		convertToCore(diff.SiacoinOutput, (*types.V1SiacoinOutput)(&sco))
-		if sco.Address != s.walletAddress {
+		if !s.isWalletAddress(sco.Address) {
 			continue
 		}
 		if diff.Direction == modules.DiffApply {
@@ -162,7 +190,7 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 			// output has matured -- add a payout transaction.
 			if dsco.Direction != modules.DiffRevert {
 				continue
-			} else if types.Address(dsco.SiacoinOutput.UnlockHash) != s.walletAddress {
+			} else if !s.isWalletAddress(types.Address(dsco.SiacoinOutput.UnlockHash)) {
 				continue
 			}
 			var sco types.SiacoinOutput
@@ -175,6 +203,7 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 					Inflow:        currency(sco.Value),                                                         // transaction inflow is value of matured output
 					TransactionID: hash256(dsco.ID),                                                            // use output as txn id
 					Timestamp:     int64(cc.AppliedBlocks[dsco.MaturityHeight-cc.InitialHeight()-1].Timestamp), // use timestamp of block that caused output to mature
+					Type:          wallet.TransactionTypeReceive,
 				},
 			})
 		}
@@ -185,7 +214,7 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 		for _, stxn := range block.Transactions {
 			var txn types.Transaction
 			convertToCore(stxn, &txn)
-			if transactionIsRelevant(txn, s.walletAddress) {
+			if s.transactionIsRelevant(txn) {
 				// remove reverted txns
 				s.unappliedTxnChanges = append(s.unappliedTxnChanges, txnChange{
 					addition: false,
@@ -221,15 +250,15 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 		for _, stxn := range block.Transactions {
 			var txn types.Transaction
 			convertToCore(stxn, &txn)
-			if transactionIsRelevant(txn, s.walletAddress) {
+			if s.transactionIsRelevant(txn) {
 				var inflow, outflow types.Currency
 				for _, out := range txn.SiacoinOutputs {
-					if out.Address == s.walletAddress {
+					if s.isWalletAddress(out.Address) {
 						inflow = inflow.Add(out.Value)
 					}
 				}
 				for _, in := range txn.SiacoinInputs {
-					if in.UnlockConditions.UnlockHash() == s.walletAddress {
+					if s.isWalletAddress(in.UnlockConditions.UnlockHash()) {
 						so, ok := spentOutputs[in.ParentID]
 						if !ok {
 							panic("spent output not found")
@@ -250,6 +279,7 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 						Outflow:       currency(outflow),
 						TransactionID: hash256(txn.ID()),
 						Timestamp:     int64(block.Timestamp),
+						Type:          wallet.TransactionType(txn, inflow, outflow),
 					},
 				})
 			}
@@ -257,50 +287,58 @@ func (s *SQLStore) processConsensusChangeWallet(cc modules.ConsensusChange) {
 	}
 }
 
-func transactionIsRelevant(txn types.Transaction, addr types.Address) bool {
+// isWalletAddress returns true if the given address belongs to the wallet.
+func (s *SQLStore) isWalletAddress(addr types.Address) bool {
+	_, ok := s.walletAddresses[addr]
+	return ok
+}
+
+// transactionIsRelevant returns true if the transaction involves any of the
+// wallet's addresses.
+func (s *SQLStore) transactionIsRelevant(txn types.Transaction) bool {
 	for i := range txn.SiacoinInputs {
-		if txn.SiacoinInputs[i].UnlockConditions.UnlockHash() == addr {
+		if s.isWalletAddress(txn.SiacoinInputs[i].UnlockConditions.UnlockHash()) {
 			return true
 		}
 	}
 	for i := range txn.SiacoinOutputs {
-		if txn.SiacoinOutputs[i].Address == addr {
+		if s.isWalletAddress(txn.SiacoinOutputs[i].Address) {
 			return true
 		}
 	}
 	for i := range txn.SiafundInputs {
-		if txn.SiafundInputs[i].UnlockConditions.UnlockHash() == addr {
+		if s.isWalletAddress(txn.SiafundInputs[i].UnlockConditions.UnlockHash()) {
 			return true
 		}
-		if txn.SiafundInputs[i].ClaimAddress == addr {
+		if s.isWalletAddress(txn.SiafundInputs[i].ClaimAddress) {
 			return true
 		}
 	}
 	for i := range txn.SiafundOutputs {
-		if txn.SiafundOutputs[i].Address == addr {
+		if s.isWalletAddress(txn.SiafundOutputs[i].Address) {
 			return true
 		}
 	}
 	for i := range txn.FileContracts {
 		for _, sco := range txn.FileContracts[i].ValidProofOutputs {
-			if sco.Address == addr {
+			if s.isWalletAddress(sco.Address) {
 				return true
 			}
 		}
 		for _, sco := range txn.FileContracts[i].MissedProofOutputs {
-			if sco.Address == addr {
+			if s.isWalletAddress(sco.Address) {
 				return true
 			}
 		}
 	}
 	for i := range txn.FileContractRevisions {
 		for _, sco := range txn.FileContractRevisions[i].ValidProofOutputs {
-			if sco.Address == addr {
+			if s.isWalletAddress(sco.Address) {
 				return true
 			}
 		}
 		for _, sco := range txn.FileContractRevisions[i].MissedProofOutputs {
-			if sco.Address == addr {
+			if s.isWalletAddress(sco.Address) {
 				return true
 			}
 		}
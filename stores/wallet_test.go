@@ -0,0 +1,88 @@
+package stores
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/wallet"
+)
+
+func TestTransactions(t *testing.T) {
+	ss := newTestSQLStore(t, defaultTestSQLStoreConfig)
+	defer ss.Close()
+
+	// insert transactions of mixed types, the last two share a timestamp
+	now := time.Now().Round(time.Second)
+	for i, typ := range []string{
+		wallet.TransactionTypeReceive,
+		wallet.TransactionTypeContract,
+		wallet.TransactionTypeSend,
+		wallet.TransactionTypeRenewal,
+		wallet.TransactionTypeContract,
+		wallet.TransactionTypeContract,
+	} {
+		timestamp := now.Add(time.Duration(i) * time.Minute)
+		if i == 5 {
+			timestamp = now.Add(4 * time.Minute)
+		}
+		if err := ss.db.Create(&dbTransaction{
+			TransactionID: hash256{byte(i + 1)},
+			Timestamp:     timestamp.Unix(),
+			Type:          typ,
+		}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// assertTxns fetches the transactions and asserts their ids
+	assertTxns := func(since time.Time, typ string, marker types.TransactionID, limit int, ids ...byte) {
+		t.Helper()
+		txns, err := ss.Transactions(time.Time{}, since, typ, marker, 0, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, 0, len(txns))
+		for _, txn := range txns {
+			got = append(got, txn.ID[0])
+			if typ != "" && txn.Type != typ {
+				t.Fatalf("unexpected type %v", txn.Type)
+			}
+		}
+		if ids == nil {
+			ids = []byte{}
+		}
+		if !reflect.DeepEqual(got, ids) {
+			t.Fatalf("unexpected transactions %v != %v", got, ids)
+		}
+	}
+
+	// assert transactions are sorted from newest to oldest
+	assertTxns(time.Time{}, "", types.TransactionID{}, -1, 6, 5, 4, 3, 2, 1)
+
+	// assert filtering by type and time
+	assertTxns(time.Time{}, wallet.TransactionTypeContract, types.TransactionID{}, -1, 6, 5, 2)
+	assertTxns(time.Time{}, wallet.TransactionTypeRenewal, types.TransactionID{}, -1, 4)
+	assertTxns(now.Add(2*time.Minute), "", types.TransactionID{}, -1, 6, 5, 4, 3)
+	assertTxns(now.Add(2*time.Minute), wallet.TransactionTypeContract, types.TransactionID{}, -1, 6, 5)
+
+	// assert paging through the transactions using the marker, the marker
+	// can be used to page across transactions with the same timestamp
+	assertTxns(time.Time{}, "", types.TransactionID{}, 2, 6, 5)
+	assertTxns(time.Time{}, "", types.TransactionID{6}, 2, 5, 4)
+	assertTxns(time.Time{}, "", types.TransactionID{4}, 2, 3, 2)
+	assertTxns(time.Time{}, "", types.TransactionID{2}, 2, 1)
+	assertTxns(time.Time{}, "", types.TransactionID{1}, 2)
+
+	// assert paging combined with a type filter
+	assertTxns(time.Time{}, wallet.TransactionTypeContract, types.TransactionID{}, 1, 6)
+	assertTxns(time.Time{}, wallet.TransactionTypeContract, types.TransactionID{6}, 1, 5)
+	assertTxns(time.Time{}, wallet.TransactionTypeContract, types.TransactionID{5}, 1, 2)
+
+	// assert an unknown marker is rejected
+	if _, err := ss.Transactions(time.Time{}, time.Time{}, "", types.TransactionID{7}, 0, -1); !errors.Is(err, wallet.ErrUnknownTransaction) {
+		t.Fatal("unexpected error", err)
+	}
+}
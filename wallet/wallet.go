@@ -3,6 +3,7 @@ package wallet
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sort"
@@ -37,9 +38,45 @@ const (
 	maxDefragUTXOs = 10
 )
 
-// ErrInsufficientBalance is returned when there aren't enough unused outputs to
-// cover the requested amount.
-var ErrInsufficientBalance = errors.New("insufficient balance")
+const (
+	// TransactionTypeContract is the type of transactions that form a
+	// contract.
+	TransactionTypeContract = "contract"
+
+	// TransactionTypeRenewal is the type of transactions that renew a
+	// contract, they form a new contract and revise the renewed one.
+	TransactionTypeRenewal = "renewal"
+
+	// TransactionTypeSend is the type of transactions that spend more than
+	// they send to the wallet.
+	TransactionTypeSend = "send"
+
+	// TransactionTypeReceive is the type of transactions that send more to
+	// the wallet than they spend, e.g. matured payouts.
+	TransactionTypeReceive = "receive"
+
+	// TransactionTypeOther is the type of transactions that send exactly as
+	// much to the wallet as they spend.
+	TransactionTypeOther = "other"
+)
+
+var (
+	// ErrDefragFeeTooHigh is returned when the fee budget of a
+	// defragmentation doesn't allow for consolidating at least two outputs.
+	ErrDefragFeeTooHigh = errors.New("fee budget too low to defrag wallet")
+
+	// ErrInsufficientBalance is returned when there aren't enough unused
+	// outputs to cover the requested amount.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+
+	// ErrUnknownAddress is returned when an address is passed that isn't
+	// controlled by the wallet.
+	ErrUnknownAddress = errors.New("address is not controlled by the wallet")
+
+	// ErrUnknownTransaction is returned when a transaction is passed as a
+	// marker that isn't relevant to the wallet.
+	ErrUnknownTransaction = errors.New("transaction is not relevant to the wallet")
+)
 
 // StandardUnlockConditions returns the standard unlock conditions for a single
 // Ed25519 key.
@@ -58,6 +95,26 @@ func StandardAddress(pk types.PublicKey) types.Address {
 	return StandardUnlockConditions(pk).UnlockHash()
 }
 
+// DeriveAddressKey derives the key of the wallet address with the given index
+// from the wallet's primary key. The key at index 0 is the primary key itself.
+func DeriveAddressKey(priv types.PrivateKey, index uint64) types.PrivateKey {
+	if index == 0 {
+		return priv
+	}
+	seed := types.HashBytes(append(append([]byte(nil), priv[:32]...), binary.LittleEndian.AppendUint64(nil, index)...))
+	return types.NewPrivateKeyFromSeed(seed[:])
+}
+
+// DeriveAddresses returns the first n addresses derived from the wallet's
+// primary key, the first address is the primary address.
+func DeriveAddresses(priv types.PrivateKey, n uint64) []types.Address {
+	addrs := make([]types.Address, n)
+	for i := range addrs {
+		addrs[i] = StandardAddress(DeriveAddressKey(priv, uint64(i)).PublicKey())
+	}
+	return addrs
+}
+
 // StandardTransactionSignature returns the standard signature object for a
 // siacoin or siafund input.
 func StandardTransactionSignature(id types.Hash256) types.TransactionSignature {
@@ -120,6 +177,34 @@ type Transaction struct {
 	Inflow    types.Currency      `json:"inflow"`
 	Outflow   types.Currency      `json:"outflow"`
 	Timestamp time.Time           `json:"timestamp"`
+	Type      string              `json:"type"`
+}
+
+// TransactionType infers the type of a transaction from its contents and the
+// value it moves in and out of the wallet.
+func TransactionType(txn types.Transaction, inflow, outflow types.Currency) string {
+	switch {
+	case len(txn.FileContracts) > 0 && len(txn.FileContractRevisions) > 0:
+		return TransactionTypeRenewal
+	case len(txn.FileContracts) > 0:
+		return TransactionTypeContract
+	case outflow.Cmp(inflow) > 0:
+		return TransactionTypeSend
+	case inflow.Cmp(outflow) > 0:
+		return TransactionTypeReceive
+	default:
+		return TransactionTypeOther
+	}
+}
+
+// ValidTransactionType returns true if the given type is a known transaction
+// type.
+func ValidTransactionType(typ string) bool {
+	switch typ {
+	case TransactionTypeContract, TransactionTypeRenewal, TransactionTypeSend, TransactionTypeReceive, TransactionTypeOther:
+		return true
+	}
+	return false
 }
 
 // A SingleAddressStore stores the state of a single-address wallet.
@@ -127,7 +212,7 @@ type Transaction struct {
 type SingleAddressStore interface {
 	Height() uint64
 	UnspentSiacoinElements(matured bool) ([]SiacoinElement, error)
-	Transactions(before, since time.Time, offset, limit int) ([]Transaction, error)
+	Transactions(before, since time.Time, txnType string, marker types.TransactionID, offset, limit int) ([]Transaction, error)
 	RecordWalletMetric(ctx context.Context, metrics ...api.WalletMetric) error
 }
 
@@ -138,16 +223,23 @@ type TransactionPool interface {
 }
 
 // A SingleAddressWallet is a hot wallet that manages the outputs controlled by
-// a single address.
+// a single key. Besides the primary address, the wallet can manage additional
+// addresses derived from that key to segregate funds.
 type SingleAddressWallet struct {
 	log            *zap.SugaredLogger
 	priv           types.PrivateKey
 	addr           types.Address
+	addrs          []types.Address
+	keys           map[types.Address]types.PrivateKey
 	store          SingleAddressStore
 	usedUTXOExpiry time.Duration
 
 	// for building transactions
-	mu       sync.Mutex
+	mu            sync.Mutex
+	coinSelection api.CoinSelectionStrategy
+	// lastUsed maps a siacoin output ID to the time it was last selected
+	// to fund a transaction, outputs are reserved until they are released
+	// or the usedUTXOExpiry has passed.
 	lastUsed map[types.Hash256]time.Time
 	// tpoolTxns maps a transaction set ID to the transactions in that set
 	tpoolTxns map[types.Hash256][]Transaction
@@ -165,11 +257,51 @@ func (w *SingleAddressWallet) PrivateKey() types.PrivateKey {
 	return w.priv
 }
 
-// Address returns the address of the wallet.
+// Address returns the primary address of the wallet.
 func (w *SingleAddressWallet) Address() types.Address {
 	return w.addr
 }
 
+// Addresses returns all addresses of the wallet, starting with the primary
+// address.
+func (w *SingleAddressWallet) Addresses() []types.Address {
+	return append([]types.Address(nil), w.addrs...)
+}
+
+// AddressBalances returns the balance of every address of the wallet.
+func (w *SingleAddressWallet) AddressBalances() ([]api.WalletAddressBalance, error) {
+	sces, err := w.store.UnspentSiacoinElements(false)
+	if err != nil {
+		return nil, err
+	}
+	height := w.store.Height()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	balances := make([]api.WalletAddressBalance, len(w.addrs))
+	indices := make(map[types.Address]int)
+	for i, addr := range w.addrs {
+		balances[i].Address = addr
+		indices[addr] = i
+	}
+	for _, sce := range sces {
+		i, ok := indices[sce.Address]
+		if !ok || sce.MaturityHeight > height {
+			continue
+		}
+		if !w.isOutputUsed(sce.ID) {
+			balances[i].Spendable = balances[i].Spendable.Add(sce.Value)
+		}
+		balances[i].Confirmed = balances[i].Confirmed.Add(sce.Value)
+	}
+	for _, sco := range w.tpoolUtxos {
+		if i, ok := indices[sco.Address]; ok && !w.isOutputUsed(sco.ID) {
+			balances[i].Unconfirmed = balances[i].Unconfirmed.Add(sco.Value)
+		}
+	}
+	return balances, nil
+}
+
 // Balance returns the balance of the wallet.
 func (w *SingleAddressWallet) Balance() (spendable, confirmed, unconfirmed types.Currency, _ error) {
 	sces, err := w.store.UnspentSiacoinElements(true)
@@ -192,6 +324,26 @@ func (w *SingleAddressWallet) Balance() (spendable, confirmed, unconfirmed types
 	return
 }
 
+// CoinSelectionStrategy returns the strategy used to select the outputs that
+// fund a transaction.
+func (w *SingleAddressWallet) CoinSelectionStrategy() api.CoinSelectionStrategy {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.coinSelection
+}
+
+// SetCoinSelectionStrategy updates the strategy used to select the outputs
+// that fund a transaction.
+func (w *SingleAddressWallet) SetCoinSelectionStrategy(strategy api.CoinSelectionStrategy) error {
+	if err := strategy.Validate(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.coinSelection = strategy
+	return nil
+}
+
 func (w *SingleAddressWallet) Height() uint64 {
 	return w.store.Height()
 }
@@ -214,17 +366,33 @@ func (w *SingleAddressWallet) UnspentOutputs() ([]SiacoinElement, error) {
 	return filtered, nil
 }
 
-// Transactions returns up to max transactions relevant to the wallet that have
-// a timestamp later than since.
-func (w *SingleAddressWallet) Transactions(before, since time.Time, offset, limit int) ([]Transaction, error) {
-	return w.store.Transactions(before, since, offset, limit)
+// Transactions returns up to limit transactions relevant to the wallet that
+// have a timestamp in [since, before) and match the given type, if any. The
+// transactions are sorted from newest to oldest, if a marker is given only
+// transactions that come after the marker are returned.
+func (w *SingleAddressWallet) Transactions(before, since time.Time, txnType string, marker types.TransactionID, offset, limit int) ([]Transaction, error) {
+	return w.store.Transactions(before, since, txnType, marker, offset, limit)
 }
 
 // FundTransaction adds siacoin inputs worth at least the requested amount to
-// the provided transaction. A change output is also added, if necessary. The
+// the provided transaction. The inputs are selected according to the wallet's
+// coin selection strategy. A change output is also added, if necessary. The
 // inputs will not be available to future calls to FundTransaction unless
 // ReleaseInputs is called or enough time has passed.
 func (w *SingleAddressWallet) FundTransaction(cs consensus.State, txn *types.Transaction, amount types.Currency, useUnconfirmedTxns bool) ([]types.Hash256, error) {
+	return w.FundTransactionFromAddress(cs, txn, amount, useUnconfirmedTxns, types.VoidAddress)
+}
+
+// FundTransactionFromAddress funds the transaction like FundTransaction but
+// only uses outputs controlled by the given address, the change output is
+// sent back to that address. If the void address is passed, outputs of all of
+// the wallet's addresses are used.
+func (w *SingleAddressWallet) FundTransactionFromAddress(cs consensus.State, txn *types.Transaction, amount types.Currency, useUnconfirmedTxns bool, from types.Address) ([]types.Hash256, error) {
+	if from != types.VoidAddress {
+		if _, ok := w.keys[from]; !ok {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownAddress, from)
+		}
+	}
 	if amount.IsZero() {
 		return nil, nil
 	}
@@ -237,43 +405,23 @@ func (w *SingleAddressWallet) FundTransaction(cs consensus.State, txn *types.Tra
 		return nil, err
 	}
 
-	// desc sort
-	sort.Slice(utxos, func(i, j int) bool {
-		return utxos[i].Value.Cmp(utxos[j].Value) > 0
-	})
+	// remove locked and spent outputs and outputs of other addresses
+	utxos = w.filterUsed(utxos, from)
+
+	// fund the transaction using the confirmed outputs
+	selected, usableUTXOs, inputSum := selectUTXOs(utxos, amount, w.coinSelection)
 
-	// add all unconfirmed outputs to the end of the slice as a last resort
-	if useUnconfirmedTxns {
+	// use the unconfirmed outputs as a last resort, at this point all
+	// confirmed outputs are selected
+	if inputSum.Cmp(amount) < 0 && useUnconfirmedTxns {
 		var tpoolUtxos []SiacoinElement
 		for _, sco := range w.tpoolUtxos {
 			tpoolUtxos = append(tpoolUtxos, sco)
 		}
-		// desc sort
-		sort.Slice(tpoolUtxos, func(i, j int) bool {
-			return tpoolUtxos[i].Value.Cmp(tpoolUtxos[j].Value) > 0
-		})
-		utxos = append(utxos, tpoolUtxos...)
-	}
-
-	// remove locked and spent outputs
-	usableUTXOs := utxos[:0]
-	for _, sce := range utxos {
-		if w.isOutputUsed(sce.ID) {
-			continue
-		}
-		usableUTXOs = append(usableUTXOs, sce)
-	}
-
-	// fund the transaction using the largest utxos first
-	var selected []SiacoinElement
-	var inputSum types.Currency
-	for i, sce := range usableUTXOs {
-		if inputSum.Cmp(amount) >= 0 {
-			usableUTXOs = usableUTXOs[i:]
-			break
-		}
-		selected = append(selected, sce)
-		inputSum = inputSum.Add(sce.Value)
+		unconfirmed, remaining, unconfirmedSum := selectUTXOs(w.filterUsed(tpoolUtxos, from), amount.Sub(inputSum), w.coinSelection)
+		selected = append(selected, unconfirmed...)
+		usableUTXOs = remaining
+		inputSum = inputSum.Add(unconfirmedSum)
 	}
 
 	// if the transaction can't be funded, return an error
@@ -281,6 +429,11 @@ func (w *SingleAddressWallet) FundTransaction(cs consensus.State, txn *types.Tra
 		return nil, fmt.Errorf("%w: inputSum: %v, amount: %v", ErrInsufficientBalance, inputSum.String(), amount.String())
 	}
 
+	// desc sort the remaining utxos
+	sort.Slice(usableUTXOs, func(i, j int) bool {
+		return usableUTXOs[i].Value.Cmp(usableUTXOs[j].Value) > 0
+	})
+
 	// check if remaining utxos should be defragged
 	txnInputs := len(txn.SiacoinInputs) + len(selected)
 	if len(usableUTXOs) > transactionDefragThreshold && txnInputs < maxInputsForDefrag {
@@ -303,9 +456,13 @@ func (w *SingleAddressWallet) FundTransaction(cs consensus.State, txn *types.Tra
 
 	// add a change output if necessary
 	if inputSum.Cmp(amount) > 0 {
+		changeAddr := w.addr
+		if from != types.VoidAddress {
+			changeAddr = from
+		}
 		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
 			Value:   inputSum.Sub(amount),
-			Address: w.addr,
+			Address: changeAddr,
 		})
 	}
 
@@ -313,7 +470,7 @@ func (w *SingleAddressWallet) FundTransaction(cs consensus.State, txn *types.Tra
 	for i, sce := range selected {
 		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
 			ParentID:         types.SiacoinOutputID(sce.ID),
-			UnlockConditions: types.StandardUnlockConditions(w.priv.PublicKey()),
+			UnlockConditions: w.unlockConditions(sce.Address),
 		})
 		toSign[i] = types.Hash256(sce.ID)
 		w.lastUsed[sce.ID] = time.Now()
@@ -322,6 +479,29 @@ func (w *SingleAddressWallet) FundTransaction(cs consensus.State, txn *types.Tra
 	return toSign, nil
 }
 
+// filterUsed removes all outputs that are locked or spent from the given
+// outputs. If an address other than the void address is passed, outputs of
+// other addresses are removed as well.
+func (w *SingleAddressWallet) filterUsed(utxos []SiacoinElement, addr types.Address) []SiacoinElement {
+	filtered := make([]SiacoinElement, 0, len(utxos))
+	for _, sce := range utxos {
+		if w.isOutputUsed(sce.ID) || (addr != types.VoidAddress && sce.Address != addr) {
+			continue
+		}
+		filtered = append(filtered, sce)
+	}
+	return filtered
+}
+
+// unlockConditions returns the unlock conditions of the given wallet address.
+func (w *SingleAddressWallet) unlockConditions(addr types.Address) types.UnlockConditions {
+	key, ok := w.keys[addr]
+	if !ok {
+		key = w.priv
+	}
+	return StandardUnlockConditions(key.PublicKey())
+}
+
 // ReleaseInputs is a helper function that releases the inputs of txn for use in
 // other transactions. It should only be called on transactions that are invalid
 // or will never be broadcast.
@@ -341,6 +521,14 @@ func (w *SingleAddressWallet) releaseInputs(txns ...types.Transaction) {
 
 // SignTransaction adds a signature to each of the specified inputs.
 func (w *SingleAddressWallet) SignTransaction(cs consensus.State, txn *types.Transaction, toSign []types.Hash256, cf types.CoveredFields) error {
+	// map the inputs to the keys that control them
+	keys := make(map[types.Hash256]types.PrivateKey)
+	for _, sci := range txn.SiacoinInputs {
+		if key, ok := w.keys[sci.UnlockConditions.UnlockHash()]; ok {
+			keys[types.Hash256(sci.ParentID)] = key
+		}
+	}
+
 	for _, id := range toSign {
 		ts := types.TransactionSignature{
 			ParentID:       id,
@@ -353,13 +541,108 @@ func (w *SingleAddressWallet) SignTransaction(cs consensus.State, txn *types.Tra
 		} else {
 			h = cs.PartialSigHash(*txn, cf)
 		}
-		sig := w.priv.SignHash(h)
+		key, ok := keys[id]
+		if !ok {
+			key = w.priv
+		}
+		sig := key.SignHash(h)
 		ts.Signature = sig[:]
 		txn.Signatures = append(txn.Signatures, ts)
 	}
 	return nil
 }
 
+// Defrag returns a transaction that consolidates up to maxInputs of the
+// wallet's smallest outputs into a single output if the wallet has more than
+// threshold usable outputs. The transaction's fee never exceeds maxFee, if
+// necessary fewer outputs are consolidated. Outputs that are worth less than
+// the fee to spend them are ignored. If no consolidation is necessary, an empty
+// transaction is returned.
+func (w *SingleAddressWallet) Defrag(cs consensus.State, threshold, maxInputs int, feePerByte, maxFee types.Currency, pool []types.Transaction) (types.Transaction, []types.Hash256, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// build map of inputs currently in the tx pool
+	inPool := make(map[types.Hash256]bool)
+	for _, ptxn := range pool {
+		for _, in := range ptxn.SiacoinInputs {
+			inPool[types.Hash256(in.ParentID)] = true
+		}
+	}
+
+	// fetch unspent transaction outputs
+	utxos, err := w.store.UnspentSiacoinElements(false)
+	if err != nil {
+		return types.Transaction{}, nil, err
+	}
+
+	// filter out outputs that can't be used
+	usable := utxos[:0]
+	for _, sce := range utxos {
+		inUse := w.isOutputUsed(sce.ID) || inPool[sce.ID]
+		matured := cs.Index.Height >= sce.MaturityHeight
+		if !inUse && matured {
+			usable = append(usable, sce)
+		}
+	}
+
+	// check whether a consolidation is necessary
+	if len(usable) <= threshold {
+		return types.Transaction{}, nil, nil
+	}
+
+	// asc sort
+	sort.Slice(usable, func(i, j int) bool {
+		return usable[i].Value.Cmp(usable[j].Value) < 0
+	})
+
+	// estimate the fees
+	output := types.SiacoinOutput{Address: w.addr}
+	outputFee := feePerByte.Mul64(uint64(len(encoding.Marshal([]types.SiacoinOutput{output}))))
+	feePerInput := feePerByte.Mul64(BytesPerInput)
+
+	// collect the smallest outputs that are worth spending without exceeding
+	// the fee budget
+	var inputs []SiacoinElement
+	fee := outputFee
+	for _, sce := range usable {
+		if len(inputs) >= maxInputs || fee.Add(feePerInput).Cmp(maxFee) > 0 {
+			break
+		} else if sce.Value.Cmp(feePerInput) <= 0 {
+			continue
+		}
+		inputs = append(inputs, sce)
+		fee = fee.Add(feePerInput)
+	}
+
+	// consolidating a single output is pointless
+	if len(inputs) < 2 {
+		return types.Transaction{}, nil, fmt.Errorf("%w: fee budget %v only allows for consolidating %v outputs", ErrDefragFeeTooHigh, maxFee, len(inputs))
+	}
+
+	// the inputs need to cover the fee, including the fee of the output
+	if sum := SumOutputs(inputs); sum.Cmp(fee) <= 0 {
+		return types.Transaction{}, nil, fmt.Errorf("%w: value of the inputs %v doesn't cover the fee %v", ErrDefragFeeTooHigh, sum, fee)
+	}
+
+	// build the transaction
+	output.Value = SumOutputs(inputs).Sub(fee)
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{output},
+		MinerFees:      []types.Currency{fee},
+	}
+	toSign := make([]types.Hash256, 0, len(inputs))
+	for _, sce := range inputs {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         types.SiacoinOutputID(sce.ID),
+			UnlockConditions: w.unlockConditions(sce.Address),
+		})
+		toSign = append(toSign, sce.ID)
+		w.lastUsed[sce.ID] = time.Now()
+	}
+	return txn, toSign, nil
+}
+
 // Redistribute returns a transaction that redistributes money in the wallet by
 // selecting a minimal set of inputs to cover the creation of the requested
 // outputs. It also returns a list of output IDs that need to be signed.
@@ -469,7 +752,7 @@ func (w *SingleAddressWallet) Redistribute(cs consensus.State, outputs int, amou
 		for _, sce := range inputs {
 			txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
 				ParentID:         types.SiacoinOutputID(sce.ID),
-				UnlockConditions: StandardUnlockConditions(w.priv.PublicKey()),
+				UnlockConditions: w.unlockConditions(sce.Address),
 			})
 			toSign = append(toSign, sce.ID)
 			w.lastUsed[sce.ID] = time.Now()
@@ -573,7 +856,7 @@ func (w *SingleAddressWallet) ReceiveUpdatedUnconfirmedTransactions(diff *module
 				Timestamp: time.Now(),
 			}
 			for _, sci := range txn.SiacoinInputs {
-				if sci.UnlockConditions.UnlockHash() != w.addr {
+				if _, ok := w.keys[sci.UnlockConditions.UnlockHash()]; !ok {
 					continue
 				}
 				relevant = true
@@ -590,7 +873,7 @@ func (w *SingleAddressWallet) ReceiveUpdatedUnconfirmedTransactions(diff *module
 			}
 
 			for i, sco := range txn.SiacoinOutputs {
-				if sco.Address != w.addr {
+				if _, ok := w.keys[sco.Address]; !ok {
 					continue
 				}
 				relevant = true
@@ -623,12 +906,67 @@ func SumOutputs(outputs []SiacoinElement) (sum types.Currency) {
 	return
 }
 
+// selectUTXOs selects outputs worth at least the given amount according to the
+// given strategy. It returns the selected outputs, the remaining outputs and
+// the sum of the selected outputs. If the outputs aren't sufficient to cover
+// the amount, all of them are selected.
+func selectUTXOs(utxos []SiacoinElement, amount types.Currency, strategy api.CoinSelectionStrategy) (selected, remaining []SiacoinElement, sum types.Currency) {
+	// sort the outputs, largest first unless the smallest outputs are
+	// preferred
+	sorted := append([]SiacoinElement(nil), utxos...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if strategy == api.CoinSelectionSmallestFirst {
+			return sorted[i].Value.Cmp(sorted[j].Value) < 0
+		}
+		return sorted[i].Value.Cmp(sorted[j].Value) > 0
+	})
+
+	for len(sorted) > 0 && sum.Cmp(amount) < 0 {
+		next := 0
+		if strategy == api.CoinSelectionMinimizeChange {
+			// use the smallest output that covers the remainder, if there is
+			// none we use the largest output
+			remainder := amount.Sub(sum)
+			if i := sort.Search(len(sorted), func(i int) bool {
+				return sorted[i].Value.Cmp(remainder) < 0
+			}); i > 0 {
+				next = i - 1
+			}
+		}
+		selected = append(selected, sorted[next])
+		sum = sum.Add(sorted[next].Value)
+		sorted = append(sorted[:next], sorted[next+1:]...)
+	}
+	return selected, sorted, sum
+}
+
 // NewSingleAddressWallet returns a new SingleAddressWallet using the provided private key and store.
 func NewSingleAddressWallet(priv types.PrivateKey, store SingleAddressStore, usedUTXOExpiry time.Duration, log *zap.SugaredLogger) *SingleAddressWallet {
+	return NewSingleAddressWalletWithAddresses(priv, 1, store, usedUTXOExpiry, log)
+}
+
+// NewSingleAddressWalletWithAddresses returns a new SingleAddressWallet that
+// manages the first n addresses derived from the provided private key, the
+// first one being its primary address. The store is expected to track the
+// outputs of all of these addresses.
+func NewSingleAddressWalletWithAddresses(priv types.PrivateKey, n uint64, store SingleAddressStore, usedUTXOExpiry time.Duration, log *zap.SugaredLogger) *SingleAddressWallet {
+	if n == 0 {
+		n = 1
+	}
+	keys := make(map[types.Address]types.PrivateKey)
+	addrs := make([]types.Address, n)
+	for i := range addrs {
+		key := DeriveAddressKey(priv, uint64(i))
+		addrs[i] = StandardAddress(key.PublicKey())
+		keys[addrs[i]] = key
+	}
 	return &SingleAddressWallet{
 		priv:           priv,
-		addr:           StandardAddress(priv.PublicKey()),
+		addr:           addrs[0],
+		addrs:          addrs,
+		keys:           keys,
 		store:          store,
+		coinSelection:  api.CoinSelectionLargestFirst,
 		lastUsed:       make(map[types.Hash256]time.Time),
 		usedUTXOExpiry: usedUTXOExpiry,
 		tpoolTxns:      make(map[types.Hash256][]Transaction),
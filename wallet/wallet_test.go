@@ -2,7 +2,9 @@ package wallet
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,7 +26,7 @@ func (s *mockStore) Height() uint64                   { return 0 }
 func (s *mockStore) UnspentSiacoinElements(bool) ([]SiacoinElement, error) {
 	return s.utxos, nil
 }
-func (s *mockStore) Transactions(before, since time.Time, offset, limit int) ([]Transaction, error) {
+func (s *mockStore) Transactions(before, since time.Time, txnType string, marker types.TransactionID, offset, limit int) ([]Transaction, error) {
 	return nil, nil
 }
 func (s *mockStore) RecordWalletMetric(ctx context.Context, metrics ...api.WalletMetric) error {
@@ -189,7 +191,320 @@ func TestWalletRedistribute(t *testing.T) {
 	}
 }
 
+// TestWalletCoinSelection asserts the wallet selects the expected outputs for
+// every coin selection strategy.
+func TestWalletCoinSelection(t *testing.T) {
+	oneSC := types.Siacoins(1)
+
+	// create a set of utxos
+	priv := types.GeneratePrivateKey()
+	var utxos []SiacoinElement
+	for _, sc := range []uint64{5, 1, 20, 2, 10} {
+		utxos = append(utxos, SiacoinElement{
+			types.SiacoinOutput{
+				Value:   oneSC.Mul64(sc),
+				Address: StandardAddress(priv.PublicKey()),
+			},
+			randomOutputID(),
+			0,
+		})
+	}
+
+	tests := []struct {
+		strategy api.CoinSelectionStrategy
+		amount   uint64
+		inputs   []uint64
+		change   uint64
+	}{
+		{api.CoinSelectionLargestFirst, 6, []uint64{20}, 14},
+		{api.CoinSelectionLargestFirst, 25, []uint64{20, 10}, 5},
+		{api.CoinSelectionSmallestFirst, 6, []uint64{1, 2, 5}, 2},
+		{api.CoinSelectionSmallestFirst, 25, []uint64{1, 2, 5, 10, 20}, 13},
+		{api.CoinSelectionMinimizeChange, 6, []uint64{10}, 4},
+		{api.CoinSelectionMinimizeChange, 25, []uint64{20, 5}, 0},
+	}
+	for _, test := range tests {
+		// create a new wallet for every test to avoid locked outputs
+		w := NewSingleAddressWallet(priv, &mockStore{utxos: append([]SiacoinElement(nil), utxos...)}, 0, zap.NewNop().Sugar())
+		if err := w.SetCoinSelectionStrategy(test.strategy); err != nil {
+			t.Fatal(err)
+		}
+
+		// fund a transaction
+		var txn types.Transaction
+		if _, err := w.FundTransaction(cs, &txn, oneSC.Mul64(test.amount), false); err != nil {
+			t.Fatal(err)
+		}
+
+		// assert the expected inputs were selected
+		values := make(map[types.SiacoinOutputID]types.Currency)
+		for _, utxo := range utxos {
+			values[types.SiacoinOutputID(utxo.ID)] = utxo.Value
+		}
+		if len(txn.SiacoinInputs) != len(test.inputs) {
+			t.Fatalf("%v: unexpected number of inputs, %v != %v", test.strategy, len(txn.SiacoinInputs), len(test.inputs))
+		}
+		for i, input := range txn.SiacoinInputs {
+			if !values[input.ParentID].Equals(oneSC.Mul64(test.inputs[i])) {
+				t.Fatalf("%v: unexpected input %v, %v != %v", test.strategy, i, values[input.ParentID], oneSC.Mul64(test.inputs[i]))
+			}
+		}
+
+		// assert the change output
+		if test.change == 0 && len(txn.SiacoinOutputs) != 0 {
+			t.Fatalf("%v: unexpected change output", test.strategy)
+		} else if test.change != 0 && (len(txn.SiacoinOutputs) != 1 || !txn.SiacoinOutputs[0].Value.Equals(oneSC.Mul64(test.change))) {
+			t.Fatalf("%v: unexpected change output, %v", test.strategy, txn.SiacoinOutputs)
+		}
+	}
+
+	// assert unknown strategies are rejected
+	w := NewSingleAddressWallet(priv, &mockStore{}, 0, zap.NewNop().Sugar())
+	if err := w.SetCoinSelectionStrategy("foo"); !errors.Is(err, api.ErrInvalidCoinSelectionStrategy) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// TestWalletConcurrentFunding asserts concurrent calls to FundTransaction never
+// select the same outputs and that reserved outputs become available again
+// once they are released.
+func TestWalletConcurrentFunding(t *testing.T) {
+	priv := types.GeneratePrivateKey()
+	var utxos []SiacoinElement
+	for i := 0; i < 100; i++ {
+		utxos = append(utxos, SiacoinElement{
+			types.SiacoinOutput{
+				Value:   types.Siacoins(1),
+				Address: StandardAddress(priv.PublicKey()),
+			},
+			randomOutputID(),
+			0,
+		})
+	}
+	w := NewSingleAddressWallet(priv, &mockStore{utxos: utxos}, time.Hour, zap.NewNop().Sugar())
+
+	// fund transactions from two goroutines until the wallet runs dry
+	var wg sync.WaitGroup
+	txnsChan := make(chan types.Transaction, len(utxos))
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var txn types.Transaction
+				if _, err := w.FundTransaction(cs, &txn, types.Siacoins(2), false); errors.Is(err, ErrInsufficientBalance) {
+					return
+				} else if err != nil {
+					t.Error(err)
+					return
+				}
+				txnsChan <- txn
+			}
+		}()
+	}
+	wg.Wait()
+	close(txnsChan)
+
+	// assert no output was used twice and all outputs were used
+	var txns []types.Transaction
+	used := make(map[types.SiacoinOutputID]bool)
+	for txn := range txnsChan {
+		for _, sci := range txn.SiacoinInputs {
+			if used[sci.ParentID] {
+				t.Fatalf("output %v was selected more than once", sci.ParentID)
+			}
+			used[sci.ParentID] = true
+		}
+		txns = append(txns, txn)
+	}
+	if len(used) != len(utxos) {
+		t.Fatalf("expected %v outputs to be used, got %v", len(utxos), len(used))
+	}
+
+	// release the inputs of one transaction and assert they can be used again
+	w.ReleaseInputs(txns[0])
+	var txn types.Transaction
+	if _, err := w.FundTransaction(cs, &txn, types.Siacoins(2), false); err != nil {
+		t.Fatal(err)
+	}
+	for _, sci := range txn.SiacoinInputs {
+		var released bool
+		for _, rci := range txns[0].SiacoinInputs {
+			released = released || rci.ParentID == sci.ParentID
+		}
+		if !released {
+			t.Fatalf("output %v was not released", sci.ParentID)
+		}
+	}
+}
+
+// TestWalletDefrag asserts the wallet consolidates its smallest outputs without
+// exceeding the fee budget and is a no-op if the wallet isn't fragmented.
+func TestWalletDefrag(t *testing.T) {
+	oneSC := types.Siacoins(1)
+	feePerByte := oneSC.Div64(1000)
+
+	// create a wallet with many small outputs and a dust output that's not
+	// worth spending
+	priv := types.GeneratePrivateKey()
+	addr := StandardAddress(priv.PublicKey())
+	var utxos []SiacoinElement
+	for _, sc := range frand.Perm(50) {
+		utxos = append(utxos, SiacoinElement{types.SiacoinOutput{Value: oneSC.Mul64(uint64(sc + 1)), Address: addr}, randomOutputID(), 0})
+	}
+	dust := SiacoinElement{types.SiacoinOutput{Value: feePerByte.Mul64(BytesPerInput - 1), Address: addr}, randomOutputID(), 0}
+	utxos = append(utxos, dust)
+	w := NewSingleAddressWallet(priv, &mockStore{utxos: utxos}, 0, zap.NewNop().Sugar())
+
+	// assert defragging is a no-op if the wallet isn't fragmented enough
+	if _, toSign, err := w.Defrag(cs, len(utxos), 20, feePerByte, oneSC.Mul64(100), nil); err != nil {
+		t.Fatal(err)
+	} else if len(toSign) != 0 {
+		t.Fatal("expected no-op")
+	}
+
+	// assert the fee budget must allow for consolidating at least 2 outputs
+	if _, _, err := w.Defrag(cs, 10, 20, feePerByte, feePerByte.Mul64(BytesPerInput), nil); !errors.Is(err, ErrDefragFeeTooHigh) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// defrag the wallet with a budget that allows for 15 inputs
+	maxFee := feePerByte.Mul64(BytesPerInput*15 + 100)
+	txn, toSign, err := w.Defrag(cs, 10, 20, feePerByte, maxFee, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(toSign) != 15 || len(txn.SiacoinInputs) != 15 {
+		t.Fatalf("unexpected number of inputs, %v != 15", len(txn.SiacoinInputs))
+	} else if len(txn.MinerFees) != 1 || txn.MinerFees[0].Cmp(maxFee) > 0 {
+		t.Fatalf("unexpected fee %v, max %v", txn.MinerFees, maxFee)
+	}
+
+	// assert the smallest outputs were consolidated, skipping the dust
+	values := make(map[types.SiacoinOutputID]types.Currency)
+	for _, utxo := range utxos {
+		values[types.SiacoinOutputID(utxo.ID)] = utxo.Value
+	}
+	var sum types.Currency
+	for _, in := range txn.SiacoinInputs {
+		if in.ParentID == types.SiacoinOutputID(dust.ID) {
+			t.Fatal("dust output was consolidated")
+		} else if values[in.ParentID].Cmp(oneSC.Mul64(15)) > 0 {
+			t.Fatalf("unexpected input with value %v", values[in.ParentID])
+		}
+		sum = sum.Add(values[in.ParentID])
+	}
+
+	// assert the output
+	if len(txn.SiacoinOutputs) != 1 {
+		t.Fatalf("unexpected number of outputs, %v != 1", len(txn.SiacoinOutputs))
+	} else if out := txn.SiacoinOutputs[0]; out.Address != addr || !out.Value.Equals(sum.Sub(txn.MinerFees[0])) {
+		t.Fatalf("unexpected output %v", out)
+	}
+
+	// assert outputs that are barely worth spending don't cause the output
+	// value to underflow when they don't cover the fee of the output
+	utxos = utxos[:0]
+	for i := 0; i < 11; i++ {
+		utxos = append(utxos, SiacoinElement{types.SiacoinOutput{Value: feePerByte.Mul64(BytesPerInput).Add(types.NewCurrency64(1)), Address: addr}, randomOutputID(), 0})
+	}
+	w = NewSingleAddressWallet(priv, &mockStore{utxos: utxos}, 0, zap.NewNop().Sugar())
+	if _, _, err := w.Defrag(cs, 10, 2, feePerByte, oneSC.Mul64(100), nil); !errors.Is(err, ErrDefragFeeTooHigh) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// TestWalletAddressBalances asserts the wallet tracks the balance of every
+// address it manages and can fund transactions from a specific address.
+func TestWalletAddressBalances(t *testing.T) {
+	oneSC := types.Siacoins(1)
+
+	// create a wallet with two addresses
+	priv := types.GeneratePrivateKey()
+	s := &mockStore{}
+	w := NewSingleAddressWalletWithAddresses(priv, 2, s, 0, zap.NewNop().Sugar())
+	addrs := w.Addresses()
+	if len(addrs) != 2 {
+		t.Fatalf("unexpected number of addresses, %v != 2", len(addrs))
+	} else if addrs[0] != w.Address() || addrs[0] != StandardAddress(priv.PublicKey()) {
+		t.Fatal("first address should be the primary address")
+	} else if addrs[1] == addrs[0] {
+		t.Fatal("addresses should be unique")
+	}
+
+	// receive outputs to both addresses
+	receive := func(addr types.Address, sc uint64) {
+		s.utxos = append(s.utxos, SiacoinElement{types.SiacoinOutput{Value: oneSC.Mul64(sc), Address: addr}, randomOutputID(), 0})
+	}
+	receive(addrs[0], 1)
+	receive(addrs[0], 2)
+	receive(addrs[1], 5)
+	receive(addrs[1], 10)
+
+	// assert the balances
+	assertBalances := func(spendable0, spendable1 uint64) {
+		t.Helper()
+		balances, err := w.AddressBalances()
+		if err != nil {
+			t.Fatal(err)
+		} else if len(balances) != 2 {
+			t.Fatalf("unexpected number of balances, %v != 2", len(balances))
+		}
+		for i, b := range balances {
+			expected := []uint64{3, 15}[i]
+			spendable := []uint64{spendable0, spendable1}[i]
+			if b.Address != addrs[i] {
+				t.Fatalf("unexpected address %v != %v", b.Address, addrs[i])
+			} else if !b.Confirmed.Equals(oneSC.Mul64(expected)) {
+				t.Fatalf("unexpected confirmed balance for address %v, %v != %v", i, b.Confirmed, oneSC.Mul64(expected))
+			} else if !b.Spendable.Equals(oneSC.Mul64(spendable)) {
+				t.Fatalf("unexpected spendable balance for address %v, %v != %v", i, b.Spendable, oneSC.Mul64(spendable))
+			}
+		}
+	}
+	assertBalances(3, 15)
+
+	// fund a transaction from the second address
+	var txn types.Transaction
+	toSign, err := w.FundTransactionFromAddress(cs, &txn, oneSC.Mul64(4), false, addrs[1])
+	if err != nil {
+		t.Fatal(err)
+	} else if len(toSign) != 1 {
+		t.Fatalf("unexpected number of inputs, %v != 1", len(toSign))
+	} else if txn.SiacoinInputs[0].UnlockConditions.UnlockHash() != addrs[1] {
+		t.Fatal("input should be spent from the second address")
+	} else if len(txn.SiacoinOutputs) != 1 || txn.SiacoinOutputs[0].Address != addrs[1] {
+		t.Fatal("change should be sent back to the second address")
+	}
+	assertBalances(3, 5)
+
+	// assert funding from an unknown address fails
+	if _, err := w.FundTransactionFromAddress(cs, &types.Transaction{}, oneSC, false, types.Address{1}); !errors.Is(err, ErrUnknownAddress) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
 func randomOutputID() (t types.Hash256) {
 	frand.Read(t[:])
 	return
 }
+
+func TestTransactionType(t *testing.T) {
+	for _, test := range []struct {
+		txn      types.Transaction
+		inflow   types.Currency
+		outflow  types.Currency
+		expected string
+	}{
+		{types.Transaction{FileContracts: []types.FileContract{{}}}, types.ZeroCurrency, types.Siacoins(1), TransactionTypeContract},
+		{types.Transaction{FileContracts: []types.FileContract{{}}, FileContractRevisions: []types.FileContractRevision{{}}}, types.ZeroCurrency, types.Siacoins(1), TransactionTypeRenewal},
+		{types.Transaction{}, types.Siacoins(1), types.Siacoins(2), TransactionTypeSend},
+		{types.Transaction{}, types.Siacoins(2), types.Siacoins(1), TransactionTypeReceive},
+		{types.Transaction{}, types.Siacoins(1), types.ZeroCurrency, TransactionTypeReceive},
+		{types.Transaction{}, types.Siacoins(1), types.Siacoins(1), TransactionTypeOther},
+		{types.Transaction{}, types.ZeroCurrency, types.ZeroCurrency, TransactionTypeOther},
+	} {
+		if typ := TransactionType(test.txn, test.inflow, test.outflow); typ != test.expected {
+			t.Fatalf("expected %v, got %v", test.expected, typ)
+		}
+	}
+}
@@ -36,8 +36,16 @@ func (NoopBroadcaster) BroadcastAction(_ context.Context, _ Event) error { retur
 const (
 	webhookTimeout   = 10 * time.Second
 	WebhookEventPing = "ping"
+
+	// webhookMaxAttempts is the number of times delivering an event is
+	// attempted before it is dropped.
+	webhookMaxAttempts = 5
 )
 
+// webhookRetryBackoff is the time to wait before retrying to deliver an event
+// for the first time, it doubles with every failed attempt.
+var webhookRetryBackoff = time.Second
+
 type (
 	Webhook struct {
 		Module string `json:"module"`
@@ -195,7 +203,7 @@ func (q *eventQueue) dequeue() {
 		q.events = q.events[1:]
 		q.mu.Unlock()
 
-		err := sendEvent(q.ctx, q.url, next)
+		err := sendEventWithRetry(q.ctx, q.url, next, webhookMaxAttempts, webhookRetryBackoff)
 		if err != nil {
 			q.logger.Errorf("failed to send Webhook event %v to %v: %v", next.String(), q.url, err)
 		}
@@ -235,6 +243,28 @@ func NewManager(logger *zap.SugaredLogger, store WebhookStore) (*Manager, error)
 	return m, nil
 }
 
+// sendEventWithRetry sends the event to the given url, retrying with an
+// exponential backoff until it's delivered or maxAttempts is reached.
+func sendEventWithRetry(ctx context.Context, url string, action Event, maxAttempts int, backoff time.Duration) (err error) {
+	for attempt := 1; ; attempt++ {
+		err = func() error {
+			ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+			defer cancel()
+			return sendEvent(ctx, url, action)
+		}()
+		if err == nil || attempt >= maxAttempts {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w; %w", context.Cause(ctx), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
 func sendEvent(ctx context.Context, url string, action Event) error {
 	body, err := json.Marshal(action)
 	if err != nil {
@@ -251,8 +281,9 @@ func sendEvent(ctx context.Context, url string, action Event) error {
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		errStr, err := io.ReadAll(req.Body)
+		errStr, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return fmt.Errorf("failed to read response body: %w", err)
 		}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+type (
+	HostBandwidthRecorder interface {
+		Record(hk types.PublicKey, uploaded, downloaded uint64)
+		Stop(context.Context)
+	}
+
+	hostBandwidthRecorder struct {
+		*batchRecorder
+	}
+
+	// bandwidthBatch is a batch of bandwidth records, keyed by host.
+	bandwidthBatch map[types.PublicKey]api.HostBandwidth
+
+	// bandwidthConn is a net.Conn that records the bytes it transfers with
+	// the host on the other end. Reads are counted as downloaded bytes,
+	// writes as uploaded bytes.
+	bandwidthConn struct {
+		net.Conn
+		hk types.PublicKey
+		r  HostBandwidthRecorder
+	}
+)
+
+var (
+	_ HostBandwidthRecorder = (*hostBandwidthRecorder)(nil)
+)
+
+func (w *worker) initHostBandwidthRecorder(flushInterval time.Duration) {
+	if w.hostBandwidthRecorder != nil {
+		panic("HostBandwidthRecorder already initialized") // developer error
+	}
+	w.hostBandwidthRecorder = newHostBandwidthRecorder(w.shutdownCtx, w.bus, w.logger, flushInterval)
+}
+
+func newHostBandwidthRecorder(ctx context.Context, bus Bus, logger *zap.SugaredLogger, flushInterval time.Duration) *hostBandwidthRecorder {
+	return &hostBandwidthRecorder{
+		batchRecorder: newBatchRecorder(ctx, bus, logger, "host bandwidth records", flushInterval, 0, func() recordBatch {
+			return make(bandwidthBatch)
+		}),
+	}
+}
+
+// wrapBandwidthConn wraps the given connection to the host with the given key
+// so the bytes it transfers are recorded by the given recorder.
+func wrapBandwidthConn(conn net.Conn, hk types.PublicKey, r HostBandwidthRecorder) net.Conn {
+	if r == nil {
+		return conn
+	}
+	return &bandwidthConn{Conn: conn, hk: hk, r: r}
+}
+
+func (c *bandwidthConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.r.Record(c.hk, 0, uint64(n))
+	}
+	return n, err
+}
+
+func (c *bandwidthConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.r.Record(c.hk, uint64(n), 0)
+	}
+	return n, err
+}
+
+// Record adds the given number of bytes to the host's bandwidth until it gets
+// flushed to the bus.
+func (r *hostBandwidthRecorder) Record(hk types.PublicKey, uploaded, downloaded uint64) {
+	r.record(func(b recordBatch) {
+		b.(bandwidthBatch).add(api.HostBandwidth{
+			HostKey:    hk,
+			Uploaded:   uploaded,
+			Downloaded: downloaded,
+		})
+	})
+}
+
+func (b bandwidthBatch) add(bw api.HostBandwidth) {
+	total := b[bw.HostKey]
+	total.HostKey = bw.HostKey
+	total.Uploaded += bw.Uploaded
+	total.Downloaded += bw.Downloaded
+	b[bw.HostKey] = total
+}
+
+func (b bandwidthBatch) len() int {
+	return len(b)
+}
+
+func (b bandwidthBatch) merge(other recordBatch) {
+	for _, bw := range other.(bandwidthBatch) {
+		b.add(bw)
+	}
+}
+
+func (b bandwidthBatch) send(ctx context.Context, bus Bus) error {
+	records := make([]api.HostBandwidth, 0, len(b))
+	for _, bw := range b {
+		records = append(records, bw)
+	}
+	return bus.RecordHostBandwidth(ctx, records)
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+	"lukechampine.com/frand"
+)
+
+func TestHostBandwidthRecorder(t *testing.T) {
+	hs := newHostStoreMock()
+	r := newHostBandwidthRecorder(context.Background(), newBusMock(newContractStoreMock(), hs, newObjectStoreMock(testBucket)), zap.NewNop().Sugar(), time.Hour)
+
+	// transfer writes 'upload' bytes to and reads 'download' bytes from the
+	// host with the given key
+	transfer := func(hk types.PublicKey, upload, download int) {
+		t.Helper()
+
+		c1, c2 := net.Pipe()
+		defer c1.Close()
+		defer c2.Close()
+		conn := wrapBandwidthConn(c1, hk, r)
+
+		errChan := make(chan error, 1)
+		go func() {
+			if _, err := io.ReadFull(c2, make([]byte, upload)); err != nil {
+				errChan <- err
+				return
+			}
+			if download == 0 {
+				errChan <- nil
+				return
+			}
+			_, err := c2.Write(frand.Bytes(download))
+			errChan <- err
+		}()
+
+		if _, err := conn.Write(frand.Bytes(upload)); err != nil {
+			t.Fatal(err)
+		} else if _, err := io.ReadFull(conn, make([]byte, download)); err != nil {
+			t.Fatal(err)
+		} else if err := <-errChan; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// transfer data with two hosts
+	hk1, hk2 := types.PublicKey{1}, types.PublicKey{2}
+	transfer(hk1, 100, 200)
+	transfer(hk2, 300, 0)
+	transfer(hk1, 50, 25)
+
+	// stop the recorder to flush the bandwidth
+	r.Stop(context.Background())
+
+	// assert the bandwidth was accumulated per host
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	bandwidth := make(map[types.PublicKey]api.HostBandwidth)
+	for _, bw := range hs.bandwidth {
+		bandwidth[bw.HostKey] = bw
+	}
+	if len(hs.bandwidth) != 2 {
+		t.Fatalf("expected bandwidth for 2 hosts, got %v", len(hs.bandwidth))
+	} else if bw := bandwidth[hk1]; bw.Uploaded != 150 || bw.Downloaded != 225 {
+		t.Fatalf("unexpected bandwidth for host 1 %+v", bw)
+	} else if bw := bandwidth[hk2]; bw.Uploaded != 300 || bw.Downloaded != 0 {
+		t.Fatalf("unexpected bandwidth for host 2 %+v", bw)
+	}
+}
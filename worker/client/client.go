@@ -77,6 +77,12 @@ func (c *Client) DownloadStats() (resp api.DownloadStatsResponse, err error) {
 	return
 }
 
+// PriceTableStats returns statistics about the worker's price table cache.
+func (c *Client) PriceTableStats() (resp api.PriceTableStatsResponse, err error) {
+	err = c.c.GET("/stats/pricetables", &resp)
+	return
+}
+
 // HeadObject returns the metadata of the object at the given path.
 func (c *Client) HeadObject(ctx context.Context, bucket, path string, opts api.HeadObjectOptions) (*api.HeadObjectResponse, error) {
 	c.c.Custom("HEAD", fmt.Sprintf("/objects/%s", path), nil, nil)
@@ -157,14 +163,28 @@ func (c *Client) Memory(ctx context.Context) (resp api.MemoryResponse, err error
 	return
 }
 
-// MigrateSlab migrates the specified slab.
-func (c *Client) MigrateSlab(ctx context.Context, slab object.Slab, set string) (res api.MigrateSlabResponse, err error) {
+// MigrateSlab migrates the specified slab. Sectors stored on any of the
+// excluded hosts are migrated as well and never migrated to those hosts.
+func (c *Client) MigrateSlab(ctx context.Context, slab object.Slab, set string, excludedHosts []types.PublicKey) (res api.MigrateSlabResponse, err error) {
 	values := make(url.Values)
 	values.Set("contractset", set)
+	for _, hk := range excludedHosts {
+		values.Add("exclude", hk.String())
+	}
 	err = c.c.WithContext(ctx).POST("/slab/migrate?"+values.Encode(), slab, &res)
 	return
 }
 
+// RekeySlab encrypts the data of the slab with given key using a newly
+// generated key, the data is re-uploaded to the given contract set.
+func (c *Client) RekeySlab(ctx context.Context, key object.EncryptionKey, set string) (slab object.Slab, err error) {
+	values := make(url.Values)
+	values.Set("key", key.String())
+	values.Set("contractset", set)
+	err = c.c.WithContext(ctx).POST("/slab/rekey?"+values.Encode(), nil, &slab)
+	return
+}
+
 // ObjectEntries returns the entries at the given path, which must end in /.
 func (c *Client) ObjectEntries(ctx context.Context, bucket, path string, opts api.GetObjectOptions) (entries []api.ObjectMetadata, err error) {
 	path = api.ObjectPathEscape(path)
@@ -180,12 +200,24 @@ func (c *Client) ObjectEntries(ctx context.Context, bucket, path string, opts ap
 	return
 }
 
+// RateLimits returns the bandwidth limits of the worker.
+func (c *Client) RateLimits(ctx context.Context) (limits api.WorkerRateLimits, err error) {
+	err = c.c.WithContext(ctx).GET("/ratelimit", &limits)
+	return
+}
+
 // State returns the current state of the worker.
 func (c *Client) State() (state api.WorkerStateResponse, err error) {
 	err = c.c.GET("/state", &state)
 	return
 }
 
+// UpdateRateLimits updates the bandwidth limits of the worker.
+func (c *Client) UpdateRateLimits(ctx context.Context, limits api.WorkerRateLimits) (err error) {
+	err = c.c.WithContext(ctx).PUT("/ratelimit", limits)
+	return
+}
+
 // UploadMultipartUploadPart uploads part of the data for a multipart upload.
 func (c *Client) UploadMultipartUploadPart(ctx context.Context, r io.Reader, bucket, path, uploadID string, partNumber int, opts api.UploadMultipartUploadPartOptions) (*api.UploadMultipartUploadPartResponse, error) {
 	path = api.ObjectPathEscape(path)
@@ -262,12 +294,57 @@ func (c *Client) UploadObject(ctx context.Context, r io.Reader, bucket, path str
 	return &api.UploadObjectResponse{ETag: resp.Header.Get("ETag")}, nil
 }
 
+// PatchObject overwrites the given range of the object at the given path with
+// the data in r.
+func (c *Client) PatchObject(ctx context.Context, r io.Reader, bucket, path string, opts api.PatchObjectOptions) (*api.PatchObjectResponse, error) {
+	path = api.ObjectPathEscape(path)
+	c.c.Custom("PATCH", fmt.Sprintf("/objects/%s", path), []byte{}, nil)
+
+	values := make(url.Values)
+	values.Set("bucket", bucket)
+	opts.ApplyValues(values)
+	u, err := url.Parse(fmt.Sprintf("%v/objects/%v", c.c.BaseURL, path))
+	if err != nil {
+		panic(err)
+	}
+	u.RawQuery = values.Encode()
+	req, err := http.NewRequestWithContext(ctx, "PATCH", u.String(), r)
+	if err != nil {
+		panic(err)
+	}
+	req.SetBasicAuth("", c.c.WithContext(ctx).Password)
+	opts.ApplyHeaders(req.Header)
+	req.ContentLength = opts.Length
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer io.Copy(io.Discard, resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		err, _ := io.ReadAll(resp.Body)
+		return nil, errors.New(string(err))
+	}
+	return &api.PatchObjectResponse{ETag: resp.Header.Get("ETag")}, nil
+}
+
 // UploadStats returns the upload stats.
 func (c *Client) UploadStats() (resp api.UploadStatsResponse, err error) {
 	err = c.c.GET("/stats/uploads", &resp)
 	return
 }
 
+// VerifyObject verifies that every slab of the object at the given path can be
+// recovered from the hosts, without downloading the object.
+func (c *Client) VerifyObject(ctx context.Context, bucket, path string) (resp api.VerifyObjectResponse, err error) {
+	values := url.Values{}
+	values.Set("bucket", bucket)
+
+	path = api.ObjectPathEscape(path)
+	err = c.c.WithContext(ctx).POST(fmt.Sprintf("/verify/objects/%s?"+values.Encode(), path), nil, &resp)
+	return
+}
+
 func (c *Client) object(ctx context.Context, bucket, path string, opts api.DownloadObjectOptions) (_ io.ReadCloser, _ http.Header, err error) {
 	values := url.Values{}
 	values.Set("bucket", url.QueryEscape(bucket))
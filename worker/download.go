@@ -13,6 +13,7 @@ import (
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/internal/utils"
 	"go.sia.tech/renterd/object"
 	"go.sia.tech/renterd/stats"
@@ -38,6 +39,9 @@ type (
 
 		maxOverdrive     uint64
 		overdriveTimeout time.Duration
+		overfetch        uint64
+
+		cache *sectorCache
 
 		statsOverdrivePct                *stats.DataPoints
 		statsSlabDownloadSpeedBytesPerMS *stats.DataPoints
@@ -127,16 +131,21 @@ type (
 	}
 )
 
-func (w *worker) initDownloadManager(maxMemory, maxOverdrive uint64, overdriveTimeout time.Duration, logger *zap.SugaredLogger) {
+func (w *worker) initDownloadManager(maxMemory, maxOverdrive, overfetch, cacheSize uint64, overdriveTimeout time.Duration, logger *zap.SugaredLogger) {
 	if w.downloadManager != nil {
 		panic("download manager already initialized") // developer error
 	}
 
 	mm := newMemoryManager(logger.Named("memorymanager"), maxMemory)
-	w.downloadManager = newDownloadManager(w.shutdownCtx, w, mm, w.bus, maxOverdrive, overdriveTimeout, logger)
+	w.downloadManager = newDownloadManager(w.shutdownCtx, w, mm, w.bus, maxOverdrive, overfetch, cacheSize, overdriveTimeout, logger)
 }
 
-func newDownloadManager(ctx context.Context, hm HostManager, mm MemoryManager, os ObjectStore, maxOverdrive uint64, overdriveTimeout time.Duration, logger *zap.SugaredLogger) *downloadManager {
+func newDownloadManager(ctx context.Context, hm HostManager, mm MemoryManager, os ObjectStore, maxOverdrive, overfetch, cacheSize uint64, overdriveTimeout time.Duration, logger *zap.SugaredLogger) *downloadManager {
+	var cache *sectorCache
+	if cacheSize > 0 {
+		cache = newSectorCache(cacheSize)
+	}
+
 	return &downloadManager{
 		hm:     hm,
 		mm:     mm,
@@ -145,6 +154,9 @@ func newDownloadManager(ctx context.Context, hm HostManager, mm MemoryManager, o
 
 		maxOverdrive:     maxOverdrive,
 		overdriveTimeout: overdriveTimeout,
+		overfetch:        overfetch,
+
+		cache: cache,
 
 		statsOverdrivePct:                stats.NoDecay(),
 		statsSlabDownloadSpeedBytesPerMS: stats.NoDecay(),
@@ -156,6 +168,10 @@ func newDownloadManager(ctx context.Context, hm HostManager, mm MemoryManager, o
 }
 
 func (mgr *downloadManager) DownloadObject(ctx context.Context, w io.Writer, o object.Object, offset, length uint64, contracts []api.ContractMetadata) (err error) {
+	// start the download span, it's the parent of all sector download spans
+	ctx, span := tracing.StartSpan(ctx, "worker.Download")
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// calculate what slabs we need
 	var ss []slabSlice
 	for _, s := range o.Slabs {
@@ -330,6 +346,13 @@ outer:
 						}
 					}
 
+					// flush the slab to stream it to the client while the
+					// slabs that follow are still being downloaded
+					if err := bw.Flush(); err != nil {
+						mgr.logger.Errorf("failed to flush slab %v: %v", respIndex, err)
+						return err
+					}
+
 					next = nil
 					delete(responses, respIndex)
 					respIndex++
@@ -354,7 +377,13 @@ outer:
 	return nil
 }
 
-func (mgr *downloadManager) DownloadSlab(ctx context.Context, slab object.Slab, contracts []api.ContractMetadata) ([][]byte, bool, error) {
+func (mgr *downloadManager) DownloadSlab(ctx context.Context, slab object.Slab, contracts []api.ContractMetadata) (_ [][]byte, _ bool, err error) {
+	// start the download span
+	ctx, span := tracing.StartSpan(ctx, "worker.DownloadSlab",
+		tracing.AttributeSlabKey.String(slab.Key.String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// refresh the downloaders
 	mgr.refreshDownloaders(contracts)
 
@@ -709,8 +738,13 @@ func (s *slabDownload) download(ctx context.Context) ([][]byte, bool, error) {
 	// launch overdrive
 	resetOverdrive := s.overdrive(ctx, resps)
 
-	// launch 'MinShard' requests
-	for i := 0; i < int(s.minShards); {
+	// serve as many sectors as possible from the cache
+	if s.serveFromCache() {
+		return s.finish()
+	}
+
+	// launch requests for the missing shards
+	for i, missing := 0, s.missing(); i < missing; {
 		req := s.nextRequest(ctx, resps, false)
 		if req == nil {
 			return nil, false, fmt.Errorf("no host available for shard %d", i)
@@ -719,6 +753,17 @@ func (s *slabDownload) download(ctx context.Context) ([][]byte, bool, error) {
 		i++
 	}
 
+	// launch 'overfetch' additional requests up front, the download finishes
+	// as soon as the fastest 'MinShard' hosts responded and the remaining
+	// requests are cancelled
+	for i := 0; i < int(s.mgr.overfetch); i++ {
+		req := s.nextRequest(ctx, resps, true)
+		if req == nil {
+			break
+		}
+		s.launch(req)
+	}
+
 	// collect requests that failed due to gouging
 	var gouging []*sectorDownloadReq
 
@@ -852,6 +897,37 @@ func (s *slabDownload) launch(req *sectorDownloadReq) {
 	}
 }
 
+// serveFromCache fills in the sectors that are available in the sector cache
+// and returns true if that's enough to complete the download.
+func (s *slabDownload) serveFromCache() (finished bool) {
+	if s.mgr.cache == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hk, sectors := range s.unusedHostSectors {
+		var uncached []sectorInfo
+		for _, sector := range sectors {
+			if s.numCompleted >= s.minShards || s.sectors[sector.index] != nil {
+				uncached = append(uncached, sector)
+			} else if data, ok := s.mgr.cache.Get(sector.Root, s.offset, s.length); ok {
+				s.sectors[sector.index] = data
+				s.numCompleted++
+			} else {
+				uncached = append(uncached, sector)
+			}
+		}
+		if len(uncached) == 0 {
+			delete(s.unusedHostSectors, hk)
+		} else {
+			s.unusedHostSectors[hk] = uncached
+		}
+	}
+	return s.numCompleted >= s.minShards
+}
+
 func (s *slabDownload) receive(resp sectorDownloadResp) (finished bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -873,9 +949,12 @@ func (s *slabDownload) receive(resp sectorDownloadResp) (finished bool) {
 		s.numOverpaid++
 	}
 
-	// store the sector
+	// store the sector, full sectors are cached
 	s.sectors[resp.req.sectorIndex] = resp.sector
 	s.numCompleted++
+	if s.offset == 0 && s.length == rhpv2.SectorSize {
+		s.mgr.cache.Add(resp.req.root, resp.sector)
+	}
 
 	return s.numCompleted >= s.minShards
 }
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"lukechampine.com/frand"
+)
+
+func TestDownloadOverfetch(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	hosts := w.AddHosts(testRedundancySettings.TotalShards)
+
+	// upload an object
+	data := frand.Bytes(128)
+	params := testParameters(t.Name())
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := w.os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// make all but 'MinShards' hosts unresponsive and disable overdrive, this
+	// ensures the download can only complete if all sectors are requested up
+	// front
+	slow := testRedundancySettings.TotalShards - testRedundancySettings.MinShards
+	for _, h := range hosts[:slow] {
+		h.downloadDelay = time.Hour
+	}
+	dm := w.downloadManager
+	dm.overdriveTimeout = 0
+	dm.overfetch = uint64(slow)
+
+	// download the object and assert it completes using the fast hosts
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var buf bytes.Buffer
+	if err := dm.DownloadObject(ctx, &buf, *o.Object.Object, 0, uint64(len(data)), w.Contracts()); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, buf.Bytes()) {
+		t.Fatal("data mismatch")
+	}
+}
+
+func TestDownloadStreamsSlabs(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	hosts := w.AddHosts(testRedundancySettings.TotalShards)
+
+	// upload an object that spans two full slabs
+	slabSize := testRedundancySettings.MinShards * rhpv2.SectorSize
+	data := frand.Bytes(2 * slabSize)
+	params := testParameters(t.Name())
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := w.os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(o.Object.Slabs) != 2 {
+		t.Fatalf("expected 2 slabs, got %v", len(o.Object.Slabs))
+	}
+
+	// download the first slab to populate the sector cache, this ensures the
+	// first slab doesn't depend on hosts that are busy with the second one
+	dm := w.downloadManager
+	dm.cache = newSectorCache(uint64(testRedundancySettings.TotalShards) * rhpv2.SectorSize)
+	if err := dm.DownloadObject(context.Background(), io.Discard, *o.Object.Object, 0, uint64(slabSize), w.Contracts()); err != nil {
+		t.Fatal(err)
+	}
+
+	// block the download of every sector of the second slab
+	unblock := make(chan struct{})
+	blocked := make(map[types.Hash256]chan struct{})
+	for _, shard := range o.Object.Slabs[1].Shards {
+		blocked[shard.Root] = unblock
+	}
+	for _, h := range hosts {
+		h.blockedSectors = blocked
+	}
+
+	// download the object
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(dm.DownloadObject(context.Background(), pw, *o.Object.Object, 0, uint64(len(data)), w.Contracts()))
+	}()
+	defer pr.Close()
+
+	// assert the first slab is streamed while the second one is blocked
+	first := make([]byte, slabSize)
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := io.ReadFull(pr, first)
+		readErr <- err
+	}()
+	select {
+	case err := <-readErr:
+		if err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(first, data[:slabSize]) {
+			t.Fatal("data mismatch")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("first slab wasn't streamed before the second slab was downloaded")
+	}
+
+	// unblock the second slab and assert the remainder is streamed
+	close(unblock)
+	if rest, err := io.ReadAll(pr); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(rest, data[slabSize:]) {
+		t.Fatal("data mismatch")
+	}
+}
+
+func TestDownloadSectorCache(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	hosts := w.AddHosts(testRedundancySettings.TotalShards)
+
+	// enable the sector cache
+	dm := w.downloadManager
+	dm.cache = newSectorCache(uint64(testRedundancySettings.TotalShards) * rhpv2.SectorSize)
+
+	// upload an object that spans a full slab
+	data := frand.Bytes(testRedundancySettings.MinShards * rhpv2.SectorSize)
+	params := testParameters(t.Name())
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := w.os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// helper to count the number of sector downloads
+	numDownloads := func() (n uint64) {
+		for _, h := range hosts {
+			n += h.numDownloads.Load()
+		}
+		return
+	}
+
+	// helper to download the object
+	download := func() {
+		t.Helper()
+		var buf bytes.Buffer
+		if err := dm.DownloadObject(context.Background(), &buf, *o.Object.Object, 0, uint64(len(data)), w.Contracts()); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(data, buf.Bytes()) {
+			t.Fatal("data mismatch")
+		}
+	}
+
+	// download the object, this should populate the cache
+	download()
+	if numDownloads() == 0 {
+		t.Fatal("expected sectors to be downloaded from the hosts")
+	} else if dm.cache.hits.Load() != 0 {
+		t.Fatal("unexpected cache hits")
+	}
+
+	// download it again and assert it was served from the cache
+	before := numDownloads()
+	download()
+	if numDownloads() != before {
+		t.Fatalf("expected no host calls, got %d", numDownloads()-before)
+	} else if hits := dm.cache.hits.Load(); hits != uint64(testRedundancySettings.MinShards) {
+		t.Fatalf("expected %d cache hits, got %d", testRedundancySettings.MinShards, hits)
+	}
+
+	// corrupt a cached sector and assert it's evicted and downloaded again
+	dm.cache.mu.Lock()
+	for _, e := range dm.cache.entries {
+		e.Value.(*sectorCacheEntry).sector[0] ^= 1
+		break
+	}
+	dm.cache.mu.Unlock()
+	download()
+	if numDownloads() == before {
+		t.Fatal("expected corrupted sector to be downloaded from the hosts")
+	}
+}
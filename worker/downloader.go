@@ -10,6 +10,7 @@ import (
 
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/stats"
 )
 
@@ -126,9 +127,15 @@ func (d *downloader) estimate() float64 {
 }
 
 func (d *downloader) execute(req *sectorDownloadReq) (err error) {
+	// start the sector download span, it's a child of the download span
+	ctx, span := tracing.StartSpan(req.ctx, "worker.DownloadSector",
+		tracing.AttributeHostKey.String(d.host.PublicKey().String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// download the sector
 	buf := bytes.NewBuffer(make([]byte, 0, req.length))
-	err = d.host.DownloadSector(req.ctx, buf, req.root, req.offset, req.length, req.overpay)
+	err = d.host.DownloadSector(ctx, buf, req.root, req.offset, req.length, req.overpay)
 	if err != nil {
 		req.fail(err)
 		return err
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"time"
 
 	rhpv2 "go.sia.tech/core/rhp/v2"
@@ -123,21 +125,69 @@ func (gc gougingChecker) Check(hs *rhpv2.HostSettings, pt *rhpv3.HostPriceTable)
 		panic("gouging checker needs to be provided with at least host settings or a price table") // developer error
 	}
 
+	contractErrs := []error{
+		checkContractGougingRHPv2(gc.period, gc.renewWindow, hs),
+		checkContractGougingRHPv3(gc.period, gc.renewWindow, pt),
+	}
+	downloadErr := checkDownloadGougingRHPv3(gc.settings, pt)
+	gougingErrs := []error{
+		checkPriceGougingPT(gc.settings, gc.consensusState, gc.txFee, pt),
+		checkPriceGougingHS(gc.settings, hs),
+	}
+	pruneErr := checkPruneGougingRHPv2(gc.settings, hs)
+	uploadErr := checkUploadGougingRHPv3(gc.settings, pt)
+
 	return api.HostGougingBreakdown{
-		ContractErr: errsToStr(
-			checkContractGougingRHPv2(gc.period, gc.renewWindow, hs),
-			checkContractGougingRHPv3(gc.period, gc.renewWindow, pt),
-		),
-		DownloadErr: errsToStr(checkDownloadGougingRHPv3(gc.settings, pt)),
-		GougingErr: errsToStr(
-			checkPriceGougingPT(gc.settings, gc.consensusState, gc.txFee, pt),
-			checkPriceGougingHS(gc.settings, hs),
-		),
-		PruneErr:  errsToStr(checkPruneGougingRHPv2(gc.settings, hs)),
-		UploadErr: errsToStr(checkUploadGougingRHPv3(gc.settings, pt)),
+		ContractErr: errsToStr(contractErrs...),
+		DownloadErr: errsToStr(downloadErr),
+		GougingErr:  errsToStr(gougingErrs...),
+		PruneErr:    errsToStr(pruneErr),
+		UploadErr:   errsToStr(uploadErr),
+
+		Reasons: gougingReasons(append(append(contractErrs, gougingErrs...), downloadErr, pruneErr, uploadErr)...),
 	}
 }
 
+// PercentileGougingSettings returns a copy of the given gouging settings where
+// the price limits are set to the configured percentile of the prices found in
+// the given price tables. The settings are returned unaltered if no percentile
+// is configured or if there are no price tables to derive the limits from.
+func PercentileGougingSettings(gs api.GougingSettings, pts []rhpv3.HostPriceTable) api.GougingSettings {
+	if gs.PricePercentile == 0 || len(pts) == 0 {
+		return gs
+	}
+
+	var rpc, contract, download, upload, storage []types.Currency
+	for _, pt := range pts {
+		rpc = append(rpc, pt.InitBaseCost)
+		contract = append(contract, pt.ContractPrice)
+		storage = append(storage, pt.WriteStoreCost)
+
+		if price, overflow := sectorReadCostRHPv3(pt); !overflow {
+			if dpptb, overflow := price.Mul64WithOverflow(1 << 40 / rhpv2.SectorSize); !overflow {
+				download = append(download, dpptb)
+			}
+		}
+		if price, overflow := sectorUploadCostRHPv3(pt); !overflow {
+			if upptb, overflow := price.Mul64WithOverflow(1 << 40 / rhpv2.SectorSize); !overflow {
+				upload = append(upload, upptb)
+			}
+		}
+	}
+
+	update := func(limit *types.Currency, prices []types.Currency) {
+		if len(prices) > 0 {
+			*limit = percentile(prices, gs.PricePercentile)
+		}
+	}
+	update(&gs.MaxRPCPrice, rpc)
+	update(&gs.MaxContractPrice, contract)
+	update(&gs.MaxDownloadPrice, download)
+	update(&gs.MaxUploadPrice, upload)
+	update(&gs.MaxStoragePrice, storage)
+	return gs
+}
+
 func checkPriceGougingHS(gs api.GougingSettings, hs *rhpv2.HostSettings) error {
 	// check if we have settings
 	if hs == nil {
@@ -145,7 +195,7 @@ func checkPriceGougingHS(gs api.GougingSettings, hs *rhpv2.HostSettings) error {
 	}
 	// check base rpc price
 	if !gs.MaxRPCPrice.IsZero() && hs.BaseRPCPrice.Cmp(gs.MaxRPCPrice) > 0 {
-		return fmt.Errorf("rpc price exceeds max: %v > %v", hs.BaseRPCPrice, gs.MaxRPCPrice)
+		return newPriceGougingError(api.GougingPriceRPC, hs.BaseRPCPrice, gs.MaxRPCPrice, fmt.Errorf("rpc price exceeds max: %v > %v", hs.BaseRPCPrice, gs.MaxRPCPrice))
 	}
 	maxBaseRPCPrice := hs.DownloadBandwidthPrice.Mul64(maxBaseRPCPriceVsBandwidth)
 	if hs.BaseRPCPrice.Cmp(maxBaseRPCPrice) > 0 {
@@ -163,12 +213,12 @@ func checkPriceGougingHS(gs api.GougingSettings, hs *rhpv2.HostSettings) error {
 
 	// check max storage price
 	if !gs.MaxStoragePrice.IsZero() && hs.StoragePrice.Cmp(gs.MaxStoragePrice) > 0 {
-		return fmt.Errorf("storage price exceeds max: %v > %v", hs.StoragePrice, gs.MaxStoragePrice)
+		return newPriceGougingError(api.GougingPriceStorage, hs.StoragePrice, gs.MaxStoragePrice, fmt.Errorf("storage price exceeds max: %v > %v", hs.StoragePrice, gs.MaxStoragePrice))
 	}
 
 	// check contract price
 	if !gs.MaxContractPrice.IsZero() && hs.ContractPrice.Cmp(gs.MaxContractPrice) > 0 {
-		return fmt.Errorf("contract price exceeds max: %v > %v", hs.ContractPrice, gs.MaxContractPrice)
+		return newPriceGougingError(api.GougingPriceContract, hs.ContractPrice, gs.MaxContractPrice, fmt.Errorf("contract price exceeds max: %v > %v", hs.ContractPrice, gs.MaxContractPrice))
 	}
 
 	// check max EA balance
@@ -194,17 +244,17 @@ func checkPriceGougingPT(gs api.GougingSettings, cs api.ConsensusState, txnFee t
 	}
 	// check base rpc price
 	if !gs.MaxRPCPrice.IsZero() && gs.MaxRPCPrice.Cmp(pt.InitBaseCost) < 0 {
-		return fmt.Errorf("init base cost exceeds max: %v > %v", pt.InitBaseCost, gs.MaxRPCPrice)
+		return newPriceGougingError(api.GougingPriceRPC, pt.InitBaseCost, gs.MaxRPCPrice, fmt.Errorf("init base cost exceeds max: %v > %v", pt.InitBaseCost, gs.MaxRPCPrice))
 	}
 
 	// check contract price
 	if !gs.MaxContractPrice.IsZero() && pt.ContractPrice.Cmp(gs.MaxContractPrice) > 0 {
-		return fmt.Errorf("contract price exceeds max: %v > %v", pt.ContractPrice, gs.MaxContractPrice)
+		return newPriceGougingError(api.GougingPriceContract, pt.ContractPrice, gs.MaxContractPrice, fmt.Errorf("contract price exceeds max: %v > %v", pt.ContractPrice, gs.MaxContractPrice))
 	}
 
 	// check max storage
 	if !gs.MaxStoragePrice.IsZero() && pt.WriteStoreCost.Cmp(gs.MaxStoragePrice) > 0 {
-		return fmt.Errorf("storage price exceeds max: %v > %v", pt.WriteStoreCost, gs.MaxStoragePrice)
+		return newPriceGougingError(api.GougingPriceStorage, pt.WriteStoreCost, gs.MaxStoragePrice, fmt.Errorf("storage price exceeds max: %v > %v", pt.WriteStoreCost, gs.MaxStoragePrice))
 	}
 
 	// check max collateral
@@ -389,7 +439,7 @@ func checkPruneGougingRHPv2(gs api.GougingSettings, hs *rhpv2.HostSettings) erro
 		return fmt.Errorf("%w: overflow detected when computing download price per TiB", errHostSettingsGouging)
 	}
 	if !gs.MaxDownloadPrice.IsZero() && dpptb.Cmp(gs.MaxDownloadPrice) > 0 {
-		return fmt.Errorf("%w: cost per TiB exceeds max dl price: %v > %v", errHostSettingsGouging, dpptb, gs.MaxDownloadPrice)
+		return newPriceGougingError(api.GougingPriceDownload, dpptb, gs.MaxDownloadPrice, fmt.Errorf("%w: cost per TiB exceeds max dl price: %v > %v", errHostSettingsGouging, dpptb, gs.MaxDownloadPrice))
 	}
 	return nil
 }
@@ -407,7 +457,7 @@ func checkDownloadGougingRHPv3(gs api.GougingSettings, pt *rhpv3.HostPriceTable)
 		return fmt.Errorf("%w: overflow detected when computing download price per TiB", errPriceTableGouging)
 	}
 	if !gs.MaxDownloadPrice.IsZero() && dpptb.Cmp(gs.MaxDownloadPrice) > 0 {
-		return fmt.Errorf("%w: cost per TiB exceeds max dl price: %v > %v", errPriceTableGouging, dpptb, gs.MaxDownloadPrice)
+		return newPriceGougingError(api.GougingPriceDownload, dpptb, gs.MaxDownloadPrice, fmt.Errorf("%w: cost per TiB exceeds max dl price: %v > %v", errPriceTableGouging, dpptb, gs.MaxDownloadPrice))
 	}
 	return nil
 }
@@ -425,7 +475,7 @@ func checkUploadGougingRHPv3(gs api.GougingSettings, pt *rhpv3.HostPriceTable) e
 		return fmt.Errorf("%w: overflow detected when computing upload price per TiB", errPriceTableGouging)
 	}
 	if !gs.MaxUploadPrice.IsZero() && uploadPrice.Cmp(gs.MaxUploadPrice) > 0 {
-		return fmt.Errorf("%w: cost per TiB exceeds max ul price: %v > %v", errPriceTableGouging, uploadPrice, gs.MaxUploadPrice)
+		return newPriceGougingError(api.GougingPriceUpload, uploadPrice, gs.MaxUploadPrice, fmt.Errorf("%w: cost per TiB exceeds max ul price: %v > %v", errPriceTableGouging, uploadPrice, gs.MaxUploadPrice))
 	}
 	return nil
 }
@@ -502,6 +552,61 @@ func sectorUploadCostRHPv3(pt rhpv3.HostPriceTable) (types.Currency, bool) {
 	return total, false
 }
 
+// percentile returns the p-th percentile of the given prices using the
+// nearest-rank method.
+func percentile(prices []types.Currency, p float64) types.Currency {
+	sorted := append([]types.Currency(nil), prices...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Cmp(sorted[j]) < 0
+	})
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// priceGougingError is returned by the gouging checks when a price exceeds the
+// limit configured in the gouging settings.
+type priceGougingError struct {
+	reason api.GougingReason
+	err    error
+}
+
+func newPriceGougingError(price string, value, limit types.Currency, err error) error {
+	return &priceGougingError{
+		reason: api.GougingReason{
+			Price:  price,
+			Value:  value,
+			Limit:  limit,
+			Excess: value.Sub(limit),
+		},
+		err: err,
+	}
+}
+
+func (e *priceGougingError) Error() string { return e.err.Error() }
+func (e *priceGougingError) Unwrap() error { return e.err }
+
+// gougingReasons extracts the reasons from the given errors, a price that is
+// checked in multiple places is only reported once.
+func gougingReasons(errs ...error) (reasons []api.GougingReason) {
+	seen := make(map[api.GougingReason]struct{})
+	for _, err := range errs {
+		var pge *priceGougingError
+		if !errors.As(err, &pge) {
+			continue
+		} else if _, exists := seen[pge.reason]; exists {
+			continue
+		}
+		seen[pge.reason] = struct{}{}
+		reasons = append(reasons, pge.reason)
+	}
+	return
+}
+
 func errsToStr(errs ...error) string {
 	if err := errors.Join(errs...); err != nil {
 		return err.Error()
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"testing"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	rhpv3 "go.sia.tech/core/rhp/v3"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+)
+
+func TestGougingReasons(t *testing.T) {
+	// prepare a price table with an expensive download bandwidth cost
+	pt := rhpv3.HostPriceTable{
+		DownloadBandwidthCost: types.Siacoins(1).Div64(rhpv2.SectorSize),
+	}
+	sectorPrice, overflow := sectorReadCostRHPv3(pt)
+	if overflow {
+		t.Fatal("unexpected overflow")
+	}
+	dpptb := sectorPrice.Mul64(1 << 40 / rhpv2.SectorSize)
+
+	// set the max download price to half the host's price
+	gs := api.GougingSettings{MaxDownloadPrice: dpptb.Div64(2)}
+	gc := NewGougingChecker(gs, api.ConsensusState{}, types.ZeroCurrency, 0, 0)
+
+	// assert the breakdown contains a download reason
+	breakdown := gc.Check(nil, &pt)
+	if breakdown.DownloadErr == "" {
+		t.Fatal("expected download gouging error")
+	}
+
+	var reason *api.GougingReason
+	for _, r := range breakdown.Reasons {
+		if r.Price == api.GougingPriceDownload {
+			reason = &r
+			break
+		}
+	}
+	if reason == nil {
+		t.Fatalf("expected download reason, got %+v", breakdown.Reasons)
+	} else if !reason.Value.Equals(dpptb) {
+		t.Fatalf("unexpected value %v != %v", reason.Value, dpptb)
+	} else if !reason.Limit.Equals(gs.MaxDownloadPrice) {
+		t.Fatalf("unexpected limit %v != %v", reason.Limit, gs.MaxDownloadPrice)
+	} else if !reason.Excess.Equals(dpptb.Sub(gs.MaxDownloadPrice)) {
+		t.Fatalf("unexpected excess %v", reason.Excess)
+	}
+
+	// assert a host within the limits has no reasons
+	gs.MaxDownloadPrice = dpptb
+	gc = NewGougingChecker(gs, api.ConsensusState{}, types.ZeroCurrency, 0, 0)
+	for _, r := range gc.Check(nil, &pt).Reasons {
+		if r.Price == api.GougingPriceDownload {
+			t.Fatal("unexpected download reason", r)
+		}
+	}
+}
+
+func TestPercentileGougingSettings(t *testing.T) {
+	// prepare price tables with storage prices ranging from 1 to 10 SC
+	var pts []rhpv3.HostPriceTable
+	for i := 1; i <= 10; i++ {
+		pts = append(pts, rhpv3.HostPriceTable{
+			WriteStoreCost: types.Siacoins(uint32(i)),
+		})
+	}
+
+	// assert static limits are left untouched
+	gs := api.GougingSettings{MaxStoragePrice: types.Siacoins(5)}
+	if updated := PercentileGougingSettings(gs, pts); !updated.MaxStoragePrice.Equals(gs.MaxStoragePrice) {
+		t.Fatal("unexpected storage limit", updated.MaxStoragePrice)
+	}
+
+	// derive the limits from the 90th percentile
+	gs.PricePercentile = 90
+	gs = PercentileGougingSettings(gs, pts)
+	if !gs.MaxStoragePrice.Equals(types.Siacoins(9)) {
+		t.Fatal("unexpected storage limit", gs.MaxStoragePrice)
+	}
+
+	// assert only the host above the percentile is rejected
+	gc := NewGougingChecker(gs, api.ConsensusState{}, types.ZeroCurrency, 0, 0)
+	for i, pt := range pts {
+		var gouging bool
+		for _, r := range gc.Check(nil, &pt).Reasons {
+			gouging = gouging || r.Price == api.GougingPriceStorage
+		}
+		if expected := i == len(pts)-1; gouging != expected {
+			t.Fatalf("host %d: expected gouging %v, got %v", i, expected, gouging)
+		}
+	}
+}
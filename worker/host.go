@@ -51,6 +51,8 @@ type (
 		acc                      *account
 		bus                      Bus
 		contractSpendingRecorder ContractSpendingRecorder
+		hostInteractionRecorder  HostInteractionRecorder
+		hostOperationRecorder    HostOperationRecorder
 		logger                   *zap.SugaredLogger
 		transportPool            *transportPoolV3
 		priceTables              *priceTables
@@ -68,6 +70,8 @@ func (w *worker) Host(hk types.PublicKey, fcid types.FileContractID, siamuxAddr
 		acc:                      w.accounts.ForHost(hk),
 		bus:                      w.bus,
 		contractSpendingRecorder: w.contractSpendingRecorder,
+		hostInteractionRecorder:  w.hostInteractionRecorder,
+		hostOperationRecorder:    w.hostOperationRecorder,
 		logger:                   w.logger.Named(hk.String()[:4]),
 		fcid:                     fcid,
 		siamuxAddr:               siamuxAddr,
@@ -96,6 +100,9 @@ func (h *host) DownloadSector(ctx context.Context, w io.Writer, root types.Hash2
 		return fmt.Errorf("%w: %v", errPriceTableGouging, breakdown.DownloadErr)
 	}
 
+	// record the outcome of the download
+	defer func() { h.hostOperationRecorder.Record(h.hk, err) }()
+
 	// return errBalanceInsufficient if balance insufficient
 	defer func() {
 		if isBalanceInsufficient(err) {
@@ -131,7 +138,7 @@ func (h *host) UploadSector(ctx context.Context, sectorRoot types.Hash256, secto
 	//
 	// TODO: change to account payments once we have the means to check for an
 	// insufficient balance error
-	expectedCost, _, _, err := uploadSectorCost(pt, rev.WindowEnd)
+	expectedCost, _, storageCost, err := uploadSectorCost(pt, rev.WindowEnd)
 	if err != nil {
 		return err
 	}
@@ -143,6 +150,9 @@ func (h *host) UploadSector(ctx context.Context, sectorRoot types.Hash256, secto
 		return errFailedToCreatePayment
 	}
 
+	// record the outcome of the upload
+	defer func() { h.hostOperationRecorder.Record(h.hk, err) }()
+
 	var cost types.Currency
 	err = h.transportPool.withTransportV3(ctx, h.hk, h.siamuxAddr, func(ctx context.Context, t *transportV3) error {
 		cost, err = RPCAppendSector(ctx, t, h.renterKey, pt, &rev, &payment, sectorRoot, sector)
@@ -152,8 +162,14 @@ func (h *host) UploadSector(ctx context.Context, sectorRoot types.Hash256, secto
 		return err
 	}
 
-	// record spending
-	h.contractSpendingRecorder.Record(rev, api.ContractSpending{Uploads: cost})
+	// record spending, the storage portion of the cost is tracked separately
+	if storageCost.Cmp(cost) > 0 {
+		storageCost = cost
+	}
+	h.contractSpendingRecorder.Record(rev, api.ContractSpending{
+		Uploads: cost.Sub(storageCost),
+		Storage: storageCost,
+	})
 	return nil
 }
 
@@ -195,13 +211,11 @@ func (h *host) FetchPriceTable(ctx context.Context, rev *types.FileContractRevis
 	fetchPT := func(paymentFn PriceTablePaymentFunc) (hpt api.HostPriceTable, err error) {
 		err = h.transportPool.withTransportV3(ctx, h.hk, h.siamuxAddr, func(ctx context.Context, t *transportV3) (err error) {
 			hpt, err = RPCPriceTable(ctx, t, paymentFn)
-			h.bus.RecordPriceTables(ctx, []api.HostPriceTableUpdate{
-				{
-					HostKey:    h.hk,
-					Success:    isSuccessfulInteraction(err),
-					Timestamp:  time.Now(),
-					PriceTable: hpt,
-				},
+			h.hostInteractionRecorder.RecordPriceTableUpdate(api.HostPriceTableUpdate{
+				HostKey:    h.hk,
+				Success:    isSuccessfulInteraction(err),
+				Timestamp:  time.Now(),
+				PriceTable: hpt,
 			})
 			return
 		})
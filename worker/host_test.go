@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,8 +22,16 @@ type (
 	testHost struct {
 		*hostMock
 		*contractMock
-		hptFn       func() api.HostPriceTable
-		uploadDelay time.Duration
+		hptFn         func() api.HostPriceTable
+		downloadDelay time.Duration
+		uploadDelay   time.Duration
+		uploadErr     error
+
+		// blockedSectors contains sectors whose downloads block until the
+		// corresponding channel is closed
+		blockedSectors map[types.Hash256]chan struct{}
+
+		numDownloads atomic.Uint64
 	}
 
 	testHostManager struct {
@@ -80,6 +89,7 @@ func (h *testHost) PublicKey() types.PublicKey {
 }
 
 func (h *testHost) DownloadSector(ctx context.Context, w io.Writer, root types.Hash256, offset, length uint32, overpay bool) error {
+	h.numDownloads.Add(1)
 	sector, exist := h.Sector(root)
 	if !exist {
 		return errSectorNotFound
@@ -87,11 +97,28 @@ func (h *testHost) DownloadSector(ctx context.Context, w io.Writer, root types.H
 	if offset+length > rhpv2.SectorSize {
 		return errSectorOutOfBounds
 	}
+	if h.downloadDelay > 0 {
+		select {
+		case <-time.After(h.downloadDelay):
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+	if blocked, ok := h.blockedSectors[root]; ok {
+		select {
+		case <-blocked:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
 	_, err := w.Write(sector[offset : offset+length])
 	return err
 }
 
 func (h *testHost) UploadSector(ctx context.Context, sectorRoot types.Hash256, sector *[rhpv2.SectorSize]byte, rev types.FileContractRevision) error {
+	if h.uploadErr != nil {
+		return h.uploadErr
+	}
 	h.AddSector(sectorRoot, sector)
 	if h.uploadDelay > 0 {
 		select {
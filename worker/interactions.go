@@ -1,16 +1,175 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+const (
+	// interactionsFlushThreshold is the number of buffered interactions at
+	// which the interaction recorder flushes them to the bus without waiting
+	// for the flush interval to pass.
+	interactionsFlushThreshold = 1000
 )
 
 type (
 	HostInteractionRecorder interface {
 		RecordHostScan(...api.HostScan)
 		RecordPriceTableUpdate(...api.HostPriceTableUpdate)
+		Stop(context.Context)
+	}
+
+	HostOperationRecorder interface {
+		Record(hk types.PublicKey, err error)
+		Stop(context.Context)
+	}
+
+	// hostInteractionRecorder buffers host interactions and flushes them to
+	// the bus in batches, either after the flush interval or as soon as the
+	// number of buffered interactions reaches the flush threshold.
+	hostInteractionRecorder struct {
+		*batchRecorder
 	}
+
+	hostOperationRecorder struct {
+		*batchRecorder
+	}
+
+	// interactionsBatch is a batch of host scans and price table updates.
+	interactionsBatch struct {
+		hostScans         []api.HostScan
+		priceTableUpdates []api.HostPriceTableUpdate
+	}
+
+	// operationsBatch is a batch of host operation records, keyed by host.
+	operationsBatch map[types.PublicKey]api.HostOperationRecord
+)
+
+var (
+	_ HostInteractionRecorder = (*hostInteractionRecorder)(nil)
+	_ HostOperationRecorder   = (*hostOperationRecorder)(nil)
 )
 
+func (w *worker) initHostInteractionRecorder(flushInterval time.Duration) {
+	if w.hostInteractionRecorder != nil {
+		panic("HostInteractionRecorder already initialized") // developer error
+	}
+	w.hostInteractionRecorder = newHostInteractionRecorder(w.shutdownCtx, w.bus, w.logger, flushInterval, interactionsFlushThreshold)
+}
+
+func newHostInteractionRecorder(ctx context.Context, bus Bus, logger *zap.SugaredLogger, flushInterval time.Duration, flushThreshold int) *hostInteractionRecorder {
+	return &hostInteractionRecorder{
+		batchRecorder: newBatchRecorder(ctx, bus, logger, "host interactions", flushInterval, flushThreshold, func() recordBatch {
+			return &interactionsBatch{}
+		}),
+	}
+}
+
+// RecordHostScan buffers the given scans until they get flushed to the bus.
+func (r *hostInteractionRecorder) RecordHostScan(scans ...api.HostScan) {
+	r.record(func(b recordBatch) {
+		batch := b.(*interactionsBatch)
+		batch.hostScans = append(batch.hostScans, scans...)
+	})
+}
+
+// RecordPriceTableUpdate buffers the given price table updates until they get
+// flushed to the bus.
+func (r *hostInteractionRecorder) RecordPriceTableUpdate(ptUpdates ...api.HostPriceTableUpdate) {
+	r.record(func(b recordBatch) {
+		batch := b.(*interactionsBatch)
+		batch.priceTableUpdates = append(batch.priceTableUpdates, ptUpdates...)
+	})
+}
+
+func (b *interactionsBatch) len() int {
+	return len(b.hostScans) + len(b.priceTableUpdates)
+}
+
+func (b *interactionsBatch) merge(other recordBatch) {
+	o := other.(*interactionsBatch)
+	b.hostScans = append(b.hostScans, o.hostScans...)
+	b.priceTableUpdates = append(b.priceTableUpdates, o.priceTableUpdates...)
+}
+
+func (b *interactionsBatch) send(ctx context.Context, bus Bus) error {
+	if len(b.hostScans) > 0 {
+		if err := bus.RecordHostScans(ctx, b.hostScans); err != nil {
+			return fmt.Errorf("failed to record %d scans: %w", len(b.hostScans), err)
+		}
+		b.hostScans = nil
+	}
+	if len(b.priceTableUpdates) > 0 {
+		if err := bus.RecordPriceTables(ctx, b.priceTableUpdates); err != nil {
+			return fmt.Errorf("failed to record %d price table updates: %w", len(b.priceTableUpdates), err)
+		}
+		b.priceTableUpdates = nil
+	}
+	return nil
+}
+
+func (w *worker) initHostOperationRecorder(flushInterval time.Duration) {
+	if w.hostOperationRecorder != nil {
+		panic("HostOperationRecorder already initialized") // developer error
+	}
+	w.hostOperationRecorder = &hostOperationRecorder{
+		batchRecorder: newBatchRecorder(w.shutdownCtx, w.bus, w.logger, "host operations", flushInterval, 0, func() recordBatch {
+			return make(operationsBatch)
+		}),
+	}
+}
+
+// Record stores the outcome of an operation performed on the given host until
+// it gets flushed to the bus. Operations that were cancelled are ignored since
+// they don't reflect on the host.
+func (r *hostOperationRecorder) Record(hk types.PublicKey, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	op := api.HostOperationRecord{HostKey: hk}
+	if isSuccessfulInteraction(err) {
+		op.Successful++
+	} else {
+		op.Failed++
+	}
+	r.record(func(b recordBatch) {
+		b.(operationsBatch).add(op)
+	})
+}
+
+func (b operationsBatch) add(op api.HostOperationRecord) {
+	total := b[op.HostKey]
+	total.HostKey = op.HostKey
+	total.Successful += op.Successful
+	total.Failed += op.Failed
+	b[op.HostKey] = total
+}
+
+func (b operationsBatch) len() int {
+	return len(b)
+}
+
+func (b operationsBatch) merge(other recordBatch) {
+	for _, op := range other.(operationsBatch) {
+		b.add(op)
+	}
+}
+
+func (b operationsBatch) send(ctx context.Context, bus Bus) error {
+	records := make([]api.HostOperationRecord, 0, len(b))
+	for _, op := range b {
+		records = append(records, op)
+	}
+	return bus.RecordHostOperations(ctx, records)
+}
+
 func isSuccessfulInteraction(err error) bool {
 	// No error always means success.
 	if err == nil {
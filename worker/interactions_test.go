@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.uber.org/zap"
+)
+
+func TestHostInteractionRecorder(t *testing.T) {
+	hs := newHostStoreMock()
+	r := newHostInteractionRecorder(context.Background(), newBusMock(newContractStoreMock(), hs, newObjectStoreMock(testBucket)), zap.NewNop().Sugar(), time.Hour, 100)
+
+	// numRecorded returns the number of price table updates the bus received
+	numRecorded := func() int {
+		hs.mu.Lock()
+		defer hs.mu.Unlock()
+		return len(hs.priceTableUpdates)
+	}
+
+	// record a lot of price table updates concurrently
+	const n = 1050
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r.RecordPriceTableUpdate(api.HostPriceTableUpdate{
+				HostKey:   types.PublicKey{byte(i)},
+				Success:   true,
+				Timestamp: time.Now(),
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	// the flush threshold should trigger flushes before the flush interval
+	// passes, leaving less than the threshold buffered
+	deadline := time.Now().Add(10 * time.Second)
+	for numRecorded() <= n-r.flushThreshold {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected more than %d updates to be flushed, got %d", n-r.flushThreshold, numRecorded())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// record another batch that triggers a flush and stop the recorder right
+	// away, stopping waits for ongoing flushes and flushes the remaining
+	// updates
+	for i := 0; i < n; i++ {
+		r.RecordPriceTableUpdate(api.HostPriceTableUpdate{
+			HostKey:   types.PublicKey{byte(i)},
+			Success:   true,
+			Timestamp: time.Now(),
+		})
+	}
+	r.Stop(context.Background())
+	if recorded := numRecorded(); recorded != 2*n {
+		t.Fatalf("expected %d updates to be flushed, got %d", 2*n, recorded)
+	}
+}
@@ -10,7 +10,41 @@ import (
 	"go.sia.tech/renterd/object"
 )
 
-func (w *worker) migrate(ctx context.Context, s object.Slab, contractSet string, dlContracts, ulContracts []api.ContractMetadata, bh uint64) (int, bool, error) {
+// rekey downloads the given slab, encrypts its data with a newly generated key
+// and uploads it to the given contracts. The slab is replaced in the bus and
+// the rekeyed slab is returned.
+func (w *worker) rekey(ctx context.Context, s object.Slab, contractSet string, dlContracts, ulContracts []api.ContractMetadata, bh uint64) (object.Slab, error) {
+	// perform some sanity checks
+	if len(ulContracts) < len(s.Shards) {
+		return object.Slab{}, fmt.Errorf("not enough hosts to upload rekeyed slab, %d<%d", len(ulContracts), len(s.Shards))
+	}
+
+	// acquire memory for the shards
+	mem := w.uploadManager.mm.AcquireMemory(ctx, uint64(len(s.Shards))*rhpv2.SectorSize)
+	if mem == nil {
+		return object.Slab{}, fmt.Errorf("failed to acquire memory for rekey")
+	}
+	defer mem.Release()
+
+	// download the slab, the returned shards are decrypted
+	shards, _, err := w.downloadManager.DownloadSlab(ctx, s, dlContracts)
+	if err != nil {
+		return object.Slab{}, fmt.Errorf("failed to download slab for rekey: %w", err)
+	}
+
+	// encrypt the shards using a new key
+	rekeyed := object.NewSlab(s.MinShards)
+	rekeyed.Encrypt(shards)
+
+	// upload the shards and replace the slab
+	rekeyed, err = w.uploadManager.RekeySlab(ctx, s.Key, rekeyed, shards, contractSet, ulContracts, bh, lockingPriorityUpload, mem)
+	if err != nil {
+		return object.Slab{}, fmt.Errorf("failed to upload rekeyed slab: %w", err)
+	}
+	return rekeyed, nil
+}
+
+func (w *worker) migrate(ctx context.Context, s object.Slab, contractSet string, dlContracts, ulContracts []api.ContractMetadata, excludedHosts []types.PublicKey, bh uint64) (int, bool, error) {
 	// make a map of good hosts
 	goodHosts := make(map[types.PublicKey]map[types.FileContractID]bool)
 	for _, c := range ulContracts {
@@ -26,8 +60,12 @@ func (w *worker) migrate(ctx context.Context, s object.Slab, contractSet string,
 		h2c[c.HostKey] = c.ID
 	}
 
-	// collect indices of shards that need to be migrated
+	// collect indices of shards that need to be migrated, excluded hosts are
+	// marked as used so shards on them are migrated and they are not reused
 	usedMap := make(map[types.PublicKey]struct{})
+	for _, hk := range excludedHosts {
+		usedMap[hk] = struct{}{}
+	}
 	var shardIndices []int
 SHARDS:
 	for i, shard := range s.Shards {
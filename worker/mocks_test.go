@@ -8,6 +8,7 @@ import (
 	"math"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	rhpv2 "go.sia.tech/core/rhp/v2"
@@ -274,9 +275,11 @@ func newHostMock(hk types.PublicKey) *hostMock {
 var _ HostStore = (*hostStoreMock)(nil)
 
 type hostStoreMock struct {
-	mu     sync.Mutex
-	hosts  map[types.PublicKey]*hostMock
-	hkCntr uint
+	mu                sync.Mutex
+	hosts             map[types.PublicKey]*hostMock
+	hkCntr            uint
+	bandwidth         []api.HostBandwidth
+	priceTableUpdates []api.HostPriceTableUpdate
 }
 
 func newHostStoreMock() *hostStoreMock {
@@ -294,11 +297,25 @@ func (hs *hostStoreMock) Host(ctx context.Context, hostKey types.PublicKey) (api
 	return h.hi, nil
 }
 
+func (hs *hostStoreMock) RecordHostBandwidth(ctx context.Context, records []api.HostBandwidth) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.bandwidth = append(hs.bandwidth, records...)
+	return nil
+}
+
+func (hs *hostStoreMock) RecordHostOperations(ctx context.Context, records []api.HostOperationRecord) error {
+	return nil
+}
+
 func (hs *hostStoreMock) RecordHostScans(ctx context.Context, scans []api.HostScan) error {
 	return nil
 }
 
 func (hs *hostStoreMock) RecordPriceTables(ctx context.Context, priceTableUpdate []api.HostPriceTableUpdate) error {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.priceTableUpdates = append(hs.priceTableUpdates, priceTableUpdate...)
 	return nil
 }
 
@@ -322,8 +339,19 @@ var (
 )
 
 type (
-	memoryMock        struct{}
-	memoryManagerMock struct{ memBlockChan chan struct{} }
+	memoryMock struct {
+		mm       *memoryManagerMock
+		released atomic.Bool
+	}
+	memoryManagerMock struct {
+		memBlockChan chan struct{}
+
+		// keep track of the number of acquired memory allocations that
+		// weren't released yet
+		mu       sync.Mutex
+		inUse    int
+		maxInUse int
+	}
 )
 
 func newMemoryManagerMock() *memoryManagerMock {
@@ -332,7 +360,14 @@ func newMemoryManagerMock() *memoryManagerMock {
 	return mm
 }
 
-func (m *memoryMock) Release()           {}
+func (m *memoryMock) Release() {
+	if m.released.CompareAndSwap(false, true) {
+		m.mm.mu.Lock()
+		m.mm.inUse--
+		m.mm.mu.Unlock()
+	}
+}
+
 func (m *memoryMock) ReleaseSome(uint64) {}
 
 func (mm *memoryManagerMock) Limit(amt uint64) (MemoryManager, error) {
@@ -343,7 +378,14 @@ func (mm *memoryManagerMock) Status() api.MemoryStatus { return api.MemoryStatus
 
 func (mm *memoryManagerMock) AcquireMemory(ctx context.Context, amt uint64) Memory {
 	<-mm.memBlockChan
-	return &memoryMock{}
+
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.inUse++
+	if mm.inUse > mm.maxInUse {
+		mm.maxInUse = mm.inUse
+	}
+	return &memoryMock{mm: mm}
 }
 
 var _ ObjectStore = (*objectStoreMock)(nil)
@@ -352,9 +394,11 @@ type (
 	objectStoreMock struct {
 		mu                    sync.Mutex
 		objects               map[string]map[string]object.Object
+		eTags                 map[string]map[string]string
 		partials              map[string]*packedSlabMock
 		slabBufferMaxSizeSoft int
 		bufferIDCntr          uint // allows marking packed slabs as uploaded
+		deadlines             map[api.UploadID]time.Time
 	}
 
 	packedSlabMock struct {
@@ -369,10 +413,13 @@ type (
 func newObjectStoreMock(bucket string) *objectStoreMock {
 	os := &objectStoreMock{
 		objects:               make(map[string]map[string]object.Object),
+		eTags:                 make(map[string]map[string]string),
 		partials:              make(map[string]*packedSlabMock),
 		slabBufferMaxSizeSoft: math.MaxInt64,
+		deadlines:             make(map[api.UploadID]time.Time),
 	}
 	os.objects[bucket] = make(map[string]object.Object)
+	os.eTags[bucket] = make(map[string]string)
 	return os
 }
 
@@ -384,7 +431,12 @@ func (os *objectStoreMock) AddUploadingSector(ctx context.Context, uID api.Uploa
 	return nil
 }
 
-func (os *objectStoreMock) TrackUpload(ctx context.Context, uID api.UploadID) error { return nil }
+func (os *objectStoreMock) TrackUpload(ctx context.Context, uID api.UploadID, priority int, deadline time.Time) error {
+	os.mu.Lock()
+	defer os.mu.Unlock()
+	os.deadlines[uID] = deadline
+	return nil
+}
 
 func (os *objectStoreMock) FinishUpload(ctx context.Context, uID api.UploadID) error { return nil }
 
@@ -420,7 +472,17 @@ func (os *objectStoreMock) AddObject(ctx context.Context, bucket, path, contract
 		return api.ErrBucketNotFound
 	}
 
+	// check if the object was modified
+	if opts.ExpectedETag != "" {
+		if _, exists := os.objects[bucket][path]; !exists {
+			return api.ErrObjectNotFound
+		} else if os.eTags[bucket][path] != opts.ExpectedETag {
+			return api.ErrObjectModified
+		}
+	}
+
 	os.objects[bucket][path] = o
+	os.eTags[bucket][path] = opts.ETag
 	return nil
 }
 
@@ -477,7 +539,7 @@ func (os *objectStoreMock) Object(ctx context.Context, bucket, path string, opts
 	}
 
 	return api.ObjectsResponse{Object: &api.Object{
-		ObjectMetadata: api.ObjectMetadata{Name: path, Size: o.TotalSize()},
+		ObjectMetadata: api.ObjectMetadata{Name: path, Size: o.TotalSize(), ETag: os.eTags[bucket][path]},
 		Object:         &o,
 	}}, nil
 }
@@ -513,6 +575,22 @@ func (os *objectStoreMock) Slab(ctx context.Context, key object.EncryptionKey) (
 	return
 }
 
+func (os *objectStoreMock) RekeySlab(ctx context.Context, oldKey object.EncryptionKey, s object.Slab, contractSet string) (err error) {
+	os.mu.Lock()
+	defer os.mu.Unlock()
+
+	err = api.ErrSlabNotFound
+	os.forEachObject(func(bucket, path string, o object.Object) {
+		for i, slab := range o.Slabs {
+			if slab.Key.String() == oldKey.String() {
+				os.objects[bucket][path].Slabs[i].Slab = s
+				err = nil
+			}
+		}
+	})
+	return
+}
+
 func (os *objectStoreMock) UpdateSlab(ctx context.Context, s object.Slab, contractSet string) error {
 	os.mu.Lock()
 	defer os.mu.Unlock()
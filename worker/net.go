@@ -36,7 +36,10 @@ func isPrivateIP(addr net.IP) bool {
 	return false
 }
 
-func dial(ctx context.Context, hostIP string) (net.Conn, error) {
+func dial(ctx context.Context, rl *rateLimiter, hostIP string) (net.Conn, error) {
 	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", hostIP)
-	return conn, err
+	if err != nil {
+		return nil, err
+	}
+	return rl.Wrap(conn), nil
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	rhpv3 "go.sia.tech/core/rhp/v3"
@@ -37,10 +38,18 @@ type (
 		hm HostManager
 		hs HostStore
 
+		hits   atomic.Uint64
+		misses atomic.Uint64
+
 		mu          sync.Mutex
 		priceTables map[types.PublicKey]*priceTable
 	}
 
+	priceTablesStats struct {
+		hits   uint64
+		misses uint64
+	}
+
 	priceTable struct {
 		hm HostManager
 		hs HostStore
@@ -88,7 +97,22 @@ func (pts *priceTables) fetch(ctx context.Context, hk types.PublicKey, rev *type
 	}
 	pts.mu.Unlock()
 
-	return pt.fetch(ctx, rev)
+	hpt, cached, err := pt.fetch(ctx, rev)
+	if err == nil && cached {
+		pts.hits.Add(1)
+	} else if err == nil {
+		pts.misses.Add(1)
+	}
+	return hpt, err
+}
+
+// Stats returns the number of times a price table was served from the cache
+// and the number of times it had to be updated.
+func (pts *priceTables) Stats() priceTablesStats {
+	return priceTablesStats{
+		hits:   pts.hits.Load(),
+		misses: pts.misses.Load(),
+	}
 }
 
 func (pt *priceTable) ongoingUpdate() (bool, *priceTableUpdate) {
@@ -105,7 +129,11 @@ func (pt *priceTable) ongoingUpdate() (bool, *priceTableUpdate) {
 	return ongoing, pt.update
 }
 
-func (p *priceTable) fetch(ctx context.Context, rev *types.FileContractRevision) (hpt api.HostPriceTable, err error) {
+// fetch returns a price table for the host, cached indicates whether the
+// price table was served from the cache. Price tables that are about to expire
+// are never served from the cache to ensure they're still valid when used for
+// payment.
+func (p *priceTable) fetch(ctx context.Context, rev *types.FileContractRevision) (hpt api.HostPriceTable, cached bool, err error) {
 	// grab the current price table
 	p.mu.Lock()
 	hpt = p.hpt
@@ -115,7 +143,7 @@ func (p *priceTable) fetch(ctx context.Context, rev *types.FileContractRevision)
 	// current price table is considered to gouge on the block height
 	gc, err := GougingCheckerFromContext(ctx, false)
 	if err != nil {
-		return api.HostPriceTable{}, err
+		return api.HostPriceTable{}, false, err
 	}
 
 	// figure out whether we should update the price table, if not we can return
@@ -124,7 +152,7 @@ func (p *priceTable) fetch(ctx context.Context, rev *types.FileContractRevision)
 		closeToGouging := gc.BlocksUntilBlockHeightGouging(hpt.HostBlockHeight) <= priceTableBlockHeightLeeway
 		closeToExpiring := time.Now().Add(priceTableValidityLeeway).Add(time.Duration(randomUpdateLeeway) * time.Second).After(hpt.Expiry)
 		if !closeToExpiring && !closeToGouging {
-			return
+			return hpt, true, nil
 		}
 	}
 
@@ -133,14 +161,14 @@ func (p *priceTable) fetch(ctx context.Context, rev *types.FileContractRevision)
 	// we have is still usable
 	ongoing, update := p.ongoingUpdate()
 	if ongoing && time.Now().Add(priceTableValidityLeeway).Before(hpt.Expiry) {
-		return
+		return hpt, true, nil
 	} else if ongoing {
 		select {
 		case <-ctx.Done():
-			return api.HostPriceTable{}, fmt.Errorf("%w; %w", errPriceTableUpdateTimedOut, context.Cause(ctx))
+			return api.HostPriceTable{}, false, fmt.Errorf("%w; %w", errPriceTableUpdateTimedOut, context.Cause(ctx))
 		case <-update.done:
 		}
-		return update.hpt, update.err
+		return update.hpt, false, update.err
 	}
 
 	// this thread is updating the price table
@@ -166,14 +194,14 @@ func (p *priceTable) fetch(ctx context.Context, rev *types.FileContractRevision)
 
 	// sanity check the host has been scanned before fetching the price table
 	if !host.Scanned {
-		return api.HostPriceTable{}, fmt.Errorf("host %v was not scanned", p.hk)
+		return api.HostPriceTable{}, false, fmt.Errorf("host %v was not scanned", p.hk)
 	}
 
 	// otherwise fetch it
 	h := p.hm.Host(p.hk, types.FileContractID{}, host.Settings.SiamuxAddr())
 	hpt, err = h.FetchPriceTable(ctx, rev)
 	if err != nil {
-		return api.HostPriceTable{}, fmt.Errorf("failed to update pricetable, err %v", err)
+		return api.HostPriceTable{}, false, fmt.Errorf("failed to update pricetable, err %v", err)
 	}
 
 	return
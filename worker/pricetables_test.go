@@ -117,3 +117,66 @@ func TestPriceTables(t *testing.T) {
 		t.Fatal("price table mismatch")
 	}
 }
+
+func TestPriceTablesCache(t *testing.T) {
+	// create host & contract stores
+	hs := newHostStoreMock()
+	cs := newContractStoreMock()
+
+	// create host manager & price table
+	hm := newTestHostManager(t)
+	pts := newPriceTables(hm, hs)
+
+	// create host & contract mock
+	h := hs.addHost()
+	c := cs.addContract(h.hk)
+
+	// expire the price table in the host store to force fetching it from the
+	// host
+	h.hi.PriceTable = newTestHostPriceTable()
+	h.hi.PriceTable.Expiry = time.Now()
+
+	// manage the host, keep track of the number of price table fetches
+	var fetches int
+	hm.addHost(newTestHostCustom(h, c, func() api.HostPriceTable {
+		fetches++
+		return newTestHostPriceTable()
+	}))
+
+	cm := &chainMock{cs: api.ConsensusState{BlockHeight: 1}}
+	ctx := WithGougingChecker(context.Background(), cm, api.GougingParams{
+		ConsensusState: cm.cs,
+		GougingSettings: api.GougingSettings{
+			HostBlockHeightLeeway: 10,
+		},
+	})
+
+	// fetch the price table twice, assert it was only fetched once
+	pt1, err := pts.fetch(ctx, h.hk, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt2, err := pts.fetch(ctx, h.hk, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if fetches != 1 {
+		t.Fatalf("expected 1 fetch, got %d", fetches)
+	} else if pt1.UID != pt2.UID {
+		t.Fatal("price table mismatch")
+	} else if stats := pts.Stats(); stats.hits != 1 || stats.misses != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// expire the price table, assert it's refetched
+	pts.priceTables[h.hk].hpt.Expiry = time.Now()
+	pt3, err := pts.fetch(ctx, h.hk, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if fetches != 2 {
+		t.Fatalf("expected 2 fetches, got %d", fetches)
+	} else if pt3.UID == pt1.UID {
+		t.Fatal("expected a new price table")
+	} else if stats := pts.Stats(); stats.hits != 1 || stats.misses != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
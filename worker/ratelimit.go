@@ -0,0 +1,228 @@
+package worker
+
+import (
+	"context"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"go.sia.tech/renterd/api"
+	"golang.org/x/time/rate"
+)
+
+type (
+	// rateLimiter enforces the worker's bandwidth limits on connections to
+	// hosts. The global limiters are shared by all connections, the per
+	// connection limits are applied to every connection individually.
+	rateLimiter struct {
+		download *rate.Limiter
+		upload   *rate.Limiter
+
+		mu     sync.Mutex
+		limits api.WorkerRateLimits
+	}
+
+	// rateLimitedConn is a net.Conn that respects the limits of a rateLimiter.
+	// Reads are counted towards the download limits, writes towards the
+	// upload limits.
+	rateLimitedConn struct {
+		net.Conn
+		rl *rateLimiter
+
+		// ctx is cancelled when the connection is closed, interrupting reads
+		// and writes that are waiting on the limiters
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		mu            sync.Mutex
+		readDeadline  time.Time
+		writeDeadline time.Time
+
+		limits   api.WorkerRateLimits
+		download *rate.Limiter
+		upload   *rate.Limiter
+	}
+)
+
+func newRateLimiter(limits api.WorkerRateLimits) *rateLimiter {
+	rl := &rateLimiter{
+		download: rate.NewLimiter(rate.Inf, 0),
+		upload:   rate.NewLimiter(rate.Inf, 0),
+	}
+	rl.SetLimits(limits)
+	return rl
+}
+
+// Limits returns the current limits.
+func (rl *rateLimiter) Limits() api.WorkerRateLimits {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.limits
+}
+
+// SetLimits updates the limits, the new limits apply to existing connections
+// as well.
+func (rl *rateLimiter) SetLimits(limits api.WorkerRateLimits) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.limits = limits
+	setLimit(rl.download, limits.Download)
+	setLimit(rl.upload, limits.Upload)
+}
+
+// Wrap wraps the given connection so it respects the limiter's limits.
+func (rl *rateLimiter) Wrap(conn net.Conn) net.Conn {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &rateLimitedConn{
+		Conn:     conn,
+		rl:       rl,
+		ctx:      ctx,
+		cancel:   cancel,
+		download: rate.NewLimiter(rate.Inf, 0),
+		upload:   rate.NewLimiter(rate.Inf, 0),
+	}
+	c.refresh()
+	return c
+}
+
+func (c *rateLimitedConn) Close() error {
+	c.cancel()
+	return c.Conn.Close()
+}
+
+func (c *rateLimitedConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *rateLimitedConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *rateLimitedConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func (c *rateLimitedConn) Read(b []byte) (int, error) {
+	c.refresh()
+	if max := maxChunkSize(c.download, c.rl.download); len(b) > max {
+		b = b[:max]
+	}
+	n, err := c.Conn.Read(b)
+	if werr := c.waitN(n, c.deadlines(true), c.download, c.rl.download); err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+func (c *rateLimitedConn) Write(b []byte) (n int, err error) {
+	c.refresh()
+	max := maxChunkSize(c.upload, c.rl.upload)
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		if err = c.waitN(len(chunk), c.deadlines(false), c.upload, c.rl.upload); err != nil {
+			return
+		}
+
+		var written int
+		written, err = c.Conn.Write(chunk)
+		n += written
+		if err != nil {
+			return
+		}
+		b = b[written:]
+	}
+	return
+}
+
+// refresh applies the per connection limits if they were updated since the
+// last read or write.
+func (c *rateLimitedConn) refresh() {
+	limits := c.rl.Limits()
+	if limits == c.limits {
+		return
+	}
+	c.limits = limits
+	setLimit(c.download, limits.DownloadPerConn)
+	setLimit(c.upload, limits.UploadPerConn)
+}
+
+// maxChunkSize returns the largest number of bytes that can be read or written
+// at once without exceeding the burst of any of the given limiters.
+func maxChunkSize(limiters ...*rate.Limiter) int {
+	max := math.MaxInt
+	for _, l := range limiters {
+		if l.Limit() != rate.Inf && l.Burst() < max {
+			max = l.Burst()
+		}
+	}
+	return max
+}
+
+// setLimit sets the limit of the given limiter to 'limit' bytes per second, a
+// limit of zero means unlimited. The burst is set to the limit itself.
+func setLimit(l *rate.Limiter, limit uint64) {
+	if limit == 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	burst := math.MaxInt
+	if limit < math.MaxInt {
+		burst = int(limit)
+	}
+	l.SetLimit(rate.Limit(limit))
+	l.SetBurst(burst)
+}
+
+// deadlines returns the read or write deadline of the connection.
+func (c *rateLimitedConn) deadlines(read bool) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if read {
+		return c.readDeadline
+	}
+	return c.writeDeadline
+}
+
+// waitN blocks until 'n' bytes can be transferred according to all of the
+// given limiters. It returns net.ErrClosed if the connection is closed while
+// waiting and os.ErrDeadlineExceeded if the wait would exceed the deadline.
+func (c *rateLimitedConn) waitN(n int, deadline time.Time, limiters ...*rate.Limiter) error {
+	if n <= 0 {
+		return nil
+	}
+
+	ctx := c.ctx
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+
+	for _, l := range limiters {
+		// NOTE: n exceeds the burst if the limits were updated concurrently,
+		// in which case WaitN would fail so we don't wait
+		if n > l.Burst() && l.Limit() != rate.Inf {
+			continue
+		} else if err := l.WaitN(ctx, n); err != nil {
+			if c.ctx.Err() != nil {
+				return net.ErrClosed
+			}
+			return os.ErrDeadlineExceeded
+		}
+	}
+	return nil
+}
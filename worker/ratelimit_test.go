@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"go.sia.tech/renterd/api"
+	"lukechampine.com/frand"
+)
+
+func TestRateLimiter(t *testing.T) {
+	const limit = 1 << 18 // 256 KiB/s
+	const size = 2 * limit
+
+	// transfer writes 'size' bytes over a rate limited connection and returns
+	// the throughput, not counting the burst the limiter allows initially
+	transfer := func(rl *rateLimiter, upload bool) float64 {
+		t.Helper()
+
+		c1, c2 := net.Pipe()
+		defer c1.Close()
+		defer c2.Close()
+		conn := rl.Wrap(c1)
+
+		// prepare the reader and writer
+		var r io.Reader = c2
+		var w io.Writer = conn
+		if !upload {
+			r, w = conn, c2
+		}
+
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := w.Write(frand.Bytes(size))
+			errChan <- err
+		}()
+
+		start := time.Now()
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			t.Fatal(err)
+		} else if err := <-errChan; err != nil {
+			t.Fatal(err)
+		}
+		return float64(size-limit) / time.Since(start).Seconds()
+	}
+
+	// assert a global upload limit is respected
+	rl := newRateLimiter(api.WorkerRateLimits{Upload: limit})
+	if throughput := transfer(rl, true); throughput > limit*1.05 {
+		t.Fatalf("throughput %v exceeds limit %v", throughput, limit)
+	}
+
+	// assert downloads are not limited by the upload limit
+	if throughput := transfer(rl, false); throughput < limit*4 {
+		t.Fatalf("download was limited, throughput %v", throughput)
+	}
+
+	// update the limits to a per connection download limit, assert it's
+	// respected
+	rl.SetLimits(api.WorkerRateLimits{DownloadPerConn: limit})
+	if throughput := transfer(rl, false); throughput > limit*1.05 {
+		t.Fatalf("throughput %v exceeds limit %v", throughput, limit)
+	}
+
+	// assert a limit of zero means unlimited
+	rl.SetLimits(api.WorkerRateLimits{})
+	if throughput := transfer(rl, true); throughput < limit*4 {
+		t.Fatalf("upload was limited, throughput %v", throughput)
+	}
+}
+
+func TestRateLimitedConnInterrupt(t *testing.T) {
+	const limit = 1 << 10 // 1 KiB/s
+
+	// write returns the error of writing more than the burst allows to a rate
+	// limited connection, the writes block on the limiter after the burst
+	write := func(fn func(conn net.Conn)) error {
+		t.Helper()
+
+		c1, c2 := net.Pipe()
+		defer c2.Close()
+		go io.Copy(io.Discard, c2)
+
+		conn := newRateLimiter(api.WorkerRateLimits{Upload: limit}).Wrap(c1)
+		defer conn.Close()
+		fn(conn)
+
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := conn.Write(frand.Bytes(10 * limit))
+			errChan <- err
+		}()
+		select {
+		case err := <-errChan:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("write wasn't interrupted")
+		}
+		return nil
+	}
+
+	// assert closing the connection interrupts the write
+	if err := write(func(conn net.Conn) {
+		time.AfterFunc(100*time.Millisecond, func() { conn.Close() })
+	}); !errors.Is(err, net.ErrClosed) {
+		t.Fatal("expected net.ErrClosed, got", err)
+	}
+
+	// assert the write deadline is respected
+	if err := write(func(conn net.Conn) {
+		conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+	}); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatal("expected os.ErrDeadlineExceeded, got", err)
+	}
+}
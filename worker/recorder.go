@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// recordBatch is a batch of records that is buffered by a batchRecorder
+	// until it gets flushed to the bus.
+	recordBatch interface {
+		// len returns the number of records in the batch.
+		len() int
+
+		// merge appends the records of the given batch, which is always of
+		// the same type, to the batch.
+		merge(recordBatch)
+
+		// send sends the batch to the bus, records that were sent are
+		// removed from the batch so only the ones that failed remain.
+		send(ctx context.Context, bus Bus) error
+	}
+
+	// batchRecorder buffers records and flushes them to the bus in batches,
+	// either after the flush interval or as soon as the number of buffered
+	// records reaches the flush threshold. The batch is swapped out while
+	// holding the lock but sent to the bus without holding it, that way
+	// recording records is never blocked by an ongoing flush.
+	batchRecorder struct {
+		flushInterval  time.Duration
+		flushThreshold int // 0 means no threshold
+
+		bus      Bus
+		logger   *zap.SugaredLogger
+		name     string
+		newBatch func() recordBatch
+
+		mu    sync.Mutex
+		batch recordBatch
+
+		flushCtx   context.Context
+		flushTimer *time.Timer
+		flushWg    sync.WaitGroup
+	}
+)
+
+func newBatchRecorder(ctx context.Context, bus Bus, logger *zap.SugaredLogger, name string, flushInterval time.Duration, flushThreshold int, newBatch func() recordBatch) *batchRecorder {
+	return &batchRecorder{
+		flushInterval:  flushInterval,
+		flushThreshold: flushThreshold,
+
+		bus:      bus,
+		logger:   logger,
+		name:     name,
+		newBatch: newBatch,
+
+		batch:    newBatch(),
+		flushCtx: ctx,
+	}
+}
+
+// record passes the current batch to the given function while holding the
+// lock and schedules a flush.
+func (r *batchRecorder) record(fn func(recordBatch)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(r.batch)
+	r.scheduleFlush()
+}
+
+// Stop stops the flush timer, waits for ongoing flushes and flushes one last
+// time.
+func (r *batchRecorder) Stop(ctx context.Context) {
+	// stop the flush timer
+	r.mu.Lock()
+	if r.flushTimer != nil && r.flushTimer.Stop() {
+		r.flushWg.Done()
+	}
+	r.flushCtx = ctx
+	r.mu.Unlock()
+
+	// wait for ongoing flushes and flush all records
+	r.flushWg.Wait()
+	r.flush()
+
+	// log if we weren't able to flush them
+	r.mu.Lock()
+	if n := r.batch.len(); n > 0 {
+		r.logger.Errorw(fmt.Sprintf("failed to record %d %s on worker shutdown", n, r.name))
+	}
+	r.mu.Unlock()
+}
+
+// scheduleFlush schedules a flush after the flush interval, or right away if
+// the flush threshold is reached. The caller must hold the lock.
+func (r *batchRecorder) scheduleFlush() {
+	if r.flushTimer == nil {
+		r.flushWg.Add(1)
+		r.flushTimer = time.AfterFunc(r.flushInterval, r.scheduledFlush)
+	}
+	if r.flushThreshold > 0 && r.batch.len() >= r.flushThreshold && r.flushTimer.Stop() {
+		r.flushTimer = time.AfterFunc(0, r.scheduledFlush)
+	}
+}
+
+// scheduledFlush is called by the flush timer, it flushes the records and
+// marks the scheduled flush as done.
+func (r *batchRecorder) scheduledFlush() {
+	defer r.flushWg.Done()
+	r.flush()
+}
+
+func (r *batchRecorder) flush() {
+	r.mu.Lock()
+
+	// NOTE: don't bother flushing if the context is cancelled, we flush on
+	// shutdown and log in case we weren't able to flush all records
+	select {
+	case <-r.flushCtx.Done():
+		r.flushTimer = nil
+		r.mu.Unlock()
+		return
+	default:
+	}
+
+	// swap out the batch
+	ctx := r.flushCtx
+	batch := r.batch
+	r.batch = r.newBatch()
+	r.flushTimer = nil
+	r.mu.Unlock()
+
+	if batch.len() == 0 {
+		return
+	}
+
+	// send it and put back the records we failed to send, that way the
+	// final flush on shutdown gets to retry them
+	if err := batch.send(ctx, r.bus); err != nil {
+		r.logger.Errorw(fmt.Sprintf("failed to record %d %s: %v", batch.len(), r.name, err))
+
+		r.mu.Lock()
+		batch.merge(r.batch)
+		r.batch = batch
+		r.mu.Unlock()
+	}
+}
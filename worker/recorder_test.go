@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// testBatch is a batch of ints that fails to be sent as long as its 'fail' flag
+// is set.
+type testBatch struct {
+	records []int
+	sent    *[]int
+	fail    *bool
+}
+
+func (b *testBatch) len() int { return len(b.records) }
+
+func (b *testBatch) merge(other recordBatch) {
+	b.records = append(b.records, other.(*testBatch).records...)
+}
+
+func (b *testBatch) send(ctx context.Context, bus Bus) error {
+	if *b.fail {
+		return errors.New("failed")
+	}
+	*b.sent = append(*b.sent, b.records...)
+	b.records = nil
+	return nil
+}
+
+func TestBatchRecorder(t *testing.T) {
+	var sent []int
+	fail := true
+	r := newBatchRecorder(context.Background(), nil, zap.NewNop().Sugar(), "test records", time.Hour, 2, func() recordBatch {
+		return &testBatch{sent: &sent, fail: &fail}
+	})
+	record := func(i int) {
+		r.record(func(b recordBatch) {
+			b.(*testBatch).records = append(b.(*testBatch).records, i)
+		})
+	}
+
+	// reach the threshold while the bus is failing
+	record(1)
+	record(2)
+
+	// wait until the flush is done and assert the records were put back
+	r.flushWg.Wait()
+	r.mu.Lock()
+	if n := r.batch.len(); n != 2 {
+		t.Fatalf("expected 2 records to be put back, got %v", n)
+	}
+	r.mu.Unlock()
+
+	// record another one once the bus recovered and stop the recorder
+	fail = false
+	record(3)
+	r.Stop(context.Background())
+
+	// assert all records were sent in order
+	if len(sent) != 3 || sent[0] != 1 || sent[1] != 2 || sent[2] != 3 {
+		t.Fatalf("unexpected records %v", sent)
+	}
+}
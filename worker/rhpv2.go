@@ -635,10 +635,11 @@ func (w *worker) fetchContractRoots(t *rhpv2.Transport, rev *rhpv2.ContractRevis
 }
 
 func (w *worker) withTransportV2(ctx context.Context, hostKey types.PublicKey, hostIP string, fn func(*rhpv2.Transport) error) (err error) {
-	conn, err := dial(ctx, hostIP)
+	conn, err := dial(ctx, w.rateLimiter, hostIP)
 	if err != nil {
 		return err
 	}
+	conn = wrapBandwidthConn(conn, hostKey, w.hostBandwidthRecorder)
 	done := make(chan struct{})
 	go func() {
 		select {
@@ -135,6 +135,8 @@ type transportV3 struct {
 
 	mu         sync.Mutex
 	hostKey    types.PublicKey
+	rl         *rateLimiter
+	br         HostBandwidthRecorder
 	siamuxAddr string
 	t          *rhpv3.Transport
 }
@@ -175,7 +177,7 @@ func (t *transportV3) DialStream(ctx context.Context) (*streamV3, error) {
 	t.mu.Lock()
 	if t.t == nil {
 		start := time.Now()
-		newTransport, err := dialTransport(ctx, t.siamuxAddr, t.hostKey)
+		newTransport, err := dialTransport(ctx, t.rl, t.br, t.siamuxAddr, t.hostKey)
 		if err != nil {
 			t.mu.Unlock()
 			return nil, fmt.Errorf("DialStream: %w: %w (%v)", errDialTransport, err, time.Since(start))
@@ -212,22 +214,28 @@ func (t *transportV3) DialStream(ctx context.Context) (*streamV3, error) {
 
 // transportPoolV3 is a pool of rhpv3.Transports which allows for reusing them.
 type transportPoolV3 struct {
+	rl *rateLimiter
+	br HostBandwidthRecorder
+
 	mu   sync.Mutex
 	pool map[string]*transportV3
 }
 
-func newTransportPoolV3() *transportPoolV3 {
+func newTransportPoolV3(rl *rateLimiter, br HostBandwidthRecorder) *transportPoolV3 {
 	return &transportPoolV3{
+		rl:   rl,
+		br:   br,
 		pool: make(map[string]*transportV3),
 	}
 }
 
-func dialTransport(ctx context.Context, siamuxAddr string, hostKey types.PublicKey) (*rhpv3.Transport, error) {
+func dialTransport(ctx context.Context, rl *rateLimiter, br HostBandwidthRecorder, siamuxAddr string, hostKey types.PublicKey) (*rhpv3.Transport, error) {
 	// Dial host.
-	conn, err := dial(ctx, siamuxAddr)
+	conn, err := dial(ctx, rl, siamuxAddr)
 	if err != nil {
 		return nil, err
 	}
+	conn = wrapBandwidthConn(conn, hostKey, br)
 
 	// Upgrade to rhpv3.Transport.
 	var t *rhpv3.Transport
@@ -253,6 +261,8 @@ func (p *transportPoolV3) withTransportV3(ctx context.Context, hostKey types.Pub
 	if !found {
 		t = &transportV3{
 			hostKey:    hostKey,
+			rl:         p.rl,
+			br:         p.br,
 			siamuxAddr: siamuxAddr,
 		}
 		p.pool[siamuxAddr] = t
@@ -404,7 +414,7 @@ func (w *worker) initTransportPool() {
 	if w.transportPoolV3 != nil {
 		panic("transport pool already initialized") // developer error
 	}
-	w.transportPoolV3 = newTransportPoolV3()
+	w.transportPoolV3 = newTransportPoolV3(w.rateLimiter, w.hostBandwidthRecorder)
 }
 
 // ForHost returns an account to use for a given host. If the account
@@ -477,6 +487,11 @@ func (a *account) WithWithdrawal(ctx context.Context, amtFn func() (types.Curren
 			return fmt.Errorf("%w; account requires resync", errBalanceInsufficient)
 		}
 
+		// return early if the account drifted
+		if account.Drifted {
+			return fmt.Errorf("%w; account drifted", errBalanceInsufficient)
+		}
+
 		// return early if our account is not funded
 		if account.Balance.Cmp(big.NewInt(0)) <= 0 {
 			return errBalanceInsufficient
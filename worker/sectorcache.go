@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+)
+
+type (
+	// sectorCache is an LRU cache for full sectors, keyed by sector root. The
+	// amount of memory it uses is bounded by its max size.
+	sectorCache struct {
+		maxSize uint64
+
+		hits   atomic.Uint64
+		misses atomic.Uint64
+
+		mu      sync.Mutex
+		size    uint64
+		lru     *list.List
+		entries map[types.Hash256]*list.Element
+	}
+
+	sectorCacheEntry struct {
+		root   types.Hash256
+		sector []byte
+	}
+)
+
+func newSectorCache(maxSize uint64) *sectorCache {
+	return &sectorCache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[types.Hash256]*list.Element),
+	}
+}
+
+// Add adds a copy of the given sector to the cache, evicting the least
+// recently used sectors if the cache is full. Partial sectors are ignored.
+func (c *sectorCache) Add(root types.Hash256, sector []byte) {
+	if c == nil || len(sector) != rhpv2.SectorSize || c.maxSize < rhpv2.SectorSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// check if the sector is already cached
+	if e, ok := c.entries[root]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	// evict sectors until there's room for the new one
+	for c.size+rhpv2.SectorSize > c.maxSize {
+		c.removeElement(c.lru.Back())
+	}
+
+	// copy the sector since the caller might modify it, e.g. when decrypting
+	c.entries[root] = c.lru.PushFront(&sectorCacheEntry{
+		root:   root,
+		sector: append([]byte(nil), sector...),
+	})
+	c.size += rhpv2.SectorSize
+}
+
+// Get returns a copy of the given region of the sector with the given root.
+// Cached sectors are verified against their root before being used, sectors
+// that fail verification are evicted.
+func (c *sectorCache) Get(root types.Hash256, offset, length uint32) ([]byte, bool) {
+	if c == nil || uint64(offset)+uint64(length) > rhpv2.SectorSize {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[root]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	// cached sectors are never modified, so we can verify them without
+	// holding the lock
+	sector := e.Value.(*sectorCacheEntry).sector
+	if rhpv2.SectorRoot((*[rhpv2.SectorSize]byte)(sector)) != root {
+		c.mu.Lock()
+		if c.entries[root] == e {
+			c.removeElement(e)
+		}
+		c.mu.Unlock()
+		c.misses.Add(1)
+		return nil, false
+	}
+
+	c.hits.Add(1)
+	return append([]byte(nil), sector[offset:offset+length]...), true
+}
+
+func (c *sectorCache) removeElement(e *list.Element) {
+	entry := c.lru.Remove(e).(*sectorCacheEntry)
+	delete(c.entries, entry.root)
+	c.size -= rhpv2.SectorSize
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"lukechampine.com/frand"
+)
+
+func TestServeRange(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	w.AddHosts(testRedundancySettings.TotalShards)
+
+	// upload an object that spans two slabs
+	params := testParameters("/" + t.Name())
+	data := frand.Bytes(int(params.rs.SlabSize()) + 1<<10)
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// serve the worker API
+	srv := httptest.NewServer(w.Handler())
+	defer srv.Close()
+
+	get := func(rangeHdr string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/objects/%s?bucket=%s", srv.URL, t.Name(), testBucket), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Range", rangeHdr)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// request a range that crosses the slab boundary
+	start, end := int(params.rs.SlabSize())-100, int(params.rs.SlabSize())+99
+	resp := get(fmt.Sprintf("bytes=%d-%d", start, end))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	} else if cr := resp.Header.Get("Content-Range"); cr != fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)) {
+		t.Fatal("unexpected Content-Range", cr)
+	}
+
+	// assert the returned bytes match
+	got, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, data[start:end+1]) {
+		t.Fatal("data mismatch")
+	}
+
+	// assert multi-range requests are rejected
+	resp = get("bytes=0-1,10-11")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal("unexpected status code", resp.StatusCode)
+	}
+}
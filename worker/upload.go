@@ -17,6 +17,7 @@ import (
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/object"
 	"go.sia.tech/renterd/stats"
 	"go.uber.org/zap"
@@ -25,14 +26,15 @@ import (
 const (
 	statsRecomputeMinInterval = 3 * time.Second
 
-	defaultPackedSlabsLockDuration  = 10 * time.Minute
-	defaultPackedSlabsUploadTimeout = 10 * time.Minute
+	defaultPackedSlabsLockDuration   = 10 * time.Minute
+	defaultPackedSlabsUploadInterval = 5 * time.Minute
+	defaultPackedSlabsUploadTimeout  = 10 * time.Minute
 )
 
 var (
 	errContractExpired      = errors.New("contract expired")
+	errInvalidPatchLength   = errors.New("patch length doesn't match the uploaded data")
 	errNoCandidateUploader  = errors.New("no candidate uploader found")
-	errNotEnoughContracts   = errors.New("not enough contracts to support requested redundancy")
 	errUploadInterrupted    = errors.New("upload was interrupted")
 	errSectorUploadFinished = errors.New("sector upload already finished")
 )
@@ -51,6 +53,18 @@ type (
 		maxOverdrive     uint64
 		overdriveTimeout time.Duration
 
+		// maxConcurrentSlabs is the max number of slabs of a single object
+		// that are uploaded concurrently, 0 means it's only limited by the
+		// available memory. It can only lower the concurrency, every slab
+		// still needs to acquire its memory so the upload memory remains
+		// the upper bound.
+		maxConcurrentSlabs uint64
+
+		// maxSectorRetries is the max number of times failed sector uploads
+		// of a single slab are retried on another host, 0 means they are
+		// retried until we run out of hosts
+		maxSectorRetries uint64
+
 		statsOverdrivePct              *stats.DataPoints
 		statsSlabUploadSpeedBytesPerMS *stats.DataPoints
 
@@ -77,6 +91,8 @@ type (
 		contractLockPriority int
 		contractLockDuration time.Duration
 
+		maxSectorRetries uint64
+
 		shutdownCtx context.Context
 	}
 
@@ -88,12 +104,15 @@ type (
 		maxOverdrive  uint64
 		lastOverdrive time.Time
 
+		maxRetries uint64
+
 		sectors    []*sectorUpload
 		candidates []*candidate // sorted by upload estimate
 
 		numLaunched    uint64
 		numInflight    uint64
 		numOverdriving uint64
+		numRetries     uint64
 		numUploaded    uint64
 		numSectors     uint64
 
@@ -146,13 +165,13 @@ type (
 	}
 )
 
-func (w *worker) initUploadManager(maxMemory, maxOverdrive uint64, overdriveTimeout time.Duration, logger *zap.SugaredLogger) {
+func (w *worker) initUploadManager(maxMemory, maxOverdrive, maxConcurrentSlabs, maxSectorRetries uint64, overdriveTimeout time.Duration, logger *zap.SugaredLogger) {
 	if w.uploadManager != nil {
 		panic("upload manager already initialized") // developer error
 	}
 
 	mm := newMemoryManager(logger.Named("memorymanager"), maxMemory)
-	w.uploadManager = newUploadManager(w.shutdownCtx, w, mm, w.bus, w.bus, w.bus, maxOverdrive, overdriveTimeout, w.contractLockingDuration, logger)
+	w.uploadManager = newUploadManager(w.shutdownCtx, w, mm, w.bus, w.bus, w.bus, maxOverdrive, maxConcurrentSlabs, maxSectorRetries, overdriveTimeout, w.contractLockingDuration, logger)
 }
 
 func (w *worker) upload(ctx context.Context, bucket, path string, r io.Reader, contracts []api.ContractMetadata, opts ...UploadOption) (_ string, err error) {
@@ -163,7 +182,7 @@ func (w *worker) upload(ctx context.Context, bucket, path string, r io.Reader, c
 	}
 
 	// if not given, try decide on a mime type using the file extension
-	if !up.multipart && up.mimeType == "" {
+	if !up.multipart && up.patch == nil && up.mimeType == "" {
 		up.mimeType = mime.TypeByExtension(filepath.Ext(up.path))
 
 		// if mime type is still not known, wrap the reader with a mime reader
@@ -212,6 +231,32 @@ func (w *worker) upload(ctx context.Context, bucket, path string, r io.Reader, c
 	return eTag, nil
 }
 
+// threadedUploadPackedSlabsLoop periodically uploads packed slabs that are
+// ready for upload using the default upload settings. Uploads only trigger
+// packed slab uploads for their own settings, this makes sure slab buffers
+// the bus sealed because they reached their max age get uploaded too.
+func (w *worker) threadedUploadPackedSlabsLoop() {
+	t := time.NewTicker(defaultPackedSlabsUploadInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-w.shutdownCtx.Done():
+			return
+		case <-t.C:
+		}
+
+		up, err := w.bus.UploadParams(w.shutdownCtx)
+		if err != nil {
+			w.logger.Errorf("couldn't fetch upload params from bus: %v", err)
+			continue
+		} else if !up.UploadPacking {
+			continue
+		}
+		w.threadedUploadPackedSlabs(up.RedundancySettings, up.ContractSet, lockingPriorityBackgroundUpload)
+	}
+}
+
 func (w *worker) threadedUploadPackedSlabs(rs api.RedundancySettings, contractSet string, lockPriority int) {
 	key := fmt.Sprintf("%d-%d_%s", rs.MinShards, rs.TotalShards, contractSet)
 	w.uploadsMu.Lock()
@@ -303,7 +348,7 @@ func (w *worker) tryUploadPackedSlab(ctx context.Context, mem Memory, ps api.Pac
 	return nil
 }
 
-func newUploadManager(ctx context.Context, hm HostManager, mm MemoryManager, os ObjectStore, cl ContractLocker, cs ContractStore, maxOverdrive uint64, overdriveTimeout time.Duration, contractLockDuration time.Duration, logger *zap.SugaredLogger) *uploadManager {
+func newUploadManager(ctx context.Context, hm HostManager, mm MemoryManager, os ObjectStore, cl ContractLocker, cs ContractStore, maxOverdrive, maxConcurrentSlabs, maxSectorRetries uint64, overdriveTimeout time.Duration, contractLockDuration time.Duration, logger *zap.SugaredLogger) *uploadManager {
 	return &uploadManager{
 		hm:     hm,
 		mm:     mm,
@@ -314,8 +359,10 @@ func newUploadManager(ctx context.Context, hm HostManager, mm MemoryManager, os
 
 		contractLockDuration: contractLockDuration,
 
-		maxOverdrive:     maxOverdrive,
-		overdriveTimeout: overdriveTimeout,
+		maxOverdrive:       maxOverdrive,
+		maxConcurrentSlabs: maxConcurrentSlabs,
+		maxSectorRetries:   maxSectorRetries,
+		overdriveTimeout:   overdriveTimeout,
 
 		statsOverdrivePct:              stats.NoDecay(),
 		statsSlabUploadSpeedBytesPerMS: stats.NoDecay(),
@@ -384,13 +431,30 @@ func (mgr *uploadManager) Stop() {
 }
 
 func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []api.ContractMetadata, up uploadParameters, lockPriority int) (bufferSizeLimitReached bool, eTag string, err error) {
-	// cancel all in-flight requests when the upload is done
+	// validate the metadata before reading any data, the bus rejects it
+	// anyway but only once the whole object was uploaded
+	if err := up.metadata.Validate(); err != nil {
+		return false, "", err
+	}
+
+	// cancel all in-flight requests when the upload is done or its deadline
+	// is exceeded
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	if !up.deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, up.deadline)
+		defer cancel()
+	}
 
 	// create the object
 	o := object.NewObject(up.ec)
 
+	// a patch has to contain exactly the patched range, a short patch fails
+	// the upload as soon as it's read and before the affected slab is uploaded
+	if up.patch != nil {
+		r = &patchReader{r: io.LimitReader(r, int64(up.patch.length)), remaining: up.patch.length}
+	}
+
 	// create the md5 hasher for the etag
 	// NOTE: we use md5 since it's s3 compatible and clients expect it to be md5
 	hasher := md5.New()
@@ -408,8 +472,16 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 		return false, "", err
 	}
 
+	// start the upload span, it spans the lifetime of the upload in the bus
+	// and is the parent of all sector upload spans
+	ctx, span := tracing.StartSpan(ctx, "worker.Upload",
+		tracing.AttributeUploadID.String(upload.id.String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// track the upload in the bus
-	if err := mgr.os.TrackUpload(ctx, upload.id); err != nil {
+	deadline, _ := ctx.Deadline()
+	if err := mgr.os.TrackUpload(ctx, upload.id, lockPriority, deadline); err != nil {
 		return false, "", fmt.Errorf("failed to track upload '%v', err: %w", upload.id, err)
 	}
 
@@ -433,6 +505,28 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 	slabSize := up.rs.SlabSize()
 	var partialSlab []byte
 
+	// limit the number of slabs we upload concurrently
+	var slabSem chan struct{}
+	if mgr.maxConcurrentSlabs > 0 {
+		slabSem = make(chan struct{}, mgr.maxConcurrentSlabs)
+	}
+	acquireSlot := func() bool {
+		if slabSem == nil {
+			return true
+		}
+		select {
+		case slabSem <- struct{}{}:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	releaseSlot := func() {
+		if slabSem != nil {
+			<-slabSem
+		}
+	}
+
 	// launch uploads in a separate goroutine
 	go func() {
 		var slabIndex int
@@ -444,9 +538,16 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 				return // interrupted
 			default:
 			}
+
+			// wait for a slot to upload the slab
+			if !acquireSlot() {
+				return // interrupted
+			}
+
 			// acquire memory
 			mem := mgr.mm.AcquireMemory(ctx, slabSize)
 			if mem == nil {
+				releaseSlot()
 				return // interrupted
 			}
 
@@ -455,6 +556,7 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 			length, err := io.ReadFull(io.LimitReader(cr, int64(slabSizeNoRedundancy)), data)
 			if err == io.EOF {
 				mem.Release()
+				releaseSlot()
 
 				// no more data to upload, notify main thread of the number of
 				// slabs to wait for
@@ -466,6 +568,7 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 				return
 			} else if err != nil && err != io.ErrUnexpectedEOF {
 				mem.Release()
+				releaseSlot()
 
 				// unexpected error, notify main thread
 				select {
@@ -475,6 +578,7 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 				return
 			} else if up.packing && errors.Is(err, io.ErrUnexpectedEOF) {
 				mem.Release()
+				releaseSlot()
 
 				// uploadPacking is true, we return the partial slab without
 				// uploading.
@@ -482,6 +586,8 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 			} else {
 				// regular upload
 				go func(rs api.RedundancySettings, data []byte, length, slabIndex int) {
+					defer releaseSlot()
+
 					uploadSpeed, overdrivePct := upload.uploadSlab(ctx, rs, data, length, slabIndex, respChan, mgr.candidates(upload.allowed), mem, mgr.maxOverdrive, mgr.overdriveTimeout)
 
 					// track stats
@@ -505,7 +611,7 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 		case <-mgr.shutdownCtx.Done():
 			return false, "", ErrShuttingDown
 		case <-ctx.Done():
-			return false, "", errUploadInterrupted
+			return false, "", fmt.Errorf("%w: %w", errUploadInterrupted, ctx.Err())
 		case numSlabs = <-numSlabsChan:
 		case res := <-respChan:
 			if res.err != nil {
@@ -544,6 +650,21 @@ func (mgr *uploadManager) Upload(ctx context.Context, r io.Reader, contracts []a
 		if err != nil {
 			return bufferSizeLimitReached, "", fmt.Errorf("couldn't add multi part: %w", err)
 		}
+	} else if up.patch != nil {
+		// splice the uploaded slabs into the patched object, the etag of the
+		// patched object is derived from its previous etag and the etag of
+		// the patch since we don't have access to the object's full data
+		patched := *up.patch.object.Object
+		patched.Slabs = patchSlabSlices(patched.Slabs, o.Slabs, up.patch.offset, up.patch.length)
+		sum := md5.Sum([]byte(up.patch.object.ETag + eTag))
+		eTag = hex.EncodeToString(sum[:])
+
+		// persist the object, the bus rejects the patch if the object was
+		// modified since we fetched it
+		err = mgr.os.AddObject(ctx, up.bucket, up.path, up.contractSet, patched, api.AddObjectOptions{MimeType: up.mimeType, ETag: eTag, Metadata: up.metadata, ExpectedETag: up.patch.object.ETag})
+		if err != nil {
+			return bufferSizeLimitReached, "", fmt.Errorf("couldn't patch object: %w", err)
+		}
 	} else {
 		// persist the object
 		err = mgr.os.AddObject(ctx, up.bucket, up.path, up.contractSet, o, api.AddObjectOptions{MimeType: up.mimeType, ETag: eTag, Metadata: up.metadata})
@@ -569,8 +690,15 @@ func (mgr *uploadManager) UploadPackedSlab(ctx context.Context, rs api.Redundanc
 		return err
 	}
 
+	// start the upload span
+	ctx, span := tracing.StartSpan(ctx, "worker.UploadPackedSlab",
+		tracing.AttributeUploadID.String(upload.id.String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// track the upload in the bus
-	if err := mgr.os.TrackUpload(ctx, upload.id); err != nil {
+	deadline, _ := ctx.Deadline()
+	if err := mgr.os.TrackUpload(ctx, upload.id, lockPriority, deadline); err != nil {
 		return fmt.Errorf("failed to track upload '%v', err: %w", upload.id, err)
 	}
 
@@ -614,8 +742,16 @@ func (mgr *uploadManager) UploadShards(ctx context.Context, s object.Slab, shard
 		return err
 	}
 
+	// start the upload span
+	ctx, span := tracing.StartSpan(ctx, "worker.UploadShards",
+		tracing.AttributeUploadID.String(upload.id.String()),
+		tracing.AttributeSlabKey.String(s.Key.String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// track the upload in the bus
-	if err := mgr.os.TrackUpload(ctx, upload.id); err != nil {
+	deadline, _ := ctx.Deadline()
+	if err := mgr.os.TrackUpload(ctx, upload.id, lockPriority, deadline); err != nil {
 		return fmt.Errorf("failed to track upload '%v', err: %w", upload.id, err)
 	}
 
@@ -651,6 +787,59 @@ func (mgr *uploadManager) UploadShards(ctx context.Context, s object.Slab, shard
 	return mgr.os.UpdateSlab(ctx, s, contractSet)
 }
 
+// RekeySlab uploads the given shards, which are expected to be encrypted with
+// the key of the given slab, and replaces the slab with given key in the bus.
+func (mgr *uploadManager) RekeySlab(ctx context.Context, oldKey object.EncryptionKey, s object.Slab, shards [][]byte, contractSet string, contracts []api.ContractMetadata, bh uint64, lockPriority int, mem Memory) (_ object.Slab, err error) {
+	// cancel all in-flight requests when the upload is done
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// create the upload
+	upload, err := mgr.newUpload(len(shards), contracts, bh, lockPriority)
+	if err != nil {
+		return object.Slab{}, err
+	}
+
+	// start the upload span
+	ctx, span := tracing.StartSpan(ctx, "worker.RekeySlab",
+		tracing.AttributeUploadID.String(upload.id.String()),
+		tracing.AttributeSlabKey.String(oldKey.String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
+	// track the upload in the bus
+	deadline, _ := ctx.Deadline()
+	if err := mgr.os.TrackUpload(ctx, upload.id, lockPriority, deadline); err != nil {
+		return object.Slab{}, fmt.Errorf("failed to track upload '%v', err: %w", upload.id, err)
+	}
+
+	// defer a function that finishes the upload
+	defer func() {
+		ctx, cancel := context.WithTimeout(mgr.shutdownCtx, time.Minute)
+		if err := mgr.os.FinishUpload(ctx, upload.id); err != nil {
+			mgr.logger.Errorf("failed to mark upload %v as finished: %v", upload.id, err)
+		}
+		cancel()
+	}()
+
+	// upload the shards
+	uploaded, uploadSpeed, overdrivePct, err := upload.uploadShards(ctx, shards, mgr.candidates(upload.allowed), mem, mgr.maxOverdrive, mgr.overdriveTimeout)
+	if err != nil {
+		return object.Slab{}, err
+	}
+
+	// track stats
+	mgr.statsOverdrivePct.Track(overdrivePct)
+	mgr.statsSlabUploadSpeedBytesPerMS.Track(float64(uploadSpeed))
+
+	// replace the slab
+	s.Shards = uploaded
+	if err := mgr.os.RekeySlab(ctx, oldKey, s, contractSet); err != nil {
+		return object.Slab{}, err
+	}
+	return s, nil
+}
+
 func (mgr *uploadManager) candidates(allowed map[types.PublicKey]struct{}) (candidates []*uploader) {
 	mgr.mu.Lock()
 	defer mgr.mu.Unlock()
@@ -677,7 +866,7 @@ func (mgr *uploadManager) newUpload(totalShards int, contracts []api.ContractMet
 
 	// check if we have enough contracts
 	if len(contracts) < totalShards {
-		return nil, fmt.Errorf("%v < %v: %w", len(contracts), totalShards, errNotEnoughContracts)
+		return nil, fmt.Errorf("%v < %v: %w", len(contracts), totalShards, api.ErrInsufficientContracts)
 	}
 
 	// create allowed map
@@ -692,6 +881,7 @@ func (mgr *uploadManager) newUpload(totalShards int, contracts []api.ContractMet
 		allowed:              allowed,
 		contractLockDuration: mgr.contractLockDuration,
 		contractLockPriority: lockPriority,
+		maxSectorRetries:     mgr.maxSectorRetries,
 		shutdownCtx:          mgr.shutdownCtx,
 	}, nil
 }
@@ -786,6 +976,7 @@ func (u *upload) newSlabUpload(ctx context.Context, shards [][]byte, uploaders [
 		contractLockDuration: u.contractLockDuration,
 
 		maxOverdrive: maxOverdrive,
+		maxRetries:   u.maxSectorRetries,
 		mem:          mem,
 
 		sectors:    sectors,
@@ -882,8 +1073,13 @@ loop:
 				break loop
 			}
 
-			// relaunch non-overdrive uploads
+			// relaunch non-overdrive uploads on the next best host, unless
+			// we've exhausted the retry budget
 			if resp.err != nil && !resp.req.overdrive {
+				if !slab.canRetry() {
+					break loop
+				}
+				slab.numRetries++
 				if err := slab.launch(resp.req); err != nil {
 					// a failure to relaunch non-overdrive uploads is bad, but
 					// we need to keep them around because an overdrive upload
@@ -938,7 +1134,7 @@ loop:
 
 	if slab.numUploaded < slab.numSectors {
 		remaining := slab.numSectors - slab.numUploaded
-		err = fmt.Errorf("failed to upload slab: launched=%d uploaded=%d remaining=%d inflight=%d pending=%d uploaders=%d errors=%d %w", slab.numLaunched, slab.numUploaded, remaining, slab.numInflight, len(buffer), len(slab.candidates), len(slab.errs), slab.errs)
+		err = fmt.Errorf("failed to upload slab: launched=%d uploaded=%d remaining=%d inflight=%d pending=%d retries=%d uploaders=%d errors=%d %w", slab.numLaunched, slab.numUploaded, remaining, slab.numInflight, len(buffer), slab.numRetries, len(slab.candidates), len(slab.errs), slab.errs)
 		return
 	}
 
@@ -969,6 +1165,12 @@ func (s *slabUpload) canOverdrive(overdriveTimeout time.Duration) bool {
 	return true
 }
 
+// canRetry returns true if a failed sector upload can be retried on another
+// host without exceeding the retry budget.
+func (s *slabUpload) canRetry() bool {
+	return s.maxRetries == 0 || s.numRetries < s.maxRetries
+}
+
 func (s *slabUpload) launch(req *sectorUploadReq) error {
 	// nothing to do
 	if req == nil {
@@ -1121,3 +1323,51 @@ func (req *sectorUploadReq) finish(err error) {
 	}:
 	}
 }
+
+// patchSlabSlices replaces the range [offset, offset+length) of the data
+// referenced by the given slices with the data referenced by the patch. Slices
+// that are only partially overwritten are split, slices that are not affected
+// by the patch are kept as-is.
+func patchSlabSlices(slices, patch []object.SlabSlice, offset, length uint64) []object.SlabSlice {
+	patched := slabSlicesInRange(slices, 0, offset)
+	patched = append(patched, patch...)
+	return append(patched, slabSlicesInRange(slices, offset+length, math.MaxUint64)...)
+}
+
+// slabSlicesInRange returns the slices that reference the data in the range
+// [from, to), slices at the boundaries of the range are trimmed.
+func slabSlicesInRange(slices []object.SlabSlice, from, to uint64) (ss []object.SlabSlice) {
+	var pos uint64
+	for _, slice := range slices {
+		start, end := pos, pos+uint64(slice.Length)
+		pos = end
+		if end <= from || start >= to {
+			continue
+		}
+		if from > start {
+			slice.Offset += uint32(from - start)
+			slice.Length -= uint32(from - start)
+		}
+		if to < end {
+			slice.Length -= uint32(end - to)
+		}
+		ss = append(ss, slice)
+	}
+	return
+}
+
+// patchReader wraps the reader of a patch and returns errInvalidPatchLength if
+// the reader is drained before the patch length was read.
+type patchReader struct {
+	r         io.Reader
+	remaining uint64
+}
+
+func (pr *patchReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	pr.remaining -= uint64(n)
+	if errors.Is(err, io.EOF) && pr.remaining > 0 {
+		err = fmt.Errorf("%w; %d bytes missing", errInvalidPatchLength, pr.remaining)
+	}
+	return n, err
+}
@@ -1,6 +1,8 @@
 package worker
 
 import (
+	"time"
+
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/build"
 	"go.sia.tech/renterd/object"
@@ -20,10 +22,21 @@ type uploadParameters struct {
 	rs          api.RedundancySettings
 	bh          uint64
 	contractSet string
+	deadline    time.Time
 	packing     bool
 	mimeType    string
 
 	metadata api.ObjectUserMetadata
+
+	patch *objectPatch
+}
+
+// objectPatch describes the range of an existing object that is overwritten by
+// an upload.
+type objectPatch struct {
+	object api.Object
+	offset uint64
+	length uint64
 }
 
 func defaultParameters(bucket, path string) uploadParameters {
@@ -64,6 +77,14 @@ func WithCustomEncryptionOffset(offset uint64) UploadOption {
 	}
 }
 
+// WithDeadline aborts the upload if it isn't finished by the given deadline,
+// the bus aborts the tracked upload as well.
+func WithDeadline(deadline time.Time) UploadOption {
+	return func(up *uploadParameters) {
+		up.deadline = deadline
+	}
+}
+
 func WithMimeType(mimeType string) UploadOption {
 	return func(up *uploadParameters) {
 		up.mimeType = mimeType
@@ -95,6 +116,22 @@ func WithUploadID(uploadID string) UploadOption {
 	}
 }
 
+// WithPatch turns the upload into a patch of the given object, the uploaded
+// data replaces the object's data in the range [offset, offset+length).
+func WithPatch(o api.Object, offset, length uint64) UploadOption {
+	return func(up *uploadParameters) {
+		up.ec = o.Key
+		up.encryptionOffset = offset
+		up.mimeType = o.MimeType
+		up.metadata = o.Metadata
+		up.patch = &objectPatch{
+			object: o,
+			offset: offset,
+			length: length,
+		}
+	}
+}
+
 func WithObjectUserMetadata(metadata api.ObjectUserMetadata) UploadOption {
 	return func(up *uploadParameters) {
 		up.metadata = metadata
@@ -5,13 +5,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/internal/test"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/object"
 	"lukechampine.com/frand"
 )
@@ -124,6 +129,99 @@ func TestUpload(t *testing.T) {
 	}
 }
 
+func TestUploadInsufficientContracts(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+
+	// add fewer hosts than the redundancy requires
+	w.AddHosts(testRedundancySettings.TotalShards - 1)
+
+	// create test data
+	data := frand.Bytes(128)
+	r := bytes.NewReader(data)
+
+	// upload data and assert it's rejected
+	params := testParameters(t.Name())
+	_, _, err := w.uploadManager.Upload(context.Background(), r, w.Contracts(), params, lockingPriorityUpload)
+	if !errors.Is(err, api.ErrInsufficientContracts) {
+		t.Fatal("expected insufficient contracts error", err)
+	}
+
+	// assert the upload failed before any data was read
+	if r.Len() != len(data) {
+		t.Fatalf("expected no data to be read, %v bytes were read", len(data)-r.Len())
+	}
+
+	// assert no object was created
+	_, err = w.os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if !errors.Is(err, api.ErrObjectNotFound) {
+		t.Fatal("expected object not found error", err)
+	}
+}
+
+func TestUploadUserMetadataTooLarge(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	w.AddHosts(testRedundancySettings.TotalShards)
+
+	// create test data
+	data := frand.Bytes(128)
+	r := bytes.NewReader(data)
+
+	// upload data with metadata that exceeds the limit and assert it's
+	// rejected
+	params := testParameters(t.Name())
+	params.metadata = api.ObjectUserMetadata{"key": string(frand.Bytes(api.ObjectUserMetadataMaxSize))}
+	_, _, err := w.uploadManager.Upload(context.Background(), r, w.Contracts(), params, lockingPriorityUpload)
+	if !errors.Is(err, api.ErrObjectUserMetadataTooLarge) {
+		t.Fatal("expected metadata too large error", err)
+	}
+
+	// assert the upload failed before any data was read
+	if r.Len() != len(data) {
+		t.Fatalf("expected no data to be read, %v bytes were read", len(data)-r.Len())
+	}
+
+	// assert the upload succeeds with metadata within the limit
+	params.metadata = api.ObjectUserMetadata{"key": "value"}
+	if _, _, err := w.uploadManager.Upload(context.Background(), r, w.Contracts(), params, lockingPriorityUpload); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadDeadline(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	w.AddHosts(testRedundancySettings.TotalShards)
+
+	// upload data with a deadline
+	deadline := time.Now().Add(time.Hour).Round(0)
+	params := testParameters(t.Name())
+	WithDeadline(deadline)(&params)
+	if _, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(frand.Bytes(128)), w.Contracts(), params, lockingPriorityUpload); err != nil {
+		t.Fatal(err)
+	}
+
+	// assert the deadline was passed to the bus
+	w.os.mu.Lock()
+	if len(w.os.deadlines) != 1 {
+		t.Fatalf("expected 1 tracked upload, got %v", len(w.os.deadlines))
+	}
+	for _, d := range w.os.deadlines {
+		if !d.Equal(deadline) {
+			t.Fatalf("unexpected deadline %v != %v", d, deadline)
+		}
+	}
+	w.os.mu.Unlock()
+
+	// assert an upload past its deadline is aborted
+	params = testParameters(t.Name() + "expired")
+	WithDeadline(time.Now().Add(-time.Second))(&params)
+	if _, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(frand.Bytes(128)), w.Contracts(), params, lockingPriorityUpload); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("expected deadline exceeded error", err)
+	}
+}
+
 func TestUploadPackedSlab(t *testing.T) {
 	// create test worker
 	w := newTestWorker(t)
@@ -487,6 +585,162 @@ func TestUploadShards(t *testing.T) {
 	}
 }
 
+func TestRekeySlab(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+
+	// add hosts to worker
+	w.AddHosts(testRedundancySettings.TotalShards)
+
+	// convenience variables
+	os := w.os
+	dl := w.downloadManager
+
+	// upload data
+	data := frand.Bytes(128)
+	params := testParameters(t.Name())
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// grab the slab
+	o, err := os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(o.Object.Object.Slabs) != 1 {
+		t.Fatal("expected 1 slab")
+	}
+	slab := o.Object.Object.Slabs[0].Slab
+
+	// rekey the slab
+	rekeyed, err := w.rekey(context.Background(), slab, testContractSet, w.Contracts(), w.Contracts(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// re-grab the slab and assert its key and roots changed
+	o, err = os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := o.Object.Object.Slabs[0].Slab
+	if stored.Key.String() == slab.Key.String() {
+		t.Fatal("expected slab key to change")
+	} else if stored.Key.String() != rekeyed.Key.String() {
+		t.Fatal("unexpected slab key")
+	} else if len(stored.Shards) != len(slab.Shards) {
+		t.Fatal("unexpected number of shards", len(stored.Shards))
+	}
+	for i := range stored.Shards {
+		if stored.Shards[i].Root == slab.Shards[i].Root {
+			t.Fatal("expected shard root to change")
+		}
+	}
+
+	// download the data and assert it round-trips under the new key
+	var buf bytes.Buffer
+	err = dl.DownloadObject(context.Background(), &buf, *o.Object.Object, 0, uint64(o.Object.Size), w.Contracts())
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, buf.Bytes()) {
+		t.Fatal("data mismatch")
+	}
+}
+
+func TestPatchObject(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+
+	// add hosts to worker
+	rs := api.RedundancySettings{MinShards: 1, TotalShards: 2}
+	w.AddHosts(rs.TotalShards)
+
+	// convenience variables
+	os := w.os
+	dl := w.downloadManager
+	slabSize := int(rs.SlabSizeNoRedundancy())
+
+	// upload an object that spans three slabs
+	data := frand.Bytes(2*slabSize + slabSize/2)
+	params := testParameters(t.Name())
+	params.rs = rs
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := o.Object.Object.Slabs
+	if len(original) != 3 {
+		t.Fatalf("expected 3 slabs, got %d", len(original))
+	}
+
+	// patch a range in the middle of the object that crosses the boundary
+	// between the first and second slab
+	offset, length := slabSize-100, 200
+	patch := frand.Bytes(length)
+	params = testParameters(t.Name())
+	params.rs = rs
+	WithPatch(*o.Object, uint64(offset), uint64(length))(&params)
+	_, _, err = w.uploadManager.Upload(context.Background(), bytes.NewReader(patch), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(data[offset:], patch)
+
+	// assert the untouched slabs are still referenced and the slices at the
+	// boundaries of the patch were split
+	o, err = os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched := o.Object.Object.Slabs
+	if len(patched) != 4 {
+		t.Fatalf("expected 4 slices, got %d", len(patched))
+	} else if patched[0].Key.String() != original[0].Key.String() || patched[0].Offset != 0 || patched[0].Length != uint32(offset) {
+		t.Fatal("unexpected head slice", patched[0].Offset, patched[0].Length)
+	} else if patched[1].Length != uint32(length) {
+		t.Fatal("unexpected patch slice", patched[1].Length)
+	} else if patched[2].Key.String() != original[1].Key.String() || patched[2].Offset != 100 || patched[2].Length != uint32(slabSize-100) {
+		t.Fatal("unexpected tail slice", patched[2].Offset, patched[2].Length)
+	} else if patched[3].Key.String() != original[2].Key.String() || patched[3].Offset != original[2].Offset || patched[3].Length != original[2].Length {
+		t.Fatal("expected last slab to be untouched")
+	}
+
+	// download the object and assert only the patched range changed
+	var buf bytes.Buffer
+	err = dl.DownloadObject(context.Background(), &buf, *o.Object.Object, 0, uint64(o.Object.Size), w.Contracts())
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, buf.Bytes()) {
+		t.Fatal("data mismatch")
+	}
+
+	// assert a patch of the wrong length is rejected
+	params = testParameters(t.Name())
+	params.rs = rs
+	WithPatch(*o.Object, 0, uint64(length))(&params)
+	_, _, err = w.uploadManager.Upload(context.Background(), bytes.NewReader(patch[:length/2]), w.Contracts(), params, lockingPriorityUpload)
+	if !errors.Is(err, errInvalidPatchLength) {
+		t.Fatal("expected invalid patch length error", err)
+	}
+
+	// assert a patch of an object that was modified in the meantime is
+	// rejected
+	params = testParameters(t.Name())
+	params.rs = rs
+	outdated := *o.Object
+	outdated.ETag = "outdated"
+	WithPatch(outdated, 0, uint64(length))(&params)
+	_, _, err = w.uploadManager.Upload(context.Background(), bytes.NewReader(patch), w.Contracts(), params, lockingPriorityUpload)
+	if !errors.Is(err, api.ErrObjectModified) {
+		t.Fatal("expected object modified error", err)
+	}
+}
+
 func TestRefreshUploaders(t *testing.T) {
 	// create test worker
 	w := newTestWorker(t)
@@ -660,6 +914,127 @@ func TestUploadSingleSectorSlowHosts(t *testing.T) {
 	}
 }
 
+func TestUploadConcurrentSlabs(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+
+	// add enough hosts to upload several slabs at once and slow them down so
+	// the slab uploads overlap
+	hosts := w.AddHosts(4 * testRedundancySettings.TotalShards)
+	for _, h := range hosts {
+		h.uploadDelay = 50 * time.Millisecond
+	}
+
+	// upload returns the max number of slabs that were uploaded concurrently
+	numSlabs := 4
+	data := frand.Bytes(numSlabs * testRedundancySettings.MinShards * rhpv2.SectorSize)
+	upload := func(maxConcurrentSlabs uint64) int {
+		t.Helper()
+		w.uploadManager.maxConcurrentSlabs = maxConcurrentSlabs
+		w.ulmm.mu.Lock()
+		w.ulmm.maxInUse = 0
+		w.ulmm.mu.Unlock()
+
+		params := testParameters(fmt.Sprintf("%s_%d", t.Name(), maxConcurrentSlabs))
+		if _, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload); err != nil {
+			t.Fatal(err)
+		}
+		o, err := w.os.Object(context.Background(), testBucket, params.path, api.GetObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		} else if len(o.Object.Slabs) != numSlabs {
+			t.Fatalf("expected %v slabs, got %v", numSlabs, len(o.Object.Slabs))
+		}
+
+		w.ulmm.mu.Lock()
+		defer w.ulmm.mu.Unlock()
+		return w.ulmm.maxInUse
+	}
+
+	// assert the slabs are uploaded concurrently up to the limit
+	if n := upload(2); n != 2 {
+		t.Fatalf("expected 2 concurrent slab uploads, got %v", n)
+	}
+
+	// assert the slabs are only limited by the available memory without limit
+	if n := upload(0); n <= 2 {
+		t.Fatalf("expected more than 2 concurrent slab uploads, got %v", n)
+	}
+}
+
+func TestUploadFailover(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	mgr := w.uploadManager
+
+	// add two more hosts than we need
+	hosts := w.AddHosts(testRedundancySettings.TotalShards + 2)
+	mgr.refreshUploaders(w.Contracts(), 0)
+
+	// uploadShards uploads a slab to the candidates, the failing hosts are
+	// moved to the front so they are tried first
+	uploadShards := func(failing ...*testHost) ([]object.Sector, []*uploader, error) {
+		t.Helper()
+		upload, err := mgr.newUpload(testRedundancySettings.TotalShards, w.Contracts(), 0, lockingPriorityUpload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		candidates := mgr.candidates(upload.allowed)
+		for i, h := range failing {
+			for j, c := range candidates {
+				if c.hk == h.hk {
+					candidates[i], candidates[j] = candidates[j], candidates[i]
+					break
+				}
+			}
+		}
+
+		shards := make([][]byte, testRedundancySettings.TotalShards)
+		for i := range shards {
+			shards[i] = frand.Bytes(rhpv2.SectorSize)
+		}
+		mem := w.ulmm.AcquireMemory(context.Background(), uint64(len(shards))*rhpv2.SectorSize)
+		sectors, _, _, err := upload.uploadShards(context.Background(), shards, candidates, mem, 0, 0)
+		return sectors, candidates, err
+	}
+
+	// make the first host fail
+	errHostFailure := errors.New("host failure")
+	hosts[0].uploadErr = errHostFailure
+
+	// assert the sector is uploaded to the next best host
+	sectors, candidates, err := uploadShards(hosts[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if fallback := candidates[testRedundancySettings.TotalShards].hk; sectors[0].LatestHost != fallback {
+		t.Fatalf("expected sector to be uploaded to fallback host %v, got %v", fallback, sectors[0].LatestHost)
+	}
+	for _, sector := range sectors {
+		if sector.LatestHost == hosts[0].hk {
+			t.Fatal("sector uploaded to failing host")
+		}
+	}
+
+	// make the second host fail as well and limit the retries
+	hosts[1].uploadErr = errHostFailure
+	mgr.maxSectorRetries = 1
+
+	// assert the upload fails once the retry budget is exhausted
+	_, _, err = uploadShards(hosts[0], hosts[1])
+	if err == nil || !strings.Contains(err.Error(), errHostFailure.Error()) {
+		t.Fatal("expected host failure", err)
+	} else if !strings.Contains(err.Error(), "retries=1") {
+		t.Fatal("expected retry budget to be exhausted", err)
+	}
+
+	// assert the upload succeeds without a limit
+	mgr.maxSectorRetries = 0
+	if _, _, err := uploadShards(hosts[0], hosts[1]); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func testParameters(path string) uploadParameters {
 	return uploadParameters{
 		bucket: testBucket,
@@ -679,3 +1054,73 @@ func testOpts() []UploadOption {
 		WithRedundancySettings(testRedundancySettings),
 	}
 }
+
+func TestUploadTracing(t *testing.T) {
+	// register an in-memory span exporter
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	// create test worker
+	w := newTestWorker(t)
+
+	// add hosts to worker
+	w.AddHosts(testRedundancySettings.TotalShards)
+
+	// upload data using a context containing a root span
+	ctx, root := tp.Tracer(t.Name()).Start(context.Background(), t.Name())
+	_, _, err := w.uploadManager.Upload(ctx, bytes.NewReader(frand.Bytes(128)), w.Contracts(), testParameters(t.Name()), lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.End()
+
+	// find the upload span
+	spans := exporter.GetSpans()
+	var upload *tracetest.SpanStub
+	for i := range spans {
+		if spans[i].Name == "worker.Upload" && spans[i].Parent.SpanID() == root.SpanContext().SpanID() {
+			upload = &spans[i]
+			break
+		}
+	}
+	if upload == nil {
+		t.Fatal("upload span not found")
+	}
+
+	// assert it has the upload id attribute
+	var uploadID string
+	for _, attr := range upload.Attributes {
+		if attr.Key == tracing.AttributeUploadID {
+			uploadID = attr.Value.AsString()
+		}
+	}
+	if uploadID == "" {
+		t.Fatal("upload span is missing the upload id")
+	}
+
+	// assert there's a sector upload span per contract
+	fcids := make(map[string]struct{})
+	for _, span := range spans {
+		if span.Name != "worker.UploadSector" || span.Parent.SpanID() != upload.SpanContext.SpanID() {
+			continue
+		}
+		for _, attr := range span.Attributes {
+			if attr.Key == tracing.AttributeUploadID && attr.Value.AsString() != uploadID {
+				t.Fatalf("unexpected upload id %v, expected %v", attr.Value.AsString(), uploadID)
+			} else if attr.Key == tracing.AttributeContractID {
+				fcids[attr.Value.AsString()] = struct{}{}
+			}
+		}
+	}
+	if len(fcids) != testRedundancySettings.TotalShards {
+		t.Fatalf("expected %v contracts in the sector upload spans, got %v", testRedundancySettings.TotalShards, len(fcids))
+	}
+	for _, c := range w.Contracts() {
+		if _, ok := fcids[c.ID.String()]; !ok {
+			t.Fatalf("missing sector upload span for contract %v", c.ID)
+		}
+	}
+}
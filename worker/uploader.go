@@ -11,6 +11,7 @@ import (
 	rhpv2 "go.sia.tech/core/rhp/v2"
 	"go.sia.tech/core/types"
 	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/internal/utils"
 	"go.sia.tech/renterd/stats"
 	"go.uber.org/zap"
@@ -255,6 +256,14 @@ func (u *uploader) execute(req *sectorUploadReq) (_ time.Duration, err error) {
 	fcid := u.fcid
 	u.mu.Unlock()
 
+	// start the sector upload span, it's a child of the upload span
+	ctx, span := tracing.StartSpan(req.sector.ctx, "worker.UploadSector",
+		tracing.AttributeUploadID.String(req.uploadID.String()),
+		tracing.AttributeContractID.String(fcid.String()),
+		tracing.AttributeHostKey.String(u.hk.String()),
+	)
+	defer func() { tracing.EndSpan(span, err) }()
+
 	// wrap cause
 	defer func() {
 		if cause := context.Cause(req.sector.ctx); cause != nil && !utils.IsErr(err, cause) {
@@ -267,7 +276,7 @@ func (u *uploader) execute(req *sectorUploadReq) (_ time.Duration, err error) {
 	}()
 
 	// acquire contract lock
-	lockID, err := u.cl.AcquireContract(req.sector.ctx, fcid, req.contractLockPriority, req.contractLockDuration)
+	lockID, err := u.cl.AcquireContract(ctx, fcid, req.contractLockPriority, req.contractLockDuration)
 	if err != nil {
 		return 0, fmt.Errorf("%w; %w", errAcquireContractFailed, err)
 	}
@@ -281,7 +290,7 @@ func (u *uploader) execute(req *sectorUploadReq) (_ time.Duration, err error) {
 	}()
 
 	// apply sane timeout
-	ctx, cancel := context.WithTimeout(req.sector.ctx, sectorUploadTimeout)
+	ctx, cancel := context.WithTimeout(ctx, sectorUploadTimeout)
 	defer cancel()
 
 	// fetch the revision
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"go.sia.tech/renterd/object"
+)
+
+const (
+	// verifySectorTimeout is the timeout for verifying a single sector is
+	// retrievable from a host.
+	verifySectorTimeout = 30 * time.Second
+)
+
+// VerifyObject checks whether every slab of the given object can be recovered
+// from the hosts without downloading it. Rather than fetching entire sectors,
+// a single leaf of every sector is downloaded, which requires the host to
+// provide a valid merkle proof for the sector.
+func (mgr *downloadManager) VerifyObject(ctx context.Context, o object.Object, contracts []api.ContractMetadata) api.VerifyObjectResponse {
+	// build a map of contracts by host
+	hostContracts := make(map[types.PublicKey]api.ContractMetadata)
+	for _, c := range contracts {
+		hostContracts[c.HostKey] = c
+	}
+
+	resp := api.VerifyObjectResponse{Recoverable: true}
+	for _, slice := range o.Slabs {
+		sv := mgr.verifySlab(ctx, slice.Slab, hostContracts)
+		resp.Recoverable = resp.Recoverable && sv.Recoverable
+		resp.Slabs = append(resp.Slabs, sv)
+	}
+	return resp
+}
+
+func (mgr *downloadManager) verifySlab(ctx context.Context, slab object.Slab, contracts map[types.PublicKey]api.ContractMetadata) api.SlabVerification {
+	sv := api.SlabVerification{
+		Key:         slab.Key,
+		MinShards:   slab.MinShards,
+		TotalShards: uint8(len(slab.Shards)),
+	}
+
+	// partial slabs are stored in the bus' buffers
+	if slab.IsPartial() {
+		sv.Recoverable = true
+		return sv
+	}
+
+	// verify all shards in parallel
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, shard := range slab.Shards {
+		wg.Add(1)
+		go func(shard object.Sector) {
+			defer wg.Done()
+			if mgr.verifySector(ctx, shard, contracts) {
+				mu.Lock()
+				sv.ReachableShards++
+				mu.Unlock()
+			}
+		}(shard)
+	}
+	wg.Wait()
+
+	sv.Recoverable = sv.ReachableShards >= sv.MinShards
+	sv.AtRisk = sv.ReachableShards < sv.TotalShards
+	return sv
+}
+
+// verifySector returns true if the sector can be retrieved from any of the
+// hosts that store it, the sector's latest host is tried first.
+func (mgr *downloadManager) verifySector(ctx context.Context, sector object.Sector, contracts map[types.PublicKey]api.ContractMetadata) bool {
+	hosts := []types.PublicKey{sector.LatestHost}
+	for hk := range sector.Contracts {
+		if hk != sector.LatestHost {
+			hosts = append(hosts, hk)
+		}
+	}
+
+	for _, hk := range hosts {
+		c, ok := contracts[hk]
+		if !ok {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, verifySectorTimeout)
+		err := mgr.hm.Host(c.HostKey, c.ID, c.SiamuxAddr).DownloadSector(ctx, io.Discard, sector.Root, 0, rhpv2.LeafSize, false)
+		cancel()
+		if err == nil {
+			return true
+		}
+		mgr.logger.Debugw("failed to verify sector", "hk", hk, "root", sector.Root, "error", err)
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	rhpv2 "go.sia.tech/core/rhp/v2"
+	"go.sia.tech/core/types"
+	"go.sia.tech/renterd/api"
+	"lukechampine.com/frand"
+)
+
+func TestVerifyObject(t *testing.T) {
+	// create test worker
+	w := newTestWorker(t)
+	hosts := w.AddHosts(testRedundancySettings.TotalShards)
+
+	// upload an object
+	data := frand.Bytes(128)
+	params := testParameters(t.Name())
+	_, _, err := w.uploadManager.Upload(context.Background(), bytes.NewReader(data), w.Contracts(), params, lockingPriorityUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := w.os.Object(context.Background(), testBucket, t.Name(), api.GetObjectOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// helper to verify the object and assert the slab's state
+	assertVerification := func(reachable int, recoverable, atRisk bool) {
+		t.Helper()
+		resp := w.downloadManager.VerifyObject(context.Background(), *o.Object.Object, w.Contracts())
+		if len(resp.Slabs) != 1 {
+			t.Fatalf("expected 1 slab, got %d", len(resp.Slabs))
+		} else if resp.Recoverable != recoverable {
+			t.Fatalf("expected object recoverable %v, got %v", recoverable, resp.Recoverable)
+		}
+		sv := resp.Slabs[0]
+		if sv.ReachableShards != uint8(reachable) {
+			t.Fatalf("expected %d reachable shards, got %d", reachable, sv.ReachableShards)
+		} else if sv.Recoverable != recoverable {
+			t.Fatalf("expected slab recoverable %v, got %v", recoverable, sv.Recoverable)
+		} else if sv.AtRisk != atRisk {
+			t.Fatalf("expected slab at risk %v, got %v", atRisk, sv.AtRisk)
+		} else if sv.MinShards != uint8(testRedundancySettings.MinShards) || sv.TotalShards != uint8(testRedundancySettings.TotalShards) {
+			t.Fatalf("unexpected redundancy %d-of-%d", sv.MinShards, sv.TotalShards)
+		}
+	}
+
+	// helper to make a host lose all of its sectors
+	loseSectors := func(h *testHost) {
+		h.contractMock.mu.Lock()
+		h.contractMock.sectors = make(map[types.Hash256]*[rhpv2.SectorSize]byte)
+		h.contractMock.mu.Unlock()
+	}
+
+	// assert all shards are reachable
+	assertVerification(testRedundancySettings.TotalShards, true, false)
+
+	// make some shards unreachable, the slab should be at risk but recoverable
+	unreachable := testRedundancySettings.TotalShards - testRedundancySettings.MinShards
+	for _, h := range hosts[:unreachable] {
+		loseSectors(h)
+	}
+	assertVerification(testRedundancySettings.MinShards, true, true)
+
+	// make one more shard unreachable, the slab is no longer recoverable
+	loseSectors(hosts[unreachable])
+	assertVerification(testRedundancySettings.MinShards-1, false, true)
+}
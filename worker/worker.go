@@ -25,6 +25,8 @@ import (
 	"go.sia.tech/renterd/alerts"
 	"go.sia.tech/renterd/api"
 	"go.sia.tech/renterd/build"
+	"go.sia.tech/renterd/config"
+	"go.sia.tech/renterd/internal/tracing"
 	"go.sia.tech/renterd/internal/utils"
 	"go.sia.tech/renterd/object"
 	"go.sia.tech/renterd/webhooks"
@@ -106,6 +108,8 @@ type (
 	}
 
 	HostStore interface {
+		RecordHostBandwidth(ctx context.Context, records []api.HostBandwidth) error
+		RecordHostOperations(ctx context.Context, records []api.HostOperationRecord) error
 		RecordHostScans(ctx context.Context, scans []api.HostScan) error
 		RecordPriceTables(ctx context.Context, priceTableUpdate []api.HostPriceTableUpdate) error
 		RecordContractSpending(ctx context.Context, records []api.ContractSpendingRecord) error
@@ -126,8 +130,9 @@ type (
 		AddUploadingSector(ctx context.Context, uID api.UploadID, id types.FileContractID, root types.Hash256) error
 		FinishUpload(ctx context.Context, uID api.UploadID) error
 		MarkPackedSlabsUploaded(ctx context.Context, slabs []api.UploadedPackedSlab) error
-		TrackUpload(ctx context.Context, uID api.UploadID) error
+		TrackUpload(ctx context.Context, uID api.UploadID, priority int, deadline time.Time) error
 		UpdateSlab(ctx context.Context, s object.Slab, contractSet string) error
+		RekeySlab(ctx context.Context, oldKey object.EncryptionKey, s object.Slab, contractSet string) error
 
 		// NOTE: used by worker
 		Bucket(_ context.Context, bucket string) (api.Bucket, error)
@@ -209,12 +214,16 @@ type worker struct {
 
 	accounts        *accounts
 	priceTables     *priceTables
+	rateLimiter     *rateLimiter
 	transportPoolV3 *transportPoolV3
 
 	uploadsMu            sync.Mutex
 	uploadingPackedSlabs map[string]struct{}
 
 	contractSpendingRecorder ContractSpendingRecorder
+	hostInteractionRecorder  HostInteractionRecorder
+	hostOperationRecorder    HostOperationRecorder
+	hostBandwidthRecorder    HostBandwidthRecorder
 	contractLockingDuration  time.Duration
 
 	shutdownCtx       context.Context
@@ -357,13 +366,11 @@ func (w *worker) rhpPriceTableHandler(jc jape.Context) {
 	var err error
 	var hpt api.HostPriceTable
 	defer func() {
-		w.bus.RecordPriceTables(ctx, []api.HostPriceTableUpdate{
-			{
-				HostKey:    rptr.HostKey,
-				Success:    isSuccessfulInteraction(err),
-				Timestamp:  time.Now(),
-				PriceTable: hpt,
-			},
+		w.hostInteractionRecorder.RecordPriceTableUpdate(api.HostPriceTableUpdate{
+			HostKey:    rptr.HostKey,
+			Success:    isSuccessfulInteraction(err),
+			Timestamp:  time.Now(),
+			PriceTable: hpt,
 		})
 	}()
 
@@ -755,6 +762,17 @@ func (w *worker) slabMigrateHandler(jc jape.Context) {
 		up.ContractSet = contractset
 	}
 
+	// decode the hosts to evacuate from the query string
+	var excludedHosts []types.PublicKey
+	for _, v := range jc.Request.URL.Query()["exclude"] {
+		var hk types.PublicKey
+		if err := hk.UnmarshalText([]byte(v)); err != nil {
+			jc.Error(fmt.Errorf("invalid form value %q: %w", "exclude", err), http.StatusBadRequest)
+			return
+		}
+		excludedHosts = append(excludedHosts, hk)
+	}
+
 	// cancel the migration if no contract set is specified
 	if up.ContractSet == "" {
 		jc.Error(fmt.Errorf("migrations require the contract set to be passed as a query string parameter; %w", api.ErrContractSetNotSpecified), http.StatusBadRequest)
@@ -784,7 +802,7 @@ func (w *worker) slabMigrateHandler(jc jape.Context) {
 	}
 
 	// migrate the slab
-	numShardsMigrated, surchargeApplied, err := w.migrate(ctx, slab, up.ContractSet, dlContracts, ulContracts, up.CurrentHeight)
+	numShardsMigrated, surchargeApplied, err := w.migrate(ctx, slab, up.ContractSet, dlContracts, ulContracts, excludedHosts, up.CurrentHeight)
 	if err != nil {
 		jc.Encode(api.MigrateSlabResponse{
 			NumShardsMigrated: numShardsMigrated,
@@ -800,6 +818,72 @@ func (w *worker) slabMigrateHandler(jc jape.Context) {
 	})
 }
 
+func (w *worker) slabRekeyHandlerPOST(jc jape.Context) {
+	ctx := jc.Request.Context()
+
+	// decode the slab key
+	var key object.EncryptionKey
+	if jc.DecodeForm("key", &key) != nil {
+		return
+	} else if key == (object.EncryptionKey{}) {
+		jc.Error(errors.New("rekeying a slab requires the slab key to be passed as a query string parameter"), http.StatusBadRequest)
+		return
+	}
+
+	// decode the contract set from the query string, like migrations a rekey
+	// requires the contract set to be specified explicitly
+	var contractset string
+	if jc.DecodeForm("contractset", &contractset) != nil {
+		return
+	} else if contractset == "" {
+		jc.Error(fmt.Errorf("rekeying a slab requires the contract set to be passed as a query string parameter; %w", api.ErrContractSetNotSpecified), http.StatusBadRequest)
+		return
+	}
+
+	// fetch the upload parameters
+	up, err := w.bus.UploadParams(ctx)
+	if jc.Check("couldn't fetch upload parameters from bus", err) != nil {
+		return
+	}
+
+	// cancel the rekey if consensus is not synced
+	if !up.ConsensusState.Synced {
+		jc.Error(api.ErrConsensusNotSynced, http.StatusServiceUnavailable)
+		return
+	}
+
+	// attach gouging checker to the context
+	ctx = WithGougingChecker(ctx, w.bus, up.GougingParams)
+
+	// fetch the slab
+	slab, err := w.bus.Slab(ctx, key)
+	if utils.IsErr(err, api.ErrSlabNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if jc.Check("couldn't fetch slab from bus", err) != nil {
+		return
+	}
+
+	// fetch all contracts
+	dlContracts, err := w.bus.Contracts(ctx, api.ContractsOpts{})
+	if jc.Check("couldn't fetch contracts from bus", err) != nil {
+		return
+	}
+
+	// fetch upload contracts
+	ulContracts, err := w.bus.Contracts(ctx, api.ContractsOpts{ContractSet: contractset})
+	if jc.Check("couldn't fetch contracts from bus", err) != nil {
+		return
+	}
+
+	// rekey the slab
+	rekeyed, err := w.rekey(ctx, slab, contractset, dlContracts, ulContracts, up.CurrentHeight)
+	if jc.Check("couldn't rekey slab", err) != nil {
+		return
+	}
+	jc.Encode(rekeyed)
+}
+
 func (w *worker) downloadsStatsHandlerGET(jc jape.Context) {
 	stats := w.downloadManager.Stats()
 
@@ -830,6 +914,23 @@ func (w *worker) downloadsStatsHandlerGET(jc jape.Context) {
 	})
 }
 
+func (w *worker) priceTablesStatsHandlerGET(jc jape.Context) {
+	stats := w.priceTables.Stats()
+
+	// compute the hit rate
+	var hitRate float64
+	if total := stats.hits + stats.misses; total > 0 {
+		hitRate = math.Floor(float64(stats.hits)/float64(total)*100*100) / 100
+	}
+
+	// encode response
+	jc.Encode(api.PriceTableStatsResponse{
+		CacheHits:    stats.hits,
+		CacheMisses:  stats.misses,
+		CacheHitRate: hitRate,
+	})
+}
+
 func (w *worker) uploadsStatsHandlerGET(jc jape.Context) {
 	stats := w.uploadManager.Stats()
 
@@ -1041,6 +1142,12 @@ func (w *worker) objectsHandlerPUT(jc jape.Context) {
 		return
 	}
 
+	// decode the timeout from the query string
+	var timeout api.DurationMS
+	if jc.DecodeForm("timeout", &timeout) != nil {
+		return
+	}
+
 	// parse headers and extract object meta
 	metadata := make(api.ObjectUserMetadata)
 	for k, v := range jc.Request.Header {
@@ -1057,10 +1164,14 @@ func (w *worker) objectsHandlerPUT(jc jape.Context) {
 		ContentLength: jc.Request.ContentLength,
 		MimeType:      mimeType,
 		Metadata:      metadata,
+		Timeout:       time.Duration(timeout),
 	})
 	if utils.IsErr(err, api.ErrInvalidRedundancySettings) {
 		jc.Error(err, http.StatusBadRequest)
 		return
+	} else if utils.IsErr(err, api.ErrObjectUserMetadataTooLarge) {
+		jc.Error(err, http.StatusBadRequest)
+		return
 	} else if utils.IsErr(err, api.ErrBucketNotFound) {
 		jc.Error(err, http.StatusNotFound)
 		return
@@ -1070,6 +1181,9 @@ func (w *worker) objectsHandlerPUT(jc jape.Context) {
 	} else if utils.IsErr(err, api.ErrConsensusNotSynced) {
 		jc.Error(err, http.StatusServiceUnavailable)
 		return
+	} else if utils.IsErr(err, api.ErrInsufficientContracts) {
+		jc.Error(err, http.StatusServiceUnavailable)
+		return
 	} else if jc.Check("couldn't upload object", err) != nil {
 		return
 	}
@@ -1078,6 +1192,77 @@ func (w *worker) objectsHandlerPUT(jc jape.Context) {
 	jc.ResponseWriter.Header().Set("ETag", api.FormatETag(resp.ETag))
 }
 
+func (w *worker) objectsHandlerPATCH(jc jape.Context) {
+	jc.Custom((*[]byte)(nil), nil)
+	ctx := jc.Request.Context()
+
+	// grab the path
+	path := jc.PathParam("path")
+
+	// decode the contract set from the query string
+	var contractset string
+	if jc.DecodeForm("contractset", &contractset) != nil {
+		return
+	}
+
+	// decode the bucket from the query string
+	bucket := api.DefaultBucketName
+	if jc.DecodeForm("bucket", &bucket) != nil {
+		return
+	}
+
+	// parse the range that is overwritten
+	if jc.Request.Header.Get("Range") == "" {
+		jc.Error(errors.New("patching an object requires a range header"), http.StatusBadRequest)
+		return
+	}
+	dr, err := api.ParseDownloadRange(jc.Request)
+	if errors.Is(err, http_range.ErrInvalid) || errors.Is(err, api.ErrMultiRangeNotSupported) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		jc.Error(err, http.StatusInternalServerError)
+		return
+	} else if dr.Length != jc.Request.ContentLength {
+		jc.Error(fmt.Errorf("range length %d doesn't match content length %d", dr.Length, jc.Request.ContentLength), http.StatusBadRequest)
+		return
+	}
+
+	// patch the object
+	resp, err := w.PatchObject(ctx, jc.Request.Body, bucket, path, api.PatchObjectOptions{
+		ContractSet: contractset,
+		Offset:      dr.Offset,
+		Length:      dr.Length,
+	})
+	if utils.IsErr(err, api.ErrObjectNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if utils.IsErr(err, api.ErrObjectModified) {
+		jc.Error(err, http.StatusPreconditionFailed)
+		return
+	} else if errors.Is(err, http_range.ErrInvalid) {
+		jc.Error(err, http.StatusRequestedRangeNotSatisfiable)
+		return
+	} else if utils.IsErr(err, errInvalidPatchLength) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if utils.IsErr(err, api.ErrContractSetNotSpecified) {
+		jc.Error(err, http.StatusBadRequest)
+		return
+	} else if utils.IsErr(err, api.ErrConsensusNotSynced) {
+		jc.Error(err, http.StatusServiceUnavailable)
+		return
+	} else if utils.IsErr(err, api.ErrInsufficientContracts) {
+		jc.Error(err, http.StatusServiceUnavailable)
+		return
+	} else if jc.Check("couldn't patch object", err) != nil {
+		return
+	}
+
+	// set etag header
+	jc.ResponseWriter.Header().Set("ETag", api.FormatETag(resp.ETag))
+}
+
 func (w *worker) multipartUploadHandlerPUT(jc jape.Context) {
 	jc.Custom((*[]byte)(nil), nil)
 	ctx := jc.Request.Context()
@@ -1152,6 +1337,9 @@ func (w *worker) multipartUploadHandlerPUT(jc jape.Context) {
 	} else if utils.IsErr(err, api.ErrConsensusNotSynced) {
 		jc.Error(err, http.StatusServiceUnavailable)
 		return
+	} else if utils.IsErr(err, api.ErrInsufficientContracts) {
+		jc.Error(err, http.StatusServiceUnavailable)
+		return
 	} else if utils.IsErr(err, api.ErrMultipartUploadNotFound) {
 		jc.Error(err, http.StatusNotFound)
 		return
@@ -1183,6 +1371,21 @@ func (w *worker) objectsHandlerDELETE(jc jape.Context) {
 	jc.Check("couldn't delete object", err)
 }
 
+func (w *worker) objectsVerifyHandlerPOST(jc jape.Context) {
+	bucket := api.DefaultBucketName
+	if jc.DecodeForm("bucket", &bucket) != nil {
+		return
+	}
+	resp, err := w.VerifyObject(jc.Request.Context(), bucket, jc.PathParam("path"))
+	if utils.IsErr(err, api.ErrObjectNotFound) {
+		jc.Error(err, http.StatusNotFound)
+		return
+	} else if jc.Check("couldn't verify object", err) != nil {
+		return
+	}
+	jc.Encode(resp)
+}
+
 func (w *worker) rhpContractsHandlerGET(jc jape.Context) {
 	ctx := jc.Request.Context()
 
@@ -1223,6 +1426,24 @@ func (w *worker) idHandlerGET(jc jape.Context) {
 	jc.Encode(w.id)
 }
 
+func (w *worker) rateLimitHandlerGET(jc jape.Context) {
+	jc.Encode(w.rateLimiter.Limits())
+}
+
+func (w *worker) rateLimitHandlerPUT(jc jape.Context) {
+	var limits api.WorkerRateLimits
+	if jc.Decode(&limits) != nil {
+		return
+	}
+	w.UpdateRateLimits(limits)
+}
+
+// UpdateRateLimits updates the bandwidth limits of the worker, the limits
+// apply to existing connections to hosts as well.
+func (w *worker) UpdateRateLimits(limits api.WorkerRateLimits) {
+	w.rateLimiter.SetLimits(limits)
+}
+
 func (w *worker) memoryGET(jc jape.Context) {
 	jc.Encode(api.MemoryResponse{
 		Download: w.downloadManager.mm.Status(),
@@ -1254,36 +1475,37 @@ func (w *worker) stateHandlerGET(jc jape.Context) {
 }
 
 // New returns an HTTP handler that serves the worker API.
-func New(masterKey [32]byte, id string, b Bus, contractLockingDuration, busFlushInterval, downloadOverdriveTimeout, uploadOverdriveTimeout time.Duration, downloadMaxOverdrive, uploadMaxOverdrive, downloadMaxMemory, uploadMaxMemory uint64, allowPrivateIPs bool, l *zap.Logger) (*worker, error) {
-	if contractLockingDuration == 0 {
+func New(cfg config.Worker, masterKey [32]byte, b Bus, l *zap.Logger) (*worker, error) {
+	if cfg.ContractLockTimeout == 0 {
 		return nil, errors.New("contract lock duration must be positive")
 	}
-	if busFlushInterval == 0 {
+	if cfg.BusFlushInterval == 0 {
 		return nil, errors.New("bus flush interval must be positive")
 	}
-	if downloadOverdriveTimeout == 0 {
+	if cfg.DownloadOverdriveTimeout == 0 {
 		return nil, errors.New("download overdrive timeout must be positive")
 	}
-	if uploadOverdriveTimeout == 0 {
+	if cfg.UploadOverdriveTimeout == 0 {
 		return nil, errors.New("upload overdrive timeout must be positive")
 	}
-	if downloadMaxMemory == 0 {
+	if cfg.DownloadMaxMemory == 0 {
 		return nil, errors.New("downloadMaxMemory cannot be 0")
 	}
-	if uploadMaxMemory == 0 {
+	if cfg.UploadMaxMemory == 0 {
 		return nil, errors.New("uploadMaxMemory cannot be 0")
 	}
 
-	l = l.Named("worker").Named(id)
+	l = l.Named("worker").Named(cfg.ID)
 	ctx, cancel := context.WithCancel(context.Background())
 	w := &worker{
-		alerts:                  alerts.WithOrigin(b, fmt.Sprintf("worker.%s", id)),
-		allowPrivateIPs:         allowPrivateIPs,
-		contractLockingDuration: contractLockingDuration,
-		id:                      id,
+		alerts:                  alerts.WithOrigin(b, fmt.Sprintf("worker.%s", cfg.ID)),
+		allowPrivateIPs:         cfg.AllowPrivateIPs,
+		contractLockingDuration: cfg.ContractLockTimeout,
+		id:                      cfg.ID,
 		bus:                     b,
 		masterKey:               masterKey,
 		logger:                  l.Sugar(),
+		rateLimiter:             newRateLimiter(api.WorkerRateLimits{}),
 		startTime:               time.Now(),
 		uploadingPackedSlabs:    make(map[string]struct{}),
 		shutdownCtx:             ctx,
@@ -1292,23 +1514,31 @@ func New(masterKey [32]byte, id string, b Bus, contractLockingDuration, busFlush
 
 	w.initAccounts(b)
 	w.initPriceTables()
+	w.initHostBandwidthRecorder(cfg.BusFlushInterval)
 	w.initTransportPool()
 
-	w.initDownloadManager(downloadMaxMemory, downloadMaxOverdrive, downloadOverdriveTimeout, l.Named("downloadmanager").Sugar())
-	w.initUploadManager(uploadMaxMemory, uploadMaxOverdrive, uploadOverdriveTimeout, l.Named("uploadmanager").Sugar())
+	w.initDownloadManager(cfg.DownloadMaxMemory, cfg.DownloadMaxOverdrive, cfg.DownloadOverfetch, cfg.DownloadCacheSize, cfg.DownloadOverdriveTimeout, l.Named("downloadmanager").Sugar())
+	w.initUploadManager(cfg.UploadMaxMemory, cfg.UploadMaxOverdrive, cfg.UploadMaxConcurrentSlabs, cfg.UploadMaxSectorRetries, cfg.UploadOverdriveTimeout, l.Named("uploadmanager").Sugar())
+
+	w.initContractSpendingRecorder(cfg.BusFlushInterval)
+	w.initHostInteractionRecorder(cfg.BusFlushInterval)
+	w.initHostOperationRecorder(cfg.BusFlushInterval)
 
-	w.initContractSpendingRecorder(busFlushInterval)
+	go w.threadedUploadPackedSlabsLoop()
 	return w, nil
 }
 
 // Handler returns an HTTP handler that serves the worker API.
 func (w *worker) Handler() http.Handler {
-	return jape.Mux(map[string]jape.Handler{
+	return tracing.Middleware(jape.Mux(map[string]jape.Handler{
 		"GET    /account/:hostkey": w.accountHandlerGET,
 		"GET    /id":               w.idHandlerGET,
 
 		"GET /memory": w.memoryGET,
 
+		"GET    /ratelimit": w.rateLimitHandlerGET,
+		"PUT    /ratelimit": w.rateLimitHandlerPUT,
+
 		"GET    /rhp/contracts":              w.rhpContractsHandlerGET,
 		"POST   /rhp/contract/:id/broadcast": w.rhpBroadcastHandler,
 		"POST   /rhp/contract/:id/prune":     w.rhpPruneContractHandlerPOST,
@@ -1320,19 +1550,24 @@ func (w *worker) Handler() http.Handler {
 		"POST   /rhp/sync":                   w.rhpSyncHandler,
 		"POST   /rhp/pricetable":             w.rhpPriceTableHandler,
 
-		"GET    /stats/downloads": w.downloadsStatsHandlerGET,
-		"GET    /stats/uploads":   w.uploadsStatsHandlerGET,
-		"POST   /slab/migrate":    w.slabMigrateHandler,
+		"GET    /stats/downloads":   w.downloadsStatsHandlerGET,
+		"GET    /stats/pricetables": w.priceTablesStatsHandlerGET,
+		"GET    /stats/uploads":     w.uploadsStatsHandlerGET,
+		"POST   /slab/migrate":      w.slabMigrateHandler,
+		"POST   /slab/rekey":        w.slabRekeyHandlerPOST,
 
 		"HEAD   /objects/*path": w.objectsHandlerHEAD,
 		"GET    /objects/*path": w.objectsHandlerGET,
 		"PUT    /objects/*path": w.objectsHandlerPUT,
+		"PATCH  /objects/*path": w.objectsHandlerPATCH,
 		"DELETE /objects/*path": w.objectsHandlerDELETE,
 
 		"PUT    /multipart/*path": w.multipartUploadHandlerPUT,
 
+		"POST   /verify/objects/*path": w.objectsVerifyHandlerPOST,
+
 		"GET    /state": w.stateHandlerGET,
-	})
+	}))
 }
 
 // Shutdown shuts down the worker.
@@ -1346,6 +1581,9 @@ func (w *worker) Shutdown(ctx context.Context) error {
 
 	// stop recorders
 	w.contractSpendingRecorder.Stop(ctx)
+	w.hostInteractionRecorder.Stop(ctx)
+	w.hostOperationRecorder.Stop(ctx)
+	w.hostBandwidthRecorder.Stop(ctx)
 	return nil
 }
 
@@ -1453,23 +1691,15 @@ func (w *worker) scanHost(ctx context.Context, timeout time.Duration, hostKey ty
 	default:
 	}
 
-	// record host scan - make sure this isn't interrupted by the same context
-	// used to time out the scan itself because otherwise we won't be able to
-	// record scans that timed out.
-	recordCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	scanErr := w.bus.RecordHostScans(recordCtx, []api.HostScan{
-		{
-			HostKey:    hostKey,
-			Success:    isSuccessfulInteraction(err),
-			Timestamp:  time.Now(),
-			Settings:   settings,
-			PriceTable: pt,
-		},
+	// record host scan - the recorder flushes it to the bus using its own
+	// context so scans that timed out are recorded as well
+	w.hostInteractionRecorder.RecordHostScan(api.HostScan{
+		HostKey:    hostKey,
+		Success:    isSuccessfulInteraction(err),
+		Timestamp:  time.Now(),
+		Settings:   settings,
+		PriceTable: pt,
 	})
-	if scanErr != nil {
-		logger.Errorw("failed to record host scan", zap.Error(scanErr))
-	}
 	return settings, pt, duration, err
 }
 
@@ -1622,18 +1852,24 @@ func (w *worker) UploadObject(ctx context.Context, r io.Reader, bucket, path str
 		return nil, fmt.Errorf("couldn't fetch contracts from bus: %w", err)
 	}
 
-	// upload
-	eTag, err := w.upload(ctx, bucket, path, r, contracts,
+	// prepare the upload options
+	uploadOpts := []UploadOption{
 		WithBlockHeight(up.CurrentHeight),
 		WithContractSet(up.ContractSet),
 		WithMimeType(opts.MimeType),
 		WithPacking(up.UploadPacking),
 		WithRedundancySettings(up.RedundancySettings),
 		WithObjectUserMetadata(opts.Metadata),
-	)
+	}
+	if opts.Timeout > 0 {
+		uploadOpts = append(uploadOpts, WithDeadline(time.Now().Add(opts.Timeout)))
+	}
+
+	// upload
+	eTag, err := w.upload(ctx, bucket, path, r, contracts, uploadOpts...)
 	if err != nil {
 		w.logger.With(zap.Error(err)).With("path", path).With("bucket", bucket).Error("failed to upload object")
-		if !errors.Is(err, ErrShuttingDown) && !errors.Is(err, errUploadInterrupted) && !errors.Is(err, context.Canceled) {
+		if !errors.Is(err, ErrShuttingDown) && !errors.Is(err, errUploadInterrupted) && !errors.Is(err, context.Canceled) && !errors.Is(err, api.ErrObjectUserMetadataTooLarge) {
 			w.registerAlert(newUploadFailedAlert(bucket, path, up.ContractSet, opts.MimeType, up.RedundancySettings.MinShards, up.RedundancySettings.TotalShards, len(contracts), up.UploadPacking, false, err))
 		}
 		return nil, fmt.Errorf("couldn't upload object: %w", err)
@@ -1643,6 +1879,77 @@ func (w *worker) UploadObject(ctx context.Context, r io.Reader, bucket, path str
 	}, nil
 }
 
+func (w *worker) PatchObject(ctx context.Context, r io.Reader, bucket, path string, opts api.PatchObjectOptions) (*api.PatchObjectResponse, error) {
+	// fetch the object, the patch has to start within the object or at its
+	// very end in which case the data is appended
+	res, err := w.bus.Object(ctx, bucket, path, api.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch object: %w", err)
+	} else if res.Object == nil {
+		return nil, errors.New("object is a directory")
+	} else if opts.Offset < 0 || opts.Length <= 0 || opts.Offset > res.Object.Size {
+		return nil, http_range.ErrInvalid
+	}
+
+	// prepare upload params
+	up, err := w.prepareUploadParams(ctx, bucket, opts.ContractSet, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// attach gouging checker to the context
+	ctx = WithGougingChecker(ctx, w.bus, up.GougingParams)
+
+	// fetch contracts
+	contracts, err := w.bus.Contracts(ctx, api.ContractsOpts{ContractSet: up.ContractSet})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch contracts from bus: %w", err)
+	}
+
+	// upload the patch
+	eTag, err := w.upload(ctx, bucket, path, r, contracts,
+		WithBlockHeight(up.CurrentHeight),
+		WithContractSet(up.ContractSet),
+		WithPacking(up.UploadPacking),
+		WithRedundancySettings(up.RedundancySettings),
+		WithPatch(*res.Object, uint64(opts.Offset), uint64(opts.Length)),
+	)
+	if err != nil {
+		w.logger.With(zap.Error(err)).With("path", path).With("bucket", bucket).Error("failed to patch object")
+		return nil, fmt.Errorf("couldn't patch object: %w", err)
+	}
+	return &api.PatchObjectResponse{
+		ETag: eTag,
+	}, nil
+}
+
+func (w *worker) VerifyObject(ctx context.Context, bucket, path string) (*api.VerifyObjectResponse, error) {
+	// fetch object
+	res, err := w.bus.Object(ctx, bucket, path, api.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch object: %w", err)
+	} else if res.Object == nil || res.Object.Object == nil {
+		return nil, api.ErrObjectNotFound
+	}
+
+	// fetch gouging params
+	gp, err := w.bus.GougingParams(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch gouging parameters from bus: %w", err)
+	}
+
+	// fetch all contracts
+	contracts, err := w.bus.Contracts(ctx, api.ContractsOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch contracts from bus: %w", err)
+	}
+
+	// verify the object
+	ctx = WithGougingChecker(ctx, w.bus, gp)
+	resp := w.downloadManager.VerifyObject(ctx, *res.Object.Object, contracts)
+	return &resp, nil
+}
+
 func (w *worker) UploadMultipartUploadPart(ctx context.Context, r io.Reader, bucket, path, uploadID string, partNumber int, opts api.UploadMultipartUploadPartOptions) (*api.UploadMultipartUploadPartResponse, error) {
 	// prepare upload params
 	up, err := w.prepareUploadParams(ctx, bucket, opts.ContractSet, opts.MinShards, opts.TotalShards)